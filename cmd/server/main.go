@@ -29,13 +29,20 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	if err := database.ConfigureRowLevelSecurity(cfg.DBRowLevelSecurity); err != nil {
+		log.Fatalf("Failed to configure row-level security: %v", err)
+	}
+
 	// Seed database with initial data
 	if err := database.Seed(); err != nil {
 		log.Printf("Warning: Failed to seed database: %v", err)
 	}
 
 	// Setup routes
-	router := routes.SetupRoutes(cfg)
+	router, err := routes.SetupRoutes(cfg)
+	if err != nil {
+		log.Fatalf("Failed to setup routes: %v", err)
+	}
 
 	// Create HTTP server
 	server := &http.Server{
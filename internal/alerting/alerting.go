@@ -0,0 +1,87 @@
+// Package alerting notifies people of security alerts over email, Slack and
+// PagerDuty.
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/config"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/mailer"
+)
+
+// Events of an alert's life notified to channels
+const (
+	EventTrigger     = "trigger"
+	EventAcknowledge = "acknowledge"
+	EventResolve     = "resolve"
+)
+
+// Alert is a security alert as notified to a channel
+type Alert struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	Rule           string    `json:"rule"`
+	Severity       string    `json:"severity"` // low, medium, high or critical
+	Summary        string    `json:"summary"`
+	UserID         *uint     `json:"user_id,omitempty"`
+	IPAddress      string    `json:"ip_address,omitempty"`
+	EventCount     int       `json:"event_count"`
+	FirstSeenAt    time.Time `json:"first_seen_at"`
+	LastSeenAt     time.Time `json:"last_seen_at"`
+	Details        string    `json:"details,omitempty"` // JSON evidence
+	URL            string    `json:"url,omitempty"`     // Of the alert in the API
+}
+
+// Notifier delivers alert events to a channel
+type Notifier interface {
+	// Notify delivers an event of an alert. Channels without a notion of
+	// acknowledging or resolving only deliver triggers.
+	Notify(ctx context.Context, event string, alert Alert) error
+	// Name returns the channel name for logging
+	Name() string
+}
+
+// NewNotifiers creates the notifiers of the channels selected in the
+// configuration. m may be nil when email isn't selected.
+func NewNotifiers(cfg *config.Config, m mailer.Mailer) ([]Notifier, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var notifiers []Notifier
+	for _, channel := range cfg.SecurityAlertChannels {
+		switch channel {
+		case "email":
+			if m == nil {
+				return nil, fmt.Errorf("SMTP_HOST is required for email alerts")
+			}
+			notifier, err := NewEmailNotifier(m, cfg.SecurityAlertEmails)
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, notifier)
+		case "slack":
+			notifier, err := NewSlackNotifier(client, cfg.SecurityAlertSlackWebhookURL)
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, notifier)
+		case "pagerduty":
+			notifier, err := NewPagerDutyNotifier(client, cfg.SecurityAlertPagerDutyRoutingKey)
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, notifier)
+		default:
+			return nil, fmt.Errorf("unknown alert channel: %s", channel)
+		}
+	}
+	return notifiers, nil
+}
+
+// title is the one line description of an alert
+func title(alert Alert) string {
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(alert.Severity), alert.Rule, alert.Summary)
+}
@@ -0,0 +1,68 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/mailer"
+)
+
+// EmailNotifier emails new alerts to a fixed list of recipients
+type EmailNotifier struct {
+	mailer     mailer.Mailer
+	recipients []string
+}
+
+// NewEmailNotifier creates a new email notifier
+func NewEmailNotifier(m mailer.Mailer, recipients []string) (*EmailNotifier, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("alert email recipients are not configured")
+	}
+	return &EmailNotifier{mailer: m, recipients: recipients}, nil
+}
+
+// Notify emails a triggered alert to every recipient
+func (n *EmailNotifier) Notify(ctx context.Context, event string, alert Alert) error {
+	if event != EventTrigger {
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "A security alert was raised:\n\n")
+	fmt.Fprintf(&body, "Alert:        %d\n", alert.ID)
+	fmt.Fprintf(&body, "Organization: %d\n", alert.OrganizationID)
+	fmt.Fprintf(&body, "Rule:         %s\n", alert.Rule)
+	fmt.Fprintf(&body, "Severity:     %s\n", alert.Severity)
+	fmt.Fprintf(&body, "Summary:      %s\n", alert.Summary)
+	if alert.UserID != nil {
+		fmt.Fprintf(&body, "User:         %d\n", *alert.UserID)
+	}
+	if alert.IPAddress != "" {
+		fmt.Fprintf(&body, "IP address:   %s\n", alert.IPAddress)
+	}
+	fmt.Fprintf(&body, "Events:       %d\n", alert.EventCount)
+	fmt.Fprintf(&body, "First seen:   %s\n", alert.FirstSeenAt.Format(time.RFC1123))
+	fmt.Fprintf(&body, "Last seen:    %s\n", alert.LastSeenAt.Format(time.RFC1123))
+	if alert.URL != "" {
+		fmt.Fprintf(&body, "\nAcknowledge or resolve it at %s\n", alert.URL)
+	}
+
+	var errs []error
+	for _, recipient := range n.recipients {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := n.mailer.Send(recipient, title(alert), body.String()); err != nil {
+			errs = append(errs, fmt.Errorf("failed to email %s: %w", recipient, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Name returns the channel name
+func (n *EmailNotifier) Name() string {
+	return "email"
+}
@@ -0,0 +1,125 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// pagerDutyEventsURL is the endpoint of the PagerDuty Events API v2
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyNotifier triggers PagerDuty incidents for alerts, and
+// acknowledges and resolves them along with the alerts
+type PagerDutyNotifier struct {
+	client     *http.Client
+	routingKey string
+	source     string
+}
+
+// pagerDutyEvent is an event of the Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string `json:"summary"`
+	Source        string `json:"source"`
+	Severity      string `json:"severity"`
+	Timestamp     string `json:"timestamp"`
+	Component     string `json:"component"`
+	Class         string `json:"class"`
+	CustomDetails Alert  `json:"custom_details"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// NewPagerDutyNotifier creates a new PagerDuty notifier sending to the
+// service of the integration's routing key
+func NewPagerDutyNotifier(client *http.Client, routingKey string) (*PagerDutyNotifier, error) {
+	if routingKey == "" {
+		return nil, fmt.Errorf("PagerDuty routing key is not configured")
+	}
+	source, _ := os.Hostname()
+	if source == "" {
+		source = "datamanagement"
+	}
+	return &PagerDutyNotifier{client: client, routingKey: routingKey, source: source}, nil
+}
+
+// Notify sends the event for the alert's incident, which is keyed by the
+// alert ID
+func (n *PagerDutyNotifier) Notify(ctx context.Context, event string, alert Alert) error {
+	message := pagerDutyEvent{
+		RoutingKey:  n.routingKey,
+		EventAction: event,
+		DedupKey:    "security-alert-" + strconv.Itoa(int(alert.ID)),
+	}
+	if event == EventTrigger {
+		message.Payload = &pagerDutyPayload{
+			Summary:       title(alert),
+			Source:        n.source,
+			Severity:      pagerDutySeverity(alert.Severity),
+			Timestamp:     alert.FirstSeenAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+			Component:     "organization-" + strconv.Itoa(int(alert.OrganizationID)),
+			Class:         alert.Rule,
+			CustomDetails: alert,
+		}
+		if alert.URL != "" {
+			message.Links = []pagerDutyLink{{Href: alert.URL, Text: "Security alert"}}
+		}
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode PagerDuty event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pagerDutyEventsURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create PagerDuty request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach PagerDuty: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PagerDuty returned status %d: %s", resp.StatusCode, message)
+	}
+	return nil
+}
+
+// pagerDutySeverity maps alert severities to those of PagerDuty
+func pagerDutySeverity(severity string) string {
+	switch severity {
+	case "critical":
+		return "critical"
+	case "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// Name returns the channel name
+func (n *PagerDutyNotifier) Name() string {
+	return "pagerduty"
+}
@@ -0,0 +1,79 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SlackNotifier posts new alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	client     *http.Client
+	webhookURL string
+}
+
+// NewSlackNotifier creates a new Slack notifier
+func NewSlackNotifier(client *http.Client, webhookURL string) (*SlackNotifier, error) {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || parsed.Host == "" || parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Slack webhook URL")
+	}
+	return &SlackNotifier{client: client, webhookURL: webhookURL}, nil
+}
+
+// Notify posts a triggered alert as a message
+func (n *SlackNotifier) Notify(ctx context.Context, event string, alert Alert) error {
+	if event != EventTrigger {
+		return nil
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, ":rotating_light: *%s*\n", slackEscape(title(alert)))
+	fmt.Fprintf(&text, "Alert %d in organization %d, %d events", alert.ID, alert.OrganizationID, alert.EventCount)
+	if alert.UserID != nil {
+		fmt.Fprintf(&text, ", user %d", *alert.UserID)
+	}
+	if alert.IPAddress != "" {
+		fmt.Fprintf(&text, ", IP %s", slackEscape(alert.IPAddress))
+	}
+	if alert.URL != "" {
+		fmt.Fprintf(&text, "\n<%s|Review the alert>", alert.URL)
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text.String()})
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Slack: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Slack returned status %d: %s", resp.StatusCode, message)
+	}
+	return nil
+}
+
+// slackEscape escapes the characters Slack treats as markup
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// Name returns the channel name
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// ActivityHandler handles the activity feed of the current user
+type ActivityHandler struct {
+	auditService *services.AuditService
+}
+
+// NewActivityHandler creates a new activity handler
+func NewActivityHandler(auditService *services.AuditService) *ActivityHandler {
+	return &ActivityHandler{
+		auditService: auditService,
+	}
+}
+
+// ActivityResponse represents a page of the activity feed
+type ActivityResponse struct {
+	Data       []services.ActivityItem `json:"data"`
+	NextCursor *uint                   `json:"next_cursor"`
+	Limit      int                     `json:"limit"`
+}
+
+// GetActivity returns the current user's recent document views, edits,
+// shares and comments, newest first. Pass next_cursor as cursor to get the
+// following page.
+func (h *ActivityHandler) GetActivity(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	_, limit := parsePagination(c)
+
+	var cursor uint
+	if value := c.Query("cursor"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		cursor = uint(parsed)
+	}
+
+	var types []services.ActivityType
+	if value := c.Query("type"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			t := services.ActivityType(strings.TrimSpace(name))
+			if !services.ValidActivityType(t) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid activity type: " + string(t)})
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	items, next, err := h.auditService.GetUserActivity(c.Request.Context(), user.ID, types, cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get activity"})
+		return
+	}
+
+	response := &ActivityResponse{
+		Data:  items,
+		Limit: limit,
+	}
+	if next != 0 {
+		response.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, response)
+}
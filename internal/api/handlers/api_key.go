@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// APIKeyHandler handles the personal API keys of users
+type APIKeyHandler struct {
+	apiKeyService   *services.APIKeyService
+	apiUsageService *services.APIUsageService
+	auditService    *services.AuditService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService, apiUsageService *services.APIUsageService, auditService *services.AuditService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService:   apiKeyService,
+		apiUsageService: apiUsageService,
+		auditService:    auditService,
+	}
+}
+
+// CreateAPIKeyRequest names a new API key and the areas it may use
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`          // Such as documents:read or folders:write
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0,max=3650"` // 0 never expires
+}
+
+// CreateAPIKeyResponse returns a new API key, shown only once
+type CreateAPIKeyResponse struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey issues a new API key for the current user
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		expiry := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	apiKey, key, err := h.apiKeyService.Create(user.ID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		var scopeErr *services.ScopeError
+		if errors.As(err, &scopeErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": scopeErr.Error(), "areas": services.ScopeAreas})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "api_key_created", "api_key", strconv.Itoa(int(apiKey.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":       apiKey.Name,
+		"scopes":     apiKey.Scopes,
+		"expires_at": apiKey.ExpiresAt,
+	})
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// GetAPIKeys lists the current user's API keys
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	keys, err := h.apiKeyService.GetKeys(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+// RevokeAPIKey stops one of the current user's API keys from working
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	apiKey, err := h.apiKeyService.Revoke(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "api_key_revoked", "api_key", strconv.Itoa(int(apiKey.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name": apiKey.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// APIQuotaRequest sets the daily quotas of an API key or OAuth client. A
+// null quota uses the default and 0 is unlimited.
+type APIQuotaRequest struct {
+	DailyRequestQuota     *int64 `json:"daily_request_quota" binding:"omitempty,min=0"`
+	DailyBandwidthQuotaMB *int64 `json:"daily_bandwidth_quota_mb" binding:"omitempty,min=0"`
+}
+
+// GetAPIKeyUsage returns the daily usage of one of the current user's API
+// keys over the last ?days=, 30 by default
+func (h *APIKeyHandler) GetAPIKeyUsage(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	apiKey, err := h.apiKeyService.GetKey(user.OrganizationID, id)
+	if err != nil || apiKey.UserID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	h.writeUsage(c, h.apiUsageService.ForAPIKey(apiKey))
+}
+
+// GetUserAPIKeyUsage returns the daily usage of any API key of the admin's
+// organization
+func (h *APIKeyHandler) GetUserAPIKeyUsage(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	apiKey, err := h.apiKeyService.GetKey(admin.OrganizationID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	h.writeUsage(c, h.apiUsageService.ForAPIKey(apiKey))
+}
+
+// SetAPIKeyQuota sets the daily quotas of an API key
+func (h *APIKeyHandler) SetAPIKeyQuota(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var req APIQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	apiKey, err := h.apiKeyService.SetQuota(admin.OrganizationID, id, req.DailyRequestQuota, req.DailyBandwidthQuotaMB)
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set API key quota"})
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "api_key_quota_set", "api_key", strconv.Itoa(int(apiKey.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"daily_request_quota":      req.DailyRequestQuota,
+		"daily_bandwidth_quota_mb": req.DailyBandwidthQuotaMB,
+	})
+
+	c.JSON(http.StatusOK, apiKey)
+}
+
+// writeUsage responds with the usage report of a credential
+func (h *APIKeyHandler) writeUsage(c *gin.Context, credential services.APICredential) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > services.MaxUsageDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+
+	report, err := h.apiUsageService.GetReport(credential, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API usage"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
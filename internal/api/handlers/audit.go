@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// maxAuditExportSize is the largest signed audit export accepted for
+// verification
+const maxAuditExportSize = 256 << 20
+
+// AuditHandler queries, exports and retrieves archives of the
+// organization's audit log
+type AuditHandler struct {
+	auditExportService  *services.AuditExportService
+	auditArchiveService *services.AuditArchiveService
+	auditService        *services.AuditService
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditExportService *services.AuditExportService, auditArchiveService *services.AuditArchiveService, auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditExportService:  auditExportService,
+		auditArchiveService: auditArchiveService,
+		auditService:        auditService,
+	}
+}
+
+// auditExportFlushEvery is the number of entries streamed between flushes
+// of an audit export
+const auditExportFlushEvery = 1000
+
+// auditExportHeader lists the CSV columns of an audit export
+var auditExportHeader = []string{
+	"id", "timestamp", "user_id", "username", "document_id",
+	"action", "resource_type", "resource_id", "ip_address", "user_agent", "details",
+}
+
+// parseAuditTime reads a time query parameter, writing an error response
+// when it is invalid. A to date without a time includes that day.
+func parseAuditTime(c *gin.Context, name string) (time.Time, bool) {
+	value := c.Query(name)
+	parsed, err := parseDateParam(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ", expected RFC 3339 or YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	if name == "to" && len(value) == len("2006-01-02") {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return parsed, true
+}
+
+// parseAuditPeriod reads the from and to query parameters, writing an error
+// response when they are missing or invalid
+func parseAuditPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	from, ok := parseAuditTime(c, "from")
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	to, ok := parseAuditTime(c, "to")
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// parseAuditFilter reads the audit log filter from the query, writing an
+// error response when it is invalid. action takes a comma separated list;
+// from and to are optional.
+func parseAuditFilter(c *gin.Context) (*services.AuditLogFilter, bool) {
+	filter := &services.AuditLogFilter{
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		IPAddress:    c.Query("ip_address"),
+		Query:        strings.TrimSpace(c.Query("q")),
+	}
+	for _, action := range strings.Split(c.Query("action"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			filter.Actions = append(filter.Actions, action)
+		}
+	}
+
+	idParams := map[string]**uint{
+		"user_id":     &filter.UserID,
+		"document_id": &filter.DocumentID,
+	}
+	for name, target := range idParams {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+			return nil, false
+		}
+		parsed := uint(id)
+		*target = &parsed
+	}
+
+	var ok bool
+	if c.Query("from") != "" {
+		if filter.From, ok = parseAuditTime(c, "from"); !ok {
+			return nil, false
+		}
+	}
+	if c.Query("to") != "" {
+		if filter.To, ok = parseAuditTime(c, "to"); !ok {
+			return nil, false
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return nil, false
+	}
+	return filter, true
+}
+
+// GetAuditLogs lists the organization's audit entries matching the filter,
+// newest first
+func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	page, limit := parsePagination(c)
+
+	logs, total, err := h.auditService.GetAuditLogs(user.OrganizationID, filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  logs,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// ExportAuditLogs streams all of the organization's audit entries matching
+// the filter, oldest first, as CSV, NDJSON or a JSON array
+func (h *AuditHandler) ExportAuditLogs(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	// Each format writes an entry, and finishes after the last one
+	var write func(services.AuditExportEntry) error
+	var finish func() error
+	format := c.DefaultQuery("format", "csv")
+	contentType := ""
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+		writer := csv.NewWriter(c.Writer)
+		header := false
+		write = func(entry services.AuditExportEntry) error {
+			if !header {
+				header = true
+				if err := writer.Write(auditExportHeader); err != nil {
+					return err
+				}
+			}
+			documentID := ""
+			if entry.DocumentID != nil {
+				documentID = strconv.Itoa(int(*entry.DocumentID))
+			}
+			return writer.Write([]string{
+				strconv.Itoa(int(entry.ID)),
+				entry.Timestamp.Format(time.RFC3339),
+				strconv.Itoa(int(entry.UserID)),
+				entry.Username,
+				documentID,
+				entry.Action,
+				entry.ResourceType,
+				entry.ResourceID,
+				entry.IPAddress,
+				entry.UserAgent,
+				string(entry.Details),
+			})
+		}
+		finish = func() error {
+			if !header {
+				if err := writer.Write(auditExportHeader); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		}
+	case "ndjson":
+		contentType = "application/x-ndjson"
+		encoder := json.NewEncoder(c.Writer)
+		write = func(entry services.AuditExportEntry) error {
+			return encoder.Encode(entry)
+		}
+		finish = func() error { return nil }
+	case "json":
+		contentType = "application/json; charset=utf-8"
+		separator := "["
+		write = func(entry services.AuditExportEntry) error {
+			encoded, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(c.Writer, separator); err != nil {
+				return err
+			}
+			separator = ",\n"
+			_, err = c.Writer.Write(encoded)
+			return err
+		}
+		finish = func() error {
+			if separator == "[" {
+				_, err := io.WriteString(c.Writer, "[]\n")
+				return err
+			}
+			_, err := io.WriteString(c.Writer, "]\n")
+			return err
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected csv, ndjson or json"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%s.%s\"", time.Now().UTC().Format("20060102T150405Z"), format))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+
+	count := 0
+	err := h.auditService.EachAuditLog(user.OrganizationID, filter, func(log *models.AuditLog) error {
+		if err := write(services.NewAuditExportEntry(log)); err != nil {
+			return err
+		}
+		count++
+		if count%auditExportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			c.Writer.Header().Del("Content-Type")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audit log"})
+			return
+		}
+		// Too late for an error response; the truncated export is incomplete
+		log.Printf("Audit export of organization %d failed after %d entries: %v", user.OrganizationID, count, err)
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "audit_export", "audit_log", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"format":  format,
+		"filter":  c.Request.URL.RawQuery,
+		"entries": count,
+	})
+}
+
+// SignedExport downloads the organization's audit entries from from until
+// to as a JWS signed with the server's certificate
+func (h *AuditHandler) SignedExport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	from, to, ok := parseAuditPeriod(c)
+	if !ok {
+		return
+	}
+	if to.Sub(from) > services.MaxAuditExportPeriod {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Signed exports cover at most 366 days"})
+		return
+	}
+
+	token, export, err := h.auditExportService.Export(user.OrganizationID, from, to, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audit log"})
+		return
+	}
+
+	// Audited after the export, so the entry describes it without being in it
+	h.auditService.LogAction(user.ID, nil, "audit_export_signed", "audit_log", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"from":    export.From,
+		"to":      export.To,
+		"entries": export.Count,
+		"sha256":  fmt.Sprintf("%x", sha256.Sum256([]byte(token))),
+	})
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%s-%s.jws\"", export.From.Format("20060102T150405Z"), export.To.Format("20060102T150405Z")))
+	c.Data(http.StatusOK, "application/jose", []byte(token))
+}
+
+// VerifySignedExport checks the signature of a signed audit export sent as
+// the request body
+func (h *AuditHandler) VerifySignedExport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxAuditExportSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Export is too large"})
+		return
+	}
+
+	verification, err := h.auditExportService.Verify(string(body))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAuditExport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Not a signed audit export"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit export"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "audit_export_verify", "audit_log", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"valid":  verification.Valid,
+		"sha256": verification.SHA256,
+	})
+
+	c.JSON(http.StatusOK, verification)
+}
+
+// GetArchives lists the organization's audit archives holding entries from
+// from until to, oldest first
+func (h *AuditHandler) GetArchives(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	page, limit := parsePagination(c)
+
+	archives, total, err := h.auditArchiveService.GetForOrganization(user.OrganizationID, filter.From, filter.To, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit archives"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  archives,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// DownloadArchive downloads the gzipped NDJSON file of an audit archive
+func (h *AuditHandler) DownloadArchive(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid archive ID"})
+		return
+	}
+
+	record, err := h.auditArchiveService.Get(user.OrganizationID, id)
+	if err != nil {
+		if errors.Is(err, services.ErrAuditArchiveNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Archive not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit archive"})
+		return
+	}
+
+	data, err := h.auditArchiveService.Read(record)
+	if err != nil {
+		if errors.Is(err, services.ErrAuditArchiveCorrupt) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Archive file is missing or doesn't match its hash"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audit archive"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "audit_archive_download", "audit_archive", strconv.Itoa(int(record.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"first_entry_id": record.FirstEntryID,
+		"last_entry_id":  record.LastEntryID,
+	})
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%d-%d.ndjson.gz\"", record.FirstEntryID, record.LastEntryID))
+	c.Data(http.StatusOK, "application/gzip", data)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,24 +16,57 @@ import (
 
 // AuthHandler handles authentication related requests
 type AuthHandler struct {
-	tokenService    *auth.TokenService
-	passwordService *crypto.PasswordService
-	userService     *services.UserService
-	auditService    *services.AuditService
+	tokenService          *auth.TokenService
+	passwordService       *crypto.PasswordService
+	passwordPolicyService *services.PasswordPolicyService
+	passwordChangeService *services.PasswordChangeService
+	userService           *services.UserService
+	roleService           *services.RoleService
+	sessionService        *services.SessionService
+	tokenDenylist         *services.TokenDenylistService
+	registrationService   *services.RegistrationService
+	ldapAuthService       *services.LDAPAuthService // nil when LDAP is disabled
+	webauthnService       *services.WebAuthnService // nil when WebAuthn is disabled
+	recoveryCodeService   *services.RecoveryCodeService
+	loginAlertService     *services.LoginAlertService // nil when login alerts are disabled
+	stepUpPolicy          *services.StepUpPolicy
+	auditService          *services.AuditService
 }
 
 // NewAuthHandler creates a new auth handler
 func NewAuthHandler(
 	tokenService *auth.TokenService,
 	passwordService *crypto.PasswordService,
+	passwordPolicyService *services.PasswordPolicyService,
+	passwordChangeService *services.PasswordChangeService,
 	userService *services.UserService,
+	roleService *services.RoleService,
+	sessionService *services.SessionService,
+	tokenDenylist *services.TokenDenylistService,
+	registrationService *services.RegistrationService,
+	ldapAuthService *services.LDAPAuthService,
+	webauthnService *services.WebAuthnService,
+	recoveryCodeService *services.RecoveryCodeService,
+	loginAlertService *services.LoginAlertService,
+	stepUpPolicy *services.StepUpPolicy,
 	auditService *services.AuditService,
 ) *AuthHandler {
 	return &AuthHandler{
-		tokenService:    tokenService,
-		passwordService: passwordService,
-		userService:     userService,
-		auditService:    auditService,
+		tokenService:          tokenService,
+		passwordService:       passwordService,
+		passwordPolicyService: passwordPolicyService,
+		passwordChangeService: passwordChangeService,
+		userService:           userService,
+		roleService:           roleService,
+		sessionService:        sessionService,
+		tokenDenylist:         tokenDenylist,
+		registrationService:   registrationService,
+		ldapAuthService:       ldapAuthService,
+		webauthnService:       webauthnService,
+		recoveryCodeService:   recoveryCodeService,
+		loginAlertService:     loginAlertService,
+		stepUpPolicy:          stepUpPolicy,
+		auditService:          auditService,
 	}
 }
 
@@ -51,18 +86,42 @@ type LoginResponse struct {
 
 // UserResponse represents user data in responses
 type UserResponse struct {
-	ID         uint      `json:"id"`
-	Username   string    `json:"username"`
-	Email      string    `json:"email"`
-	FirstName  string    `json:"first_name"`
-	LastName   string    `json:"last_name"`
-	Role       string    `json:"role"`
-	Department string    `json:"department"`
-	IsActive   bool      `json:"is_active"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID                 uint      `json:"id"`
+	OrganizationID     uint      `json:"organization_id"`
+	Username           string    `json:"username"`
+	Email              string    `json:"email"`
+	FirstName          string    `json:"first_name"`
+	LastName           string    `json:"last_name"`
+	Role               string    `json:"role"`
+	Department         string    `json:"department"`
+	IsActive           bool      `json:"is_active"`
+	MustChangePassword bool      `json:"must_change_password"`
+	LoginAlerts        bool      `json:"login_alerts"`
+	Capabilities       []string  `json:"capabilities,omitempty"` // Only in the profile
+	CreatedAt          time.Time `json:"created_at"`
 }
 
-// Login handles user login
+// newUserResponse returns the public fields of a user
+func newUserResponse(user *models.User) *UserResponse {
+	return &UserResponse{
+		ID:                 user.ID,
+		OrganizationID:     user.OrganizationID,
+		Username:           user.Username,
+		Email:              user.Email,
+		FirstName:          user.FirstName,
+		LastName:           user.LastName,
+		Role:               string(user.Role),
+		Department:         user.Department,
+		IsActive:           user.IsActive,
+		MustChangePassword: user.MustChangePassword,
+		LoginAlerts:        user.LoginAlerts,
+		CreatedAt:          user.CreatedAt,
+	}
+}
+
+// Login handles user login. With LDAP enabled, directory users and
+// usernames without a local account authenticate against the directory;
+// local accounts keep their local passwords.
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -76,6 +135,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Find user by username
 	user, err := h.userService.GetByUsername(req.Username)
+	if h.ldapAuthService != nil && (err != nil || user.AuthSource == models.AuthSourceLDAP) {
+		if err == nil && !h.checkAccount(c, user, req.Username) {
+			return
+		}
+		h.loginWithDirectory(c, req, user)
+		return
+	}
 	if err != nil {
 		// Log failed login attempt
 		h.auditService.LogAction(0, nil, "login_failed", "auth", "0", clientIP, userAgent, map[string]interface{}{
@@ -86,23 +152,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Check if user is active
-	if !user.IsActive {
-		h.auditService.LogAction(user.ID, nil, "login_failed", "auth", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
-			"username": req.Username,
-			"reason":   "account_inactive",
-		})
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is inactive"})
-		return
-	}
-
-	// Check if account is locked
-	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
-		h.auditService.LogAction(user.ID, nil, "login_failed", "auth", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
-			"username": req.Username,
-			"reason":   "account_locked",
-		})
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is temporarily locked"})
+	if !h.checkAccount(c, user, req.Username) {
 		return
 	}
 
@@ -122,21 +172,132 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Generate tokens
-	token, err := h.tokenService.GenerateToken(user)
+	h.passwordVerified(c, user, req.Username, "password")
+}
+
+// loginWithDirectory authenticates against the directory. known is the
+// local account of the username, nil when there is none yet.
+func (h *AuthHandler) loginWithDirectory(c *gin.Context, req LoginRequest, known *models.User) {
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	user, created, err := h.ldapAuthService.Authenticate(req.Username, req.Password)
 	if err != nil {
+		userID := uint(0)
+		if known != nil {
+			userID = known.ID
+		}
+
+		status, message, reason := http.StatusUnauthorized, "Invalid credentials", "invalid_password"
+		switch {
+		case errors.Is(err, services.ErrDirectoryCredentials):
+			if known == nil {
+				reason = "user_not_found"
+			} else if err := h.userService.IncrementLoginAttempts(known.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return
+			}
+		case errors.Is(err, services.ErrDirectoryNoRole):
+			status, message, reason = http.StatusForbidden, "Account is not permitted to sign in", "no_directory_role"
+		case errors.Is(err, services.ErrUserExists):
+			reason = "account_conflict"
+		default:
+			log.Printf("Directory login of %q failed: %v", req.Username, err)
+			status, message, reason = http.StatusServiceUnavailable, "Directory service unavailable", "directory_unavailable"
+		}
+
+		h.auditService.LogAction(userID, nil, "login_failed", "auth", strconv.Itoa(int(userID)), clientIP, userAgent, map[string]interface{}{
+			"username": req.Username,
+			"reason":   reason,
+		})
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	if created {
+		h.auditService.LogAction(user.ID, nil, "user_provisioned", "user", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
+			"username":   user.Username,
+			"role":       user.Role,
+			"department": user.Department,
+			"source":     models.AuthSourceLDAP,
+		})
+	}
+
+	// Usernames differing in case from the account's are only checked now
+	if !h.checkAccount(c, user, req.Username) {
+		return
+	}
+	h.passwordVerified(c, user, req.Username, "ldap")
+}
+
+// checkAccount refuses inactive and locked accounts, reporting whether the
+// login may proceed
+func (h *AuthHandler) checkAccount(c *gin.Context, user *models.User, username string) bool {
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	// Check if user is active
+	if !user.IsActive {
+		reason, message := "account_inactive", "Account is inactive"
+		if user.PendingApproval {
+			reason, message = "account_pending_approval", "Account is awaiting approval"
+		}
+		h.auditService.LogAction(user.ID, nil, "login_failed", "auth", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
+			"username": username,
+			"reason":   reason,
+		})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+		return false
+	}
+
+	// Check if account is locked
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		h.auditService.LogAction(user.ID, nil, "login_failed", "auth", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
+			"username": username,
+			"reason":   "account_locked",
+		})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is temporarily locked"})
+		return false
+	}
+	return true
+}
+
+// completeLogin issues the tokens of an authenticated user. method records
+// how the user authenticated.
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, username, method string) {
+	// Generate tokens for the audience of the client signing in
+	clientType := c.GetHeader("X-Client-Type")
+	if clientType == "" {
+		clientType = auth.DefaultClientType
+	}
+	now := time.Now()
+	token, err := h.tokenService.GenerateToken(user, clientType, now)
+	if err != nil {
+		if errors.Is(err, auth.ErrUnknownClientType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown client type"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	refreshToken, err := h.tokenService.GenerateRefreshToken(user, 7*24*time.Hour)
+	refreshToken, err := h.tokenService.GenerateRefreshToken(user, clientType, now, 7*24*time.Hour)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
 		return
 	}
 
-	// Save refresh token to database
-	if err := h.userService.SaveRefreshToken(user.ID, refreshToken, time.Now().Add(7*24*time.Hour)); err != nil {
+	// Save refresh token to database, within the session limit
+	revoked, err := h.sessionService.Start(user.ID, refreshToken, time.Now().Add(7*24*time.Hour), c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		if errors.Is(err, services.ErrSessionLimitReached) {
+			h.auditService.LogAction(user.ID, nil, "login_failed", "auth", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+				"username": username,
+				"reason":   "session_limit",
+			})
+			c.JSON(http.StatusConflict, gin.H{"error": "Maximum number of active sessions reached; sign out of another session first"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save refresh token"})
 		return
 	}
@@ -147,26 +308,32 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	details := map[string]interface{}{
+		"username": username,
+		"method":   method,
+	}
+	if revoked > 0 {
+		details["sessions_revoked"] = revoked
+	}
+
+	// Alert the user about logins from new devices; failing to doesn't fail the login
+	if h.loginAlertService != nil {
+		newDevice, err := h.loginAlertService.CheckLogin(user, c.ClientIP(), c.GetHeader("User-Agent"))
+		if err != nil {
+			log.Printf("Failed to check the login device of user %d: %v", user.ID, err)
+		} else if newDevice {
+			details["new_device"] = true
+		}
+	}
+
 	// Log successful login
-	h.auditService.LogAction(user.ID, nil, "login_success", "auth", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
-		"username": req.Username,
-	})
+	h.auditService.LogAction(user.ID, nil, "login_success", "auth", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
 
 	// Get token expiry time
 	expiryTime, _ := h.tokenService.GetTokenExpiryTime(token)
 
 	response := &LoginResponse{
-		User: &UserResponse{
-			ID:         user.ID,
-			Username:   user.Username,
-			Email:      user.Email,
-			FirstName:  user.FirstName,
-			LastName:   user.LastName,
-			Role:       string(user.Role),
-			Department: user.Department,
-			IsActive:   user.IsActive,
-			CreatedAt:  user.CreatedAt,
-		},
+		User:         newUserResponse(user),
 		Token:        token,
 		RefreshToken: refreshToken,
 		ExpiresAt:    expiryTime,
@@ -175,6 +342,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetPasswordPolicy returns the rules new passwords must follow
+func (h *AuthHandler) GetPasswordPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, h.passwordPolicyService.Policy())
+}
+
 // RefreshTokenRequest represents refresh token request
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
@@ -189,7 +361,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// Validate refresh token
-	claims, err := h.tokenService.ValidateToken(req.RefreshToken)
+	claims, err := h.tokenService.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
@@ -208,8 +380,12 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Generate new access token
-	newToken, err := h.tokenService.GenerateToken(user)
+	if err := h.sessionService.Touch(req.RefreshToken); err != nil {
+		log.Printf("Failed to record use of a session of user %d: %v", user.ID, err)
+	}
+
+	// Generate new access token; it is no fresher an authentication than the login
+	newToken, err := h.tokenService.GenerateToken(user, claims.ClientType, claims.AuthenticatedAt())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -244,6 +420,15 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		h.userService.RevokeRefreshToken(req.RefreshToken)
 	}
 
+	// Revoke the access token at once rather than at its expiry
+	if claims, ok := c.Get("token_claims"); ok {
+		claims := claims.(*auth.Claims)
+		if err := h.tokenDenylist.Revoke(claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+			return
+		}
+	}
+
 	// Log logout
 	h.auditService.LogAction(user.ID, nil, "logout", "auth", strconv.Itoa(int(user.ID)), clientIP, userAgent, nil)
 
@@ -260,17 +445,170 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 
 	user := userInterface.(*models.User)
 
-	response := &UserResponse{
-		ID:         user.ID,
-		Username:   user.Username,
-		Email:      user.Email,
-		FirstName:  user.FirstName,
-		LastName:   user.LastName,
-		Role:       string(user.Role),
-		Department: user.Department,
-		IsActive:   user.IsActive,
-		CreatedAt:  user.CreatedAt,
+	response := newUserResponse(user)
+	capabilities, err := h.roleService.Capabilities(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get capabilities"})
+		return
 	}
+	response.Capabilities = capabilities
 
 	c.JSON(http.StatusOK, response)
 }
+
+// GetLoginHistory lists the current user's successful and failed logins,
+// newest first, so they can spot access that wasn't theirs
+func (h *AuthHandler) GetLoginHistory(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	page, limit := parsePagination(c)
+
+	events, total, err := h.auditService.GetLoginHistory(user.ID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get login history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  events,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// LoginAlertsRequest turns emails about logins from new devices on or off
+type LoginAlertsRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// SetLoginAlerts turns the current user's login alerts on or off
+func (h *AuthHandler) SetLoginAlerts(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req LoginAlertsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := h.loginAlertService.SetAlerts(user.ID, *req.Enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update login alerts"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "login_alerts_updated", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"enabled": *req.Enabled,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"login_alerts": *req.Enabled})
+}
+
+// ChangePasswordRequest replaces the current user's password. RefreshToken
+// is the session to keep signed in; every other one is signed out.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"` // Checked against the password policy
+	RefreshToken    string `json:"refresh_token"`
+}
+
+// ChangePassword changes the current user's password and signs out their
+// other sessions
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	if err := h.passwordChangeService.ChangePassword(user, req.CurrentPassword, req.NewPassword, req.RefreshToken); err != nil {
+		if writePasswordPolicyError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidCurrentPassword):
+			// Guessing the password with a stolen token locks the account like logins do
+			if err := h.userService.IncrementLoginAttempts(user.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return
+			}
+			h.auditService.LogAction(user.ID, nil, "password_change_failed", "user", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
+				"reason": "invalid_current_password",
+			})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		case errors.Is(err, services.ErrPasswordResetNotAllowed):
+			c.JSON(http.StatusConflict, gin.H{"error": "The password of this account is managed by the directory"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "password_changed", "user", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
+		"username": user.Username,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed; other sessions have been signed out"})
+}
+
+// ForcePasswordResetRequest optionally records why a reset was forced
+type ForcePasswordResetRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// ForcePasswordReset makes a user choose a new password before doing
+// anything else and signs them out everywhere, such as after a suspected
+// compromise
+func (h *AuthHandler) ForcePasswordReset(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	target, ok := organizationUser(c, h.userService, admin)
+	if !ok {
+		return
+	}
+
+	var req ForcePasswordResetRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+
+	user, err := h.userService.RequirePasswordChange(target.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrPasswordResetNotAllowed) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The password of this account is managed by the directory"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "password_reset_forced", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"username": user.Username,
+		"reason":   req.Reason,
+	})
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
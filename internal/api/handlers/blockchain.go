@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/blockchain"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// BlockchainHandler exposes the blockchain recording document operations
+type BlockchainHandler struct {
+	blockchainService *services.BlockchainService
+	timestampClient   *blockchain.TimestampClient
+	documentService   *services.DocumentService
+	authService       *services.AuthorizationService
+	auditService      *services.AuditService
+}
+
+// NewBlockchainHandler creates a new blockchain handler. timestampClient may
+// be nil when external anchoring is disabled.
+func NewBlockchainHandler(blockchainService *services.BlockchainService, timestampClient *blockchain.TimestampClient, documentService *services.DocumentService, authService *services.AuthorizationService, auditService *services.AuditService) *BlockchainHandler {
+	return &BlockchainHandler{
+		blockchainService: blockchainService,
+		timestampClient:   timestampClient,
+		documentService:   documentService,
+		authService:       authService,
+		auditService:      auditService,
+	}
+}
+
+// GetBlocks lists the chain's blocks, newest first
+func (h *BlockchainHandler) GetBlocks(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	blocks, total, err := h.blockchainService.GetBlocks(c.Request.Context(), user.OrganizationID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get blocks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  blocks,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetTransaction returns a transaction and the block it was mined in
+func (h *BlockchainHandler) GetTransaction(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tx, err := h.blockchainService.GetTransaction(c.Request.Context(), user.OrganizationID, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrBlockchainTransactionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tx)
+}
+
+// GetDocumentHistory lists the transactions recorded for a document the
+// user may read
+func (h *BlockchainHandler) GetDocumentHistory(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), id)
+	if err != nil || doc.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	allowed, err := h.authService.CanRead(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": h.blockchainService.GetDocumentHistory(doc.ID)})
+}
+
+// VerifyIntegrity checks the hashes and links of the whole chain
+func (h *BlockchainHandler) VerifyIntegrity(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	verification, err := h.blockchainService.Verify()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify blockchain"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "blockchain_verify", "blockchain", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"valid":              verification.Valid,
+		"blocks":             verification.Blocks,
+		"anchors_mismatched": verification.Anchors.Mismatched,
+	})
+
+	c.JSON(http.StatusOK, verification)
+}
+
+// Reconcile cross-checks the blockchain records against the chain, marking
+// which are verified, and reports those of the organization's documents that
+// are orphaned, mismatched or missing
+func (h *BlockchainHandler) Reconcile(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	report, err := h.blockchainService.Reconcile(c.Request.Context(), user.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile blockchain records"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "blockchain_reconcile", "blockchain", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"verified":   report.Verified,
+		"orphaned":   len(report.Orphaned),
+		"mismatched": len(report.Mismatched),
+		"missing":    len(report.Missing),
+	})
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetAnchors lists the block hashes anchored externally, newest first
+func (h *BlockchainHandler) GetAnchors(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	anchors, total, err := h.blockchainService.GetAnchors(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get anchors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  anchors,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// CreateAnchor anchors the latest block externally right away
+func (h *BlockchainHandler) CreateAnchor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	anchor, err := h.blockchainService.Anchor(c.Request.Context(), h.timestampClient)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to anchor the latest block"})
+		return
+	}
+	if anchor == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Latest block is already anchored"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "blockchain_anchor", "blockchain_anchor", strconv.Itoa(int(anchor.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"block_number": anchor.BlockNumber,
+		"calendar":     anchor.Calendar,
+	})
+
+	c.JSON(http.StatusCreated, anchor)
+}
+
+// DownloadAnchorProof serves an anchor's OpenTimestamps proof, for checking
+// with the ots client independently of this server
+func (h *BlockchainHandler) DownloadAnchorProof(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid anchor ID"})
+		return
+	}
+
+	anchor, proof, err := h.blockchainService.GetAnchorProof(id)
+	if err != nil {
+		if errors.Is(err, services.ErrBlockchainAnchorNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Anchor not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get anchor"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"block-%d.ots\"", anchor.BlockNumber))
+	c.Data(http.StatusOK, "application/vnd.opentimestamps.v1", proof)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// CreateBulkUpload accepts a zip archive whose files become individual
+// documents. The archive is extracted in the background; the returned bulk
+// upload reports progress and the outcome of each file.
+func (h *DocumentHandler) CreateBulkUpload(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		if writeBodyTooLarge(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	upload := &models.BulkUpload{
+		FileName:          fileHeader.Filename,
+		Category:          c.PostForm("category"),
+		Tags:              c.PostForm("tags"),
+		AccessLevelReason: c.PostForm("access_level_reason"),
+	}
+
+	// An unset access level is classified per file
+	if value := c.PostForm("access_level"); value != "" {
+		level, err := strconv.Atoi(value)
+		if err != nil || !validAccessLevel(models.AccessLevel(level)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access level"})
+			return
+		}
+		upload.AccessLevel = models.AccessLevel(level)
+	}
+
+	if value := c.PostForm("folder_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+			return
+		}
+		if !h.authorizeFolder(c, user, uint(id)) {
+			return
+		}
+		folderID := uint(id)
+		upload.FolderID = &folderID
+	}
+
+	content, err := readFormFile(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	if !verifyChecksum(c, content) {
+		return
+	}
+
+	// Each file is held to the same limit as a single upload
+	maxFileSize, _ := maxUploadSize(c)
+
+	if err := h.bulkUploadService.Start(upload, user, content, maxFileSize, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidArchive):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrArchiveTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start bulk upload"})
+		}
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.Itoa(int(upload.ID)))
+	c.JSON(http.StatusAccepted, upload)
+}
+
+// GetBulkUploads returns the current user's bulk uploads
+func (h *DocumentHandler) GetBulkUploads(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	uploads, total, err := h.bulkUploadService.GetAllForUser(user.ID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get bulk uploads"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  uploads,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetBulkUpload returns a bulk upload with the outcome of each file so far,
+// as JSON or, with format=csv, as a report
+func (h *DocumentHandler) GetBulkUpload(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bulk upload ID"})
+		return
+	}
+
+	upload, err := h.bulkUploadService.GetForUser(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bulk upload not found"})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, upload)
+		return
+	}
+
+	rows := make([][]string, 0, len(upload.Items))
+	for _, item := range upload.Items {
+		documentID := ""
+		if item.DocumentID != nil {
+			documentID = strconv.Itoa(int(*item.DocumentID))
+		}
+		rows = append(rows, []string{
+			item.Path,
+			strconv.FormatBool(item.Succeeded),
+			documentID,
+			item.Error,
+		})
+	}
+	writeCSV(c, "bulk-upload-"+strconv.Itoa(int(upload.ID)), []string{"path", "succeeded", "document_id", "error"}, rows)
+}
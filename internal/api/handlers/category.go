@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// CategoryHandler handles category management requests
+type CategoryHandler struct {
+	categoryService *services.CategoryService
+	auditService    *services.AuditService
+}
+
+// NewCategoryHandler creates a new category handler
+func NewCategoryHandler(categoryService *services.CategoryService, auditService *services.AuditService) *CategoryHandler {
+	return &CategoryHandler{
+		categoryService: categoryService,
+		auditService:    auditService,
+	}
+}
+
+// CategoryRequest represents a category create or update request
+type CategoryRequest struct {
+	Name        string `json:"name" binding:"required"`
+	ParentID    *uint  `json:"parent_id"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	Icon        string `json:"icon"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// GetCategories returns the categories of the user's organization with their
+// document counts
+func (h *CategoryHandler) GetCategories(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	categories, err := h.categoryService.GetAll(c.Request.Context(), user.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": categories})
+}
+
+// GetCategory returns a category
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	category, ok := h.loadCategory(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// CreateCategory creates a category
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	category := &models.Category{OrganizationID: user.OrganizationID, IsActive: true}
+	if !h.applyRequest(c, category, &req) {
+		return
+	}
+
+	if err := h.categoryService.Create(category); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "category_create", "category", strconv.Itoa(int(category.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":      category.Name,
+		"parent_id": category.ParentID,
+	})
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory updates a category, renaming it on its documents too
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	category, ok := h.loadCategory(c)
+	if !ok {
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	previousName := category.Name
+	if !h.applyRequest(c, category, &req) {
+		return
+	}
+
+	if err := h.categoryService.Update(category, previousName); err != nil {
+		if errors.Is(err, services.ErrCategoryCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "category_update", "category", strconv.Itoa(int(category.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":          category.Name,
+		"previous_name": previousName,
+		"parent_id":     category.ParentID,
+	})
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory deletes an unused category
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	category, ok := h.loadCategory(c)
+	if !ok {
+		return
+	}
+
+	if err := h.categoryService.Delete(category); err != nil {
+		if errors.Is(err, services.ErrCategoryInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "category_delete", "category", strconv.Itoa(int(category.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name": category.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// applyRequest validates a category request and copies it onto the category,
+// writing an error response when the request is invalid
+func (h *CategoryHandler) applyRequest(c *gin.Context, category *models.Category, req *CategoryRequest) bool {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+		return false
+	}
+
+	if req.Color != "" && !validHexColor(req.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Color must be a hex color code such as #2563EB"})
+		return false
+	}
+
+	if req.ParentID != nil {
+		parent, err := h.categoryService.GetByID(*req.ParentID)
+		if err != nil || parent.OrganizationID != category.OrganizationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+			return false
+		}
+	}
+
+	category.Name = name
+	category.ParentID = req.ParentID
+	category.Description = req.Description
+	category.Color = req.Color
+	category.Icon = req.Icon
+	if req.IsActive != nil {
+		category.IsActive = *req.IsActive
+	}
+
+	return true
+}
+
+// loadCategory resolves the category from the :id parameter, writing an error
+// response when it is unavailable or belongs to another organization
+func (h *CategoryHandler) loadCategory(c *gin.Context) (*models.Category, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return nil, false
+	}
+
+	category, err := h.categoryService.GetByID(id)
+	if err != nil || category.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return nil, false
+	}
+
+	return category, true
+}
+
+// validHexColor checks for a #RRGGBB color code
+func validHexColor(color string) bool {
+	if len(color) != 7 || color[0] != '#' {
+		return false
+	}
+	_, err := strconv.ParseUint(color[1:], 16, 32)
+	return err == nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// cdnOriginSecretHeader carries the secret the CDN adds to origin requests
+const cdnOriginSecretHeader = "X-CDN-Origin-Secret"
+
+// CDNHandler issues signed CDN download URLs and serves as the CDN origin
+type CDNHandler struct {
+	cdnService          *services.CDNService
+	documentService     *services.DocumentService
+	watermarkService    *services.WatermarkService
+	authService         *services.AuthorizationService
+	stepUpPolicy        *services.StepUpPolicy
+	justificationPolicy *services.JustificationPolicy
+	auditService        *services.AuditService
+	originSecret        string
+}
+
+// NewCDNHandler creates a new CDN handler. Origin requests must carry
+// originSecret in the X-CDN-Origin-Secret header.
+func NewCDNHandler(cdnService *services.CDNService, documentService *services.DocumentService, watermarkService *services.WatermarkService, authService *services.AuthorizationService, stepUpPolicy *services.StepUpPolicy, justificationPolicy *services.JustificationPolicy, auditService *services.AuditService, originSecret string) *CDNHandler {
+	return &CDNHandler{
+		cdnService:          cdnService,
+		documentService:     documentService,
+		watermarkService:    watermarkService,
+		authService:         authService,
+		stepUpPolicy:        stepUpPolicy,
+		justificationPolicy: justificationPolicy,
+		auditService:        auditService,
+		originSecret:        originSecret,
+	}
+}
+
+// GetDownloadURL returns a short-lived signed CDN URL for downloading a
+// public or internal document the current user may download
+func (h *CDNHandler) GetDownloadURL(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	allowed, err := h.authService.CanDownload(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if !checkStepUp(c, h.stepUpPolicy, doc) {
+		return
+	}
+	reason, ok := checkJustification(c, h.justificationPolicy, doc)
+	if !ok {
+		return
+	}
+
+	// Watermarks are stamped per download, which the CDN can't do
+	if !h.cdnService.Allowed(doc) || h.watermarkService.Applies(doc) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Document is not available through the CDN; use the download endpoint"})
+		return
+	}
+
+	signedURL, expires, err := h.cdnService.SignedURL(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create download URL"})
+		return
+	}
+
+	// Downloads through the CDN don't reach the server, so they are audited
+	// when the URL is issued
+	details := map[string]interface{}{
+		"cdn":        true,
+		"expires_at": expires,
+	}
+	if reason != "" {
+		details["justification"] = reason
+	}
+	h.auditService.LogAction(user.ID, &doc.ID, "document_download", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        signedURL,
+		"expires_at": expires,
+	})
+}
+
+// ServeBlob serves decrypted content by hash to the CDN. Responses are
+// cacheable forever since the content at a hash never changes.
+func (h *CDNHandler) ServeBlob(c *gin.Context) {
+	secret := c.GetHeader(cdnOriginSecretHeader)
+	if h.originSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.originSecret)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	doc, content, err := h.cdnService.ReadOriginContent(c.Param("hash"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	mimeType := doc.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	setContentDisposition(c, "attachment", c.Param("filename"))
+	c.Data(http.StatusOK, mimeType, content)
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// RevokeCertificateRequest represents a request to revoke a signing
+// certificate
+type RevokeCertificateRequest struct {
+	Reason models.RevocationReason `json:"reason"` // Defaults to unspecified
+}
+
+// CertificateResponse represents a signing certificate issued by the
+// internal CA
+type CertificateResponse struct {
+	models.UserCertificate
+	RenewalDue bool `json:"renewal_due"` // Valid, but expires within the renewal window
+}
+
+// CertificateHandler issues, renews and revokes users' signing certificates
+// and publishes the internal CA's certificate and revocations
+type CertificateHandler struct {
+	certificateService *services.CertificateService
+	stepUpPolicy       *services.StepUpPolicy
+	auditService       *services.AuditService
+}
+
+// NewCertificateHandler creates a new certificate handler
+func NewCertificateHandler(certificateService *services.CertificateService, stepUpPolicy *services.StepUpPolicy, auditService *services.AuditService) *CertificateHandler {
+	return &CertificateHandler{
+		certificateService: certificateService,
+		stepUpPolicy:       stepUpPolicy,
+		auditService:       auditService,
+	}
+}
+
+// toResponse adds whether a certificate is due for renewal
+func (h *CertificateHandler) toResponse(record *models.UserCertificate) CertificateResponse {
+	return CertificateResponse{
+		UserCertificate: *record,
+		RenewalDue:      h.certificateService.RenewalDue(record),
+	}
+}
+
+// toResponses adds whether certificates are due for renewal
+func (h *CertificateHandler) toResponses(records []models.UserCertificate) []CertificateResponse {
+	responses := make([]CertificateResponse, 0, len(records))
+	for i := range records {
+		responses = append(responses, h.toResponse(&records[i]))
+	}
+	return responses
+}
+
+// IssueCertificate issues the current user a signing certificate
+func (h *CertificateHandler) IssueCertificate(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !requireFreshAuth(c, h.stepUpPolicy) {
+		return
+	}
+
+	record, err := h.certificateService.Issue(user, user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrCertificateExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have a valid signing certificate; renew it instead"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue certificate"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "certificate_issue", "certificate", strconv.Itoa(int(record.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"serial_number": record.SerialNumber,
+		"not_after":     record.NotAfter,
+	})
+
+	c.JSON(http.StatusCreated, h.toResponse(record))
+}
+
+// GetCertificates lists the current user's signing certificates
+func (h *CertificateHandler) GetCertificates(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	records, err := h.certificateService.GetForUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get certificates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": h.toResponses(records)})
+}
+
+// RenewCertificate issues the current user a new certificate in place of
+// one of theirs, which is revoked as superseded
+func (h *CertificateHandler) RenewCertificate(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+	if !requireFreshAuth(c, h.stepUpPolicy) {
+		return
+	}
+
+	record, err := h.certificateService.Renew(user, id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCertificateNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		case errors.Is(err, services.ErrCertificateRevoked):
+			c.JSON(http.StatusConflict, gin.H{"error": "Certificate is revoked"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to renew certificate"})
+		}
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "certificate_renew", "certificate", strconv.Itoa(int(record.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"renewed_from":  id,
+		"serial_number": record.SerialNumber,
+		"not_after":     record.NotAfter,
+	})
+
+	c.JSON(http.StatusCreated, h.toResponse(record))
+}
+
+// RevokeCertificate revokes one of the current user's certificates
+func (h *CertificateHandler) RevokeCertificate(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	existing, err := h.certificateService.Get(user.OrganizationID, id)
+	if err != nil || existing.UserID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		return
+	}
+
+	h.revoke(c, user, existing)
+}
+
+// RevokeUserCertificate revokes a certificate of any user in the
+// administrator's organization
+func (h *CertificateHandler) RevokeUserCertificate(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	existing, err := h.certificateService.Get(user.OrganizationID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		return
+	}
+
+	h.revoke(c, user, existing)
+}
+
+// revoke revokes a certificate for the reason in the request
+func (h *CertificateHandler) revoke(c *gin.Context, user *models.User, existing *models.UserCertificate) {
+	var req RevokeCertificateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = models.RevocationUnspecified
+	}
+	if !services.ValidRevocationReason(req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revocation reason"})
+		return
+	}
+
+	record, err := h.certificateService.Revoke(user.OrganizationID, existing.ID, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCertificateNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		case errors.Is(err, services.ErrCertificateRevoked):
+			c.JSON(http.StatusConflict, gin.H{"error": "Certificate is already revoked"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke certificate"})
+		}
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "certificate_revoke", "certificate", strconv.Itoa(int(record.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"holder":        record.UserID,
+		"serial_number": record.SerialNumber,
+		"reason":        record.RevocationReason,
+	})
+
+	c.JSON(http.StatusOK, h.toResponse(record))
+}
+
+// GetOrganizationCertificates lists the signing certificates of the
+// administrator's organization
+func (h *CertificateHandler) GetOrganizationCertificates(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	records, total, err := h.certificateService.GetForOrganization(user.OrganizationID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get certificates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  h.toResponses(records),
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetCACertificate returns the PEM certificates of the internal CA
+func (h *CertificateHandler) GetCACertificate(c *gin.Context) {
+	c.Data(http.StatusOK, "application/x-pem-file", []byte(h.certificateService.CACertificates()))
+}
+
+// GetRevocationList returns the internal CA's DER CRL
+func (h *CertificateHandler) GetRevocationList(c *gin.Context) {
+	crl, err := h.certificateService.RevocationList()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create CRL"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/pkix-crl", crl)
+}
+
+// GetCertificateStatus returns whether the certificate with a hex serial
+// number is good, revoked or unknown to the internal CA
+func (h *CertificateHandler) GetCertificateStatus(c *gin.Context) {
+	status, err := h.certificateService.Status(c.Param("serial"))
+	if err != nil {
+		if errors.Is(err, services.ErrCertificateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serial number"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get certificate status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
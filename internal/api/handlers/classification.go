@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// classifyUpload suggests an access level for a new document. A document
+// without a requested level gets the suggestion, or internal when no rule
+// matches. A requested level below the suggestion is an override and needs a
+// reason; without one a 400 response is written and false returned.
+func (h *DocumentHandler) classifyUpload(c *gin.Context, user *models.User, doc *models.Document, content []byte, reason string) (*services.Classification, bool) {
+	classification, err := h.classificationService.Classify(&services.ClassificationInput{
+		Title:       doc.Title,
+		Description: doc.Description,
+		Category:    doc.Category,
+		Department:  user.Department,
+		FileName:    doc.FileName,
+		MimeType:    doc.MimeType,
+		Content:     content,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify document"})
+		return nil, false
+	}
+
+	switch {
+	case doc.AccessLevel == 0:
+		doc.AccessLevel = max(models.AccessInternal, classification.AccessLevel)
+	case doc.AccessLevel < classification.AccessLevel && strings.TrimSpace(reason) == "":
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":                  "Access level is below the suggested classification; access_level_reason is required",
+			"suggested_access_level": classification.AccessLevel,
+			"rules":                  classification.Rules,
+		})
+		return nil, false
+	}
+
+	return classification, true
+}
+
+// recordClassificationOverride audits a document stored below its suggested
+// access level
+func (h *DocumentHandler) recordClassificationOverride(c *gin.Context, user *models.User, doc *models.Document, classification *services.Classification, reason string) {
+	if doc.AccessLevel >= classification.AccessLevel {
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "classification_override", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"access_level":           doc.AccessLevel,
+		"suggested_access_level": classification.AccessLevel,
+		"rules":                  classification.Rules,
+		"reason":                 strings.TrimSpace(reason),
+	})
+}
+
+// ClassifyDocument suggests an access level for a document before it is
+// uploaded, from its title, description, category, the user's department and
+// the optional file
+func (h *DocumentHandler) ClassifyDocument(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	input := &services.ClassificationInput{
+		Title:       c.PostForm("title"),
+		Description: c.PostForm("description"),
+		Category:    c.PostForm("category"),
+		Department:  user.Department,
+	}
+
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		content, err := readFormFile(fileHeader)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		input.FileName = fileHeader.Filename
+		input.MimeType = fileHeader.Header.Get("Content-Type")
+		input.Content = content
+	} else if writeBodyTooLarge(c, err) {
+		return
+	}
+
+	classification, err := h.classificationService.Classify(input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify document"})
+		return
+	}
+	if classification.AccessLevel == 0 {
+		classification.AccessLevel = models.AccessInternal
+	}
+
+	c.JSON(http.StatusOK, classification)
+}
+
+// ClassificationHandler handles management of access-level classification rules
+type ClassificationHandler struct {
+	classificationService *services.ClassificationService
+	auditService          *services.AuditService
+}
+
+// NewClassificationHandler creates a new classification handler
+func NewClassificationHandler(classificationService *services.ClassificationService, auditService *services.AuditService) *ClassificationHandler {
+	return &ClassificationHandler{
+		classificationService: classificationService,
+		auditService:          auditService,
+	}
+}
+
+// ClassificationRuleRequest represents a classification rule create or update request
+type ClassificationRuleRequest struct {
+	Name        string             `json:"name" binding:"required"`
+	Category    string             `json:"category"`
+	Department  string             `json:"department"`
+	Keywords    string             `json:"keywords"`
+	AccessLevel models.AccessLevel `json:"access_level" binding:"required"`
+	IsActive    *bool              `json:"is_active"`
+}
+
+// GetRules returns all classification rules
+func (h *ClassificationHandler) GetRules(c *gin.Context) {
+	rules, err := h.classificationService.GetRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get classification rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// CreateRule creates a classification rule
+func (h *ClassificationHandler) CreateRule(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ClassificationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	rule := &models.ClassificationRule{IsActive: true, CreatedBy: user.ID}
+	if !applyRuleRequest(c, rule, &req) {
+		return
+	}
+
+	if err := h.classificationService.CreateRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create classification rule"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "classification_rule_create", "classification_rule", strconv.Itoa(int(rule.ID)), c.ClientIP(), c.GetHeader("User-Agent"), ruleAuditDetails(rule))
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule updates a classification rule
+func (h *ClassificationHandler) UpdateRule(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rule, ok := h.loadRule(c)
+	if !ok {
+		return
+	}
+
+	var req ClassificationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if !applyRuleRequest(c, rule, &req) {
+		return
+	}
+
+	if err := h.classificationService.UpdateRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update classification rule"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "classification_rule_update", "classification_rule", strconv.Itoa(int(rule.ID)), c.ClientIP(), c.GetHeader("User-Agent"), ruleAuditDetails(rule))
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule deletes a classification rule
+func (h *ClassificationHandler) DeleteRule(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rule, ok := h.loadRule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.classificationService.DeleteRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete classification rule"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "classification_rule_delete", "classification_rule", strconv.Itoa(int(rule.ID)), c.ClientIP(), c.GetHeader("User-Agent"), ruleAuditDetails(rule))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Classification rule deleted successfully"})
+}
+
+// applyRuleRequest validates a classification rule request and copies it onto
+// the rule, writing an error response when the request is invalid
+func applyRuleRequest(c *gin.Context, rule *models.ClassificationRule, req *ClassificationRuleRequest) bool {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+		return false
+	}
+
+	if !validAccessLevel(req.AccessLevel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access level"})
+		return false
+	}
+
+	keywords := services.ParseKeywords(req.Keywords)
+	category := strings.TrimSpace(req.Category)
+	department := strings.TrimSpace(req.Department)
+	if category == "" && department == "" && len(keywords) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A rule needs a category, department or keywords"})
+		return false
+	}
+
+	rule.Name = name
+	rule.Category = category
+	rule.Department = department
+	rule.Keywords = strings.Join(keywords, ",")
+	rule.AccessLevel = req.AccessLevel
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+
+	return true
+}
+
+// loadRule resolves the classification rule from the :id parameter, writing
+// an error response when it is unavailable
+func (h *ClassificationHandler) loadRule(c *gin.Context) (*models.ClassificationRule, bool) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid classification rule ID"})
+		return nil, false
+	}
+
+	rule, err := h.classificationService.GetRule(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Classification rule not found"})
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// ruleAuditDetails describes a classification rule for the audit log
+func ruleAuditDetails(rule *models.ClassificationRule) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         rule.Name,
+		"category":     rule.Category,
+		"department":   rule.Department,
+		"keywords":     rule.Keywords,
+		"access_level": rule.AccessLevel,
+		"is_active":    rule.IsActive,
+	}
+}
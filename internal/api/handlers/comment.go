@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// maxCommentLength bounds the size of a comment
+const maxCommentLength = 10000
+
+// CommentRequest represents a comment create or update request
+type CommentRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// CommentResponse represents a comment in responses
+type CommentResponse struct {
+	ID         uint      `json:"id"`
+	DocumentID uint      `json:"document_id"`
+	UserID     uint      `json:"user_id"`
+	Username   string    `json:"username"`
+	Content    string    `json:"content"`
+	Mentions   []string  `json:"mentions"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// newCommentResponse converts a comment model to its response representation
+func newCommentResponse(comment *models.Comment) *CommentResponse {
+	return &CommentResponse{
+		ID:         comment.ID,
+		DocumentID: comment.DocumentID,
+		UserID:     comment.UserID,
+		Username:   comment.User.Username,
+		Content:    comment.Content,
+		Mentions:   services.ParseMentions(comment.Content),
+		CreatedAt:  comment.CreatedAt,
+		UpdatedAt:  comment.UpdatedAt,
+	}
+}
+
+// GetComments returns the comments on a document
+func (h *DocumentHandler) GetComments(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return
+	}
+
+	comments, err := h.commentService.GetByDocument(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comments"})
+		return
+	}
+
+	responses := make([]*CommentResponse, 0, len(comments))
+	for i := range comments {
+		responses = append(responses, newCommentResponse(&comments[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// CreateComment adds a comment to a document, notifying mentioned users
+func (h *DocumentHandler) CreateComment(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return
+	}
+
+	content, ok := bindComment(c)
+	if !ok {
+		return
+	}
+
+	comment := &models.Comment{Content: content}
+	if err := h.commentService.Create(doc, comment, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "comment_create", "comment", strconv.Itoa(int(comment.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"mentions": services.ParseMentions(comment.Content),
+	})
+
+	c.JSON(http.StatusCreated, newCommentResponse(comment))
+}
+
+// UpdateComment edits a comment; only its author may do so
+func (h *DocumentHandler) UpdateComment(c *gin.Context) {
+	user, doc, comment, ok := h.loadComment(c)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit a comment"})
+		return
+	}
+
+	content, ok := bindComment(c)
+	if !ok {
+		return
+	}
+
+	previousContent := comment.Content
+	comment.Content = content
+	if err := h.commentService.Update(doc, comment, user, previousContent); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "comment_update", "comment", strconv.Itoa(int(comment.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"mentions": services.ParseMentions(comment.Content),
+	})
+
+	c.JSON(http.StatusOK, newCommentResponse(comment))
+}
+
+// DeleteComment removes a comment; its author and admins may do so
+func (h *DocumentHandler) DeleteComment(c *gin.Context) {
+	user, doc, comment, ok := h.loadComment(c)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != user.ID && user.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	if err := h.commentService.Delete(comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "comment_delete", "comment", strconv.Itoa(int(comment.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"author_id": comment.UserID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// loadComment resolves the document and comment of a request and checks that
+// the user may read the document
+func (h *DocumentHandler) loadComment(c *gin.Context) (*models.User, *models.Document, *models.Comment, bool) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return nil, nil, nil, false
+	}
+
+	commentID, ok := parseIDParam(c, "commentId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return nil, nil, nil, false
+	}
+
+	comment, err := h.commentService.GetByID(doc.ID, commentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, nil, nil, false
+	}
+
+	return user, doc, comment, true
+}
+
+// bindComment reads and validates the content of a comment request
+func bindComment(c *gin.Context) (string, bool) {
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return "", false
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment cannot be empty"})
+		return "", false
+	}
+	if len(content) > maxCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment is too long"})
+		return "", false
+	}
+
+	return content, true
+}
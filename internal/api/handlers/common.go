@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// PaginatedResponse represents a page of results
+type PaginatedResponse struct {
+	Data  interface{} `json:"data"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+}
+
+// currentUser returns the authenticated user set by the auth middleware
+func currentUser(c *gin.Context) (*models.User, bool) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		return nil, false
+	}
+
+	user, ok := userInterface.(*models.User)
+	return user, ok
+}
+
+// maxUploadSize returns the upload size limit of the current user set by
+// the upload size middleware
+func maxUploadSize(c *gin.Context) (int64, bool) {
+	limit, exists := c.Get("max_upload_size")
+	if !exists {
+		return 0, false
+	}
+
+	maxSize, ok := limit.(int64)
+	return maxSize, ok
+}
+
+// writeBodyTooLarge writes a 413 response and returns true when err is caused
+// by a request body exceeding its size limit
+func writeBodyTooLarge(c *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":    "Upload exceeds the maximum size for your role",
+		"max_size": tooLarge.Limit,
+	})
+	return true
+}
+
+// writePasswordPolicyError writes a 400 response listing the broken rules
+// and returns true when err is a password policy violation
+func writePasswordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "Password does not meet the password policy",
+		"violations": policyErr.Violations,
+	})
+	return true
+}
+
+// parseIDParam parses a numeric path parameter
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// organizationUser loads the user named by the id parameter, writing an
+// error response when the ID is invalid or there is no such user in the
+// organization of actor
+func organizationUser(c *gin.Context, userService *services.UserService, actor *models.User) (*models.User, bool) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	user, err := userService.GetByID(id)
+	if err != nil || user.OrganizationID != actor.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+// parsePagination reads page and limit query parameters with sane defaults
+func parsePagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if err != nil || limit < 1 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	return page, limit
+}
+
+// parseDateParam parses a date query parameter in RFC 3339 or YYYY-MM-DD format
+func parseDateParam(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// setContentDisposition sets the Content-Disposition header with the
+// disposition and file name, quoting or encoding the name as needed so that
+// no file name can break out of the header
+func setContentDisposition(c *gin.Context, disposition, name string) {
+	value := mime.FormatMediaType(disposition, map[string]string{"filename": name})
+	if value == "" {
+		value = disposition
+	}
+	c.Header("Content-Disposition", value)
+}
+
+// writeCSV writes a header and rows as a CSV attachment named name.csv
+func writeCSV(c *gin.Context, name string, header []string, rows [][]string) {
+	setContentDisposition(c, "attachment", name+".csv")
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(header)
+	for _, row := range rows {
+		writer.Write(row)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// ConversionHandler serves documents converted to PDF
+type ConversionHandler struct {
+	conversionService   *services.ConversionService
+	documentService     *services.DocumentService
+	watermarkService    *services.WatermarkService
+	authService         *services.AuthorizationService
+	stepUpPolicy        *services.StepUpPolicy
+	justificationPolicy *services.JustificationPolicy
+	auditService        *services.AuditService
+}
+
+// NewConversionHandler creates a new conversion handler
+func NewConversionHandler(conversionService *services.ConversionService, documentService *services.DocumentService, watermarkService *services.WatermarkService, authService *services.AuthorizationService, stepUpPolicy *services.StepUpPolicy, justificationPolicy *services.JustificationPolicy, auditService *services.AuditService) *ConversionHandler {
+	return &ConversionHandler{
+		conversionService:   conversionService,
+		documentService:     documentService,
+		watermarkService:    watermarkService,
+		authService:         authService,
+		stepUpPolicy:        stepUpPolicy,
+		justificationPolicy: justificationPolicy,
+		auditService:        auditService,
+	}
+}
+
+// GetPDF returns a document rendered as PDF for viewing in the browser
+func (h *ConversionHandler) GetPDF(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	allowed, err := h.authService.CanRead(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if !checkStepUp(c, h.stepUpPolicy, doc) {
+		return
+	}
+	reason, ok := checkJustification(c, h.justificationPolicy, doc)
+	if !ok {
+		return
+	}
+
+	if !checkScanStatus(c, doc) {
+		return
+	}
+
+	if !h.conversionService.Convertible(doc) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Document can't be converted to PDF"})
+		return
+	}
+
+	downloadable, err := h.authService.CanDownload(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	printable, err := h.authService.Can(user, doc, services.ActionPrint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+
+	// The PDF of a PDF document is its original file, which users who may
+	// not download only get watermarked
+	stamp := h.watermarkService.Applies(doc)
+	if !downloadable && h.conversionService.IsOriginal(doc) {
+		if !h.watermarkService.Available() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Document can't be previewed without download permission"})
+			return
+		}
+		stamp = true
+	}
+
+	pdf, err := h.conversionService.GetPDF(doc)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to convert document to PDF"})
+		return
+	}
+
+	details := map[string]interface{}{"format": "pdf"}
+	if stamp {
+		if pdf, err = h.watermarkService.Stamp(pdf, doc, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watermark document"})
+			return
+		}
+		details["watermarked"] = true
+	}
+	if reason != "" {
+		details["justification"] = reason
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_download", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+
+	// Viewers hide saving and printing the preview when they aren't allowed
+	c.Header("X-Download-Allowed", strconv.FormatBool(downloadable))
+	c.Header("X-Print-Allowed", strconv.FormatBool(printable))
+	if !downloadable || !printable {
+		c.Header("Cache-Control", "no-store")
+	}
+
+	fileName := strings.TrimSuffix(doc.FileName, filepath.Ext(doc.FileName)) + ".pdf"
+	setContentDisposition(c, "inline", fileName)
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
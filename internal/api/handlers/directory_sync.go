@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// DirectorySyncHandler handles syncs of users with the corporate directory
+type DirectorySyncHandler struct {
+	directorySyncService *services.DirectorySyncService
+	auditService         *services.AuditService
+}
+
+// NewDirectorySyncHandler creates a new directory sync handler
+func NewDirectorySyncHandler(directorySyncService *services.DirectorySyncService, auditService *services.AuditService) *DirectorySyncHandler {
+	return &DirectorySyncHandler{
+		directorySyncService: directorySyncService,
+		auditService:         auditService,
+	}
+}
+
+// RunSync syncs the users with the directory now and returns the report.
+// With ?dry_run=true the changes are only reported.
+func (h *DirectorySyncHandler) RunSync(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	dryRun := c.DefaultQuery("dry_run", "false") == "true"
+
+	report, err := h.directorySyncService.Run(c.Request.Context(), dryRun, &user.ID)
+	if errors.Is(err, services.ErrDirectorySyncRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A directory sync is already running"})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync with the directory"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "directory_sync", "directory_sync", strconv.Itoa(int(report.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"source":      report.Source,
+		"dry_run":     report.DryRun,
+		"linked":      report.Linked,
+		"updated":     report.Updated,
+		"deactivated": report.Deactivated,
+		"error":       report.Error,
+	})
+
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to sync with the directory", "report": report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetRuns lists the directory sync reports
+func (h *DirectorySyncHandler) GetRuns(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	runs, total, err := h.directorySyncService.GetRuns(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get directory syncs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  runs,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetRun returns a directory sync report with its changes
+func (h *DirectorySyncHandler) GetRun(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid directory sync ID"})
+		return
+	}
+
+	report, err := h.directorySyncService.GetRun(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Directory sync not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
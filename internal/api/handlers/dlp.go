@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/dlp"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// scanSensitiveContent runs DLP on uploaded content. Uploads with findings
+// are rejected with 422 when DLP blocks them; otherwise the findings are
+// returned for the caller to raise the access level and record them. doc is
+// the existing document for new versions and nil for new documents.
+func (h *DocumentHandler) scanSensitiveContent(c *gin.Context, user *models.User, doc *models.Document, fileName, mimeType string, content []byte) ([]dlp.Finding, bool) {
+	findings := h.dlpService.Scan(mimeType, fileName, content)
+	if len(findings) == 0 || h.dlpService.Action() != models.DLPActionBlock {
+		return findings, true
+	}
+
+	var documentID *uint
+	if doc != nil {
+		documentID = &doc.ID
+	}
+	if err := h.dlpService.RecordBlocked(findings, documentID, user.ID, fileName, content); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record DLP findings"})
+		return nil, false
+	}
+
+	resourceID := ""
+	if doc != nil {
+		resourceID = strconv.Itoa(int(doc.ID))
+	}
+	h.auditService.LogAction(user.ID, documentID, "dlp_upload_blocked", "document", resourceID, c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"file_name": fileName,
+		"findings":  findings,
+	})
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":    "Upload contains sensitive content",
+		"findings": findings,
+	})
+	return nil, false
+}
+
+// recordSensitiveContent records the DLP findings of a stored document whose
+// access level was raised because of them
+func (h *DocumentHandler) recordSensitiveContent(c *gin.Context, user *models.User, doc *models.Document, findings []dlp.Finding, previousLevel models.AccessLevel) {
+	if len(findings) == 0 {
+		return
+	}
+
+	// The document is stored; failing to record findings doesn't undo it
+	h.dlpService.RecordRaised(findings, doc, user.ID)
+
+	h.auditService.LogAction(user.ID, &doc.ID, "dlp_access_level_raised", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"version":        doc.Version,
+		"findings":       findings,
+		"previous_level": previousLevel,
+		"access_level":   doc.AccessLevel,
+	})
+}
+
+// DLPHandler handles review of data loss prevention findings
+type DLPHandler struct {
+	dlpService *services.DLPService
+}
+
+// NewDLPHandler creates a new DLP handler
+func NewDLPHandler(dlpService *services.DLPService) *DLPHandler {
+	return &DLPHandler{
+		dlpService: dlpService,
+	}
+}
+
+// GetFindings lists the DLP findings of the user's organization, optionally
+// filtered by document_id, user_id, rule and action
+func (h *DLPHandler) GetFindings(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	filter := &services.DLPFindingFilter{
+		OrganizationID: user.OrganizationID,
+		Rule:           c.Query("rule"),
+		Action:         models.DLPAction(c.Query("action")),
+	}
+
+	idParams := map[string]**uint{
+		"document_id": &filter.DocumentID,
+		"user_id":     &filter.UserID,
+	}
+	for name, target := range idParams {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+			return
+		}
+		parsed := uint(id)
+		*target = &parsed
+	}
+
+	findings, total, err := h.dlpService.GetFindings(filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get DLP findings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  findings,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
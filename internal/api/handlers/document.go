@@ -0,0 +1,932 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/filetype"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/auth"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// DocumentHandler handles document related requests
+type DocumentHandler struct {
+	documentService       *services.DocumentService
+	folderService         *services.FolderService
+	searchService         *services.SearchService
+	semanticService       *services.SemanticSearchService
+	autoTagService        *services.AutoTagService
+	metadataService       *services.MetadataService
+	commentService        *services.CommentService
+	subscriptionService   *services.SubscriptionService
+	uploadService         *services.UploadService
+	bulkUploadService     *services.BulkUploadService
+	exportService         *services.ExportService
+	importService         *services.ImportService
+	fileTypes             *filetype.Policy
+	watermarkService      *services.WatermarkService
+	dlpService            *services.DLPService
+	classificationService *services.ClassificationService
+	authService           *services.AuthorizationService
+	roleService           *services.RoleService
+	stepUpPolicy          *services.StepUpPolicy
+	justificationPolicy   *services.JustificationPolicy
+	auditService          *services.AuditService
+	blockchainService     *services.BlockchainService
+}
+
+// NewDocumentHandler creates a new document handler. semanticService,
+// importService and blockchainService may be nil when semantic search,
+// imports or the blockchain are disabled.
+func NewDocumentHandler(
+	documentService *services.DocumentService,
+	folderService *services.FolderService,
+	searchService *services.SearchService,
+	semanticService *services.SemanticSearchService,
+	autoTagService *services.AutoTagService,
+	metadataService *services.MetadataService,
+	commentService *services.CommentService,
+	subscriptionService *services.SubscriptionService,
+	uploadService *services.UploadService,
+	bulkUploadService *services.BulkUploadService,
+	exportService *services.ExportService,
+	importService *services.ImportService,
+	fileTypes *filetype.Policy,
+	watermarkService *services.WatermarkService,
+	dlpService *services.DLPService,
+	classificationService *services.ClassificationService,
+	authService *services.AuthorizationService,
+	roleService *services.RoleService,
+	stepUpPolicy *services.StepUpPolicy,
+	justificationPolicy *services.JustificationPolicy,
+	auditService *services.AuditService,
+	blockchainService *services.BlockchainService,
+) *DocumentHandler {
+	return &DocumentHandler{
+		documentService:       documentService,
+		folderService:         folderService,
+		searchService:         searchService,
+		semanticService:       semanticService,
+		autoTagService:        autoTagService,
+		metadataService:       metadataService,
+		commentService:        commentService,
+		subscriptionService:   subscriptionService,
+		uploadService:         uploadService,
+		bulkUploadService:     bulkUploadService,
+		exportService:         exportService,
+		importService:         importService,
+		fileTypes:             fileTypes,
+		watermarkService:      watermarkService,
+		dlpService:            dlpService,
+		classificationService: classificationService,
+		authService:           authService,
+		roleService:           roleService,
+		stepUpPolicy:          stepUpPolicy,
+		justificationPolicy:   justificationPolicy,
+		auditService:          auditService,
+		blockchainService:     blockchainService,
+	}
+}
+
+// DocumentResponse represents document data in responses
+type DocumentResponse struct {
+	ID              uint                   `json:"id"`
+	Title           string                 `json:"title"`
+	Description     string                 `json:"description"`
+	FileName        string                 `json:"file_name"`
+	FileHash        string                 `json:"file_hash"`
+	FileSize        int64                  `json:"file_size"`
+	MimeType        string                 `json:"mime_type"`
+	Category        string                 `json:"category"`
+	Tags            string                 `json:"tags"`
+	AccessLevel     models.AccessLevel     `json:"access_level"`
+	FolderID        *uint                  `json:"folder_id"`
+	IsEncrypted     bool                   `json:"is_encrypted"`
+	ScanStatus      models.ScanStatus      `json:"scan_status"`
+	IntegrityStatus models.IntegrityStatus `json:"integrity_status,omitempty"`
+	LockedAt        *time.Time             `json:"locked_at,omitempty"`
+	Version         int                    `json:"version"`
+	CreatedBy       uint                   `json:"created_by"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// DocumentSearchResponse represents a search hit with its relevance rank
+type DocumentSearchResponse struct {
+	*DocumentResponse
+	Rank float64 `json:"rank"`
+}
+
+// UpdateDocumentRequest represents document metadata update request
+type UpdateDocumentRequest struct {
+	Title       *string             `json:"title"`
+	Description *string             `json:"description"`
+	Category    *string             `json:"category"`
+	Tags        *string             `json:"tags"`
+	AccessLevel *models.AccessLevel `json:"access_level"`
+	// AccessLevelReason justifies lowering the access level
+	AccessLevelReason string `json:"access_level_reason"`
+}
+
+// newDocumentResponse converts a document model to its response representation
+func newDocumentResponse(doc *models.Document) *DocumentResponse {
+	return &DocumentResponse{
+		ID:              doc.ID,
+		Title:           doc.Title,
+		Description:     doc.Description,
+		FileName:        doc.FileName,
+		FileHash:        doc.FileHash,
+		FileSize:        doc.FileSize,
+		MimeType:        doc.MimeType,
+		Category:        doc.Category,
+		Tags:            doc.Tags,
+		AccessLevel:     doc.AccessLevel,
+		FolderID:        doc.FolderID,
+		IsEncrypted:     doc.IsEncrypted,
+		ScanStatus:      doc.ScanStatus,
+		IntegrityStatus: doc.IntegrityStatus,
+		LockedAt:        doc.LockedAt,
+		Version:         doc.Version,
+		CreatedBy:       doc.CreatedBy,
+		CreatedAt:       doc.CreatedAt,
+		UpdatedAt:       doc.UpdatedAt,
+	}
+}
+
+// validAccessLevel checks whether the access level is within the known range
+func validAccessLevel(level models.AccessLevel) bool {
+	return level >= models.AccessPublic && level <= models.AccessTopSecret
+}
+
+// GetDocuments returns documents visible to the current user
+func (h *DocumentHandler) GetDocuments(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filter, err := parseDocumentFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.resolveFilter(c, filter) {
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	docs, total, err := h.documentService.GetAccessible(c.Request.Context(), user, filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get documents"})
+		return
+	}
+
+	responses := make([]*DocumentResponse, 0, len(docs))
+	for i := range docs {
+		responses = append(responses, newDocumentResponse(&docs[i]))
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  responses,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// parseDocumentFilter builds a document filter from query parameters
+func parseDocumentFilter(c *gin.Context) (*services.DocumentFilter, error) {
+	filter := &services.DocumentFilter{
+		Category:     c.Query("category"),
+		Department:   c.Query("department"),
+		MimeType:     c.Query("mime_type"),
+		SortBy:       c.Query("sort"),
+		SortDesc:     c.DefaultQuery("order", "desc") == "desc",
+		MatchAllTags: c.DefaultQuery("tag_mode", "any") == "all",
+	}
+
+	if tags := c.Query("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+
+	intParams := map[string]*int64{
+		"min_size": &filter.MinSize,
+		"max_size": &filter.MaxSize,
+	}
+	for name, target := range intParams {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 0 {
+				return nil, fmt.Errorf("invalid %s", name)
+			}
+			*target = parsed
+		}
+	}
+
+	levelParams := map[string]*models.AccessLevel{
+		"min_access_level": &filter.MinAccessLevel,
+		"max_access_level": &filter.MaxAccessLevel,
+	}
+	for name, target := range levelParams {
+		if value := c.Query(name); value != "" {
+			level, err := strconv.Atoi(value)
+			if err != nil || !validAccessLevel(models.AccessLevel(level)) {
+				return nil, fmt.Errorf("invalid %s", name)
+			}
+			*target = models.AccessLevel(level)
+		}
+	}
+
+	if value := c.Query("created_by"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_by")
+		}
+		filter.CreatedBy = uint(id)
+	}
+
+	if value := c.Query("folder_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid folder_id")
+		}
+		folderID := uint(id)
+		filter.FolderID = &folderID
+	}
+
+	dateParams := map[string]**time.Time{
+		"created_from": &filter.CreatedAfter,
+		"created_to":   &filter.CreatedBefore,
+		"updated_from": &filter.UpdatedAfter,
+		"updated_to":   &filter.UpdatedBefore,
+	}
+	for name, target := range dateParams {
+		if value := c.Query(name); value != "" {
+			parsed, err := parseDateParam(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s, expected RFC 3339 or YYYY-MM-DD", name)
+			}
+			*target = &parsed
+		}
+	}
+
+	filter.Metadata = parseMetadataConditions(c)
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// parseMetadataConditions reads custom metadata filters given as
+// metadata[key]=value, metadata_min[key]=value and metadata_max[key]=value
+func parseMetadataConditions(c *gin.Context) []services.MetadataCondition {
+	params := []struct {
+		name     string
+		operator services.MetadataOperator
+	}{
+		{"metadata", services.MetadataEquals},
+		{"metadata_min", services.MetadataAtLeast},
+		{"metadata_max", services.MetadataAtMost},
+	}
+
+	var conditions []services.MetadataCondition
+	for _, param := range params {
+		for key, value := range c.QueryMap(param.name) {
+			conditions = append(conditions, services.MetadataCondition{Key: key, Operator: param.operator, Value: value})
+		}
+	}
+	return conditions
+}
+
+// resolveFilter resolves the metadata conditions of a filter, writing an
+// error response when they are invalid
+func (h *DocumentHandler) resolveFilter(c *gin.Context, filter *services.DocumentFilter) bool {
+	if err := h.metadataService.ResolveFilter(filter); err != nil {
+		if errors.Is(err, services.ErrInvalidMetadata) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve metadata filters"})
+		return false
+	}
+	return true
+}
+
+// SearchDocuments performs a ranked full-text search over accessible documents
+func (h *DocumentHandler) SearchDocuments(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	filter := &services.DocumentFilter{Metadata: parseMetadataConditions(c)}
+	if !h.resolveFilter(c, filter) {
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	results, total, err := h.searchService.Search(c.Request.Context(), user, query, filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search documents"})
+		return
+	}
+
+	responses := make([]*DocumentSearchResponse, 0, len(results))
+	for i := range results {
+		responses = append(responses, &DocumentSearchResponse{
+			DocumentResponse: newDocumentResponse(&results[i].Document),
+			Rank:             results[i].Rank,
+		})
+	}
+
+	h.auditService.LogAction(user.ID, nil, "document_search", "document", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"query":   query,
+		"results": total,
+	})
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  responses,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// SemanticSearchDocuments returns accessible documents ranked by similarity to the query
+func (h *DocumentHandler) SemanticSearchDocuments(c *gin.Context) {
+	if h.semanticService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Semantic search is not enabled"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	_, limit := parsePagination(c)
+
+	results, err := h.semanticService.Search(c.Request.Context(), user, query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search documents"})
+		return
+	}
+
+	responses := make([]*DocumentSearchResponse, 0, len(results))
+	for i := range results {
+		responses = append(responses, &DocumentSearchResponse{
+			DocumentResponse: newDocumentResponse(&results[i].Document),
+			Rank:             results[i].Rank,
+		})
+	}
+
+	h.auditService.LogAction(user.ID, nil, "document_semantic_search", "document", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"query":   query,
+		"results": len(responses),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// CreateDocument handles document upload
+func (h *DocumentHandler) CreateDocument(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		if writeBodyTooLarge(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	title := c.PostForm("title")
+	if title == "" {
+		title = fileHeader.Filename
+	}
+
+	// An unset access level is classified from the upload
+	var accessLevel models.AccessLevel
+	if value := c.PostForm("access_level"); value != "" {
+		level, err := strconv.Atoi(value)
+		if err != nil || !validAccessLevel(models.AccessLevel(level)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access level"})
+			return
+		}
+		accessLevel = models.AccessLevel(level)
+	}
+
+	var folderID *uint
+	if value := c.PostForm("folder_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+			return
+		}
+		if !h.authorizeFolder(c, user, uint(id)) {
+			return
+		}
+		folder := uint(id)
+		folderID = &folder
+	}
+
+	content, err := readFormFile(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	if !verifyChecksum(c, content) {
+		return
+	}
+
+	doc := &models.Document{
+		Title:       title,
+		Description: c.PostForm("description"),
+		FileName:    fileHeader.Filename,
+		MimeType:    fileHeader.Header.Get("Content-Type"),
+		Category:    c.PostForm("category"),
+		Tags:        c.PostForm("tags"),
+		AccessLevel: accessLevel,
+		FolderID:    folderID,
+		Version:     1,
+		CreatedBy:   user.ID,
+	}
+
+	if !h.createDocument(c, user, doc, content, c.PostForm("access_level_reason")) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, newDocumentResponse(doc))
+}
+
+// createDocument stores an uploaded document and records its creation. A
+// zero access level is set from the suggested classification; reason
+// justifies a requested level below it. It writes an error response and
+// returns false on failure.
+func (h *DocumentHandler) createDocument(c *gin.Context, user *models.User, doc *models.Document, content []byte, reason string) bool {
+	mimeType, ok := h.checkFileType(c, doc.FileName, content)
+	if !ok {
+		return false
+	}
+	if doc.MimeType == "" {
+		doc.MimeType = mimeType
+	}
+	doc.OrganizationID = user.OrganizationID
+
+	classification, ok := h.classifyUpload(c, user, doc, content, reason)
+	if !ok {
+		return false
+	}
+
+	findings, ok := h.scanSensitiveContent(c, user, nil, doc.FileName, doc.MimeType, content)
+	if !ok {
+		return false
+	}
+	requestedLevel := doc.AccessLevel
+	if len(findings) > 0 {
+		doc.AccessLevel = h.dlpService.RaisedLevel(doc.AccessLevel)
+	}
+
+	if err := h.documentService.Create(c.Request.Context(), doc, content); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create document"})
+		return false
+	}
+
+	h.recordSensitiveContent(c, user, doc, findings, requestedLevel)
+
+	details := map[string]interface{}{
+		"title":     doc.Title,
+		"file_name": doc.FileName,
+		"file_hash": doc.FileHash,
+		"version":   doc.Version,
+	}
+	if len(classification.Rules) > 0 {
+		details["suggested_access_level"] = classification.AccessLevel
+		details["classification_rules"] = classification.Rules
+	}
+	h.auditService.LogAction(user.ID, &doc.ID, "document_create", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+	h.recordOnChain(doc.ID, user.ID, "document_create", details)
+
+	h.recordClassificationOverride(c, user, doc, classification, reason)
+
+	return true
+}
+
+// checkFileType checks an uploaded file against the file type policy using
+// the type detected from its content rather than the declared one, and
+// returns the detected type. It writes a 415 response and returns false
+// when the file is rejected.
+func (h *DocumentHandler) checkFileType(c *gin.Context, fileName string, content []byte) (string, bool) {
+	mimeType, err := h.fileTypes.Check(fileName, content)
+	if err != nil {
+		writeFileTypeError(c, err, mimeType)
+		return "", false
+	}
+	return mimeType, true
+}
+
+// writeFileTypeError writes the response for a file rejected by the file
+// type policy
+func writeFileTypeError(c *gin.Context, err error, detectedType string) {
+	var notAllowed *filetype.NotAllowedError
+	if !errors.As(err, &notAllowed) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check file type"})
+		return
+	}
+
+	response := gin.H{"error": "File type not allowed", "reason": notAllowed.Reason}
+	if detectedType != "" {
+		response["detected_type"] = detectedType
+	}
+	c.JSON(http.StatusUnsupportedMediaType, response)
+}
+
+// readFormFile reads the full content of an uploaded file
+func readFormFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// checksumHeader carries the client-computed SHA-256 of an uploaded file
+const checksumHeader = "X-Content-SHA256"
+
+// verifyChecksum compares the uploaded content with the SHA-256 checksum the
+// client sent in the X-Content-SHA256 header or the sha256 form field, hex or
+// base64 encoded. It writes an error response and returns false when the
+// checksum is malformed or doesn't match; uploads without one are accepted.
+func verifyChecksum(c *gin.Context, content []byte) bool {
+	value := strings.TrimSpace(c.GetHeader(checksumHeader))
+	if value == "" {
+		value = strings.TrimSpace(c.PostForm("sha256"))
+	}
+	if value == "" {
+		return true
+	}
+
+	expected, err := parseChecksum(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SHA-256 checksum"})
+		return false
+	}
+
+	actual := sha256.Sum256(content)
+	if subtle.ConstantTimeCompare(expected, actual[:]) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Checksum mismatch",
+			"expected": hex.EncodeToString(expected),
+			"actual":   hex.EncodeToString(actual[:]),
+		})
+		return false
+	}
+
+	return true
+}
+
+// parseChecksum decodes a hex or base64 encoded SHA-256 checksum
+func parseChecksum(value string) ([]byte, error) {
+	checksum, err := hex.DecodeString(value)
+	if err != nil {
+		checksum, err = base64.StdEncoding.DecodeString(value)
+	}
+	if err != nil || len(checksum) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 checksum")
+	}
+	return checksum, nil
+}
+
+// GetDocument returns document metadata
+func (h *DocumentHandler) GetDocument(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_view", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+
+	c.JSON(http.StatusOK, newDocumentResponse(doc))
+}
+
+// DownloadDocument decrypts and returns the document content
+func (h *DocumentHandler) DownloadDocument(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionDownload) {
+		return
+	}
+
+	reason, ok := checkJustification(c, h.justificationPolicy, doc)
+	if !ok {
+		return
+	}
+
+	if !checkScanStatus(c, doc) {
+		return
+	}
+
+	content, err := h.documentService.ReadContent(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read document"})
+		return
+	}
+
+	details := map[string]interface{}{}
+	if h.watermarkService.Applies(doc) && filetype.Detect(content) == "application/pdf" {
+		if content, err = h.watermarkService.Stamp(content, doc, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watermark document"})
+			return
+		}
+		details["watermarked"] = true
+	}
+	if reason != "" {
+		details["justification"] = reason
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_download", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+
+	mimeType := doc.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	setContentDisposition(c, "attachment", doc.FileName)
+	c.Data(http.StatusOK, mimeType, content)
+}
+
+// checkScanStatus writes an error response and returns false when the
+// document content is awaiting its virus scan or quarantined
+func checkScanStatus(c *gin.Context, doc *models.Document) bool {
+	switch doc.ScanStatus {
+	case models.ScanPending:
+		c.JSON(http.StatusConflict, gin.H{"error": "Document is awaiting virus scan"})
+		return false
+	case models.ScanInfected:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Document is quarantined"})
+		return false
+	}
+	return true
+}
+
+// checkJustification returns the reason given in the reason query parameter
+// for downloading the document. It writes a 400 response with
+// justification_required and returns false when the document needs a reason
+// and none was given, or the reason is too long.
+func checkJustification(c *gin.Context, policy *services.JustificationPolicy, doc *models.Document) (string, bool) {
+	reason, err := policy.Check(doc, c.Query("reason"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":                  err.Error(),
+			"justification_required": policy.Required(doc),
+		})
+		return "", false
+	}
+	return reason, true
+}
+
+// authTime returns when the current user last entered a password or used a
+// security key, or the zero time for API keys and service tokens
+func authTime(c *gin.Context) time.Time {
+	claims, ok := c.Get("token_claims")
+	if !ok {
+		return time.Time{}
+	}
+	return claims.(*auth.Claims).AuthenticatedAt()
+}
+
+// checkStepUp writes a 401 response the client can answer by
+// authenticating again at /auth/step-up, and returns false, when reading the
+// document needs a fresher authentication than the current token's
+func checkStepUp(c *gin.Context, policy *services.StepUpPolicy, doc *models.Document) bool {
+	if policy.Allows(doc, authTime(c)) {
+		return true
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":            "Fresh authentication required",
+		"step_up_required": true,
+		"max_age":          int(policy.MaxAge().Seconds()),
+	})
+	return false
+}
+
+// UpdateDocument updates document metadata
+func (h *DocumentHandler) UpdateDocument(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionWrite) {
+		return
+	}
+
+	var req UpdateDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.Title != nil {
+		if *req.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Title cannot be empty"})
+			return
+		}
+		doc.Title = *req.Title
+	}
+	if req.Description != nil {
+		doc.Description = *req.Description
+	}
+	if req.Category != nil {
+		doc.Category = *req.Category
+	}
+	previousLevel := doc.AccessLevel
+	if req.AccessLevel != nil && *req.AccessLevel != doc.AccessLevel {
+		if !validAccessLevel(*req.AccessLevel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access level"})
+			return
+		}
+		// Raising it beyond the user's clearance would lock others out, and
+		// lowering it may publish the document, so that needs a reason
+		if *req.AccessLevel > services.MaxAccessLevel(user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access level is above your clearance"})
+			return
+		}
+		if *req.AccessLevel < doc.AccessLevel && strings.TrimSpace(req.AccessLevelReason) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lowering the access level requires access_level_reason"})
+			return
+		}
+		doc.AccessLevel = *req.AccessLevel
+	}
+
+	if err := h.documentService.Update(c.Request.Context(), doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update document"})
+		return
+	}
+
+	if req.Tags != nil {
+		if err := h.documentService.SetTags(c.Request.Context(), doc, services.ParseTags(*req.Tags), user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update document tags"})
+			return
+		}
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_update", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+	if doc.AccessLevel != previousLevel {
+		h.auditService.LogAction(user.ID, &doc.ID, "document_access_level_change", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+			"previous_level": previousLevel,
+			"access_level":   doc.AccessLevel,
+			"reason":         strings.TrimSpace(req.AccessLevelReason),
+		})
+	}
+
+	c.JSON(http.StatusOK, newDocumentResponse(doc))
+}
+
+// DeleteDocument soft deletes a document
+func (h *DocumentHandler) DeleteDocument(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionDelete) {
+		return
+	}
+
+	if err := h.documentService.Delete(c.Request.Context(), doc.ID, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete document"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_delete", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"title": doc.Title,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted successfully"})
+}
+
+// loadDocument resolves the current user and the document from the :id parameter,
+// writing an error response when either is unavailable
+func (h *DocumentHandler) loadDocument(c *gin.Context) (*models.User, *models.Document, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return nil, nil, false
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), id)
+	if err != nil || doc.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, nil, false
+	}
+
+	return user, doc, true
+}
+
+// authorize checks whether the user may perform the action on the document,
+// writing an error response when the check fails or the action is denied.
+// Reading restricted documents may also need a fresh authentication.
+func (h *DocumentHandler) authorize(c *gin.Context, user *models.User, doc *models.Document, action services.Action) bool {
+	if doc.LockedAt != nil && (action == services.ActionWrite || action == services.ActionDelete) {
+		c.JSON(http.StatusLocked, gin.H{"error": "Document is locked", "reason": doc.LockReason})
+		return false
+	}
+
+	allowed, err := h.authService.Can(user, doc, action)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return false
+	}
+
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+
+	if action.ReadOnly() {
+		return checkStepUp(c, h.stepUpPolicy, doc)
+	}
+	return true
+}
+
+// authorizeFolder checks that the folder exists and the user may add documents to it,
+// writing an error response otherwise
+func (h *DocumentHandler) authorizeFolder(c *gin.Context, user *models.User, folderID uint) bool {
+	folder, err := h.folderService.GetByID(folderID)
+	if err != nil || folder.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Folder not found"})
+		return false
+	}
+
+	allowed, err := h.authService.CanOnFolder(user, folder, services.ActionWrite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return false
+	}
+
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+
+	return true
+}
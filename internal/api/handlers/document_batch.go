@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// maxBatchDocuments bounds the documents of a batch request
+const maxBatchDocuments = 500
+
+// BatchRequest represents a batch operation over documents. tags apply to
+// the tag operation, folder_id to move (omit for none) and access_level to
+// access_level, with access_level_reason when it lowers any document's.
+type BatchRequest struct {
+	Operation         services.BatchOperation `json:"operation" binding:"required"`
+	DocumentIDs       []uint                  `json:"document_ids" binding:"required,min=1"`
+	Tags              []string                `json:"tags"`
+	FolderID          *uint                   `json:"folder_id"`
+	AccessLevel       models.AccessLevel      `json:"access_level"`
+	AccessLevelReason string                  `json:"access_level_reason"`
+}
+
+// BatchItemResult is the outcome of a batch operation for one document
+type BatchItemResult struct {
+	DocumentID uint   `json:"document_id"`
+	Succeeded  bool   `json:"succeeded"`
+	Error      string `json:"error,omitempty"`
+}
+
+// batchActions maps batch operations to the permission they need and the
+// audit action of the equivalent single-document change
+var batchActions = map[services.BatchOperation]struct {
+	permission  services.Action
+	auditAction string
+}{
+	services.BatchTag:         {services.ActionWrite, "document_tag_add"},
+	services.BatchMove:        {services.ActionWrite, "document_move"},
+	services.BatchAccessLevel: {services.ActionWrite, "document_access_level_change"},
+	services.BatchDelete:      {services.ActionDelete, "document_delete"},
+}
+
+// BatchDocuments applies one operation to a list of documents. Every document
+// is checked first; if any is missing or not permitted nothing is changed and
+// 422 is returned with the result of each document. Otherwise all changes and
+// their audit entries are stored in one transaction.
+func (h *DocumentHandler) BatchDocuments(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	actions, ok := batchActions[req.Operation]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operation, expected tag, move, access_level or delete"})
+		return
+	}
+
+	ids := uniqueIDs(req.DocumentIDs)
+	if len(ids) > maxBatchDocuments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many documents, at most " + strconv.Itoa(maxBatchDocuments) + " per batch"})
+		return
+	}
+
+	change := &services.BatchChange{Operation: req.Operation}
+	switch req.Operation {
+	case services.BatchTag:
+		change.Tags = services.NormalizeTags(req.Tags)
+		if len(change.Tags) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tags are required"})
+			return
+		}
+	case services.BatchMove:
+		if req.FolderID != nil && !h.authorizeFolder(c, user, *req.FolderID) {
+			return
+		}
+		change.FolderID = req.FolderID
+	case services.BatchAccessLevel:
+		if !validAccessLevel(req.AccessLevel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access level"})
+			return
+		}
+		if req.AccessLevel > services.MaxAccessLevel(user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access level is above your clearance"})
+			return
+		}
+		change.AccessLevel = req.AccessLevel
+	}
+
+	docs := make([]*models.Document, 0, len(ids))
+	results := make([]BatchItemResult, 0, len(ids))
+	failed := false
+	for _, id := range ids {
+		result := BatchItemResult{DocumentID: id}
+
+		doc, err := h.documentService.GetByID(c.Request.Context(), id)
+		if err != nil {
+			result.Error = "Document not found"
+		} else if allowed, err := h.authService.Can(user, doc, actions.permission); err != nil {
+			result.Error = "Failed to check permissions"
+		} else if !allowed {
+			result.Error = "Insufficient permissions"
+		} else {
+			docs = append(docs, doc)
+		}
+
+		failed = failed || result.Error != ""
+		results = append(results, result)
+	}
+
+	if failed {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "No documents were changed",
+			"results": results,
+		})
+		return
+	}
+
+	reason := strings.TrimSpace(req.AccessLevelReason)
+	if change.Operation == services.BatchAccessLevel && reason == "" {
+		for _, doc := range docs {
+			if change.AccessLevel < doc.AccessLevel {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Lowering the access level requires access_level_reason"})
+				return
+			}
+		}
+	}
+
+	batchID := newBatchID()
+	details := make([]map[string]interface{}, 0, len(docs))
+	entries := make([]*models.AuditLog, 0, len(docs))
+	for _, doc := range docs {
+		details = append(details, batchAuditDetails(doc, change, batchID, reason))
+		entry, err := services.NewAuditEntry(user.ID, &doc.ID, actions.auditAction, "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details[len(details)-1])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply batch operation"})
+			return
+		}
+		entry.OrganizationID = user.OrganizationID
+		entries = append(entries, entry)
+	}
+
+	var chainActions []services.ChainAction
+	if change.Operation == services.BatchMove && h.blockchainService != nil {
+		for i, doc := range docs {
+			chainActions = append(chainActions, services.ChainAction{DocumentID: doc.ID, Action: "document_move", Data: details[i]})
+		}
+	}
+
+	if err := h.documentService.ApplyBatch(c.Request.Context(), docs, change, user.ID, entries, chainActions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply batch operation"})
+		return
+	}
+
+	for i := range results {
+		results[i].Succeeded = true
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"batch_id": batchID,
+		"results":  results,
+	})
+}
+
+// batchAuditDetails describes the change of one document of a batch for the
+// audit log, in the shape of the equivalent single-document entry
+func batchAuditDetails(doc *models.Document, change *services.BatchChange, batchID, reason string) map[string]interface{} {
+	details := map[string]interface{}{"batch_id": batchID}
+	switch change.Operation {
+	case services.BatchTag:
+		details["tags"] = change.Tags
+	case services.BatchMove:
+		details["from_folder_id"] = doc.FolderID
+		details["to_folder_id"] = change.FolderID
+	case services.BatchAccessLevel:
+		details["previous_level"] = doc.AccessLevel
+		details["access_level"] = change.AccessLevel
+		details["reason"] = reason
+	case services.BatchDelete:
+		details["title"] = doc.Title
+	}
+	return details
+}
+
+// uniqueIDs removes duplicate IDs, keeping the first occurrence
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// newBatchID returns a random identifier linking the audit entries of a batch
+func newBatchID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
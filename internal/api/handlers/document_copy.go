@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// MoveDocumentRequest represents a document move request. A missing folder_id
+// moves the document out of any folder.
+type MoveDocumentRequest struct {
+	FolderID *uint `json:"folder_id"`
+}
+
+// CopyDocumentRequest represents a document copy request
+type CopyDocumentRequest struct {
+	FolderID           *uint  `json:"folder_id"`
+	Title              string `json:"title"`
+	IncludeVersions    bool   `json:"include_versions"`
+	IncludePermissions bool   `json:"include_permissions"`
+}
+
+// MoveDocument places a document in another folder
+func (h *DocumentHandler) MoveDocument(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionWrite) {
+		return
+	}
+
+	var req MoveDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.FolderID != nil && !h.authorizeFolder(c, user, *req.FolderID) {
+		return
+	}
+
+	fromFolder := doc.FolderID
+	if err := h.documentService.Move(c.Request.Context(), doc, req.FolderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move document"})
+		return
+	}
+
+	details := map[string]interface{}{
+		"from_folder_id": fromFolder,
+		"to_folder_id":   doc.FolderID,
+	}
+	h.auditService.LogAction(user.ID, &doc.ID, "document_move", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+	h.recordOnChain(doc.ID, user.ID, "document_move", details)
+
+	c.JSON(http.StatusOK, newDocumentResponse(doc))
+}
+
+// CopyDocument duplicates a document, optionally with its versions and permissions
+func (h *DocumentHandler) CopyDocument(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return
+	}
+
+	var req CopyDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Copying permissions hands out access, which takes the right to share
+	if req.IncludePermissions && !h.authorize(c, user, doc, services.ActionShare) {
+		return
+	}
+
+	if req.FolderID != nil && !h.authorizeFolder(c, user, *req.FolderID) {
+		return
+	}
+
+	copied, err := h.documentService.Copy(c.Request.Context(), doc, services.CopyOptions{
+		FolderID:           req.FolderID,
+		Title:              req.Title,
+		IncludeVersions:    req.IncludeVersions,
+		IncludePermissions: req.IncludePermissions,
+	}, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy document"})
+		return
+	}
+
+	details := map[string]interface{}{
+		"source_document_id":  doc.ID,
+		"copy_document_id":    copied.ID,
+		"folder_id":           copied.FolderID,
+		"include_versions":    req.IncludeVersions,
+		"include_permissions": req.IncludePermissions,
+		"file_hash":           copied.FileHash,
+	}
+	h.auditService.LogAction(user.ID, &doc.ID, "document_copy", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+	h.auditService.LogAction(user.ID, &copied.ID, "document_create", "document", strconv.Itoa(int(copied.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+	h.recordOnChain(doc.ID, user.ID, "document_copy", details)
+	h.recordOnChain(copied.ID, user.ID, "document_create", details)
+
+	c.JSON(http.StatusCreated, newDocumentResponse(copied))
+}
+
+// recordOnChain records a document operation on the blockchain when it is enabled
+func (h *DocumentHandler) recordOnChain(documentID, userID uint, action string, data map[string]interface{}) {
+	if h.blockchainService == nil {
+		return
+	}
+
+	if _, err := h.blockchainService.RecordDocumentAction(documentID, userID, action, data); err != nil {
+		log.Printf("Failed to record %s of document %d on the blockchain: %v", action, documentID, err)
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// DocumentVersionResponse represents a document version in responses
+type DocumentVersionResponse struct {
+	ID          uint      `json:"id"`
+	DocumentID  uint      `json:"document_id"`
+	Version     int       `json:"version"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	FileName    string    `json:"file_name"`
+	FileHash    string    `json:"file_hash"`
+	FileSize    int64     `json:"file_size"`
+	MimeType    string    `json:"mime_type"`
+	ChangeLog   string    `json:"change_log"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// newDocumentVersionResponse converts a version model to its response representation
+func newDocumentVersionResponse(version *models.DocumentVersion) *DocumentVersionResponse {
+	return &DocumentVersionResponse{
+		ID:          version.ID,
+		DocumentID:  version.DocumentID,
+		Version:     version.Version,
+		Title:       version.Title,
+		Description: version.Description,
+		FileName:    version.FileName,
+		FileHash:    version.FileHash,
+		FileSize:    version.FileSize,
+		MimeType:    version.MimeType,
+		ChangeLog:   version.ChangeLog,
+		CreatedBy:   version.CreatedBy,
+		CreatedAt:   version.CreatedAt,
+	}
+}
+
+// GetVersions returns the version history of a document
+func (h *DocumentHandler) GetVersions(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return
+	}
+
+	versions, err := h.documentService.GetVersions(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get document versions"})
+		return
+	}
+
+	responses := make([]*DocumentVersionResponse, 0, len(versions))
+	for i := range versions {
+		responses = append(responses, newDocumentVersionResponse(&versions[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// CreateVersion uploads a new version of a document
+func (h *DocumentHandler) CreateVersion(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionWrite) {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		if writeBodyTooLarge(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	content, err := readFormFile(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	if !verifyChecksum(c, content) {
+		return
+	}
+
+	changeLog := c.PostForm("change_log")
+	if changeLog == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Change log is required"})
+		return
+	}
+
+	version, ok := h.createVersion(c, user, doc, content, fileHeader.Filename, fileHeader.Header.Get("Content-Type"), changeLog)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusCreated, newDocumentVersionResponse(version))
+}
+
+// createVersion stores uploaded content as the next version of a document and
+// notifies its subscribers. It writes an error response and returns false on
+// failure.
+func (h *DocumentHandler) createVersion(c *gin.Context, user *models.User, doc *models.Document, content []byte, fileName, mimeType, changeLog string) (*models.DocumentVersion, bool) {
+	name := fileName
+	if name == "" {
+		name = doc.FileName
+	}
+	detectedType, ok := h.checkFileType(c, name, content)
+	if !ok {
+		return nil, false
+	}
+	if mimeType == "" {
+		mimeType = detectedType
+	}
+
+	findings, ok := h.scanSensitiveContent(c, user, doc, name, mimeType, content)
+	if !ok {
+		return nil, false
+	}
+
+	// Raise the access level before the sensitive content is stored
+	previousLevel := doc.AccessLevel
+	if len(findings) > 0 {
+		if doc.AccessLevel = h.dlpService.RaisedLevel(previousLevel); doc.AccessLevel != previousLevel {
+			if err := h.documentService.Update(c.Request.Context(), doc); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to raise document access level"})
+				return nil, false
+			}
+		}
+	}
+
+	version, err := h.documentService.CreateVersion(c.Request.Context(), doc, content, fileName, mimeType, changeLog, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create document version"})
+		return nil, false
+	}
+
+	h.recordSensitiveContent(c, user, doc, findings, previousLevel)
+
+	details := map[string]interface{}{
+		"version":    version.Version,
+		"file_hash":  version.FileHash,
+		"change_log": changeLog,
+	}
+	h.auditService.LogAction(user.ID, &doc.ID, "document_version_create", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+	h.recordOnChain(doc.ID, user.ID, "document_version_create", details)
+
+	h.subscriptionService.Publish(doc, &services.SubscriptionEvent{
+		Type:    models.NotificationNewVersion,
+		ActorID: user.ID,
+		Message: fmt.Sprintf("%s uploaded version %d of %q", user.Username, version.Version, doc.Title),
+	})
+
+	return version, true
+}
+
+// RestoreVersion rolls a document back to an earlier version
+func (h *DocumentHandler) RestoreVersion(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionWrite) {
+		return
+	}
+
+	versionNumber, err := strconv.Atoi(c.Param("version"))
+	if err != nil || versionNumber < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	if versionNumber == doc.Version {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Version is already current"})
+		return
+	}
+
+	if _, err := h.documentService.GetVersion(doc.ID, versionNumber); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	version, err := h.documentService.RestoreVersion(c.Request.Context(), doc, versionNumber, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore document version"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_version_restore", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"restored_version": versionNumber,
+		"new_version":      version.Version,
+	})
+	h.recordOnChain(doc.ID, user.ID, "document_version_restore", map[string]interface{}{
+		"restored_version": versionNumber,
+		"version":          version.Version,
+		"file_hash":        version.FileHash,
+	})
+
+	h.subscriptionService.Publish(doc, &services.SubscriptionEvent{
+		Type:    models.NotificationNewVersion,
+		ActorID: user.ID,
+		Message: fmt.Sprintf("%s restored %q to version %d", user.Username, doc.Title, versionNumber),
+	})
+
+	c.JSON(http.StatusOK, newDocumentVersionResponse(version))
+}
+
+// DiffVersions returns the metadata and content differences between two versions
+func (h *DocumentHandler) DiffVersions(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return
+	}
+
+	from, errFrom := strconv.Atoi(c.Param("version"))
+	to, errTo := strconv.Atoi(c.Param("other"))
+	if errFrom != nil || errTo != nil || from < 1 || to < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	if _, err := h.documentService.GetVersion(doc.ID, from); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if _, err := h.documentService.GetVersion(doc.ID, to); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	diff, err := h.documentService.DiffVersions(doc.ID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare document versions"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_version_diff", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"from_version": from,
+		"to_version":   to,
+	})
+
+	c.JSON(http.StatusOK, diff)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// CreateExportRequest represents an export of the listed documents. Without
+// document_ids the documents matching the query filters of the document list
+// are exported.
+type CreateExportRequest struct {
+	DocumentIDs []uint `json:"document_ids"`
+	Reason      string `json:"reason" binding:"max=500"` // Needed to export documents requiring a justification
+}
+
+// CreateExport starts building a zip archive of documents with a metadata
+// manifest. The user is notified when it is ready to download.
+func (h *DocumentHandler) CreateExport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateExportRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+
+	ids := uniqueIDs(req.DocumentIDs)
+	if len(ids) == 0 {
+		filter, err := parseDocumentFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !h.resolveFilter(c, filter) {
+			return
+		}
+
+		// One more than allowed tells that the filter matches too many
+		docs, _, err := h.documentService.GetAccessible(c.Request.Context(), user, filter, 1, h.exportService.MaxDocuments()+1)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get documents"})
+			return
+		}
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No documents to export"})
+		return
+	}
+
+	job, err := h.exportService.Start(user, ids, authTime(c), req.Reason, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		if errors.Is(err, services.ErrExportTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":         "Too many documents to export",
+				"max_documents": h.exportService.MaxDocuments(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.Itoa(int(job.ID)))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetExports returns the current user's exports
+func (h *DocumentHandler) GetExports(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	jobs, total, err := h.exportService.GetAllForUser(user.ID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get exports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  jobs,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetExport returns the status of an export
+func (h *DocumentHandler) GetExport(c *gin.Context) {
+	_, job, ok := h.loadExport(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadExport sends the archive of a completed export
+func (h *DocumentHandler) DownloadExport(c *gin.Context) {
+	user, job, ok := h.loadExport(c)
+	if !ok {
+		return
+	}
+
+	archive, err := h.exportService.ReadArchive(job)
+	if err != nil {
+		if errors.Is(err, services.ErrExportNotReady) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": job.Status})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read export"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "export_download", "export", strconv.Itoa(int(job.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+
+	c.Header("Content-Disposition", "attachment; filename=\"export-"+strconv.Itoa(int(job.ID))+".zip\"")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// loadExport resolves the current user and their export from the :id path
+// parameter, writing an error response on failure
+func (h *DocumentHandler) loadExport(c *gin.Context) (*models.User, *models.ExportJob, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return nil, nil, false
+	}
+
+	job, err := h.exportService.GetForUser(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return nil, nil, false
+	}
+
+	return user, job, true
+}
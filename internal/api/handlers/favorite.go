@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// FavoriteHandler handles the current user's document bookmarks
+type FavoriteHandler struct {
+	favoriteService *services.FavoriteService
+	documentService *services.DocumentService
+	authService     *services.AuthorizationService
+}
+
+// NewFavoriteHandler creates a new favorite handler
+func NewFavoriteHandler(favoriteService *services.FavoriteService, documentService *services.DocumentService, authService *services.AuthorizationService) *FavoriteHandler {
+	return &FavoriteHandler{
+		favoriteService: favoriteService,
+		documentService: documentService,
+		authService:     authService,
+	}
+}
+
+// FavoriteRequest represents a request bookmarking a document, optionally at
+// a zero-based position in the list
+type FavoriteRequest struct {
+	DocumentID uint `json:"document_id" binding:"required"`
+	Position   *int `json:"position"`
+}
+
+// FavoriteOrderRequest lists bookmarked documents in their new order
+type FavoriteOrderRequest struct {
+	DocumentIDs []uint `json:"document_ids" binding:"required"`
+}
+
+// FavoriteResponse represents a bookmarked document in responses
+type FavoriteResponse struct {
+	*DocumentResponse
+	Position    int       `json:"position"`
+	FavoritedAt time.Time `json:"favorited_at"`
+}
+
+// GetFavorites returns the current user's bookmarked documents in order
+func (h *FavoriteHandler) GetFavorites(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	favorites, err := h.favoriteService.GetForUser(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get favorites"})
+		return
+	}
+
+	responses := make([]*FavoriteResponse, 0, len(favorites))
+	for i := range favorites {
+		responses = append(responses, &FavoriteResponse{
+			DocumentResponse: newDocumentResponse(&favorites[i].Document),
+			Position:         favorites[i].Position,
+			FavoritedAt:      favorites[i].CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// AddFavorite bookmarks a document the current user may read
+func (h *FavoriteHandler) AddFavorite(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req FavoriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), req.DocumentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	allowed, err := h.authService.CanRead(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	favorite, err := h.favoriteService.Add(user.ID, doc.ID, req.Position)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add favorite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &FavoriteResponse{
+		DocumentResponse: newDocumentResponse(doc),
+		Position:         favorite.Position,
+		FavoritedAt:      favorite.CreatedAt,
+	})
+}
+
+// ReorderFavorites changes the order of the current user's bookmarks
+func (h *FavoriteHandler) ReorderFavorites(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req FavoriteOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := h.favoriteService.Reorder(user.ID, req.DocumentIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder favorites"})
+		return
+	}
+
+	h.GetFavorites(c)
+}
+
+// RemoveFavorite removes a document from the current user's bookmarks
+func (h *FavoriteHandler) RemoveFavorite(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	documentID, ok := parseIDParam(c, "documentId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	if err := h.favoriteService.Remove(user.ID, documentID); err != nil {
+		if errors.Is(err, services.ErrFavoriteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Favorite not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove favorite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Favorite removed successfully"})
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// FolderHandler handles folder related requests
+type FolderHandler struct {
+	folderService       *services.FolderService
+	authService         *services.AuthorizationService
+	roleService         *services.RoleService
+	auditService        *services.AuditService
+	subscriptionService *services.SubscriptionService
+}
+
+// NewFolderHandler creates a new folder handler
+func NewFolderHandler(
+	folderService *services.FolderService,
+	authService *services.AuthorizationService,
+	roleService *services.RoleService,
+	auditService *services.AuditService,
+	subscriptionService *services.SubscriptionService,
+) *FolderHandler {
+	return &FolderHandler{
+		folderService:       folderService,
+		authService:         authService,
+		roleService:         roleService,
+		auditService:        auditService,
+		subscriptionService: subscriptionService,
+	}
+}
+
+// FolderRequest represents a folder create or update request
+type FolderRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// FolderResponse represents folder data in responses
+type FolderResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  *uint     `json:"parent_id"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// newFolderResponse converts a folder model to its response representation
+func newFolderResponse(folder *models.Folder) *FolderResponse {
+	return &FolderResponse{
+		ID:        folder.ID,
+		Name:      folder.Name,
+		ParentID:  folder.ParentID,
+		CreatedBy: folder.CreatedBy,
+		CreatedAt: folder.CreatedAt,
+		UpdatedAt: folder.UpdatedAt,
+	}
+}
+
+// GetFolders returns the subfolders of parent_id, or the top-level folders
+// of the user's organization
+func (h *FolderHandler) GetFolders(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var parentID *uint
+	if value := c.Query("parent_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent_id"})
+			return
+		}
+		parent := uint(id)
+		parentID = &parent
+	}
+
+	folders, err := h.folderService.GetChildren(user.OrganizationID, parentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get folders"})
+		return
+	}
+
+	responses := make([]*FolderResponse, 0, len(folders))
+	for i := range folders {
+		responses = append(responses, newFolderResponse(&folders[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// GetFolder returns a folder
+func (h *FolderHandler) GetFolder(c *gin.Context) {
+	_, folder, ok := h.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newFolderResponse(folder))
+}
+
+// CreateFolder creates a folder, requiring write access to the parent folder
+func (h *FolderHandler) CreateFolder(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+		return
+	}
+
+	if req.ParentID != nil && !h.authorizeParent(c, user, *req.ParentID) {
+		return
+	}
+
+	folder := &models.Folder{
+		OrganizationID: user.OrganizationID,
+		Name:           name,
+		ParentID:       req.ParentID,
+		CreatedBy:      user.ID,
+	}
+
+	if err := h.folderService.Create(folder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "folder_create", "folder", strconv.Itoa(int(folder.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":      folder.Name,
+		"parent_id": folder.ParentID,
+	})
+
+	c.JSON(http.StatusCreated, newFolderResponse(folder))
+}
+
+// UpdateFolder renames or moves a folder
+func (h *FolderHandler) UpdateFolder(c *gin.Context) {
+	user, folder, ok := h.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, folder, services.ActionWrite) {
+		return
+	}
+
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+		return
+	}
+
+	moved := (req.ParentID == nil) != (folder.ParentID == nil) ||
+		(req.ParentID != nil && *req.ParentID != *folder.ParentID)
+	if moved && req.ParentID != nil && !h.authorizeParent(c, user, *req.ParentID) {
+		return
+	}
+
+	folder.Name = name
+	folder.ParentID = req.ParentID
+
+	if err := h.folderService.Update(folder); err != nil {
+		if errors.Is(err, services.ErrFolderCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "folder_update", "folder", strconv.Itoa(int(folder.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":      folder.Name,
+		"parent_id": folder.ParentID,
+	})
+
+	c.JSON(http.StatusOK, newFolderResponse(folder))
+}
+
+// DeleteFolder deletes an empty folder
+func (h *FolderHandler) DeleteFolder(c *gin.Context) {
+	user, folder, ok := h.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, folder, services.ActionDelete) {
+		return
+	}
+
+	if err := h.folderService.Delete(folder.ID); err != nil {
+		if errors.Is(err, services.ErrFolderNotEmpty) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "folder_delete", "folder", strconv.Itoa(int(folder.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name": folder.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted successfully"})
+}
+
+// GetPermissions returns the permissions set directly on a folder
+func (h *FolderHandler) GetPermissions(c *gin.Context) {
+	user, folder, ok := h.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, folder, services.ActionShare) {
+		return
+	}
+
+	permissions, err := h.authService.GetFolderPermissions(folder.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": newPermissionResponses(permissions)})
+}
+
+// SetPermission grants or updates a folder permission, inherited by everything in the folder
+func (h *FolderHandler) SetPermission(c *gin.Context) {
+	user, folder, ok := h.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, folder, services.ActionShare) {
+		return
+	}
+
+	permission, ok := bindPermission(c, user, h.roleService)
+	if !ok {
+		return
+	}
+	permission.FolderID = &folder.ID
+
+	if !savePermission(c, h.authService, permission) {
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "permission_grant", "folder", strconv.Itoa(int(folder.ID)), c.ClientIP(), c.GetHeader("User-Agent"), permissionAuditDetails(permission))
+
+	h.publishPermissionChange(user, folder)
+
+	c.JSON(http.StatusOK, newPermissionResponse(permission))
+}
+
+// RevokePermission removes a permission from a folder
+func (h *FolderHandler) RevokePermission(c *gin.Context) {
+	user, folder, ok := h.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, folder, services.ActionShare) {
+		return
+	}
+
+	permissionID, ok := parseIDParam(c, "permissionId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission ID"})
+		return
+	}
+
+	permission, err := h.authService.GetPermission(permissionID)
+	if err != nil || permission.FolderID == nil || *permission.FolderID != folder.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permission not found"})
+		return
+	}
+
+	if err := h.authService.RevokePermission(permission.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke permission"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "permission_revoke", "folder", strconv.Itoa(int(folder.ID)), c.ClientIP(), c.GetHeader("User-Agent"), permissionAuditDetails(permission))
+
+	h.publishPermissionChange(user, folder)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Permission revoked successfully"})
+}
+
+// loadFolder resolves the current user and the folder from the :id parameter,
+// writing an error response when either is unavailable. Folders of other
+// organizations aren't found.
+func (h *FolderHandler) loadFolder(c *gin.Context) (*models.User, *models.Folder, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return nil, nil, false
+	}
+
+	folder, err := h.folderService.GetByID(id)
+	if err != nil || folder.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return nil, nil, false
+	}
+
+	return user, folder, true
+}
+
+// authorizeParent checks that the parent folder exists and the user may add to it
+func (h *FolderHandler) authorizeParent(c *gin.Context, user *models.User, parentID uint) bool {
+	parent, err := h.folderService.GetByID(parentID)
+	if err != nil || parent.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent folder not found"})
+		return false
+	}
+
+	return h.authorize(c, user, parent, services.ActionWrite)
+}
+
+// authorize checks whether the user may perform the action on the folder,
+// writing an error response when the check fails or the action is denied
+func (h *FolderHandler) authorize(c *gin.Context, user *models.User, folder *models.Folder, action services.Action) bool {
+	allowed, err := h.authService.CanOnFolder(user, folder, action)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return false
+	}
+
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+
+	return true
+}
+
+// publishPermissionChange notifies subscribers of the folder and its ancestors of changed permissions
+func (h *FolderHandler) publishPermissionChange(user *models.User, folder *models.Folder) {
+	h.subscriptionService.PublishFolder(folder, &services.SubscriptionEvent{
+		Type:    models.NotificationPermissionChange,
+		ActorID: user.ID,
+		Message: fmt.Sprintf("%s changed the permissions of folder %q", user.Username, folder.Name),
+	})
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// GroupHandler handles groups of users and their memberships
+type GroupHandler struct {
+	groupService *services.GroupService
+	userService  *services.UserService
+	auditService *services.AuditService
+}
+
+// NewGroupHandler creates a new group handler
+func NewGroupHandler(groupService *services.GroupService, userService *services.UserService, auditService *services.AuditService) *GroupHandler {
+	return &GroupHandler{
+		groupService: groupService,
+		userService:  userService,
+		auditService: auditService,
+	}
+}
+
+// GroupRequest represents a group create or update request
+type GroupRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=255"`
+}
+
+// GroupMemberRequest adds a user to a group
+type GroupMemberRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// GroupMemberResponse represents a group member in responses
+type GroupMemberResponse struct {
+	UserID     uint        `json:"user_id"`
+	Username   string      `json:"username"`
+	FirstName  string      `json:"first_name"`
+	LastName   string      `json:"last_name"`
+	Department string      `json:"department"`
+	Role       models.Role `json:"role"`
+	AddedBy    uint        `json:"added_by"`
+	AddedAt    time.Time   `json:"added_at"`
+}
+
+// newGroupMemberResponse converts a membership to its response representation
+func newGroupMemberResponse(member *models.GroupMember) *GroupMemberResponse {
+	return &GroupMemberResponse{
+		UserID:     member.UserID,
+		Username:   member.User.Username,
+		FirstName:  member.User.FirstName,
+		LastName:   member.User.LastName,
+		Department: member.User.Department,
+		Role:       member.User.Role,
+		AddedBy:    member.AddedBy,
+		AddedAt:    member.CreatedAt,
+	}
+}
+
+// loadGroup loads the group named by the id parameter, writing an error
+// response when it can't or the group belongs to another organization
+func (h *GroupHandler) loadGroup(c *gin.Context, user *models.User) (*models.Group, bool) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return nil, false
+	}
+
+	group, err := h.groupService.GetGroup(id)
+	if err == nil && group.OrganizationID != user.OrganizationID {
+		err = services.ErrGroupNotFound
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrGroupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group"})
+		return nil, false
+	}
+	return group, true
+}
+
+// GetGroups lists the groups of the user's organization, so documents can be
+// shared with them
+func (h *GroupHandler) GetGroups(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groups, err := h.groupService.GetGroups(user.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": groups})
+}
+
+// GetGroup returns a group with its members
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	group, ok := h.loadGroup(c, user)
+	if !ok {
+		return
+	}
+
+	members, err := h.groupService.GetMembers(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group members"})
+		return
+	}
+
+	responses := make([]*GroupMemberResponse, 0, len(members))
+	for i := range members {
+		responses = append(responses, newGroupMemberResponse(&members[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group":   group,
+		"members": responses,
+	})
+}
+
+// CreateGroup creates a group
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	group := &models.Group{
+		OrganizationID: user.OrganizationID,
+		Name:           req.Name,
+		Description:    req.Description,
+		CreatedBy:      user.ID,
+	}
+	if err := h.groupService.Create(group); err != nil {
+		if errors.Is(err, services.ErrGroupExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "group_create", "group", strconv.Itoa(int(group.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name": group.Name,
+	})
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateGroup renames a group or changes its description
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	group, ok := h.loadGroup(c, user)
+	if !ok {
+		return
+	}
+
+	var req GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	previousName := group.Name
+	group.Name = req.Name
+	group.Description = req.Description
+	if err := h.groupService.Update(group); err != nil {
+		if errors.Is(err, services.ErrGroupExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "group_update", "group", strconv.Itoa(int(group.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"previous_name": previousName,
+		"name":          group.Name,
+	})
+
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup deletes a group; documents and folders shared with it are no
+// longer shared with its members
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	group, ok := h.loadGroup(c, user)
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.Delete(group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete group"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "group_delete", "group", strconv.Itoa(int(group.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name": group.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group deleted successfully"})
+}
+
+// AddMember adds a user to a group
+func (h *GroupHandler) AddMember(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	group, ok := h.loadGroup(c, user)
+	if !ok {
+		return
+	}
+
+	var req GroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	member, err := h.userService.GetByID(req.UserID)
+	if err != nil || member.OrganizationID != group.OrganizationID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+		return
+	}
+
+	membership, err := h.groupService.AddMember(group.ID, member.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add group member"})
+		return
+	}
+	membership.User = *member
+
+	h.auditService.LogAction(user.ID, nil, "group_member_add", "group", strconv.Itoa(int(group.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"user_id":  member.ID,
+		"username": member.Username,
+	})
+
+	c.JSON(http.StatusOK, newGroupMemberResponse(membership))
+}
+
+// RemoveMember removes a user from a group
+func (h *GroupHandler) RemoveMember(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	group, ok := h.loadGroup(c, user)
+	if !ok {
+		return
+	}
+
+	memberID, ok := parseIDParam(c, "userId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.groupService.RemoveMember(group.ID, memberID); err != nil {
+		if errors.Is(err, services.ErrNotGroupMember) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "group_member_remove", "group", strconv.Itoa(int(group.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"user_id": memberID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group member removed successfully"})
+}
+
+// GetMyGroups lists the groups the current user belongs to
+func (h *GroupHandler) GetMyGroups(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groups, err := h.groupService.GetUserGroups(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": groups})
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// importStateCookie binds an import authorization to the browser that
+// started it. It holds a hash of the state and is only sent to the callback.
+const (
+	importStateCookie     = "import_state"
+	importStateCookiePath = "/api/v1/imports/oauth/callback"
+)
+
+// CreateImportRequest represents an import of folders of a connected
+// provider. A folder without a category gets the import's category; the
+// other fields apply to every imported file as in a bulk upload.
+type CreateImportRequest struct {
+	Provider          string                `json:"provider" binding:"required"`
+	Folders           []ImportFolderRequest `json:"folders" binding:"required,min=1,dive"`
+	FolderID          *uint                 `json:"folder_id"`
+	Category          string                `json:"category"`
+	Tags              string                `json:"tags"`
+	AccessLevel       models.AccessLevel    `json:"access_level"` // Omit to classify each file
+	AccessLevelReason string                `json:"access_level_reason"`
+}
+
+// ImportFolderRequest selects a folder of the provider for import
+type ImportFolderRequest struct {
+	ID       string `json:"id" binding:"required"`
+	Category string `json:"category"`
+}
+
+// GetImportConnections returns the configured import providers and the
+// ones the current user has connected
+func (h *DocumentHandler) GetImportConnections(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	connections, err := h.importService.GetConnections(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get connections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"providers":   h.importService.Providers(),
+		"connections": connections,
+	})
+}
+
+// ConnectImportProvider starts connecting a provider and returns the URL
+// where the user grants read access. The provider redirects back to the
+// authorization callback.
+func (h *DocumentHandler) ConnectImportProvider(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	url, binding, err := h.importService.Authorize(user.ID, c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownImportProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import provider not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start authorization"})
+		return
+	}
+
+	// Lax, so that the provider's redirect back carries it
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(importStateCookie, binding, int(h.importService.AuthorizationTTL().Seconds()), importStateCookiePath, "", true, true)
+
+	c.JSON(http.StatusOK, gin.H{"authorization_url": url})
+}
+
+// CompleteImportAuthorization handles the provider's redirect after the user
+// granted or denied access. It is public; the state identifies the user, and
+// the state cookie proves the redirect reached the browser that started.
+func (h *DocumentHandler) CompleteImportAuthorization(c *gin.Context) {
+	binding, _ := c.Cookie(importStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(importStateCookie, "", -1, importStateCookiePath, "", true, true)
+
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization was not granted", "reason": reason})
+		return
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "State and code are required"})
+		return
+	}
+
+	connection, err := h.importService.CompleteAuthorization(c.Request.Context(), state, binding, code)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidImportState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to complete authorization"})
+		return
+	}
+
+	h.auditService.LogAction(connection.UserID, nil, "import_connected", "import_connection", strconv.Itoa(int(connection.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"provider": connection.Provider,
+		"account":  connection.Account,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Connected successfully",
+		"connection": connection,
+	})
+}
+
+// DisconnectImportProvider deletes the current user's connection to a provider
+func (h *DocumentHandler) DisconnectImportProvider(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	provider := c.Param("provider")
+	if err := h.importService.Disconnect(user.ID, provider); err != nil {
+		if errors.Is(err, services.ErrImportNotConnected) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "import_disconnected", "import_connection", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"provider": provider,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Disconnected successfully"})
+}
+
+// GetImportFolders lists the folders of a connected provider below the
+// parent query parameter, or at the top level without it
+func (h *DocumentHandler) GetImportFolders(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	folders, err := h.importService.Folders(c.Request.Context(), user.ID, c.Param("provider"), c.Query("parent"))
+	if err != nil {
+		if !writeImportError(c, err) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to list folders"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"folders": folders})
+}
+
+// CreateImport starts pulling the files of the selected folders into
+// documents. The returned import reports progress and the outcome of each
+// file; the user is notified when it finishes.
+func (h *DocumentHandler) CreateImport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.AccessLevel != 0 && !validAccessLevel(req.AccessLevel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access level"})
+		return
+	}
+
+	if req.FolderID != nil && !h.authorizeFolder(c, user, *req.FolderID) {
+		return
+	}
+
+	folders := make([]models.ImportFolder, 0, len(req.Folders))
+	for _, folder := range req.Folders {
+		folders = append(folders, models.ImportFolder{ID: folder.ID, Category: folder.Category})
+	}
+
+	job := &models.ImportJob{
+		Provider:          req.Provider,
+		FolderID:          req.FolderID,
+		Category:          req.Category,
+		Tags:              req.Tags,
+		AccessLevel:       req.AccessLevel,
+		AccessLevelReason: req.AccessLevelReason,
+	}
+
+	// Each file is held to the same limit as a single upload
+	maxFileSize, _ := maxUploadSize(c)
+
+	if err := h.importService.Start(job, folders, user, maxFileSize, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		if !writeImportError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import"})
+		}
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.Itoa(int(job.ID)))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetImports returns the current user's imports
+func (h *DocumentHandler) GetImports(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	jobs, total, err := h.importService.GetAllForUser(user.ID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get imports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  jobs,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetImport returns the progress of an import with the outcome of each file
+// so far, as JSON or, with format=csv, as a report
+func (h *DocumentHandler) GetImport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	job, err := h.importService.GetForUser(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, job)
+		return
+	}
+
+	rows := make([][]string, 0, len(job.Items))
+	for _, item := range job.Items {
+		documentID := ""
+		if item.DocumentID != nil {
+			documentID = strconv.Itoa(int(*item.DocumentID))
+		}
+		rows = append(rows, []string{
+			item.Path,
+			string(item.Status),
+			documentID,
+			item.FileHash,
+			item.Error,
+		})
+	}
+	writeCSV(c, "import-"+strconv.Itoa(int(job.ID)), []string{"path", "status", "document_id", "file_hash", "error"}, rows)
+}
+
+// writeImportError writes the response for import errors caused by the
+// request and returns false for other errors
+func writeImportError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrUnknownImportProvider):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import provider not found"})
+	case errors.Is(err, services.ErrImportNotConnected):
+		c.JSON(http.StatusConflict, gin.H{"error": "Import provider is not connected"})
+	case errors.Is(err, services.ErrImportFolderUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/ingest"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// IngestHandler handles inbound emails and review of files collected from
+// drop locations
+type IngestHandler struct {
+	ingestService     *services.IngestService
+	auditService      *services.AuditService
+	emailSecret       string
+	requireSenderAuth bool
+	maxEmailSize      int64
+}
+
+// NewIngestHandler creates a new ingest handler. The inbound email webhook
+// authenticates with emailSecret as its basic auth password; with
+// requireSenderAuth, only emails whose sender passed SPF or DKIM are filed.
+func NewIngestHandler(ingestService *services.IngestService, auditService *services.AuditService, emailSecret string, requireSenderAuth bool, maxEmailSize int64) *IngestHandler {
+	return &IngestHandler{
+		ingestService:     ingestService,
+		auditService:      auditService,
+		emailSecret:       emailSecret,
+		requireSenderAuth: requireSenderAuth,
+		maxEmailSize:      maxEmailSize,
+	}
+}
+
+// ReceiveEmail files the attachments of an inbound email posted by SendGrid
+// Inbound Parse, either parsed or as the raw message in the email field.
+// Rejected emails are acknowledged so they aren't delivered again.
+func (h *IngestHandler) ReceiveEmail(c *gin.Context) {
+	_, secret, _ := c.Request.BasicAuth()
+	if h.emailSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.emailSecret)) != 1 {
+		c.Header("WWW-Authenticate", `Basic realm="inbound-email"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook credentials"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxEmailSize)
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Email too large", "max_size": tooLarge.Limit})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	message, envelopeFrom, err := inboundMessage(c.Request.MultipartForm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email: " + err.Error()})
+		return
+	}
+
+	if h.requireSenderAuth && !senderAuthenticated(message.From, envelopeFrom, c.PostForm("SPF"), c.PostForm("dkim")) {
+		h.rejectEmail(c, message, "sender failed SPF and DKIM checks")
+		return
+	}
+
+	user, files, err := h.ingestService.IngestEmail(message, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		if errors.Is(err, services.ErrEmailRecipient) || errors.Is(err, services.ErrUnknownSender) {
+			h.rejectEmail(c, message, err.Error())
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file email"})
+		return
+	}
+
+	filed := 0
+	for _, file := range files {
+		if file.Succeeded {
+			filed++
+		}
+	}
+	h.auditService.LogAction(user.ID, nil, "email_ingested", "email", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"from":        message.From,
+		"subject":     message.Subject,
+		"attachments": len(files),
+		"filed":       filed,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "accepted",
+		"files":  files,
+	})
+}
+
+// rejectEmail records an email that isn't filed and acknowledges it
+func (h *IngestHandler) rejectEmail(c *gin.Context, message *ingest.Message, reason string) {
+	h.auditService.LogAction(0, nil, "email_ingest_rejected", "email", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"from":       message.From,
+		"recipients": message.Recipients,
+		"subject":    message.Subject,
+		"reason":     reason,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "rejected",
+		"reason": reason,
+	})
+}
+
+// inboundMessage reads the email of an Inbound Parse post and the sender
+// of its envelope. The envelope's recipients replace the headers' so blind
+// copies to the ingest address are accepted.
+func inboundMessage(form *multipart.Form) (*ingest.Message, string, error) {
+	formValue := func(key string) string {
+		if values := form.Value[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	var message *ingest.Message
+	if raw := formValue("email"); raw != "" {
+		parsed, err := ingest.ParseMessage(strings.NewReader(raw))
+		if err != nil {
+			return nil, "", err
+		}
+		message = parsed
+	} else {
+		from, err := mail.ParseAddress(formValue("from"))
+		if err != nil {
+			return nil, "", errors.New("invalid sender")
+		}
+		message = &ingest.Message{From: from.Address, Subject: formValue("subject")}
+		if to, err := mail.ParseAddressList(formValue("to")); err == nil {
+			for _, address := range to {
+				message.Recipients = append(message.Recipients, address.Address)
+			}
+		}
+
+		keys := make([]string, 0, len(form.File))
+		for key := range form.File {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, header := range form.File[key] {
+				content, err := readFormFile(header)
+				if err != nil {
+					return nil, "", errors.New("failed to read attachment")
+				}
+				message.Attachments = append(message.Attachments, ingest.Attachment{Name: header.Filename, Content: content})
+			}
+		}
+	}
+
+	var envelope struct {
+		To   []string `json:"to"`
+		From string   `json:"from"`
+	}
+	if err := json.Unmarshal([]byte(formValue("envelope")), &envelope); err == nil && len(envelope.To) > 0 {
+		message.Recipients = envelope.To
+	}
+	return message, envelope.From, nil
+}
+
+// senderAuthenticated reports whether the sender's domain signed the email
+// (DKIM) or sent it from an authorized server (SPF) with a matching
+// envelope sender, as reported by Inbound Parse
+func senderAuthenticated(from, envelopeFrom, spf, dkim string) bool {
+	domain := strings.ToLower(from[strings.LastIndex(from, "@")+1:])
+	if domain == "" {
+		return false
+	}
+
+	// dkim lists each signature as "@domain : result"
+	for _, signature := range strings.Split(strings.Trim(dkim, "{}"), ",") {
+		if strings.EqualFold(strings.Join(strings.Fields(signature), " "), "@"+domain+" : pass") {
+			return true
+		}
+	}
+
+	envelopeDomain := strings.ToLower(envelopeFrom[strings.LastIndex(envelopeFrom, "@")+1:])
+	return strings.EqualFold(strings.TrimSpace(spf), "pass") && envelopeDomain == domain
+}
+
+// GetIngestedFiles lists collected files, optionally filtered by source and
+// by status (succeeded or failed)
+func (h *IngestHandler) GetIngestedFiles(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	filter := &services.IngestedFileFilter{Source: c.Query("source")}
+	switch c.Query("status") {
+	case "":
+	case "succeeded":
+		succeeded := true
+		filter.Succeeded = &succeeded
+	case "failed":
+		succeeded := false
+		filter.Succeeded = &succeeded
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	files, total, err := h.ingestService.GetFiles(filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get ingested files"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  files,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// IntegrityHandler reports and runs checks of stored files against their
+// recorded hashes
+type IntegrityHandler struct {
+	integrityService *services.IntegrityService
+	auditService     *services.AuditService
+}
+
+// NewIntegrityHandler creates a new integrity handler
+func NewIntegrityHandler(integrityService *services.IntegrityService, auditService *services.AuditService) *IntegrityHandler {
+	return &IntegrityHandler{
+		integrityService: integrityService,
+		auditService:     auditService,
+	}
+}
+
+// GetReport returns the integrity report of the organization's documents
+// with a page of the flagged ones
+func (h *IntegrityHandler) GetReport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	report, err := h.integrityService.GetReport(c.Request.Context(), user.OrganizationID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get integrity report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// VerifyDocuments checks the stored files of the organization's documents
+// right away
+func (h *IntegrityHandler) VerifyDocuments(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	run, err := h.integrityService.VerifyDocuments(c.Request.Context(), user.OrganizationID)
+	if err != nil {
+		if errors.Is(err, services.ErrIntegrityCheckRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An integrity check is already running"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check documents"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "integrity_check", "document", "", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"checked":  run.Checked,
+		"verified": run.Verified,
+		"flagged":  run.Flagged,
+	})
+
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// JustificationHandler reports the reasons given for downloading sensitive
+// documents
+type JustificationHandler struct {
+	auditService *services.AuditService
+}
+
+// NewJustificationHandler creates a new justification handler
+func NewJustificationHandler(auditService *services.AuditService) *JustificationHandler {
+	return &JustificationHandler{
+		auditService: auditService,
+	}
+}
+
+// GetDownloadJustifications lists the organization's downloads and exports
+// made with a justification between from and to, by default the last 30 days
+func (h *JustificationHandler) GetDownloadJustifications(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := parseDateParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, expected RFC 3339 or YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if value := c.Query("from"); value != "" {
+		parsed, err := parseDateParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, expected RFC 3339 or YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	justifications, total, err := h.auditService.GetDownloadJustifications(c.Request.Context(), user.OrganizationID, from, to, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get download justifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  justifications,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// KeyHandler handles encryption key management requests
+type KeyHandler struct {
+	keyRotationService *services.KeyRotationService
+}
+
+// NewKeyHandler creates a new key handler
+func NewKeyHandler(keyRotationService *services.KeyRotationService) *KeyHandler {
+	return &KeyHandler{
+		keyRotationService: keyRotationService,
+	}
+}
+
+// RotateKey rotates the master key and starts re-wrapping document data keys
+func (h *KeyHandler) RotateKey(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	job, err := h.keyRotationService.StartRotation(user.ID, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		if errors.Is(err, services.ErrKeyRotationRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate key"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetRotationJobs returns key rotation jobs
+func (h *KeyHandler) GetRotationJobs(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	jobs, total, err := h.keyRotationService.GetJobs(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get key rotation jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  jobs,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetRotationJob returns a single key rotation job
+func (h *KeyHandler) GetRotationJob(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := h.keyRotationService.GetJob(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key rotation job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// MetadataHandler handles custom metadata field definition requests
+type MetadataHandler struct {
+	metadataService *services.MetadataService
+	auditService    *services.AuditService
+}
+
+// NewMetadataHandler creates a new metadata handler
+func NewMetadataHandler(metadataService *services.MetadataService, auditService *services.AuditService) *MetadataHandler {
+	return &MetadataHandler{
+		metadataService: metadataService,
+		auditService:    auditService,
+	}
+}
+
+// MetadataFieldRequest represents a metadata field create or update request
+type MetadataFieldRequest struct {
+	Key         string                   `json:"key"`
+	Label       string                   `json:"label" binding:"required"`
+	Type        models.MetadataFieldType `json:"type" binding:"required"`
+	Options     []string                 `json:"options"`
+	Department  string                   `json:"department"`
+	Description string                   `json:"description"`
+}
+
+// DocumentMetadataRequest represents a request setting metadata values on a
+// document; null values remove fields
+type DocumentMetadataRequest struct {
+	Values map[string]interface{} `json:"values" binding:"required"`
+}
+
+// GetFields returns the metadata field definitions, optionally for a department
+func (h *MetadataHandler) GetFields(c *gin.Context) {
+	fields, err := h.metadataService.GetFields(c.Query("department"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metadata fields"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": fields})
+}
+
+// CreateField defines a metadata field
+func (h *MetadataHandler) CreateField(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req MetadataFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	field := &models.MetadataField{
+		Key:       strings.TrimSpace(req.Key),
+		CreatedBy: user.ID,
+	}
+	applyMetadataFieldRequest(field, &req)
+
+	if err := h.metadataService.CreateField(field); err != nil {
+		writeMetadataError(c, err, "Failed to create metadata field")
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "metadata_field_create", "metadata_field", strconv.Itoa(int(field.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"key":  field.Key,
+		"type": field.Type,
+	})
+
+	c.JSON(http.StatusCreated, field)
+}
+
+// UpdateField updates a metadata field definition; its key cannot change
+func (h *MetadataHandler) UpdateField(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	field, ok := h.loadField(c)
+	if !ok {
+		return
+	}
+
+	var req MetadataFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	previousType := field.Type
+	applyMetadataFieldRequest(field, &req)
+
+	if err := h.metadataService.UpdateField(field, previousType); err != nil {
+		writeMetadataError(c, err, "Failed to update metadata field")
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "metadata_field_update", "metadata_field", strconv.Itoa(int(field.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"key":  field.Key,
+		"type": field.Type,
+	})
+
+	c.JSON(http.StatusOK, field)
+}
+
+// DeleteField removes a metadata field and its values on all documents
+func (h *MetadataHandler) DeleteField(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	field, ok := h.loadField(c)
+	if !ok {
+		return
+	}
+
+	if err := h.metadataService.DeleteField(field); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete metadata field"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "metadata_field_delete", "metadata_field", strconv.Itoa(int(field.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"key": field.Key,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Metadata field deleted successfully"})
+}
+
+// loadField resolves the metadata field from the :id parameter, writing an
+// error response when it is unavailable
+func (h *MetadataHandler) loadField(c *gin.Context) (*models.MetadataField, bool) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metadata field ID"})
+		return nil, false
+	}
+
+	field, err := h.metadataService.GetField(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Metadata field not found"})
+		return nil, false
+	}
+
+	return field, true
+}
+
+// applyMetadataFieldRequest copies a request onto a field definition
+func applyMetadataFieldRequest(field *models.MetadataField, req *MetadataFieldRequest) {
+	field.Label = strings.TrimSpace(req.Label)
+	field.Type = req.Type
+	field.Department = strings.TrimSpace(req.Department)
+	field.Description = req.Description
+	field.Options = ""
+	if len(req.Options) > 0 {
+		field.Options = services.NormalizeOptions(req.Options)
+	}
+}
+
+// writeMetadataError writes the response for a failed metadata operation
+func writeMetadataError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMetadata):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMetadataFieldExists), errors.Is(err, services.ErrMetadataFieldInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetMetadata returns the custom metadata values of a document
+func (h *DocumentHandler) GetMetadata(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return
+	}
+
+	metadata, err := h.metadataService.GetDocumentMetadata(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get document metadata"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": metadata})
+}
+
+// SetMetadata sets custom metadata values on a document
+func (h *DocumentHandler) SetMetadata(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionWrite) {
+		return
+	}
+
+	var req DocumentMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := h.metadataService.SetDocumentMetadata(doc.ID, req.Values, user.ID); err != nil {
+		writeMetadataError(c, err, "Failed to update document metadata")
+		return
+	}
+
+	metadata, err := h.metadataService.GetDocumentMetadata(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get document metadata"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_metadata_update", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"values": req.Values,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": metadata})
+}
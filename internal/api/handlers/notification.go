@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// NotificationHandler handles notification requests of the current user
+type NotificationHandler struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notificationService *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// GetNotifications returns the current user's notifications, optionally only
+// unread ones (unread=true) of a type
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	h.listNotifications(c, &services.NotificationFilter{
+		Type:       models.NotificationType(c.Query("type")),
+		UnreadOnly: c.Query("unread") == "true",
+	})
+}
+
+// GetMentions returns the current user's unread mentions, or all mentions with unread=false
+func (h *NotificationHandler) GetMentions(c *gin.Context) {
+	h.listNotifications(c, &services.NotificationFilter{
+		Type:       models.NotificationMention,
+		UnreadOnly: c.DefaultQuery("unread", "true") == "true",
+	})
+}
+
+// listNotifications writes a page of the current user's notifications
+func (h *NotificationHandler) listNotifications(c *gin.Context, filter *services.NotificationFilter) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	notifications, total, err := h.notificationService.GetForUser(user.ID, filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  notifications,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// MarkRead marks a notification as read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	notification, err := h.notificationService.MarkRead(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllRead marks all unread notifications, optionally of a type, as read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	updated, err := h.notificationService.MarkAllRead(user.ID, &services.NotificationFilter{
+		Type: models.NotificationType(c.Query("type")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/auth"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// OAuthHandler handles OAuth clients and their client credentials grant
+type OAuthHandler struct {
+	oauthClientService *services.OAuthClientService
+	apiUsageService    *services.APIUsageService
+	roleService        *services.RoleService
+	tokenService       *auth.TokenService
+	auditService       *services.AuditService
+	tokenTTL           time.Duration
+}
+
+// NewOAuthHandler creates a new OAuth handler issuing tokens valid for
+// tokenTTL
+func NewOAuthHandler(oauthClientService *services.OAuthClientService, apiUsageService *services.APIUsageService, roleService *services.RoleService, tokenService *auth.TokenService, auditService *services.AuditService, tokenTTL time.Duration) *OAuthHandler {
+	return &OAuthHandler{
+		oauthClientService: oauthClientService,
+		apiUsageService:    apiUsageService,
+		roleService:        roleService,
+		tokenService:       tokenService,
+		auditService:       auditService,
+		tokenTTL:           tokenTTL,
+	}
+}
+
+// Token issues an access token with the client credentials grant of RFC
+// 6749. Clients authenticate with HTTP Basic or client_id and
+// client_secret form fields, and may ask for fewer scopes with scope.
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	if c.PostForm("grant_type") != "client_credentials" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, clientSecret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if clientID == "" || clientSecret == "" {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+
+	client, scopes, err := h.oauthClientService.Authenticate(clientID, clientSecret, strings.Fields(c.PostForm("scope")))
+	if err != nil {
+		var scopeErr *services.ScopeError
+		switch {
+		case errors.Is(err, services.ErrInvalidClient):
+			h.auditService.LogAction(0, nil, "oauth_token_failed", "oauth_client", "0", c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+				"client_id": clientID,
+			})
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		case errors.As(err, &scopeErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": scopeErr.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		}
+		return
+	}
+
+	token, err := h.tokenService.GenerateServiceToken(&client.User, client.ClientID, scopes, h.tokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	h.auditService.LogAction(client.UserID, nil, "oauth_token_issued", "oauth_client", strconv.Itoa(int(client.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"client_id": client.ClientID,
+		"scope":     strings.Join(scopes, " "),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(h.tokenTTL.Seconds()),
+		"scope":        strings.Join(scopes, " "),
+	})
+}
+
+// CreateOAuthClientRequest registers an OAuth client. Its service account
+// gets Role and Department, and its tokens at most Scopes.
+type CreateOAuthClientRequest struct {
+	Name       string      `json:"name" binding:"required,max=100"`
+	Role       models.Role `json:"role" binding:"required"`
+	Department string      `json:"department" binding:"max=100"`
+	Scopes     []string    `json:"scopes" binding:"required,min=1"`
+}
+
+// CreateOAuthClientResponse returns a new OAuth client with its secret,
+// shown only once
+type CreateOAuthClientResponse struct {
+	*models.OAuthClient
+	ClientSecret string `json:"client_secret"`
+}
+
+// CreateClient registers an OAuth client
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if !checkRole(c, h.roleService, req.Role) {
+		return
+	}
+
+	client, secret, err := h.oauthClientService.Create(admin.OrganizationID, req.Name, req.Role, req.Department, req.Scopes, admin.ID)
+	if err != nil {
+		var scopeErr *services.ScopeError
+		if errors.As(err, &scopeErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": scopeErr.Error(), "areas": services.ScopeAreas})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create OAuth client"})
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "oauth_client_created", "oauth_client", strconv.Itoa(int(client.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":       client.Name,
+		"client_id":  client.ClientID,
+		"role":       req.Role,
+		"department": req.Department,
+		"scopes":     client.Scopes,
+	})
+
+	c.JSON(http.StatusCreated, CreateOAuthClientResponse{OAuthClient: client, ClientSecret: secret})
+}
+
+// GetClients lists the OAuth clients of the admin's organization
+func (h *OAuthHandler) GetClients(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	clients, err := h.oauthClientService.GetClients(admin.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get OAuth clients"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": clients})
+}
+
+// RevokeClient stops an OAuth client from calling the API, tokens already
+// issued included
+func (h *OAuthHandler) RevokeClient(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth client ID"})
+		return
+	}
+
+	client, err := h.oauthClientService.Revoke(admin.OrganizationID, id)
+	if err != nil {
+		if errors.Is(err, services.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "OAuth client not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke OAuth client"})
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "oauth_client_revoked", "oauth_client", strconv.Itoa(int(client.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":      client.Name,
+		"client_id": client.ClientID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "OAuth client revoked"})
+}
+
+// GetClientUsage returns the daily usage of an OAuth client over the last
+// ?days=, 30 by default
+func (h *OAuthHandler) GetClientUsage(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth client ID"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > services.MaxUsageDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+
+	client, err := h.oauthClientService.GetClient(admin.OrganizationID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OAuth client not found"})
+		return
+	}
+
+	report, err := h.apiUsageService.GetReport(h.apiUsageService.ForOAuthClient(client), days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API usage"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// SetClientQuota sets the daily quotas of an OAuth client
+func (h *OAuthHandler) SetClientQuota(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth client ID"})
+		return
+	}
+
+	var req APIQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	client, err := h.oauthClientService.SetQuota(admin.OrganizationID, id, req.DailyRequestQuota, req.DailyBandwidthQuotaMB)
+	if err != nil {
+		if errors.Is(err, services.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "OAuth client not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set OAuth client quota"})
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "oauth_client_quota_set", "oauth_client", strconv.Itoa(int(client.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"client_id":                client.ClientID,
+		"daily_request_quota":      req.DailyRequestQuota,
+		"daily_bandwidth_quota_mb": req.DailyBandwidthQuotaMB,
+	})
+
+	c.JSON(http.StatusOK, client)
+}
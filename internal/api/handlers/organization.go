@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// OrganizationHandler handles management of the organizations hosted by the
+// deployment. Its routes are limited to administrators of the default
+// organization.
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+	userService         *services.UserService
+	auditService        *services.AuditService
+}
+
+// NewOrganizationHandler creates a new organization handler
+func NewOrganizationHandler(organizationService *services.OrganizationService, userService *services.UserService, auditService *services.AuditService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+		userService:         userService,
+		auditService:        auditService,
+	}
+}
+
+// CreateOrganizationRequest represents an organization create request
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required"`
+}
+
+// RenameOrganizationRequest represents an organization rename request
+type RenameOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// SetUserOrganizationRequest moves a user to an organization
+type SetUserOrganizationRequest struct {
+	OrganizationID uint `json:"organization_id" binding:"required"`
+}
+
+// writeOrganizationError writes the response of a failed organization change
+func writeOrganizationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrInvalidOrganizationSlug):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrganizationExists), errors.Is(err, services.ErrLastOrganizationAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetOrganizations lists all organizations
+func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	organizations, err := h.organizationService.GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": organizations})
+}
+
+// CreateOrganization adds an organization. Users join it by registering with
+// its slug or by being moved to it.
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	organization, err := h.organizationService.Create(req.Name, req.Slug)
+	if err != nil {
+		writeOrganizationError(c, err, "Failed to create organization")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "organization_create", "organization", strconv.Itoa(int(organization.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name": organization.Name,
+		"slug": organization.Slug,
+	})
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+// RenameOrganization changes the name of an organization
+func (h *OrganizationHandler) RenameOrganization(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	var req RenameOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	organization, err := h.organizationService.GetByID(id)
+	if err != nil {
+		writeOrganizationError(c, err, "Failed to get organization")
+		return
+	}
+
+	previousName := organization.Name
+	if err := h.organizationService.Rename(organization, req.Name); err != nil {
+		writeOrganizationError(c, err, "Failed to rename organization")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "organization_rename", "organization", strconv.Itoa(int(organization.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"previous_name": previousName,
+		"name":          organization.Name,
+	})
+
+	c.JSON(http.StatusOK, organization)
+}
+
+// SetUserOrganization moves a user of any organization to another one
+func (h *OrganizationHandler) SetUserOrganization(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req SetUserOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	target, err := h.userService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	previousOrganization := target.OrganizationID
+	if err := h.organizationService.SetUserOrganization(target, req.OrganizationID); err != nil {
+		writeOrganizationError(c, err, "Failed to set user organization")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "user_organization_change", "user", strconv.Itoa(int(target.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"username":              target.Username,
+		"previous_organization": previousOrganization,
+		"organization_id":       target.OrganizationID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User organization updated successfully"})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// OutboxHandler handles monitoring of the outbox audit entries and blockchain
+// transactions are delivered through. The outbox spans every organization,
+// so only administrators of the default organization reach it.
+type OutboxHandler struct {
+	dispatcher   *services.OutboxDispatcher
+	auditService *services.AuditService
+}
+
+// NewOutboxHandler creates a new outbox handler
+func NewOutboxHandler(dispatcher *services.OutboxDispatcher, auditService *services.AuditService) *OutboxHandler {
+	return &OutboxHandler{
+		dispatcher:   dispatcher,
+		auditService: auditService,
+	}
+}
+
+// GetOutbox counts the pending and failed events and lists the failed ones
+func (h *OutboxHandler) GetOutbox(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	status, err := h.dispatcher.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get outbox status"})
+		return
+	}
+
+	events, total, err := h.dispatcher.GetFailed(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get failed outbox events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pending": status.Pending,
+		"failed": PaginatedResponse{
+			Data:  events,
+			Total: total,
+			Page:  page,
+			Limit: limit,
+		},
+	})
+}
+
+// RetryEvent schedules a failed outbox event for delivery again
+func (h *OutboxHandler) RetryEvent(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	if err := h.dispatcher.Retry(id); err != nil {
+		if errors.Is(err, services.ErrOutboxEventNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed outbox event not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry outbox event"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "outbox_retry", "outbox_event", strconv.Itoa(int(id)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Outbox event scheduled for retry"})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// PasswordResetHandler handles forgotten passwords
+type PasswordResetHandler struct {
+	passwordResetService *services.PasswordResetService
+	auditService         *services.AuditService
+}
+
+// NewPasswordResetHandler creates a new password reset handler
+func NewPasswordResetHandler(passwordResetService *services.PasswordResetService, auditService *services.AuditService) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		passwordResetService: passwordResetService,
+		auditService:         auditService,
+	}
+}
+
+// ForgotPasswordRequest asks for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email,max=100"`
+}
+
+// ResetPasswordRequest sets a new password with an emailed token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required,max=200"`
+	Password string `json:"password" binding:"required"` // Checked against the password policy
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the address belongs to an account.
+func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	clientIP := c.ClientIP()
+	user, err := h.passwordResetService.RequestReset(req.Email, clientIP)
+
+	userID, details := uint(0), map[string]interface{}{"email": req.Email}
+	if user != nil {
+		userID = user.ID
+	}
+	switch {
+	case err == nil && user == nil:
+		details["reason"] = "unknown_email"
+	case errors.Is(err, services.ErrPasswordResetNotAllowed):
+		details["reason"] = "not_allowed"
+	case errors.Is(err, services.ErrPasswordResetRateLimited):
+		details["reason"] = "rate_limited"
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset"})
+		return
+	}
+	h.auditService.LogAction(userID, nil, "password_reset_requested", "user", strconv.Itoa(int(userID)), clientIP, c.GetHeader("User-Agent"), details)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an account, a reset link has been emailed"})
+}
+
+// ResetPassword sets a new password with an emailed token and signs the
+// user out everywhere
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	user, err := h.passwordResetService.Reset(req.Token, req.Password)
+	if err != nil {
+		if writePasswordPolicyError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			h.auditService.LogAction(0, nil, "password_reset_failed", "user", "0", clientIP, userAgent, map[string]interface{}{
+				"reason": "invalid_token",
+			})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset link is invalid or expired"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "password_reset", "user", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
+		"username": user.Username,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
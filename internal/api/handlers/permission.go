@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// PermissionRequest represents a permission grant for exactly one user, role,
+// department or group
+type PermissionRequest struct {
+	UserID      *uint        `json:"user_id"`
+	Role        *models.Role `json:"role"`
+	Department  *string      `json:"department"`
+	GroupID     *uint        `json:"group_id"`
+	CanRead     bool         `json:"can_read"`
+	CanWrite    bool         `json:"can_write"`
+	CanDelete   bool         `json:"can_delete"`
+	CanShare    bool         `json:"can_share"`
+	CanDownload *bool        `json:"can_download"` // Readers may download and print unless false
+	CanPrint    *bool        `json:"can_print"`
+}
+
+// PermissionResponse represents a permission in responses
+type PermissionResponse struct {
+	ID          uint         `json:"id"`
+	DocumentID  *uint        `json:"document_id,omitempty"`
+	FolderID    *uint        `json:"folder_id,omitempty"`
+	UserID      *uint        `json:"user_id,omitempty"`
+	Role        *models.Role `json:"role,omitempty"`
+	Department  *string      `json:"department,omitempty"`
+	GroupID     *uint        `json:"group_id,omitempty"`
+	CanRead     bool         `json:"can_read"`
+	CanWrite    bool         `json:"can_write"`
+	CanDelete   bool         `json:"can_delete"`
+	CanShare    bool         `json:"can_share"`
+	CanDownload bool         `json:"can_download"`
+	CanPrint    bool         `json:"can_print"`
+	GrantedBy   uint         `json:"granted_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// newPermissionResponse converts a permission model to its response representation
+func newPermissionResponse(permission *models.Permission) *PermissionResponse {
+	return &PermissionResponse{
+		ID:          permission.ID,
+		DocumentID:  permission.DocumentID,
+		FolderID:    permission.FolderID,
+		UserID:      permission.UserID,
+		Role:        permission.Role,
+		Department:  permission.Department,
+		GroupID:     permission.GroupID,
+		CanRead:     permission.CanRead,
+		CanWrite:    permission.CanWrite,
+		CanDelete:   permission.CanDelete,
+		CanShare:    permission.CanShare,
+		CanDownload: permission.CanDownload == nil || *permission.CanDownload,
+		CanPrint:    permission.CanPrint == nil || *permission.CanPrint,
+		GrantedBy:   permission.GrantedBy,
+		CreatedAt:   permission.CreatedAt,
+		UpdatedAt:   permission.UpdatedAt,
+	}
+}
+
+// newPermissionResponses converts permission models to their response representations
+func newPermissionResponses(permissions []models.Permission) []*PermissionResponse {
+	responses := make([]*PermissionResponse, 0, len(permissions))
+	for i := range permissions {
+		responses = append(responses, newPermissionResponse(&permissions[i]))
+	}
+	return responses
+}
+
+// bindPermission parses a permission request into a permission granted by the user,
+// writing an error response when the request is invalid
+func bindPermission(c *gin.Context, user *models.User, roleService *services.RoleService) (*models.Permission, bool) {
+	var req PermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return nil, false
+	}
+
+	if req.Role != nil && !checkRole(c, roleService, *req.Role) {
+		return nil, false
+	}
+	if req.Department != nil && *req.Department == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Department cannot be empty"})
+		return nil, false
+	}
+
+	return &models.Permission{
+		UserID:      req.UserID,
+		Role:        req.Role,
+		Department:  req.Department,
+		GroupID:     req.GroupID,
+		CanRead:     req.CanRead,
+		CanWrite:    req.CanWrite,
+		CanDelete:   req.CanDelete,
+		CanShare:    req.CanShare,
+		CanDownload: req.CanDownload,
+		CanPrint:    req.CanPrint,
+		GrantedBy:   user.ID,
+	}, true
+}
+
+// savePermission stores a permission, writing an error response on failure
+func savePermission(c *gin.Context, authService *services.AuthorizationService, permission *models.Permission) bool {
+	if err := authService.SetPermission(c.Request.Context(), permission); err != nil {
+		if errors.Is(err, services.ErrInvalidPrincipal) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		if errors.Is(err, services.ErrGroupNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Group not found"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save permission"})
+		return false
+	}
+	return true
+}
+
+// permissionAuditDetails describes a permission in audit log details
+func permissionAuditDetails(permission *models.Permission) map[string]interface{} {
+	return map[string]interface{}{
+		"permission_id": permission.ID,
+		"user_id":       permission.UserID,
+		"role":          permission.Role,
+		"department":    permission.Department,
+		"group_id":      permission.GroupID,
+		"can_read":      permission.CanRead,
+		"can_write":     permission.CanWrite,
+		"can_delete":    permission.CanDelete,
+		"can_share":     permission.CanShare,
+		"can_download":  permission.CanDownload == nil || *permission.CanDownload,
+		"can_print":     permission.CanPrint == nil || *permission.CanPrint,
+	}
+}
+
+// GetPermissions returns the permissions set directly on a document
+func (h *DocumentHandler) GetPermissions(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionShare) {
+		return
+	}
+
+	permissions, err := h.authService.GetDocumentPermissions(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": newPermissionResponses(permissions)})
+}
+
+// SetPermission grants or updates a document permission, overriding permissions
+// inherited from the document's folders for the same principal
+func (h *DocumentHandler) SetPermission(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionShare) {
+		return
+	}
+
+	permission, ok := bindPermission(c, user, h.roleService)
+	if !ok {
+		return
+	}
+	permission.DocumentID = &doc.ID
+
+	if !savePermission(c, h.authService, permission) {
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "permission_grant", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), permissionAuditDetails(permission))
+
+	h.publishPermissionChange(user, doc)
+
+	c.JSON(http.StatusOK, newPermissionResponse(permission))
+}
+
+// RevokePermission removes a permission from a document
+func (h *DocumentHandler) RevokePermission(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionShare) {
+		return
+	}
+
+	permissionID, ok := parseIDParam(c, "permissionId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission ID"})
+		return
+	}
+
+	permission, err := h.authService.GetPermission(permissionID)
+	if err != nil || permission.DocumentID == nil || *permission.DocumentID != doc.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permission not found"})
+		return
+	}
+
+	if err := h.authService.RevokePermission(permission.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke permission"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "permission_revoke", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), permissionAuditDetails(permission))
+
+	h.publishPermissionChange(user, doc)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Permission revoked successfully"})
+}
+
+// publishPermissionChange notifies the document's subscribers of changed permissions
+func (h *DocumentHandler) publishPermissionChange(user *models.User, doc *models.Document) {
+	h.subscriptionService.Publish(doc, &services.SubscriptionEvent{
+		Type:    models.NotificationPermissionChange,
+		ActorID: user.ID,
+		Message: fmt.Sprintf("%s changed the permissions of %q", user.Username, doc.Title),
+	})
+}
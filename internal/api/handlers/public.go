@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/filetype"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// publicReader stands in for the reader in watermarks of public downloads
+var publicReader = &models.User{Username: "Public portal"}
+
+// PublicHandler serves the public documents of each organization to
+// visitors who aren't signed in. Only public documents are listed or served.
+type PublicHandler struct {
+	documentService     *services.DocumentService
+	organizationService *services.OrganizationService
+	watermarkService    *services.WatermarkService
+	auditService        *services.AuditService
+}
+
+// NewPublicHandler creates a new public portal handler
+func NewPublicHandler(documentService *services.DocumentService, organizationService *services.OrganizationService, watermarkService *services.WatermarkService, auditService *services.AuditService) *PublicHandler {
+	return &PublicHandler{
+		documentService:     documentService,
+		organizationService: organizationService,
+		watermarkService:    watermarkService,
+		auditService:        auditService,
+	}
+}
+
+// PublicDocumentResponse represents a public document. It leaves out what
+// only matters inside the organization, such as the creator and folder.
+type PublicDocumentResponse struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	FileName    string    `json:"file_name"`
+	FileSize    int64     `json:"file_size"`
+	MimeType    string    `json:"mime_type"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// newPublicDocumentResponse converts a document to its public response
+func newPublicDocumentResponse(doc *models.Document) *PublicDocumentResponse {
+	return &PublicDocumentResponse{
+		ID:          doc.ID,
+		Title:       doc.Title,
+		Description: doc.Description,
+		Category:    doc.Category,
+		FileName:    doc.FileName,
+		FileSize:    doc.FileSize,
+		MimeType:    doc.MimeType,
+		Version:     doc.Version,
+		CreatedAt:   doc.CreatedAt,
+		UpdatedAt:   doc.UpdatedAt,
+	}
+}
+
+// loadOrganization resolves the organization query parameter, a slug that
+// defaults to the default organization, writing an error response on failure
+func (h *PublicHandler) loadOrganization(c *gin.Context) (*models.Organization, bool) {
+	organization, err := h.organizationService.GetBySlugOrDefault(c.Query("organization"))
+	if err != nil {
+		if errors.Is(err, services.ErrOrganizationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organization"})
+		}
+		return nil, false
+	}
+	return organization, true
+}
+
+// loadDocument resolves the organization and the public document of the
+// request, writing an error response on failure. Documents that aren't
+// public are reported as missing.
+func (h *PublicHandler) loadDocument(c *gin.Context) (*models.Organization, *models.Document, bool) {
+	organization, ok := h.loadOrganization(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return nil, nil, false
+	}
+
+	doc, err := h.documentService.GetPublicByID(organization.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, nil, false
+	}
+	return organization, doc, true
+}
+
+// GetDocuments lists the public documents of an organization
+func (h *PublicHandler) GetDocuments(c *gin.Context) {
+	organization, ok := h.loadOrganization(c)
+	if !ok {
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	docs, total, err := h.documentService.GetPublic(organization.ID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get documents"})
+		return
+	}
+
+	responses := make([]*PublicDocumentResponse, 0, len(docs))
+	for i := range docs {
+		responses = append(responses, newPublicDocumentResponse(&docs[i]))
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  responses,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetDocument returns the metadata of a public document
+func (h *PublicHandler) GetDocument(c *gin.Context) {
+	organization, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	h.auditService.LogAnonymousAction(organization.ID, &doc.ID, "public_document_view", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+
+	c.JSON(http.StatusOK, newPublicDocumentResponse(doc))
+}
+
+// DownloadDocument serves the content of a public document
+func (h *PublicHandler) DownloadDocument(c *gin.Context) {
+	organization, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !checkScanStatus(c, doc) {
+		return
+	}
+
+	content, err := h.documentService.ReadContent(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read document"})
+		return
+	}
+
+	var details map[string]interface{}
+	if h.watermarkService.Applies(doc) && filetype.Detect(content) == "application/pdf" {
+		if content, err = h.watermarkService.Stamp(content, doc, publicReader); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watermark document"})
+			return
+		}
+		details = map[string]interface{}{"watermarked": true}
+	}
+
+	h.auditService.LogAnonymousAction(organization.ID, &doc.ID, "public_document_download", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+
+	mimeType := doc.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	setContentDisposition(c, "attachment", doc.FileName)
+	c.Data(http.StatusOK, mimeType, content)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// RecoveryLoginRequest confirms a password with a recovery code instead of
+// a security key. Challenge is the one returned by the login.
+type RecoveryLoginRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required,max=20"`
+}
+
+// LoginWithRecoveryCode finishes a login awaiting a security key with a
+// recovery code, using the code up
+func (h *AuthHandler) LoginWithRecoveryCode(c *gin.Context) {
+	var req RecoveryLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	// The challenge is given up either way, so each guess needs the password again
+	userID, err := h.webauthnService.CancelSecondFactor(req.Challenge)
+	if err != nil {
+		if errors.Is(err, services.ErrWebAuthnChallenge) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, sign in again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	user, err := h.userService.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if !h.checkAccount(c, user, user.Username) {
+		return
+	}
+
+	if err := h.recoveryCodeService.Redeem(user.ID, req.Code); err != nil {
+		if !errors.Is(err, services.ErrInvalidRecoveryCode) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if err := h.userService.IncrementLoginAttempts(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		h.auditService.LogAction(user.ID, nil, "login_failed", "auth", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
+			"username": user.Username,
+			"reason":   "invalid_recovery_code",
+		})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid recovery code"})
+		return
+	}
+
+	remaining, err := h.recoveryCodeService.Remaining(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	h.auditService.LogAction(user.ID, nil, "mfa_recovery_code_used", "user", strconv.Itoa(int(user.ID)), clientIP, userAgent, map[string]interface{}{
+		"remaining": remaining,
+	})
+
+	h.completeLogin(c, user, user.Username, "password+recovery_code")
+}
+
+// GetRecoveryCodes returns how many unused recovery codes the current user has
+func (h *AuthHandler) GetRecoveryCodes(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	remaining, err := h.recoveryCodeService.Remaining(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"remaining": remaining})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes with
+// new ones, returned only this once. It needs a fresh authentication.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !requireFreshCredentialAuth(c, h.stepUpPolicy) {
+		return
+	}
+
+	enrolled, err := h.webauthnService.HasCredentials(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if !enrolled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Register a security key first"})
+		return
+	}
+
+	codes, err := h.recoveryCodeService.Generate(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	h.auditService.LogAction(user.ID, nil, "mfa_recovery_codes_generated", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
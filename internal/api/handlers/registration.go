@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// RegisterRequest represents a self-service registration
+type RegisterRequest struct {
+	Username     string `json:"username" binding:"required,max=50"`
+	Email        string `json:"email" binding:"required,email,max=100"`
+	Password     string `json:"password" binding:"required"` // Checked against the password policy
+	FirstName    string `json:"first_name" binding:"max=50"`
+	LastName     string `json:"last_name" binding:"max=50"`
+	Department   string `json:"department" binding:"max=100"`
+	Organization string `json:"organization" binding:"max=50"` // Slug; the default organization when empty
+}
+
+// ApproveRegistrationRequest optionally changes the role or department a
+// user registered with when approving them
+type ApproveRegistrationRequest struct {
+	Role       models.Role `json:"role"`
+	Department *string     `json:"department"`
+}
+
+// Register creates an account that can sign in once an administrator
+// approves it
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	user := &models.User{
+		Username:   strings.TrimSpace(req.Username),
+		Email:      strings.TrimSpace(req.Email),
+		FirstName:  req.FirstName,
+		LastName:   req.LastName,
+		Department: req.Department,
+	}
+	if err := h.passwordPolicyService.Validate(req.Password, user); err != nil {
+		writePasswordPolicyError(c, err)
+		return
+	}
+
+	hashedPassword, err := h.passwordService.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	user.Password = hashedPassword
+	if err := h.registrationService.Register(user, strings.TrimSpace(req.Organization)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrOrganizationNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown organization"})
+		case errors.Is(err, services.ErrUserExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmailDomainNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register"})
+		}
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "user_registered", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"username":        user.Username,
+		"email":           user.Email,
+		"department":      user.Department,
+		"organization_id": user.OrganizationID,
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Registration received; an administrator must approve the account before you can sign in",
+		"user":    newUserResponse(user),
+	})
+}
+
+// GetRegistrations returns the registrations to the administrator's
+// organization awaiting approval
+func (h *AuthHandler) GetRegistrations(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	users, total, err := h.registrationService.GetPending(admin.OrganizationID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get registrations"})
+		return
+	}
+
+	responses := make([]*UserResponse, 0, len(users))
+	for i := range users {
+		responses = append(responses, newUserResponse(&users[i]))
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  responses,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// ApproveRegistration activates a registered account
+func (h *AuthHandler) ApproveRegistration(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req ApproveRegistrationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+	if req.Role != "" && !checkRole(c, h.roleService, req.Role) {
+		return
+	}
+
+	user, err := h.registrationService.Approve(admin.OrganizationID, id, req.Role, req.Department)
+	if err != nil {
+		writeRegistrationError(c, err, "Failed to approve registration")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "registration_approved", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"username":   user.Username,
+		"role":       user.Role,
+		"department": user.Department,
+	})
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// RejectRegistration declines a registration, leaving the account inactive
+func (h *AuthHandler) RejectRegistration(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.registrationService.Reject(admin.OrganizationID, id)
+	if err != nil {
+		writeRegistrationError(c, err, "Failed to reject registration")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "registration_rejected", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"username": user.Username,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Registration rejected"})
+}
+
+// writeRegistrationError writes the response for a failed approval or rejection
+func writeRegistrationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrNotPendingRegistration):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRegistrationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Registration not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
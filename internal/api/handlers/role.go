@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// RoleHandler handles management of roles and their capabilities. Roles are
+// shared by every organization, so only administrators of the default
+// organization define them; others only give their users a role.
+type RoleHandler struct {
+	roleService  *services.RoleService
+	userService  *services.UserService
+	auditService *services.AuditService
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(roleService *services.RoleService, userService *services.UserService, auditService *services.AuditService) *RoleHandler {
+	return &RoleHandler{
+		roleService:  roleService,
+		userService:  userService,
+		auditService: auditService,
+	}
+}
+
+// CreateRoleRequest represents a role create request
+type CreateRoleRequest struct {
+	Name         models.Role `json:"name" binding:"required"`
+	Description  string      `json:"description" binding:"max=255"`
+	Capabilities []string    `json:"capabilities"`
+}
+
+// UpdateRoleRequest represents a role update request; omitted fields are
+// left unchanged
+type UpdateRoleRequest struct {
+	Description  *string  `json:"description" binding:"omitempty,max=255"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// SetUserRoleRequest gives a user a role
+type SetUserRoleRequest struct {
+	Role models.Role `json:"role" binding:"required"`
+}
+
+// checkRole writes a 400 response and returns false when users can't be
+// given the role
+func checkRole(c *gin.Context, roleService *services.RoleService, role models.Role) bool {
+	exists, err := roleService.Exists(role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role"})
+		return false
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return false
+	}
+	return true
+}
+
+// writeRoleError writes the response of a failed role change
+func writeRoleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+	case errors.Is(err, services.ErrUnknownCapability):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "capabilities": models.Capabilities})
+	case errors.Is(err, services.ErrInvalidRoleName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOwnRole), errors.Is(err, services.ErrAdminRoleRequired),
+		errors.Is(err, services.ErrCapabilityNotHeld):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRoleExists), errors.Is(err, services.ErrRoleInUse),
+		errors.Is(err, services.ErrBuiltInRole), errors.Is(err, services.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetRoles returns all roles with their capabilities, and every capability
+// there is
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	roles, err := h.roleService.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         roles,
+		"capabilities": models.Capabilities,
+	})
+}
+
+// CreateRole creates a role
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	role, err := h.roleService.Create(req.Name, req.Description, req.Capabilities)
+	if err != nil {
+		writeRoleError(c, err, "Failed to create role")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "role_create", "role", strconv.Itoa(int(role.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":         role.Name,
+		"capabilities": role.Capabilities,
+	})
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole changes the description or capabilities of a role
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	role, err := h.roleService.Update(id, req.Description, req.Capabilities)
+	if err != nil {
+		writeRoleError(c, err, "Failed to update role")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "role_update", "role", strconv.Itoa(int(role.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":         role.Name,
+		"capabilities": role.Capabilities,
+	})
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a role no user has
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	role, err := h.roleService.Delete(id)
+	if err != nil {
+		writeRoleError(c, err, "Failed to delete role")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "role_delete", "role", strconv.Itoa(int(role.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name": role.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// SetUserRole gives a user a role
+func (h *RoleHandler) SetUserRole(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	user, ok := organizationUser(c, h.userService, admin)
+	if !ok {
+		return
+	}
+
+	var req SetUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	previous := user.Role
+	err := h.roleService.SetUserRole(admin, user, req.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrRoleNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+			return
+		}
+		writeRoleError(c, err, "Failed to set user role")
+		return
+	}
+
+	h.auditService.LogAction(admin.ID, nil, "user_role_change", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"username":      user.Username,
+		"previous_role": previous,
+		"role":          user.Role,
+	})
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
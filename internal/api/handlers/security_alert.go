@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// SecurityAlertHandler handles review of security alerts raised by anomaly
+// detection
+type SecurityAlertHandler struct {
+	detectionService *services.SecurityDetectionService
+	auditService     *services.AuditService
+}
+
+// NewSecurityAlertHandler creates a new security alert handler
+func NewSecurityAlertHandler(detectionService *services.SecurityDetectionService, auditService *services.AuditService) *SecurityAlertHandler {
+	return &SecurityAlertHandler{
+		detectionService: detectionService,
+		auditService:     auditService,
+	}
+}
+
+// GetAlerts lists the security alerts of the user's organization, optionally
+// filtered by status, severity, rule and user_id
+func (h *SecurityAlertHandler) GetAlerts(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	filter := &services.SecurityAlertFilter{
+		Rule:     c.Query("rule"),
+		Severity: models.AlertSeverity(c.Query("severity")),
+		Status:   models.AlertStatus(c.Query("status")),
+	}
+	if value := c.Query("user_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		userID := uint(id)
+		filter.UserID = &userID
+	}
+
+	alerts, total, err := h.detectionService.GetAlerts(user.OrganizationID, filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get security alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:  alerts,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetAlert returns a security alert of the user's organization
+func (h *SecurityAlertHandler) GetAlert(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	alert, err := h.detectionService.GetAlert(user.OrganizationID, id)
+	if err != nil {
+		if errors.Is(err, services.ErrSecurityAlertNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Security alert not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get security alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// AcknowledgeAlert marks a security alert as being looked into
+func (h *SecurityAlertHandler) AcknowledgeAlert(c *gin.Context) {
+	h.reviewAlert(c, models.AlertStatusAcknowledged)
+}
+
+// ResolveAlert closes a security alert; further activity raises a new one
+func (h *SecurityAlertHandler) ResolveAlert(c *gin.Context) {
+	h.reviewAlert(c, models.AlertStatusResolved)
+}
+
+func (h *SecurityAlertHandler) reviewAlert(c *gin.Context, status models.AlertStatus) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	alert, err := h.detectionService.Review(user.OrganizationID, id, status, user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrSecurityAlertNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Security alert not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security alert"})
+		return
+	}
+
+	action := "security_alert_acknowledge"
+	if status == models.AlertStatusResolved {
+		action = "security_alert_resolve"
+	}
+	h.auditService.LogAction(user.ID, nil, action, "security_alert", strconv.Itoa(int(alert.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"rule":     alert.Rule,
+		"severity": alert.Severity,
+	})
+
+	c.JSON(http.StatusOK, alert)
+}
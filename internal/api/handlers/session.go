@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// SessionResponse describes an active session without its token
+type SessionResponse struct {
+	ID         uint       `json:"id"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// newSessionResponses returns the public fields of sessions
+func newSessionResponses(sessions []models.RefreshToken) []SessionResponse {
+	responses := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, SessionResponse{
+			ID:         session.ID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+		})
+	}
+	return responses
+}
+
+// GetSessions lists the current user's active sessions
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.listSessions(c, user.ID)
+}
+
+// RevokeSession signs out one of the current user's sessions
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	h.revokeSession(c, user, user.ID, id)
+}
+
+// GetUserSessions lists the active sessions of a user
+func (h *AuthHandler) GetUserSessions(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	user, ok := organizationUser(c, h.userService, admin)
+	if !ok {
+		return
+	}
+
+	h.listSessions(c, user.ID)
+}
+
+// RevokeUserSession signs out one of the sessions of a user
+func (h *AuthHandler) RevokeUserSession(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	user, ok := organizationUser(c, h.userService, admin)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "sessionId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	h.revokeSession(c, admin, user.ID, id)
+}
+
+// listSessions responds with the active sessions of a user
+func (h *AuthHandler) listSessions(c *gin.Context, userID uint) {
+	sessions, err := h.sessionService.GetSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": newSessionResponses(sessions)})
+}
+
+// revokeSession signs out a session of a user on behalf of actor, the user
+// or an administrator
+func (h *AuthHandler) revokeSession(c *gin.Context, actor *models.User, userID, id uint) {
+	session, err := h.sessionService.Revoke(userID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	h.auditService.LogAction(actor.ID, nil, "session_revoked", "session", strconv.Itoa(int(session.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"user_id":    userID,
+		"ip_address": session.IPAddress,
+		"user_agent": session.UserAgent,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// LogoutAll signs the current user out of every session, this one included
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.logoutEverywhere(c, user, user)
+}
+
+// LogoutUserEverywhere signs a user out of every session, such as after a
+// lost device
+func (h *AuthHandler) LogoutUserEverywhere(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	user, ok := organizationUser(c, h.userService, admin)
+	if !ok {
+		return
+	}
+
+	h.logoutEverywhere(c, admin, user)
+}
+
+// logoutEverywhere revokes every session and access token of a user on
+// behalf of actor, the user or an administrator
+func (h *AuthHandler) logoutEverywhere(c *gin.Context, actor, user *models.User) {
+	revoked, err := h.sessionService.RevokeAll(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out sessions"})
+		return
+	}
+
+	h.auditService.LogAction(actor.ID, nil, "logout_all", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"username":         user.Username,
+		"sessions_revoked": revoked,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out of all sessions", "sessions_revoked": revoked})
+}
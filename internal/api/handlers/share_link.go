@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/filetype"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// shareLinkReader stands in for the reader in watermarks of downloads
+// through share links
+var shareLinkReader = &models.User{Username: "Share link"}
+
+// ShareLinkHandler manages links sharing documents with people without an
+// account and serves downloads through them
+type ShareLinkHandler struct {
+	shareLinkService    *services.ShareLinkService
+	documentService     *services.DocumentService
+	watermarkService    *services.WatermarkService
+	authService         *services.AuthorizationService
+	stepUpPolicy        *services.StepUpPolicy
+	justificationPolicy *services.JustificationPolicy
+	auditService        *services.AuditService
+}
+
+// NewShareLinkHandler creates a new share link handler
+func NewShareLinkHandler(shareLinkService *services.ShareLinkService, documentService *services.DocumentService, watermarkService *services.WatermarkService, authService *services.AuthorizationService, stepUpPolicy *services.StepUpPolicy, justificationPolicy *services.JustificationPolicy, auditService *services.AuditService) *ShareLinkHandler {
+	return &ShareLinkHandler{
+		shareLinkService:    shareLinkService,
+		documentService:     documentService,
+		watermarkService:    watermarkService,
+		authService:         authService,
+		stepUpPolicy:        stepUpPolicy,
+		justificationPolicy: justificationPolicy,
+		auditService:        auditService,
+	}
+}
+
+// CreateShareLinkRequest represents a share link create request. Links
+// without expires_in_hours stay valid for the longest time allowed.
+type CreateShareLinkRequest struct {
+	Password       string `json:"password" binding:"max=72"` // Optional; required at download when set
+	ExpiresInHours int    `json:"expires_in_hours" binding:"min=0"`
+}
+
+// ShareLinkResponse represents a share link in responses
+type ShareLinkResponse struct {
+	*models.ShareLink
+	PasswordProtected bool `json:"password_protected"`
+}
+
+// CreateShareLinkResponse returns a new share link, whose token is shown
+// only once
+type CreateShareLinkResponse struct {
+	*ShareLinkResponse
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// ShareLinkDownloadRequest carries the password of a protected share link
+type ShareLinkDownloadRequest struct {
+	Password string `json:"password"`
+}
+
+// newShareLinkResponse converts a share link to its response
+func newShareLinkResponse(link *models.ShareLink) *ShareLinkResponse {
+	return &ShareLinkResponse{
+		ShareLink:         link,
+		PasswordProtected: link.PasswordHash != "",
+	}
+}
+
+// linkable reports whether the document may be downloaded through a share
+// link, which can neither prove a fresh authentication nor state a reason
+func (h *ShareLinkHandler) linkable(doc *models.Document) bool {
+	return !h.stepUpPolicy.Required(doc) && !h.justificationPolicy.Required(doc)
+}
+
+// loadSharableDocument returns the current user and the document of the
+// request when the user may share it, writing an error response otherwise
+func (h *ShareLinkHandler) loadSharableDocument(c *gin.Context) (*models.User, *models.Document, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return nil, nil, false
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), id)
+	if err != nil || doc.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, nil, false
+	}
+
+	allowed, err := h.authService.CanShare(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, nil, false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return nil, nil, false
+	}
+
+	return user, doc, true
+}
+
+// CreateShareLink issues a link downloading a document without an account,
+// optionally protected by a password
+func (h *ShareLinkHandler) CreateShareLink(c *gin.Context) {
+	user, doc, ok := h.loadSharableDocument(c)
+	if !ok {
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Whoever opens the link gets the original file
+	downloadable, err := h.authService.CanDownload(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !downloadable {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	if !h.linkable(doc) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Documents requiring step-up authentication or a download reason can't be shared by link"})
+		return
+	}
+
+	link, token, err := h.shareLinkService.Create(doc.ID, user.ID, req.Password, time.Duration(req.ExpiresInHours)*time.Hour)
+	if err != nil {
+		if errors.Is(err, services.ErrShareLinkPasswordTooShort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "share_link_create", "share_link", strconv.Itoa(int(link.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"password_protected": link.PasswordHash != "",
+		"expires_at":         link.ExpiresAt,
+	})
+
+	c.JSON(http.StatusCreated, &CreateShareLinkResponse{
+		ShareLinkResponse: newShareLinkResponse(link),
+		Token:             token,
+		URL:               "/api/v1/share/" + token,
+	})
+}
+
+// GetShareLinks lists the active share links of a document
+func (h *ShareLinkHandler) GetShareLinks(c *gin.Context) {
+	_, doc, ok := h.loadSharableDocument(c)
+	if !ok {
+		return
+	}
+
+	links, err := h.shareLinkService.GetForDocument(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get share links"})
+		return
+	}
+
+	responses := make([]*ShareLinkResponse, 0, len(links))
+	for i := range links {
+		responses = append(responses, newShareLinkResponse(&links[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// RevokeShareLink stops a share link of a document from working
+func (h *ShareLinkHandler) RevokeShareLink(c *gin.Context) {
+	user, doc, ok := h.loadSharableDocument(c)
+	if !ok {
+		return
+	}
+
+	linkID, ok := parseIDParam(c, "linkId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share link ID"})
+		return
+	}
+
+	link, err := h.shareLinkService.Revoke(doc.ID, linkID)
+	if err != nil {
+		if errors.Is(err, services.ErrShareLinkNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "share_link_revoke", "share_link", strconv.Itoa(int(link.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked successfully"})
+}
+
+// DownloadSharedDocument serves the document of a share link to anyone
+// holding its token. Protected links take their password in the JSON body
+// of a POST request.
+func (h *ShareLinkHandler) DownloadSharedDocument(c *gin.Context) {
+	var req ShareLinkDownloadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+
+	link, doc, err := h.shareLinkService.Resolve(c.Param("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrShareLinkNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get share link"})
+		return
+	}
+
+	if err := h.shareLinkService.CheckPassword(link, req.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidShareLinkPassword) {
+			h.auditService.LogAnonymousAction(doc.OrganizationID, &doc.ID, "share_link_password_failed", "share_link", strconv.Itoa(int(link.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "password_required": true})
+		return
+	}
+
+	// The document may have been reclassified since the link was created
+	if !h.linkable(doc) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Document is no longer available through share links"})
+		return
+	}
+
+	// So may the creator's access, which the link passes on
+	creator, err := h.shareLinkService.Creator(link)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	allowed, err := h.authService.CanShareByLink(creator, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Document is no longer available through this share link"})
+		return
+	}
+
+	if !checkScanStatus(c, doc) {
+		return
+	}
+
+	content, err := h.documentService.ReadContent(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read document"})
+		return
+	}
+
+	details := map[string]interface{}{"share_link_id": link.ID}
+	if h.watermarkService.Applies(doc) && filetype.Detect(content) == "application/pdf" {
+		if content, err = h.watermarkService.Stamp(content, doc, shareLinkReader); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watermark document"})
+			return
+		}
+		details["watermarked"] = true
+	}
+
+	if err := h.shareLinkService.RecordDownload(link); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record download"})
+		return
+	}
+	h.auditService.LogAnonymousAction(doc.OrganizationID, &doc.ID, "share_link_download", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+
+	mimeType := doc.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	setContentDisposition(c, "attachment", doc.FileName)
+	c.Data(http.StatusOK, mimeType, content)
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/signature"
+)
+
+// SignDocumentRequest represents a signature over a document version's file.
+// With issued_certificate the user signs with the certificate the internal CA
+// issued them; without any field the server signs with its own certificate.
+type SignDocumentRequest struct {
+	Certificate       string `json:"certificate"`        // PEM certificate, optionally followed by its intermediates
+	Signature         string `json:"signature"`          // Base64 signature over the file
+	IssuedCertificate bool   `json:"issued_certificate"` // Sign with the issued certificate
+}
+
+// SignatureHandler signs document versions with X.509 certificates and
+// verifies the signatures
+type SignatureHandler struct {
+	signatureService *services.SignatureService
+	documentService  *services.DocumentService
+	authService      *services.AuthorizationService
+	stepUpPolicy     *services.StepUpPolicy
+	auditService     *services.AuditService
+}
+
+// NewSignatureHandler creates a new signature handler
+func NewSignatureHandler(signatureService *services.SignatureService, documentService *services.DocumentService, authService *services.AuthorizationService, stepUpPolicy *services.StepUpPolicy, auditService *services.AuditService) *SignatureHandler {
+	return &SignatureHandler{
+		signatureService: signatureService,
+		documentService:  documentService,
+		authService:      authService,
+		stepUpPolicy:     stepUpPolicy,
+		auditService:     auditService,
+	}
+}
+
+// loadDocument returns the current user and the document of the request
+// when the user may read it, writing an error response otherwise
+func (h *SignatureHandler) loadDocument(c *gin.Context) (*models.User, *models.Document, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return nil, nil, false
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), id)
+	if err != nil || doc.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, nil, false
+	}
+
+	allowed, err := h.authService.CanRead(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, nil, false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return nil, nil, false
+	}
+
+	return user, doc, true
+}
+
+// SignVersion stores the user's signature over a document version's file,
+// or signs it with the server's certificate when none is given
+func (h *SignatureHandler) SignVersion(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	versionNumber, err := strconv.Atoi(c.Param("version"))
+	if err != nil || versionNumber < 1 || versionNumber > doc.Version {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	var req SignDocumentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+
+	var record *models.DocumentSignature
+	switch {
+	case req.IssuedCertificate:
+		if !requireFreshAuth(c, h.stepUpPolicy) {
+			return
+		}
+		record, err = h.signatureService.IssuedSignVersion(doc, versionNumber, user.ID)
+	case req.Certificate == "" && req.Signature == "":
+		record, err = h.signatureService.ServerSignVersion(doc, versionNumber, user.ID)
+	default:
+		certificates, parseErr := signature.ParseCertificates([]byte(req.Certificate))
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate"})
+			return
+		}
+		sig, decodeErr := base64.StdEncoding.DecodeString(req.Signature)
+		if decodeErr != nil || len(sig) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature encoding"})
+			return
+		}
+		record, err = h.signatureService.SignVersion(doc, versionNumber, certificates, sig, user.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrServerSigningDisabled):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A certificate and signature are required"})
+		case errors.Is(err, services.ErrNoSigningCertificate):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You have no valid signing certificate"})
+		case errors.Is(err, services.ErrVersionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		case errors.Is(err, services.ErrSignatureExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Version is already signed with this certificate"})
+		case errors.Is(err, signature.ErrInvalidSignature):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Signature doesn't match the version's file"})
+		case errors.Is(err, signature.ErrUntrustedCertificate):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Certificate is not trusted", "details": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign document"})
+		}
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_sign", "signature", strconv.Itoa(int(record.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"version":       record.Version,
+		"file_hash":     record.FileHash,
+		"subject":       record.Subject,
+		"fingerprint":   record.Fingerprint,
+		"server_signed": record.ServerSigned,
+	})
+
+	c.JSON(http.StatusCreated, record)
+}
+
+// GetSignatures lists the signatures of a document's versions
+func (h *SignatureHandler) GetSignatures(c *gin.Context) {
+	_, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	signatures, err := h.signatureService.GetForDocument(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get signatures"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": signatures})
+}
+
+// VerifySignature checks a signature against the version's stored file and
+// the signer's certificate chain
+func (h *SignatureHandler) VerifySignature(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "signatureId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature ID"})
+		return
+	}
+
+	verification, err := h.signatureService.Verify(doc, id)
+	if err != nil {
+		if errors.Is(err, services.ErrSignatureNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Signature not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify signature"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_signature_verify", "signature", strconv.Itoa(int(id)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"valid":   verification.Valid,
+		"trusted": verification.Trusted,
+	})
+
+	c.JSON(http.StatusOK, verification)
+}
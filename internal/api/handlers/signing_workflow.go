@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/signature"
+)
+
+// SignerRequest represents a signer of a signing workflow
+type SignerRequest struct {
+	UserID uint                 `json:"user_id" binding:"required"`
+	Method models.SigningMethod `json:"method" binding:"required,oneof=click certificate"`
+}
+
+// CreateSigningWorkflowRequest represents a request to collect signatures on
+// a document. Signers sign in the given order.
+type CreateSigningWorkflowRequest struct {
+	Signers []SignerRequest `json:"signers" binding:"required,min=1,max=20,dive"`
+	Message string          `json:"message" binding:"max=2000"`
+}
+
+// SignWorkflowRequest represents a signer's signature. Click signers confirm
+// with their name; certificate signers send their certificate and signature,
+// or sign with the certificate the internal CA issued them.
+type SignWorkflowRequest struct {
+	SignedName        string `json:"signed_name"`
+	Certificate       string `json:"certificate"`        // PEM certificate, optionally followed by its intermediates
+	Signature         string `json:"signature"`          // Base64 signature over the file
+	IssuedCertificate bool   `json:"issued_certificate"` // Sign with the issued certificate instead
+}
+
+// DeclineSigningRequest represents a signer's refusal to sign
+type DeclineSigningRequest struct {
+	Reason string `json:"reason" binding:"required,max=2000"`
+}
+
+// SigningWorkflowHandler collects signatures of several users on documents
+type SigningWorkflowHandler struct {
+	signingWorkflowService *services.SigningWorkflowService
+	documentService        *services.DocumentService
+	authService            *services.AuthorizationService
+	stepUpPolicy           *services.StepUpPolicy
+	auditService           *services.AuditService
+}
+
+// NewSigningWorkflowHandler creates a new signing workflow handler
+func NewSigningWorkflowHandler(signingWorkflowService *services.SigningWorkflowService, documentService *services.DocumentService, authService *services.AuthorizationService, stepUpPolicy *services.StepUpPolicy, auditService *services.AuditService) *SigningWorkflowHandler {
+	return &SigningWorkflowHandler{
+		signingWorkflowService: signingWorkflowService,
+		documentService:        documentService,
+		authService:            authService,
+		stepUpPolicy:           stepUpPolicy,
+		auditService:           auditService,
+	}
+}
+
+// loadDocument returns the current user and the document of the request
+// when the user may read it, writing an error response otherwise
+func (h *SigningWorkflowHandler) loadDocument(c *gin.Context) (*models.User, *models.Document, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return nil, nil, false
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), id)
+	if err != nil || doc.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, nil, false
+	}
+
+	allowed, err := h.authService.CanRead(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, nil, false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return nil, nil, false
+	}
+
+	return user, doc, true
+}
+
+// CreateWorkflow starts collecting signatures on the document's current
+// version
+func (h *SigningWorkflowHandler) CreateWorkflow(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if doc.LockedAt != nil {
+		c.JSON(http.StatusLocked, gin.H{"error": "Document is locked", "reason": doc.LockReason})
+		return
+	}
+	allowed, err := h.authService.Can(user, doc, services.ActionWrite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var req CreateSigningWorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	signers := make([]services.SignerRequest, 0, len(req.Signers))
+	for _, signer := range req.Signers {
+		signers = append(signers, services.SignerRequest{UserID: signer.UserID, Method: signer.Method})
+	}
+
+	workflow, err := h.signingWorkflowService.Create(c.Request.Context(), doc, user, signers, req.Message)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSigners):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signers", "details": err.Error()})
+		case errors.Is(err, services.ErrSigningWorkflowInProgress):
+			c.JSON(http.StatusConflict, gin.H{"error": "Document already has a signing workflow in progress"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create signing workflow"})
+		}
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "signing_workflow_create", "signing_workflow", strconv.Itoa(int(workflow.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"version":   workflow.Version,
+		"file_hash": workflow.FileHash,
+		"signers":   len(workflow.Signers),
+	})
+
+	c.JSON(http.StatusCreated, workflow)
+}
+
+// GetWorkflows lists the document's signing workflows
+func (h *SigningWorkflowHandler) GetWorkflows(c *gin.Context) {
+	_, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	workflows, err := h.signingWorkflowService.GetForDocument(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get signing workflows"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workflows})
+}
+
+// GetWorkflow returns a signing workflow of the document
+func (h *SigningWorkflowHandler) GetWorkflow(c *gin.Context) {
+	_, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "workflowId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signing workflow ID"})
+		return
+	}
+
+	workflow, err := h.signingWorkflowService.Get(doc.ID, id)
+	if err != nil {
+		if errors.Is(err, services.ErrSigningWorkflowNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Signing workflow not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get signing workflow"})
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// GetSigningRequests lists the signing workflows waiting for the current
+// user's signature
+func (h *SigningWorkflowHandler) GetSigningRequests(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	workflows, err := h.signingWorkflowService.GetPendingForUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get signing requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workflows})
+}
+
+// Sign signs a document for the current user when it is their turn. Click
+// signatures need a fresh authentication, which binds them to the user.
+func (h *SigningWorkflowHandler) Sign(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "workflowId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signing workflow ID"})
+		return
+	}
+
+	var req SignWorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var workflow *models.SigningWorkflow
+	var err error
+	switch {
+	case req.IssuedCertificate:
+		if !requireFreshAuth(c, h.stepUpPolicy) {
+			return
+		}
+		workflow, err = h.signingWorkflowService.SignIssued(c.Request.Context(), doc, id, user, c.ClientIP(), c.GetHeader("User-Agent"))
+	case req.Certificate == "" && req.Signature == "":
+		if req.SignedName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A signed name, or a certificate and signature, is required"})
+			return
+		}
+		if !requireFreshAuth(c, h.stepUpPolicy) {
+			return
+		}
+		workflow, err = h.signingWorkflowService.SignClick(c.Request.Context(), doc, id, user, services.ClickSignature{
+			SignedName:      req.SignedName,
+			AuthenticatedAt: authTime(c),
+			IPAddress:       c.ClientIP(),
+			UserAgent:       c.GetHeader("User-Agent"),
+		})
+	default:
+		certificates, parseErr := signature.ParseCertificates([]byte(req.Certificate))
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate"})
+			return
+		}
+		sig, decodeErr := base64.StdEncoding.DecodeString(req.Signature)
+		if decodeErr != nil || len(sig) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature encoding"})
+			return
+		}
+		workflow, err = h.signingWorkflowService.SignCertificate(c.Request.Context(), doc, id, user, certificates, sig, c.ClientIP(), c.GetHeader("User-Agent"))
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWrongSigningMethod):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sign with the method you were asked to"})
+		case errors.Is(err, services.ErrSignerIdentityMismatch):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Signature doesn't identify you", "details": err.Error()})
+		case errors.Is(err, signature.ErrInvalidSignature):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Signature doesn't match the version's file"})
+		case errors.Is(err, signature.ErrUntrustedCertificate):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Certificate is not trusted", "details": err.Error()})
+		case errors.Is(err, services.ErrSignatureExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Version is already signed with this certificate"})
+		case errors.Is(err, services.ErrNoSigningCertificate):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You have no valid signing certificate"})
+		default:
+			h.workflowError(c, err, "Failed to sign document")
+		}
+		return
+	}
+
+	details := map[string]interface{}{
+		"workflow_id": workflow.ID,
+		"version":     workflow.Version,
+		"file_hash":   workflow.FileHash,
+		"status":      workflow.Status,
+	}
+	for _, signer := range workflow.Signers {
+		if signer.UserID == user.ID {
+			details["method"] = signer.Method
+			details["evidence"] = signer.Evidence
+		}
+	}
+	h.auditService.LogAction(user.ID, &doc.ID, "document_sign", "signing_workflow", strconv.Itoa(int(workflow.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// Decline refuses to sign a document, ending the workflow
+func (h *SigningWorkflowHandler) Decline(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "workflowId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signing workflow ID"})
+		return
+	}
+
+	var req DeclineSigningRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	workflow, err := h.signingWorkflowService.Decline(doc, id, user, req.Reason)
+	if err != nil {
+		h.workflowError(c, err, "Failed to decline signing")
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "signing_workflow_decline", "signing_workflow", strconv.Itoa(int(workflow.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"reason": req.Reason,
+	})
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// Cancel ends a signing workflow in progress. Only its creator and
+// administrators may cancel it.
+func (h *SigningWorkflowHandler) Cancel(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "workflowId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signing workflow ID"})
+		return
+	}
+
+	existing, err := h.signingWorkflowService.Get(doc.ID, id)
+	if err != nil {
+		h.workflowError(c, err, "Failed to cancel signing workflow")
+		return
+	}
+	if existing.CreatedBy != user.ID && user.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	workflow, err := h.signingWorkflowService.Cancel(doc, id, user)
+	if err != nil {
+		h.workflowError(c, err, "Failed to cancel signing workflow")
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "signing_workflow_cancel", "signing_workflow", strconv.Itoa(int(workflow.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// workflowError writes the response for an error acting on a signing
+// workflow
+func (h *SigningWorkflowHandler) workflowError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSigningWorkflowNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Signing workflow not found"})
+	case errors.Is(err, services.ErrSigningWorkflowClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Signing workflow is no longer in progress"})
+	case errors.Is(err, services.ErrDocumentChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Document changed since the signing workflow started"})
+	case errors.Is(err, services.ErrNotPendingSigner):
+		c.JSON(http.StatusForbidden, gin.H{"error": "It isn't your turn to sign"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// StatsHandler handles statistics requests for dashboards
+type StatsHandler struct {
+	statsService *services.StatsService
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(statsService *services.StatsService) *StatsHandler {
+	return &StatsHandler{
+		statsService: statsService,
+	}
+}
+
+// GetDocumentStats returns the organization's document counts, storage use and the upload trend
+// bucketed by interval (day, week or month) between from and to
+func (h *StatsHandler) GetDocumentStats(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	interval := services.TrendInterval(c.DefaultQuery("interval", string(services.TrendMonthly)))
+	if !services.ValidTrendInterval(interval) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval, expected day, week or month"})
+		return
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := parseDateParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, expected RFC 3339 or YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+
+	var from time.Time
+	switch interval {
+	case services.TrendDaily:
+		from = to.AddDate(0, 0, -30)
+	case services.TrendWeekly:
+		from = to.AddDate(0, 0, -12*7)
+	default:
+		from = to.AddDate(-1, 0, 0)
+	}
+	if value := c.Query("from"); value != "" {
+		parsed, err := parseDateParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, expected RFC 3339 or YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	stats, err := h.statsService.GetDocumentStats(c.Request.Context(), user.OrganizationID, interval, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get document statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetStorageUsage returns the storage currently held per department, or per
+// creator with group_by=creator
+func (h *StatsHandler) GetStorageUsage(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	grouping, ok := parseStorageGrouping(c)
+	if !ok {
+		return
+	}
+
+	usage, err := h.statsService.GetStorageUsage(c.Request.Context(), user.OrganizationID, grouping)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage usage"})
+		return
+	}
+
+	if c.DefaultQuery("format", "json") != "csv" {
+		c.JSON(http.StatusOK, gin.H{"data": usage})
+		return
+	}
+
+	rows := make([][]string, 0, len(usage))
+	for _, entry := range usage {
+		rows = append(rows, storageUsageRow(grouping, entry))
+	}
+	writeCSV(c, "storage-usage", storageUsageHeader(grouping), rows)
+}
+
+// GetMonthlyStorageUsage returns the storage held at the end of each month
+// between from and to (YYYY-MM, defaulting to the last twelve months) per
+// department, or per creator with group_by=creator
+func (h *StatsHandler) GetMonthlyStorageUsage(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	grouping, ok := parseStorageGrouping(c)
+	if !ok {
+		return
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, expected YYYY-MM"})
+			return
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, -11, 0)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, expected YYYY-MM"})
+			return
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	usage, err := h.statsService.GetMonthlyStorageUsage(c.Request.Context(), user.OrganizationID, grouping, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get monthly storage usage"})
+		return
+	}
+
+	if c.DefaultQuery("format", "json") != "csv" {
+		c.JSON(http.StatusOK, gin.H{"data": usage})
+		return
+	}
+
+	header := append([]string{"month"}, storageUsageHeader(grouping)...)
+	header = append(header, "uploaded_documents", "uploaded_bytes")
+	rows := make([][]string, 0, len(usage))
+	for _, entry := range usage {
+		row := append([]string{entry.Month.Format("2006-01")}, storageUsageRow(grouping, entry.StorageUsage)...)
+		row = append(row, strconv.FormatInt(entry.UploadedDocuments, 10), strconv.FormatInt(entry.UploadedBytes, 10))
+		rows = append(rows, row)
+	}
+	writeCSV(c, "storage-usage-monthly", header, rows)
+}
+
+// parseStorageGrouping reads the group_by query parameter, writing an error
+// response when it is invalid
+func parseStorageGrouping(c *gin.Context) (services.StorageGrouping, bool) {
+	grouping := services.StorageGrouping(c.DefaultQuery("group_by", string(services.GroupByDepartment)))
+	if !services.ValidStorageGrouping(grouping) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_by, expected department or creator"})
+		return "", false
+	}
+	return grouping, true
+}
+
+// storageUsageHeader lists the CSV columns of a storage usage row
+func storageUsageHeader(grouping services.StorageGrouping) []string {
+	if grouping == services.GroupByCreator {
+		return []string{"department", "creator_id", "username", "documents", "bytes"}
+	}
+	return []string{"department", "documents", "bytes"}
+}
+
+// storageUsageRow formats a storage usage entry as CSV fields
+func storageUsageRow(grouping services.StorageGrouping, usage services.StorageUsage) []string {
+	row := []string{usage.Department}
+	if grouping == services.GroupByCreator {
+		row = append(row, strconv.Itoa(int(usage.CreatorID)), usage.Username)
+	}
+	return append(row, strconv.FormatInt(usage.Documents, 10), strconv.FormatInt(usage.Bytes, 10))
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/auth"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// StepUpRequest confirms a signed-in user with their password
+type StepUpRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// stepUpUser returns the current user and access token claims, writing an
+// error response when the request wasn't made by a signed-in user who may
+// authenticate. API keys and service tokens can't step up.
+func stepUpUser(c *gin.Context) (*models.User, *auth.Claims, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	value, ok := c.Get("token_claims")
+	if !ok || value.(*auth.Claims).ClientType == auth.ServiceClientType {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only signed-in users can authenticate again"})
+		return nil, nil, false
+	}
+
+	// Failed step-ups lock the account like failed logins
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is temporarily locked"})
+		return nil, nil, false
+	}
+	return user, value.(*auth.Claims), true
+}
+
+// StepUp authenticates the current user again with their password and
+// issues an access token that counts as a fresh authentication, as reading
+// restricted documents needs
+func (h *AuthHandler) StepUp(c *gin.Context) {
+	user, claims, ok := stepUpUser(c)
+	if !ok {
+		return
+	}
+
+	var req StepUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Directory users confirm their directory password
+	var err error
+	if h.ldapAuthService != nil && user.AuthSource == models.AuthSourceLDAP {
+		var verified *models.User
+		verified, _, err = h.ldapAuthService.Authenticate(user.Username, req.Password)
+		if err == nil && verified.ID != user.ID {
+			err = services.ErrDirectoryCredentials
+		}
+		if err != nil && !errors.Is(err, services.ErrDirectoryCredentials) {
+			log.Printf("Directory step-up of user %d failed: %v", user.ID, err)
+			if errors.Is(err, services.ErrDirectoryNoRole) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Account is not permitted to sign in"})
+				return
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Directory service unavailable"})
+			return
+		}
+	} else {
+		err = h.passwordService.VerifyPassword(req.Password, user.Password)
+	}
+	if err != nil {
+		h.stepUpFailed(c, user, "invalid_password")
+		return
+	}
+
+	h.completeStepUp(c, user, claims, "password")
+}
+
+// BeginWebAuthnStepUp starts authenticating the current user again with
+// one of their security keys or passkeys
+func (h *AuthHandler) BeginWebAuthnStepUp(c *gin.Context) {
+	user, _, ok := stepUpUser(c)
+	if !ok {
+		return
+	}
+
+	hasCredentials, err := h.webauthnService.HasCredentials(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !hasCredentials {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No security keys registered"})
+		return
+	}
+
+	options, err := h.webauthnService.BeginStepUp(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start security key verification"})
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishWebAuthnStepUp verifies the security key of the current user and
+// issues an access token that counts as a fresh authentication
+func (h *AuthHandler) FinishWebAuthnStepUp(c *gin.Context) {
+	user, claims, ok := stepUpUser(c)
+	if !ok {
+		return
+	}
+
+	var req WebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	assertion, err := req.assertion()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if _, err := h.webauthnService.FinishStepUp(user.ID, assertion); err != nil {
+		reason := "invalid_webauthn"
+		switch {
+		case errors.Is(err, services.ErrWebAuthnChallenge):
+			reason = "webauthn_challenge_expired"
+		case errors.Is(err, services.ErrWebAuthnCredentialNotFound):
+			reason = "webauthn_unknown_credential"
+		case errors.Is(err, services.ErrWebAuthnVerification):
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		log.Printf("WebAuthn step-up of user %d failed: %v", user.ID, err)
+		h.stepUpFailed(c, user, reason)
+		return
+	}
+
+	h.completeStepUp(c, user, claims, "webauthn")
+}
+
+// stepUpFailed counts a failed step-up towards locking the account, as a
+// failed login would, and writes the 401 response
+func (h *AuthHandler) stepUpFailed(c *gin.Context, user *models.User, reason string) {
+	if err := h.userService.IncrementLoginAttempts(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "step_up_failed", "auth", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"reason": reason,
+	})
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
+// completeStepUp issues an access token for the same client as the current
+// one, authenticated now. The current token keeps working until it expires.
+func (h *AuthHandler) completeStepUp(c *gin.Context, user *models.User, claims *auth.Claims, method string) {
+	token, err := h.tokenService.GenerateToken(user, claims.ClientType, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	if err := h.userService.ResetLoginAttempts(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "step_up", "auth", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"method": method,
+	})
+
+	expiryTime, _ := h.tokenService.GetTokenExpiryTime(token)
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expiryTime,
+	})
+}
+
+// credentialChangeMaxAge is how recent an authentication must be to change
+// the user's second factors while step-up is off
+const credentialChangeMaxAge = 5 * time.Minute
+
+// requireFreshAuth writes a 401 response the client can answer by
+// authenticating again at /auth/step-up, and returns false, unless the
+// current user entered a password or used a security key within the step-up
+// max age, or at all when step-up is off. It guards acting with the user's
+// signature; API keys and service tokens can't.
+func requireFreshAuth(c *gin.Context, policy *services.StepUpPolicy) bool {
+	return requireAuthWithin(c, policy.MaxAge(), "Only signed-in users can sign")
+}
+
+// requireFreshCredentialAuth is requireFreshAuth for changes to the user's
+// security keys and recovery codes, so a stolen token alone can't take over
+// the second factor. It needs a recent authentication even with step-up off.
+func requireFreshCredentialAuth(c *gin.Context, policy *services.StepUpPolicy) bool {
+	maxAge := policy.MaxAge()
+	if maxAge == 0 {
+		maxAge = credentialChangeMaxAge
+	}
+	return requireAuthWithin(c, maxAge, "Only signed-in users can change their security keys")
+}
+
+// requireAuthWithin writes a step-up response and returns false unless the
+// current user entered a password or used a security key within maxAge, or
+// at all for a maxAge of 0. Other clients get forbidden as a 403.
+func requireAuthWithin(c *gin.Context, maxAge time.Duration, forbidden string) bool {
+	value, ok := c.Get("token_claims")
+	if !ok || value.(*auth.Claims).ClientType == auth.ServiceClientType {
+		c.JSON(http.StatusForbidden, gin.H{"error": forbidden})
+		return false
+	}
+
+	authenticatedAt := authTime(c)
+	if !authenticatedAt.IsZero() && (maxAge == 0 || time.Since(authenticatedAt) <= maxAge) {
+		return true
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":            "Fresh authentication required",
+		"step_up_required": true,
+		"max_age":          int(maxAge.Seconds()),
+	})
+	return false
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// SubscriptionHandler handles document and folder subscriptions of the current user
+type SubscriptionHandler struct {
+	subscriptionService *services.SubscriptionService
+	documentService     *services.DocumentService
+	folderService       *services.FolderService
+	authService         *services.AuthorizationService
+}
+
+// NewSubscriptionHandler creates a new subscription handler
+func NewSubscriptionHandler(
+	subscriptionService *services.SubscriptionService,
+	documentService *services.DocumentService,
+	folderService *services.FolderService,
+	authService *services.AuthorizationService,
+) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		subscriptionService: subscriptionService,
+		documentService:     documentService,
+		folderService:       folderService,
+		authService:         authService,
+	}
+}
+
+// SubscriptionRequest represents a subscription to a document or a folder.
+// Omitted event flags default to true.
+type SubscriptionRequest struct {
+	DocumentID        *uint `json:"document_id"`
+	FolderID          *uint `json:"folder_id"`
+	NotifyVersions    *bool `json:"notify_versions"`
+	NotifyPermissions *bool `json:"notify_permissions"`
+	NotifyComments    *bool `json:"notify_comments"`
+}
+
+// apply copies the event flags of the request onto a subscription, keeping
+// the current value of omitted flags
+func (r *SubscriptionRequest) apply(subscription *models.Subscription) {
+	if r.NotifyVersions != nil {
+		subscription.NotifyVersions = *r.NotifyVersions
+	}
+	if r.NotifyPermissions != nil {
+		subscription.NotifyPermissions = *r.NotifyPermissions
+	}
+	if r.NotifyComments != nil {
+		subscription.NotifyComments = *r.NotifyComments
+	}
+}
+
+// GetSubscriptions returns the current user's subscriptions
+func (h *SubscriptionHandler) GetSubscriptions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	subscriptions, err := h.subscriptionService.GetForUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": subscriptions})
+}
+
+// Subscribe subscribes the current user to a document or folder they may read
+func (h *SubscriptionHandler) Subscribe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if (req.DocumentID == nil) == (req.FolderID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidSubscription.Error()})
+		return
+	}
+
+	var allowed bool
+	var err error
+	if req.DocumentID != nil {
+		doc, getErr := h.documentService.GetByID(c.Request.Context(), *req.DocumentID)
+		if getErr != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		allowed, err = h.authService.CanRead(user, doc)
+	} else {
+		folder, getErr := h.folderService.GetByID(*req.FolderID)
+		if getErr != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
+		allowed, err = h.authService.CanOnFolder(user, folder, services.ActionRead)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	subscription := &models.Subscription{
+		UserID:            user.ID,
+		DocumentID:        req.DocumentID,
+		FolderID:          req.FolderID,
+		NotifyVersions:    true,
+		NotifyPermissions: true,
+		NotifyComments:    true,
+	}
+	req.apply(subscription)
+
+	if err := h.subscriptionService.Subscribe(subscription); err != nil {
+		if errors.Is(err, services.ErrInvalidSubscription) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// UpdateSubscription changes the events of a subscription
+func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
+	subscription, ok := h.loadSubscription(c)
+	if !ok {
+		return
+	}
+
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	req.apply(subscription)
+
+	if err := h.subscriptionService.Update(subscription); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// Unsubscribe removes a subscription
+func (h *SubscriptionHandler) Unsubscribe(c *gin.Context) {
+	subscription, ok := h.loadSubscription(c)
+	if !ok {
+		return
+	}
+
+	if err := h.subscriptionService.Delete(subscription); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed successfully"})
+}
+
+// loadSubscription resolves the current user's subscription from the :id
+// parameter, writing an error response when it is unavailable
+func (h *SubscriptionHandler) loadSubscription(c *gin.Context) (*models.Subscription, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return nil, false
+	}
+
+	subscription, err := h.subscriptionService.GetByID(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return nil, false
+	}
+
+	return subscription, true
+}
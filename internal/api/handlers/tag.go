@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// TagHandler handles tag related requests
+type TagHandler struct {
+	tagService *services.TagService
+}
+
+// NewTagHandler creates a new tag handler
+func NewTagHandler(tagService *services.TagService) *TagHandler {
+	return &TagHandler{
+		tagService: tagService,
+	}
+}
+
+// DocumentTagsRequest represents a request assigning tags to a document
+type DocumentTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// GetTags returns the tags used in the user's organization with their usage
+// counts
+func (h *TagHandler) GetTags(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tags, err := h.tagService.GetAll(c.Request.Context(), user.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// Tag suggestion defaults
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+	trendingWindow      = 7 * 24 * time.Hour
+)
+
+// SuggestTags returns tags matching a name prefix, most used first, together
+// with the tags applied most often over the past week
+func (h *TagHandler) SuggestTags(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSuggestLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	suggestions := []models.Tag{}
+	if prefix := strings.TrimSpace(c.Query("q")); prefix != "" {
+		suggestions, err = h.tagService.Suggest(c.Request.Context(), user.OrganizationID, prefix, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suggest tags"})
+			return
+		}
+	}
+
+	trending, err := h.tagService.Trending(c.Request.Context(), user.OrganizationID, time.Now().Add(-trendingWindow), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get trending tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     suggestions,
+		"trending": trending,
+	})
+}
+
+// SetTags replaces the tags of a document
+func (h *DocumentHandler) SetTags(c *gin.Context) {
+	h.updateTags(c, "document_tag_set", h.documentService.SetTags)
+}
+
+// AddTags adds tags to a document, keeping its existing tags
+func (h *DocumentHandler) AddTags(c *gin.Context) {
+	h.updateTags(c, "document_tag_add", h.documentService.AddTags)
+}
+
+// updateTags applies the tags of a request to the document with the given update
+func (h *DocumentHandler) updateTags(c *gin.Context, action string, update func(context.Context, *models.Document, []string, uint) error) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionWrite) {
+		return
+	}
+
+	var req DocumentTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	names := services.NormalizeTags(req.Tags)
+	if err := update(c.Request.Context(), doc, names, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update document tags"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, action, "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"tags": names,
+	})
+
+	c.JSON(http.StatusOK, newDocumentResponse(doc))
+}
+
+// RemoveTag removes a tag from a document
+func (h *DocumentHandler) RemoveTag(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionWrite) {
+		return
+	}
+
+	name := strings.TrimSpace(c.Param("tag"))
+	if err := h.documentService.RemoveTag(c.Request.Context(), doc, name, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update document tags"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_untag", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"tag": name,
+	})
+
+	c.JSON(http.StatusOK, newDocumentResponse(doc))
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// GetSuggestions returns the tags and category suggested for a document
+func (h *DocumentHandler) GetSuggestions(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionRead) {
+		return
+	}
+
+	status := models.SuggestionStatus(c.DefaultQuery("status", string(models.SuggestionPending)))
+	switch status {
+	case "all":
+		status = ""
+	case models.SuggestionPending, models.SuggestionAccepted, models.SuggestionRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid suggestion status"})
+		return
+	}
+
+	suggestions, err := h.autoTagService.GetSuggestions(doc.ID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get suggestions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": suggestions})
+}
+
+// AcceptSuggestion applies a suggested tag or category to a document
+func (h *DocumentHandler) AcceptSuggestion(c *gin.Context) {
+	user, doc, suggestion, ok := h.loadSuggestion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.autoTagService.Accept(c.Request.Context(), doc, suggestion, user.ID); err != nil {
+		h.writeSuggestionError(c, err)
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "suggestion_accept", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), suggestionAuditDetails(suggestion))
+
+	c.JSON(http.StatusOK, newDocumentResponse(doc))
+}
+
+// RejectSuggestion dismisses a suggested tag or category
+func (h *DocumentHandler) RejectSuggestion(c *gin.Context) {
+	user, doc, suggestion, ok := h.loadSuggestion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.autoTagService.Reject(suggestion, user.ID); err != nil {
+		h.writeSuggestionError(c, err)
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "suggestion_reject", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), suggestionAuditDetails(suggestion))
+
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// loadSuggestion resolves the document and suggestion of a review request and
+// checks that the user may modify the document
+func (h *DocumentHandler) loadSuggestion(c *gin.Context) (*models.User, *models.Document, *models.TagSuggestion, bool) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	if !h.authorize(c, user, doc, services.ActionWrite) {
+		return nil, nil, nil, false
+	}
+
+	suggestionID, ok := parseIDParam(c, "suggestionId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid suggestion ID"})
+		return nil, nil, nil, false
+	}
+
+	suggestion, err := h.autoTagService.GetSuggestion(doc.ID, suggestionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return nil, nil, nil, false
+	}
+
+	return user, doc, suggestion, true
+}
+
+// writeSuggestionError writes the response for a failed review
+func (h *DocumentHandler) writeSuggestionError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrSuggestionReviewed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review suggestion"})
+}
+
+// suggestionAuditDetails describes a suggestion for the audit log
+func suggestionAuditDetails(suggestion *models.TagSuggestion) map[string]interface{} {
+	return map[string]interface{}{
+		"suggestion_id": suggestion.ID,
+		"kind":          suggestion.Kind,
+		"value":         suggestion.Value,
+		"source":        suggestion.Source,
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// TimestampHandler obtains and serves RFC 3161 timestamps of document and
+// block hashes
+type TimestampHandler struct {
+	timestampService  *services.TimestampService
+	documentService   *services.DocumentService
+	blockchainService *services.BlockchainService
+	authService       *services.AuthorizationService
+	auditService      *services.AuditService
+}
+
+// NewTimestampHandler creates a new timestamp handler. blockchainService may
+// be nil when the blockchain is disabled.
+func NewTimestampHandler(timestampService *services.TimestampService, documentService *services.DocumentService, blockchainService *services.BlockchainService, authService *services.AuthorizationService, auditService *services.AuditService) *TimestampHandler {
+	return &TimestampHandler{
+		timestampService:  timestampService,
+		documentService:   documentService,
+		blockchainService: blockchainService,
+		authService:       authService,
+		auditService:      auditService,
+	}
+}
+
+// loadDocument returns the current user and the document of the request
+// when the user may read it, writing an error response otherwise
+func (h *TimestampHandler) loadDocument(c *gin.Context) (*models.User, *models.Document, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return nil, nil, false
+	}
+
+	doc, err := h.documentService.GetByID(c.Request.Context(), id)
+	if err != nil || doc.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, nil, false
+	}
+
+	allowed, err := h.authService.CanRead(user, doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, nil, false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return nil, nil, false
+	}
+
+	return user, doc, true
+}
+
+// StampDocument timestamps the file hash of a document's current version
+func (h *TimestampHandler) StampDocument(c *gin.Context) {
+	user, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	record, err := h.timestampService.StampDocument(c.Request.Context(), doc, user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get timestamp"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, &doc.ID, "document_timestamp", "timestamp", strconv.Itoa(int(record.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"version":       record.Version,
+		"hash":          record.Hash,
+		"serial_number": record.SerialNumber,
+		"gen_time":      record.GenTime,
+	})
+
+	c.JSON(http.StatusCreated, record)
+}
+
+// GetDocumentTimestamps lists the timestamps of a document's versions with
+// the result of checking each
+func (h *TimestampHandler) GetDocumentTimestamps(c *gin.Context) {
+	_, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	statuses, err := h.timestampService.GetForDocument(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get timestamps"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": statuses})
+}
+
+// DownloadDocumentToken serves a document timestamp's DER token, for
+// checking with `openssl ts -verify -token_in`
+func (h *TimestampHandler) DownloadDocumentToken(c *gin.Context) {
+	_, doc, ok := h.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "timestampId")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timestamp ID"})
+		return
+	}
+
+	record, err := h.timestampService.GetDocumentTimestamp(doc.ID, id)
+	h.serveToken(c, record, err)
+}
+
+// StampBlock timestamps the hash of the latest block
+func (h *TimestampHandler) StampBlock(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	record, err := h.timestampService.StampBlock(c.Request.Context(), h.blockchainService.LatestBlock(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get timestamp"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "blockchain_timestamp", "timestamp", strconv.Itoa(int(record.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"block_number":  *record.BlockNumber,
+		"hash":          record.Hash,
+		"serial_number": record.SerialNumber,
+		"gen_time":      record.GenTime,
+	})
+
+	c.JSON(http.StatusCreated, record)
+}
+
+// GetBlockTimestamps lists the timestamps of blocks with the result of
+// checking each
+func (h *TimestampHandler) GetBlockTimestamps(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	statuses, total, err := h.timestampService.GetForBlocks(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get timestamps"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  statuses,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// DownloadBlockToken serves a block timestamp's DER token
+func (h *TimestampHandler) DownloadBlockToken(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timestamp ID"})
+		return
+	}
+
+	record, err := h.timestampService.GetBlockTimestamp(id)
+	h.serveToken(c, record, err)
+}
+
+// serveToken writes a timestamp's token, or the error getting it
+func (h *TimestampHandler) serveToken(c *gin.Context, record *models.TrustedTimestamp, err error) {
+	if err != nil {
+		if errors.Is(err, services.ErrTimestampNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Timestamp not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get timestamp"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"timestamp-%d.tst\"", record.ID))
+	c.Data(http.StatusOK, "application/octet-stream", record.Token)
+}
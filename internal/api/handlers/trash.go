@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// GetTrash returns deleted documents the current user may restore
+func (h *DocumentHandler) GetTrash(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+
+	docs, total, err := h.documentService.GetTrash(c.Request.Context(), user, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get trashed documents"})
+		return
+	}
+
+	responses := make([]*DocumentResponse, 0, len(docs))
+	for i := range docs {
+		responses = append(responses, newDocumentResponse(&docs[i]))
+	}
+
+	c.JSON(http.StatusOK, &PaginatedResponse{
+		Data:  responses,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// RestoreDocument takes a document out of the trash
+func (h *DocumentHandler) RestoreDocument(c *gin.Context) {
+	user, doc, ok := h.loadTrashedDocument(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(c, user, doc, services.ActionDelete) {
+		return
+	}
+
+	if err := h.documentService.Restore(c.Request.Context(), doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore document"})
+		return
+	}
+
+	details := map[string]interface{}{
+		"title": doc.Title,
+	}
+	h.auditService.LogAction(user.ID, &doc.ID, "document_restore", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+	h.recordOnChain(doc.ID, user.ID, "document_restore", details)
+
+	c.JSON(http.StatusOK, newDocumentResponse(doc))
+}
+
+// PurgeDocument permanently removes the content of a trashed document
+func (h *DocumentHandler) PurgeDocument(c *gin.Context) {
+	user, doc, ok := h.loadTrashedDocument(c)
+	if !ok {
+		return
+	}
+
+	if err := h.documentService.Purge(c.Request.Context(), doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge document"})
+		return
+	}
+
+	details := map[string]interface{}{
+		"title":     doc.Title,
+		"file_hash": doc.FileHash,
+	}
+	h.auditService.LogAction(user.ID, &doc.ID, "document_purge", "document", strconv.Itoa(int(doc.ID)), c.ClientIP(), c.GetHeader("User-Agent"), details)
+	h.recordOnChain(doc.ID, user.ID, "document_purge", details)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document purged successfully"})
+}
+
+// loadTrashedDocument resolves the current user and the trashed document from
+// the :id parameter, writing an error response when either is unavailable
+func (h *DocumentHandler) loadTrashedDocument(c *gin.Context) (*models.User, *models.Document, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return nil, nil, false
+	}
+
+	doc, err := h.documentService.GetTrashedByID(c.Request.Context(), id)
+	if err != nil || doc.OrganizationID != user.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found in trash"})
+		return nil, nil, false
+	}
+
+	return user, doc, true
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// uploadOffsetHeader carries the offset of a chunk and, in responses, the
+// number of bytes received so far
+const uploadOffsetHeader = "Upload-Offset"
+
+// CreateUploadRequest represents a request to start a chunked upload. With
+// DocumentID the file becomes a new version of that document; otherwise a
+// new document is created from the remaining fields.
+type CreateUploadRequest struct {
+	FileName    string             `json:"file_name" binding:"required"`
+	MimeType    string             `json:"mime_type"`
+	TotalSize   int64              `json:"total_size" binding:"required,min=1"`
+	SHA256      string             `json:"sha256"`
+	DocumentID  *uint              `json:"document_id"`
+	ChangeLog   string             `json:"change_log"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Category    string             `json:"category"`
+	Tags        string             `json:"tags"`
+	AccessLevel models.AccessLevel `json:"access_level"`
+	// AccessLevelReason justifies an access level below the suggested classification
+	AccessLevelReason string `json:"access_level_reason"`
+	FolderID          *uint  `json:"folder_id"`
+}
+
+// CreateUpload starts a resumable chunked upload session
+func (h *DocumentHandler) CreateUpload(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if limit, ok := maxUploadSize(c); ok && req.TotalSize > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":    "Upload exceeds the maximum size for your role",
+			"max_size": limit,
+			"role":     user.Role,
+		})
+		return
+	}
+
+	// Reject blocked extensions before any data is sent; the content is
+	// checked once the upload is complete
+	if err := h.fileTypes.CheckName(req.FileName); err != nil {
+		writeFileTypeError(c, err, "")
+		return
+	}
+
+	session := &models.UploadSession{
+		UserID:    user.ID,
+		FileName:  req.FileName,
+		MimeType:  req.MimeType,
+		TotalSize: req.TotalSize,
+	}
+
+	if req.SHA256 != "" {
+		checksum, err := parseChecksum(req.SHA256)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SHA-256 checksum"})
+			return
+		}
+		session.ExpectedHash = hex.EncodeToString(checksum)
+	}
+
+	if req.DocumentID != nil {
+		if req.ChangeLog == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Change log is required"})
+			return
+		}
+
+		doc, err := h.documentService.GetByID(c.Request.Context(), *req.DocumentID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		if !h.authorize(c, user, doc, services.ActionWrite) {
+			return
+		}
+
+		session.DocumentID = &doc.ID
+		session.ChangeLog = req.ChangeLog
+	} else {
+		// An unset access level is classified when the upload completes
+		if req.AccessLevel != 0 && !validAccessLevel(req.AccessLevel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access level"})
+			return
+		}
+
+		if req.FolderID != nil && !h.authorizeFolder(c, user, *req.FolderID) {
+			return
+		}
+
+		session.Title = req.Title
+		session.Description = req.Description
+		session.Category = req.Category
+		session.Tags = req.Tags
+		session.AccessLevel = req.AccessLevel
+		session.AccessLevelReason = req.AccessLevelReason
+		session.FolderID = req.FolderID
+	}
+
+	if err := h.uploadService.Create(session); err != nil {
+		if errors.Is(err, services.ErrUploadTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the maximum upload size"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload session"})
+		return
+	}
+
+	c.Header(uploadOffsetHeader, "0")
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.Itoa(int(session.ID)))
+	c.JSON(http.StatusCreated, session)
+}
+
+// GetUpload returns an upload session; its received_size is the offset to
+// resume from
+func (h *DocumentHandler) GetUpload(c *gin.Context) {
+	_, session, ok := h.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header(uploadOffsetHeader, strconv.FormatInt(session.ReceivedSize, 10))
+	c.JSON(http.StatusOK, session)
+}
+
+// UploadChunk appends the raw request body to an upload session at the
+// offset given in the Upload-Offset header
+func (h *DocumentHandler) UploadChunk(c *gin.Context) {
+	_, session, ok := h.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset header is required"})
+		return
+	}
+
+	maxChunk := h.uploadService.MaxChunkSize()
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxChunk))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk exceeds the maximum chunk size", "max_chunk_size": maxChunk})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read chunk"})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chunk is empty"})
+		return
+	}
+
+	if !verifyChecksum(c, data) {
+		return
+	}
+
+	updated, err := h.uploadService.AppendChunk(session, offset, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUploadOffsetMismatch):
+			c.Header(uploadOffsetHeader, strconv.FormatInt(updated.ReceivedSize, 10))
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload offset mismatch", "received_size": updated.ReceivedSize})
+		case errors.Is(err, services.ErrUploadTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk exceeds the declared file size"})
+		case errors.Is(err, services.ErrUploadNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload is not pending"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store chunk"})
+		}
+		return
+	}
+
+	c.Header(uploadOffsetHeader, strconv.FormatInt(updated.ReceivedSize, 10))
+	c.JSON(http.StatusOK, updated)
+}
+
+// CompleteUpload assembles a fully received upload, verifies it against the
+// expected checksum and creates the document or document version
+func (h *DocumentHandler) CompleteUpload(c *gin.Context) {
+	user, session, ok := h.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	// Permissions may have changed since the session was created
+	var doc *models.Document
+	if session.DocumentID != nil {
+		var err error
+		if doc, err = h.documentService.GetByID(c.Request.Context(), *session.DocumentID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		if !h.authorize(c, user, doc, services.ActionWrite) {
+			return
+		}
+	} else if session.FolderID != nil && !h.authorizeFolder(c, user, *session.FolderID) {
+		return
+	}
+
+	content, err := h.uploadService.Assemble(session)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUploadIncomplete):
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload is incomplete", "received_size": session.ReceivedSize, "total_size": session.TotalSize})
+		case errors.Is(err, services.ErrUploadNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload is not pending"})
+		case errors.Is(err, services.ErrUploadChecksum):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Checksum mismatch", "expected": session.ExpectedHash})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assemble upload"})
+		}
+		return
+	}
+
+	if doc != nil {
+		version, ok := h.createVersion(c, user, doc, content, session.FileName, session.MimeType, session.ChangeLog)
+		if !ok {
+			h.uploadService.Release(session)
+			return
+		}
+		h.uploadService.Finish(session, doc.ID)
+
+		c.JSON(http.StatusCreated, newDocumentVersionResponse(version))
+		return
+	}
+
+	title := session.Title
+	if title == "" {
+		title = session.FileName
+	}
+
+	doc = &models.Document{
+		Title:       title,
+		Description: session.Description,
+		FileName:    session.FileName,
+		MimeType:    session.MimeType,
+		Category:    session.Category,
+		Tags:        session.Tags,
+		AccessLevel: session.AccessLevel,
+		FolderID:    session.FolderID,
+		Version:     1,
+		CreatedBy:   user.ID,
+	}
+
+	if !h.createDocument(c, user, doc, content, session.AccessLevelReason) {
+		h.uploadService.Release(session)
+		return
+	}
+	h.uploadService.Finish(session, doc.ID)
+
+	c.JSON(http.StatusCreated, newDocumentResponse(doc))
+}
+
+// AbortUpload cancels an upload session and discards its chunks
+func (h *DocumentHandler) AbortUpload(c *gin.Context) {
+	_, session, ok := h.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploadService.Abort(session); err != nil {
+		if errors.Is(err, services.ErrUploadNotPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload is not pending"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to abort upload"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload aborted successfully"})
+}
+
+// loadUpload resolves the current user and their upload session from the
+// :id path parameter, writing an error response on failure
+func (h *DocumentHandler) loadUpload(c *gin.Context) (*models.User, *models.UploadSession, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload ID"})
+		return nil, nil, false
+	}
+
+	session, err := h.uploadService.GetForUser(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return nil, nil, false
+	}
+
+	return user, session, true
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/webauthn"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+)
+
+// WebAuthnRegistrationRequest is the PublicKeyCredential created by the
+// browser, with binary values base64url encoded, and a name for it
+type WebAuthnRegistrationRequest struct {
+	Name     string `json:"name" binding:"max=100"`
+	ID       string `json:"id" binding:"required"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+		AttestationObject string   `json:"attestationObject" binding:"required"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// WebAuthnLoginRequest is the PublicKeyCredential returned by the browser
+// for a login, with binary values base64url encoded
+type WebAuthnLoginRequest struct {
+	ID       string `json:"id" binding:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData string `json:"authenticatorData" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// assertion decodes the PublicKeyCredential
+func (req *WebAuthnLoginRequest) assertion() (*services.WebAuthnAssertion, error) {
+	assertion := &services.WebAuthnAssertion{CredentialID: req.ID}
+	var err error
+	if assertion.ClientDataJSON, err = webauthn.DecodeBase64(req.Response.ClientDataJSON); err != nil {
+		return nil, err
+	}
+	if assertion.AuthenticatorData, err = webauthn.DecodeBase64(req.Response.AuthenticatorData); err != nil {
+		return nil, err
+	}
+	if assertion.Signature, err = webauthn.DecodeBase64(req.Response.Signature); err != nil {
+		return nil, err
+	}
+	if assertion.UserHandle, err = webauthn.DecodeBase64(req.Response.UserHandle); err != nil {
+		return nil, err
+	}
+	return assertion, nil
+}
+
+// WebAuthnRegistrationResponse is a registered credential. Registering the
+// first one also issues the recovery codes, shown only this once.
+type WebAuthnRegistrationResponse struct {
+	*models.WebAuthnCredential
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// RenameWebAuthnCredentialRequest renames a credential
+type RenameWebAuthnCredentialRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// passwordVerified finishes a login whose password was verified. Users with
+// security keys must confirm it with one first.
+func (h *AuthHandler) passwordVerified(c *gin.Context, user *models.User, username, method string) {
+	if h.webauthnService == nil {
+		h.completeLogin(c, user, username, method)
+		return
+	}
+
+	hasCredentials, err := h.webauthnService.HasCredentials(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !hasCredentials {
+		h.completeLogin(c, user, username, method)
+		return
+	}
+
+	options, err := h.webauthnService.BeginSecondFactor(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start security key verification"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"webauthn_required": true,
+		"webauthn":          options,
+	})
+}
+
+// BeginWebAuthnLogin starts a passwordless login with a passkey
+func (h *AuthHandler) BeginWebAuthnLogin(c *gin.Context) {
+	options, err := h.webauthnService.BeginPasswordless()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey login"})
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishWebAuthnLogin verifies a security key confirming a password, or a
+// passkey logging in on its own, and issues the tokens
+func (h *AuthHandler) FinishWebAuthnLogin(c *gin.Context) {
+	var req WebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	assertion, err := req.assertion()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	login, err := h.webauthnService.FinishLogin(assertion)
+	if err != nil {
+		userID, reason := uint(0), "invalid_webauthn"
+		switch {
+		case errors.Is(err, services.ErrWebAuthnChallenge):
+			reason = "webauthn_challenge_expired"
+		case errors.Is(err, services.ErrWebAuthnCredentialNotFound):
+			reason = "webauthn_unknown_credential"
+		case errors.Is(err, services.ErrWebAuthnVerification):
+			if login != nil {
+				userID = login.UserID
+				// Failed security keys count towards locking the account
+				if err := h.userService.IncrementLoginAttempts(userID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+					return
+				}
+			}
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		log.Printf("WebAuthn login failed: %v", err)
+
+		h.auditService.LogAction(userID, nil, "login_failed", "auth", strconv.Itoa(int(userID)), clientIP, userAgent, map[string]interface{}{
+			"reason": reason,
+		})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Security key verification failed"})
+		return
+	}
+
+	user, err := h.userService.GetByID(login.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if !h.checkAccount(c, user, user.Username) {
+		return
+	}
+
+	method := "password+webauthn"
+	if login.Ceremony == models.WebAuthnPasswordless {
+		method = "passkey"
+	}
+	h.completeLogin(c, user, user.Username, method)
+}
+
+// BeginWebAuthnRegistration starts registering a security key or passkey
+// for the current user
+func (h *AuthHandler) BeginWebAuthnRegistration(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	options, err := h.webauthnService.BeginRegistration(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start security key registration"})
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishWebAuthnRegistration verifies and stores the security key or
+// passkey created by the browser. It needs a fresh authentication.
+func (h *AuthHandler) FinishWebAuthnRegistration(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !requireFreshCredentialAuth(c, h.stepUpPolicy) {
+		return
+	}
+
+	var req WebAuthnRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	clientDataJSON, err := webauthn.DecodeBase64(req.Response.ClientDataJSON)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	attestationObject, err := webauthn.DecodeBase64(req.Response.AttestationObject)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Recovery codes are issued with the first security key
+	enrolled, err := h.webauthnService.HasCredentials(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register security key"})
+		return
+	}
+
+	credential, err := h.webauthnService.FinishRegistration(user, req.Name, clientDataJSON, attestationObject, req.Response.Transports)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebAuthnChallenge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Registration challenge is invalid or expired"})
+		case errors.Is(err, services.ErrWebAuthnVerification):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrWebAuthnCredentialExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Security key is already registered"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register security key"})
+		}
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "webauthn_registered", "webauthn_credential", strconv.Itoa(int(credential.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name":   credential.Name,
+		"aaguid": credential.AAGUID,
+	})
+
+	response := WebAuthnRegistrationResponse{WebAuthnCredential: credential}
+	if !enrolled {
+		if response.RecoveryCodes, err = h.recoveryCodeService.Generate(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+			return
+		}
+		h.auditService.LogAction(user.ID, nil, "mfa_recovery_codes_generated", "user", strconv.Itoa(int(user.ID)), c.ClientIP(), c.GetHeader("User-Agent"), nil)
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetWebAuthnCredentials lists the current user's security keys and passkeys
+func (h *AuthHandler) GetWebAuthnCredentials(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	credentials, err := h.webauthnService.GetCredentials(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get security keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": credentials})
+}
+
+// RenameWebAuthnCredential renames one of the current user's security keys
+func (h *AuthHandler) RenameWebAuthnCredential(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid security key ID"})
+		return
+	}
+
+	var req RenameWebAuthnCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	credential, err := h.webauthnService.RenameCredential(user.ID, id, req.Name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security key not found"})
+		return
+	}
+	c.JSON(http.StatusOK, credential)
+}
+
+// DeleteWebAuthnCredential removes one of the current user's security keys.
+// It needs a fresh authentication.
+func (h *AuthHandler) DeleteWebAuthnCredential(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid security key ID"})
+		return
+	}
+	if !requireFreshCredentialAuth(c, h.stepUpPolicy) {
+		return
+	}
+
+	credential, err := h.webauthnService.DeleteCredential(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security key not found"})
+		return
+	}
+
+	h.auditService.LogAction(user.ID, nil, "webauthn_removed", "webauthn_credential", strconv.Itoa(int(credential.ID)), c.ClientIP(), c.GetHeader("User-Agent"), map[string]interface{}{
+		"name": credential.Name,
+	})
+
+	// Recovery codes go with the last security key
+	remaining, err := h.webauthnService.HasCredentials(user.ID)
+	if err == nil && !remaining {
+		err = h.recoveryCodeService.Delete(user.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Security key removed"})
+}
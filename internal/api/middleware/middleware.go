@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,8 +16,9 @@ import (
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
 )
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(tokenService *auth.TokenService, userService *services.UserService) gin.HandlerFunc {
+// AuthMiddleware validates JWT tokens. Bearer API keys are accepted too,
+// within their scopes.
+func AuthMiddleware(tokenService *auth.TokenService, userService *services.UserService, tokenDenylist *services.TokenDenylistService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -37,6 +42,11 @@ func AuthMiddleware(tokenService *auth.TokenService, userService *services.UserS
 			return
 		}
 
+		if strings.HasPrefix(token, services.APIKeyPrefix) {
+			authenticateAPIKey(c, token, userService, apiKeyService)
+			return
+		}
+
 		// Validate token
 		claims, err := tokenService.ValidateToken(token)
 		if err != nil {
@@ -45,6 +55,19 @@ func AuthMiddleware(tokenService *auth.TokenService, userService *services.UserS
 			return
 		}
 
+		// Refuse tokens revoked before they expire, such as at logout
+		revoked, err := tokenDenylist.IsRevoked(claims.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// Get user from database
 		user, err := userService.GetByID(claims.UserID)
 		if err != nil {
@@ -60,12 +83,206 @@ func AuthMiddleware(tokenService *auth.TokenService, userService *services.UserS
 			return
 		}
 
+		// Refuse tokens issued before the user was signed out everywhere
+		if tokenRevoked(user, claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// Tokens of OAuth clients are limited to their scopes
+		if claims.ClientType == auth.ServiceClientType && !checkScopes(c, strings.Fields(claims.Scope)) {
+			return
+		}
+
 		// Set user in context
-		c.Set("user", user)
-		c.Set("user_id", user.ID)
-		c.Set("user_role", user.Role)
+		setUser(c, user)
+		c.Set("token_claims", claims)
+
+		c.Next()
+	}
+}
+
+// tokenRevoked reports whether an access token was issued until the user's
+// tokens were revoked. iat only has whole seconds, so tokens issued in the
+// second of the revocation are refused too; signing in again in that second
+// yields a token that is refused until the next one.
+func tokenRevoked(user *models.User, claims *auth.Claims) bool {
+	if user.TokensRevokedAt == nil {
+		return false
+	}
+	return claims.IssuedAt == nil || !claims.IssuedAt.After(user.TokensRevokedAt.Truncate(time.Second))
+}
+
+// authenticateAPIKey authenticates a request with an API key, refusing it
+// unless one of the key's scopes covers the route
+func authenticateAPIKey(c *gin.Context, key string, userService *services.UserService, apiKeyService *services.APIKeyService) {
+	apiKey, err := apiKeyService.Authenticate(key, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		c.Abort()
+		return
+	}
+
+	if !checkScopes(c, apiKeyService.Scopes(apiKey)) {
+		return
+	}
+
+	user, err := userService.GetByID(apiKey.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.Abort()
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is inactive"})
+		c.Abort()
+		return
+	}
+
+	setUser(c, user)
+	c.Set("api_key", apiKey)
+
+	c.Next()
+}
+
+// setUser sets the authenticated user of a request. Queries of the request
+// run for the user under row-level security.
+func setUser(c *gin.Context, user *models.User) {
+	c.Set("user", user)
+	c.Set("user_id", user.ID)
+	c.Set("user_role", user.Role)
+	c.Request = c.Request.WithContext(services.UserContext(c.Request.Context(), user))
+}
+
+// checkScopes refuses a request, answering 403, unless scopes cover the
+// route. The area of a route is its first segment after /api/v1, such as
+// documents.
+func checkScopes(c *gin.Context, scopes []string) bool {
+	area, _, _ := strings.Cut(strings.TrimPrefix(c.FullPath(), "/api/v1/"), "/")
+	write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+	if area != "auth" && services.ScopesAllow(scopes, area, write) {
+		return true
+	}
+
+	scope := area + ":read"
+	if write {
+		scope = area + ":write"
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient scope", "required_scope": scope})
+	c.Abort()
+	return false
+}
+
+// APIQuota enforces the daily quotas of API keys and OAuth clients and
+// counts their requests and bandwidth. Other requests pass through.
+func APIQuota(usageService *services.APIUsageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var credential services.APICredential
+		if apiKey, ok := c.Get("api_key"); ok {
+			credential = usageService.ForAPIKey(apiKey.(*models.APIKey))
+		} else if value, ok := c.Get("token_claims"); ok && value.(*auth.Claims).ClientType == auth.ServiceClientType {
+			var err error
+			if credential, err = usageService.ForServiceAccount(value.(*auth.Claims).UserID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				c.Abort()
+				return
+			}
+		} else {
+			c.Next()
+			return
+		}
+
+		if err := usageService.CheckQuota(credential); err != nil {
+			var quotaErr *services.QuotaError
+			if errors.As(err, &quotaErr) {
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(quotaErr.ResetAt).Seconds())+1))
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"error":    "Daily API quota exceeded",
+					"quota":    quotaErr.Quota,
+					"limit":    quotaErr.Limit,
+					"reset_at": quotaErr.ResetAt,
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			}
+			c.Abort()
+			return
+		}
 
 		c.Next()
+
+		bytesIn := max(c.Request.ContentLength, 0)
+		bytesOut := int64(max(c.Writer.Size(), 0))
+		if err := usageService.Record(credential, bytesIn, bytesOut); err != nil {
+			log.Printf("Failed to record API usage of %s %d: %v", credential.Type, credential.ID, err)
+		}
+	}
+}
+
+// RequirePasswordChanged refuses requests of users who must change their
+// password, except on the exempt routes such as changing it
+func RequirePasswordChanged(exemptPaths ...string) gin.HandlerFunc {
+	exempt := make(map[string]bool, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = true
+	}
+
+	return func(c *gin.Context) {
+		userInterface, exists := c.Get("user")
+		if !exists {
+			c.Next()
+			return
+		}
+
+		user := userInterface.(*models.User)
+		if user.MustChangePassword && !exempt[c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                "Password change required",
+				"must_change_password": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GuestReadOnly refuses requests of guests that change anything, except on
+// routes under the exempt prefixes, such as managing their own account
+func GuestReadOnly(exemptPrefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userInterface, exists := c.Get("user")
+		if !exists {
+			c.Next()
+			return
+		}
+
+		user := userInterface.(*models.User)
+		if user.Role != models.RoleGuest {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		for _, prefix := range exemptPrefixes {
+			if strings.HasPrefix(c.FullPath(), prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Guests have read-only access"})
+		c.Abort()
 	}
 }
 
@@ -100,14 +317,130 @@ func RequireRole(roles ...models.Role) gin.HandlerFunc {
 	}
 }
 
-// RequireAdmin middleware checks if user is admin
-func RequireAdmin() gin.HandlerFunc {
-	return RequireRole(models.RoleAdmin)
+// RequireCapability middleware checks if the user's role holds the capability
+func RequireCapability(roleService *services.RoleService, capability string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userInterface, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		user := userInterface.(*models.User)
+		allowed, err := roleService.HasCapability(user.Role, capability)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":               "Insufficient permissions",
+				"required_capability": capability,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireDefaultOrganization limits a route to users of the default
+// organization, for deployment-wide administration that no tenant's own
+// administrators may reach
+func RequireDefaultOrganization(organizationService *services.OrganizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userInterface, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		user := userInterface.(*models.User)
+		isDefault, err := organizationService.IsDefault(user.OrganizationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organization"})
+			c.Abort()
+			return
+		}
+
+		if !isDefault {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only administrators of the default organization may do this"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// BodySizeLimit rejects request bodies larger than maxBytes with 413.
+// Requests declaring a larger Content-Length are rejected before the body is
+// read; others fail once they exceed the limit while being read. Routes in
+// skipPaths, such as uploads limited by UploadSizeLimit, are left alone.
+func BodySizeLimit(maxBytes int64, skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > maxBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":    "Request body too large",
+				"max_size": maxBytes,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
 }
 
-// RequireManagerOrAdmin middleware checks if user is manager or admin
-func RequireManagerOrAdmin() gin.HandlerFunc {
-	return RequireRole(models.RoleAdmin, models.RoleManager)
+// UploadSizeLimit limits uploads to the maximum size for the user's role,
+// falling back to defaultLimit for roles without one. It must run after
+// AuthMiddleware. The limit is stored in the context as "max_upload_size".
+func UploadSizeLimit(limits map[models.Role]int64, defaultLimit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userInterface, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		user := userInterface.(*models.User)
+
+		limit, ok := limits[user.Role]
+		if !ok {
+			limit = defaultLimit
+		}
+
+		if c.Request.ContentLength > limit {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":    "Upload exceeds the maximum size for your role",
+				"max_size": limit,
+				"role":     user.Role,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("max_upload_size", limit)
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
 }
 
 // CORSMiddleware handles CORS
@@ -129,7 +462,8 @@ func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
 		}
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Length, Content-Type, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Length, Content-Type, Authorization, X-Requested-With, X-Client-Type, X-Content-SHA256, Upload-Offset")
+		c.Header("Access-Control-Expose-Headers", "Upload-Offset, Location")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Max-Age", "86400")
 
@@ -211,3 +545,47 @@ func RateLimitMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RouteRateLimit limits each client to maxRequests per window on the routes
+// it guards, such as ones sending emails
+func RouteRateLimit(maxRequests int, window time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	clients := make(map[string][]time.Time)
+
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+		now := time.Now()
+
+		mu.Lock()
+		// Forget requests that left the window, and clients without any
+		for ip, requests := range clients {
+			valid := requests[:0]
+			for _, at := range requests {
+				if now.Sub(at) < window {
+					valid = append(valid, at)
+				}
+			}
+			if len(valid) == 0 {
+				delete(clients, ip)
+			} else {
+				clients[ip] = valid
+			}
+		}
+		limited := len(clients[clientIP]) >= maxRequests
+		if !limited {
+			clients[clientIP] = append(clients[clientIP], now)
+		}
+		mu.Unlock()
+
+		if limited {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Rate limit exceeded",
+				"retry_after": int(window.Seconds()),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
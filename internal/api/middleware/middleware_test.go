@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/auth"
+)
+
+// newGuestRouter serves a few routes behind GuestReadOnly for a user of role
+func newGuestRouter(role models.Role) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user", &models.User{ID: 1, Role: role})
+		c.Next()
+	})
+	router.Use(GuestReadOnly("/api/v1/auth/", "/api/v1/favorites"))
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		router.Handle(method, "/api/v1/documents/:id", ok)
+		router.Handle(method, "/api/v1/auth/profile", ok)
+		router.Handle(method, "/api/v1/favorites/:id", ok)
+	}
+	return router
+}
+
+func serve(router *gin.Engine, method, path string) int {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code
+}
+
+func TestGuestReadOnlyRefusesGuestWrites(t *testing.T) {
+	router := newGuestRouter(models.RoleGuest)
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if code := serve(router, method, "/api/v1/documents/1"); code != http.StatusForbidden {
+			t.Errorf("%s by a guest returned %d, want %d", method, code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestGuestReadOnlyAllowsGuestReads(t *testing.T) {
+	router := newGuestRouter(models.RoleGuest)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		if code := serve(router, method, "/api/v1/documents/1"); code != http.StatusOK {
+			t.Errorf("%s by a guest returned %d, want %d", method, code, http.StatusOK)
+		}
+	}
+}
+
+func TestGuestReadOnlyAllowsExemptPrefixes(t *testing.T) {
+	router := newGuestRouter(models.RoleGuest)
+
+	for _, path := range []string{"/api/v1/auth/profile", "/api/v1/favorites/1"} {
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+			if code := serve(router, method, path); code != http.StatusOK {
+				t.Errorf("%s %s by a guest returned %d, want %d", method, path, code, http.StatusOK)
+			}
+		}
+	}
+}
+
+func TestGuestReadOnlyIgnoresOtherRoles(t *testing.T) {
+	router := newGuestRouter(models.RoleEmployee)
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		if code := serve(router, method, "/api/v1/documents/1"); code != http.StatusOK {
+			t.Errorf("%s by an employee returned %d, want %d", method, code, http.StatusOK)
+		}
+	}
+}
+
+func TestTokenRevoked(t *testing.T) {
+	revokedAt := time.Date(2026, 10, 16, 9, 30, 15, 0, time.UTC)
+	issued := func(at time.Time) *auth.Claims {
+		return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(at)}}
+	}
+
+	tests := []struct {
+		name      string
+		revokedAt *time.Time
+		claims    *auth.Claims
+		want      bool
+	}{
+		{"never revoked", nil, issued(revokedAt), false},
+		{"issued before", &revokedAt, issued(revokedAt.Add(-time.Minute)), true},
+		{"issued in the same second", &revokedAt, issued(revokedAt.Add(500 * time.Millisecond)), true},
+		{"issued in the same second, stored with fractions", ptr(revokedAt.Add(900 * time.Millisecond)), issued(revokedAt.Add(100 * time.Millisecond)), true},
+		{"issued the next second", &revokedAt, issued(revokedAt.Add(time.Second)), false},
+		{"without iat", &revokedAt, &auth.Claims{}, true},
+	}
+	for _, tt := range tests {
+		user := &models.User{ID: 1, TokensRevokedAt: tt.revokedAt}
+		if got := tokenRevoked(user, tt.claims); got != tt.want {
+			t.Errorf("%s: tokenRevoked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
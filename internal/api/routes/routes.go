@@ -1,17 +1,48 @@
 package routes
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/alerting"
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/api/handlers"
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/api/middleware"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/archive"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/blockchain"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/cdn"
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/config"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/converter"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/directory"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/dlp"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/filetype"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/importer"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/ingest"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/mailer"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/scanner"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/search"
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/auth"
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/kms"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/webauthn"
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/services"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/siem"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/signature"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/storage"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/tagging"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/timestamp"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/watermark"
 )
 
 // SetupRoutes configures all application routes
-func SetupRoutes(cfg *config.Config) *gin.Engine {
+func SetupRoutes(cfg *config.Config) (*gin.Engine, error) {
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -24,16 +55,761 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 	router.Use(middleware.SecurityHeadersMiddleware())
 	router.Use(middleware.CORSMiddleware(cfg.AllowedOrigins))
 	router.Use(middleware.RateLimitMiddleware())
+	// Uploads are limited per role, chunks by the chunk size and inbound
+	// emails by their own limit
+	router.Use(middleware.BodySizeLimit(int64(cfg.MaxRequestBodyMB)<<20,
+		"/api/v1/documents",
+		"/api/v1/documents/classify",
+		"/api/v1/documents/:id/versions",
+		"/api/v1/uploads",
+		"/api/v1/uploads/:id",
+		"/api/v1/bulk-uploads",
+		"/api/v1/inbound-email",
+	))
 	router.Use(gin.Recovery())
 
+	// Initialize storage
+	fileStorage, err := storage.NewLocalStorage(cfg.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	// Resolve the master encryption key
+	keyProvider, err := kms.NewKeyProvider(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize key provider: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	masterKey, err := keyProvider.MasterKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load master key from %s: %w", keyProvider.Name(), err)
+	}
+	log.Printf("Master encryption key loaded from %s provider", keyProvider.Name())
+
 	// Initialize services
-	tokenService := auth.NewTokenService(cfg)
+	tokenService, err := auth.NewTokenService(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token configuration: %w", err)
+	}
 	passwordService := crypto.NewPasswordService()
+	envelopeService := crypto.NewEnvelopeService(masterKey)
+	if cfg.PreviousKey != "" {
+		envelopeService.SetPreviousRootKeys(cfg.PreviousKey)
+	}
 	userService := services.NewUserService()
 	auditService := services.NewAuditService()
+	documentService := services.NewDocumentService(fileStorage, envelopeService)
+	authService := services.NewAuthorizationService()
+	roleService := services.NewRoleService()
+	groupService := services.NewGroupService()
+	organizationService := services.NewOrganizationService()
+	folderService := services.NewFolderService()
+	categoryService := services.NewCategoryService()
+	metadataService := services.NewMetadataService()
+	notificationService := services.NewNotificationService()
+	registrationService := services.NewRegistrationService(userService, notificationService, cfg.RegistrationAllowedDomains)
+	subscriptionService := services.NewSubscriptionService(authService, notificationService)
+	favoriteService := services.NewFavoriteService()
+	statsService := services.NewStatsService()
+	uploadService := services.NewUploadService(fileStorage, envelopeService,
+		time.Duration(cfg.UploadSessionTTL)*time.Hour,
+		int64(cfg.UploadMaxSizeMB)<<20,
+		int64(cfg.UploadMaxChunkSizeMB)<<20)
+	fileTypePolicy := filetype.NewPolicy(cfg.UploadAllowedExtensions, cfg.UploadAllowedTypes, cfg.UploadBlockedExtensions, cfg.UploadBlockedTypes)
+	commentService := services.NewCommentService(authService, notificationService, subscriptionService)
+
+	// Directory groups map to roles and departments for LDAP login and directory sync
+	directoryMapping := services.DirectoryMapping{
+		RoleGroups: map[models.Role][]string{
+			models.RoleAdmin:    cfg.DirectoryAdminGroups,
+			models.RoleManager:  cfg.DirectoryManagerGroups,
+			models.RoleEmployee: cfg.DirectoryEmployeeGroups,
+			models.RoleGuest:    cfg.DirectoryGuestGroups,
+		},
+		DefaultRole: models.Role(cfg.DirectoryDefaultRole),
+	}
+	if role := directoryMapping.DefaultRole; role != "" && role != models.RoleAdmin && role != models.RoleManager &&
+		role != models.RoleEmployee && role != models.RoleGuest {
+		return nil, fmt.Errorf("invalid directory default role: %s", cfg.DirectoryDefaultRole)
+	}
+	for _, pair := range cfg.DirectoryDepartmentGroups {
+		group, department, ok := strings.Cut(pair, ":")
+		if !ok || group == "" || department == "" {
+			return nil, fmt.Errorf("invalid directory department group %q, expected group:department", pair)
+		}
+		directoryMapping.DepartmentGroups = append(directoryMapping.DepartmentGroups, services.DepartmentGroup{Group: group, Department: department})
+	}
+
+	// LDAP connection shared by LDAP login and the ldap directory sync
+	var ldapTLSConfig *tls.Config
+	if cfg.LDAPEnabled || (cfg.DirectorySyncEnabled && cfg.DirectorySyncProvider == "ldap") {
+		if cfg.LDAPURL == "" || cfg.LDAPBaseDN == "" {
+			return nil, fmt.Errorf("LDAP_URL and LDAP_BASE_DN are required for LDAP")
+		}
+		if strings.HasPrefix(strings.ToLower(cfg.LDAPURL), "ldap://") && !cfg.LDAPStartTLS {
+			return nil, fmt.Errorf("LDAP_START_TLS is required for ldap:// URLs")
+		}
+		ldapTLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.LDAPCACertPath != "" {
+			pem, err := os.ReadFile(cfg.LDAPCACertPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read LDAP CA certificate: %w", err)
+			}
+			ldapTLSConfig.RootCAs = x509.NewCertPool()
+			if !ldapTLSConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", cfg.LDAPCACertPath)
+			}
+		}
+	}
+
+	// Optional LDAP / Active Directory login
+	var ldapAuthService *services.LDAPAuthService
+	if cfg.LDAPEnabled {
+		if strings.Count(cfg.LDAPUserFilter, "%s") != 1 {
+			return nil, fmt.Errorf("LDAP_USER_FILTER must contain one %%s")
+		}
+		ldapAuthService = services.NewLDAPAuthService(userService, services.LDAPConfig{
+			URL:            cfg.LDAPURL,
+			StartTLS:       cfg.LDAPStartTLS,
+			TLSConfig:      ldapTLSConfig,
+			BindDN:         cfg.LDAPBindDN,
+			BindPassword:   cfg.LDAPBindPassword,
+			BaseDN:         cfg.LDAPBaseDN,
+			UserFilter:     cfg.LDAPUserFilter,
+			GroupAttribute: cfg.LDAPGroupAttribute,
+			Timeout:        time.Duration(cfg.LDAPTimeout) * time.Second,
+			Mapping:        directoryMapping,
+		})
+	}
+
+	passwordPolicyService, err := services.NewPasswordPolicyService(passwordService, services.PasswordPolicy{
+		MinLength:        cfg.PasswordMinLength,
+		RequireUppercase: cfg.PasswordRequireUppercase,
+		RequireLowercase: cfg.PasswordRequireLowercase,
+		RequireDigit:     cfg.PasswordRequireDigit,
+		RequireSymbol:    cfg.PasswordRequireSymbol,
+		HistoryDepth:     cfg.PasswordHistoryDepth,
+	}, cfg.PasswordBannedFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password policy: %w", err)
+	}
+
+	passwordChangeService := services.NewPasswordChangeService(passwordService, passwordPolicyService)
+
+	apiKeyService := services.NewAPIKeyService()
+	apiUsageService := services.NewAPIUsageService(int64(cfg.APIDailyRequestQuota), int64(cfg.APIDailyBandwidthQuotaMB))
+	// Optional OAuth clients for internal services
+	var oauthClientService *services.OAuthClientService
+	if cfg.OAuthEnabled {
+		if cfg.OAuthTokenTTL <= 0 {
+			return nil, fmt.Errorf("OAUTH_TOKEN_TTL must be positive")
+		}
+		if !tokenService.HasClientType(auth.ServiceClientType) {
+			return nil, fmt.Errorf("JWT_AUDIENCES needs an audience for the %s client type for OAuth clients", auth.ServiceClientType)
+		}
+		oauthClientService = services.NewOAuthClientService()
+	}
+
+	tokenDenylist := services.NewTokenDenylistService()
+	tokenDenylist.StartCleanup(time.Hour)
+
+	sessionService, err := services.NewSessionService(cfg.MaxSessions, services.SessionLimitAction(cfg.SessionLimitAction))
+	if err != nil {
+		return nil, fmt.Errorf("invalid session limit: %w", err)
+	}
+
+	// Outgoing email, when an SMTP server is configured
+	var smtpMailer *mailer.SMTPMailer
+	if cfg.SMTPHost != "" {
+		smtpMailer, err = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP configuration: %w", err)
+		}
+	}
+
+	// Optional password resets by email
+	var passwordResetService *services.PasswordResetService
+	if cfg.PasswordResetEnabled {
+		if smtpMailer == nil || cfg.PasswordResetURL == "" {
+			return nil, fmt.Errorf("SMTP_HOST and PASSWORD_RESET_URL are required for password resets")
+		}
+		passwordResetService = services.NewPasswordResetService(userService, passwordService, passwordPolicyService, smtpMailer, services.PasswordResetConfig{
+			Secret:     cfg.JWTSecret,
+			TTL:        time.Duration(cfg.PasswordResetTTL) * time.Minute,
+			ResetURL:   cfg.PasswordResetURL,
+			MaxPerHour: cfg.PasswordResetMaxPerHour,
+		})
+	}
+
+	// Optional emails about logins from new devices
+	var loginAlertService *services.LoginAlertService
+	if cfg.LoginAlertsEnabled {
+		if smtpMailer == nil {
+			return nil, fmt.Errorf("SMTP_HOST is required for login alerts")
+		}
+		loginAlertService = services.NewLoginAlertService(smtpMailer)
+	}
+
+	// Optional security keys and passkeys
+	var webauthnService *services.WebAuthnService
+	var recoveryCodeService *services.RecoveryCodeService
+	if cfg.WebAuthnEnabled {
+		if cfg.WebAuthnRPID == "" || len(cfg.WebAuthnOrigins) == 0 {
+			return nil, fmt.Errorf("WEBAUTHN_RP_ID and WEBAUTHN_ORIGINS are required for WebAuthn")
+		}
+		webauthnService = services.NewWebAuthnService(webauthn.RelyingParty{
+			ID:      cfg.WebAuthnRPID,
+			Name:    cfg.WebAuthnRPName,
+			Origins: cfg.WebAuthnOrigins,
+		}, time.Duration(cfg.WebAuthnTimeout)*time.Second)
+		recoveryCodeService = services.NewRecoveryCodeService()
+	}
+
+	// Optional sync of roles, departments and leavers with the directory
+	var directorySyncService *services.DirectorySyncService
+	if cfg.DirectorySyncEnabled {
+		var source directory.Source
+		switch cfg.DirectorySyncProvider {
+		case "ldap":
+			source = directory.NewLDAPSource(directory.LDAPConfig{
+				URL:               cfg.LDAPURL,
+				StartTLS:          cfg.LDAPStartTLS,
+				TLSConfig:         ldapTLSConfig,
+				BindDN:            cfg.LDAPBindDN,
+				BindPassword:      cfg.LDAPBindPassword,
+				BaseDN:            cfg.LDAPBaseDN,
+				Filter:            cfg.DirectorySyncLDAPFilter,
+				UsernameAttribute: cfg.DirectorySyncLDAPUsernameAttr,
+				GroupAttribute:    cfg.LDAPGroupAttribute,
+				Timeout:           time.Duration(cfg.LDAPTimeout) * time.Second,
+			})
+		case "okta":
+			if cfg.OktaOrgURL == "" || cfg.OktaAPIToken == "" {
+				return nil, fmt.Errorf("OKTA_ORG_URL and OKTA_API_TOKEN are required for Okta directory sync")
+			}
+			// Only the memberships of mapped groups are fetched
+			var groups []string
+			seen := make(map[string]bool)
+			addGroup := func(group string) {
+				if !seen[strings.ToLower(group)] {
+					seen[strings.ToLower(group)] = true
+					groups = append(groups, group)
+				}
+			}
+			for _, role := range []models.Role{models.RoleAdmin, models.RoleManager, models.RoleEmployee, models.RoleGuest} {
+				for _, group := range directoryMapping.RoleGroups[role] {
+					addGroup(group)
+				}
+			}
+			for _, mapping := range directoryMapping.DepartmentGroups {
+				addGroup(mapping.Group)
+			}
+			source = directory.NewOktaSource(cfg.OktaOrgURL, cfg.OktaAPIToken, groups)
+		default:
+			return nil, fmt.Errorf("unknown directory sync provider: %s", cfg.DirectorySyncProvider)
+		}
+		directorySyncService = services.NewDirectorySyncService(userService, source, directoryMapping,
+			time.Duration(cfg.DirectorySyncInterval)*time.Minute,
+			cfg.DirectorySyncDryRun)
+		directorySyncService.Start()
+	}
+
+	// Optional Elasticsearch index
+	var elasticClient *search.ElasticsearchClient
+	if cfg.ElasticsearchEnabled {
+		elasticClient = search.NewElasticsearchClient(cfg.ElasticsearchURL, cfg.ElasticsearchIndex, cfg.ElasticsearchUsername, cfg.ElasticsearchPassword)
+		if err := elasticClient.EnsureIndex(ctx); err != nil {
+			return nil, fmt.Errorf("failed to initialize search index: %w", err)
+		}
+		documentService.AddIndexer(elasticClient)
+	}
+	searchService := services.NewSearchService(elasticClient)
+
+	// Optional semantic search
+	var semanticService *services.SemanticSearchService
+	if cfg.SemanticSearchEnabled {
+		embeddingClient := search.NewEmbeddingClient(cfg.EmbeddingAPIURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel)
+		semanticService = services.NewSemanticSearchService(embeddingClient, cfg.EmbeddingDimensions)
+		if err := semanticService.EnsureSchema(); err != nil {
+			return nil, err
+		}
+		documentService.AddIndexer(semanticService)
+	}
+
+	// Optional auto-tagging of uploaded documents
+	var tagger tagging.Tagger
+	if cfg.AutoTagEnabled {
+		switch cfg.AutoTagProvider {
+		case "keywords":
+			tagger = tagging.NewKeywordTagger(cfg.AutoTagMaxTags)
+		case "model":
+			if cfg.AutoTagAPIURL == "" {
+				return nil, fmt.Errorf("AUTO_TAG_API_URL is required for the model auto-tag provider")
+			}
+			tagger = tagging.NewClassifierClient(cfg.AutoTagAPIURL, cfg.AutoTagAPIKey, cfg.AutoTagModel)
+		default:
+			return nil, fmt.Errorf("unknown auto-tag provider: %s", cfg.AutoTagProvider)
+		}
+	}
+	autoTagService := services.NewAutoTagService(documentService, tagger, cfg.AutoTagAutoApply, cfg.AutoTagMinScore)
+	if tagger != nil {
+		documentService.AddIndexer(autoTagService)
+	}
+	keyRotationService := services.NewKeyRotationService(envelopeService, auditService)
+
+	// Optional PDF conversion of office documents
+	var conversionService *services.ConversionService
+	if cfg.PDFConversionEnabled {
+		var pdfConverter converter.Converter
+		switch cfg.PDFConverter {
+		case "libreoffice":
+			pdfConverter = converter.NewLibreOfficeConverter(cfg.LibreOfficePath)
+		case "gotenberg":
+			pdfConverter = converter.NewGotenbergClient(cfg.GotenbergURL)
+		default:
+			return nil, fmt.Errorf("unknown PDF converter: %s", cfg.PDFConverter)
+		}
+		conversionService = services.NewConversionService(documentService, pdfConverter,
+			time.Duration(cfg.PDFConversionTimeout)*time.Second, cfg.PDFConvertOnUpload)
+		if cfg.PDFConvertOnUpload {
+			documentService.AddIndexer(conversionService)
+		}
+		log.Printf("PDF conversion enabled with %s", pdfConverter.Name())
+	}
+
+	// Optional watermarking of sensitive PDF downloads
+	var stamper watermark.Stamper
+	var watermarkLevels []models.AccessLevel
+	if cfg.WatermarkEnabled {
+		stamper = watermark.NewQPDFStamper(cfg.QPDFPath)
+		for _, level := range cfg.WatermarkAccessLevels {
+			watermarkLevels = append(watermarkLevels, models.AccessLevel(level))
+		}
+	}
+	watermarkService := services.NewWatermarkService(stamper, watermarkLevels)
+
+	// Optional data loss prevention scanning of uploads
+	var dlpDetector *dlp.Detector
+	dlpAction := models.DLPAction(cfg.DLPAction)
+	if cfg.DLPEnabled {
+		if dlpAction != models.DLPActionRaise && dlpAction != models.DLPActionBlock {
+			return nil, fmt.Errorf("unknown DLP action: %s", cfg.DLPAction)
+		}
+		dlpDetector = dlp.NewDetector(cfg.DLPRules, cfg.DLPKeywords)
+	}
+	classificationService := services.NewClassificationService()
+	dlpService := services.NewDLPService(dlpDetector, dlpAction, models.AccessLevel(cfg.DLPMinAccessLevel))
+
+	// Reading restricted documents needs a recent password or security key
+	stepUpPolicy := services.NewStepUpPolicy(time.Duration(cfg.StepUpMaxAge)*time.Minute, models.AccessLevel(cfg.StepUpAccessLevel))
+
+	// Downloading sensitive documents needs a stated reason
+	justificationPolicy := services.NewJustificationPolicy(models.AccessLevel(cfg.DownloadJustificationLevel))
+
+	exportService := services.NewExportService(documentService, authService, stepUpPolicy, justificationPolicy, watermarkService, notificationService, auditService, fileStorage, envelopeService, time.Duration(cfg.ExportTTL)*time.Hour, cfg.ExportMaxDocuments, int64(cfg.ExportMaxSizeMB)<<20)
+
+	documentIntake := services.NewDocumentIntake(documentService, folderService, authService, classificationService, dlpService, auditService, fileTypePolicy)
+	bulkUploadService := services.NewBulkUploadService(documentIntake, notificationService, auditService, cfg.BulkUploadMaxFiles, int64(cfg.BulkUploadMaxSizeMB)<<20)
+
+	// Optional imports from external sources
+	var importProviders []importer.Provider
+	if cfg.GoogleDriveImportEnabled {
+		if cfg.GoogleDriveClientID == "" || cfg.GoogleDriveClientSecret == "" {
+			return nil, fmt.Errorf("GOOGLE_DRIVE_CLIENT_ID and GOOGLE_DRIVE_CLIENT_SECRET are required for Google Drive imports")
+		}
+		importProviders = append(importProviders, importer.NewGoogleDriveProvider(cfg.GoogleDriveClientID, cfg.GoogleDriveClientSecret, cfg.ImportOAuthRedirectURL))
+	}
+	if cfg.SharePointImportEnabled {
+		if cfg.SharePointClientID == "" || cfg.SharePointClientSecret == "" {
+			return nil, fmt.Errorf("SHAREPOINT_CLIENT_ID and SHAREPOINT_CLIENT_SECRET are required for SharePoint imports")
+		}
+		importProviders = append(importProviders, importer.NewSharePointProvider(cfg.SharePointTenantID, cfg.SharePointClientID, cfg.SharePointClientSecret, cfg.ImportOAuthRedirectURL))
+	}
+	var importService *services.ImportService
+	if len(importProviders) > 0 {
+		importService = services.NewImportService(documentIntake, metadataService, notificationService, auditService, envelopeService, importProviders, cfg.ImportMaxFiles)
+		if err := importService.EnsureMetadataFields(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Optional ingestion of files deposited in drop locations
+	var ingestSources []services.IngestSource
+	if cfg.SFTPIngestEnabled {
+		if cfg.SFTPIngestOwner == "" {
+			return nil, fmt.Errorf("SFTP_INGEST_OWNER is required for SFTP ingestion")
+		}
+		if cfg.SFTPIngestAccessLevel < 0 || cfg.SFTPIngestAccessLevel > int(models.AccessTopSecret) {
+			return nil, fmt.Errorf("invalid SFTP ingest access level: %d", cfg.SFTPIngestAccessLevel)
+		}
+		drop, err := ingest.NewSFTPDrop("sftp", cfg.SFTPIngestAddress, cfg.SFTPIngestUser, cfg.SFTPIngestPassword, cfg.SFTPIngestKeyPath, cfg.SFTPIngestHostKey, cfg.SFTPIngestDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize SFTP ingestion: %w", err)
+		}
+		source := services.IngestSource{
+			Drop:        drop,
+			Owner:       cfg.SFTPIngestOwner,
+			Category:    cfg.SFTPIngestCategory,
+			AccessLevel: models.AccessLevel(cfg.SFTPIngestAccessLevel),
+		}
+		if cfg.SFTPIngestFolderID > 0 {
+			folderID := uint(cfg.SFTPIngestFolderID)
+			source.FolderID = &folderID
+		}
+		ingestSources = append(ingestSources, source)
+	}
+	if cfg.WatchFoldersFile != "" {
+		watchFolders, err := ingest.LoadWatchFolders(cfg.WatchFoldersFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, folder := range watchFolders {
+			if folder.AccessLevel < 0 || folder.AccessLevel > int(models.AccessTopSecret) {
+				return nil, fmt.Errorf("invalid access level of watch folder %q: %d", folder.Name, folder.AccessLevel)
+			}
+			source := services.IngestSource{
+				Drop:        ingest.NewLocalDrop(folder.Name, folder.Path),
+				Owner:       folder.Owner,
+				Category:    folder.Category,
+				Department:  folder.Department,
+				AccessLevel: models.AccessLevel(folder.AccessLevel),
+			}
+			if folder.FolderID > 0 {
+				folderID := folder.FolderID
+				source.FolderID = &folderID
+			}
+			ingestSources = append(ingestSources, source)
+		}
+	}
+	var emailIngest *services.EmailIngest
+	if cfg.EmailIngestEnabled {
+		if cfg.EmailIngestAddress == "" || cfg.EmailIngestWebhookSecret == "" {
+			return nil, fmt.Errorf("EMAIL_INGEST_ADDRESS and EMAIL_INGEST_WEBHOOK_SECRET are required for email ingestion")
+		}
+		if cfg.EmailIngestAccessLevel < 0 || cfg.EmailIngestAccessLevel > int(models.AccessTopSecret) {
+			return nil, fmt.Errorf("invalid email ingest access level: %d", cfg.EmailIngestAccessLevel)
+		}
+		emailIngest = &services.EmailIngest{
+			Address:     cfg.EmailIngestAddress,
+			Category:    cfg.EmailIngestCategory,
+			AccessLevel: models.AccessLevel(cfg.EmailIngestAccessLevel),
+		}
+		if cfg.EmailIngestFolderID > 0 {
+			folderID := uint(cfg.EmailIngestFolderID)
+			emailIngest.FolderID = &folderID
+		}
+	}
+	var ingestService *services.IngestService
+	if len(ingestSources) > 0 || emailIngest != nil {
+		ingestService = services.NewIngestService(documentIntake, userService, notificationService, ingestSources, emailIngest,
+			time.Duration(cfg.IngestInterval)*time.Second,
+			time.Duration(cfg.IngestMinAge)*time.Second,
+			int64(cfg.IngestMaxFileSizeMB)<<20)
+	}
+
+	// Optional virus scanning of uploaded content
+	var virusScanner scanner.Scanner
+	if cfg.VirusScanEnabled {
+		switch cfg.VirusScanProvider {
+		case "clamav":
+			virusScanner = scanner.NewClamAVScanner(cfg.ClamAVAddress, time.Minute)
+		default:
+			return nil, fmt.Errorf("unknown virus scan provider: %s", cfg.VirusScanProvider)
+		}
+		documentService.EnableScanning()
+	}
+
+	// Optional links sharing documents with people without an account
+	var shareLinkService *services.ShareLinkService
+	if cfg.ShareLinksEnabled {
+		shareLinkService = services.NewShareLinkService(passwordService, time.Duration(cfg.ShareLinkMaxDays)*24*time.Hour)
+	}
+
+	var timestampService *services.TimestampService
+	if cfg.TimestampAuthorityURL != "" {
+		roots, err := loadCertPool(cfg.TimestampCACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timestamping CA certificates: %w", err)
+		}
+		// Tokens are always checked against a chain of trust
+		if roots == nil {
+			if roots, err = x509.SystemCertPool(); err != nil {
+				return nil, fmt.Errorf("TIMESTAMP_CA_CERT_PATH is required without system CA certificates: %w", err)
+			}
+		}
+		timestampService = services.NewTimestampService(timestamp.NewClient(cfg.TimestampAuthorityURL), roots)
+	}
+
+	var documentSigner *signature.Signer
+	if cfg.SigningCertPath != "" {
+		if documentSigner, err = signature.LoadSigner(cfg.SigningCertPath, cfg.SigningKeyPath); err != nil {
+			return nil, fmt.Errorf("failed to load document signing certificate: %w", err)
+		}
+	}
+	signingRoots, err := loadCertPool(cfg.SigningCACertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing CA certificates: %w", err)
+	}
+
+	// Internal CA issuing users signing certificates, which signer
+	// certificates may chain to as well
+	var certificateService *services.CertificateService
+	if cfg.PKICACertPath != "" {
+		ca, err := signature.LoadCA(cfg.PKICACertPath, cfg.PKICAKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load PKI CA certificate: %w", err)
+		}
+		certificateService = services.NewCertificateService(ca, envelopeService,
+			time.Duration(cfg.PKICertValidity)*24*time.Hour,
+			time.Duration(cfg.PKICRLValidity)*time.Hour,
+			time.Duration(cfg.PKIRenewalWindow)*24*time.Hour)
+		if signingRoots != nil {
+			signingRoots.AddCert(ca.Certificates()[0])
+		}
+	}
+	signatureService := services.NewSignatureService(documentService, certificateService, documentSigner, signingRoots)
+
+	// Audit exports signed with the server's certificate
+	var auditExportService *services.AuditExportService
+	if documentSigner != nil {
+		auditExportService = services.NewAuditExportService(auditService, documentSigner, signingRoots)
+	}
+
+	// Optional CDN downloads of public and internal documents
+	var cdnService *services.CDNService
+	if cfg.CDNEnabled {
+		signer, err := cdn.NewURLSigner(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize CDN signer: %w", err)
+		}
+		cdnService = services.NewCDNService(documentService, signer, cfg.CDNBaseURL, time.Duration(cfg.CDNURLTTL)*time.Minute)
+		log.Printf("CDN downloads enabled through %s", signer.Name())
+	}
+
+	var blockchainService *services.BlockchainService
+	var timestampClient *blockchain.TimestampClient
+	if cfg.BlockchainEnabled {
+		var authority *blockchain.Authority
+		switch cfg.BlockchainConsensus {
+		case blockchain.ConsensusProofOfWork:
+		case blockchain.ConsensusProofOfAuthority:
+			if authority, err = blockchain.LoadAuthority(cfg.BlockchainSigningKeyPath); err != nil {
+				return nil, fmt.Errorf("failed to initialize blockchain authority: %w", err)
+			}
+			log.Printf("Blockchain blocks signed with key %s", authority.KeyID())
+		default:
+			return nil, fmt.Errorf("unknown blockchain consensus %q", cfg.BlockchainConsensus)
+		}
+
+		blockchainService, err = services.NewBlockchainService(time.Duration(cfg.BlockchainMineInterval)*time.Second, cfg.BlockchainBlockSize, time.Duration(cfg.BlockchainTargetMiningTime)*time.Millisecond, cfg.BlockchainMaxDifficulty, authority)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize blockchain: %w", err)
+		}
+		blockchainService.Start()
+
+		if cfg.BlockchainAnchorCalendars != "" {
+			timestampClient = blockchain.NewTimestampClient(strings.Split(cfg.BlockchainAnchorCalendars, ","))
+			if cfg.BlockchainAnchorInterval > 0 {
+				blockchainService.StartAnchoring(timestampClient, time.Duration(cfg.BlockchainAnchorInterval)*time.Minute)
+			}
+		}
+		if cfg.BlockchainReconcileInterval > 0 {
+			blockchainService.StartReconciliation(time.Duration(cfg.BlockchainReconcileInterval) * time.Minute)
+		}
+	}
+
+	// Deliver audit entries and blockchain transactions written to the outbox
+	outboxDispatcher := services.NewOutboxDispatcher(auditService, blockchainService, time.Duration(cfg.OutboxDispatchInterval)*time.Second, cfg.OutboxMaxAttempts)
+	outboxDispatcher.Start()
+
+	// Link documents tagged before document_tags existed
+	tagService := services.NewTagService()
+	if err := tagService.MigrateLegacyTags(); err != nil {
+		return nil, err
+	}
+
+	// Move files stored before content-addressable storage into blobs
+	if err := documentService.MigrateBlobs(); err != nil {
+		return nil, err
+	}
+
+	// Load rotated master keys into the keyring
+	if err := keyRotationService.LoadKeys(); err != nil {
+		return nil, fmt.Errorf("failed to load encryption keys: %w", err)
+	}
+
+	// Purge documents left in the trash past the retention window
+	if cfg.TrashRetentionDays > 0 {
+		retention := time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+		interval := time.Duration(cfg.TrashPurgeInterval) * time.Minute
+		services.NewTrashPurgeService(documentService, auditService, retention, interval).Start()
+	}
+
+	// Move audit entries past the retention window to the archive store;
+	// archives stay retrievable when retention is turned off again
+	archiveStore, err := archive.NewStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit archive configuration: %w", err)
+	}
+	auditArchiveService := services.NewAuditArchiveService(archiveStore, auditService,
+		time.Duration(cfg.AuditRetentionDays)*24*time.Hour, time.Duration(cfg.AuditArchiveInterval)*time.Minute)
+	if cfg.AuditRetentionDays > 0 {
+		auditArchiveService.Start()
+		log.Printf("Audit archival enabled with %s storage after %d days", archiveStore.Name(), cfg.AuditRetentionDays)
+	}
+
+	// Create monthly audit log partitions ahead of time and drop the ones
+	// archival emptied
+	services.NewAuditPartitionService(time.Duration(cfg.AuditRetentionDays)*24*time.Hour,
+		time.Duration(cfg.AuditPartitionInterval)*time.Minute).Start()
+
+	// Stream every audit event to a SIEM
+	if cfg.SIEMSink != "" {
+		sink, err := siem.NewSink(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SIEM configuration: %w", err)
+		}
+		streamer := siem.NewStreamer(sink, cfg.SIEMBufferSize)
+		streamer.Start()
+		auditService.AddObserver(services.NewAuditStreamService(streamer))
+		log.Printf("SIEM streaming enabled with %s", sink.Name())
+	}
+
+	// Raise security alerts on suspicious activity in the audit log
+	location, err := time.LoadLocation(cfg.SecurityTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid security timezone: %w", err)
+	}
+	var securityAlertNotifications *services.SecurityAlertNotificationService
+	if len(cfg.SecurityAlertChannels) > 0 {
+		var alertMailer mailer.Mailer
+		if smtpMailer != nil {
+			alertMailer = smtpMailer
+		}
+		notifiers, err := alerting.NewNotifiers(cfg, alertMailer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid security alert configuration: %w", err)
+		}
+		securityAlertNotifications, err = services.NewSecurityAlertNotificationService(notifiers, models.AlertSeverity(cfg.SecurityAlertMinSeverity), cfg.SecurityAlertURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid security alert configuration: %w", err)
+		}
+		log.Printf("Security alerts notified over %s", strings.Join(cfg.SecurityAlertChannels, ", "))
+	}
+	securityDetectionService := services.NewSecurityDetectionService(roleService, auditService, blockchainService, securityAlertNotifications, services.SecurityDetectionConfig{
+		Interval:              time.Duration(cfg.SecurityDetectionInterval) * time.Minute,
+		FailedLoginThreshold:  cfg.SecurityFailedLoginThreshold,
+		MassDownloadThreshold: cfg.SecurityMassDownloadThreshold,
+		BusinessHoursStart:    cfg.SecurityBusinessHoursStart,
+		BusinessHoursEnd:      cfg.SecurityBusinessHoursEnd,
+		Location:              location,
+	})
+	if cfg.SecurityDetectionInterval > 0 {
+		securityDetectionService.Start()
+	}
+
+	// Re-hash stored files and flag documents whose files don't match
+	integrityService := services.NewIntegrityService(documentService, blockchainService, auditService, time.Duration(cfg.IntegrityCheckInterval)*time.Minute)
+	if cfg.IntegrityCheckInterval > 0 {
+		integrityService.Start()
+	}
+
+	// Collect signatures of several users on documents, emailing signers
+	// when SMTP is configured
+	var signingMailer mailer.Mailer
+	if smtpMailer != nil {
+		signingMailer = smtpMailer
+	}
+	signingWorkflowService := services.NewSigningWorkflowService(documentService, signatureService, authService, notificationService, blockchainService, signingMailer)
+
+	// Scan uploaded content for malware
+	if virusScanner != nil {
+		interval := time.Duration(cfg.VirusScanInterval) * time.Second
+		services.NewVirusScanService(documentService, virusScanner, notificationService, auditService, interval).Start()
+		log.Printf("Virus scanning enabled with %s", virusScanner.Name())
+	}
+
+	// Discard upload sessions abandoned before completion
+	uploadService.StartCleanup(time.Hour)
+
+	// Archives of bulk uploads in progress were lost with the previous process
+	if err := bulkUploadService.FailInterrupted(); err != nil {
+		return nil, err
+	}
+
+	// Exports in progress were lost too; finished archives are deleted once expired
+	if err := exportService.FailInterrupted(); err != nil {
+		return nil, err
+	}
+	exportService.StartCleanup(time.Hour)
+
+	// Imports in progress stopped with the previous process
+	if importService != nil {
+		if err := importService.FailInterrupted(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Collect files waiting in drop locations
+	if len(ingestSources) > 0 {
+		ingestService.Start()
+		log.Printf("Ingestion enabled from %d drop locations", len(ingestSources))
+	}
+
+	uploadSizeLimit := middleware.UploadSizeLimit(map[models.Role]int64{
+		models.RoleAdmin:    int64(cfg.UploadMaxSizeAdminMB) << 20,
+		models.RoleManager:  int64(cfg.UploadMaxSizeManagerMB) << 20,
+		models.RoleEmployee: int64(cfg.UploadMaxSizeEmployeeMB) << 20,
+		models.RoleGuest:    int64(cfg.UploadMaxSizeGuestMB) << 20,
+	}, int64(cfg.UploadMaxSizeEmployeeMB)<<20)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(tokenService, passwordService, userService, auditService)
+	authHandler := handlers.NewAuthHandler(tokenService, passwordService, passwordPolicyService, passwordChangeService, userService, roleService, sessionService, tokenDenylist, registrationService, ldapAuthService, webauthnService, recoveryCodeService, loginAlertService, stepUpPolicy, auditService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, apiUsageService, auditService)
+	oauthHandler := handlers.NewOAuthHandler(oauthClientService, apiUsageService, roleService, tokenService, auditService, time.Duration(cfg.OAuthTokenTTL)*time.Minute)
+	documentHandler := handlers.NewDocumentHandler(documentService, folderService, searchService, semanticService, autoTagService, metadataService, commentService, subscriptionService, uploadService, bulkUploadService, exportService, importService, fileTypePolicy, watermarkService, dlpService, classificationService, authService, roleService, stepUpPolicy, justificationPolicy, auditService, blockchainService)
+	roleHandler := handlers.NewRoleHandler(roleService, userService, auditService)
+	groupHandler := handlers.NewGroupHandler(groupService, userService, auditService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, userService, auditService)
+	folderHandler := handlers.NewFolderHandler(folderService, authService, roleService, auditService, subscriptionService)
+	categoryHandler := handlers.NewCategoryHandler(categoryService, auditService)
+	tagHandler := handlers.NewTagHandler(tagService)
+	metadataHandler := handlers.NewMetadataHandler(metadataService, auditService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionService, documentService, folderService, authService)
+	favoriteHandler := handlers.NewFavoriteHandler(favoriteService, documentService, authService)
+	activityHandler := handlers.NewActivityHandler(auditService)
+	justificationHandler := handlers.NewJustificationHandler(auditService)
+	statsHandler := handlers.NewStatsHandler(statsService)
+	keyHandler := handlers.NewKeyHandler(keyRotationService)
+	dlpHandler := handlers.NewDLPHandler(dlpService)
+	securityAlertHandler := handlers.NewSecurityAlertHandler(securityDetectionService, auditService)
+	outboxHandler := handlers.NewOutboxHandler(outboxDispatcher, auditService)
+	integrityHandler := handlers.NewIntegrityHandler(integrityService, auditService)
+	directorySyncHandler := handlers.NewDirectorySyncHandler(directorySyncService, auditService)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService, auditService)
+	ingestHandler := handlers.NewIngestHandler(ingestService, auditService, cfg.EmailIngestWebhookSecret, cfg.EmailIngestRequireSenderAuth, int64(cfg.EmailIngestMaxSizeMB)<<20)
+	classificationHandler := handlers.NewClassificationHandler(classificationService, auditService)
+	accessReportHandler := handlers.NewAccessReportHandler(authService, documentService, userService, auditService)
+	conversionHandler := handlers.NewConversionHandler(conversionService, documentService, watermarkService, authService, stepUpPolicy, justificationPolicy, auditService)
+	publicHandler := handlers.NewPublicHandler(documentService, organizationService, watermarkService, auditService)
+	shareLinkHandler := handlers.NewShareLinkHandler(shareLinkService, documentService, watermarkService, authService, stepUpPolicy, justificationPolicy, auditService)
+	timestampHandler := handlers.NewTimestampHandler(timestampService, documentService, blockchainService, authService, auditService)
+	signatureHandler := handlers.NewSignatureHandler(signatureService, documentService, authService, stepUpPolicy, auditService)
+	auditHandler := handlers.NewAuditHandler(auditExportService, auditArchiveService, auditService)
+	certificateHandler := handlers.NewCertificateHandler(certificateService, stepUpPolicy, auditService)
+	signingWorkflowHandler := handlers.NewSigningWorkflowHandler(signingWorkflowService, documentService, authService, stepUpPolicy, auditService)
+	blockchainHandler := handlers.NewBlockchainHandler(blockchainService, timestampClient, documentService, authService, auditService)
+	cdnHandler := handlers.NewCDNHandler(cdnService, documentService, watermarkService, authService, stepUpPolicy, justificationPolicy, auditService, cfg.CDNOriginSecret)
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -44,6 +820,11 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		})
 	})
 
+	// CDN origin, fetched by the CDN with the shared origin secret
+	if cdnService != nil {
+		router.GET("/cdn/blobs/:hash/:filename", cdnHandler.ServeBlob)
+	}
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
@@ -52,17 +833,193 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		{
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.GET("/password-policy", authHandler.GetPasswordPolicy)
+			if cfg.RegistrationEnabled {
+				auth.POST("/register", authHandler.Register)
+			}
+			if passwordResetService != nil {
+				resetLimit := middleware.RouteRateLimit(10, time.Hour)
+				auth.POST("/password/forgot", resetLimit, passwordResetHandler.ForgotPassword)
+				auth.POST("/password/reset", resetLimit, passwordResetHandler.ResetPassword)
+			}
+			if webauthnService != nil {
+				// Confirms a password, or logs in alone with a passkey
+				auth.POST("/webauthn/login/finish", authHandler.FinishWebAuthnLogin)
+				auth.POST("/webauthn/login/recovery", authHandler.LoginWithRecoveryCode)
+				if cfg.WebAuthnPasswordless {
+					auth.POST("/webauthn/login/begin", authHandler.BeginWebAuthnLogin)
+				}
+			}
+		}
+
+		// Public documents, read by anyone without signing in
+		if cfg.PublicPortalEnabled {
+			public := v1.Group("/public/documents")
+			public.Use(middleware.RouteRateLimit(cfg.PublicPortalRateLimit, time.Minute))
+			{
+				public.GET("", publicHandler.GetDocuments)
+				public.GET("/:id", publicHandler.GetDocument)
+				public.GET("/:id/download", publicHandler.DownloadDocument)
+			}
+		}
+
+		// Downloads through share links, which carry their own token and
+		// password; the rate limit slows down guessing passwords
+		if shareLinkService != nil {
+			share := v1.Group("/share/:token")
+			share.Use(middleware.RouteRateLimit(30, time.Minute))
+			{
+				share.GET("", shareLinkHandler.DownloadSharedDocument)
+				share.POST("", shareLinkHandler.DownloadSharedDocument)
+			}
+		}
+
+		// Certificate, revocation list and certificate status of the internal CA,
+		// fetched by anyone checking signatures
+		if certificateService != nil {
+			pki := v1.Group("/pki")
+			{
+				pki.GET("/ca.pem", certificateHandler.GetCACertificate)
+				pki.GET("/crl", certificateHandler.GetRevocationList)
+				pki.GET("/status/:serial", certificateHandler.GetCertificateStatus)
+			}
+		}
+
+		// Token endpoint of OAuth clients, which authenticate with their secret
+		if oauthClientService != nil {
+			v1.POST("/oauth/token", middleware.RouteRateLimit(60, time.Minute), oauthHandler.Token)
+		}
+
+		// Inbound email webhook, authenticated with its own secret
+		if emailIngest != nil {
+			v1.POST("/inbound-email", ingestHandler.ReceiveEmail)
+		}
+
+		// Redirect target of import providers after the user granted access
+		if importService != nil {
+			v1.GET("/imports/oauth/callback", documentHandler.CompleteImportAuthorization)
 		}
 
 		// Protected routes (authentication required)
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(tokenService, userService))
+		protected.Use(middleware.AuthMiddleware(tokenService, userService, tokenDenylist, apiKeyService))
+		protected.Use(middleware.RequirePasswordChanged("/api/v1/auth/profile", "/api/v1/auth/logout", "/api/v1/auth/logout-all", "/api/v1/auth/change-password"))
+		protected.Use(middleware.APIQuota(apiUsageService))
+		// Guests only read, though they manage their own account, notifications,
+		// favorites and subscriptions, and export documents they can read
+		protected.Use(middleware.GuestReadOnly("/api/v1/auth/", "/api/v1/notifications/", "/api/v1/favorites", "/api/v1/subscriptions", "/api/v1/exports"))
 		{
 			// Auth routes
 			authProtected := protected.Group("/auth")
 			{
 				authProtected.POST("/logout", authHandler.Logout)
 				authProtected.GET("/profile", authHandler.GetProfile)
+				authProtected.POST("/change-password", authHandler.ChangePassword)
+				authProtected.GET("/login-history", authHandler.GetLoginHistory)
+				authProtected.GET("/sessions", authHandler.GetSessions)
+				authProtected.DELETE("/sessions/:id", authHandler.RevokeSession)
+				authProtected.POST("/logout-all", authHandler.LogoutAll)
+				authProtected.POST("/step-up", middleware.RouteRateLimit(10, time.Minute), authHandler.StepUp)
+				authProtected.GET("/api-keys", apiKeyHandler.GetAPIKeys)
+				authProtected.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+				authProtected.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey)
+				authProtected.GET("/api-keys/:id/usage", apiKeyHandler.GetAPIKeyUsage)
+				if loginAlertService != nil {
+					authProtected.PUT("/login-alerts", authHandler.SetLoginAlerts)
+				}
+				if webauthnService != nil {
+					authProtected.POST("/webauthn/register/begin", authHandler.BeginWebAuthnRegistration)
+					authProtected.POST("/webauthn/register/finish", authHandler.FinishWebAuthnRegistration)
+					authProtected.GET("/webauthn/credentials", authHandler.GetWebAuthnCredentials)
+					authProtected.PATCH("/webauthn/credentials/:id", authHandler.RenameWebAuthnCredential)
+					authProtected.DELETE("/webauthn/credentials/:id", authHandler.DeleteWebAuthnCredential)
+					authProtected.GET("/webauthn/recovery-codes", authHandler.GetRecoveryCodes)
+					authProtected.POST("/webauthn/recovery-codes", authHandler.RegenerateRecoveryCodes)
+					authProtected.POST("/step-up/webauthn/begin", authHandler.BeginWebAuthnStepUp)
+					authProtected.POST("/step-up/webauthn/finish", authHandler.FinishWebAuthnStepUp)
+				}
+			}
+
+			// Admin routes, each needing a capability of the user's role
+			admin := protected.Group("/admin")
+			{
+				// The master key, role definitions and organizations are shared
+				// by every organization, so only the default organization
+				// manages them
+				defaultOrganization := middleware.RequireDefaultOrganization(organizationService)
+
+				manageKeys := middleware.RequireCapability(roleService, models.CapabilityManageKeys)
+				admin.POST("/keys/rotate", manageKeys, defaultOrganization, keyHandler.RotateKey)
+				admin.GET("/keys/rotations", manageKeys, defaultOrganization, keyHandler.GetRotationJobs)
+				if certificateService != nil {
+					admin.GET("/certificates", manageKeys, certificateHandler.GetOrganizationCertificates)
+					admin.POST("/certificates/:id/revoke", manageKeys, certificateHandler.RevokeUserCertificate)
+				}
+				admin.GET("/keys/rotations/:id", manageKeys, defaultOrganization, keyHandler.GetRotationJob)
+
+				manageRoles := middleware.RequireCapability(roleService, models.CapabilityManageRoles)
+				admin.GET("/roles", manageRoles, roleHandler.GetRoles)
+				admin.POST("/roles", manageRoles, defaultOrganization, roleHandler.CreateRole)
+				admin.PUT("/roles/:id", manageRoles, defaultOrganization, roleHandler.UpdateRole)
+				admin.DELETE("/roles/:id", manageRoles, defaultOrganization, roleHandler.DeleteRole)
+				admin.PUT("/users/:id/role", manageRoles, roleHandler.SetUserRole)
+
+				manageUsers := middleware.RequireCapability(roleService, models.CapabilityManageUsers)
+				admin.GET("/registrations", manageUsers, authHandler.GetRegistrations)
+				admin.POST("/registrations/:id/approve", manageUsers, authHandler.ApproveRegistration)
+				admin.POST("/registrations/:id/reject", manageUsers, authHandler.RejectRegistration)
+				admin.POST("/users/:id/force-password-reset", manageUsers, authHandler.ForcePasswordReset)
+				admin.GET("/users/:id/sessions", manageUsers, authHandler.GetUserSessions)
+				admin.DELETE("/users/:id/sessions/:sessionId", manageUsers, authHandler.RevokeUserSession)
+				admin.POST("/users/:id/logout-all", manageUsers, authHandler.LogoutUserEverywhere)
+				if directorySyncService != nil {
+					admin.POST("/directory-sync", manageUsers, directorySyncHandler.RunSync)
+					admin.GET("/directory-sync/runs", manageUsers, directorySyncHandler.GetRuns)
+					admin.GET("/directory-sync/runs/:id", manageUsers, directorySyncHandler.GetRun)
+				}
+
+				manageAPIClients := middleware.RequireCapability(roleService, models.CapabilityManageAPIClients)
+				admin.GET("/api-keys/:id/usage", manageAPIClients, apiKeyHandler.GetUserAPIKeyUsage)
+				admin.PUT("/api-keys/:id/quota", manageAPIClients, apiKeyHandler.SetAPIKeyQuota)
+				if oauthClientService != nil {
+					admin.GET("/oauth-clients", manageAPIClients, oauthHandler.GetClients)
+					admin.POST("/oauth-clients", manageAPIClients, oauthHandler.CreateClient)
+					admin.DELETE("/oauth-clients/:id", manageAPIClients, oauthHandler.RevokeClient)
+					admin.GET("/oauth-clients/:id/usage", manageAPIClients, oauthHandler.GetClientUsage)
+					admin.PUT("/oauth-clients/:id/quota", manageAPIClients, oauthHandler.SetClientQuota)
+				}
+
+				managePermissions := middleware.RequireCapability(roleService, models.CapabilityManagePermissions)
+				admin.GET("/access-report/documents/:id", managePermissions, accessReportHandler.GetDocumentAccess)
+				admin.GET("/access-report/users/:id", managePermissions, accessReportHandler.GetUserAccess)
+
+				viewAudit := middleware.RequireCapability(roleService, models.CapabilityViewAudit)
+				admin.GET("/dlp/findings", viewAudit, dlpHandler.GetFindings)
+				admin.GET("/security-alerts", viewAudit, securityAlertHandler.GetAlerts)
+				admin.GET("/security-alerts/:id", viewAudit, securityAlertHandler.GetAlert)
+				admin.POST("/security-alerts/:id/acknowledge", viewAudit, securityAlertHandler.AcknowledgeAlert)
+				admin.POST("/security-alerts/:id/resolve", viewAudit, securityAlertHandler.ResolveAlert)
+				admin.GET("/download-justifications", viewAudit, justificationHandler.GetDownloadJustifications)
+				admin.GET("/integrity", viewAudit, integrityHandler.GetReport)
+				admin.POST("/integrity/verify", viewAudit, integrityHandler.VerifyDocuments)
+				if ingestService != nil {
+					admin.GET("/ingested-files", viewAudit, ingestHandler.GetIngestedFiles)
+				}
+
+				manageClassification := middleware.RequireCapability(roleService, models.CapabilityManageClassification)
+				admin.GET("/classification-rules", manageClassification, classificationHandler.GetRules)
+				admin.POST("/classification-rules", manageClassification, classificationHandler.CreateRule)
+				admin.PUT("/classification-rules/:id", manageClassification, classificationHandler.UpdateRule)
+				admin.DELETE("/classification-rules/:id", manageClassification, classificationHandler.DeleteRule)
+
+				manageOrganizations := middleware.RequireCapability(roleService, models.CapabilityManageOrganizations)
+				admin.GET("/organizations", manageOrganizations, defaultOrganization, organizationHandler.GetOrganizations)
+				admin.POST("/organizations", manageOrganizations, defaultOrganization, organizationHandler.CreateOrganization)
+				admin.PUT("/organizations/:id", manageOrganizations, defaultOrganization, organizationHandler.RenameOrganization)
+				admin.PUT("/users/:id/organization", manageOrganizations, defaultOrganization, organizationHandler.SetUserOrganization)
+				// The outbox holds the events of every organization
+				admin.GET("/outbox", manageOrganizations, defaultOrganization, outboxHandler.GetOutbox)
+				admin.POST("/outbox/:id/retry", manageOrganizations, defaultOrganization, outboxHandler.RetryEvent)
 			}
 
 			// TODO: Implement additional handlers
@@ -75,20 +1032,271 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			// }
 
 			// Document management routes
-			// documents := protected.Group("/documents")
-			// {
-			// 	documents.GET("", documentHandler.GetDocuments)
-			// 	documents.POST("", documentHandler.CreateDocument)
-			// }
+			documents := protected.Group("/documents")
+			{
+				documents.GET("", documentHandler.GetDocuments)
+				documents.POST("", uploadSizeLimit, documentHandler.CreateDocument)
+				documents.POST("/classify", uploadSizeLimit, documentHandler.ClassifyDocument)
+				documents.POST("/batch", documentHandler.BatchDocuments)
+				documents.GET("/search", documentHandler.SearchDocuments)
+				documents.GET("/semantic-search", documentHandler.SemanticSearchDocuments)
+				documents.GET("/trash", documentHandler.GetTrash)
+				documents.GET("/:id", documentHandler.GetDocument)
+				documents.PUT("/:id", documentHandler.UpdateDocument)
+				documents.DELETE("/:id", documentHandler.DeleteDocument)
+				documents.POST("/:id/restore", documentHandler.RestoreDocument)
+				documents.DELETE("/:id/purge", middleware.RequireCapability(roleService, models.CapabilityPurgeDocuments), documentHandler.PurgeDocument)
+				documents.GET("/:id/download", documentHandler.DownloadDocument)
+				if cdnService != nil {
+					documents.GET("/:id/cdn-url", cdnHandler.GetDownloadURL)
+				}
+				if conversionService != nil {
+					documents.GET("/:id/pdf", conversionHandler.GetPDF)
+				}
+				if shareLinkService != nil {
+					documents.GET("/:id/share-links", shareLinkHandler.GetShareLinks)
+					documents.POST("/:id/share-links", shareLinkHandler.CreateShareLink)
+					documents.DELETE("/:id/share-links/:linkId", shareLinkHandler.RevokeShareLink)
+				}
+				if timestampService != nil {
+					documents.GET("/:id/timestamps", timestampHandler.GetDocumentTimestamps)
+					documents.POST("/:id/timestamps", timestampHandler.StampDocument)
+					documents.GET("/:id/timestamps/:timestampId/token", timestampHandler.DownloadDocumentToken)
+				}
+				documents.POST("/:id/move", documentHandler.MoveDocument)
+				documents.POST("/:id/copy", documentHandler.CopyDocument)
+				documents.GET("/:id/versions", documentHandler.GetVersions)
+				documents.POST("/:id/versions", uploadSizeLimit, documentHandler.CreateVersion)
+				documents.POST("/:id/versions/:version/restore", documentHandler.RestoreVersion)
+				documents.POST("/:id/versions/:version/signatures", signatureHandler.SignVersion)
+				documents.GET("/:id/versions/:version/diff/:other", documentHandler.DiffVersions)
+				documents.GET("/:id/signatures", signatureHandler.GetSignatures)
+				documents.POST("/:id/signatures/:signatureId/verify", signatureHandler.VerifySignature)
+				documents.POST("/:id/signing-workflows", signingWorkflowHandler.CreateWorkflow)
+				documents.GET("/:id/signing-workflows", signingWorkflowHandler.GetWorkflows)
+				documents.GET("/:id/signing-workflows/:workflowId", signingWorkflowHandler.GetWorkflow)
+				documents.POST("/:id/signing-workflows/:workflowId/sign", signingWorkflowHandler.Sign)
+				documents.POST("/:id/signing-workflows/:workflowId/decline", signingWorkflowHandler.Decline)
+				documents.POST("/:id/signing-workflows/:workflowId/cancel", signingWorkflowHandler.Cancel)
+				documents.PUT("/:id/tags", documentHandler.SetTags)
+				documents.POST("/:id/tags", documentHandler.AddTags)
+				documents.DELETE("/:id/tags/:tag", documentHandler.RemoveTag)
+				documents.GET("/:id/comments", documentHandler.GetComments)
+				documents.POST("/:id/comments", documentHandler.CreateComment)
+				documents.PUT("/:id/comments/:commentId", documentHandler.UpdateComment)
+				documents.DELETE("/:id/comments/:commentId", documentHandler.DeleteComment)
+				documents.GET("/:id/metadata", documentHandler.GetMetadata)
+				documents.PUT("/:id/metadata", documentHandler.SetMetadata)
+				documents.GET("/:id/suggestions", documentHandler.GetSuggestions)
+				documents.POST("/:id/suggestions/:suggestionId/accept", documentHandler.AcceptSuggestion)
+				documents.POST("/:id/suggestions/:suggestionId/reject", documentHandler.RejectSuggestion)
+				documents.GET("/:id/permissions", documentHandler.GetPermissions)
+				documents.POST("/:id/permissions", documentHandler.SetPermission)
+				documents.DELETE("/:id/permissions/:permissionId", documentHandler.RevokePermission)
+			}
+
+			// Chunked upload routes
+			uploads := protected.Group("/uploads")
+			{
+				uploads.POST("", uploadSizeLimit, documentHandler.CreateUpload)
+				uploads.GET("/:id", documentHandler.GetUpload)
+				uploads.PATCH("/:id", documentHandler.UploadChunk)
+				uploads.POST("/:id/complete", documentHandler.CompleteUpload)
+				uploads.DELETE("/:id", documentHandler.AbortUpload)
+			}
+
+			// Bulk upload routes
+			bulkUploads := protected.Group("/bulk-uploads")
+			{
+				bulkUploads.GET("", documentHandler.GetBulkUploads)
+				bulkUploads.POST("", uploadSizeLimit, documentHandler.CreateBulkUpload)
+				bulkUploads.GET("/:id", documentHandler.GetBulkUpload)
+			}
+
+			// Export routes
+			exports := protected.Group("/exports")
+			{
+				exports.GET("", documentHandler.GetExports)
+				exports.POST("", documentHandler.CreateExport)
+				exports.GET("/:id", documentHandler.GetExport)
+				exports.GET("/:id/download", documentHandler.DownloadExport)
+			}
+
+			// Import routes
+			if importService != nil {
+				imports := protected.Group("/imports")
+				{
+					imports.GET("", documentHandler.GetImports)
+					imports.POST("", uploadSizeLimit, documentHandler.CreateImport)
+					imports.GET("/:id", documentHandler.GetImport)
+					imports.GET("/connections", documentHandler.GetImportConnections)
+					imports.POST("/connections/:provider", documentHandler.ConnectImportProvider)
+					imports.DELETE("/connections/:provider", documentHandler.DisconnectImportProvider)
+					imports.GET("/connections/:provider/folders", documentHandler.GetImportFolders)
+				}
+			}
+
+			// Folder routes
+			folders := protected.Group("/folders")
+			{
+				folders.GET("", folderHandler.GetFolders)
+				folders.POST("", folderHandler.CreateFolder)
+				folders.GET("/:id", folderHandler.GetFolder)
+				folders.PUT("/:id", folderHandler.UpdateFolder)
+				folders.DELETE("/:id", folderHandler.DeleteFolder)
+				folders.GET("/:id/permissions", folderHandler.GetPermissions)
+				folders.POST("/:id/permissions", folderHandler.SetPermission)
+				folders.DELETE("/:id/permissions/:permissionId", folderHandler.RevokePermission)
+			}
+
+			// Group routes
+			groups := protected.Group("/groups")
+			manageGroups := middleware.RequireCapability(roleService, models.CapabilityManageGroups)
+			{
+				groups.GET("", groupHandler.GetGroups)
+				groups.GET("/mine", groupHandler.GetMyGroups)
+				groups.GET("/:id", groupHandler.GetGroup)
+				groups.POST("", manageGroups, groupHandler.CreateGroup)
+				groups.PUT("/:id", manageGroups, groupHandler.UpdateGroup)
+				groups.DELETE("/:id", manageGroups, groupHandler.DeleteGroup)
+				groups.POST("/:id/members", manageGroups, groupHandler.AddMember)
+				groups.DELETE("/:id/members/:userId", manageGroups, groupHandler.RemoveMember)
+			}
+
+			// Tag routes
+			tags := protected.Group("/tags")
+			{
+				tags.GET("", tagHandler.GetTags)
+				tags.GET("/suggest", tagHandler.SuggestTags)
+			}
+
+			// Notification routes
+			notifications := protected.Group("/notifications")
+			{
+				notifications.GET("", notificationHandler.GetNotifications)
+				notifications.GET("/mentions", notificationHandler.GetMentions)
+				notifications.POST("/:id/read", notificationHandler.MarkRead)
+				notifications.POST("/read-all", notificationHandler.MarkAllRead)
+			}
+
+			// Subscription routes
+			subscriptions := protected.Group("/subscriptions")
+			{
+				subscriptions.GET("", subscriptionHandler.GetSubscriptions)
+				subscriptions.POST("", subscriptionHandler.Subscribe)
+				subscriptions.PUT("/:id", subscriptionHandler.UpdateSubscription)
+				subscriptions.DELETE("/:id", subscriptionHandler.Unsubscribe)
+			}
+
+			// Favorite routes
+			favorites := protected.Group("/favorites")
+			{
+				favorites.GET("", favoriteHandler.GetFavorites)
+				favorites.POST("", favoriteHandler.AddFavorite)
+				favorites.PUT("/order", favoriteHandler.ReorderFavorites)
+				favorites.DELETE("/:documentId", favoriteHandler.RemoveFavorite)
+			}
+
+			// Activity feed
+			protected.GET("/activity", activityHandler.GetActivity)
+
+			// Signing workflows waiting for the user's signature
+			protected.GET("/signing-requests", signingWorkflowHandler.GetSigningRequests)
+
+			// Signing certificates issued to the user by the internal CA
+			if certificateService != nil {
+				protected.GET("/certificates", certificateHandler.GetCertificates)
+				protected.POST("/certificates", certificateHandler.IssueCertificate)
+				protected.POST("/certificates/:id/renew", certificateHandler.RenewCertificate)
+				protected.POST("/certificates/:id/revoke", certificateHandler.RevokeCertificate)
+			}
+
+			// Statistics routes
+			stats := protected.Group("/stats")
+			stats.Use(middleware.RequireCapability(roleService, models.CapabilityViewStats))
+			{
+				stats.GET("/documents", statsHandler.GetDocumentStats)
+				stats.GET("/storage", statsHandler.GetStorageUsage)
+				stats.GET("/storage/monthly", statsHandler.GetMonthlyStorageUsage)
+			}
+
+			// Metadata field routes
+			metadataFields := protected.Group("/metadata-fields")
+			manageCatalog := middleware.RequireCapability(roleService, models.CapabilityManageCatalog)
+			{
+				metadataFields.GET("", metadataHandler.GetFields)
+				metadataFields.POST("", manageCatalog, metadataHandler.CreateField)
+				metadataFields.PUT("/:id", manageCatalog, metadataHandler.UpdateField)
+				metadataFields.DELETE("/:id", manageCatalog, metadataHandler.DeleteField)
+			}
+
+			// Category routes
+			categories := protected.Group("/categories")
+			{
+				categories.GET("", categoryHandler.GetCategories)
+				categories.GET("/:id", categoryHandler.GetCategory)
+				categories.POST("", manageCatalog, categoryHandler.CreateCategory)
+				categories.PUT("/:id", manageCatalog, categoryHandler.UpdateCategory)
+				categories.DELETE("/:id", manageCatalog, categoryHandler.DeleteCategory)
+			}
+
+			// Audit log routes
+			audit := protected.Group("/audit")
+			viewAudit := middleware.RequireCapability(roleService, models.CapabilityViewAudit)
+			{
+				audit.GET("", viewAudit, auditHandler.GetAuditLogs)
+				audit.GET("/logs", viewAudit, auditHandler.GetAuditLogs)
+				audit.GET("/export", viewAudit, auditHandler.ExportAuditLogs)
+				audit.GET("/archives", viewAudit, auditHandler.GetArchives)
+				audit.GET("/archives/:id", viewAudit, auditHandler.DownloadArchive)
+				if auditExportService != nil {
+					audit.GET("/signed-export", viewAudit, auditHandler.SignedExport)
+					audit.POST("/signed-export/verify", viewAudit, auditHandler.VerifySignedExport)
+				}
+			}
 
 			// Blockchain routes
-			// blockchain := protected.Group("/blockchain")
-			// {
-			// 	blockchain.GET("/blocks", blockchainHandler.GetBlocks)
-			// 	blockchain.POST("/verify", blockchainHandler.VerifyIntegrity)
-			// }
+			if blockchainService != nil {
+				chain := protected.Group("/blockchain")
+				viewAudit := middleware.RequireCapability(roleService, models.CapabilityViewAudit)
+				{
+					chain.GET("/blocks", viewAudit, blockchainHandler.GetBlocks)
+					chain.GET("/transactions/:id", viewAudit, blockchainHandler.GetTransaction)
+					chain.GET("/documents/:id/history", blockchainHandler.GetDocumentHistory)
+					chain.POST("/verify", viewAudit, blockchainHandler.VerifyIntegrity)
+					chain.POST("/reconcile", viewAudit, blockchainHandler.Reconcile)
+					chain.GET("/anchors", viewAudit, blockchainHandler.GetAnchors)
+					chain.GET("/anchors/:id/proof", viewAudit, blockchainHandler.DownloadAnchorProof)
+					if timestampClient != nil {
+						chain.POST("/anchors", viewAudit, blockchainHandler.CreateAnchor)
+					}
+					if timestampService != nil {
+						chain.GET("/timestamps", viewAudit, timestampHandler.GetBlockTimestamps)
+						chain.POST("/timestamps", viewAudit, timestampHandler.StampBlock)
+						chain.GET("/timestamps/:id/token", viewAudit, timestampHandler.DownloadBlockToken)
+					}
+				}
+			}
 		}
 	}
 
-	return router
+	return router, nil
+}
+
+// loadCertPool reads the PEM CA certificates at path, or returns nil for an
+// empty path
+func loadCertPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return roots, nil
 }
@@ -0,0 +1,38 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/config"
+)
+
+// ErrNotFound is returned when reading an object that isn't in the store
+var ErrNotFound = errors.New("archived object not found")
+
+// Store keeps archived files, such as audit log entries moved out of the
+// database, under slash separated keys
+type Store interface {
+	// Put writes an object, replacing any with the same key
+	Put(ctx context.Context, key string, data []byte) error
+	// Get reads an object
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Name returns the store name for logging and records
+	Name() string
+}
+
+// NewStore creates the archive store selected in the configuration
+func NewStore(cfg *config.Config) (Store, error) {
+	switch cfg.AuditArchiveStore {
+	case "", "local":
+		return NewLocalStore(cfg.AuditArchivePath), nil
+	case "s3":
+		client := &http.Client{Timeout: 5 * time.Minute}
+		return NewS3Store(client, cfg.AuditArchiveS3Endpoint, cfg.AWSRegion, cfg.AuditArchiveS3Bucket, cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey, cfg.AWSSessionToken)
+	default:
+		return nil, fmt.Errorf("unknown archive store: %s", cfg.AuditArchiveStore)
+	}
+}
@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps archived files in a directory, for a mounted volume or
+// when no object storage is available
+type LocalStore struct {
+	basePath string
+}
+
+// NewLocalStore creates a new local store rooted at basePath, which is
+// created on the first write
+func NewLocalStore(basePath string) *LocalStore {
+	return &LocalStore{basePath: basePath}
+}
+
+// Put writes an object through a temporary file so it is never read
+// half-written
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte) error {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o750); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	tmpPath := fullPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o640); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// Get reads an object
+func (s *LocalStore) Get(ctx context.Context, key string) ([]byte, error) {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return data, nil
+}
+
+// Name returns the store name
+func (s *LocalStore) Name() string {
+	return "local"
+}
+
+// resolve converts a key to an absolute path inside basePath
+func (s *LocalStore) resolve(key string) (string, error) {
+	fullPath := filepath.Join(s.basePath, filepath.Clean("/"+key))
+	if !strings.HasPrefix(fullPath, filepath.Clean(s.basePath)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid archive key")
+	}
+	return fullPath, nil
+}
@@ -0,0 +1,206 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store keeps archived files in a bucket of Amazon S3 or a compatible
+// object storage, addressed path style so endpoints such as MinIO work too
+type S3Store struct {
+	client          *http.Client
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// NewS3Store creates a new S3 store. Without an endpoint the regional AWS
+// endpoint is used.
+func NewS3Store(client *http.Client, endpoint, region, bucket, accessKeyID, secretAccessKey, sessionToken string) (*S3Store, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("archive bucket is not configured")
+	}
+	if accessKeyID == "" || secretAccessKey == "" {
+		return nil, fmt.Errorf("aws credentials are not configured")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return nil, fmt.Errorf("invalid archive endpoint: %s", endpoint)
+	}
+
+	return &S3Store{
+		client:          client,
+		endpoint:        parsed,
+		region:          region,
+		bucket:          bucket,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		sessionToken:    sessionToken,
+	}, nil
+}
+
+// Put uploads an object
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("object storage returned status %d: %s", resp.StatusCode, message)
+	}
+	return nil
+}
+
+// Get downloads an object
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("object storage returned status %d: %s", resp.StatusCode, message)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return data, nil
+}
+
+// Name returns the store name
+func (s *S3Store) Name() string {
+	return "s3"
+}
+
+// do sends a signed request for an object
+func (s *S3Store) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+		return nil, fmt.Errorf("invalid archive key")
+	}
+
+	path := strings.TrimSuffix(s.endpoint.Path, "/") + "/" + uriEncode(s.bucket) + "/"
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	path += strings.Join(segments, "/")
+
+	target := *s.endpoint
+	target.Path = ""
+	target.RawPath = ""
+	req, err := http.NewRequestWithContext(ctx, method, target.String()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object storage request: %w", err)
+	}
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	s.signRequest(req, path, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach object storage: %w", err)
+	}
+	return resp, nil
+}
+
+// signRequest signs the request with AWS Signature Version 4. path is the
+// already encoded canonical URI.
+func (s *S3Store) signRequest(req *http.Request, path string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	// Canonical headers must be sorted by name
+	headers := [][2]string{
+		{"host", req.URL.Host},
+		{"x-amz-content-sha256", payloadHash},
+		{"x-amz-date", amzDate},
+	}
+	if s.sessionToken != "" {
+		headers = append(headers, [2]string{"x-amz-security-token", s.sessionToken})
+	}
+
+	var canonicalHeaders string
+	names := make([]string, 0, len(headers))
+	for _, header := range headers {
+		canonicalHeaders += header[0] + ":" + header[1] + "\n"
+		names = append(names, header[0])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := req.Method + "\n" + path + "\n\n" + canonicalHeaders + "\n" + signedHeaders + "\n" + payloadHash
+
+	credentialScope := dateStamp + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + credentialScope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretAccessKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, credentialScope, signedHeaders, signature,
+	))
+}
+
+// uriEncode percent-encodes everything but the unreserved characters of
+// RFC 3986, as Signature Version 4 expects
+func uriEncode(value string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~' {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
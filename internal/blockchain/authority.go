@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// Consensus modes deciding how new blocks are sealed
+const (
+	ConsensusProofOfWork      = "pow"
+	ConsensusProofOfAuthority = "poa"
+)
+
+// Authority seals blocks in proof-of-authority mode by signing their hash
+// with the server's Ed25519 key instead of mining them
+type Authority struct {
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+	keyID      string
+}
+
+// NewAuthority creates an authority signing with the private key
+func NewAuthority(privateKey ed25519.PrivateKey) *Authority {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	sum := sha256.Sum256(publicKey)
+
+	return &Authority{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		keyID:      fmt.Sprintf("%x", sum[:8]),
+	}
+}
+
+// LoadAuthority creates an authority from a PEM file holding a PKCS #8
+// Ed25519 private key, as written by `openssl genpkey -algorithm ed25519`
+func LoadAuthority(path string) (*Authority, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blockchain signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("blockchain signing key is not PEM encoded")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse blockchain signing key: %w", err)
+	}
+	privateKey, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("blockchain signing key is not an Ed25519 key")
+	}
+
+	return NewAuthority(privateKey), nil
+}
+
+// KeyID identifies the authority's key in the blocks it signs
+func (a *Authority) KeyID() string {
+	return a.keyID
+}
+
+// sign signs the block's hash
+func (a *Authority) sign(block *Block) {
+	block.Signer = a.keyID
+	block.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(a.privateKey, []byte(block.Hash)))
+}
+
+// verify checks that the block's hash was signed by the authority
+func (a *Authority) verify(block *Block) bool {
+	if block.Signer != a.keyID {
+		return false
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(block.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(a.publicKey, []byte(block.Hash), signature)
+}
@@ -3,10 +3,27 @@ package blockchain
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
+// DefaultDifficulty is the number of leading zeros required in block hashes
+// of a new chain, and in blocks mined before their difficulty was recorded
+const DefaultDifficulty = 4
+
+// MinDifficulty is the lowest difficulty blocks are mined at
+const MinDifficulty = 1
+
+// retargetWindow is the number of blocks whose mining times are averaged
+// before adjusting the difficulty
+const retargetWindow = 10
+
+// ErrStaleBlock is returned for a prepared block when another block was added
+// after the one it follows
+var ErrStaleBlock = errors.New("block doesn't follow the latest block")
+
 // Transaction represents a blockchain transaction
 type Transaction struct {
 	ID         string                 `json:"id"`
@@ -27,28 +44,175 @@ type Block struct {
 	Hash         string        `json:"hash"`
 	Nonce        int64         `json:"nonce"`
 	MerkleRoot   string        `json:"merkle_root"`
+	Difficulty   int           `json:"difficulty,omitempty"` // Leading zeros of the hash; 0 for DefaultDifficulty
+	// PreviousMiningTime is how long the block before took to mine, 0 when
+	// unknown. TargetMiningTime and MaxDifficulty are set on the blocks the
+	// difficulty was retargeted at, to the mining time aimed for and the
+	// highest difficulty allowed.
+	PreviousMiningTime time.Duration `json:"previous_mining_time,omitempty"`
+	TargetMiningTime   time.Duration `json:"target_mining_time,omitempty"`
+	MaxDifficulty      int           `json:"max_difficulty,omitempty"`
+	Signer             string        `json:"signer,omitempty"`    // Key ID of the authority that signed the block
+	Signature          string        `json:"signature,omitempty"` // Authority's signature of the hash, instead of proof of work
+
+	miningTime time.Duration // How long sealing took, from PrepareBlock to AppendBlock
 }
 
-// Blockchain represents the blockchain
+// Blockchain represents the blockchain. It is safe for concurrent use: reads
+// share the lock and adding blocks takes it exclusively, once they have been
+// mined or signed without it.
+//
+// With a target mining time set, the difficulty is retargeted at every
+// retargetWindow-th block from the mining times recorded in the window:
+// raised when the blocks were mined in under a quarter of the target and
+// lowered when they took over four times as long, as each step changes the
+// work needed sixteenfold. Everything the retarget depends on is hashed into
+// the blocks, so validation recomputes the difficulty of every block and a
+// tampered block can't be re-mined at a lower one.
+//
+// With an authority, blocks are signed instead of mined. Every block from
+// the one the authority took over at must be signed; only the blocks mined
+// before it may have proof of work.
 type Blockchain struct {
-	Blocks     []Block `json:"blocks"`
-	Difficulty int     `json:"difficulty"`
+	authority  *Authority
+	signedFrom int64 // Index of the first block that must be signed
+
+	mu               sync.RWMutex
+	blocks           []Block
+	targetMiningTime time.Duration
+	maxDifficulty    int
+	lastMiningTime   time.Duration // Of the latest block, 0 when it wasn't mined here
 }
 
-// NewBlockchain creates a new blockchain with genesis block
-func NewBlockchain() *Blockchain {
+// NewBlockchain creates a new blockchain with genesis block. Blocks are
+// signed by the authority, or mined when it is nil.
+func NewBlockchain(authority *Authority) *Blockchain {
 	bc := &Blockchain{
-		Blocks:     make([]Block, 0),
-		Difficulty: 4, // Number of leading zeros required in hash
+		authority: authority,
+		blocks:    make([]Block, 0),
 	}
 
 	// Create genesis block
 	genesisBlock := bc.createGenesisBlock()
-	bc.Blocks = append(bc.Blocks, genesisBlock)
+	bc.blocks = append(bc.blocks, genesisBlock)
 
 	return bc
 }
 
+// LoadBlockchain restores a blockchain from its blocks, in order from the
+// genesis block, and validates it. New blocks continue at the difficulty of
+// the latest one until the next retarget. Signed blocks can only be validated
+// with the authority that signed them, which must have signed every block
+// from signedFrom.
+func LoadBlockchain(blocks []Block, authority *Authority, signedFrom int64) (*Blockchain, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("blockchain has no genesis block")
+	}
+
+	bc := &Blockchain{
+		authority:  authority,
+		signedFrom: signedFrom,
+		blocks:     blocks,
+	}
+
+	for i, block := range bc.blocks {
+		if block.Index != int64(i) {
+			return nil, fmt.Errorf("block %d found at position %d", block.Index, i)
+		}
+	}
+	if !bc.validate() {
+		return nil, fmt.Errorf("blockchain failed validation")
+	}
+
+	return bc, nil
+}
+
+// SetDifficultyTarget turns on difficulty adjustment, aiming for blocks to
+// take target to mine at no more than maxDifficulty. A zero target keeps the
+// difficulty fixed. Blocks already retargeted keep the target they recorded.
+func (bc *Blockchain) SetDifficultyTarget(target time.Duration, maxDifficulty int) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if maxDifficulty < MinDifficulty {
+		maxDifficulty = DefaultDifficulty
+	}
+	bc.targetMiningTime = target
+	bc.maxDifficulty = maxDifficulty
+}
+
+// Difficulty returns the difficulty the next block will be mined at
+func (bc *Blockchain) Difficulty() int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	next := bc.newBlock(nil)
+	return blockDifficulty(&next)
+}
+
+// newBlock starts the block that would follow the latest one, at the
+// difficulty it must be mined at. The caller holds the lock.
+func (bc *Blockchain) newBlock(transactions []Transaction) Block {
+	latestBlock := bc.getLatestBlock()
+
+	block := Block{
+		Index:        latestBlock.Index + 1,
+		Timestamp:    time.Now(),
+		Transactions: transactions,
+		PreviousHash: latestBlock.Hash,
+		Nonce:        0,
+	}
+	if bc.authority != nil {
+		return block
+	}
+
+	block.PreviousMiningTime = bc.lastMiningTime
+	if bc.targetMiningTime > 0 && block.Index%retargetWindow == 0 {
+		block.TargetMiningTime = bc.targetMiningTime
+		block.MaxDifficulty = bc.maxDifficulty
+	}
+	block.Difficulty = bc.expectedDifficulty(&block)
+
+	return block
+}
+
+// expectedDifficulty returns the difficulty a block must be mined at: that of
+// the block before it, retargeted when the block records a target. Only the
+// blocks before it in the chain are read. The caller holds the lock.
+func (bc *Blockchain) expectedDifficulty(block *Block) int {
+	previous := blockDifficulty(&bc.blocks[block.Index-1])
+	if block.TargetMiningTime <= 0 || block.Index%retargetWindow != 0 {
+		return previous
+	}
+
+	// The window's mining times are recorded by the block after each one
+	miningTimes := []time.Duration{block.PreviousMiningTime}
+	for _, b := range bc.blocks[block.Index-retargetWindow+1 : block.Index] {
+		miningTimes = append(miningTimes, b.PreviousMiningTime)
+	}
+
+	var total time.Duration
+	count := 0
+	for _, t := range miningTimes {
+		if t > 0 {
+			total += t
+			count++
+		}
+	}
+	if count == 0 {
+		return previous
+	}
+	average := total / time.Duration(count)
+
+	switch {
+	case average < block.TargetMiningTime/4 && previous < block.MaxDifficulty:
+		return previous + 1
+	case average > block.TargetMiningTime*4 && previous > MinDifficulty:
+		return previous - 1
+	}
+	return previous
+}
+
 // createGenesisBlock creates the first block in the blockchain
 func (bc *Blockchain) createGenesisBlock() Block {
 	genesisTransaction := Transaction{
@@ -65,46 +229,105 @@ func (bc *Blockchain) createGenesisBlock() Block {
 		Transactions: []Transaction{genesisTransaction},
 		PreviousHash: "0",
 		Nonce:        0,
+		Difficulty:   DefaultDifficulty,
 	}
 
 	block.MerkleRoot = bc.calculateMerkleRoot(block.Transactions)
-	block.Hash = bc.mineBlock(&block)
+	bc.seal(&block)
 
 	return block
 }
 
-// AddTransaction adds a new transaction to the blockchain
+// AddTransaction adds a new transaction to the blockchain in a block of its
+// own. The block is mined without holding the lock, and mined again if
+// another block was added meanwhile.
 func (bc *Blockchain) AddTransaction(transaction Transaction) error {
-	// Calculate transaction hash
-	transaction.Hash = bc.calculateTransactionHash(transaction)
-
-	// Get the latest block
-	latestBlock := bc.getLatestBlock()
+	for {
+		err := bc.AppendBlock(bc.PrepareBlock([]Transaction{transaction}))
+		if !errors.Is(err, ErrStaleBlock) {
+			return err
+		}
+	}
+}
 
-	// Create new block
-	newBlock := Block{
-		Index:        latestBlock.Index + 1,
-		Timestamp:    time.Now(),
-		Transactions: []Transaction{transaction},
-		PreviousHash: latestBlock.Hash,
-		Nonce:        0,
+// PrepareBlock hashes the transactions and mines or signs the block that
+// would follow the latest one with them, without adding it to the chain
+func (bc *Blockchain) PrepareBlock(transactions []Transaction) Block {
+	hashed := make([]Transaction, len(transactions))
+	for i, tx := range transactions {
+		tx.Hash = bc.calculateTransactionHash(tx)
+		hashed[i] = tx
 	}
 
-	// Calculate Merkle root
-	newBlock.MerkleRoot = bc.calculateMerkleRoot(newBlock.Transactions)
+	bc.mu.RLock()
+	block := bc.newBlock(hashed)
+	bc.mu.RUnlock()
 
-	// Mine the block
-	newBlock.Hash = bc.mineBlock(&newBlock)
+	block.MerkleRoot = bc.calculateMerkleRoot(block.Transactions)
+	block.miningTime = bc.seal(&block)
 
-	// Add block to blockchain
-	bc.Blocks = append(bc.Blocks, newBlock)
+	return block
+}
+
+// AppendBlock adds a block prepared by PrepareBlock to the chain, checking
+// that it still follows the latest block
+func (bc *Blockchain) AppendBlock(block Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	latestBlock := bc.getLatestBlock()
+	if block.Index != latestBlock.Index+1 || block.PreviousHash != latestBlock.Hash {
+		return fmt.Errorf("block %d: %w", block.Index, ErrStaleBlock)
+	}
+	if block.Hash != bc.calculateBlockHash(&block) || !bc.validSeal(&block, bc.authority != nil) {
+		return fmt.Errorf("block %d has an invalid hash", block.Index)
+	}
 
+	bc.blocks = append(bc.blocks, block)
+	bc.lastMiningTime = block.miningTime
 	return nil
 }
 
+// seal signs the block in proof-of-authority mode and mines it otherwise,
+// returning how long it took
+func (bc *Blockchain) seal(block *Block) time.Duration {
+	started := time.Now()
+	if bc.authority != nil {
+		block.Difficulty = 0
+		block.Hash = bc.calculateBlockHash(block)
+		bc.authority.sign(block)
+	} else {
+		block.Hash = bc.mineBlock(block)
+	}
+	return time.Since(started)
+}
+
+// validSeal checks the signature of a signed block and the proof of work of
+// others, at the difficulty expected at their height. Unsigned blocks aren't
+// accepted when signedOnly is set. The caller holds the lock.
+func (bc *Blockchain) validSeal(block *Block, signedOnly bool) bool {
+	if block.Signature != "" {
+		return bc.authority != nil && bc.authority.verify(block)
+	}
+	if signedOnly {
+		return false
+	}
+
+	difficulty := blockDifficulty(block)
+	return difficulty == bc.expectedDifficulty(block) && bc.isValidHash(block.Hash, getTarget(difficulty))
+}
+
+// Consensus returns how new blocks are sealed
+func (bc *Blockchain) Consensus() string {
+	if bc.authority != nil {
+		return ConsensusProofOfAuthority
+	}
+	return ConsensusProofOfWork
+}
+
 // mineBlock mines a block using proof of work
 func (bc *Blockchain) mineBlock(block *Block) string {
-	target := bc.getTarget()
+	target := getTarget(blockDifficulty(block))
 
 	for {
 		hash := bc.calculateBlockHash(block)
@@ -115,7 +338,8 @@ func (bc *Blockchain) mineBlock(block *Block) string {
 	}
 }
 
-// calculateBlockHash calculates the hash of a block
+// calculateBlockHash calculates the hash of a block. Blocks mined before
+// their difficulty was recorded hash without it, as they always have.
 func (bc *Blockchain) calculateBlockHash(block *Block) string {
 	data := fmt.Sprintf("%d%s%s%s%d",
 		block.Index,
@@ -124,6 +348,14 @@ func (bc *Blockchain) calculateBlockHash(block *Block) string {
 		block.MerkleRoot,
 		block.Nonce,
 	)
+	if block.Difficulty != 0 || block.PreviousMiningTime != 0 || block.TargetMiningTime != 0 || block.MaxDifficulty != 0 {
+		data += fmt.Sprintf("|%d|%d|%d|%d",
+			block.Difficulty,
+			int64(block.PreviousMiningTime),
+			int64(block.TargetMiningTime),
+			block.MaxDifficulty,
+		)
+	}
 
 	hash := sha256.Sum256([]byte(data))
 	return fmt.Sprintf("%x", hash)
@@ -166,10 +398,18 @@ func (bc *Blockchain) calculateMerkleRoot(transactions []Transaction) string {
 	return fmt.Sprintf("%x", hash)
 }
 
-// getTarget returns the target for proof of work
-func (bc *Blockchain) getTarget() string {
+// blockDifficulty returns the difficulty a block was mined at
+func blockDifficulty(block *Block) int {
+	if block.Difficulty == 0 {
+		return DefaultDifficulty
+	}
+	return block.Difficulty
+}
+
+// getTarget returns the target for proof of work at a difficulty
+func getTarget(difficulty int) string {
 	target := ""
-	for i := 0; i < bc.Difficulty; i++ {
+	for i := 0; i < difficulty; i++ {
 		target += "0"
 	}
 	return target
@@ -180,16 +420,67 @@ func (bc *Blockchain) isValidHash(hash, target string) bool {
 	return hash[:len(target)] == target
 }
 
-// getLatestBlock returns the latest block in the blockchain
+// getLatestBlock returns the latest block in the blockchain. The caller
+// holds the lock.
 func (bc *Blockchain) getLatestBlock() Block {
-	return bc.Blocks[len(bc.Blocks)-1]
+	return bc.blocks[len(bc.blocks)-1]
+}
+
+// Len returns the number of blocks in the blockchain
+func (bc *Blockchain) Len() int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return len(bc.blocks)
+}
+
+// LatestBlock returns the latest block in the blockchain
+func (bc *Blockchain) LatestBlock() Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.getLatestBlock()
+}
+
+// Blocks returns a copy of the blocks from index from up to but not
+// including to, clamped to the chain. The blocks' transactions are shared
+// with the chain and must not be modified.
+func (bc *Blockchain) Blocks(from, to int64) []Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	if from < 0 {
+		from = 0
+	}
+	if to > int64(len(bc.blocks)) {
+		to = int64(len(bc.blocks))
+	}
+	if from >= to {
+		return []Block{}
+	}
+
+	return append([]Block(nil), bc.blocks[from:to]...)
 }
 
 // ValidateChain validates the entire blockchain
 func (bc *Blockchain) ValidateChain() bool {
-	for i := 1; i < len(bc.Blocks); i++ {
-		currentBlock := bc.Blocks[i]
-		previousBlock := bc.Blocks[i-1]
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.validate()
+}
+
+// validate validates the entire blockchain. The caller holds the lock.
+func (bc *Blockchain) validate() bool {
+	// Every difficulty derives from the genesis block's
+	genesisBlock := bc.blocks[0]
+	if blockDifficulty(&genesisBlock) != DefaultDifficulty || genesisBlock.Hash != bc.calculateBlockHash(&genesisBlock) {
+		return false
+	}
+
+	for i := 1; i < len(bc.blocks); i++ {
+		currentBlock := bc.blocks[i]
+		previousBlock := bc.blocks[i-1]
 
 		// Validate current block hash
 		if currentBlock.Hash != bc.calculateBlockHash(&currentBlock) {
@@ -201,9 +492,8 @@ func (bc *Blockchain) ValidateChain() bool {
 			return false
 		}
 
-		// Validate proof of work
-		target := bc.getTarget()
-		if !bc.isValidHash(currentBlock.Hash, target) {
+		// Validate signature or proof of work
+		if !bc.validSeal(&currentBlock, bc.authority != nil && currentBlock.Index >= bc.signedFrom) {
 			return false
 		}
 
@@ -225,9 +515,12 @@ func (bc *Blockchain) ValidateChain() bool {
 
 // GetTransactionHistory returns all transactions for a document
 func (bc *Blockchain) GetTransactionHistory(documentID uint) []Transaction {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
 	var transactions []Transaction
 
-	for _, block := range bc.Blocks {
+	for _, block := range bc.blocks {
 		for _, tx := range block.Transactions {
 			if tx.DocumentID == documentID {
 				transactions = append(transactions, tx)
@@ -240,9 +533,12 @@ func (bc *Blockchain) GetTransactionHistory(documentID uint) []Transaction {
 
 // GetUserTransactions returns all transactions for a user
 func (bc *Blockchain) GetUserTransactions(userID uint) []Transaction {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
 	var transactions []Transaction
 
-	for _, block := range bc.Blocks {
+	for _, block := range bc.blocks {
 		for _, tx := range block.Transactions {
 			if tx.UserID == userID {
 				transactions = append(transactions, tx)
@@ -255,16 +551,24 @@ func (bc *Blockchain) GetUserTransactions(userID uint) []Transaction {
 
 // GetBlockByIndex returns a block by its index
 func (bc *Blockchain) GetBlockByIndex(index int64) (*Block, error) {
-	if index < 0 || index >= int64(len(bc.Blocks)) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	if index < 0 || index >= int64(len(bc.blocks)) {
 		return nil, fmt.Errorf("block index out of range")
 	}
 
-	return &bc.Blocks[index], nil
+	block := bc.blocks[index]
+	block.Transactions = append([]Transaction(nil), block.Transactions...)
+	return &block, nil
 }
 
 // GetTransactionByID returns a transaction by its ID
 func (bc *Blockchain) GetTransactionByID(txID string) (*Transaction, error) {
-	for _, block := range bc.Blocks {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	for _, block := range bc.blocks {
 		for _, tx := range block.Transactions {
 			if tx.ID == txID {
 				return &tx, nil
@@ -277,17 +581,22 @@ func (bc *Blockchain) GetTransactionByID(txID string) (*Transaction, error) {
 
 // GetChainInfo returns information about the blockchain
 func (bc *Blockchain) GetChainInfo() map[string]interface{} {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
 	totalTransactions := 0
-	for _, block := range bc.Blocks {
+	for _, block := range bc.blocks {
 		totalTransactions += len(block.Transactions)
 	}
+	next := bc.newBlock(nil)
 
 	return map[string]interface{}{
-		"blocks":             len(bc.Blocks),
+		"blocks":             len(bc.blocks),
 		"total_transactions": totalTransactions,
-		"difficulty":         bc.Difficulty,
+		"difficulty":         blockDifficulty(&next),
+		"consensus":          bc.Consensus(),
 		"latest_block_hash":  bc.getLatestBlock().Hash,
-		"is_valid":           bc.ValidateChain(),
+		"is_valid":           bc.validate(),
 	}
 }
 
@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func newTestAuthority(t *testing.T) *Authority {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return NewAuthority(privateKey)
+}
+
+func testTransaction(n int) Transaction {
+	return CreateDocumentTransaction(fmt.Sprintf("tx-%d", n), uint(n), 1, "upload", map[string]interface{}{"n": n})
+}
+
+// TestConcurrentWritersAndReaders adds blocks through both AddTransaction and
+// PrepareBlock/AppendBlock while readers list and validate the chain. Run
+// with -race.
+func TestConcurrentWritersAndReaders(t *testing.T) {
+	bc := NewBlockchain(newTestAuthority(t))
+
+	const writers, perWriter = 2, 5
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers*perWriter)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := bc.AddTransaction(testTransaction(w*perWriter + i)); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				tx := testTransaction((writers+w)*perWriter + i)
+				for {
+					err := bc.AppendBlock(bc.PrepareBlock([]Transaction{tx}))
+					if err == nil {
+						break
+					}
+					if !errors.Is(err, ErrStaleBlock) {
+						errs <- err
+						break
+					}
+				}
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 2; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				blocks := bc.Blocks(0, int64(bc.Len()))
+				for i := 1; i < len(blocks); i++ {
+					if blocks[i].PreviousHash != blocks[i-1].Hash {
+						errs <- fmt.Errorf("block %d doesn't link to block %d", i, i-1)
+						return
+					}
+				}
+				if !bc.ValidateChain() {
+					errs <- errors.New("chain failed validation while being written")
+					return
+				}
+				bc.GetChainInfo()
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got, want := bc.Len(), 1+2*writers*perWriter; got != want {
+		t.Errorf("chain has %d blocks, want %d", got, want)
+	}
+	if !bc.ValidateChain() {
+		t.Error("chain failed validation")
+	}
+}
+
+// TestAddTransactionRetriesStaleBlocks checks that proof-of-work blocks mined
+// concurrently outside the lock all end up on the chain
+func TestAddTransactionRetriesStaleBlocks(t *testing.T) {
+	bc := NewBlockchain(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := bc.AddTransaction(testTransaction(i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := bc.Len(); got != 3 {
+		t.Errorf("chain has %d blocks, want 3", got)
+	}
+	if !bc.ValidateChain() {
+		t.Error("chain failed validation")
+	}
+}
+
+// TestAppendBlockRefusesStaleBlock checks that a block prepared before
+// another was added is refused rather than forking the chain
+func TestAppendBlockRefusesStaleBlock(t *testing.T) {
+	bc := NewBlockchain(newTestAuthority(t))
+
+	stale := bc.PrepareBlock([]Transaction{testTransaction(1)})
+	if err := bc.AddTransaction(testTransaction(2)); err != nil {
+		t.Fatalf("AddTransaction: %v", err)
+	}
+
+	if err := bc.AppendBlock(stale); !errors.Is(err, ErrStaleBlock) {
+		t.Errorf("AppendBlock of a stale block returned %v, want ErrStaleBlock", err)
+	}
+}
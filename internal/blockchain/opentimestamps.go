@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxTimestampSize is the largest timestamp accepted from a calendar
+const maxTimestampSize = 64 * 1024
+
+// otsHeader starts every detached OpenTimestamps proof: the magic bytes,
+// major version 1 and the SHA-256 operation the digest was made with
+var otsHeader = []byte("\x00OpenTimestamps\x00\x00Proof\x00\xbf\x89\xe2\xe8\x84\xe8\x92\x94\x01\x08")
+
+// TimestampClient anchors digests with OpenTimestamps calendar servers,
+// which commit them to the Bitcoin blockchain
+type TimestampClient struct {
+	client    *http.Client
+	calendars []string
+}
+
+// NewTimestampClient creates a new OpenTimestamps client submitting to the
+// calendars in order until one accepts
+func NewTimestampClient(calendars []string) *TimestampClient {
+	trimmed := make([]string, 0, len(calendars))
+	for _, calendar := range calendars {
+		if calendar = strings.TrimRight(strings.TrimSpace(calendar), "/"); calendar != "" {
+			trimmed = append(trimmed, calendar)
+		}
+	}
+
+	return &TimestampClient{
+		client:    &http.Client{Timeout: 30 * time.Second},
+		calendars: trimmed,
+	}
+}
+
+// Stamp submits a SHA-256 digest and returns the calendar that accepted it
+// with its pending timestamp, which the calendar completes once the
+// Bitcoin transaction is confirmed
+func (c *TimestampClient) Stamp(ctx context.Context, digest []byte) (string, []byte, error) {
+	if len(digest) != 32 {
+		return "", nil, fmt.Errorf("digest must be 32 bytes, got %d", len(digest))
+	}
+	if len(c.calendars) == 0 {
+		return "", nil, fmt.Errorf("no OpenTimestamps calendars configured")
+	}
+
+	var lastErr error
+	for _, calendar := range c.calendars {
+		timestamp, err := c.submit(ctx, calendar, digest)
+		if err == nil {
+			return calendar, timestamp, nil
+		}
+		lastErr = err
+	}
+	return "", nil, lastErr
+}
+
+// submit posts the digest to one calendar
+func (c *TimestampClient) submit(ctx context.Context, calendar string, digest []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, calendar+"/digest", bytes.NewReader(digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create timestamp request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.opentimestamps.v1")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach calendar %s: %w", calendar, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("calendar %s returned status %d: %s", calendar, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	timestamp, err := io.ReadAll(io.LimitReader(resp.Body, maxTimestampSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamp from %s: %w", calendar, err)
+	}
+	if len(timestamp) == 0 || len(timestamp) > maxTimestampSize {
+		return nil, fmt.Errorf("calendar %s returned a timestamp of %d bytes", calendar, len(timestamp))
+	}
+
+	return timestamp, nil
+}
+
+// DetachedTimestamp builds the .ots proof file for a digest from a
+// calendar's timestamp, which `ots upgrade` and `ots verify` accept
+func DetachedTimestamp(digest, timestamp []byte) []byte {
+	proof := make([]byte, 0, len(otsHeader)+len(digest)+len(timestamp))
+	proof = append(proof, otsHeader...)
+	proof = append(proof, digest...)
+	return append(proof, timestamp...)
+}
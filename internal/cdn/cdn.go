@@ -0,0 +1,28 @@
+package cdn
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/config"
+)
+
+// URLSigner signs URLs so a CDN serves them only until they expire
+type URLSigner interface {
+	// SignURL returns rawURL signed to be valid until expires
+	SignURL(rawURL string, expires time.Time) (string, error)
+	// Name returns the signer name for logging
+	Name() string
+}
+
+// NewURLSigner creates the URL signer of the CDN selected in the configuration
+func NewURLSigner(cfg *config.Config) (URLSigner, error) {
+	switch cfg.CDNProvider {
+	case "cloudfront":
+		return NewCloudFrontSigner(cfg.CDNKeyID, cfg.CDNPrivateKeyPath)
+	case "cloudcdn":
+		return NewCloudCDNSigner(cfg.CDNKeyID, cfg.CDNSigningKey)
+	default:
+		return nil, fmt.Errorf("unknown CDN provider: %s", cfg.CDNProvider)
+	}
+}
@@ -4,16 +4,19 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port        string
-	DatabaseURL string
-	JWTSecret   string
-	Environment string
-	LogLevel    string
+	Port         string
+	DatabaseURL  string
+	JWTSecret    string
+	JWTIssuer    string   // iss claim of issued tokens, required on validation
+	JWTAudiences []string // client_type=audience pairs; web is the default client type, service is for OAuth clients
+	Environment  string
+	LogLevel     string
 
 	// Database Config
 	DBHost     string
@@ -22,16 +25,327 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	DBSSLMode  string
+	// Row-level security policies on documents, checked for every query of a
+	// request; queries without a user see none
+	DBRowLevelSecurity bool
+	// Record changes to users, documents and permissions in the audit log
+	// with their values before and after
+	DBAuditChanges bool
+	// User that archives and moves audit log entries, a member of the
+	// audit_maintenance role; empty runs maintenance as DBUser, which can't
+	DBAuditMaintenanceUser     string
+	DBAuditMaintenancePassword string
 
 	// Blockchain Config
-	BlockchainEnabled bool
-	GenesisBlock      string
+	BlockchainEnabled           bool
+	GenesisBlock                string
+	BlockchainReconcileInterval int // minutes, 0 disables
+	BlockchainMineInterval      int // seconds between mining pending transactions
+	BlockchainBlockSize         int // Most transactions per block; a full pool is mined at once
+	BlockchainTargetMiningTime  int // milliseconds per block the difficulty is adjusted towards, 0 keeps it fixed
+	BlockchainMaxDifficulty     int
+	BlockchainConsensus         string // pow mines blocks, poa signs them with BlockchainSigningKeyPath
+	BlockchainSigningKeyPath    string // Ed25519 private key (PKCS #8 PEM)
+	BlockchainAnchorCalendars   string // Comma-separated OpenTimestamps calendar URLs, empty disables anchoring
+	BlockchainAnchorInterval    int    // minutes, 0 only anchors on demand
+
+	// Trusted Timestamping Config
+	TimestampAuthorityURL string // RFC 3161 timestamping authority, empty disables
+	TimestampCACertPath   string // PEM CA bundle the authority's certificate must chain to; empty uses the system roots
+
+	// Document Signature Config
+	SigningCertPath   string // PEM certificate (and intermediates) the server signs documents and audit exports with, empty disables server signing
+	SigningKeyPath    string // PEM private key of SigningCertPath
+	SigningCACertPath string // PEM CA bundle signer certificates must chain to; empty only checks signatures
+
+	// Internal PKI Config
+	PKICACertPath    string // PEM CA certificate (and intermediates) user signing certificates are issued from, empty disables
+	PKICAKeyPath     string // PEM private key of PKICACertPath
+	PKICertValidity  int    // Days issued certificates are valid
+	PKICRLValidity   int    // Hours a CRL is valid
+	PKIRenewalWindow int    // Days before expiry users are told to renew
 
 	// Security Config
-	EncryptionKey    string
-	TokenExpiry      int // minutes
-	RefreshExpiry    int // days
-	MaxLoginAttempts int
+	EncryptionKey      string
+	KeyProvider        string // env, vault or awskms
+	PreviousKey        string // Master key before the current one, while stored keys move to the current one
+	TokenExpiry        int    // minutes
+	RefreshExpiry      int    // days
+	MaxLoginAttempts   int
+	MaxSessions        int    // Active refresh tokens per user; 0 is unlimited
+	SessionLimitAction string // reject or revoke_oldest
+	StepUpMaxAge       int    // minutes a password or security key counts as fresh; 0 disables step-up
+	StepUpAccessLevel  int    // Lowest access level needing a fresh authentication
+
+	// Download justification: lowest access level whose downloads need a reason; 0 disables
+	DownloadJustificationLevel int
+
+	// Password Policy Config: checked whenever a password is set
+	PasswordMinLength        int
+	PasswordRequireUppercase bool
+	PasswordRequireLowercase bool
+	PasswordRequireDigit     bool
+	PasswordRequireSymbol    bool
+	PasswordBannedFile       string // Banned passwords, one per line, on top of built-in common ones
+	PasswordHistoryDepth     int    // Recent passwords, current included, that can't be reused
+
+	// Registration Config: self-registered accounts await admin approval
+	RegistrationEnabled        bool
+	RegistrationAllowedDomains []string // Email domains that may register; empty allows any
+
+	// Public Portal Config: unauthenticated access to public documents
+	PublicPortalEnabled   bool
+	PublicPortalRateLimit int // Requests per minute of each client
+
+	// Share Link Config: document downloads without an account
+	ShareLinksEnabled bool
+	ShareLinkMaxDays  int // Longest validity of a link
+
+	// SMTP Config: outgoing emails such as password resets
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// API Quota Config: daily limits of each API key and OAuth client; 0 is unlimited
+	APIDailyRequestQuota     int
+	APIDailyBandwidthQuotaMB int
+
+	// OAuth Config: machine clients get tokens with the client credentials grant
+	OAuthEnabled  bool
+	OAuthTokenTTL int // minutes
+
+	// Login Alerts Config: users are emailed about logins from new devices
+	LoginAlertsEnabled bool
+
+	// Password Reset Config: signed, single-use links are emailed
+	PasswordResetEnabled    bool
+	PasswordResetURL        string // Page of the web application taking ?token=
+	PasswordResetTTL        int    // minutes
+	PasswordResetMaxPerHour int    // Emails per user; requests per IP are limited as well
+
+	// LDAP Config: directory users are provisioned on their first login
+	LDAPEnabled        bool
+	LDAPURL            string // ldaps://host or ldap://host with LDAPStartTLS
+	LDAPStartTLS       bool
+	LDAPCACertPath     string // PEM CA bundle; empty uses the system roots
+	LDAPBindDN         string
+	LDAPBindPassword   string
+	LDAPBaseDN         string
+	LDAPUserFilter     string // %s is replaced by the escaped username
+	LDAPGroupAttribute string
+	LDAPTimeout        int // seconds
+
+	// Directory Config: groups of LDAP and Okta users mapped to roles and departments
+	DirectoryAdminGroups      []string // Group names per role
+	DirectoryManagerGroups    []string
+	DirectoryEmployeeGroups   []string
+	DirectoryGuestGroups      []string
+	DirectoryDepartmentGroups []string // group:department pairs
+	DirectoryDefaultRole      string   // Role of users in no mapped group; empty refuses them
+
+	// Directory Sync Config: scheduled sync of roles, departments and leavers
+	DirectorySyncEnabled          bool
+	DirectorySyncProvider         string // ldap or okta
+	DirectorySyncInterval         int    // minutes, 0 only syncs on demand
+	DirectorySyncDryRun           bool   // Scheduled syncs only report their changes
+	DirectorySyncLDAPFilter       string // Matches every directory user
+	DirectorySyncLDAPUsernameAttr string
+	OktaOrgURL                    string
+	OktaAPIToken                  string
+
+	// WebAuthn Config: security keys confirm passwords, passkeys replace them
+	WebAuthnEnabled      bool
+	WebAuthnRPID         string   // Domain of the web application
+	WebAuthnRPName       string   // Shown by authenticators
+	WebAuthnOrigins      []string // Origins the browser may report, such as https://dms.example.com
+	WebAuthnPasswordless bool
+	WebAuthnTimeout      int // seconds
+
+	// Key Provider Config
+	VaultAddr     string
+	VaultToken    string
+	VaultKeyPath  string
+	VaultKeyField string
+
+	AWSRegion          string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+	KMSEncryptedKey    string // Base64 ciphertext blob of the master key
+
+	// Storage Config
+	StoragePath            string
+	TrashRetentionDays     int // Days before trashed documents are purged, 0 disables
+	TrashPurgeInterval     int // minutes
+	IntegrityCheckInterval int // minutes between re-hashing stored files, 0 disables
+
+	// Audit Log Archival Config
+	AuditRetentionDays     int    // Days audit entries stay in the database before being archived, 0 keeps them
+	AuditArchiveInterval   int    // minutes
+	AuditArchiveStore      string // local or s3
+	AuditArchivePath       string
+	AuditArchiveS3Endpoint string // Defaults to the AWS endpoint of AWSRegion
+	AuditArchiveS3Bucket   string
+	AuditPartitionInterval int // minutes between creating and dropping monthly audit log partitions
+
+	// SIEM Streaming Config
+	SIEMSink          string // syslog or hec, empty disables
+	SIEMSyslogNetwork string // udp, tcp or tls
+	SIEMSyslogAddress string // host:port
+	SIEMHECURL        string // Base URL of the HTTP Event Collector
+	SIEMHECToken      string
+	SIEMHECIndex      string // Empty for the token's default index
+	SIEMHECSourceType string
+	SIEMBufferSize    int // Audit events held in memory while the SIEM is unreachable
+
+	// Outbox Config
+	OutboxDispatchInterval int // seconds between deliveries of audit and blockchain events
+	OutboxMaxAttempts      int // Deliveries of an event before it's given up on
+
+	// Security Detection Config
+	SecurityDetectionInterval     int // minutes, 0 disables
+	SecurityFailedLoginThreshold  int // Failed logins from an IP within 15 minutes
+	SecurityMassDownloadThreshold int // Downloads by a user within an hour
+	SecurityBusinessHoursStart    int // Hour of day, business hours are on weekdays only
+	SecurityBusinessHoursEnd      int
+	SecurityTimezone              string // IANA name business hours are in
+
+	// Security Alert Notification Config
+	SecurityAlertChannels            []string // email, slack and pagerduty, empty disables
+	SecurityAlertMinSeverity         string   // Lowest severity notified
+	SecurityAlertEmails              []string
+	SecurityAlertSlackWebhookURL     string
+	SecurityAlertPagerDutyRoutingKey string // Integration key of an Events API v2 integration
+	SecurityAlertURL                 string // Page of the web application showing an alert, taking ?id=
+
+	// Chunked Upload Config
+	UploadMaxSizeMB      int // Maximum size of a chunked upload
+	UploadMaxChunkSizeMB int
+	UploadSessionTTL     int // hours without activity before a session expires
+
+	// Request size limits in MB: request bodies in general, and uploads by role
+	MaxRequestBodyMB        int
+	UploadMaxSizeAdminMB    int
+	UploadMaxSizeManagerMB  int
+	UploadMaxSizeEmployeeMB int
+	UploadMaxSizeGuestMB    int
+
+	// Upload file type policy: extensions (e.g. .pdf) and detected content
+	// types (e.g. image/*); empty allow lists accept anything not blocked
+	UploadAllowedExtensions []string
+	UploadAllowedTypes      []string
+	UploadBlockedExtensions []string
+	UploadBlockedTypes      []string
+
+	// Bulk upload Config: limits on the files of a zip archive
+	BulkUploadMaxFiles  int
+	BulkUploadMaxSizeMB int // Total size of the extracted files
+
+	// Export Config
+	ExportMaxDocuments int
+	ExportMaxSizeMB    int
+	ExportTTL          int // hours an export archive can be downloaded
+
+	// Import Config: providers are connected through OAuth and redirect to
+	// /api/v1/imports/oauth/callback at the public URL of the API
+	ImportMaxFiles           int
+	ImportOAuthRedirectURL   string
+	GoogleDriveImportEnabled bool
+	GoogleDriveClientID      string
+	GoogleDriveClientSecret  string
+	SharePointImportEnabled  bool
+	SharePointTenantID       string // Directory ID, or "organizations" for any work account
+	SharePointClientID       string
+	SharePointClientSecret   string
+
+	// Ingest Config: files deposited in drop locations become documents
+	IngestInterval        int // seconds between polls of each drop
+	IngestMinAge          int // seconds a file must be unmodified before it is collected
+	IngestMaxFileSizeMB   int
+	SFTPIngestEnabled     bool
+	SFTPIngestAddress     string // host:port
+	SFTPIngestUser        string
+	SFTPIngestPassword    string
+	SFTPIngestKeyPath     string // Private key (PEM or OpenSSH format)
+	SFTPIngestHostKey     string // Server public key in authorized_keys format
+	SFTPIngestDirectory   string
+	SFTPIngestOwner       string // Username the documents are created as
+	SFTPIngestCategory    string
+	SFTPIngestFolderID    int    // 0 for the root folder
+	SFTPIngestAccessLevel int    // 0 classifies each file
+	WatchFoldersFile      string // JSON array of local or NFS directories to watch
+
+	// Inbound emails are posted by SendGrid Inbound Parse to
+	// /api/v1/inbound-email with the webhook secret as basic auth password
+	EmailIngestEnabled           bool
+	EmailIngestAddress           string // Recipient address, such as docs@example.com
+	EmailIngestWebhookSecret     string
+	EmailIngestRequireSenderAuth bool // Only file emails whose sender passed SPF or DKIM
+	EmailIngestMaxSizeMB         int
+	EmailIngestCategory          string
+	EmailIngestFolderID          int // 0 for the root folder
+	EmailIngestAccessLevel       int // 0 classifies each file
+
+	// CDN Config
+	CDNEnabled        bool
+	CDNProvider       string // cloudfront or cloudcdn
+	CDNBaseURL        string
+	CDNKeyID          string // CloudFront key pair ID or Cloud CDN key name
+	CDNPrivateKeyPath string // CloudFront RSA private key (PEM)
+	CDNSigningKey     string // Cloud CDN signing key (base64url)
+	CDNURLTTL         int    // minutes
+	CDNOriginSecret   string // Sent by the CDN to the origin in X-CDN-Origin-Secret
+
+	// Search Config
+	ElasticsearchEnabled  bool
+	ElasticsearchURL      string
+	ElasticsearchIndex    string
+	ElasticsearchUsername string
+	ElasticsearchPassword string
+
+	SemanticSearchEnabled bool
+	EmbeddingAPIURL       string
+	EmbeddingAPIKey       string
+	EmbeddingModel        string
+	EmbeddingDimensions   int
+
+	// Auto-tagging Config
+	AutoTagEnabled   bool
+	AutoTagProvider  string // keywords or model
+	AutoTagAPIURL    string
+	AutoTagAPIKey    string
+	AutoTagModel     string
+	AutoTagMaxTags   int
+	AutoTagAutoApply bool
+	AutoTagMinScore  float64 // Minimum score of auto-applied suggestions
+
+	// PDF conversion Config
+	PDFConversionEnabled bool
+	PDFConverter         string // libreoffice or gotenberg
+	LibreOfficePath      string
+	GotenbergURL         string
+	PDFConvertOnUpload   bool
+	PDFConversionTimeout int // seconds
+
+	// Download watermarking Config
+	WatermarkEnabled      bool
+	WatermarkAccessLevels []int // Access levels whose PDF downloads are watermarked
+	QPDFPath              string
+
+	// Data loss prevention Config
+	DLPEnabled        bool
+	DLPRules          []string // credit_card, my_number, us_ssn
+	DLPKeywords       []string
+	DLPAction         string // raise or block
+	DLPMinAccessLevel int    // Access level documents with findings are raised to
+
+	// Virus scanning Config
+	VirusScanEnabled  bool
+	VirusScanProvider string // clamav
+	VirusScanInterval int    // seconds
+	ClamAVAddress     string // host:port or Unix socket path of clamd
 
 	// CORS
 	AllowedOrigins []string
@@ -44,11 +358,13 @@ func Load() *Config {
 	}
 
 	config := &Config{
-		Port:        getEnv("PORT", "8080"),
-		DatabaseURL: getEnv("DATABASE_URL", ""),
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		Environment: getEnv("ENVIRONMENT", "development"),
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		Port:         getEnv("PORT", "8080"),
+		DatabaseURL:  getEnv("DATABASE_URL", ""),
+		JWTSecret:    getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		JWTIssuer:    getEnv("JWT_ISSUER", "datamanagement-system"),
+		JWTAudiences: getEnvAsList("JWT_AUDIENCES", "web=datamanagement-web,service=datamanagement-service"),
+		Environment:  getEnv("ENVIRONMENT", "development"),
+		LogLevel:     getEnv("LOG_LEVEL", "info"),
 
 		// Database
 		DBHost:     getEnv("DB_HOST", "localhost"),
@@ -58,15 +374,318 @@ func Load() *Config {
 		DBName:     getEnv("DB_NAME", "datamanagement_db"),
 		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
 
+		DBRowLevelSecurity: getEnvAsBool("DB_ROW_LEVEL_SECURITY", false),
+		DBAuditChanges:     getEnvAsBool("DB_AUDIT_CHANGES", true),
+
+		DBAuditMaintenanceUser:     getEnv("DB_AUDIT_MAINTENANCE_USER", ""),
+		DBAuditMaintenancePassword: getEnv("DB_AUDIT_MAINTENANCE_PASSWORD", ""),
+
 		// Blockchain
-		BlockchainEnabled: getEnvAsBool("BLOCKCHAIN_ENABLED", true),
-		GenesisBlock:      getEnv("GENESIS_BLOCK", ""),
+		BlockchainEnabled:           getEnvAsBool("BLOCKCHAIN_ENABLED", true),
+		GenesisBlock:                getEnv("GENESIS_BLOCK", ""),
+		BlockchainReconcileInterval: getEnvAsInt("BLOCKCHAIN_RECONCILE_INTERVAL", 60),
+		BlockchainMineInterval:      getEnvAsInt("BLOCKCHAIN_MINE_INTERVAL", 10),
+		BlockchainBlockSize:         getEnvAsInt("BLOCKCHAIN_BLOCK_SIZE", 100),
+		BlockchainTargetMiningTime:  getEnvAsInt("BLOCKCHAIN_TARGET_MINING_TIME", 500),
+		BlockchainMaxDifficulty:     getEnvAsInt("BLOCKCHAIN_MAX_DIFFICULTY", 6),
+		BlockchainConsensus:         getEnv("BLOCKCHAIN_CONSENSUS", "pow"),
+		BlockchainSigningKeyPath:    getEnv("BLOCKCHAIN_SIGNING_KEY_PATH", ""),
+		BlockchainAnchorCalendars:   getEnv("BLOCKCHAIN_ANCHOR_CALENDARS", ""),
+		BlockchainAnchorInterval:    getEnvAsInt("BLOCKCHAIN_ANCHOR_INTERVAL", 60),
+
+		// Trusted Timestamping
+		TimestampAuthorityURL: getEnv("TIMESTAMP_AUTHORITY_URL", ""),
+		TimestampCACertPath:   getEnv("TIMESTAMP_CA_CERT_PATH", ""),
+
+		// Document Signatures
+		SigningCertPath:   getEnv("SIGNING_CERT_PATH", ""),
+		SigningKeyPath:    getEnv("SIGNING_KEY_PATH", ""),
+		SigningCACertPath: getEnv("SIGNING_CA_CERT_PATH", ""),
+
+		// Internal PKI
+		PKICACertPath:    getEnv("PKI_CA_CERT_PATH", ""),
+		PKICAKeyPath:     getEnv("PKI_CA_KEY_PATH", ""),
+		PKICertValidity:  getEnvAsInt("PKI_CERT_VALIDITY", 365),
+		PKICRLValidity:   getEnvAsInt("PKI_CRL_VALIDITY", 24),
+		PKIRenewalWindow: getEnvAsInt("PKI_RENEWAL_WINDOW", 30),
 
 		// Security
-		EncryptionKey:    getEnv("ENCRYPTION_KEY", "32-character-encryption-key-here"),
-		TokenExpiry:      getEnvAsInt("TOKEN_EXPIRY", 15),
-		RefreshExpiry:    getEnvAsInt("REFRESH_EXPIRY", 7),
-		MaxLoginAttempts: getEnvAsInt("MAX_LOGIN_ATTEMPTS", 5),
+		EncryptionKey:      getEnv("ENCRYPTION_KEY", "32-character-encryption-key-here"),
+		KeyProvider:        getEnv("KEY_PROVIDER", "env"),
+		PreviousKey:        getEnv("PREVIOUS_ENCRYPTION_KEY", ""),
+		TokenExpiry:        getEnvAsInt("TOKEN_EXPIRY", 15),
+		RefreshExpiry:      getEnvAsInt("REFRESH_EXPIRY", 7),
+		MaxLoginAttempts:   getEnvAsInt("MAX_LOGIN_ATTEMPTS", 5),
+		MaxSessions:        getEnvAsInt("MAX_SESSIONS", 0),
+		SessionLimitAction: getEnv("SESSION_LIMIT_ACTION", "revoke_oldest"),
+		StepUpMaxAge:       getEnvAsInt("STEP_UP_MAX_AGE", 15),
+		StepUpAccessLevel:  getEnvAsInt("STEP_UP_ACCESS_LEVEL", 4),
+
+		DownloadJustificationLevel: getEnvAsInt("DOWNLOAD_JUSTIFICATION_ACCESS_LEVEL", 4),
+
+		// Password Policy
+		PasswordMinLength:        getEnvAsInt("PASSWORD_MIN_LENGTH", 12),
+		PasswordRequireUppercase: getEnvAsBool("PASSWORD_REQUIRE_UPPERCASE", true),
+		PasswordRequireLowercase: getEnvAsBool("PASSWORD_REQUIRE_LOWERCASE", true),
+		PasswordRequireDigit:     getEnvAsBool("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSymbol:    getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordBannedFile:       getEnv("PASSWORD_BANNED_FILE", ""),
+		PasswordHistoryDepth:     getEnvAsInt("PASSWORD_HISTORY_DEPTH", 5),
+
+		// Registration
+		RegistrationEnabled:        getEnvAsBool("REGISTRATION_ENABLED", false),
+		RegistrationAllowedDomains: getEnvAsList("REGISTRATION_ALLOWED_DOMAINS", ""),
+
+		// Public Portal
+		PublicPortalEnabled:   getEnvAsBool("PUBLIC_PORTAL_ENABLED", false),
+		PublicPortalRateLimit: getEnvAsInt("PUBLIC_PORTAL_RATE_LIMIT", 60),
+
+		// Share Links
+		ShareLinksEnabled: getEnvAsBool("SHARE_LINKS_ENABLED", false),
+		ShareLinkMaxDays:  getEnvAsInt("SHARE_LINK_MAX_DAYS", 30),
+
+		// SMTP
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
+		// API Quotas
+		APIDailyRequestQuota:     getEnvAsInt("API_DAILY_REQUEST_QUOTA", 10000),
+		APIDailyBandwidthQuotaMB: getEnvAsInt("API_DAILY_BANDWIDTH_QUOTA_MB", 0),
+
+		// OAuth
+		OAuthEnabled:  getEnvAsBool("OAUTH_ENABLED", false),
+		OAuthTokenTTL: getEnvAsInt("OAUTH_TOKEN_TTL", 60),
+
+		// Login Alerts
+		LoginAlertsEnabled: getEnvAsBool("LOGIN_ALERTS_ENABLED", false),
+
+		// Password Reset
+		PasswordResetEnabled:    getEnvAsBool("PASSWORD_RESET_ENABLED", false),
+		PasswordResetURL:        getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+		PasswordResetTTL:        getEnvAsInt("PASSWORD_RESET_TTL", 30),
+		PasswordResetMaxPerHour: getEnvAsInt("PASSWORD_RESET_MAX_PER_HOUR", 3),
+
+		// LDAP
+		LDAPEnabled:        getEnvAsBool("LDAP_ENABLED", false),
+		LDAPURL:            getEnv("LDAP_URL", ""),
+		LDAPStartTLS:       getEnvAsBool("LDAP_START_TLS", false),
+		LDAPCACertPath:     getEnv("LDAP_CA_CERT_PATH", ""),
+		LDAPBindDN:         getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:   getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:         getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:     getEnv("LDAP_USER_FILTER", "(&(objectClass=user)(sAMAccountName=%s))"),
+		LDAPGroupAttribute: getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		LDAPTimeout:        getEnvAsInt("LDAP_TIMEOUT", 10),
+
+		// Directory
+		DirectoryAdminGroups:      getEnvAsList("DIRECTORY_ADMIN_GROUPS", ""),
+		DirectoryManagerGroups:    getEnvAsList("DIRECTORY_MANAGER_GROUPS", ""),
+		DirectoryEmployeeGroups:   getEnvAsList("DIRECTORY_EMPLOYEE_GROUPS", ""),
+		DirectoryGuestGroups:      getEnvAsList("DIRECTORY_GUEST_GROUPS", ""),
+		DirectoryDepartmentGroups: getEnvAsList("DIRECTORY_DEPARTMENT_GROUPS", ""),
+		DirectoryDefaultRole:      getEnv("DIRECTORY_DEFAULT_ROLE", "employee"),
+
+		// Directory Sync
+		DirectorySyncEnabled:          getEnvAsBool("DIRECTORY_SYNC_ENABLED", false),
+		DirectorySyncProvider:         getEnv("DIRECTORY_SYNC_PROVIDER", "ldap"),
+		DirectorySyncInterval:         getEnvAsInt("DIRECTORY_SYNC_INTERVAL", 60),
+		DirectorySyncDryRun:           getEnvAsBool("DIRECTORY_SYNC_DRY_RUN", true),
+		DirectorySyncLDAPFilter:       getEnv("DIRECTORY_SYNC_LDAP_FILTER", "(&(objectClass=user)(objectCategory=person))"),
+		DirectorySyncLDAPUsernameAttr: getEnv("DIRECTORY_SYNC_LDAP_USERNAME_ATTRIBUTE", "sAMAccountName"),
+		OktaOrgURL:                    getEnv("OKTA_ORG_URL", ""),
+		OktaAPIToken:                  getEnv("OKTA_API_TOKEN", ""),
+
+		// WebAuthn
+		WebAuthnEnabled:      getEnvAsBool("WEBAUTHN_ENABLED", false),
+		WebAuthnRPID:         getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:       getEnv("WEBAUTHN_RP_NAME", "In-house Data Management"),
+		WebAuthnOrigins:      getEnvAsList("WEBAUTHN_ORIGINS", "http://localhost:3000"),
+		WebAuthnPasswordless: getEnvAsBool("WEBAUTHN_PASSWORDLESS", true),
+		WebAuthnTimeout:      getEnvAsInt("WEBAUTHN_TIMEOUT", 300),
+
+		// Key Provider
+		VaultAddr:     getEnv("VAULT_ADDR", "http://localhost:8200"),
+		VaultToken:    getEnv("VAULT_TOKEN", ""),
+		VaultKeyPath:  getEnv("VAULT_KEY_PATH", "secret/data/datamanagement/encryption"),
+		VaultKeyField: getEnv("VAULT_KEY_FIELD", "master_key"),
+
+		AWSRegion:          getEnv("AWS_REGION", "us-east-1"),
+		AWSAccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
+		AWSSecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		AWSSessionToken:    getEnv("AWS_SESSION_TOKEN", ""),
+		KMSEncryptedKey:    getEnv("KMS_ENCRYPTED_KEY", ""),
+
+		// Storage
+		StoragePath:            getEnv("STORAGE_PATH", "./storage"),
+		TrashRetentionDays:     getEnvAsInt("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeInterval:     getEnvAsInt("TRASH_PURGE_INTERVAL", 60),
+		IntegrityCheckInterval: getEnvAsInt("INTEGRITY_CHECK_INTERVAL", 1440),
+
+		// Audit Log Archival
+		AuditRetentionDays:     getEnvAsInt("AUDIT_RETENTION_DAYS", 0),
+		AuditArchiveInterval:   getEnvAsInt("AUDIT_ARCHIVE_INTERVAL", 1440),
+		AuditArchiveStore:      getEnv("AUDIT_ARCHIVE_STORE", "local"),
+		AuditArchivePath:       getEnv("AUDIT_ARCHIVE_PATH", "./audit-archive"),
+		AuditArchiveS3Endpoint: getEnv("AUDIT_ARCHIVE_S3_ENDPOINT", ""),
+		AuditArchiveS3Bucket:   getEnv("AUDIT_ARCHIVE_S3_BUCKET", ""),
+		AuditPartitionInterval: getEnvAsInt("AUDIT_PARTITION_INTERVAL", 1440),
+
+		// SIEM Streaming
+		SIEMSink:          getEnv("SIEM_SINK", ""),
+		SIEMSyslogNetwork: getEnv("SIEM_SYSLOG_NETWORK", "tcp"),
+		SIEMSyslogAddress: getEnv("SIEM_SYSLOG_ADDRESS", ""),
+		SIEMHECURL:        getEnv("SIEM_HEC_URL", ""),
+		SIEMHECToken:      getEnv("SIEM_HEC_TOKEN", ""),
+		SIEMHECIndex:      getEnv("SIEM_HEC_INDEX", ""),
+		SIEMHECSourceType: getEnv("SIEM_HEC_SOURCETYPE", "datamanagement:audit"),
+		SIEMBufferSize:    getEnvAsInt("SIEM_BUFFER_SIZE", 10000),
+
+		// Outbox
+		OutboxDispatchInterval: getEnvAsInt("OUTBOX_DISPATCH_INTERVAL", 5),
+		OutboxMaxAttempts:      getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 10),
+
+		// Security Detection
+		SecurityDetectionInterval:     getEnvAsInt("SECURITY_DETECTION_INTERVAL", 5),
+		SecurityFailedLoginThreshold:  getEnvAsInt("SECURITY_FAILED_LOGIN_THRESHOLD", 10),
+		SecurityMassDownloadThreshold: getEnvAsInt("SECURITY_MASS_DOWNLOAD_THRESHOLD", 50),
+		SecurityBusinessHoursStart:    getEnvAsInt("SECURITY_BUSINESS_HOURS_START", 8),
+		SecurityBusinessHoursEnd:      getEnvAsInt("SECURITY_BUSINESS_HOURS_END", 19),
+		SecurityTimezone:              getEnv("SECURITY_TIMEZONE", "UTC"),
+
+		// Security Alert Notifications
+		SecurityAlertChannels:            getEnvAsList("SECURITY_ALERT_CHANNELS", ""),
+		SecurityAlertMinSeverity:         getEnv("SECURITY_ALERT_MIN_SEVERITY", "high"),
+		SecurityAlertEmails:              getEnvAsList("SECURITY_ALERT_EMAILS", ""),
+		SecurityAlertSlackWebhookURL:     getEnv("SECURITY_ALERT_SLACK_WEBHOOK_URL", ""),
+		SecurityAlertPagerDutyRoutingKey: getEnv("SECURITY_ALERT_PAGERDUTY_ROUTING_KEY", ""),
+		SecurityAlertURL:                 getEnv("SECURITY_ALERT_URL", ""),
+
+		// Chunked Uploads
+		UploadMaxSizeMB:      getEnvAsInt("UPLOAD_MAX_SIZE_MB", 2048),
+		UploadMaxChunkSizeMB: getEnvAsInt("UPLOAD_MAX_CHUNK_SIZE_MB", 64),
+		UploadSessionTTL:     getEnvAsInt("UPLOAD_SESSION_TTL", 24),
+
+		// Request size limits
+		MaxRequestBodyMB:        getEnvAsInt("MAX_REQUEST_BODY_MB", 10),
+		UploadMaxSizeAdminMB:    getEnvAsInt("UPLOAD_MAX_SIZE_ADMIN_MB", 2048),
+		UploadMaxSizeManagerMB:  getEnvAsInt("UPLOAD_MAX_SIZE_MANAGER_MB", 2048),
+		UploadMaxSizeEmployeeMB: getEnvAsInt("UPLOAD_MAX_SIZE_EMPLOYEE_MB", 100),
+		UploadMaxSizeGuestMB:    getEnvAsInt("UPLOAD_MAX_SIZE_GUEST_MB", 10),
+
+		// Upload file types
+		UploadAllowedExtensions: getEnvAsList("UPLOAD_ALLOWED_EXTENSIONS", ""),
+		UploadAllowedTypes:      getEnvAsList("UPLOAD_ALLOWED_TYPES", ""),
+		UploadBlockedExtensions: getEnvAsList("UPLOAD_BLOCKED_EXTENSIONS", ".exe,.dll,.com,.scr,.msi,.bat,.cmd,.ps1,.vbs,.js,.jar,.sh,.app"),
+		UploadBlockedTypes:      getEnvAsList("UPLOAD_BLOCKED_TYPES", "application/x-msdownload,application/x-elf,application/x-mach-binary,application/java-vm,text/x-shellscript"),
+
+		// Bulk uploads
+		BulkUploadMaxFiles:  getEnvAsInt("BULK_UPLOAD_MAX_FILES", 1000),
+		BulkUploadMaxSizeMB: getEnvAsInt("BULK_UPLOAD_MAX_SIZE_MB", 4096),
+
+		// Exports
+		ExportMaxDocuments: getEnvAsInt("EXPORT_MAX_DOCUMENTS", 1000),
+		ExportMaxSizeMB:    getEnvAsInt("EXPORT_MAX_SIZE_MB", 2048),
+		ExportTTL:          getEnvAsInt("EXPORT_TTL", 24),
+
+		// Imports
+		ImportMaxFiles:           getEnvAsInt("IMPORT_MAX_FILES", 5000),
+		ImportOAuthRedirectURL:   getEnv("IMPORT_OAUTH_REDIRECT_URL", "http://localhost:8080/api/v1/imports/oauth/callback"),
+		GoogleDriveImportEnabled: getEnvAsBool("GOOGLE_DRIVE_IMPORT_ENABLED", false),
+		GoogleDriveClientID:      getEnv("GOOGLE_DRIVE_CLIENT_ID", ""),
+		GoogleDriveClientSecret:  getEnv("GOOGLE_DRIVE_CLIENT_SECRET", ""),
+		SharePointImportEnabled:  getEnvAsBool("SHAREPOINT_IMPORT_ENABLED", false),
+		SharePointTenantID:       getEnv("SHAREPOINT_TENANT_ID", "organizations"),
+		SharePointClientID:       getEnv("SHAREPOINT_CLIENT_ID", ""),
+		SharePointClientSecret:   getEnv("SHAREPOINT_CLIENT_SECRET", ""),
+
+		// Ingest
+		IngestInterval:        getEnvAsInt("INGEST_INTERVAL", 60),
+		IngestMinAge:          getEnvAsInt("INGEST_MIN_AGE", 30),
+		IngestMaxFileSizeMB:   getEnvAsInt("INGEST_MAX_FILE_SIZE_MB", 100),
+		SFTPIngestEnabled:     getEnvAsBool("SFTP_INGEST_ENABLED", false),
+		SFTPIngestAddress:     getEnv("SFTP_INGEST_ADDRESS", ""),
+		SFTPIngestUser:        getEnv("SFTP_INGEST_USER", ""),
+		SFTPIngestPassword:    getEnv("SFTP_INGEST_PASSWORD", ""),
+		SFTPIngestKeyPath:     getEnv("SFTP_INGEST_PRIVATE_KEY_PATH", ""),
+		SFTPIngestHostKey:     getEnv("SFTP_INGEST_HOST_KEY", ""),
+		SFTPIngestDirectory:   getEnv("SFTP_INGEST_DIRECTORY", "."),
+		SFTPIngestOwner:       getEnv("SFTP_INGEST_OWNER", ""),
+		SFTPIngestCategory:    getEnv("SFTP_INGEST_CATEGORY", "scanned"),
+		SFTPIngestFolderID:    getEnvAsInt("SFTP_INGEST_FOLDER_ID", 0),
+		SFTPIngestAccessLevel: getEnvAsInt("SFTP_INGEST_ACCESS_LEVEL", 0),
+		WatchFoldersFile:      getEnv("WATCH_FOLDERS_FILE", ""),
+
+		// Inbound email
+		EmailIngestEnabled:           getEnvAsBool("EMAIL_INGEST_ENABLED", false),
+		EmailIngestAddress:           getEnv("EMAIL_INGEST_ADDRESS", ""),
+		EmailIngestWebhookSecret:     getEnv("EMAIL_INGEST_WEBHOOK_SECRET", ""),
+		EmailIngestRequireSenderAuth: getEnvAsBool("EMAIL_INGEST_REQUIRE_SENDER_AUTH", true),
+		EmailIngestMaxSizeMB:         getEnvAsInt("EMAIL_INGEST_MAX_SIZE_MB", 30),
+		EmailIngestCategory:          getEnv("EMAIL_INGEST_CATEGORY", "email"),
+		EmailIngestFolderID:          getEnvAsInt("EMAIL_INGEST_FOLDER_ID", 0),
+		EmailIngestAccessLevel:       getEnvAsInt("EMAIL_INGEST_ACCESS_LEVEL", 0),
+
+		// CDN
+		CDNEnabled:        getEnvAsBool("CDN_ENABLED", false),
+		CDNProvider:       getEnv("CDN_PROVIDER", "cloudfront"),
+		CDNBaseURL:        getEnv("CDN_BASE_URL", ""),
+		CDNKeyID:          getEnv("CDN_KEY_ID", ""),
+		CDNPrivateKeyPath: getEnv("CDN_PRIVATE_KEY_PATH", ""),
+		CDNSigningKey:     getEnv("CDN_SIGNING_KEY", ""),
+		CDNURLTTL:         getEnvAsInt("CDN_URL_TTL", 15),
+		CDNOriginSecret:   getEnv("CDN_ORIGIN_SECRET", ""),
+
+		// Search
+		ElasticsearchEnabled:  getEnvAsBool("ELASTICSEARCH_ENABLED", false),
+		ElasticsearchURL:      getEnv("ELASTICSEARCH_URL", "http://localhost:9200"),
+		ElasticsearchIndex:    getEnv("ELASTICSEARCH_INDEX", "documents"),
+		ElasticsearchUsername: getEnv("ELASTICSEARCH_USERNAME", ""),
+		ElasticsearchPassword: getEnv("ELASTICSEARCH_PASSWORD", ""),
+
+		SemanticSearchEnabled: getEnvAsBool("SEMANTIC_SEARCH_ENABLED", false),
+		EmbeddingAPIURL:       getEnv("EMBEDDING_API_URL", "https://api.openai.com/v1/embeddings"),
+		EmbeddingAPIKey:       getEnv("EMBEDDING_API_KEY", ""),
+		EmbeddingModel:        getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		EmbeddingDimensions:   getEnvAsInt("EMBEDDING_DIMENSIONS", 1536),
+
+		// Auto-tagging
+		AutoTagEnabled:   getEnvAsBool("AUTO_TAG_ENABLED", false),
+		AutoTagProvider:  getEnv("AUTO_TAG_PROVIDER", "keywords"),
+		AutoTagAPIURL:    getEnv("AUTO_TAG_API_URL", ""),
+		AutoTagAPIKey:    getEnv("AUTO_TAG_API_KEY", ""),
+		AutoTagModel:     getEnv("AUTO_TAG_MODEL", ""),
+		AutoTagMaxTags:   getEnvAsInt("AUTO_TAG_MAX_TAGS", 5),
+		AutoTagAutoApply: getEnvAsBool("AUTO_TAG_AUTO_APPLY", false),
+		AutoTagMinScore:  getEnvAsFloat("AUTO_TAG_MIN_SCORE", 0.8),
+
+		// PDF conversion
+		PDFConversionEnabled: getEnvAsBool("PDF_CONVERSION_ENABLED", false),
+		PDFConverter:         getEnv("PDF_CONVERTER", "libreoffice"),
+		LibreOfficePath:      getEnv("LIBREOFFICE_PATH", "soffice"),
+		GotenbergURL:         getEnv("GOTENBERG_URL", "http://localhost:3000"),
+		PDFConvertOnUpload:   getEnvAsBool("PDF_CONVERT_ON_UPLOAD", false),
+		PDFConversionTimeout: getEnvAsInt("PDF_CONVERSION_TIMEOUT", 120),
+
+		// Download watermarking
+		WatermarkEnabled:      getEnvAsBool("WATERMARK_ENABLED", false),
+		WatermarkAccessLevels: getEnvAsIntList("WATERMARK_ACCESS_LEVELS", "3,4,5"),
+		QPDFPath:              getEnv("QPDF_PATH", "qpdf"),
+
+		// Data loss prevention
+		DLPEnabled:        getEnvAsBool("DLP_ENABLED", false),
+		DLPRules:          getEnvAsList("DLP_RULES", "credit_card,my_number,us_ssn"),
+		DLPKeywords:       getEnvAsList("DLP_KEYWORDS", ""),
+		DLPAction:         getEnv("DLP_ACTION", "raise"),
+		DLPMinAccessLevel: getEnvAsInt("DLP_MIN_ACCESS_LEVEL", 3),
+
+		// Virus scanning
+		VirusScanEnabled:  getEnvAsBool("VIRUS_SCAN_ENABLED", false),
+		VirusScanProvider: getEnv("VIRUS_SCAN_PROVIDER", "clamav"),
+		VirusScanInterval: getEnvAsInt("VIRUS_SCAN_INTERVAL", 10),
+		ClamAVAddress:     getEnv("CLAMAV_ADDRESS", "localhost:3310"),
 
 		// CORS
 		AllowedOrigins: []string{
@@ -102,3 +721,32 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvAsIntList(key, defaultValue string) []int {
+	var list []int
+	for _, item := range getEnvAsList(key, defaultValue) {
+		if intValue, err := strconv.Atoi(item); err == nil {
+			list = append(list, intValue)
+		}
+	}
+	return list
+}
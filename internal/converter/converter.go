@@ -0,0 +1,29 @@
+package converter
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// Converter renders office documents to PDF
+type Converter interface {
+	// ConvertToPDF renders data, originally named fileName, as a PDF
+	ConvertToPDF(ctx context.Context, fileName string, data []byte) ([]byte, error)
+	// Name returns the converter name for logging
+	Name() string
+}
+
+// convertibleExtensions lists the office formats rendered to PDF
+var convertibleExtensions = map[string]bool{
+	".doc": true, ".docx": true, ".odt": true, ".rtf": true,
+	".xls": true, ".xlsx": true, ".ods": true, ".csv": true,
+	".ppt": true, ".pptx": true, ".odp": true,
+	".txt": true,
+}
+
+// Convertible reports whether a file can be converted to PDF, judged by its
+// extension since office formats share generic content types
+func Convertible(fileName string) bool {
+	return convertibleExtensions[strings.ToLower(filepath.Ext(fileName))]
+}
@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// GotenbergClient converts documents through a Gotenberg service, which runs
+// LibreOffice behind an HTTP API
+type GotenbergClient struct {
+	client *http.Client
+	url    string
+}
+
+// NewGotenbergClient creates a new Gotenberg client for the service at url
+func NewGotenbergClient(url string) *GotenbergClient {
+	return &GotenbergClient{
+		client: &http.Client{},
+		url:    strings.TrimRight(url, "/"),
+	}
+}
+
+// Name returns the converter name
+func (c *GotenbergClient) Name() string {
+	return "gotenberg"
+}
+
+// ConvertToPDF posts data to the LibreOffice conversion route
+func (c *GotenbergClient) ConvertToPDF(ctx context.Context, fileName string, data []byte) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	// Gotenberg picks the input format from the file extension
+	part, err := writer.CreateFormFile("files", "input"+strings.ToLower(filepath.Ext(fileName)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/forms/libreoffice/convert", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("conversion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("conversion service returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	pdf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read converted PDF: %w", err)
+	}
+
+	return pdf, nil
+}
@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// LibreOfficeConverter converts documents with a local headless LibreOffice
+type LibreOfficeConverter struct {
+	binary string
+}
+
+// NewLibreOfficeConverter creates a converter running the soffice binary
+func NewLibreOfficeConverter(binary string) *LibreOfficeConverter {
+	return &LibreOfficeConverter{binary: binary}
+}
+
+// Name returns the converter name
+func (c *LibreOfficeConverter) Name() string {
+	return "libreoffice"
+}
+
+// ConvertToPDF writes data to a temporary directory and converts it there.
+// Each conversion uses its own LibreOffice profile so conversions can run
+// concurrently.
+func (c *LibreOfficeConverter) ConvertToPDF(ctx context.Context, fileName string, data []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "convert-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversion directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	input := filepath.Join(dir, "input"+ext)
+	if err := os.WriteFile(input, data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write conversion input: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.binary,
+		"-env:UserInstallation=file://"+filepath.ToSlash(filepath.Join(dir, "profile")),
+		"--headless", "--norestore",
+		"--convert-to", "pdf",
+		"--outdir", dir,
+		input,
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("libreoffice conversion failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	pdf, err := os.ReadFile(filepath.Join(dir, "input.pdf"))
+	if err != nil {
+		return nil, fmt.Errorf("libreoffice produced no PDF: %w", err)
+	}
+
+	return pdf, nil
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// maintenanceDB connects as the audit maintenance user, when configured
+var maintenanceDB *gorm.DB
+
+// applicationRole is the server's own database role, set along with
+// maintenanceDB
+var applicationRole string
+
+// ErrAuditLogImmutable is returned for updates and deletes of audit log
+// entries
+var ErrAuditLogImmutable = errors.New("audit log entries are immutable")
+
+// auditMaintenanceRole is the role whose members may delete and move audit
+// log entries past the triggers. The server doesn't create it; its own role
+// must not be a member, so its credentials can't change the log.
+const auditMaintenanceRole = "audit_maintenance"
+
+// auditMaintainerSQL reports whether the current user is a member of
+// auditMaintenanceRole, false while the role doesn't exist
+const auditMaintainerSQL = `COALESCE((SELECT pg_has_role(current_user, oid, 'MEMBER')
+	FROM pg_roles WHERE rolname = '` + auditMaintenanceRole + `'), false)`
+
+// auditImmutabilityFunction rejects changes to audit log entries unless they
+// are made by the maintenance role, archiving or maintaining partitions
+const auditImmutabilityFunction = `CREATE OR REPLACE FUNCTION audit_logs_immutable() RETURNS trigger AS $$
+BEGIN
+	IF ` + auditMaintainerSQL + ` THEN
+		IF TG_OP = 'UPDATE' THEN
+			RETURN NEW;
+		END IF;
+		RETURN OLD;
+	END IF;
+	RAISE EXCEPTION 'audit log entries are immutable: % on %', TG_OP, TG_TABLE_NAME
+		USING ERRCODE = 'insufficient_privilege';
+END;
+$$ LANGUAGE plpgsql`
+
+// migrateAuditImmutability protects audit log entries from being changed or
+// removed by anyone connecting to the database but the maintenance role. Row
+// triggers on audit_logs
+// are cloned onto every partition, including ones attached later.
+func migrateAuditImmutability() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`ALTER TABLE audit_logs DROP COLUMN IF EXISTS deleted_at`,
+			auditImmutabilityFunction,
+			`DROP TRIGGER IF EXISTS audit_logs_immutable ON audit_logs`,
+			`CREATE TRIGGER audit_logs_immutable BEFORE UPDATE OR DELETE ON audit_logs
+				FOR EACH ROW EXECUTE FUNCTION audit_logs_immutable()`,
+			`DROP TRIGGER IF EXISTS audit_logs_truncate ON audit_logs`,
+			`CREATE TRIGGER audit_logs_truncate BEFORE TRUNCATE ON audit_logs
+				FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_immutable()`,
+			`REVOKE UPDATE, DELETE, TRUNCATE ON audit_logs FROM PUBLIC`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AuditMaintenanceDB returns the connection archival and partition
+// maintenance run on, as the audit maintenance user. Without one configured
+// it is the server's own connection, which can't delete or move existing
+// entries.
+func AuditMaintenanceDB() *gorm.DB {
+	if maintenanceDB != nil {
+		return maintenanceDB
+	}
+	return DB
+}
+
+// connectAuditMaintenance connects as the audit maintenance user with the
+// other settings of dsn. The user must be a member of auditMaintenanceRole
+// and of the server's role, whose tables it maintains; the server's role
+// must not be a member of auditMaintenanceRole.
+func connectAuditMaintenance(db *gorm.DB, dsn, user, password string, config *gorm.Config) (*gorm.DB, error) {
+	var serverIsMaintainer bool
+	if err := db.Raw(`SELECT current_user, `+auditMaintainerSQL).Row().Scan(&applicationRole, &serverIsMaintainer); err != nil {
+		return nil, fmt.Errorf("failed to check the database role: %w", err)
+	}
+	if serverIsMaintainer {
+		return nil, fmt.Errorf("database user %s must not be a member of %s", applicationRole, auditMaintenanceRole)
+	}
+
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect for audit maintenance: %w", err)
+	}
+	connConfig.User = user
+	connConfig.Password = password
+
+	maintenance, err := gorm.Open(postgres.New(postgres.Config{Conn: stdlib.OpenDB(*connConfig)}), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect for audit maintenance: %w", err)
+	}
+	sqlDB, err := maintenance.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit maintenance database instance: %w", err)
+	}
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetMaxOpenConns(2)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	var maintainer bool
+	if err := maintenance.Raw(`SELECT ` + auditMaintainerSQL).Scan(&maintainer).Error; err != nil {
+		return nil, fmt.Errorf("failed to check the audit maintenance role: %w", err)
+	}
+	if !maintainer {
+		return nil, fmt.Errorf("audit maintenance user %s is not a member of %s", user, auditMaintenanceRole)
+	}
+	return maintenance, nil
+}
+
+// ownByApplication hands a table created during maintenance to the server's
+// role, which owns the rest of the schema
+func ownByApplication(tx *gorm.DB, table string) error {
+	if applicationRole == "" {
+		return nil
+	}
+	if err := tx.Exec(`ALTER TABLE ` + table + ` OWNER TO ` + pgx.Identifier{applicationRole}.Sanitize()).Error; err != nil {
+		return fmt.Errorf("failed to hand %s to %s: %w", table, applicationRole, err)
+	}
+	return nil
+}
+
+// registerAuditImmutability makes the ORM refuse updates and deletes of
+// audit log entries before they reach the database
+func registerAuditImmutability(db *gorm.DB) error {
+	callbacks := []error{
+		db.Callback().Update().Before("gorm:update").Register("audit:immutable_update", rejectAuditMutation),
+		db.Callback().Delete().Before("gorm:delete").Register("audit:immutable_delete", rejectAuditMutation),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return fmt.Errorf("failed to register audit log callbacks: %w", err)
+		}
+	}
+	return nil
+}
+
+// rejectAuditMutation fails statements on audit_logs or its partitions
+func rejectAuditMutation(db *gorm.DB) {
+	table := db.Statement.Table
+	if table == "audit_logs" || strings.HasPrefix(table, auditPartitionPrefix) {
+		db.AddError(ErrAuditLogImmutable)
+	}
+}
@@ -0,0 +1,425 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Statement settings of change auditing
+const (
+	actorSetting    = "audit:actor"
+	snapshotSetting = "audit:snapshot"
+)
+
+// auditedTables are the tables whose changes are recorded in the audit log,
+// with their resource type
+var auditedTables = map[string]string{
+	"users":       "user",
+	"documents":   "document",
+	"permissions": "permission",
+}
+
+// unauditedColumns change as a matter of course, such as on every login or
+// integrity check, or are derived from other columns; they aren't recorded
+var unauditedColumns = map[string]bool{
+	"updated_at":           true,
+	"search_vector":        true,
+	"last_login":           true,
+	"login_attempts":       true,
+	"integrity_checked_at": true,
+}
+
+// redactedColumns hold secrets; changes to them are recorded without the
+// values
+var redactedColumns = map[string]bool{
+	"users.password":     true,
+	"documents.data_key": true,
+}
+
+// redacted replaces the values of redacted columns
+const redacted = "[redacted]"
+
+// AuditActor is who changes records through a database handle
+type AuditActor struct {
+	UserID    uint
+	IPAddress string
+	UserAgent string
+}
+
+// WithAuditActor attributes the changes made through db to an actor. Changes
+// without one are recorded as made by the system.
+func WithAuditActor(db *gorm.DB, actor AuditActor) *gorm.DB {
+	return db.Set(actorSetting, actor)
+}
+
+// registerChangeAuditing records the changes to users, documents and
+// permissions made through the ORM in the audit log, with the values of
+// changed columns before and after. Entries are written to the outbox in
+// the transaction of the change, which fails if they can't be. Raw
+// statements aren't recorded.
+func registerChangeAuditing(db *gorm.DB) error {
+	callbacks := []error{
+		db.Callback().Create().After("gorm:create").Register("audit:record_create", recordCreate),
+		db.Callback().Update().Before("gorm:update").Register("audit:snapshot_update", snapshotChange),
+		db.Callback().Update().After("gorm:update").Register("audit:record_update", recordUpdate),
+		db.Callback().Delete().Before("gorm:delete").Register("audit:snapshot_delete", snapshotChange),
+		db.Callback().Delete().After("gorm:delete").Register("audit:record_delete", recordDelete),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return fmt.Errorf("failed to register change audit callbacks: %w", err)
+		}
+	}
+	return nil
+}
+
+// auditedStatement reports whether a statement changes an audited table
+func auditedStatement(db *gorm.DB) bool {
+	if db.Error != nil || db.DryRun {
+		return false
+	}
+	_, ok := auditedTables[db.Statement.Table]
+	return ok
+}
+
+// snapshotChange reads the rows an update or delete is about to change
+func snapshotChange(db *gorm.DB) {
+	if !auditedStatement(db) {
+		return
+	}
+
+	query, ok := changedRows(db)
+	if !ok {
+		return
+	}
+
+	var rows []map[string]interface{}
+	if err := query.Find(&rows).Error; err != nil {
+		db.AddError(fmt.Errorf("failed to read records before change: %w", err))
+		return
+	}
+	db.InstanceSet(snapshotSetting, rows)
+}
+
+// changedRows builds the query for the rows a statement changes: those
+// matching its conditions and, when given records, their IDs. Statements
+// without either are refused by the ORM anyway.
+func changedRows(db *gorm.DB) (*gorm.DB, bool) {
+	query := newSession(db)
+	conditions := false
+	if where, ok := db.Statement.Clauses["WHERE"]; ok {
+		if expression, ok := where.Expression.(clause.Where); ok && len(expression.Exprs) > 0 {
+			query = query.Clauses(clause.Where{Exprs: expression.Exprs})
+			conditions = true
+		}
+	}
+	if ids := primaryKeys(db.Statement); len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+		conditions = true
+	}
+	return query, conditions
+}
+
+// recordCreate records created rows with their values
+func recordCreate(db *gorm.DB) {
+	if !auditedStatement(db) || db.Statement.RowsAffected == 0 {
+		return
+	}
+	ids := primaryKeys(db.Statement)
+	if len(ids) == 0 {
+		return
+	}
+	after, err := readRows(db, ids)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	var entries []*models.AuditLog
+	for _, id := range ids {
+		row, ok := after[id]
+		if !ok {
+			continue
+		}
+		entries = append(entries, changeEntry(db, "record_create", id, row, map[string]interface{}{
+			"after": presentRow(db.Statement.Table, row),
+		}))
+	}
+	storeChanges(db, entries)
+}
+
+// recordUpdate records the columns that changed in each updated row
+func recordUpdate(db *gorm.DB) {
+	before, ids, ok := snapshot(db)
+	if !ok {
+		return
+	}
+	after, err := readRows(db, ids)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	var entries []*models.AuditLog
+	for _, id := range ids {
+		row, ok := after[id]
+		if !ok {
+			continue
+		}
+		changes := diffRows(db.Statement.Table, before[id], row)
+		if len(changes) == 0 {
+			continue
+		}
+		entries = append(entries, changeEntry(db, "record_update", id, row, map[string]interface{}{
+			"changes": changes,
+		}))
+	}
+	storeChanges(db, entries)
+}
+
+// recordDelete records deleted rows with their values before. Soft deletes
+// record the deletion time as well.
+func recordDelete(db *gorm.DB) {
+	before, ids, ok := snapshot(db)
+	if !ok {
+		return
+	}
+	after, err := readRows(db, ids)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	var entries []*models.AuditLog
+	for _, id := range ids {
+		row, remaining := after[id]
+		details := map[string]interface{}{
+			"before": presentRow(db.Statement.Table, before[id]),
+		}
+		if remaining {
+			// Soft deleted, or not matched by the delete at all
+			if reflect.DeepEqual(row["deleted_at"], before[id]["deleted_at"]) {
+				continue
+			}
+			details["deleted_at"] = row["deleted_at"]
+		}
+		entries = append(entries, changeEntry(db, "record_delete", id, before[id], details))
+	}
+	storeChanges(db, entries)
+}
+
+// snapshot returns the rows read before an update or delete by ID
+func snapshot(db *gorm.DB) (map[uint]map[string]interface{}, []uint, bool) {
+	if !auditedStatement(db) || db.Statement.RowsAffected == 0 {
+		return nil, nil, false
+	}
+	value, ok := db.InstanceGet(snapshotSetting)
+	if !ok {
+		return nil, nil, false
+	}
+
+	rows := make(map[uint]map[string]interface{})
+	var ids []uint
+	for _, row := range value.([]map[string]interface{}) {
+		id, ok := rowID(row)
+		if !ok {
+			continue
+		}
+		rows[id] = row
+		ids = append(ids, id)
+	}
+	return rows, ids, len(ids) > 0
+}
+
+// newSession starts a statement on the model or table of db in its
+// transaction, including soft deleted rows
+func newSession(db *gorm.DB) *gorm.DB {
+	session := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Unscoped()
+	if db.Statement.Schema != nil {
+		return session.Model(reflect.New(db.Statement.Schema.ModelType).Interface())
+	}
+	return session.Table(db.Statement.Table)
+}
+
+// readRows reads rows of the statement's table by ID
+func readRows(db *gorm.DB, ids []uint) (map[uint]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	if err := newSession(db).Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read records after change: %w", err)
+	}
+	byID := make(map[uint]map[string]interface{}, len(rows))
+	for _, row := range rows {
+		if id, ok := rowID(row); ok {
+			byID[id] = row
+		}
+	}
+	return byID, nil
+}
+
+// primaryKeys returns the non-zero IDs of the records a statement was given
+func primaryKeys(stmt *gorm.Statement) []uint {
+	if stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+
+	var ids []uint
+	collect := func(value reflect.Value) {
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return
+			}
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct {
+			return
+		}
+		if id, zero := field.ValueOf(stmt.Context, value); !zero {
+			if id, ok := id.(uint); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			collect(stmt.ReflectValue.Index(i))
+		}
+	default:
+		collect(stmt.ReflectValue)
+	}
+	return ids
+}
+
+// rowID returns the ID column of a row
+func rowID(row map[string]interface{}) (uint, bool) {
+	return idValue(row["id"])
+}
+
+// idValue converts an ID column value as read from the database
+func idValue(value interface{}) (uint, bool) {
+	switch id := value.(type) {
+	case int64:
+		return uint(id), true
+	case int32:
+		return uint(id), true
+	default:
+		return 0, false
+	}
+}
+
+// diffRows returns the audited columns that differ between two versions of
+// a row, with their values before and after
+func diffRows(table string, before, after map[string]interface{}) map[string]interface{} {
+	changes := make(map[string]interface{})
+	for column, value := range after {
+		if unauditedColumns[column] || reflect.DeepEqual(before[column], value) {
+			continue
+		}
+		change := map[string]interface{}{"before": before[column], "after": value}
+		if redactedColumns[table+"."+column] {
+			change = map[string]interface{}{"before": redacted, "after": redacted}
+		}
+		changes[column] = change
+	}
+	return changes
+}
+
+// presentRow returns the audited columns of a row with the redacted ones
+// replaced
+func presentRow(table string, row map[string]interface{}) map[string]interface{} {
+	presented := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		if unauditedColumns[column] {
+			continue
+		}
+		if redactedColumns[table+"."+column] {
+			value = redacted
+		}
+		presented[column] = value
+	}
+	return presented
+}
+
+// changeEntry builds the audit entry of a change to a row. It belongs to the
+// organization of the row, or of the document or folder of a permission.
+func changeEntry(db *gorm.DB, action string, id uint, row map[string]interface{}, details map[string]interface{}) *models.AuditLog {
+	table := db.Statement.Table
+	entry := &models.AuditLog{
+		Action:       action,
+		ResourceType: auditedTables[table],
+		ResourceID:   strconv.FormatUint(uint64(id), 10),
+		Timestamp:    time.Now(),
+	}
+	if actor, ok := db.Get(actorSetting); ok {
+		actor := actor.(AuditActor)
+		entry.UserID = actor.UserID
+		entry.IPAddress = actor.IPAddress
+		entry.UserAgent = actor.UserAgent
+	}
+
+	switch table {
+	case "documents":
+		documentID := id
+		entry.DocumentID = &documentID
+	case "permissions":
+		if documentID, ok := idValue(row["document_id"]); ok {
+			entry.DocumentID = &documentID
+		}
+	}
+	entry.OrganizationID = rowOrganization(db, row)
+
+	if encoded, err := json.Marshal(details); err == nil {
+		entry.Details = string(encoded)
+	}
+	return entry
+}
+
+// rowOrganization returns the organization a changed row belongs to, or the
+// default organization when it can't be told
+func rowOrganization(db *gorm.DB, row map[string]interface{}) uint {
+	if id, ok := idValue(row["organization_id"]); ok && id != 0 {
+		return id
+	}
+
+	parents := []struct{ column, table string }{
+		{"document_id", "documents"},
+		{"folder_id", "folders"},
+	}
+	for _, parent := range parents {
+		id, ok := idValue(row[parent.column])
+		if !ok {
+			continue
+		}
+		var organizations []uint
+		if err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(parent.table).
+			Where("id = ?", id).Pluck("organization_id", &organizations).Error; err == nil && len(organizations) > 0 {
+			return organizations[0]
+		}
+	}
+
+	if organization, err := DefaultOrganization(); err == nil {
+		return organization.ID
+	}
+	return 0
+}
+
+// storeChanges writes the change entries to the outbox in the transaction
+// of the change
+func storeChanges(db *gorm.DB, entries []*models.AuditLog) {
+	payloads := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		payloads = append(payloads, entry)
+	}
+	if err := EnqueueOutbox(db, models.OutboxAudit, payloads...); err != nil {
+		db.AddError(fmt.Errorf("failed to record change in audit log: %w", err))
+	}
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/config"
@@ -48,7 +49,18 @@ func Connect(cfg *config.Config) error {
 		},
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), config)
+	dialector := postgres.Open(dsn)
+	if cfg.DBRowLevelSecurity {
+		// Connections that ran queries under row-level security are reset
+		// before they are reused
+		sqlDB, err := openWithPrincipalReset(dsn)
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		dialector = postgres.New(postgres.Config{Conn: sqlDB})
+	}
+
+	db, err := gorm.Open(dialector, config)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -63,6 +75,26 @@ func Connect(cfg *config.Config) error {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
+	if cfg.DBRowLevelSecurity {
+		if err := registerPrincipalCallbacks(db); err != nil {
+			return err
+		}
+	}
+
+	if err := registerAuditImmutability(db); err != nil {
+		return err
+	}
+	if cfg.DBAuditMaintenanceUser != "" {
+		if maintenanceDB, err = connectAuditMaintenance(db, dsn, cfg.DBAuditMaintenanceUser, cfg.DBAuditMaintenancePassword, config); err != nil {
+			return err
+		}
+	}
+	if cfg.DBAuditChanges {
+		if err := registerChangeAuditing(db); err != nil {
+			return err
+		}
+	}
+
 	DB = db
 	log.Println("Database connection established successfully")
 	return nil
@@ -78,25 +110,195 @@ func Migrate() error {
 
 	// Auto migrate all models
 	err := DB.AutoMigrate(
+		&models.Organization{},
 		&models.User{},
+		&models.RoleDefinition{},
+		&models.Folder{},
+		&models.Group{},
+		&models.GroupMember{},
 		&models.Document{},
 		&models.DocumentVersion{},
+		&models.Blob{},
+		&models.Rendition{},
+		&models.DLPFinding{},
+		&models.SecurityAlert{},
+		&models.ClassificationRule{},
 		&models.Permission{},
 		&models.AuditLog{},
+		&models.AuditArchive{},
 		&models.BlockchainRecord{},
+		&models.BlockchainBlock{},
+		&models.BlockchainAuthority{},
+		&models.BlockchainPendingTransaction{},
+		&models.OutboxEvent{},
+		&models.BlockchainAnchor{},
+		&models.TrustedTimestamp{},
+		&models.DocumentSignature{},
+		&models.SigningWorkflow{},
+		&models.SigningWorkflowSigner{},
+		&models.UserCertificate{},
 		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.APIKey{},
+		&models.OAuthClient{},
+		&models.APIUsage{},
 		&models.Category{},
 		&models.Tag{},
+		&models.DocumentTag{},
+		&models.TagSuggestion{},
+		&models.MetadataField{},
+		&models.DocumentMetadata{},
+		&models.Comment{},
+		&models.Notification{},
+		&models.Subscription{},
+		&models.Favorite{},
+		&models.EncryptionKey{},
+		&models.KeyRotationJob{},
+		&models.UploadSession{},
+		&models.UploadChunk{},
+		&models.BulkUpload{},
+		&models.BulkUploadItem{},
+		&models.ExportJob{},
+		&models.ImportConnection{},
+		&models.ImportJob{},
+		&models.ImportItem{},
+		&models.IngestedFile{},
+		&models.DirectorySyncRun{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnChallenge{},
+		&models.MFARecoveryCode{},
+		&models.PasswordResetToken{},
+		&models.ShareLink{},
+		&models.PasswordHistory{},
+		&models.KnownDevice{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := migrateOrganizations(); err != nil {
+		return fmt.Errorf("failed to run organization migrations: %w", err)
+	}
+
+	if err := migrateDocuments(); err != nil {
+		return fmt.Errorf("failed to run document migrations: %w", err)
+	}
+
+	if err := migrateSearch(); err != nil {
+		return fmt.Errorf("failed to run search migrations: %w", err)
+	}
+
+	if err := migrateAuditPartitions(); err != nil {
+		return fmt.Errorf("failed to run audit log partition migrations: %w", err)
+	}
+
+	if err := migrateAuditSearch(); err != nil {
+		return fmt.Errorf("failed to run audit log search migrations: %w", err)
+	}
+
+	if err := migrateAuditImmutability(); err != nil {
+		return fmt.Errorf("failed to run audit log immutability migrations: %w", err)
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
 
+// organizationTables are the tables whose rows belong to an organization
+var organizationTables = []string{"users", "documents", "folders", "groups", "categories", "audit_logs"}
+
+// migrateOrganizations creates the default organization and moves rows
+// created before organizations existed into it. Category and group names
+// are unique within an organization only.
+func migrateOrganizations() error {
+	statements := []string{
+		`ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_name_key`,
+		`DROP INDEX IF EXISTS idx_groups_name`,
+	}
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	organization, err := DefaultOrganization()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range organizationTables {
+		if err := AsSystem(DB).Table(table).
+			Where("organization_id IS NULL OR organization_id = 0").
+			Update("organization_id", organization.ID).Error; err != nil {
+			return fmt.Errorf("failed to assign %s to the default organization: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// DefaultOrganization returns the default organization, creating it when
+// there is none
+func DefaultOrganization() (*models.Organization, error) {
+	var organization models.Organization
+	if err := DB.Where("is_default = ?", true).
+		Attrs(models.Organization{Name: "Default", Slug: "default", IsDefault: true}).
+		FirstOrCreate(&organization).Error; err != nil {
+		return nil, fmt.Errorf("failed to get default organization: %w", err)
+	}
+	return &organization, nil
+}
+
+// migrateDocuments drops the unique constraint on document file hashes, as
+// copies of a document share their content hash with the original
+func migrateDocuments() error {
+	return DB.Exec(`ALTER TABLE documents DROP CONSTRAINT IF EXISTS documents_file_hash_key`).Error
+}
+
+// migrateSearch adds the full-text search vector over document metadata.
+// The column is generated by Postgres so it can never drift from the data.
+func migrateSearch() error {
+	statements := []string{
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('simple', coalesce(description, '')), 'B') ||
+				setweight(to_tsvector('simple', coalesce(tags, '')), 'C')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_documents_search_vector ON documents USING GIN (search_vector)`,
+	}
+
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateAuditSearch adds trigram indexes so audit logs can be searched for
+// any part of their details, user agent or IP address. Without permission
+// to install pg_trgm, searches fall back to scanning the matching period.
+func migrateAuditSearch() error {
+	if err := DB.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+		log.Printf("Audit log search indexes not created, pg_trgm is unavailable: %v", err)
+		return nil
+	}
+
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_details_trgm ON audit_logs USING GIN (details gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_user_agent_trgm ON audit_logs USING GIN (user_agent gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_ip_address_trgm ON audit_logs USING GIN (ip_address gin_trgm_ops)`,
+	}
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Seed adds initial data to the database
 func Seed() error {
 	if DB == nil {
@@ -105,7 +307,26 @@ func Seed() error {
 
 	log.Println("Seeding database with initial data...")
 
-	// Create default categories
+	// Create the built-in roles
+	roles := []models.RoleDefinition{
+		{Name: models.RoleAdmin, Description: "Administrators", Capabilities: strings.Join(models.Capabilities, ",")},
+		{Name: models.RoleManager, Description: "Department managers", Capabilities: models.CapabilityManageGroups},
+		{Name: models.RoleEmployee, Description: "Employees"},
+		{Name: models.RoleGuest, Description: "Guests"},
+	}
+
+	for _, role := range roles {
+		role.BuiltIn = true
+		var existingRole models.RoleDefinition
+		result := DB.Where("name = ?", role.Name).First(&existingRole)
+		if result.Error == gorm.ErrRecordNotFound {
+			if err := DB.Create(&role).Error; err != nil {
+				return fmt.Errorf("failed to create role %s: %w", role.Name, err)
+			}
+		}
+	}
+
+	// Create default categories in the default organization
 	categories := []models.Category{
 		{
 			Name:        "General",
@@ -144,9 +365,15 @@ func Seed() error {
 		},
 	}
 
+	organization, err := DefaultOrganization()
+	if err != nil {
+		return err
+	}
+
 	for _, category := range categories {
+		category.OrganizationID = organization.ID
 		var existingCategory models.Category
-		result := DB.Where("name = ?", category.Name).First(&existingCategory)
+		result := DB.Where("organization_id = ? AND name = ?", organization.ID, category.Name).First(&existingCategory)
 		if result.Error == gorm.ErrRecordNotFound {
 			if err := DB.Create(&category).Error; err != nil {
 				return fmt.Errorf("failed to create category %s: %w", category.Name, err)
@@ -193,6 +420,12 @@ func Close() error {
 		return fmt.Errorf("failed to close database connection: %w", err)
 	}
 
+	if maintenanceDB != nil {
+		if maintenanceSQLDB, err := maintenanceDB.DB(); err == nil {
+			maintenanceSQLDB.Close()
+		}
+	}
+
 	log.Println("Database connection closed")
 	return nil
 }
@@ -16,6 +16,60 @@ const (
 	RoleGuest    Role = "guest"
 )
 
+// Capabilities a role may hold, each allowing a set of administrative tasks
+const (
+	CapabilityManageUsers          = "manage_users"          // Registrations, sessions, password resets, directory sync
+	CapabilityManageRoles          = "manage_roles"          // Roles and the roles of users
+	CapabilityManageGroups         = "manage_groups"         // Groups and their members
+	CapabilityManagePermissions    = "manage_permissions"    // Access reports of documents and users
+	CapabilityViewAudit            = "view_audit"            // DLP findings and ingested files
+	CapabilityManageAPIClients     = "manage_api_clients"    // OAuth clients and API key quotas
+	CapabilityManageKeys           = "manage_keys"           // Encryption key rotation
+	CapabilityManageClassification = "manage_classification" // Classification rules
+	CapabilityManageCatalog        = "manage_catalog"        // Categories and metadata fields
+	CapabilityViewStats            = "view_stats"
+	CapabilityPurgeDocuments       = "purge_documents"
+	CapabilityManageOrganizations  = "manage_organizations" // Organizations, from the default organization only
+)
+
+// Capabilities lists every capability
+var Capabilities = []string{
+	CapabilityManageUsers,
+	CapabilityManageRoles,
+	CapabilityManageGroups,
+	CapabilityManagePermissions,
+	CapabilityViewAudit,
+	CapabilityManageAPIClients,
+	CapabilityManageKeys,
+	CapabilityManageClassification,
+	CapabilityManageCatalog,
+	CapabilityViewStats,
+	CapabilityPurgeDocuments,
+	CapabilityManageOrganizations,
+}
+
+// RoleDefinition is a role users can be given and the capabilities it
+// holds. The four built-in roles can't be deleted; admins hold every
+// capability whatever their definition says. Roles other than the built-in
+// ones read documents only through permissions granted to them.
+type RoleDefinition struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Name         Role      `json:"name" gorm:"type:varchar(20);uniqueIndex;not null"`
+	Description  string    `json:"description" gorm:"size:255"`
+	Capabilities string    `json:"capabilities" gorm:"size:1000"` // Comma-separated
+	BuiltIn      bool      `json:"built_in" gorm:"default:false"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Sources of a user's credentials
+const (
+	AuthSourceLocal   = "local"
+	AuthSourceLDAP    = "ldap"    // Provisioned from the directory on first login
+	AuthSourceOkta    = "okta"    // Local password; role and department follow Okta
+	AuthSourceService = "service" // Service account of an OAuth client; can't sign in
+)
+
 // AccessLevel represents document access levels
 type AccessLevel int
 
@@ -27,23 +81,42 @@ const (
 	AccessTopSecret    AccessLevel = 5
 )
 
+// Organization is a tenant of the deployment, such as a subsidiary. Users,
+// documents, folders, groups, categories and audit logs belong to one
+// organization and are never visible to another. The default organization
+// holds data created before organizations existed and may manage the others.
+type Organization struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"uniqueIndex;not null;size:100"`
+	Slug      string    `json:"slug" gorm:"uniqueIndex;not null;size:50"` // Chosen on registration
+	IsDefault bool      `json:"is_default" gorm:"default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // User represents a system user
 type User struct {
-	ID            uint           `json:"id" gorm:"primaryKey"`
-	Username      string         `json:"username" gorm:"unique;not null;size:50"`
-	Email         string         `json:"email" gorm:"unique;not null;size:100"`
-	Password      string         `json:"-" gorm:"not null"`
-	FirstName     string         `json:"first_name" gorm:"size:50"`
-	LastName      string         `json:"last_name" gorm:"size:50"`
-	Role          Role           `json:"role" gorm:"type:varchar(20);default:'employee'"`
-	Department    string         `json:"department" gorm:"size:100"`
-	IsActive      bool           `json:"is_active" gorm:"default:false"`
-	LastLogin     *time.Time     `json:"last_login"`
-	LoginAttempts int            `json:"login_attempts" gorm:"default:0"`
-	LockedUntil   *time.Time     `json:"locked_until"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	OrganizationID     uint           `json:"organization_id" gorm:"index"`
+	Username           string         `json:"username" gorm:"unique;not null;size:50"`
+	Email              string         `json:"email" gorm:"unique;not null;size:100"`
+	Password           string         `json:"-" gorm:"not null"`
+	FirstName          string         `json:"first_name" gorm:"size:50"`
+	LastName           string         `json:"last_name" gorm:"size:50"`
+	Role               Role           `json:"role" gorm:"type:varchar(20);default:'employee'"`
+	Department         string         `json:"department" gorm:"size:100"`
+	IsActive           bool           `json:"is_active" gorm:"default:false"`
+	PendingApproval    bool           `json:"pending_approval" gorm:"default:false;index"` // Self-registered and awaiting an administrator
+	AuthSource         string         `json:"auth_source" gorm:"size:20;default:'local'"`
+	MustChangePassword bool           `json:"must_change_password" gorm:"default:false"` // Set by an administrator after a suspected compromise
+	LoginAlerts        bool           `json:"login_alerts" gorm:"default:true"`          // Email on logins from new devices
+	LastLogin          *time.Time     `json:"last_login"`
+	LoginAttempts      int            `json:"login_attempts" gorm:"default:0"`
+	LockedUntil        *time.Time     `json:"locked_until"`
+	TokensRevokedAt    *time.Time     `json:"-"` // Access tokens issued until then are refused
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 
 	// Relationships
 	Documents        []Document        `json:"documents,omitempty" gorm:"foreignKey:CreatedBy"`
@@ -53,26 +126,39 @@ type User struct {
 
 // Document represents a document in the system
 type Document struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Title       string         `json:"title" gorm:"not null;size:200"`
-	Description string         `json:"description" gorm:"type:text"`
-	FileName    string         `json:"file_name" gorm:"size:255"`
-	FilePath    string         `json:"file_path" gorm:"size:500"`
-	FileHash    string         `json:"file_hash" gorm:"unique;size:64"`
-	FileSize    int64          `json:"file_size"`
-	MimeType    string         `json:"mime_type" gorm:"size:100"`
-	Category    string         `json:"category" gorm:"size:100"`
-	Tags        string         `json:"tags" gorm:"type:text"` // JSON array as string
-	AccessLevel AccessLevel    `json:"access_level" gorm:"default:2"`
-	IsEncrypted bool           `json:"is_encrypted" gorm:"default:true"`
-	Version     int            `json:"version" gorm:"default:1"`
-	CreatedBy   uint           `json:"created_by"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID                 uint            `json:"id" gorm:"primaryKey"`
+	OrganizationID     uint            `json:"organization_id" gorm:"index"`
+	Title              string          `json:"title" gorm:"not null;size:200"`
+	Description        string          `json:"description" gorm:"type:text"`
+	FileName           string          `json:"file_name" gorm:"size:255"`
+	FilePath           string          `json:"file_path" gorm:"size:500"`
+	FileHash           string          `json:"file_hash" gorm:"index;size:64"`
+	FileSize           int64           `json:"file_size"`
+	MimeType           string          `json:"mime_type" gorm:"size:100"`
+	Category           string          `json:"category" gorm:"size:100"`
+	Tags               string          `json:"tags" gorm:"type:text"` // JSON array of tag names, kept in sync with document_tags
+	AccessLevel        AccessLevel     `json:"access_level" gorm:"default:2"`
+	FolderID           *uint           `json:"folder_id" gorm:"index"`
+	IsEncrypted        bool            `json:"is_encrypted" gorm:"default:true"`
+	DataKey            string          `json:"-" gorm:"type:text"` // Per-document data key wrapped by the master key
+	KeyVersion         int             `json:"key_version" gorm:"default:0"`
+	ScanStatus         ScanStatus      `json:"scan_status" gorm:"type:varchar(20);index"`      // Virus scan result of the current content
+	IntegrityStatus    IntegrityStatus `json:"integrity_status" gorm:"type:varchar(20);index"` // Result of re-hashing the stored file
+	IntegrityIssue     string          `json:"integrity_issue,omitempty" gorm:"size:255"`
+	IntegrityCheckedAt *time.Time      `json:"integrity_checked_at"`
+	LockedAt           *time.Time      `json:"locked_at"` // Set once signed; locked documents can't be changed or deleted
+	LockReason         string          `json:"lock_reason,omitempty" gorm:"size:255"`
+	Version            int             `json:"version" gorm:"default:1"`
+	CreatedBy          uint            `json:"created_by"`
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt  `json:"deleted_at" gorm:"index"`
+	DeletedBy          *uint           `json:"deleted_by"`
+	PurgedAt           *time.Time      `json:"purged_at"` // Content permanently removed; the row remains for the audit trail
 
 	// Relationships
 	Creator           User               `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
+	Folder            *Folder            `json:"folder,omitempty" gorm:"foreignKey:FolderID"`
 	Versions          []DocumentVersion  `json:"versions,omitempty" gorm:"foreignKey:DocumentID"`
 	Permissions       []Permission       `json:"permissions,omitempty" gorm:"foreignKey:DocumentID"`
 	AuditLogs         []AuditLog         `json:"audit_logs,omitempty" gorm:"foreignKey:DocumentID"`
@@ -86,9 +172,13 @@ type DocumentVersion struct {
 	Version     int            `json:"version"`
 	Title       string         `json:"title" gorm:"size:200"`
 	Description string         `json:"description" gorm:"type:text"`
+	FileName    string         `json:"file_name" gorm:"size:255"`
 	FilePath    string         `json:"file_path" gorm:"size:500"`
 	FileHash    string         `json:"file_hash" gorm:"size:64"`
 	FileSize    int64          `json:"file_size"`
+	MimeType    string         `json:"mime_type" gorm:"size:100"`
+	DataKey     string         `json:"-" gorm:"type:text"` // Data key of the version's file wrapped by the master key
+	KeyVersion  int            `json:"key_version" gorm:"default:0"`
 	ChangeLog   string         `json:"change_log" gorm:"type:text"`
 	CreatedBy   uint           `json:"created_by"`
 	CreatedAt   time.Time      `json:"created_at"`
@@ -99,47 +189,216 @@ type DocumentVersion struct {
 	Creator  User     `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
 }
 
-// Permission represents access permissions for documents
+// Folder groups documents in a hierarchy. Permissions set on a folder are
+// inherited by the documents and subfolders it contains.
+type Folder struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	OrganizationID uint           `json:"organization_id" gorm:"index"`
+	Name           string         `json:"name" gorm:"not null;size:200"`
+	ParentID       *uint          `json:"parent_id" gorm:"index"`
+	CreatedBy      uint           `json:"created_by"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Relationships
+	Parent  *Folder `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
+	Creator User    `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
+}
+
+// Group is a team of users that documents and folders can be shared with
+// as a whole
+type Group struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_groups_organization_name"`
+	Name           string    `json:"name" gorm:"uniqueIndex:idx_groups_organization_name;not null;size:100"`
+	Description    string    `json:"description" gorm:"size:255"`
+	CreatedBy      uint      `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// Relationships
+	Creator User `json:"-" gorm:"foreignKey:CreatedBy"`
+}
+
+// GroupMember is a user's membership of a group
+type GroupMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GroupID   uint      `json:"group_id" gorm:"uniqueIndex:idx_group_members_group_user;not null"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_group_members_group_user;index;not null"`
+	AddedBy   uint      `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Group Group `json:"-" gorm:"foreignKey:GroupID"`
+	User  User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// Permission represents access permissions on a document or a folder. Exactly
+// one of DocumentID and FolderID is set, and exactly one of UserID, Role,
+// Department and GroupID.
 type Permission struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	DocumentID uint           `json:"document_id"`
-	UserID     *uint          `json:"user_id"`
-	Role       *Role          `json:"role"`
-	Department *string        `json:"department" gorm:"size:100"`
-	CanRead    bool           `json:"can_read" gorm:"default:false"`
-	CanWrite   bool           `json:"can_write" gorm:"default:false"`
-	CanDelete  bool           `json:"can_delete" gorm:"default:false"`
-	CanShare   bool           `json:"can_share" gorm:"default:false"`
-	GrantedBy  uint           `json:"granted_by"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	DocumentID  *uint          `json:"document_id" gorm:"index"`
+	FolderID    *uint          `json:"folder_id" gorm:"index"`
+	UserID      *uint          `json:"user_id"`
+	Role        *Role          `json:"role"`
+	Department  *string        `json:"department" gorm:"size:100"`
+	GroupID     *uint          `json:"group_id" gorm:"index"`
+	CanRead     bool           `json:"can_read" gorm:"default:false"`
+	CanWrite    bool           `json:"can_write" gorm:"default:false"`
+	CanDelete   bool           `json:"can_delete" gorm:"default:false"`
+	CanShare    bool           `json:"can_share" gorm:"default:false"`
+	CanDownload *bool          `json:"can_download" gorm:"default:true"` // Original file of a readable document; nil allows
+	CanPrint    *bool          `json:"can_print" gorm:"default:true"`    // Nil allows
+	GrantedBy   uint           `json:"granted_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 
 	// Relationships
-	Document Document `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
-	User     *User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Grantor  User     `json:"grantor,omitempty" gorm:"foreignKey:GrantedBy"`
+	Document *Document `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+	Folder   *Folder   `json:"folder,omitempty" gorm:"foreignKey:FolderID"`
+	User     *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Group    *Group    `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+	Grantor  User      `json:"grantor,omitempty" gorm:"foreignKey:GrantedBy"`
+}
+
+// WebAuthnCredential is a security key or passkey registered by a user.
+// Users with credentials confirm password logins with one of them.
+type WebAuthnCredential struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"index;not null"`
+	CredentialID string     `json:"credential_id" gorm:"uniqueIndex;size:1400;not null"` // Base64url
+	PublicKey    []byte     `json:"-" gorm:"not null"`                                   // COSE_Key
+	SignCount    uint32     `json:"-"`
+	AAGUID       string     `json:"aaguid" gorm:"size:36"`
+	Name         string     `json:"name" gorm:"size:100"`
+	Transports   string     `json:"transports" gorm:"size:200"` // Comma-separated hints such as usb,nfc,internal
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// WebAuthn ceremonies a challenge is issued for
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnSecondFactor = "second_factor" // Confirms a verified password
+	WebAuthnPasswordless = "passwordless"
+	WebAuthnStepUp       = "step_up" // Confirms a signed-in user before sensitive reads
+)
+
+// WebAuthnChallenge is an outstanding challenge of a WebAuthn ceremony. It
+// is used once.
+type WebAuthnChallenge struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Challenge string    `json:"-" gorm:"uniqueIndex;size:64;not null"` // Base64url
+	Ceremony  string    `json:"ceremony" gorm:"size:20"`
+	UserID    *uint     `json:"user_id"` // Nil for passwordless logins
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MFARecoveryCode is a one-time code signing in when a user's security keys
+// are unavailable. Only a hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"` // SHA-256 hex
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// KnownDevice is an IP address and user agent combination a user has
+// signed in from
+type KnownDevice struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"uniqueIndex:idx_known_devices_user_fingerprint;not null"`
+	Fingerprint string    `json:"-" gorm:"uniqueIndex:idx_known_devices_user_fingerprint;size:64;not null"` // SHA-256 hex of IP and user agent
+	IPAddress   string    `json:"ip_address" gorm:"size:45"`
+	UserAgent   string    `json:"user_agent" gorm:"size:500"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// PasswordHistory is a previous password of a user, kept to refuse reusing it
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"index;not null"`
+	PasswordHash string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"` // When the password was replaced
+}
+
+// PasswordResetToken is an emailed password reset link. Only a hash of the
+// token is stored, and each works once.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;size:64;not null"` // SHA-256 hex
+	RequestIP string     `json:"request_ip" gorm:"size:45"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ShareLink lets anyone holding its token download a document without an
+// account until it expires or is revoked, optionally only with a password
+type ShareLink struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	DocumentID       uint       `json:"document_id" gorm:"index;not null"`
+	CreatedBy        uint       `json:"created_by"`
+	Prefix           string     `json:"prefix" gorm:"size:16"`                 // Start of the token, to tell links apart
+	TokenHash        string     `json:"-" gorm:"uniqueIndex;size:64;not null"` // SHA-256 hex
+	PasswordHash     string     `json:"-" gorm:"size:255"`                     // Bcrypt; empty when no password is required
+	ExpiresAt        time.Time  `json:"expires_at"`
+	DownloadCount    int        `json:"download_count"`
+	LastDownloadedAt *time.Time `json:"last_downloaded_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+
+	// Relationships
+	Document Document `json:"-" gorm:"foreignKey:DocumentID"`
 }
 
 // AuditLog represents system audit trail
 type AuditLog struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	UserID       uint           `json:"user_id"`
-	DocumentID   *uint          `json:"document_id"`
-	Action       string         `json:"action" gorm:"size:100"`
-	ResourceType string         `json:"resource_type" gorm:"size:50"`
-	ResourceID   string         `json:"resource_id" gorm:"size:50"`
-	IPAddress    string         `json:"ip_address" gorm:"size:45"`
-	UserAgent    string         `json:"user_agent" gorm:"size:500"`
-	Details      string         `json:"details" gorm:"type:text"`
-	Timestamp    time.Time      `json:"timestamp"`
-	DeletedAt    gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"index"` // The acting user's
+	UserID         uint      `json:"user_id"`
+	DocumentID     *uint     `json:"document_id"`
+	Action         string    `json:"action" gorm:"size:100"`
+	ResourceType   string    `json:"resource_type" gorm:"size:50"`
+	ResourceID     string    `json:"resource_id" gorm:"size:50"`
+	IPAddress      string    `json:"ip_address" gorm:"size:45"`
+	UserAgent      string    `json:"user_agent" gorm:"size:500"`
+	Details        string    `json:"details" gorm:"type:text"`
+	Timestamp      time.Time `json:"timestamp" gorm:"not null"` // The table is partitioned by month of it
 
 	// Relationships
 	User     User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Document *Document `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
 }
 
+// AuditArchive records a range of an organization's audit log entries that
+// was moved out of the database into a gzipped NDJSON file in the archive
+// store
+type AuditArchive struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"index"`
+	FirstEntryID   uint      `json:"first_entry_id"`
+	LastEntryID    uint      `json:"last_entry_id"`
+	FirstTimestamp time.Time `json:"first_timestamp" gorm:"index"`
+	LastTimestamp  time.Time `json:"last_timestamp" gorm:"index"`
+	Count          int       `json:"count"`
+	Store          string    `json:"store" gorm:"size:20"`
+	Key            string    `json:"key" gorm:"size:500;uniqueIndex"`
+	Size           int64     `json:"size"`
+	SHA256         string    `json:"sha256" gorm:"size:64"` // Of the compressed file
+	CreatedAt      time.Time `json:"created_at"`
+}
+
 // BlockchainRecord represents blockchain transaction records
 type BlockchainRecord struct {
 	ID            uint           `json:"id" gorm:"primaryKey"`
@@ -160,32 +419,327 @@ type BlockchainRecord struct {
 	User     User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// BlockchainBlock persists a mined block of the blockchain, so the chain
+// survives restarts. Data holds the whole block as JSON, keeping the hashed
+// fields exactly as they were mined.
+type BlockchainBlock struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	BlockNumber int64     `json:"block_number" gorm:"uniqueIndex"`
+	Hash        string    `json:"hash" gorm:"size:64"`
+	Data        string    `json:"-" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BlockchainAuthority records the block from which every block must be signed
+// by the proof-of-authority key, so a chain rewritten with mined blocks is
+// refused. Blocks before it were mined before the authority took over.
+type BlockchainAuthority struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	KeyID       string    `json:"key_id" gorm:"size:16"`
+	BlockNumber int64     `json:"block_number"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OutboxKind is what an outbox event delivers
+type OutboxKind string
+
+const (
+	OutboxAudit      OutboxKind = "audit"      // An audit log entry
+	OutboxBlockchain OutboxKind = "blockchain" // A blockchain transaction to mine
+)
+
+// OutboxEvent is an audit entry or blockchain transaction written in the
+// transaction of the change it belongs to, and delivered by the outbox
+// dispatcher once that commits
+type OutboxEvent struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Kind          OutboxKind `json:"kind" gorm:"type:varchar(20)"`
+	Payload       string     `json:"payload" gorm:"type:text"` // JSON of the entry or transaction
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastError     string     `json:"last_error,omitempty" gorm:"size:500"`
+	DispatchedAt  *time.Time `json:"dispatched_at" gorm:"index"`
+	FailedAt      *time.Time `json:"failed_at"` // Given up on after the maximum attempts
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// BlockchainPendingTransaction is a transaction waiting to be mined into a
+// block, kept so queued operations survive restarts. Data holds the
+// transaction as JSON.
+type BlockchainPendingTransaction struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	TransactionID string    `json:"transaction_id" gorm:"unique;size:100"`
+	Data          string    `json:"-" gorm:"type:text"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BlockchainAnchor is a block hash anchored with an external timestamping
+// service, as outside evidence of the chain's state at that time. Receipt is
+// the service's proof that the hash existed.
+type BlockchainAnchor struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	BlockNumber int64     `json:"block_number" gorm:"index"`
+	BlockHash   string    `json:"block_hash" gorm:"size:64"`
+	Service     string    `json:"service" gorm:"size:50"`
+	Calendar    string    `json:"calendar" gorm:"size:255"`
+	Receipt     []byte    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TrustedTimestamp is an RFC 3161 timestamp token from a timestamping
+// authority, signed evidence that a document version's or block's hash
+// existed at GenTime
+type TrustedTimestamp struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	DocumentID   *uint     `json:"document_id,omitempty" gorm:"index"`
+	Version      int       `json:"version,omitempty"` // Document version timestamped
+	BlockNumber  *int64    `json:"block_number,omitempty" gorm:"index"`
+	Hash         string    `json:"hash" gorm:"size:64"`
+	Authority    string    `json:"authority" gorm:"size:255"`
+	SerialNumber string    `json:"serial_number" gorm:"size:100"`
+	Policy       string    `json:"policy" gorm:"size:100"`
+	GenTime      time.Time `json:"gen_time"`
+	Token        []byte    `json:"-"`
+	CreatedBy    uint      `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// DocumentSignature is an X.509 digital signature over the file of a
+// document version, made by a user with their own certificate or by the
+// server with its signing certificate
+type DocumentSignature struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	DocumentID   uint      `json:"document_id" gorm:"not null;uniqueIndex:idx_document_signature"`
+	Version      int       `json:"version" gorm:"not null;uniqueIndex:idx_document_signature"`
+	FileHash     string    `json:"file_hash" gorm:"size:64"` // Hash of the signed file
+	Algorithm    string    `json:"algorithm" gorm:"size:50"`
+	Signature    []byte    `json:"signature"`
+	Certificates string    `json:"certificates" gorm:"type:text"` // PEM chain, signer's certificate first
+	Subject      string    `json:"subject" gorm:"type:text"`
+	Issuer       string    `json:"issuer" gorm:"type:text"`
+	SerialNumber string    `json:"serial_number" gorm:"size:100"`
+	Fingerprint  string    `json:"fingerprint" gorm:"size:64;uniqueIndex:idx_document_signature"` // SHA-256 of the signer's certificate
+	ServerSigned bool      `json:"server_signed" gorm:"default:false"`
+	SignedBy     uint      `json:"signed_by"` // User who signed, or requested the server signature
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SigningWorkflowStatus represents the state of a signing workflow
+type SigningWorkflowStatus string
+
+const (
+	SigningWorkflowInProgress SigningWorkflowStatus = "in_progress"
+	SigningWorkflowCompleted  SigningWorkflowStatus = "completed"
+	SigningWorkflowDeclined   SigningWorkflowStatus = "declined"
+	SigningWorkflowCancelled  SigningWorkflowStatus = "cancelled"
+)
+
+// SignerStatus represents the state of a signer in a signing workflow
+type SignerStatus string
+
+const (
+	SignerWaiting  SignerStatus = "waiting" // An earlier signer hasn't signed yet
+	SignerPending  SignerStatus = "pending" // The signer's turn
+	SignerSigned   SignerStatus = "signed"
+	SignerDeclined SignerStatus = "declined"
+)
+
+// SigningMethod is how a signer signs in a signing workflow
+type SigningMethod string
+
+const (
+	// SigningMethodClick signs by confirming with the signer's name shortly
+	// after authenticating
+	SigningMethodClick SigningMethod = "click"
+	// SigningMethodCertificate signs with the signer's X.509 certificate
+	SigningMethodCertificate SigningMethod = "certificate"
+)
+
+// SigningWorkflow collects the signatures of several users on a document
+// version, one signer after the other. The document is locked once everyone
+// has signed.
+type SigningWorkflow struct {
+	ID          uint                  `json:"id" gorm:"primaryKey"`
+	DocumentID  uint                  `json:"document_id" gorm:"index"`
+	Version     int                   `json:"version"`                  // Document version being signed
+	FileHash    string                `json:"file_hash" gorm:"size:64"` // Hash of the version's file
+	Message     string                `json:"message" gorm:"type:text"` // Note to the signers
+	Status      SigningWorkflowStatus `json:"status" gorm:"type:varchar(20);index"`
+	CreatedBy   uint                  `json:"created_by"`
+	CompletedAt *time.Time            `json:"completed_at"` // Completed, declined or cancelled
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+
+	// Relationships
+	Signers []SigningWorkflowSigner `json:"signers,omitempty" gorm:"foreignKey:WorkflowID"`
+}
+
+// SigningWorkflowSigner is a signer of a signing workflow. Evidence binds the
+// signer's identity and authentication to the signed file.
+type SigningWorkflowSigner struct {
+	ID              uint          `json:"id" gorm:"primaryKey"`
+	WorkflowID      uint          `json:"workflow_id" gorm:"index"`
+	UserID          uint          `json:"user_id" gorm:"index"`
+	Position        int           `json:"position"` // Signing order, from 1
+	Method          SigningMethod `json:"method" gorm:"type:varchar(20)"`
+	Status          SignerStatus  `json:"status" gorm:"type:varchar(20);index"`
+	SignedName      string        `json:"signed_name,omitempty" gorm:"size:200"` // Name confirmed when click-signing
+	SignatureID     *uint         `json:"signature_id,omitempty"`                // Certificate signature
+	Evidence        string        `json:"evidence,omitempty" gorm:"size:64"`
+	AuthenticatedAt *time.Time    `json:"authenticated_at,omitempty"` // Signer's latest authentication when signing
+	IPAddress       string        `json:"ip_address,omitempty" gorm:"size:45"`
+	UserAgent       string        `json:"user_agent,omitempty" gorm:"size:500"`
+	TransactionID   string        `json:"transaction_id,omitempty" gorm:"size:100"` // Blockchain transaction of the signature
+	DeclineReason   string        `json:"decline_reason,omitempty" gorm:"type:text"`
+	NotifiedAt      *time.Time    `json:"notified_at"`
+	SignedAt        *time.Time    `json:"signed_at"`
+	DeclinedAt      *time.Time    `json:"declined_at"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// RevocationReason is why a certificate was revoked, as in RFC 5280
+type RevocationReason string
+
+const (
+	RevocationUnspecified          RevocationReason = "unspecified"
+	RevocationKeyCompromise        RevocationReason = "key_compromise"
+	RevocationAffiliationChanged   RevocationReason = "affiliation_changed"
+	RevocationSuperseded           RevocationReason = "superseded"
+	RevocationCessationOfOperation RevocationReason = "cessation_of_operation"
+)
+
+// UserCertificate is a signing certificate issued to a user by the internal
+// CA. Its private key is stored sealed with its own data key.
+type UserCertificate struct {
+	ID               uint             `json:"id" gorm:"primaryKey"`
+	UserID           uint             `json:"user_id" gorm:"index"`
+	OrganizationID   uint             `json:"organization_id" gorm:"index"`
+	SerialNumber     string           `json:"serial_number" gorm:"unique;size:40"` // Hex
+	Subject          string           `json:"subject" gorm:"type:text"`
+	Email            string           `json:"email" gorm:"size:100"`
+	Certificate      string           `json:"certificate" gorm:"type:text"` // PEM, followed by the CA's
+	Fingerprint      string           `json:"fingerprint" gorm:"size:64;index"`
+	PrivateKey       string           `json:"-" gorm:"type:text"` // Sealed PKCS #8 key, base64 encoded
+	DataKey          string           `json:"-" gorm:"type:text"`
+	KeyVersion       int              `json:"-" gorm:"default:0"`
+	NotBefore        time.Time        `json:"not_before"`
+	NotAfter         time.Time        `json:"not_after"`
+	RevokedAt        *time.Time       `json:"revoked_at"`
+	RevocationReason RevocationReason `json:"revocation_reason,omitempty" gorm:"type:varchar(30)"`
+	RenewedFromID    *uint            `json:"renewed_from_id"`
+	IssuedBy         uint             `json:"issued_by"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
 // RefreshToken represents JWT refresh tokens
 type RefreshToken struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	UserID    uint           `json:"user_id"`
-	Token     string         `json:"token" gorm:"unique;size:255"`
-	ExpiresAt time.Time      `json:"expires_at"`
-	IsRevoked bool           `json:"is_revoked" gorm:"default:false"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	UserID     uint           `json:"user_id"`
+	Token      string         `json:"-" gorm:"unique;size:255"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	IsRevoked  bool           `json:"is_revoked" gorm:"default:false"`
+	IPAddress  string         `json:"ip_address" gorm:"size:45"`  // Client that signed in
+	UserAgent  string         `json:"user_agent" gorm:"size:500"` // Browser that signed in
+	LastUsedAt *time.Time     `json:"last_used_at"`               // Last token refresh
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 
 	// Relationships
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
-// Category represents document categories
+// APIKey is a long-lived personal access token of a user for scripts and
+// integrations, limited to its scopes
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"not null;size:100"`
+	Prefix     string     `json:"prefix" gorm:"size:16"`                 // Start of the key, to tell keys apart
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;size:64;not null"` // SHA-256 hex of the key
+	Scopes     string     `json:"scopes" gorm:"size:1000"`               // Comma-separated, such as documents:read
+	ExpiresAt  *time.Time `json:"expires_at"`                            // Nil never expires
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip" gorm:"size:45"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	DailyRequestQuota     *int64 `json:"daily_request_quota"`      // Nil uses the default, 0 is unlimited
+	DailyBandwidthQuotaMB *int64 `json:"daily_bandwidth_quota_mb"` // Nil uses the default, 0 is unlimited
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// OAuthClient is a registered machine client getting tokens with the
+// client credentials grant. It acts as its own service account user.
+type OAuthClient struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	ClientID   string     `json:"client_id" gorm:"uniqueIndex;size:64;not null"`
+	SecretHash string     `json:"-" gorm:"size:64;not null"` // SHA-256 hex of the secret
+	Name       string     `json:"name" gorm:"not null;size:100"`
+	UserID     uint       `json:"user_id" gorm:"uniqueIndex;not null"` // Service account
+	Scopes     string     `json:"scopes" gorm:"size:1000"`             // Comma-separated, the most a token can get
+	CreatedBy  uint       `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	DailyRequestQuota     *int64 `json:"daily_request_quota"`      // Nil uses the default, 0 is unlimited
+	DailyBandwidthQuotaMB *int64 `json:"daily_bandwidth_quota_mb"` // Nil uses the default, 0 is unlimited
+
+	// Relationships
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+// Kinds of credentials whose API usage is tracked
+const (
+	CredentialAPIKey      = "api_key"
+	CredentialOAuthClient = "oauth_client"
+)
+
+// APIUsage counts a day of requests made with an API key or OAuth client
+type APIUsage struct {
+	ID             uint      `json:"-" gorm:"primaryKey"`
+	CredentialType string    `json:"credential_type" gorm:"uniqueIndex:idx_api_usages_credential_day;size:20;not null"`
+	CredentialID   uint      `json:"credential_id" gorm:"uniqueIndex:idx_api_usages_credential_day;not null"`
+	Day            time.Time `json:"day" gorm:"uniqueIndex:idx_api_usages_credential_day;type:date;not null"` // UTC
+	Requests       int64     `json:"requests"`
+	BytesIn        int64     `json:"bytes_in"`
+	BytesOut       int64     `json:"bytes_out"`
+}
+
+// RevokedToken is an access token refused before it expires, such as after
+// a logout
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	JTI       string    `json:"jti" gorm:"uniqueIndex;size:64;not null"` // The token's ID claim
+	UserID    uint      `json:"user_id" gorm:"not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"` // Past it the token is refused anyway
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Category represents document categories, optionally nested under a parent
 type Category struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Name        string         `json:"name" gorm:"unique;not null;size:100"`
-	Description string         `json:"description" gorm:"type:text"`
-	Color       string         `json:"color" gorm:"size:7"` // Hex color code
-	Icon        string         `json:"icon" gorm:"size:50"`
-	IsActive    bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	OrganizationID uint           `json:"organization_id" gorm:"uniqueIndex:idx_categories_organization_name"`
+	Name           string         `json:"name" gorm:"uniqueIndex:idx_categories_organization_name;not null;size:100"`
+	ParentID       *uint          `json:"parent_id" gorm:"index"`
+	Description    string         `json:"description" gorm:"type:text"`
+	Color          string         `json:"color" gorm:"size:7"` // Hex color code
+	Icon           string         `json:"icon" gorm:"size:50"`
+	IsActive       bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Relationships
+	Parent *Category `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
 }
 
 // Tag represents document tags
@@ -199,3 +753,600 @@ type Tag struct {
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
+
+// DocumentTag links a document to a tag
+type DocumentTag struct {
+	DocumentID uint      `json:"document_id" gorm:"primaryKey"`
+	TagID      uint      `json:"tag_id" gorm:"primaryKey;index"`
+	CreatedBy  uint      `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// Relationships
+	Document Document `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+	Tag      Tag      `json:"tag,omitempty" gorm:"foreignKey:TagID"`
+}
+
+// Comment represents a user comment on a document
+type Comment struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	DocumentID uint           `json:"document_id" gorm:"index"`
+	UserID     uint           `json:"user_id"`
+	Content    string         `json:"content" gorm:"type:text;not null"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Relationships
+	Document Document `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+	User     User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// NotificationType represents the event a notification reports
+type NotificationType string
+
+const (
+	NotificationMention          NotificationType = "mention"
+	NotificationNewVersion       NotificationType = "new_version"
+	NotificationPermissionChange NotificationType = "permission_change"
+	NotificationComment          NotificationType = "comment"
+	NotificationMalwareDetected  NotificationType = "malware_detected"
+	NotificationBulkUpload       NotificationType = "bulk_upload"
+	NotificationExportReady      NotificationType = "export_ready"
+	NotificationImportCompleted  NotificationType = "import_completed"
+	NotificationEmailIngested    NotificationType = "email_ingested"
+	NotificationRegistration     NotificationType = "registration"
+	NotificationSignatureRequest NotificationType = "signature_request"
+	NotificationSigningUpdate    NotificationType = "signing_update"
+)
+
+// Notification represents a message for a user about activity concerning them
+type Notification struct {
+	ID         uint             `json:"id" gorm:"primaryKey"`
+	UserID     uint             `json:"user_id" gorm:"index"`
+	Type       NotificationType `json:"type" gorm:"type:varchar(30);index"`
+	ActorID    *uint            `json:"actor_id"`
+	DocumentID *uint            `json:"document_id"`
+	CommentID  *uint            `json:"comment_id"`
+	Message    string           `json:"message" gorm:"size:500"`
+	ReadAt     *time.Time       `json:"read_at"`
+	CreatedAt  time.Time        `json:"created_at"`
+
+	// Relationships
+	User     User      `json:"-" gorm:"foreignKey:UserID"`
+	Actor    *User     `json:"-" gorm:"foreignKey:ActorID"`
+	Document *Document `json:"-" gorm:"foreignKey:DocumentID"`
+	Comment  *Comment  `json:"-" gorm:"foreignKey:CommentID"`
+}
+
+// Subscription lets a user watch a document, or every document in a folder
+// and its subfolders, for the selected events
+type Subscription struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	UserID            uint      `json:"user_id" gorm:"index"`
+	DocumentID        *uint     `json:"document_id" gorm:"index"`
+	FolderID          *uint     `json:"folder_id" gorm:"index"`
+	NotifyVersions    bool      `json:"notify_versions"`
+	NotifyPermissions bool      `json:"notify_permissions"`
+	NotifyComments    bool      `json:"notify_comments"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+
+	// Relationships
+	User     User      `json:"-" gorm:"foreignKey:UserID"`
+	Document *Document `json:"-" gorm:"foreignKey:DocumentID"`
+	Folder   *Folder   `json:"-" gorm:"foreignKey:FolderID"`
+}
+
+// Favorite is a document bookmarked by a user, kept in the user's order
+type Favorite struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"uniqueIndex:idx_favorites_user_document"`
+	DocumentID uint      `json:"document_id" gorm:"uniqueIndex:idx_favorites_user_document"`
+	Position   int       `json:"position"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// Relationships
+	User     User     `json:"-" gorm:"foreignKey:UserID"`
+	Document Document `json:"-" gorm:"foreignKey:DocumentID"`
+}
+
+// MetadataFieldType represents the value type of a custom metadata field
+type MetadataFieldType string
+
+const (
+	MetadataText    MetadataFieldType = "text"
+	MetadataNumber  MetadataFieldType = "number"
+	MetadataDate    MetadataFieldType = "date"
+	MetadataBoolean MetadataFieldType = "boolean"
+	MetadataSelect  MetadataFieldType = "select" // Text restricted to Options
+)
+
+// MetadataField is an admin-defined custom attribute documents may carry
+type MetadataField struct {
+	ID          uint              `json:"id" gorm:"primaryKey"`
+	Key         string            `json:"key" gorm:"unique;not null;size:50"`
+	Label       string            `json:"label" gorm:"size:100"`
+	Type        MetadataFieldType `json:"type" gorm:"type:varchar(20)"`
+	Options     string            `json:"options" gorm:"type:text"`   // JSON array of allowed values for select fields
+	Department  string            `json:"department" gorm:"size:100"` // Department the field is meant for, empty for all
+	Description string            `json:"description" gorm:"type:text"`
+	CreatedBy   uint              `json:"created_by"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// DocumentMetadata holds the value of a custom metadata field on a document.
+// Only the column matching the field type is set.
+type DocumentMetadata struct {
+	DocumentID   uint       `json:"document_id" gorm:"primaryKey"`
+	FieldID      uint       `json:"field_id" gorm:"primaryKey;index"`
+	TextValue    *string    `json:"text_value" gorm:"type:text"`
+	NumberValue  *float64   `json:"number_value"`
+	DateValue    *time.Time `json:"date_value"`
+	BooleanValue *bool      `json:"boolean_value"`
+	UpdatedBy    uint       `json:"updated_by"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Relationships
+	Document Document      `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+	Field    MetadataField `json:"field,omitempty" gorm:"foreignKey:FieldID"`
+}
+
+// SuggestionKind identifies what an auto-tagging suggestion proposes
+type SuggestionKind string
+
+const (
+	SuggestionTag      SuggestionKind = "tag"
+	SuggestionCategory SuggestionKind = "category"
+)
+
+// SuggestionStatus represents the review state of a suggestion
+type SuggestionStatus string
+
+const (
+	SuggestionPending  SuggestionStatus = "pending"
+	SuggestionAccepted SuggestionStatus = "accepted"
+	SuggestionRejected SuggestionStatus = "rejected"
+)
+
+// TagSuggestion is a tag or category proposed for a document by the
+// auto-tagging pipeline, awaiting confirmation by a user
+type TagSuggestion struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	DocumentID  uint             `json:"document_id" gorm:"index"`
+	Kind        SuggestionKind   `json:"kind" gorm:"type:varchar(20)"`
+	Value       string           `json:"value" gorm:"size:100"`
+	Score       float64          `json:"score"`
+	Source      string           `json:"source" gorm:"size:50"` // Tagger that produced the suggestion
+	Status      SuggestionStatus `json:"status" gorm:"type:varchar(20);index"`
+	AutoApplied bool             `json:"auto_applied" gorm:"default:false"`
+	ReviewedBy  *uint            `json:"reviewed_by"`
+	ReviewedAt  *time.Time       `json:"reviewed_at"`
+	CreatedAt   time.Time        `json:"created_at"`
+
+	// Relationships
+	Document Document `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+}
+
+// ScanStatus represents the virus scan state of stored content. Content
+// stored while scanning was disabled has no scan status.
+type ScanStatus string
+
+const (
+	ScanPending  ScanStatus = "pending"
+	ScanClean    ScanStatus = "clean"
+	ScanInfected ScanStatus = "infected"
+)
+
+// IntegrityStatus represents the result of checking a document's stored
+// file against its recorded hashes. Documents not checked yet have none.
+type IntegrityStatus string
+
+const (
+	IntegrityVerified      IntegrityStatus = "verified"
+	IntegrityHashMismatch  IntegrityStatus = "hash_mismatch"  // The file doesn't hash to the document's file hash
+	IntegrityChainMismatch IntegrityStatus = "chain_mismatch" // The file hash differs from the one recorded on the blockchain
+	IntegrityUnreadable    IntegrityStatus = "unreadable"     // The file is missing or can't be decrypted
+)
+
+// Blob represents an encrypted file in content-addressable storage. Blobs
+// are identified by the SHA-256 hash of their plaintext and shared by every
+// document and version with that content; RefCount counts those rows.
+type Blob struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Hash       string     `json:"hash" gorm:"uniqueIndex;not null;size:64"`
+	Path       string     `json:"-" gorm:"not null;size:500"`
+	Size       int64      `json:"size"`
+	DataKey    string     `json:"-" gorm:"type:text"` // Data key of the blob wrapped by the master key
+	KeyVersion int        `json:"key_version" gorm:"default:0"`
+	RefCount   int        `json:"ref_count" gorm:"not null;default:0;index"`
+	ScanStatus ScanStatus `json:"scan_status" gorm:"type:varchar(20);index"`
+	Signature  string     `json:"signature" gorm:"size:255"` // Malware detected by the virus scanner
+	ScannedAt  *time.Time `json:"scanned_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Rendition represents content converted to another format, such as a PDF
+// rendering of an office document. Renditions are keyed by the hash of the
+// source content and stored as blobs, so documents with the same content
+// share them.
+type Rendition struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	SourceHash string    `json:"source_hash" gorm:"not null;size:64;uniqueIndex:idx_rendition_source_format"`
+	Format     string    `json:"format" gorm:"not null;size:20;uniqueIndex:idx_rendition_source_format"`
+	BlobHash   string    `json:"blob_hash" gorm:"not null;size:64"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DLPAction is what data loss prevention did about sensitive content
+type DLPAction string
+
+const (
+	DLPActionRaise DLPAction = "raise" // The document's access level was raised
+	DLPActionBlock DLPAction = "block" // The upload was rejected
+)
+
+// DLPFinding records sensitive content found in an upload. Findings of
+// blocked uploads have no document unless they were new versions.
+type DLPFinding struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	DocumentID  *uint       `json:"document_id" gorm:"index"`
+	Version     int         `json:"version"`
+	UserID      uint        `json:"user_id" gorm:"index"`
+	FileName    string      `json:"file_name" gorm:"size:255"`
+	FileHash    string      `json:"file_hash" gorm:"size:64"`
+	Rule        string      `json:"rule" gorm:"size:50;index"`
+	Count       int         `json:"count"`
+	Sample      string      `json:"sample" gorm:"size:255"` // First match, masked
+	Action      DLPAction   `json:"action" gorm:"type:varchar(20)"`
+	AccessLevel AccessLevel `json:"access_level"` // Access level the document was raised to
+	CreatedAt   time.Time   `json:"created_at"`
+
+	// Relationships
+	User     User      `json:"-" gorm:"foreignKey:UserID"`
+	Document *Document `json:"-" gorm:"foreignKey:DocumentID"`
+}
+
+// AlertSeverity ranks how urgent a security alert is
+type AlertSeverity string
+
+const (
+	AlertSeverityLow      AlertSeverity = "low"
+	AlertSeverityMedium   AlertSeverity = "medium"
+	AlertSeverityHigh     AlertSeverity = "high"
+	AlertSeverityCritical AlertSeverity = "critical"
+)
+
+// AlertStatus is where a security alert is in its review
+type AlertStatus string
+
+const (
+	AlertStatusOpen         AlertStatus = "open"
+	AlertStatusAcknowledged AlertStatus = "acknowledged"
+	AlertStatusResolved     AlertStatus = "resolved"
+)
+
+// SecurityAlert records suspicious activity found in the audit log by a
+// detection rule. Activity continuing while an alert is open updates it
+// instead of raising another.
+type SecurityAlert struct {
+	ID             uint          `json:"id" gorm:"primaryKey"`
+	OrganizationID uint          `json:"organization_id" gorm:"index"`
+	Rule           string        `json:"rule" gorm:"size:50;index"`
+	Severity       AlertSeverity `json:"severity" gorm:"type:varchar(20);index"`
+	Status         AlertStatus   `json:"status" gorm:"type:varchar(20);index;default:open"`
+	DedupKey       string        `json:"-" gorm:"size:255;index"` // Identifies the activity within the rule
+	UserID         *uint         `json:"user_id" gorm:"index"`    // The user the activity is attributed to
+	IPAddress      string        `json:"ip_address" gorm:"size:45"`
+	Summary        string        `json:"summary" gorm:"size:500"`
+	Details        string        `json:"details" gorm:"type:text"` // JSON evidence, such as audit entry IDs
+	EventCount     int           `json:"event_count"`
+	FirstSeenAt    time.Time     `json:"first_seen_at"`
+	LastSeenAt     time.Time     `json:"last_seen_at"`
+	ReviewedBy     *uint         `json:"reviewed_by"`
+	ReviewedAt     *time.Time    `json:"reviewed_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// ClassificationRule suggests a minimum access level for uploads. A rule
+// matches when every condition it sets matches: the document's category, the
+// uploader's department and any one of its keywords in the title, description
+// or content.
+type ClassificationRule struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	Name        string      `json:"name" gorm:"not null;size:100"`
+	Category    string      `json:"category" gorm:"size:100"`
+	Department  string      `json:"department" gorm:"size:100"`
+	Keywords    string      `json:"keywords" gorm:"type:text"` // Comma-separated, case-insensitive
+	AccessLevel AccessLevel `json:"access_level"`
+	IsActive    bool        `json:"is_active" gorm:"default:true"`
+	CreatedBy   uint        `json:"created_by"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// EncryptionKey represents a versioned master key-encryption key, stored
+// wrapped by the root key from the key provider
+type EncryptionKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Version    int        `json:"version" gorm:"unique;not null"`
+	WrappedKey string     `json:"-" gorm:"type:text;not null"`
+	IsActive   bool       `json:"is_active" gorm:"default:false"`
+	CreatedBy  uint       `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at"`
+}
+
+// KeyRotationStatus represents the state of a key rotation job
+type KeyRotationStatus string
+
+const (
+	KeyRotationRunning   KeyRotationStatus = "running"
+	KeyRotationCompleted KeyRotationStatus = "completed"
+	KeyRotationFailed    KeyRotationStatus = "failed"
+)
+
+// KeyRotationJob tracks the re-wrapping of data keys after a master key rotation
+type KeyRotationJob struct {
+	ID                 uint              `json:"id" gorm:"primaryKey"`
+	FromVersion        int               `json:"from_version"`
+	ToVersion          int               `json:"to_version"`
+	Status             KeyRotationStatus `json:"status" gorm:"type:varchar(20)"`
+	TotalDocuments     int64             `json:"total_documents"`
+	ProcessedDocuments int64             `json:"processed_documents"`
+	FailedDocuments    int64             `json:"failed_documents"`
+	Error              string            `json:"error" gorm:"type:text"`
+	StartedBy          uint              `json:"started_by"`
+	StartedAt          time.Time         `json:"started_at"`
+	CompletedAt        *time.Time        `json:"completed_at"`
+}
+
+// UploadStatus represents the state of a chunked upload session
+type UploadStatus string
+
+const (
+	UploadPending    UploadStatus = "pending"
+	UploadAssembling UploadStatus = "assembling"
+	UploadCompleted  UploadStatus = "completed"
+)
+
+// UploadSession tracks a resumable chunked upload of a new document, or of a
+// new version when DocumentID is set before completion
+type UploadSession struct {
+	ID                uint         `json:"id" gorm:"primaryKey"`
+	UserID            uint         `json:"user_id" gorm:"index;not null"`
+	DocumentID        *uint        `json:"document_id"` // Target document of a new version, or the created document
+	FileName          string       `json:"file_name" gorm:"size:255"`
+	MimeType          string       `json:"mime_type" gorm:"size:100"`
+	TotalSize         int64        `json:"total_size"`
+	ReceivedSize      int64        `json:"received_size"`
+	ExpectedHash      string       `json:"expected_hash" gorm:"size:64"` // Optional SHA-256 of the whole file
+	Title             string       `json:"title" gorm:"size:200"`
+	Description       string       `json:"description" gorm:"type:text"`
+	Category          string       `json:"category" gorm:"size:100"`
+	Tags              string       `json:"tags" gorm:"type:text"`
+	AccessLevel       AccessLevel  `json:"access_level"`                         // Zero until classified on completion
+	AccessLevelReason string       `json:"access_level_reason" gorm:"type:text"` // Justifies a level below the classification
+	FolderID          *uint        `json:"folder_id"`
+	ChangeLog         string       `json:"change_log" gorm:"type:text"`
+	Status            UploadStatus `json:"status" gorm:"type:varchar(20);index"`
+	ExpiresAt         time.Time    `json:"expires_at" gorm:"index"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// BulkUploadStatus represents the state of an archive upload
+type BulkUploadStatus string
+
+const (
+	BulkUploadProcessing BulkUploadStatus = "processing"
+	BulkUploadCompleted  BulkUploadStatus = "completed"
+	BulkUploadFailed     BulkUploadStatus = "failed"
+)
+
+// BulkUpload tracks the extraction of an uploaded zip archive into documents.
+// The document fields apply to every extracted file; the archive's
+// directories become folders under FolderID.
+type BulkUpload struct {
+	ID                uint             `json:"id" gorm:"primaryKey"`
+	UserID            uint             `json:"user_id" gorm:"index;not null"`
+	FileName          string           `json:"file_name" gorm:"size:255"`
+	FolderID          *uint            `json:"folder_id"`
+	Category          string           `json:"category" gorm:"size:100"`
+	Tags              string           `json:"tags" gorm:"type:text"`
+	AccessLevel       AccessLevel      `json:"access_level"` // Zero to classify each file
+	AccessLevelReason string           `json:"access_level_reason" gorm:"type:text"`
+	Status            BulkUploadStatus `json:"status" gorm:"type:varchar(20);index"`
+	TotalFiles        int              `json:"total_files"`
+	SucceededFiles    int              `json:"succeeded_files"`
+	FailedFiles       int              `json:"failed_files"`
+	Error             string           `json:"error" gorm:"type:text"`
+	CreatedAt         time.Time        `json:"created_at"`
+	CompletedAt       *time.Time       `json:"completed_at"`
+
+	// Relationships
+	User  User             `json:"-" gorm:"foreignKey:UserID"`
+	Items []BulkUploadItem `json:"items,omitempty" gorm:"foreignKey:BulkUploadID"`
+}
+
+// BulkUploadItem is the outcome of one file of a bulk upload
+type BulkUploadItem struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	BulkUploadID uint      `json:"bulk_upload_id" gorm:"index;not null"`
+	Path         string    `json:"path" gorm:"size:1000"` // Path within the archive
+	DocumentID   *uint     `json:"document_id"`
+	Succeeded    bool      `json:"succeeded"`
+	Error        string    `json:"error" gorm:"type:text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ExportStatus represents the state of an export job
+type ExportStatus string
+
+const (
+	ExportProcessing ExportStatus = "processing"
+	ExportCompleted  ExportStatus = "completed"
+	ExportFailed     ExportStatus = "failed"
+	ExportExpired    ExportStatus = "expired" // The archive was deleted after its retention period
+)
+
+// ExportJob builds an encrypted zip archive of a set of documents with a
+// metadata manifest for its user to download
+type ExportJob struct {
+	ID                uint         `json:"id" gorm:"primaryKey"`
+	UserID            uint         `json:"user_id" gorm:"index;not null"`
+	Status            ExportStatus `json:"status" gorm:"type:varchar(20);index"`
+	DocumentIDs       string       `json:"-" gorm:"type:text"` // JSON array of the documents to export
+	TotalDocuments    int          `json:"total_documents"`
+	ExportedDocuments int          `json:"exported_documents"`
+	SkippedDocuments  int          `json:"skipped_documents"`
+	FilePath          string       `json:"-" gorm:"size:500"`
+	FileSize          int64        `json:"file_size"`
+	DataKey           string       `json:"-" gorm:"type:text"`
+	KeyVersion        int          `json:"-" gorm:"default:0"`
+	Error             string       `json:"error" gorm:"type:text"`
+	ExpiresAt         *time.Time   `json:"expires_at" gorm:"index"`
+	CreatedAt         time.Time    `json:"created_at"`
+	CompletedAt       *time.Time   `json:"completed_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// ImportConnection is a user's OAuth authorization to read an external
+// source such as Google Drive. Until the user grants access it holds the
+// pending authorization state.
+type ImportConnection struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	UserID         uint       `json:"user_id" gorm:"uniqueIndex:idx_import_connections_user_provider;not null"`
+	Provider       string     `json:"provider" gorm:"size:50;uniqueIndex:idx_import_connections_user_provider"`
+	Account        string     `json:"account" gorm:"size:255"` // Account name at the provider
+	State          string     `json:"-" gorm:"size:100;index"`
+	StateExpiresAt *time.Time `json:"-"`
+	Tokens         string     `json:"-" gorm:"type:text"` // Sealed OAuth tokens, base64 encoded
+	DataKey        string     `json:"-" gorm:"type:text"`
+	KeyVersion     int        `json:"-" gorm:"default:0"`
+	ConnectedAt    *time.Time `json:"connected_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// ImportStatus represents the state of an import job
+type ImportStatus string
+
+const (
+	ImportProcessing ImportStatus = "processing"
+	ImportCompleted  ImportStatus = "completed"
+	ImportFailed     ImportStatus = "failed"
+)
+
+// ImportFolder is a folder of an external source selected for import, with
+// the category its documents get
+type ImportFolder struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// ImportJob pulls the files of folders of an external source into documents.
+// Each selected folder becomes a folder under FolderID. TotalFiles is known
+// once all folders have been listed.
+type ImportJob struct {
+	ID                uint         `json:"id" gorm:"primaryKey"`
+	UserID            uint         `json:"user_id" gorm:"index;not null"`
+	Provider          string       `json:"provider" gorm:"size:50"`
+	Folders           string       `json:"-" gorm:"type:text"` // JSON array of the selected ImportFolders
+	FolderID          *uint        `json:"folder_id"`
+	Category          string       `json:"category" gorm:"size:100"` // For folders without their own category
+	Tags              string       `json:"tags" gorm:"type:text"`
+	AccessLevel       AccessLevel  `json:"access_level"` // Zero to classify each file
+	AccessLevelReason string       `json:"access_level_reason" gorm:"type:text"`
+	Status            ImportStatus `json:"status" gorm:"type:varchar(20);index"`
+	TotalFiles        int          `json:"total_files"`
+	ImportedFiles     int          `json:"imported_files"`
+	SkippedFiles      int          `json:"skipped_files"`
+	FailedFiles       int          `json:"failed_files"`
+	Error             string       `json:"error" gorm:"type:text"`
+	CreatedAt         time.Time    `json:"created_at"`
+	CompletedAt       *time.Time   `json:"completed_at"`
+
+	// Relationships
+	User  User         `json:"-" gorm:"foreignKey:UserID"`
+	Items []ImportItem `json:"items,omitempty" gorm:"foreignKey:ImportJobID"`
+}
+
+// ImportItemStatus is the outcome of one file of an import
+type ImportItemStatus string
+
+const (
+	ImportItemImported ImportItemStatus = "imported"
+	ImportItemSkipped  ImportItemStatus = "skipped" // Unchanged since an earlier import
+	ImportItemFailed   ImportItemStatus = "failed"
+)
+
+// ImportItem is the outcome of one file of an import
+type ImportItem struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	ImportJobID uint             `json:"import_job_id" gorm:"index;not null"`
+	ExternalID  string           `json:"external_id" gorm:"size:255;index"` // File ID at the provider
+	Path        string           `json:"path" gorm:"size:1000"`
+	FileHash    string           `json:"file_hash" gorm:"size:64"`
+	DocumentID  *uint            `json:"document_id"`
+	Status      ImportItemStatus `json:"status" gorm:"type:varchar(20)"`
+	Error       string           `json:"error" gorm:"type:text"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// IngestedFile is the outcome of collecting a file from a drop location. A
+// file is identified by its path, size and modification time, so a file
+// replaced in the drop is collected again.
+type IngestedFile struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Source     string    `json:"source" gorm:"size:100;index:idx_ingested_files_file"`
+	Path       string    `json:"path" gorm:"size:1000;index:idx_ingested_files_file"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	FileHash   string    `json:"file_hash" gorm:"size:64"`
+	DocumentID *uint     `json:"document_id"`
+	Succeeded  bool      `json:"succeeded"`
+	Error      string    `json:"error" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DirectorySyncRun is the report of synchronizing users with the corporate
+// directory. Dry runs report the changes without applying them.
+type DirectorySyncRun struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	Source         string     `json:"source" gorm:"size:20"`
+	DryRun         bool       `json:"dry_run"`
+	TriggeredBy    *uint      `json:"triggered_by"` // Nil for scheduled runs
+	DirectoryUsers int        `json:"directory_users"`
+	Linked         int        `json:"linked"`
+	Updated        int        `json:"updated"`
+	Deactivated    int        `json:"deactivated"`
+	Changes        string     `json:"-" gorm:"type:text"` // JSON array of the changes per user
+	Error          string     `json:"error" gorm:"type:text"`
+	StartedAt      time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt     *time.Time `json:"finished_at"`
+}
+
+// UploadChunk is an encrypted part of an upload session's file starting at Offset
+type UploadChunk struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	SessionID  uint      `json:"session_id" gorm:"uniqueIndex:idx_upload_chunks_session_offset;not null"`
+	Offset     int64     `json:"offset" gorm:"column:chunk_offset;uniqueIndex:idx_upload_chunks_session_offset"`
+	Size       int64     `json:"size"`
+	Path       string    `json:"-" gorm:"size:500"`
+	DataKey    string    `json:"-" gorm:"type:text"` // Data key of the chunk wrapped by the master key
+	KeyVersion int       `json:"-" gorm:"default:0"`
+	CreatedAt  time.Time `json:"created_at"`
+}
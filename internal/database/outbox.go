@@ -0,0 +1,41 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// outboxBatchSize is the number of outbox events inserted per statement
+const outboxBatchSize = 500
+
+// EnqueueOutbox writes events for the outbox dispatcher in tx, the
+// transaction of the change they belong to, so they are delivered if and
+// only if it commits
+func EnqueueOutbox(tx *gorm.DB, kind models.OutboxKind, payloads ...interface{}) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	events := make([]models.OutboxEvent, 0, len(payloads))
+	for _, payload := range payloads {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s outbox event: %w", kind, err)
+		}
+		events = append(events, models.OutboxEvent{
+			Kind:          kind,
+			Payload:       string(encoded),
+			NextAttemptAt: now,
+		})
+	}
+
+	if err := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).CreateInBatches(&events, outboxBatchSize).Error; err != nil {
+		return fmt.Errorf("failed to write %s outbox events: %w", kind, err)
+	}
+	return nil
+}
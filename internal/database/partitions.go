@@ -0,0 +1,232 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// AuditPartitionMonthsAhead is the number of months after the current one
+// that audit_logs partitions are created for in advance
+const AuditPartitionMonthsAhead = 3
+
+// auditPartitionPrefix starts the names of the monthly partitions of
+// audit_logs, which end with the year and month
+const auditPartitionPrefix = "audit_logs_"
+
+// auditDefaultPartition catches audit entries of months without a partition,
+// so logging never fails when partitions weren't created in time
+const auditDefaultPartition = "audit_logs_default"
+
+// errPartitionNotEmpty rolls back dropping a partition that holds entries
+var errPartitionNotEmpty = errors.New("audit log partition is not empty")
+
+// AuditPartition is a monthly partition of audit_logs
+type AuditPartition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// migrateAuditPartitions turns audit_logs into a table partitioned by month
+// of timestamp, so queries over a period only read its months and expired
+// months can be dropped whole. An existing table is copied over once.
+func migrateAuditPartitions() error {
+	var kind string
+	if err := DB.Raw(`SELECT relkind FROM pg_class WHERE oid = to_regclass('audit_logs')`).Scan(&kind).Error; err != nil {
+		return fmt.Errorf("failed to inspect audit_logs: %w", err)
+	}
+	if kind == "p" {
+		_, err := EnsureAuditPartitions(time.Now(), AuditPartitionMonthsAhead)
+		return err
+	}
+
+	err := AuditMaintenanceDB().Transaction(func(tx *gorm.DB) error {
+		var sequence string
+		if err := tx.Raw(`SELECT pg_get_serial_sequence('audit_logs', 'id')`).Scan(&sequence).Error; err != nil {
+			return fmt.Errorf("failed to get audit_logs sequence: %w", err)
+		}
+
+		// Rows can only be routed into partitions by their timestamp, and
+		// the primary key of a partitioned table must include it
+		statements := []string{
+			`UPDATE audit_logs SET timestamp = now() WHERE timestamp IS NULL`,
+			`ALTER TABLE audit_logs RENAME TO audit_logs_unpartitioned`,
+			`ALTER SEQUENCE ` + sequence + ` OWNED BY NONE`,
+			`CREATE TABLE audit_logs (LIKE audit_logs_unpartitioned INCLUDING DEFAULTS) PARTITION BY RANGE (timestamp)`,
+			`ALTER TABLE audit_logs ALTER COLUMN timestamp SET NOT NULL`,
+			`ALTER TABLE audit_logs ADD PRIMARY KEY (id, timestamp)`,
+			`CREATE TABLE ` + auditDefaultPartition + ` PARTITION OF audit_logs DEFAULT`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		for _, table := range []string{"audit_logs", auditDefaultPartition} {
+			if err := ownByApplication(tx, table); err != nil {
+				return err
+			}
+		}
+
+		var oldest sql.NullTime
+		if err := tx.Raw(`SELECT min(timestamp) FROM audit_logs_unpartitioned`).Row().Scan(&oldest); err != nil {
+			return fmt.Errorf("failed to get oldest audit log: %w", err)
+		}
+		from := time.Now()
+		if oldest.Valid && oldest.Time.Before(from) {
+			from = oldest.Time
+		}
+		if _, err := ensureAuditPartitions(tx, from, AuditPartitionMonthsAhead); err != nil {
+			return err
+		}
+
+		statements = []string{
+			`INSERT INTO audit_logs SELECT * FROM audit_logs_unpartitioned`,
+			`DROP TABLE audit_logs_unpartitioned`,
+			`ALTER SEQUENCE ` + sequence + ` OWNED BY audit_logs.id`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Indexes and foreign keys went with the old table
+	return DB.AutoMigrate(&models.User{}, &models.Document{}, &models.AuditLog{})
+}
+
+// EnsureAuditPartitions creates the missing monthly partitions of audit_logs
+// from the month of from until months after the current one, and returns
+// how many were created
+func EnsureAuditPartitions(from time.Time, months int) (int, error) {
+	created := 0
+	err := AuditMaintenanceDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = ensureAuditPartitions(tx, from, months)
+		return err
+	})
+	return created, err
+}
+
+// ensureAuditPartitions creates missing monthly partitions in a transaction.
+// Entries of the month that went to the default partition are moved into
+// the new one, as Postgres refuses to attach it otherwise.
+func ensureAuditPartitions(tx *gorm.DB, from time.Time, months int) (int, error) {
+	existing, err := auditPartitions(tx)
+	if err != nil {
+		return 0, err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, partition := range existing {
+		have[partition.Name] = true
+	}
+
+	now := time.Now().UTC()
+	month := time.Date(from.UTC().Year(), from.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(now.Year(), now.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+
+	created := 0
+	for ; !month.After(last); month = month.AddDate(0, 1, 0) {
+		name := auditPartitionPrefix + month.Format("200601")
+		if have[name] {
+			continue
+		}
+		next := month.AddDate(0, 1, 0)
+
+		// Partition bounds can't be bound parameters
+		bounds := fmt.Sprintf("'%s' AND timestamp < '%s'", month.Format(time.RFC3339), next.Format(time.RFC3339))
+		if err := tx.Exec(`CREATE TABLE ` + name + ` (LIKE audit_logs INCLUDING DEFAULTS)`).Error; err != nil {
+			return created, fmt.Errorf("failed to create audit log partition %s: %w", name, err)
+		}
+		if err := ownByApplication(tx, name); err != nil {
+			return created, err
+		}
+
+		statements := []string{
+			`WITH moved AS (DELETE FROM ` + auditDefaultPartition + ` WHERE timestamp >= ` + bounds + ` RETURNING *)
+				INSERT INTO ` + name + ` SELECT * FROM moved`,
+			fmt.Sprintf(`ALTER TABLE audit_logs ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`,
+				name, month.Format(time.RFC3339), next.Format(time.RFC3339)),
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return created, fmt.Errorf("failed to create audit log partition %s: %w", name, err)
+			}
+		}
+		created++
+	}
+	return created, nil
+}
+
+// AuditPartitions lists the monthly partitions of audit_logs, oldest first
+func AuditPartitions() ([]AuditPartition, error) {
+	return auditPartitions(DB)
+}
+
+// auditPartitions lists the monthly partitions of audit_logs from the
+// catalog, parsing their months from their names
+func auditPartitions(tx *gorm.DB) ([]AuditPartition, error) {
+	var names []string
+	if err := tx.Raw(`SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass('audit_logs')
+		ORDER BY c.relname`).Scan(&names).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit log partitions: %w", err)
+	}
+
+	partitions := make([]AuditPartition, 0, len(names))
+	for _, name := range names {
+		month, err := time.Parse("200601", strings.TrimPrefix(name, auditPartitionPrefix))
+		if err != nil || !strings.HasPrefix(name, auditPartitionPrefix) {
+			continue
+		}
+		partitions = append(partitions, AuditPartition{
+			Name: name,
+			From: month,
+			To:   month.AddDate(0, 1, 0),
+		})
+	}
+	return partitions, nil
+}
+
+// DropAuditPartition drops a monthly partition of audit_logs if it holds no
+// entries, and reports whether it did
+func DropAuditPartition(partition AuditPartition) (bool, error) {
+	dropped := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Detaching locks out concurrent inserts before checking it is empty
+		if err := tx.Exec(`ALTER TABLE audit_logs DETACH PARTITION ` + partition.Name).Error; err != nil {
+			return fmt.Errorf("failed to detach audit log partition %s: %w", partition.Name, err)
+		}
+
+		var exists bool
+		if err := tx.Raw(`SELECT EXISTS (SELECT 1 FROM ` + partition.Name + `)`).Scan(&exists).Error; err != nil {
+			return fmt.Errorf("failed to check audit log partition %s: %w", partition.Name, err)
+		}
+		if exists {
+			// Rolling back reattaches it
+			return errPartitionNotEmpty
+		}
+
+		if err := tx.Exec(`DROP TABLE ` + partition.Name).Error; err != nil {
+			return fmt.Errorf("failed to drop audit log partition %s: %w", partition.Name, err)
+		}
+		dropped = true
+		return nil
+	})
+	if errors.Is(err, errPartitionNotEmpty) {
+		return false, nil
+	}
+	return dropped, err
+}
@@ -0,0 +1,287 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// Principal is who a query runs for under row-level security: a user, or
+// the system for work that isn't on behalf of one
+type Principal struct {
+	UserID         uint
+	OrganizationID uint
+	Role           string
+	Department     string
+	MaxAccessLevel int
+	Unrestricted   bool // Any document of the organization
+	System         bool // Every document, for background jobs
+}
+
+// principalKey is the context key of the principal queries run for
+type principalKey struct{}
+
+// connectionSetting holds the connection of a query run with a principal
+const connectionSetting = "rls:connection"
+
+// setPrincipalSQL sets the variables the document policies read, for the
+// session or, when $8 is true, for the current transaction
+const setPrincipalSQL = `SELECT
+	set_config('app.user_id', $1, $8),
+	set_config('app.organization_id', $2, $8),
+	set_config('app.role', $3, $8),
+	set_config('app.department', $4, $8),
+	set_config('app.max_access_level', $5, $8),
+	set_config('app.unrestricted', $6, $8),
+	set_config('app.system', $7, $8)`
+
+// principalConnections holds the connections whose session variables are
+// set, to be reset before the pool hands them out again
+var principalConnections sync.Map
+
+// documentPolicies restrict documents to those of the principal's
+// organization that the principal owns, is cleared for or holds a read grant
+// on, directly or on a folder above. They bound the checks of the service
+// layer, which also honours grants withheld on nearer folders. Queries
+// without a principal see no documents; background jobs run as the system
+// to see them all.
+var documentPolicies = []string{
+	`CREATE POLICY documents_principal_select ON documents FOR SELECT USING (
+		current_setting('app.system', true) = 'true'
+		OR (
+			organization_id = NULLIF(current_setting('app.organization_id', true), '')::bigint
+			AND (
+				current_setting('app.unrestricted', true) = 'true'
+				OR access_level <= NULLIF(current_setting('app.max_access_level', true), '')::int
+				OR created_by = NULLIF(current_setting('app.user_id', true), '')::bigint
+				OR EXISTS (
+					SELECT 1 FROM permissions
+					WHERE permissions.deleted_at IS NULL AND permissions.can_read = true
+					AND (
+						permissions.document_id = documents.id
+						OR permissions.folder_id IN (
+							WITH RECURSIVE ancestors(id, parent_id, depth) AS (
+								SELECT id, parent_id, 1 FROM folders WHERE id = documents.folder_id
+								UNION ALL
+								SELECT folders.id, folders.parent_id, ancestors.depth + 1 FROM folders
+								JOIN ancestors ON folders.id = ancestors.parent_id
+								WHERE ancestors.depth < 64
+							)
+							SELECT id FROM ancestors
+						)
+					)
+					AND (
+						permissions.user_id = NULLIF(current_setting('app.user_id', true), '')::bigint
+						OR permissions.role = NULLIF(current_setting('app.role', true), '')
+						OR permissions.department = NULLIF(current_setting('app.department', true), '')
+						OR permissions.group_id IN (
+							SELECT group_id FROM group_members
+							WHERE user_id = NULLIF(current_setting('app.user_id', true), '')::bigint
+						)
+					)
+				)
+			)
+		)
+	)`,
+	`CREATE POLICY documents_insert ON documents FOR INSERT WITH CHECK (true)`,
+	`CREATE POLICY documents_update ON documents FOR UPDATE USING (true)`,
+	`CREATE POLICY documents_delete ON documents FOR DELETE USING (true)`,
+}
+
+// documentPolicyNames lists the policies of documentPolicies
+var documentPolicyNames = []string{"documents_principal_select", "documents_insert", "documents_update", "documents_delete"}
+
+// ConfigureRowLevelSecurity installs the row-level security policies on
+// documents, or removes them when disabled. The table is forced under its
+// policies so they also apply to the application, which owns it. Connect must
+// have been called with row-level security enabled as well, so queries run
+// with their principal.
+func ConfigureRowLevelSecurity(enabled bool) error {
+	if DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, name := range documentPolicyNames {
+			if err := tx.Exec("DROP POLICY IF EXISTS " + name + " ON documents").Error; err != nil {
+				return err
+			}
+		}
+		if !enabled {
+			return tx.Exec("ALTER TABLE documents NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY").Error
+		}
+
+		for _, policy := range documentPolicies {
+			if err := tx.Exec(policy).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec("ALTER TABLE documents ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY").Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure row-level security: %w", err)
+	}
+	if enabled {
+		log.Println("Row-level security enabled for documents")
+	}
+	return nil
+}
+
+// registerPrincipalCallbacks runs every statement with the principal of its
+// context. Changes run in a transaction by default, so the principal is set
+// once it has begun.
+func registerPrincipalCallbacks(db *gorm.DB) error {
+	callbacks := []error{
+		db.Callback().Query().Before("gorm:query").Register("rls:begin_query", beginPrincipal),
+		db.Callback().Query().After("gorm:query").Register("rls:end_query", endPrincipal),
+		db.Callback().Row().Before("gorm:row").Register("rls:begin_row", beginPrincipal),
+		db.Callback().Row().After("gorm:row").Register("rls:end_row", endPrincipalRows),
+		db.Callback().Raw().Before("gorm:raw").Register("rls:begin_raw", beginPrincipal),
+		db.Callback().Raw().After("gorm:raw").Register("rls:end_raw", endPrincipal),
+		db.Callback().Create().After("gorm:begin_transaction").Before("gorm:create").Register("rls:begin_create", beginPrincipal),
+		db.Callback().Create().After("gorm:create").Register("rls:end_create", endPrincipal),
+		db.Callback().Update().After("gorm:begin_transaction").Before("gorm:update").Register("rls:begin_update", beginPrincipal),
+		db.Callback().Update().After("gorm:update").Register("rls:end_update", endPrincipal),
+		db.Callback().Delete().After("gorm:begin_transaction").Before("gorm:delete").Register("rls:begin_delete", beginPrincipal),
+		db.Callback().Delete().After("gorm:delete").Register("rls:end_delete", endPrincipal),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return fmt.Errorf("failed to register row-level security callbacks: %w", err)
+		}
+	}
+	return nil
+}
+
+// openWithPrincipalReset opens a connection pool that resets the principal
+// session variables of connections before reusing them
+func openWithPrincipalReset(dsn string) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return stdlib.OpenDB(*config, stdlib.OptionResetSession(resetPrincipal)), nil
+}
+
+// resetPrincipal clears the principal session variables of a connection
+func resetPrincipal(ctx context.Context, conn *pgx.Conn) error {
+	if _, ok := principalConnections.LoadAndDelete(conn); !ok {
+		return nil
+	}
+	_, err := conn.Exec(ctx, setPrincipalSQL, "", "", "", "", "", "", "", false)
+	return err
+}
+
+// ContextWithPrincipal returns a context whose queries run for the principal
+// under row-level security. Requests of signed-in users carry one.
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// SystemContext returns a context whose queries see every document, for
+// background jobs and the few requests without a user that select the
+// documents they serve themselves
+func SystemContext(ctx context.Context) context.Context {
+	return ContextWithPrincipal(ctx, Principal{System: true})
+}
+
+// WithPrincipal runs the queries of db for a principal under row-level
+// security. Without row-level security it changes nothing.
+func WithPrincipal(db *gorm.DB, principal Principal) *gorm.DB {
+	return db.WithContext(ContextWithPrincipal(db.Statement.Context, principal))
+}
+
+// AsSystem runs the queries of db as the system, seeing every document
+func AsSystem(db *gorm.DB) *gorm.DB {
+	return db.WithContext(SystemContext(db.Statement.Context))
+}
+
+// principalArgs returns the values of setPrincipalSQL for a principal. A
+// principal without a user, such as a visitor of public pages, matches no
+// owner or user grant.
+func principalArgs(principal Principal, local bool) []interface{} {
+	if principal.System {
+		return []interface{}{"", "", "", "", "", "", "true", local}
+	}
+	userID := ""
+	if principal.UserID != 0 {
+		userID = strconv.FormatUint(uint64(principal.UserID), 10)
+	}
+	return []interface{}{
+		userID,
+		strconv.FormatUint(uint64(principal.OrganizationID), 10),
+		principal.Role,
+		principal.Department,
+		strconv.Itoa(principal.MaxAccessLevel),
+		strconv.FormatBool(principal.Unrestricted),
+		"",
+		local,
+	}
+}
+
+// beginPrincipal runs a statement with the principal of its context. Outside
+// a transaction it gets a connection of its own with the principal's session
+// variables set; in one they are set until the transaction ends. Statements
+// without a principal run as they are, seeing no documents.
+func beginPrincipal(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Context == nil {
+		return
+	}
+	principal, ok := db.Statement.Context.Value(principalKey{}).(Principal)
+	if !ok {
+		return
+	}
+
+	pool, ok := db.Statement.ConnPool.(*sql.DB)
+	if !ok {
+		if _, err := db.Statement.ConnPool.ExecContext(db.Statement.Context, setPrincipalSQL, principalArgs(principal, true)...); err != nil {
+			db.AddError(fmt.Errorf("failed to set row-level security principal: %w", err))
+		}
+		return
+	}
+
+	conn, err := pool.Conn(db.Statement.Context)
+	if err != nil {
+		db.AddError(fmt.Errorf("failed to get row-level security connection: %w", err))
+		return
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+		principalConnections.Store(pgxConn, struct{}{})
+		_, err := pgxConn.Exec(db.Statement.Context, setPrincipalSQL, principalArgs(principal, false)...)
+		return err
+	})
+	if err != nil {
+		conn.Close()
+		db.AddError(fmt.Errorf("failed to set row-level security principal: %w", err))
+		return
+	}
+
+	db.Statement.ConnPool = conn
+	db.InstanceSet(connectionSetting, conn)
+}
+
+// endPrincipal returns the connection of a query with a principal to the pool
+func endPrincipal(db *gorm.DB) {
+	if value, ok := db.InstanceGet(connectionSetting); ok {
+		value.(*sql.Conn).Close()
+	}
+}
+
+// endPrincipalRows returns the connection of a row query with a principal to
+// the pool once its rows are closed, which happens after the callbacks have
+// run
+func endPrincipalRows(db *gorm.DB) {
+	if value, ok := db.InstanceGet(connectionSetting); ok {
+		// Close waits for the rows to be closed
+		go value.(*sql.Conn).Close()
+	}
+}
@@ -0,0 +1,24 @@
+// Package directory lists the users of corporate directories such as
+// Active Directory and Okta, with the groups they belong to.
+package directory
+
+import "context"
+
+// User is a directory account
+type User struct {
+	Username   string
+	Email      string
+	FirstName  string
+	LastName   string
+	Department string
+	Groups     []string // Group names or DNs
+	Disabled   bool     // Suspended or disabled in the directory
+}
+
+// Source lists the users of a directory
+type Source interface {
+	// Name identifies the directory, such as "ldap" or "okta"
+	Name() string
+	// Users returns every user of the directory
+	Users(ctx context.Context) ([]User, error)
+}
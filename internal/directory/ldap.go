@@ -0,0 +1,89 @@
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/ldap"
+)
+
+// ldapPageSize is the number of entries fetched per page; Active Directory
+// caps pages at 1000
+const ldapPageSize = 500
+
+// adAccountDisabled is the ACCOUNTDISABLE flag of userAccountControl
+const adAccountDisabled = 0x2
+
+// LDAPConfig describes how users are listed from an LDAP directory
+type LDAPConfig struct {
+	URL               string
+	StartTLS          bool
+	TLSConfig         *tls.Config
+	BindDN            string
+	BindPassword      string
+	BaseDN            string
+	Filter            string // Matches every user, such as (&(objectClass=user)(objectCategory=person))
+	UsernameAttribute string // Login name, such as sAMAccountName
+	GroupAttribute    string
+	Timeout           time.Duration
+}
+
+// LDAPSource lists the users of an LDAP directory such as Active Directory.
+// Accounts disabled through userAccountControl are reported as disabled.
+type LDAPSource struct {
+	config LDAPConfig
+}
+
+// NewLDAPSource creates a new LDAP source
+func NewLDAPSource(config LDAPConfig) *LDAPSource {
+	return &LDAPSource{config: config}
+}
+
+// Name returns the source name
+func (s *LDAPSource) Name() string {
+	return "ldap"
+}
+
+// Users returns every user matching the filter. The connection's timeout
+// bounds each operation in place of ctx.
+func (s *LDAPSource) Users(ctx context.Context) ([]User, error) {
+	conn, err := ldap.Dial(s.config.URL, s.config.StartTLS, s.config.TLSConfig, s.config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to directory: %w", err)
+	}
+	defer conn.Close()
+
+	if s.config.BindDN != "" {
+		if err := conn.Bind(s.config.BindDN, s.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("failed to bind service account: %w", err)
+		}
+	}
+
+	attributes := []string{s.config.UsernameAttribute, s.config.GroupAttribute, "mail", "givenName", "sn", "department", "userAccountControl"}
+	entries, err := conn.SearchPaged(s.config.BaseDN, s.config.Filter, attributes, ldapPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	users := make([]User, 0, len(entries))
+	for _, entry := range entries {
+		username := entry.Value(s.config.UsernameAttribute)
+		if username == "" {
+			continue
+		}
+		control, _ := strconv.ParseInt(entry.Value("userAccountControl"), 10, 64)
+		users = append(users, User{
+			Username:   username,
+			Email:      entry.Value("mail"),
+			FirstName:  entry.Value("givenName"),
+			LastName:   entry.Value("sn"),
+			Department: entry.Value("department"),
+			Groups:     entry.Values(s.config.GroupAttribute),
+			Disabled:   control&adAccountDisabled != 0,
+		})
+	}
+	return users, nil
+}
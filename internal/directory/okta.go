@@ -0,0 +1,185 @@
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// oktaPageSize is the number of users or groups fetched per request
+const oktaPageSize = 200
+
+// OktaSource lists the users of an Okta organization through its
+// management API. Only the memberships of the given groups are fetched,
+// since those are the groups mapped to roles and departments.
+type OktaSource struct {
+	client   *http.Client
+	orgURL   string
+	apiToken string
+	groups   []string
+}
+
+// oktaUser is a user of the Okta Users API
+type oktaUser struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Profile struct {
+		Login      string `json:"login"`
+		Email      string `json:"email"`
+		FirstName  string `json:"firstName"`
+		LastName   string `json:"lastName"`
+		Department string `json:"department"`
+	} `json:"profile"`
+}
+
+// NewOktaSource creates a new Okta source for an organization such as
+// https://example.okta.com, authenticating with an API token
+func NewOktaSource(orgURL, apiToken string, groups []string) *OktaSource {
+	return &OktaSource{
+		client:   &http.Client{Timeout: time.Minute},
+		orgURL:   strings.TrimSuffix(orgURL, "/"),
+		apiToken: apiToken,
+		groups:   groups,
+	}
+}
+
+// Name returns the source name
+func (s *OktaSource) Name() string {
+	return "okta"
+}
+
+// Users returns every user of the organization. Deprovisioned users are not
+// listed by Okta; suspended ones are reported as disabled.
+func (s *OktaSource) Users(ctx context.Context) ([]User, error) {
+	var users []User
+	index := make(map[string]int)
+	err := s.list(ctx, fmt.Sprintf("%s/api/v1/users?limit=%d", s.orgURL, oktaPageSize), func(raw json.RawMessage) error {
+		var user oktaUser
+		if err := json.Unmarshal(raw, &user); err != nil {
+			return err
+		}
+		index[user.ID] = len(users)
+		users = append(users, User{
+			Username:   user.Profile.Login,
+			Email:      user.Profile.Email,
+			FirstName:  user.Profile.FirstName,
+			LastName:   user.Profile.LastName,
+			Department: user.Profile.Department,
+			Disabled:   user.Status == "SUSPENDED" || user.Status == "DEPROVISIONED",
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range s.groups {
+		groupID, err := s.groupID(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if groupID == "" {
+			continue
+		}
+		err = s.list(ctx, fmt.Sprintf("%s/api/v1/groups/%s/users?limit=%d", s.orgURL, url.PathEscape(groupID), oktaPageSize), func(raw json.RawMessage) error {
+			var member oktaUser
+			if err := json.Unmarshal(raw, &member); err != nil {
+				return err
+			}
+			if i, ok := index[member.ID]; ok {
+				users[i].Groups = append(users[i].Groups, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+// groupID returns the ID of the group with a name, empty when there is none
+func (s *OktaSource) groupID(ctx context.Context, name string) (string, error) {
+	groupID := ""
+	// The search matches name prefixes; the exact name is picked out
+	err := s.list(ctx, fmt.Sprintf("%s/api/v1/groups?q=%s&limit=%d", s.orgURL, url.QueryEscape(name), oktaPageSize), func(raw json.RawMessage) error {
+		var group struct {
+			ID      string `json:"id"`
+			Profile struct {
+				Name string `json:"name"`
+			} `json:"profile"`
+		}
+		if err := json.Unmarshal(raw, &group); err != nil {
+			return err
+		}
+		if groupID == "" && strings.EqualFold(group.Profile.Name, name) {
+			groupID = group.ID
+		}
+		return nil
+	})
+	return groupID, err
+}
+
+// list calls fn for each element of a paginated collection, following the
+// next links of the responses
+func (s *OktaSource) list(ctx context.Context, rawURL string, fn func(json.RawMessage) error) error {
+	for rawURL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "SSWS "+s.apiToken)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to call Okta: %w", err)
+		}
+		var page []json.RawMessage
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+				return fmt.Errorf("okta returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				return fmt.Errorf("failed to decode Okta response: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+
+		for _, value := range page {
+			if err := fn(value); err != nil {
+				return err
+			}
+		}
+		rawURL = nextLink(resp.Header.Values("Link"))
+		// The API token is only sent to the organization
+		if rawURL != "" && !strings.HasPrefix(rawURL, s.orgURL+"/") {
+			return fmt.Errorf("okta returned a next link outside the organization: %s", rawURL)
+		}
+	}
+	return nil
+}
+
+// nextLink returns the URL of the next page from Link headers
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if !ok || !strings.Contains(params, `rel="next"`) {
+				continue
+			}
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,218 @@
+package dlp
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Built-in rule names
+const (
+	RuleCreditCard = "credit_card"
+	RuleMyNumber   = "my_number"
+	RuleUSSSN      = "us_ssn"
+	RuleKeyword    = "keyword"
+)
+
+// maxMatchesPerRule bounds the matches counted per rule in one document
+const maxMatchesPerRule = 1000
+
+var (
+	creditCardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	myNumberPattern   = regexp.MustCompile(`\b\d{4}[ -]?\d{4}[ -]?\d{4}\b`)
+	usSSNPattern      = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+)
+
+// Finding reports the matches of one rule in a text
+type Finding struct {
+	Rule   string `json:"rule"`
+	Count  int    `json:"count"`
+	Sample string `json:"sample"` // First match, masked
+}
+
+// Detector finds sensitive data in text: payment card numbers, national
+// identification numbers and configured keywords. Numbers are validated with
+// their check digits to keep false positives down.
+type Detector struct {
+	rules    map[string]bool
+	keywords []string
+}
+
+// NewDetector creates a detector running the named built-in rules and
+// matching keywords case-insensitively
+func NewDetector(rules, keywords []string) *Detector {
+	enabled := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		enabled[strings.TrimSpace(rule)] = true
+	}
+
+	var normalized []string
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			normalized = append(normalized, keyword)
+		}
+	}
+
+	return &Detector{
+		rules:    enabled,
+		keywords: normalized,
+	}
+}
+
+// Scan returns the findings in text, ordered by rule
+func (d *Detector) Scan(text string) []Finding {
+	var findings []Finding
+
+	// Card numbers are removed once found so their digits aren't matched
+	// again as shorter identification numbers
+	if d.rules[RuleCreditCard] {
+		var matches []string
+		text = creditCardPattern.ReplaceAllStringFunc(text, func(match string) string {
+			if !luhnValid(digits(match)) {
+				return match
+			}
+			matches = append(matches, match)
+			return strings.Repeat(" ", len(match))
+		})
+		findings = appendFinding(findings, RuleCreditCard, matches)
+	}
+
+	if d.rules[RuleMyNumber] {
+		var matches []string
+		for _, loc := range myNumberPattern.FindAllStringIndex(text, maxMatchesPerRule) {
+			match := text[loc[0]:loc[1]]
+			if standalone(text, loc[0], loc[1]) && myNumberValid(digits(match)) {
+				matches = append(matches, match)
+			}
+		}
+		findings = appendFinding(findings, RuleMyNumber, matches)
+	}
+
+	if d.rules[RuleUSSSN] {
+		var matches []string
+		for _, loc := range usSSNPattern.FindAllStringSubmatchIndex(text, maxMatchesPerRule) {
+			area, group, serial := text[loc[2]:loc[3]], text[loc[4]:loc[5]], text[loc[6]:loc[7]]
+			if !standalone(text, loc[0], loc[1]) ||
+				area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+				continue
+			}
+			matches = append(matches, text[loc[0]:loc[1]])
+		}
+		findings = appendFinding(findings, RuleUSSSN, matches)
+	}
+
+	if len(d.keywords) > 0 {
+		lower := strings.ToLower(text)
+		for _, keyword := range d.keywords {
+			if count := strings.Count(lower, keyword); count > 0 {
+				findings = append(findings, Finding{Rule: RuleKeyword, Count: count, Sample: keyword})
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Rule < findings[j].Rule
+	})
+
+	return findings
+}
+
+// appendFinding adds a finding for the matches of a rule, if any
+func appendFinding(findings []Finding, rule string, matches []string) []Finding {
+	if len(matches) == 0 {
+		return findings
+	}
+	return append(findings, Finding{
+		Rule:   rule,
+		Count:  len(matches),
+		Sample: mask(matches[0]),
+	})
+}
+
+// standalone reports whether the number at text[start:end] isn't part of a
+// longer group of digits such as "1234-5678-9012-3456"
+func standalone(text string, start, end int) bool {
+	isDigit := func(i int) bool { return i >= 0 && i < len(text) && text[i] >= '0' && text[i] <= '9' }
+	isSeparator := func(i int) bool { return i >= 0 && i < len(text) && (text[i] == ' ' || text[i] == '-') }
+
+	if isSeparator(start-1) && isDigit(start-2) {
+		return false
+	}
+	if isSeparator(end) && isDigit(end+1) {
+		return false
+	}
+	return true
+}
+
+// digits returns the digits of s
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// mask hides all but the last four digits of a match
+func mask(match string) string {
+	remaining := len(digits(match))
+	var b strings.Builder
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			if remaining > 4 {
+				r = '*'
+			}
+			remaining--
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// luhnValid checks the Luhn check digit of a payment card number
+func luhnValid(number string) bool {
+	if len(number) < 13 || len(number) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// myNumberValid checks the check digit of a Japanese individual number
+// (My Number)
+func myNumberValid(number string) bool {
+	if len(number) != 12 {
+		return false
+	}
+
+	sum := 0
+	for n := 1; n <= 11; n++ {
+		digit := int(number[11-n] - '0')
+		weight := n + 1
+		if n >= 7 {
+			weight = n - 5
+		}
+		sum += digit * weight
+	}
+
+	check := 0
+	if remainder := sum % 11; remainder > 1 {
+		check = 11 - remainder
+	}
+	return int(number[11]-'0') == check
+}
@@ -0,0 +1,154 @@
+package dlp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// maxPDFStreamSize bounds the decompressed size of a single PDF stream
+const maxPDFStreamSize = 16 << 20
+
+var (
+	pdfStreamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfTextPattern   = regexp.MustCompile(`(?s)BT(.*?)ET`)
+)
+
+// ExtractPDFText returns the text shown by the content streams of a PDF.
+// Extraction is best effort: strings are taken as written, so text in fonts
+// with custom encodings may come out garbled or not at all.
+func ExtractPDFText(data []byte) string {
+	var text strings.Builder
+
+	for _, loc := range pdfStreamPattern.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		stream := data[start : start+end]
+
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			reader, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			// Truncated streams still yield the text decoded so far
+			stream, _ = io.ReadAll(io.LimitReader(reader, maxPDFStreamSize))
+			reader.Close()
+		case bytes.Contains(dict, []byte("/Filter")):
+			continue
+		}
+
+		for _, block := range pdfTextPattern.FindAllSubmatch(stream, -1) {
+			extractTextBlock(&text, block[1])
+			text.WriteByte('\n')
+		}
+	}
+
+	return text.String()
+}
+
+// extractTextBlock writes the strings of a BT ... ET text object. Operators
+// moving to a new line and wide TJ spacing become whitespace.
+func extractTextBlock(text *strings.Builder, block []byte) {
+	for i := 0; i < len(block); i++ {
+		switch c := block[i]; {
+		case c == '(':
+			var s []byte
+			s, i = readLiteralString(block, i+1)
+			text.Write(s)
+		case c == '<' && i+1 < len(block) && block[i+1] != '<':
+			end := bytes.IndexByte(block[i:], '>')
+			if end < 0 {
+				return
+			}
+			hexString := bytes.Map(func(r rune) rune {
+				if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+					return -1
+				}
+				return r
+			}, block[i+1:i+end])
+			if len(hexString)%2 == 1 {
+				hexString = append(hexString, '0')
+			}
+			if decoded, err := hex.DecodeString(string(hexString)); err == nil {
+				text.Write(printable(decoded))
+			}
+			i += end
+		case c == '-' && i+1 < len(block) && block[i+1] >= '0' && block[i+1] <= '9':
+			// Large negative TJ adjustments separate words
+			j := i + 1
+			for j < len(block) && (block[j] >= '0' && block[j] <= '9' || block[j] == '.') {
+				j++
+			}
+			if j-i > 3 {
+				text.WriteByte(' ')
+			}
+			i = j - 1
+		case c == '*' && i > 0 && block[i-1] == 'T',
+			(c == 'd' || c == 'D') && i > 0 && block[i-1] == 'T',
+			c == '\'' || c == '"':
+			text.WriteByte('\n')
+		}
+	}
+}
+
+// readLiteralString reads a PDF literal string starting after its opening
+// parenthesis and returns it with the index of its closing parenthesis
+func readLiteralString(data []byte, i int) ([]byte, int) {
+	var s []byte
+	depth := 1
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n', 'r':
+				s = append(s, '\n')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				value := 0
+				for k := 0; k < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; k++ {
+					value = value*8 + int(data[i]-'0')
+					i++
+				}
+				i--
+				s = append(s, byte(value))
+			default:
+				s = append(s, e)
+			}
+		case c == '(':
+			depth++
+			s = append(s, c)
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return printable(s), i
+			}
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+	return printable(s), i
+}
+
+// printable replaces control bytes, which come from font-specific encodings,
+// with spaces
+func printable(s []byte) []byte {
+	for i, c := range s {
+		if c < 0x20 && c != '\n' && c != '\t' {
+			s[i] = ' '
+		}
+	}
+	return s
+}
@@ -0,0 +1,159 @@
+package filetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// signature identifies a file type by the bytes it starts with. Sniffing
+// from net/http doesn't recognize executables, so those are checked first.
+type signature struct {
+	prefix   []byte
+	mimeType string
+}
+
+var signatures = []signature{
+	{[]byte("\x7fELF"), "application/x-elf"},
+	{[]byte{0xFE, 0xED, 0xFA, 0xCE}, "application/x-mach-binary"},
+	{[]byte{0xFE, 0xED, 0xFA, 0xCF}, "application/x-mach-binary"},
+	{[]byte{0xCE, 0xFA, 0xED, 0xFE}, "application/x-mach-binary"},
+	{[]byte{0xCF, 0xFA, 0xED, 0xFE}, "application/x-mach-binary"},
+	{[]byte{0xCA, 0xFE, 0xBA, 0xBE}, "application/java-vm"},
+	{[]byte("#!"), "text/x-shellscript"},
+}
+
+// Detect returns the MIME type of data determined from its content
+func Detect(data []byte) string {
+	if isPortableExecutable(data) {
+		return "application/x-msdownload"
+	}
+	for _, sig := range signatures {
+		if bytes.HasPrefix(data, sig.prefix) {
+			return sig.mimeType
+		}
+	}
+
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mimeType
+}
+
+// isPortableExecutable reports whether data is a Windows executable: an MZ
+// header whose e_lfanew field points at a PE signature
+func isPortableExecutable(data []byte) bool {
+	if len(data) < 0x40 || !bytes.HasPrefix(data, []byte("MZ")) {
+		return false
+	}
+	offset := int(binary.LittleEndian.Uint32(data[0x3C:]))
+	return offset >= 0x40 && offset+4 <= len(data) && bytes.Equal(data[offset:offset+4], []byte("PE\x00\x00"))
+}
+
+// NotAllowedError reports why a file was rejected
+type NotAllowedError struct {
+	Reason string
+}
+
+func (e *NotAllowedError) Error() string {
+	return "file type not allowed: " + e.Reason
+}
+
+// Policy decides which files may be uploaded by their extension and their
+// detected content type. Blocked entries always win; when allow lists are
+// set, only the listed extensions and types are accepted. Types may use a
+// wildcard subtype such as "image/*".
+type Policy struct {
+	allowedExtensions map[string]bool
+	allowedTypes      []string
+	blockedExtensions map[string]bool
+	blockedTypes      []string
+}
+
+// NewPolicy creates a file type policy. Empty allow lists accept every
+// extension or type that isn't blocked.
+func NewPolicy(allowedExtensions, allowedTypes, blockedExtensions, blockedTypes []string) *Policy {
+	return &Policy{
+		allowedExtensions: extensionSet(allowedExtensions),
+		allowedTypes:      normalizeTypes(allowedTypes),
+		blockedExtensions: extensionSet(blockedExtensions),
+		blockedTypes:      normalizeTypes(blockedTypes),
+	}
+}
+
+// CheckName checks the extension of a file name
+func (p *Policy) CheckName(fileName string) error {
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	if p.blockedExtensions[ext] {
+		return &NotAllowedError{Reason: "extension " + ext + " is blocked"}
+	}
+	if len(p.allowedExtensions) > 0 && !p.allowedExtensions[ext] {
+		if ext == "" {
+			return &NotAllowedError{Reason: "files without an extension are not allowed"}
+		}
+		return &NotAllowedError{Reason: "extension " + ext + " is not allowed"}
+	}
+	return nil
+}
+
+// Check checks the extension of a file name and the type detected from its
+// content, and returns the detected type
+func (p *Policy) Check(fileName string, data []byte) (string, error) {
+	if err := p.CheckName(fileName); err != nil {
+		return "", err
+	}
+
+	mimeType := Detect(data)
+	if matchType(p.blockedTypes, mimeType) {
+		return mimeType, &NotAllowedError{Reason: "content type " + mimeType + " is blocked"}
+	}
+	if len(p.allowedTypes) > 0 && !matchType(p.allowedTypes, mimeType) {
+		return mimeType, &NotAllowedError{Reason: "content type " + mimeType + " is not allowed"}
+	}
+	return mimeType, nil
+}
+
+// extensionSet normalizes extensions to lower case with a leading dot
+func extensionSet(extensions []string) map[string]bool {
+	set := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
+	}
+	return set
+}
+
+// normalizeTypes lower-cases MIME types and drops empty entries
+func normalizeTypes(types []string) []string {
+	normalized := make([]string, 0, len(types))
+	for _, t := range types {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			normalized = append(normalized, t)
+		}
+	}
+	return normalized
+}
+
+// matchType reports whether mimeType matches any pattern
+func matchType(patterns []string, mimeType string) bool {
+	for _, pattern := range patterns {
+		if pattern == mimeType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mimeType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
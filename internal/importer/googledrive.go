@@ -0,0 +1,281 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	googleDriveAPI         = "https://www.googleapis.com/drive/v3"
+	googleDriveFolderType  = "application/vnd.google-apps.folder"
+	googleDriveNativeTypes = "application/vnd.google-apps."
+)
+
+// googleDriveFileFields are the file fields requested from the Drive API
+const googleDriveFileFields = "id,name,mimeType,size,modifiedTime,webViewLink,owners(displayName,emailAddress),lastModifyingUser(displayName,emailAddress)"
+
+// googleDriveExports maps native Google formats to the format they are
+// exported as and its extension. Other native formats (forms, sites,
+// shortcuts) have no file content and are skipped.
+var googleDriveExports = map[string]struct {
+	mimeType  string
+	extension string
+}{
+	"application/vnd.google-apps.document":     {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", ".docx"},
+	"application/vnd.google-apps.spreadsheet":  {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx"},
+	"application/vnd.google-apps.presentation": {"application/vnd.openxmlformats-officedocument.presentationml.presentation", ".pptx"},
+	"application/vnd.google-apps.drawing":      {"application/pdf", ".pdf"},
+}
+
+// GoogleDriveProvider connects users' Google Drive accounts
+type GoogleDriveProvider struct {
+	oauth *OAuthConfig
+}
+
+// NewGoogleDriveProvider creates a new Google Drive provider with the
+// credentials of a Google Cloud OAuth client
+func NewGoogleDriveProvider(clientID, clientSecret, redirectURL string) *GoogleDriveProvider {
+	return &GoogleDriveProvider{
+		oauth: NewOAuthConfig(clientID, clientSecret,
+			"https://accounts.google.com/o/oauth2/v2/auth",
+			"https://oauth2.googleapis.com/token",
+			redirectURL,
+			[]string{"https://www.googleapis.com/auth/drive.readonly"},
+			// Offline access with consent grants a refresh token every time
+			url.Values{"access_type": {"offline"}, "prompt": {"consent"}}, nil),
+	}
+}
+
+// Name returns the provider name
+func (p *GoogleDriveProvider) Name() string {
+	return "google_drive"
+}
+
+// OAuth returns the Google OAuth client
+func (p *GoogleDriveProvider) OAuth() *OAuthConfig {
+	return p.oauth
+}
+
+// NewSource returns a Google Drive client
+func (p *GoogleDriveProvider) NewSource(tokens TokenSource) Source {
+	return &GoogleDrive{
+		client: &http.Client{Timeout: 5 * time.Minute},
+		tokens: tokens,
+	}
+}
+
+// GoogleDrive reads a user's Google Drive, including shared drives
+type GoogleDrive struct {
+	client *http.Client
+	tokens TokenSource
+}
+
+// googleDriveFile is a file resource of the Drive API
+type googleDriveFile struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	MimeType     string    `json:"mimeType"`
+	Size         string    `json:"size"`
+	ModifiedTime time.Time `json:"modifiedTime"`
+	WebViewLink  string    `json:"webViewLink"`
+	Owners       []struct {
+		DisplayName  string `json:"displayName"`
+		EmailAddress string `json:"emailAddress"`
+	} `json:"owners"`
+	LastModifyingUser *struct {
+		DisplayName  string `json:"displayName"`
+		EmailAddress string `json:"emailAddress"`
+	} `json:"lastModifyingUser"`
+}
+
+// author returns the owner of a file, or its last editor on shared drives
+// where files have no owner
+func (f *googleDriveFile) author() string {
+	if len(f.Owners) > 0 {
+		return firstNonEmpty(f.Owners[0].EmailAddress, f.Owners[0].DisplayName)
+	}
+	return f.modifiedBy()
+}
+
+// modifiedBy returns the last editor of a file
+func (f *googleDriveFile) modifiedBy() string {
+	if f.LastModifyingUser != nil {
+		return firstNonEmpty(f.LastModifyingUser.EmailAddress, f.LastModifyingUser.DisplayName)
+	}
+	return ""
+}
+
+// Account returns the email address of the authorized user
+func (d *GoogleDrive) Account(ctx context.Context) (string, error) {
+	var result struct {
+		User struct {
+			DisplayName  string `json:"displayName"`
+			EmailAddress string `json:"emailAddress"`
+		} `json:"user"`
+	}
+	if err := d.getJSON(ctx, googleDriveAPI+"/about?fields=user(displayName,emailAddress)", &result); err != nil {
+		return "", err
+	}
+	return firstNonEmpty(result.User.EmailAddress, result.User.DisplayName), nil
+}
+
+// Folders lists the subfolders of a folder, of My Drive for ""
+func (d *GoogleDrive) Folders(ctx context.Context, parentID string) ([]Folder, error) {
+	if parentID == "" {
+		parentID = "root"
+	}
+
+	folders := []Folder{}
+	err := d.list(ctx, fmt.Sprintf("'%s' in parents and mimeType = '%s' and trashed = false", escapeDriveQuery(parentID), googleDriveFolderType), func(file *googleDriveFile) error {
+		folders = append(folders, Folder{ID: file.ID, Name: file.Name})
+		return nil
+	})
+	return folders, err
+}
+
+// Folder returns a folder by ID
+func (d *GoogleDrive) Folder(ctx context.Context, id string) (*Folder, error) {
+	var file googleDriveFile
+	if err := d.getJSON(ctx, googleDriveAPI+"/files/"+url.PathEscape(id)+"?supportsAllDrives=true&fields=id,name,mimeType", &file); err != nil {
+		return nil, err
+	}
+	if file.MimeType != googleDriveFolderType {
+		return nil, fmt.Errorf("%s is not a folder", id)
+	}
+	return &Folder{ID: file.ID, Name: file.Name}, nil
+}
+
+// Walk calls fn for every file below a folder. Native Google documents are
+// exported to their Office or PDF equivalent.
+func (d *GoogleDrive) Walk(ctx context.Context, folderID string, fn func(*File) error) error {
+	return d.walk(ctx, folderID, "", map[string]bool{}, fn)
+}
+
+// walk lists a folder whose files are placed at dir. Files can have several
+// parents, so visited folders are skipped to avoid cycles.
+func (d *GoogleDrive) walk(ctx context.Context, folderID, dir string, visited map[string]bool, fn func(*File) error) error {
+	if visited[folderID] {
+		return nil
+	}
+	visited[folderID] = true
+
+	var subfolders []googleDriveFile
+	err := d.list(ctx, fmt.Sprintf("'%s' in parents and trashed = false", escapeDriveQuery(folderID)), func(item *googleDriveFile) error {
+		if item.MimeType == googleDriveFolderType {
+			subfolders = append(subfolders, *item)
+			return nil
+		}
+
+		file := &File{
+			ID:          item.ID,
+			Name:        safeName(item.Name),
+			MimeType:    item.MimeType,
+			ModifiedAt:  item.ModifiedTime,
+			Author:      item.author(),
+			ModifiedBy:  item.modifiedBy(),
+			WebURL:      item.WebViewLink,
+			downloadURL: googleDriveAPI + "/files/" + url.PathEscape(item.ID) + "?alt=media&supportsAllDrives=true",
+		}
+		if strings.HasPrefix(item.MimeType, googleDriveNativeTypes) {
+			export, ok := googleDriveExports[item.MimeType]
+			if !ok {
+				return nil
+			}
+			file.Name += export.extension
+			file.MimeType = export.mimeType
+			file.downloadURL = googleDriveAPI + "/files/" + url.PathEscape(item.ID) + "/export?mimeType=" + url.QueryEscape(export.mimeType)
+		} else {
+			file.Size, _ = strconv.ParseInt(item.Size, 10, 64)
+		}
+		file.Path = path.Join(dir, file.Name)
+
+		return fn(file)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range subfolders {
+		if err := d.walk(ctx, folder.ID, path.Join(dir, safeName(folder.Name)), visited, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Download returns the content of a file
+func (d *GoogleDrive) Download(ctx context.Context, file *File, maxSize int64) ([]byte, error) {
+	if file.Size > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	return download(ctx, d.client, d.tokens, file.downloadURL, maxSize)
+}
+
+// list calls fn for every file matching a Drive query, following pages
+func (d *GoogleDrive) list(ctx context.Context, query string, fn func(*googleDriveFile) error) error {
+	params := url.Values{
+		"q":                         {query},
+		"fields":                    {"nextPageToken,files(" + googleDriveFileFields + ")"},
+		"pageSize":                  {"1000"},
+		"orderBy":                   {"name"},
+		"supportsAllDrives":         {"true"},
+		"includeItemsFromAllDrives": {"true"},
+	}
+
+	for {
+		var page struct {
+			NextPageToken string            `json:"nextPageToken"`
+			Files         []googleDriveFile `json:"files"`
+		}
+		if err := d.getJSON(ctx, googleDriveAPI+"/files?"+params.Encode(), &page); err != nil {
+			return err
+		}
+
+		for i := range page.Files {
+			if err := fn(&page.Files[i]); err != nil {
+				return err
+			}
+		}
+
+		if page.NextPageToken == "" {
+			return nil
+		}
+		params.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// getJSON decodes the response of an authorized GET request
+func (d *GoogleDrive) getJSON(ctx context.Context, rawURL string, result interface{}) error {
+	resp, err := get(ctx, d.client, d.tokens, rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode Google Drive response: %w", err)
+	}
+	return nil
+}
+
+// escapeDriveQuery escapes a value for a single-quoted Drive query string
+func escapeDriveQuery(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
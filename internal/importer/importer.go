@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrFileTooLarge is returned when a downloaded file exceeds the size limit
+var ErrFileTooLarge = errors.New("file exceeds the maximum upload size")
+
+// Folder is a folder of an external source
+type Folder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// File is a file found in an external source
+type File struct {
+	ID         string
+	Name       string
+	Path       string // Slash-separated path below the walked folder, ending in Name
+	MimeType   string
+	Size       int64 // 0 when unknown before download
+	ModifiedAt time.Time
+	Author     string // Creator or owner of the file
+	ModifiedBy string
+	WebURL     string // Where the file is viewed at the source
+
+	// Source-specific location of the content
+	downloadURL string
+}
+
+// Source reads folders and files of an account on an external service
+type Source interface {
+	// Account returns the name of the authorized account
+	Account(ctx context.Context) (string, error)
+	// Folders lists the subfolders of a folder, the top level for ""
+	Folders(ctx context.Context, parentID string) ([]Folder, error)
+	// Folder returns a folder by ID. Sources may accept other references,
+	// such as URLs, and return the folder with its ID.
+	Folder(ctx context.Context, id string) (*Folder, error)
+	// Walk calls fn for every file below a folder, recursively
+	Walk(ctx context.Context, folderID string, fn func(*File) error) error
+	// Download returns the content of a file, failing with ErrFileTooLarge
+	// beyond maxSize bytes
+	Download(ctx context.Context, file *File, maxSize int64) ([]byte, error)
+}
+
+// Provider is an external service users connect to through OAuth
+type Provider interface {
+	// Name returns the provider name used in URLs and records
+	Name() string
+	// OAuth returns the OAuth client of the provider
+	OAuth() *OAuthConfig
+	// NewSource returns a source reading with the given tokens
+	NewSource(tokens TokenSource) Source
+}
+
+// download fetches a file's content with a bearer token, reading at most
+// maxSize bytes
+func download(ctx context.Context, client *http.Client, tokens TokenSource, url string, maxSize int64) ([]byte, error) {
+	resp, err := get(ctx, client, tokens, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > maxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(content)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	return content, nil
+}
+
+// get sends an authorized GET request, failing on non-200 responses
+func get(ctx context.Context, client *http.Client, tokens TokenSource, url string) (*http.Response, error) {
+	accessToken, err := tokens.AccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", req.URL.Host, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s returned status %d: %s", req.URL.Host, resp.StatusCode, message)
+	}
+
+	return resp, nil
+}
+
+// pathSeparators replaces characters that would split a name into folders
+var pathSeparators = strings.NewReplacer("/", "_", "\\", "_")
+
+// safeName makes an external file or folder name usable as a path element
+func safeName(name string) string {
+	name = pathSeparators.Replace(strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
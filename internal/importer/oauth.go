@@ -0,0 +1,178 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin refreshes access tokens this long before they expire
+const tokenExpiryMargin = time.Minute
+
+// Token is an OAuth token pair
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// TokenSource supplies access tokens, refreshing them as needed
+type TokenSource interface {
+	AccessToken(ctx context.Context) (string, error)
+}
+
+// OAuthConfig is an OAuth 2.0 authorization code client
+type OAuthConfig struct {
+	client       *http.Client
+	clientID     string
+	clientSecret string
+	authURL      string
+	tokenURL     string
+	redirectURL  string
+	scopes       []string
+	authParams   url.Values // Provider-specific authorization parameters
+	tokenParams  url.Values // Provider-specific token request parameters
+}
+
+// NewOAuthConfig creates a new OAuth client
+func NewOAuthConfig(clientID, clientSecret, authURL, tokenURL, redirectURL string, scopes []string, authParams, tokenParams url.Values) *OAuthConfig {
+	return &OAuthConfig{
+		client:       &http.Client{Timeout: 30 * time.Second},
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		authURL:      authURL,
+		tokenURL:     tokenURL,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		authParams:   authParams,
+		tokenParams:  tokenParams,
+	}
+}
+
+// AuthCodeURL returns the URL the user visits to grant access, carrying
+// state back to the redirect URL
+func (c *OAuthConfig) AuthCodeURL(state string) string {
+	params := url.Values{
+		"client_id":     {c.clientID},
+		"redirect_uri":  {c.redirectURL},
+		"response_type": {"code"},
+		"scope":         {strings.Join(c.scopes, " ")},
+		"state":         {state},
+	}
+	for key, values := range c.authParams {
+		params[key] = values
+	}
+	return c.authURL + "?" + params.Encode()
+}
+
+// Exchange trades an authorization code for a token
+func (c *OAuthConfig) Exchange(ctx context.Context, code string) (*Token, error) {
+	return c.requestToken(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {c.redirectURL},
+	})
+}
+
+// Refresh obtains a new access token with a refresh token
+func (c *OAuthConfig) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	token, err := c.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Providers may keep the refresh token without returning it again
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// requestToken posts a grant to the token endpoint
+func (c *OAuthConfig) requestToken(ctx context.Context, params url.Values) (*Token, error) {
+	params.Set("client_id", c.clientID)
+	params.Set("client_secret", c.clientSecret)
+	for key, values := range c.tokenParams {
+		params[key] = values
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, message)
+	}
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access token")
+	}
+
+	return &Token{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(result.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// TokenSource returns a token source starting from token. onRefresh is
+// called with every refreshed token so it can be stored.
+func (c *OAuthConfig) TokenSource(token *Token, onRefresh func(*Token)) TokenSource {
+	return &refreshingTokenSource{config: c, token: token, onRefresh: onRefresh}
+}
+
+// refreshingTokenSource refreshes its token shortly before it expires
+type refreshingTokenSource struct {
+	mu        sync.Mutex
+	config    *OAuthConfig
+	token     *Token
+	onRefresh func(*Token)
+}
+
+// AccessToken returns a valid access token
+func (s *refreshingTokenSource) AccessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Now().Add(tokenExpiryMargin).Before(s.token.Expiry) {
+		return s.token.AccessToken, nil
+	}
+	if s.token.RefreshToken == "" {
+		return "", fmt.Errorf("access token expired and no refresh token was granted")
+	}
+
+	token, err := s.config.Refresh(ctx, s.token.RefreshToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh access token: %w", err)
+	}
+	s.token = token
+	if s.onRefresh != nil {
+		s.onRefresh(token)
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,361 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const graphAPI = "https://graph.microsoft.com/v1.0"
+
+// graphItemFields are the drive item fields requested from Microsoft Graph
+const graphItemFields = "id,name,size,file,folder,webUrl,createdBy,lastModifiedBy,lastModifiedDateTime"
+
+// SharePointProvider connects users' Microsoft 365 accounts to import
+// SharePoint sites and OneDrive
+type SharePointProvider struct {
+	oauth *OAuthConfig
+}
+
+// NewSharePointProvider creates a new SharePoint provider with the
+// credentials of a Microsoft Entra app registration. tenantID is the
+// directory to sign in to, or "organizations" for any work account.
+func NewSharePointProvider(tenantID, clientID, clientSecret, redirectURL string) *SharePointProvider {
+	scopes := []string{"offline_access", "User.Read", "Sites.Read.All", "Files.Read.All"}
+	endpoint := "https://login.microsoftonline.com/" + url.PathEscape(tenantID) + "/oauth2/v2.0"
+	return &SharePointProvider{
+		oauth: NewOAuthConfig(clientID, clientSecret,
+			endpoint+"/authorize",
+			endpoint+"/token",
+			redirectURL,
+			scopes,
+			nil,
+			// The Microsoft identity platform expects the scopes on token requests too
+			url.Values{"scope": {strings.Join(scopes, " ")}}),
+	}
+}
+
+// Name returns the provider name
+func (p *SharePointProvider) Name() string {
+	return "sharepoint"
+}
+
+// OAuth returns the Microsoft OAuth client
+func (p *SharePointProvider) OAuth() *OAuthConfig {
+	return p.oauth
+}
+
+// NewSource returns a SharePoint client
+func (p *SharePointProvider) NewSource(tokens TokenSource) Source {
+	return &SharePoint{
+		client: &http.Client{Timeout: 5 * time.Minute},
+		tokens: tokens,
+	}
+}
+
+// SharePoint reads SharePoint sites and OneDrive through Microsoft Graph.
+// Folder IDs name what they refer to: "site:<site>" for all document
+// libraries of a site, "drive:<drive>" for a library and
+// "item:<drive>:<item>" for a folder within one. The top level lists the
+// sites the user can access and their OneDrive.
+type SharePoint struct {
+	client *http.Client
+	tokens TokenSource
+}
+
+// graphIdentity is a user reference of Microsoft Graph
+type graphIdentity struct {
+	User *struct {
+		DisplayName string `json:"displayName"`
+		Email       string `json:"email"`
+	} `json:"user"`
+}
+
+// name returns the email address or name of the user
+func (i *graphIdentity) name() string {
+	if i == nil || i.User == nil {
+		return ""
+	}
+	return firstNonEmpty(i.User.Email, i.User.DisplayName)
+}
+
+// graphDriveItem is a file or folder of a drive
+type graphDriveItem struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	WebURL string `json:"webUrl"`
+	File   *struct {
+		MimeType string `json:"mimeType"`
+	} `json:"file"`
+	Folder               *struct{}      `json:"folder"`
+	CreatedBy            *graphIdentity `json:"createdBy"`
+	LastModifiedBy       *graphIdentity `json:"lastModifiedBy"`
+	LastModifiedDateTime time.Time      `json:"lastModifiedDateTime"`
+}
+
+// Account returns the sign-in name of the authorized user
+func (s *SharePoint) Account(ctx context.Context) (string, error) {
+	var me struct {
+		DisplayName       string `json:"displayName"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}
+	if err := s.getJSON(ctx, graphAPI+"/me?$select=displayName,userPrincipalName", &me); err != nil {
+		return "", err
+	}
+	return firstNonEmpty(me.UserPrincipalName, me.DisplayName), nil
+}
+
+// Folders lists the sites and OneDrive of the user at the top level, the
+// document libraries of a site, or the subfolders of a library or folder
+func (s *SharePoint) Folders(ctx context.Context, parentID string) ([]Folder, error) {
+	folders := []Folder{}
+
+	if parentID == "" {
+		var drive struct {
+			ID string `json:"id"`
+		}
+		// Accounts without a OneDrive license only list sites
+		if err := s.getJSON(ctx, graphAPI+"/me/drive?$select=id", &drive); err == nil {
+			folders = append(folders, Folder{ID: "drive:" + drive.ID, Name: "OneDrive"})
+		}
+		err := s.list(ctx, graphAPI+"/sites?search=*&$select=id,displayName,name", func(raw json.RawMessage) error {
+			var site struct {
+				ID          string `json:"id"`
+				Name        string `json:"name"`
+				DisplayName string `json:"displayName"`
+			}
+			if err := json.Unmarshal(raw, &site); err != nil {
+				return err
+			}
+			folders = append(folders, Folder{ID: "site:" + site.ID, Name: firstNonEmpty(site.DisplayName, site.Name)})
+			return nil
+		})
+		return folders, err
+	}
+
+	kind, ownerID, itemID, err := parseSharePointID(parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == "site" {
+		err := s.listDrives(ctx, ownerID, func(id, name string) error {
+			folders = append(folders, Folder{ID: "drive:" + id, Name: name})
+			return nil
+		})
+		return folders, err
+	}
+
+	driveID := ownerID
+	err = s.listChildren(ctx, driveID, itemID, func(item *graphDriveItem) error {
+		if item.Folder != nil {
+			folders = append(folders, Folder{ID: "item:" + driveID + ":" + item.ID, Name: item.Name})
+		}
+		return nil
+	})
+	return folders, err
+}
+
+// Folder returns a site, library or folder by ID. A site can also be given
+// by its URL, such as https://contoso.sharepoint.com/sites/hr.
+func (s *SharePoint) Folder(ctx context.Context, id string) (*Folder, error) {
+	if strings.HasPrefix(id, "https://") {
+		siteURL, err := url.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid site URL: %w", err)
+		}
+		ref := siteURL.Host
+		if sitePath := strings.TrimSuffix(siteURL.EscapedPath(), "/"); sitePath != "" {
+			ref += ":" + sitePath
+		}
+		return s.site(ctx, ref)
+	}
+
+	kind, ownerID, itemID, err := parseSharePointID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	driveID := ownerID
+	switch kind {
+	case "site":
+		return s.site(ctx, url.PathEscape(ownerID))
+	case "drive":
+		var drive struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := s.getJSON(ctx, graphAPI+"/drives/"+url.PathEscape(driveID)+"?$select=id,name", &drive); err != nil {
+			return nil, err
+		}
+		return &Folder{ID: "drive:" + drive.ID, Name: drive.Name}, nil
+	default:
+		var item graphDriveItem
+		if err := s.getJSON(ctx, graphAPI+"/drives/"+url.PathEscape(driveID)+"/items/"+url.PathEscape(itemID)+"?$select=id,name,folder", &item); err != nil {
+			return nil, err
+		}
+		if item.Folder == nil {
+			return nil, fmt.Errorf("%s is not a folder", id)
+		}
+		return &Folder{ID: "item:" + driveID + ":" + item.ID, Name: item.Name}, nil
+	}
+}
+
+// site returns a site by escaped ID, host name or "<host>:<path>"
+func (s *SharePoint) site(ctx context.Context, ref string) (*Folder, error) {
+	var site struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	}
+	if err := s.getJSON(ctx, graphAPI+"/sites/"+ref+"?$select=id,displayName,name", &site); err != nil {
+		return nil, err
+	}
+	return &Folder{ID: "site:" + site.ID, Name: firstNonEmpty(site.DisplayName, site.Name)}, nil
+}
+
+// Walk calls fn for every file below a site, library or folder. The files
+// of a site are placed in a folder per document library.
+func (s *SharePoint) Walk(ctx context.Context, folderID string, fn func(*File) error) error {
+	kind, ownerID, itemID, err := parseSharePointID(folderID)
+	if err != nil {
+		return err
+	}
+
+	if kind == "site" {
+		return s.listDrives(ctx, ownerID, func(id, name string) error {
+			return s.walk(ctx, id, "root", safeName(name), fn)
+		})
+	}
+	return s.walk(ctx, ownerID, itemID, "", fn)
+}
+
+// walk lists a folder of a drive whose files are placed at dir
+func (s *SharePoint) walk(ctx context.Context, driveID, itemID, dir string, fn func(*File) error) error {
+	var subfolders []graphDriveItem
+	err := s.listChildren(ctx, driveID, itemID, func(item *graphDriveItem) error {
+		if item.Folder != nil {
+			subfolders = append(subfolders, *item)
+			return nil
+		}
+		// Items without file content, such as OneNote notebooks, are skipped
+		if item.File == nil {
+			return nil
+		}
+
+		name := safeName(item.Name)
+		return fn(&File{
+			ID:          driveID + ":" + item.ID,
+			Name:        name,
+			Path:        path.Join(dir, name),
+			MimeType:    item.File.MimeType,
+			Size:        item.Size,
+			ModifiedAt:  item.LastModifiedDateTime,
+			Author:      item.CreatedBy.name(),
+			ModifiedBy:  item.LastModifiedBy.name(),
+			WebURL:      item.WebURL,
+			downloadURL: graphAPI + "/drives/" + url.PathEscape(driveID) + "/items/" + url.PathEscape(item.ID) + "/content",
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range subfolders {
+		if err := s.walk(ctx, driveID, folder.ID, path.Join(dir, safeName(folder.Name)), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Download returns the content of a file. Graph redirects to a
+// pre-authenticated URL, so the token isn't sent to the storage host.
+func (s *SharePoint) Download(ctx context.Context, file *File, maxSize int64) ([]byte, error) {
+	if file.Size > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	return download(ctx, s.client, s.tokens, file.downloadURL, maxSize)
+}
+
+// listDrives calls fn for every document library of a site
+func (s *SharePoint) listDrives(ctx context.Context, siteID string, fn func(id, name string) error) error {
+	return s.list(ctx, graphAPI+"/sites/"+url.PathEscape(siteID)+"/drives?$select=id,name", func(raw json.RawMessage) error {
+		var drive struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(raw, &drive); err != nil {
+			return err
+		}
+		return fn(drive.ID, drive.Name)
+	})
+}
+
+// listChildren calls fn for every item of a drive folder, "root" for the
+// top of the drive
+func (s *SharePoint) listChildren(ctx context.Context, driveID, itemID string, fn func(*graphDriveItem) error) error {
+	return s.list(ctx, graphAPI+"/drives/"+url.PathEscape(driveID)+"/items/"+url.PathEscape(itemID)+"/children?$top=999&$select="+graphItemFields, func(raw json.RawMessage) error {
+		var item graphDriveItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return err
+		}
+		return fn(&item)
+	})
+}
+
+// list calls fn for every value of a Graph collection, following pages
+func (s *SharePoint) list(ctx context.Context, rawURL string, fn func(json.RawMessage) error) error {
+	for rawURL != "" {
+		var page struct {
+			NextLink string            `json:"@odata.nextLink"`
+			Value    []json.RawMessage `json:"value"`
+		}
+		if err := s.getJSON(ctx, rawURL, &page); err != nil {
+			return err
+		}
+
+		for _, value := range page.Value {
+			if err := fn(value); err != nil {
+				return err
+			}
+		}
+		rawURL = page.NextLink
+	}
+	return nil
+}
+
+// getJSON decodes the response of an authorized GET request
+func (s *SharePoint) getJSON(ctx context.Context, rawURL string, result interface{}) error {
+	resp, err := get(ctx, s.client, s.tokens, rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode Microsoft Graph response: %w", err)
+	}
+	return nil
+}
+
+// parseSharePointID splits a folder ID into its kind, the site or drive ID
+// and the item ID of folders ("root" for a whole library)
+func parseSharePointID(id string) (kind, ownerID, itemID string, err error) {
+	parts := strings.SplitN(id, ":", 3)
+	switch {
+	case len(parts) == 2 && parts[0] == "site" && parts[1] != "":
+		return "site", parts[1], "", nil
+	case len(parts) == 2 && parts[0] == "drive" && parts[1] != "":
+		return "drive", parts[1], "root", nil
+	case len(parts) == 3 && parts[0] == "item" && parts[1] != "" && parts[2] != "":
+		return "item", parts[1], parts[2], nil
+	}
+	return "", "", "", fmt.Errorf("invalid SharePoint folder ID: %s", id)
+}
@@ -0,0 +1,143 @@
+package ingest
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// maxMessageDepth bounds the nesting of multipart bodies
+const maxMessageDepth = 10
+
+// Message is an inbound email
+type Message struct {
+	From        string   // Sender address
+	Recipients  []string // Addresses the message was delivered to
+	Subject     string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Name    string
+	Content []byte
+}
+
+// ParseMessage reads a raw MIME message and collects its attachments. The
+// recipients are taken from the To and Cc headers.
+func ParseMessage(r io.Reader) (*Message, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+
+	message := &Message{
+		From:    from.Address,
+		Subject: decodeHeader(msg.Header.Get("Subject")),
+	}
+	for _, header := range []string{"To", "Cc"} {
+		addresses, err := msg.Header.AddressList(header)
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			message.Recipients = append(message.Recipients, address.Address)
+		}
+	}
+
+	if err := message.collect(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Disposition"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body, 0); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// collect adds the attachments of a message part, descending into multipart bodies
+func (m *Message) collect(contentType, disposition, encoding string, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMessageDepth {
+			return errors.New("message is nested too deeply")
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read message part: %w", err)
+			}
+			if err := m.collect(part.Header.Get("Content-Type"), part.Header.Get("Content-Disposition"), part.Header.Get("Content-Transfer-Encoding"), part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	name := attachmentName(disposition, params)
+	if name == "" {
+		// Message bodies are not documents
+		return nil
+	}
+
+	content, err := io.ReadAll(decodeBody(encoding, body))
+	if err != nil {
+		return fmt.Errorf("failed to decode attachment %q: %w", name, err)
+	}
+	m.Attachments = append(m.Attachments, Attachment{Name: name, Content: content})
+	return nil
+}
+
+// attachmentName returns the file name of a part, empty for parts that are
+// not attached files
+func attachmentName(disposition string, typeParams map[string]string) string {
+	kind, params, err := mime.ParseMediaType(disposition)
+	if err == nil && params["filename"] != "" {
+		return decodeHeader(params["filename"])
+	}
+	if err == nil && kind == "attachment" {
+		if typeParams["name"] != "" {
+			return decodeHeader(typeParams["name"])
+		}
+		return "attachment"
+	}
+	// Older clients only name the file in the content type
+	return decodeHeader(typeParams["name"])
+}
+
+// decodeBody undoes the transfer encoding of a part
+func decodeBody(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// The decoder skips line breaks
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// decodeHeader decodes RFC 2047 encoded words, keeping the raw value when
+// they can't be decoded
+func decodeHeader(value string) string {
+	decoder := &mime.WordDecoder{}
+	decoded, err := decoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,36 @@
+package ingest
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFileTooLarge is returned when a file exceeds the size limit of a read
+var ErrFileTooLarge = errors.New("file exceeds the maximum size")
+
+// File is a file waiting in a drop location
+type File struct {
+	Path       string // Slash-separated, relative to the drop's root
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// Drop is a location where other systems deposit files for ingestion
+type Drop interface {
+	// Name identifies the drop in records and audit details
+	Name() string
+	// Open connects to the drop for one collection pass
+	Open() (Session, error)
+}
+
+// Session is an open connection to a drop
+type Session interface {
+	// List returns the regular files below the drop's root. Hidden files
+	// and directories, often partial transfers, are left out.
+	List() ([]File, error)
+	// Read returns a file's content, failing with ErrFileTooLarge beyond maxSize bytes
+	Read(path string, maxSize int64) ([]byte, error)
+	// Remove deletes a collected file
+	Remove(path string) error
+	Close() error
+}
@@ -0,0 +1,153 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WatchFolder is a local or network directory watched for new files, with
+// the settings of the documents created from them
+type WatchFolder struct {
+	Name        string `json:"name"` // Defaults to the path
+	Path        string `json:"path"`
+	Owner       string `json:"owner"` // Username the documents are created as
+	FolderID    uint   `json:"folder_id"`
+	Category    string `json:"category"`
+	Department  string `json:"department"`   // Granted read access; defaults to the owner's
+	AccessLevel int    `json:"access_level"` // 0 classifies each file
+}
+
+// LoadWatchFolders reads watch folders from a JSON file holding an array of them
+func LoadWatchFolders(file string) ([]WatchFolder, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch folders: %w", err)
+	}
+
+	var folders []WatchFolder
+	if err := json.Unmarshal(data, &folders); err != nil {
+		return nil, fmt.Errorf("failed to parse watch folders: %w", err)
+	}
+
+	names := make(map[string]bool, len(folders))
+	for i := range folders {
+		folder := &folders[i]
+		if folder.Path == "" || folder.Owner == "" {
+			return nil, fmt.Errorf("watch folder %d needs a path and an owner", i+1)
+		}
+		if folder.Name == "" {
+			folder.Name = folder.Path
+		}
+		// Files are recognized by the name of their watch folder
+		if names[folder.Name] {
+			return nil, fmt.Errorf("duplicate watch folder %q", folder.Name)
+		}
+		names[folder.Name] = true
+	}
+	return folders, nil
+}
+
+// LocalDrop is a directory on a local or network file system, such as an
+// NFS export scanners write to
+type LocalDrop struct {
+	name string
+	root string
+}
+
+// NewLocalDrop creates a drop for the directory at root
+func NewLocalDrop(name, root string) *LocalDrop {
+	return &LocalDrop{name: name, root: root}
+}
+
+// Name returns the drop's name
+func (d *LocalDrop) Name() string {
+	return d.name
+}
+
+// Open checks that the directory is available, as network mounts may not be
+func (d *LocalDrop) Open() (Session, error) {
+	info, err := os.Stat(d.root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", d.root)
+	}
+	return &localSession{root: d.root}, nil
+}
+
+// localSession reads a local drop directly
+type localSession struct {
+	root string
+}
+
+func (s *localSession) List() ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(s.root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == s.root {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(rel), Size: info.Size(), ModifiedAt: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.root, err)
+	}
+	return files, nil
+}
+
+func (s *localSession) Read(filePath string, maxSize int64) ([]byte, error) {
+	file, err := os.Open(filepath.Join(s.root, filepath.FromSlash(filePath)))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	return content, nil
+}
+
+func (s *localSession) Remove(filePath string) error {
+	return os.Remove(filepath.Join(s.root, filepath.FromSlash(filePath)))
+}
+
+func (s *localSession) Close() error {
+	return nil
+}
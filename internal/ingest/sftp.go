@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPDrop is a directory on an SFTP server, such as the one scanners and
+// legacy systems upload to
+type SFTPDrop struct {
+	name      string
+	address   string
+	directory string
+	config    *ssh.ClientConfig
+}
+
+// NewSFTPDrop creates a drop for directory on the server at address
+// (host:port). The server must present hostKey, a public key in
+// authorized_keys format. The user authenticates with the private key at
+// privateKeyPath, a password, or both.
+func NewSFTPDrop(name, address, user, password, privateKeyPath, hostKey, directory string) (*SFTPDrop, error) {
+	if hostKey == "" {
+		return nil, errors.New("a host key is required")
+	}
+	serverKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse host key: %w", err)
+	}
+
+	var methods []ssh.AuthMethod
+	if privateKeyPath != "" {
+		pemBytes, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if password != "" {
+		methods = append(methods, ssh.Password(password))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("a password or private key is required")
+	}
+
+	return &SFTPDrop{
+		name:      name,
+		address:   address,
+		directory: directory,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            methods,
+			HostKeyCallback: ssh.FixedHostKey(serverKey),
+			Timeout:         30 * time.Second,
+		},
+	}, nil
+}
+
+// Name returns the drop's name
+func (d *SFTPDrop) Name() string {
+	return d.name
+}
+
+// Open connects to the server
+func (d *SFTPDrop) Open() (Session, error) {
+	client, err := sftp.Dial(d.address, d.config)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpSession{client: client, root: d.directory}, nil
+}
+
+// sftpSession is a connection to an SFTP drop
+type sftpSession struct {
+	client *sftp.Client
+	root   string
+}
+
+func (s *sftpSession) List() ([]File, error) {
+	var files []File
+	if err := s.walk("", &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// walk adds the files below the directory at dir, relative to the root
+func (s *sftpSession) walk(dir string, files *[]File) error {
+	entries, err := s.client.ReadDir(path.Join(s.root, dir))
+	if err != nil {
+		return fmt.Errorf("failed to list %q: %w", path.Join(s.root, dir), err)
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name, ".") {
+			continue
+		}
+		name := path.Join(dir, entry.Name)
+		switch {
+		case entry.IsDir():
+			if err := s.walk(name, files); err != nil {
+				return err
+			}
+		case entry.IsRegular():
+			*files = append(*files, File{Path: name, Size: entry.Size, ModifiedAt: entry.ModTime})
+		}
+	}
+	return nil
+}
+
+func (s *sftpSession) Read(filePath string, maxSize int64) ([]byte, error) {
+	content, err := s.client.ReadFile(path.Join(s.root, filePath), maxSize)
+	if errors.Is(err, sftp.ErrFileTooLarge) {
+		return nil, ErrFileTooLarge
+	}
+	return content, err
+}
+
+func (s *sftpSession) Remove(filePath string) error {
+	return s.client.Remove(path.Join(s.root, filePath))
+}
+
+func (s *sftpSession) Close() error {
+	return s.client.Close()
+}
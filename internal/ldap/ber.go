@@ -0,0 +1,201 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Identifier octets of the BER encoding used by LDAP
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = constructed | 0x10
+	tagSet         = constructed | 0x11
+)
+
+// maxPacketSize bounds the messages accepted from the server
+const maxPacketSize = 16 << 20
+
+// packet is a BER element. Constructed elements hold children, primitive
+// elements a value.
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func newSequence(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag, children: children}
+}
+
+func newOctetString(tag byte, s string) *packet {
+	return &packet{tag: tag, value: []byte(s)}
+}
+
+func newInteger(tag byte, n int64) *packet {
+	// Minimal two's complement, big-endian
+	var value []byte
+	for {
+		value = append([]byte{byte(n)}, value...)
+		n >>= 8
+		if (n == 0 && value[0]&0x80 == 0) || (n == -1 && value[0]&0x80 != 0) {
+			break
+		}
+	}
+	return &packet{tag: tag, value: value}
+}
+
+func newBoolean(b bool) *packet {
+	if b {
+		return &packet{tag: tagBoolean, value: []byte{0xff}}
+	}
+	return &packet{tag: tagBoolean, value: []byte{0x00}}
+}
+
+// encode returns the BER encoding of the packet
+func (p *packet) encode() []byte {
+	content := p.value
+	if p.tag&constructed != 0 {
+		content = nil
+		for _, child := range p.children {
+			content = append(content, child.encode()...)
+		}
+	}
+
+	out := []byte{p.tag}
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// int returns the value of an integer or enumerated packet
+func (p *packet) int() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errors.New("invalid integer")
+	}
+	n := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// child returns the i-th child, or an empty packet when there is none so
+// malformed responses surface as mismatched values rather than panics
+func (p *packet) child(i int) *packet {
+	if i < len(p.children) {
+		return p.children[i]
+	}
+	return &packet{}
+}
+
+// readPacket reads one BER element from r
+func readPacket(r *bufio.Reader) (*packet, error) {
+	var header []byte
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, tag)
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, first)
+
+	length := int(first)
+	if first&0x80 != 0 {
+		count := int(first & 0x7f)
+		if count == 0 || count > 4 {
+			return nil, fmt.Errorf("unsupported BER length of %d octets", count)
+		}
+		length = 0
+		for i := 0; i < count; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			header = append(header, b)
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("message of %d bytes is too large", length)
+	}
+
+	data := make([]byte, len(header)+length)
+	copy(data, header)
+	if _, err := io.ReadFull(r, data[len(header):]); err != nil {
+		return nil, err
+	}
+
+	p, rest, err := decodePacket(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after message")
+	}
+	return p, nil
+}
+
+// decodePacket decodes the BER element at the start of data
+func decodePacket(data []byte) (*packet, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, errors.New("truncated BER element")
+	}
+	p := &packet{tag: data[0]}
+	if p.tag&0x1f == 0x1f {
+		return nil, nil, errors.New("unsupported BER tag")
+	}
+
+	length, offset := int(data[1]), 2
+	if data[1]&0x80 != 0 {
+		count := int(data[1] & 0x7f)
+		if count == 0 || count > 4 || len(data) < 2+count {
+			return nil, nil, errors.New("invalid BER length")
+		}
+		length = 0
+		for _, b := range data[2 : 2+count] {
+			length = length<<8 | int(b)
+		}
+		offset += count
+	}
+	if length < 0 || len(data)-offset < length {
+		return nil, nil, errors.New("truncated BER element")
+	}
+
+	content := data[offset : offset+length]
+	if p.tag&constructed == 0 {
+		p.value = content
+		return p, data[offset+length:], nil
+	}
+
+	for len(content) > 0 {
+		child, rest, err := decodePacket(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.children = append(p.children, child)
+		content = rest
+	}
+	return p, data[offset+length:], nil
+}
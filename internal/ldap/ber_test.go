@@ -0,0 +1,144 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeInteger(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "020100"},
+		{1, "020101"},
+		{127, "02017f"},
+		{128, "02020080"},
+		{256, "02020100"},
+		{-1, "0201ff"},
+		{-128, "020180"},
+		{-129, "0202ff7f"},
+		{1 << 40, "0206010000000000"},
+	}
+	for _, tt := range tests {
+		p := newInteger(tagInteger, tt.n)
+		if got := hex.EncodeToString(p.encode()); got != tt.want {
+			t.Errorf("newInteger(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+		if n, err := p.int(); err != nil || n != tt.n {
+			t.Errorf("int() of %d = %d, %v", tt.n, n, err)
+		}
+	}
+}
+
+func TestEncodeLengths(t *testing.T) {
+	tests := []struct {
+		size   int
+		header string
+	}{
+		{0, "0400"},
+		{0x7f, "047f"},
+		{0x80, "048180"},
+		{0xff, "0481ff"},
+		{0x100, "04820100"},
+		{0x10000, "048400010000"},
+	}
+	for _, tt := range tests {
+		encoded := newOctetString(tagOctetString, strings.Repeat("a", tt.size)).encode()
+		header := hex.EncodeToString(encoded[:len(encoded)-tt.size])
+		if header != tt.header {
+			t.Errorf("header of %d bytes = %s, want %s", tt.size, header, tt.header)
+		}
+		decoded, rest, err := decodePacket(encoded)
+		if err != nil || len(rest) != 0 || len(decoded.value) != tt.size {
+			t.Errorf("decodePacket(%d bytes) = %d bytes, %x, %v", tt.size, len(decoded.value), rest, err)
+		}
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	message := newSequence(tagSequence,
+		newInteger(tagInteger, 7),
+		newSequence(opBindRequest,
+			newInteger(tagInteger, 3),
+			newOctetString(tagOctetString, "cn=admin,dc=example,dc=com"),
+			newOctetString(bindAuthSimple, "secret"),
+		),
+		newSequence(messageControls, newSequence(tagSequence, newBoolean(true))),
+	)
+
+	encoded := message.encode()
+	decoded, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+	if err != nil {
+		t.Fatalf("readPacket: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.encode(), encoded) {
+		t.Errorf("round trip = %x, want %x", decoded.encode(), encoded)
+	}
+	if got := string(decoded.child(1).child(1).value); got != "cn=admin,dc=example,dc=com" {
+		t.Errorf("bind DN = %q", got)
+	}
+	if got := decoded.child(2).child(0).child(0).value; !bytes.Equal(got, []byte{0xff}) {
+		t.Errorf("boolean = %x", got)
+	}
+}
+
+func TestDecodePacketRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"tag only", "30"},
+		{"truncated value", "040301"},
+		{"indefinite length", "3080"},
+		{"length of five octets", "04850000000001"},
+		{"truncated long length", "048201"},
+		{"length beyond the data", "0484ffffffff"},
+		{"high tag number", "1f8101"},
+		{"truncated child", "3003040201"},
+		{"malformed child", "30020480"},
+	}
+	for _, tt := range tests {
+		data, err := hex.DecodeString(tt.encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := decodePacket(data); err == nil {
+			t.Errorf("%s: decodePacket(%s) succeeded", tt.name, tt.encoded)
+		}
+	}
+}
+
+func TestReadPacketRejectsMalformedMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"truncated", "300504"},
+		{"too large", "30840100000100"},
+		{"indefinite length", "3080"},
+		{"malformed content", "30020480"},
+	}
+	for _, tt := range tests {
+		data, err := hex.DecodeString(tt.encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readPacket(bufio.NewReader(bytes.NewReader(data))); err == nil {
+			t.Errorf("%s: readPacket(%s) succeeded", tt.name, tt.encoded)
+		}
+	}
+}
+
+func TestPacketIntRejectsInvalidValues(t *testing.T) {
+	for _, value := range [][]byte{nil, make([]byte, 9)} {
+		if _, err := (&packet{tag: tagInteger, value: value}).int(); err == nil {
+			t.Errorf("int() of %d bytes succeeded", len(value))
+		}
+	}
+}
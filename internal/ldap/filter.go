@@ -0,0 +1,141 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choices
+const (
+	filterAnd      = classContext | constructed | 0
+	filterOr       = classContext | constructed | 1
+	filterNot      = classContext | constructed | 2
+	filterEquality = classContext | constructed | 3
+	filterPresent  = classContext | 7
+)
+
+// maxFilterDepth bounds the nesting of filters
+const maxFilterDepth = 20
+
+// EscapeFilter escapes a value for use in a filter (RFC 4515)
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter encodes a string filter. Conjunctions, disjunctions,
+// negations, equality and presence are supported.
+func compileFilter(filter string) (*packet, error) {
+	filter = strings.TrimSpace(filter)
+	if !strings.HasPrefix(filter, "(") {
+		filter = "(" + filter + ")"
+	}
+	p, rest, err := parseFilter(filter, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", filter, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid filter %q: trailing %q", filter, rest)
+	}
+	return p, nil
+}
+
+// parseFilter parses the parenthesized filter at the start of s
+func parseFilter(s string, depth int) (*packet, string, error) {
+	if depth > maxFilterDepth {
+		return nil, "", fmt.Errorf("nested too deeply")
+	}
+	if len(s) < 2 || s[0] != '(' {
+		return nil, "", fmt.Errorf("expected '('")
+	}
+
+	switch s[1] {
+	case '&', '|':
+		tag := byte(filterAnd)
+		if s[1] == '|' {
+			tag = filterOr
+		}
+		p := newSequence(tag)
+		rest := s[2:]
+		for strings.HasPrefix(rest, "(") {
+			child, next, err := parseFilter(rest, depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			p.children = append(p.children, child)
+			rest = next
+		}
+		if !strings.HasPrefix(rest, ")") {
+			return nil, "", fmt.Errorf("expected ')'")
+		}
+		return p, rest[1:], nil
+	case '!':
+		child, rest, err := parseFilter(s[2:], depth+1)
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(rest, ")") {
+			return nil, "", fmt.Errorf("expected ')'")
+		}
+		return newSequence(filterNot, child), rest[1:], nil
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, "", fmt.Errorf("expected ')'")
+	}
+	item := s[1:end]
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return nil, "", fmt.Errorf("expected attribute=value")
+	}
+	attribute, value := item[:eq], item[eq+1:]
+	if strings.ContainsAny(attribute, "<>~:") {
+		return nil, "", fmt.Errorf("unsupported match in %q", item)
+	}
+
+	if value == "*" {
+		return newOctetString(filterPresent, attribute), s[end+1:], nil
+	}
+	if strings.Contains(value, "*") {
+		return nil, "", fmt.Errorf("substring matches are not supported")
+	}
+	unescaped, err := unescapeFilter(value)
+	if err != nil {
+		return nil, "", err
+	}
+	return newSequence(filterEquality,
+		newOctetString(tagOctetString, attribute),
+		newOctetString(tagOctetString, unescaped),
+	), s[end+1:], nil
+}
+
+// unescapeFilter reverses EscapeFilter
+func unescapeFilter(value string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return "", fmt.Errorf("truncated escape in %q", value)
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in %q", value)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
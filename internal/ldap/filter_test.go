@@ -0,0 +1,100 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestEscapeFilter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"jdoe", "jdoe"},
+		{"*", `\2a`},
+		{"a*)(uid=*", `a\2a\29\28uid=\2a`},
+		{`back\slash`, `back\5cslash`},
+		{"nul\x00byte", `nul\00byte`},
+		{"Lučić", "Lučić"},
+	}
+	for _, tt := range tests {
+		escaped := EscapeFilter(tt.value)
+		if escaped != tt.want {
+			t.Errorf("EscapeFilter(%q) = %q, want %q", tt.value, escaped, tt.want)
+		}
+		unescaped, err := unescapeFilter(escaped)
+		if err != nil || unescaped != tt.value {
+			t.Errorf("unescapeFilter(%q) = %q, %v, want %q", escaped, unescaped, err, tt.value)
+		}
+	}
+}
+
+func TestUnescapeFilterRejectsInvalidEscapes(t *testing.T) {
+	for _, value := range []string{`\`, `\2`, `a\zz`, `\g0`} {
+		if _, err := unescapeFilter(value); err == nil {
+			t.Errorf("unescapeFilter(%q) succeeded", value)
+		}
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{"uid=jdoe", "a30b040375696404046a646f65"},
+		{"(objectClass=*)", "870b6f626a656374436c617373"},
+		{"(&(uid=jdoe)(mail=*))", "a013a30b040375696404046a646f6587046d61696c"},
+		{"(|(uid=a)(uid=b))", "a114a3080403756964040161a3080403756964040162"},
+		{"(!(uid=a))", "a20aa3080403756964040161"},
+		{`(cn=a\2ab)`, "a3090402636e0403612a62"},
+	}
+	for _, tt := range tests {
+		compiled, err := compileFilter(tt.filter)
+		if err != nil {
+			t.Errorf("compileFilter(%q): %v", tt.filter, err)
+			continue
+		}
+		if got := hex.EncodeToString(compiled.encode()); got != tt.want {
+			t.Errorf("compileFilter(%q) = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestCompileFilterRejectsInvalidFilters(t *testing.T) {
+	tests := []string{
+		"",
+		"(uid=jdoe",
+		"(uid=jdoe))",
+		"(=jdoe)",
+		"(uid)",
+		"(uid>=5)",
+		"(uid~=jdoe)",
+		"(uid:dn:=jdoe)",
+		"(cn=j*doe)",
+		`(cn=\4)`,
+		"(&(uid=a)",
+		"(!(uid=a)",
+		strings.Repeat("(!", maxFilterDepth+2) + "(uid=a)" + strings.Repeat(")", maxFilterDepth+2),
+	}
+	for _, filter := range tests {
+		if _, err := compileFilter(filter); err == nil {
+			t.Errorf("compileFilter(%q) succeeded", filter)
+		}
+	}
+}
+
+// Values escaped with EscapeFilter match literally, whatever they contain
+func TestCompileFilterWithEscapedValues(t *testing.T) {
+	for _, value := range []string{"*", "a)(uid=*", `x\y`, "(|(a=b))"} {
+		compiled, err := compileFilter("(uid=" + EscapeFilter(value) + ")")
+		if err != nil {
+			t.Errorf("compileFilter(%q): %v", value, err)
+			continue
+		}
+		if compiled.tag != filterEquality || string(compiled.child(1).value) != value {
+			t.Errorf("compileFilter(%q) = tag 0x%02x, value %q", value, compiled.tag, compiled.child(1).value)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+// Package ldap implements the parts of an LDAPv3 client needed to
+// authenticate and list users of a directory such as Active Directory:
+// simple binds, subtree and paged searches and StartTLS.
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Result codes of interest
+const (
+	ResultSuccess            = 0
+	ResultSizeLimitExceeded  = 4
+	ResultInvalidCredentials = 49
+)
+
+// Protocol operations
+const (
+	opBindRequest       = classApplication | constructed | 0
+	opBindResponse      = classApplication | constructed | 1
+	opUnbindRequest     = classApplication | 2
+	opSearchRequest     = classApplication | constructed | 3
+	opSearchEntry       = classApplication | constructed | 4
+	opSearchDone        = classApplication | constructed | 5
+	opSearchReference   = classApplication | constructed | 19
+	opExtendedRequest   = classApplication | constructed | 23
+	opExtendedResponse  = classApplication | constructed | 24
+	messageControls     = classContext | constructed | 0
+	startTLSOID         = "1.3.6.1.4.1.1466.20037"
+	pagedResultsOID     = "1.2.840.113556.1.4.319"
+	searchScopeSubtree  = 2
+	derefAliasesNever   = 0
+	bindAuthSimple      = classContext | 0
+	extendedRequestName = classContext | 0
+)
+
+// ResultError is a non-success result returned by the server
+type ResultError struct {
+	Code    int
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap result code %d: %s", e.Code, e.Message)
+}
+
+// IsInvalidCredentials reports whether err is a bind rejected for its credentials
+func IsInvalidCredentials(err error) bool {
+	var result *ResultError
+	return errors.As(err, &result) && result.Code == ResultInvalidCredentials
+}
+
+// Entry is a search result
+type Entry struct {
+	DN         string
+	Attributes map[string][]string // Keyed by lower-cased attribute name
+}
+
+// Values returns the values of an attribute
+func (e *Entry) Values(name string) []string {
+	return e.Attributes[strings.ToLower(name)]
+}
+
+// Value returns the first value of an attribute
+func (e *Entry) Value(name string) string {
+	if values := e.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Conn is a connection to a directory server. It is not safe for
+// concurrent use.
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	nextID  int64
+}
+
+// Dial connects to an ldaps:// or ldap:// URL. Plain ldap:// connections
+// are upgraded with StartTLS when startTLS is set. timeout applies to the
+// connection and to each operation.
+func Dial(rawURL string, startTLS bool, tlsConfig *tls.Config, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ldap url: %w", err)
+	}
+
+	host := u.Hostname()
+	port := u.Port()
+	config := tlsConfig.Clone()
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch strings.ToLower(u.Scheme) {
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), config)
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+		conn, err = dialer.Dial("tcp", net.JoinHostPort(host, port))
+	default:
+		return nil, fmt.Errorf("unsupported ldap url scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	c := &Conn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+	if strings.EqualFold(u.Scheme, "ldap") && startTLS {
+		if err := c.startTLS(config); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// startTLS upgrades the connection to TLS
+func (c *Conn) startTLS(config *tls.Config) error {
+	response, err := c.request(newSequence(opExtendedRequest,
+		newOctetString(extendedRequestName, startTLSOID),
+	), opExtendedResponse)
+	if err != nil {
+		return fmt.Errorf("failed to start TLS: %w", err)
+	}
+	if err := result(response); err != nil {
+		return fmt.Errorf("failed to start TLS: %w", err)
+	}
+
+	tlsConn := tls.Client(c.conn, config)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("failed to start TLS: %w", err)
+	}
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// Bind authenticates the connection with a simple bind. Empty passwords are
+// refused since servers treat them as unauthenticated binds that succeed.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return &ResultError{Code: ResultInvalidCredentials, Message: "empty password"}
+	}
+
+	response, err := c.request(newSequence(opBindRequest,
+		newInteger(tagInteger, 3),
+		newOctetString(tagOctetString, dn),
+		newOctetString(bindAuthSimple, password),
+	), opBindResponse)
+	if err != nil {
+		return fmt.Errorf("failed to bind: %w", err)
+	}
+	return result(response)
+}
+
+// Search runs a subtree search below baseDN and returns at most sizeLimit
+// entries; 0 leaves the limit to the server
+func (c *Conn) Search(baseDN, filter string, attributes []string, sizeLimit int) ([]*Entry, error) {
+	entries, _, err := c.search(baseDN, filter, attributes, sizeLimit, nil)
+	return entries, err
+}
+
+// SearchPaged runs a subtree search below baseDN, fetching pageSize entries
+// at a time with the paged results control (RFC 2696). Active Directory
+// returns at most 1000 entries to searches without it.
+func (c *Conn) SearchPaged(baseDN, filter string, attributes []string, pageSize int) ([]*Entry, error) {
+	var all []*Entry
+	cookie := ""
+	for {
+		control := newSequence(tagSequence,
+			newOctetString(tagOctetString, pagedResultsOID),
+			newOctetString(tagOctetString, string(newSequence(tagSequence,
+				newInteger(tagInteger, int64(pageSize)),
+				newOctetString(tagOctetString, cookie),
+			).encode())),
+		)
+		entries, controls, err := c.search(baseDN, filter, attributes, 0, newSequence(messageControls, control))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entries...)
+
+		cookie = ""
+		for _, response := range controls.children {
+			if len(response.children) < 2 || string(response.child(0).value) != pagedResultsOID {
+				continue
+			}
+			// The value is the last child; criticality may precede it
+			value, _, err := decodePacket(response.children[len(response.children)-1].value)
+			if err != nil {
+				return nil, fmt.Errorf("malformed paged results control: %w", err)
+			}
+			cookie = string(value.child(1).value)
+		}
+		if cookie == "" {
+			return all, nil
+		}
+	}
+}
+
+// search runs a subtree search and returns the entries and the controls of
+// the final response
+func (c *Conn) search(baseDN, filter string, attributes []string, sizeLimit int, controls *packet) ([]*Entry, *packet, error) {
+	compiled, err := compileFilter(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attrs := newSequence(tagSequence)
+	for _, attribute := range attributes {
+		attrs.children = append(attrs.children, newOctetString(tagOctetString, attribute))
+	}
+
+	id, err := c.send(newSequence(opSearchRequest,
+		newOctetString(tagOctetString, baseDN),
+		newInteger(tagEnumerated, searchScopeSubtree),
+		newInteger(tagEnumerated, derefAliasesNever),
+		newInteger(tagInteger, int64(sizeLimit)),
+		newInteger(tagInteger, int64(c.timeout/time.Second)),
+		newBoolean(false),
+		compiled,
+		attrs,
+	), controls)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	var entries []*Entry
+	for {
+		message, err := c.receive(id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to search: %w", err)
+		}
+		op := message.child(1)
+		switch op.tag {
+		case opSearchEntry:
+			entries = append(entries, parseEntry(op))
+		case opSearchReference:
+			// Referrals to other servers are not followed
+		case opSearchDone:
+			if err := result(op); err != nil {
+				return entries, nil, err
+			}
+			return entries, message.child(2), nil
+		default:
+			return nil, nil, fmt.Errorf("unexpected search response 0x%02x", op.tag)
+		}
+	}
+}
+
+// Close unbinds and closes the connection
+func (c *Conn) Close() error {
+	c.send(&packet{tag: opUnbindRequest}, nil)
+	return c.conn.Close()
+}
+
+// request sends an operation and waits for its single response
+func (c *Conn) request(op *packet, responseTag byte) (*packet, error) {
+	id, err := c.send(op, nil)
+	if err != nil {
+		return nil, err
+	}
+	message, err := c.receive(id)
+	if err != nil {
+		return nil, err
+	}
+	response := message.child(1)
+	if response.tag != responseTag {
+		return nil, fmt.Errorf("unexpected response 0x%02x", response.tag)
+	}
+	return response, nil
+}
+
+// send writes an operation in a new message, with optional controls, and
+// returns the message ID
+func (c *Conn) send(op *packet, controls *packet) (int64, error) {
+	c.nextID++
+	message := newSequence(tagSequence, newInteger(tagInteger, c.nextID), op)
+	if controls != nil {
+		message.children = append(message.children, controls)
+	}
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(message.encode()); err != nil {
+		return 0, err
+	}
+	return c.nextID, nil
+}
+
+// receive reads the next message for id
+func (c *Conn) receive(id int64) (*packet, error) {
+	for {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+		message, err := readPacket(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		if message.tag != tagSequence || len(message.children) < 2 {
+			return nil, errors.New("malformed message")
+		}
+		messageID, err := message.child(0).int()
+		if err != nil {
+			return nil, fmt.Errorf("malformed message id: %w", err)
+		}
+		// Unsolicited notifications carry ID 0, such as a notice of
+		// disconnection; it ends the exchange either way
+		if messageID == 0 {
+			return nil, errors.New("server closed the connection")
+		}
+		if messageID == id {
+			return message, nil
+		}
+	}
+}
+
+// result returns the error of an LDAPResult, nil on success
+func result(op *packet) error {
+	code, err := op.child(0).int()
+	if err != nil {
+		return fmt.Errorf("malformed result: %w", err)
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+	return &ResultError{Code: int(code), Message: string(op.child(2).value)}
+}
+
+// parseEntry converts a SearchResultEntry
+func parseEntry(op *packet) *Entry {
+	entry := &Entry{
+		DN:         string(op.child(0).value),
+		Attributes: make(map[string][]string),
+	}
+	for _, attribute := range op.child(1).children {
+		name := strings.ToLower(string(attribute.child(0).value))
+		for _, value := range attribute.child(1).children {
+			entry.Attributes[name] = append(entry.Attributes[name], string(value.value))
+		}
+	}
+	return entry
+}
@@ -0,0 +1,200 @@
+package ldap
+
+import (
+	"bufio"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeServer answers the requests of a Conn with the responses handle
+// returns for them
+func fakeServer(t *testing.T, handle func(id int64, op *packet) []*packet) *Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	go func() {
+		reader := bufio.NewReader(server)
+		for {
+			request, err := readPacket(reader)
+			if err != nil {
+				return
+			}
+			id, _ := request.child(0).int()
+			for _, response := range handle(id, request) {
+				if _, err := server.Write(response.encode()); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return &Conn{conn: client, reader: bufio.NewReader(client), timeout: 5 * time.Second}
+}
+
+func message(id int64, op *packet, controls ...*packet) *packet {
+	m := newSequence(tagSequence, newInteger(tagInteger, id), op)
+	if len(controls) > 0 {
+		m.children = append(m.children, newSequence(messageControls, controls...))
+	}
+	return m
+}
+
+func ldapResult(tag byte, code int64, diagnostic string) *packet {
+	return newSequence(tag,
+		newInteger(tagEnumerated, code),
+		newOctetString(tagOctetString, ""),
+		newOctetString(tagOctetString, diagnostic),
+	)
+}
+
+func searchEntry(dn string, attributes map[string][]string) *packet {
+	attrs := newSequence(tagSequence)
+	for name, values := range attributes {
+		set := newSequence(tagSet)
+		for _, value := range values {
+			set.children = append(set.children, newOctetString(tagOctetString, value))
+		}
+		attrs.children = append(attrs.children, newSequence(tagSequence, newOctetString(tagOctetString, name), set))
+	}
+	return newSequence(opSearchEntry, newOctetString(tagOctetString, dn), attrs)
+}
+
+func pagedControl(cookie string) *packet {
+	return newSequence(tagSequence,
+		newOctetString(tagOctetString, pagedResultsOID),
+		newOctetString(tagOctetString, string(newSequence(tagSequence,
+			newInteger(tagInteger, 0),
+			newOctetString(tagOctetString, cookie),
+		).encode())),
+	)
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		password string
+		code     int64
+		invalid  bool
+	}{
+		{"secret", ResultSuccess, false},
+		{"wrong", ResultInvalidCredentials, true},
+		{"", ResultSuccess, true}, // Never sent as an unauthenticated bind
+	}
+	for _, tt := range tests {
+		sent := false
+		c := fakeServer(t, func(id int64, op *packet) []*packet {
+			sent = true
+			if op.child(1).tag != opBindRequest || string(op.child(1).child(2).value) != tt.password {
+				t.Errorf("unexpected request %x", op.encode())
+			}
+			return []*packet{message(id, ldapResult(opBindResponse, tt.code, "bind result"))}
+		})
+
+		err := c.Bind("cn=jdoe,dc=example,dc=com", tt.password)
+		if IsInvalidCredentials(err) != tt.invalid {
+			t.Errorf("Bind(%q) = %v", tt.password, err)
+		}
+		if tt.password == "" && sent {
+			t.Error("Bind sent an empty password")
+		}
+	}
+}
+
+func TestSearchPagedFollowsCookies(t *testing.T) {
+	pages := []struct {
+		dn     string
+		cookie string
+	}{
+		{"uid=a,dc=example,dc=com", "page-2"},
+		{"uid=b,dc=example,dc=com", "page-3"},
+		{"uid=c,dc=example,dc=com", ""},
+	}
+	var cookies []string
+	c := fakeServer(t, func(id int64, op *packet) []*packet {
+		control := op.child(2).child(0)
+		value, _, err := decodePacket(control.child(1).value)
+		if err != nil {
+			t.Errorf("malformed paged control: %v", err)
+			return nil
+		}
+		if size, _ := value.child(0).int(); size != 1 {
+			t.Errorf("page size = %d", size)
+		}
+		cookies = append(cookies, string(value.child(1).value))
+
+		page := pages[len(cookies)-1]
+		return []*packet{
+			message(id, searchEntry(page.dn, map[string][]string{"mail": {page.dn}})),
+			// References and notices for other messages are skipped
+			message(id, newSequence(opSearchReference, newOctetString(tagOctetString, "ldap://other/"))),
+			message(id+100, ldapResult(opSearchDone, ResultSuccess, "")),
+			message(id, ldapResult(opSearchDone, ResultSuccess, ""), pagedControl(page.cookie)),
+		}
+	})
+
+	entries, err := c.SearchPaged("dc=example,dc=com", "(objectClass=person)", []string{"mail"}, 1)
+	if err != nil {
+		t.Fatalf("SearchPaged: %v", err)
+	}
+	if len(entries) != 3 || entries[2].DN != pages[2].dn || entries[1].Value("MAIL") != pages[1].dn {
+		t.Errorf("entries = %+v", entries)
+	}
+	if want := []string{"", "page-2", "page-3"}; !slices.Equal(cookies, want) {
+		t.Errorf("cookies = %q, want %q", cookies, want)
+	}
+}
+
+func TestSearchReturnsResultErrors(t *testing.T) {
+	c := fakeServer(t, func(id int64, op *packet) []*packet {
+		return []*packet{
+			message(id, searchEntry("uid=a,dc=example,dc=com", nil)),
+			message(id, ldapResult(opSearchDone, ResultSizeLimitExceeded, "size limit")),
+		}
+	})
+
+	entries, err := c.Search("dc=example,dc=com", "(uid=*)", nil, 1)
+	result, ok := err.(*ResultError)
+	if !ok || result.Code != ResultSizeLimitExceeded || result.Message != "size limit" {
+		t.Errorf("Search = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("entries = %d, want the one received", len(entries))
+	}
+}
+
+func TestSearchRejectsMalformedResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		response func(id int64) *packet
+	}{
+		{"not a sequence", func(id int64) *packet { return newOctetString(tagOctetString, "x") }},
+		{"no operation", func(id int64) *packet { return newSequence(tagSequence, newInteger(tagInteger, id)) }},
+		{"invalid message id", func(id int64) *packet {
+			return newSequence(tagSequence, &packet{tag: tagInteger}, ldapResult(opSearchDone, 0, ""))
+		}},
+		{"notice of disconnection", func(id int64) *packet {
+			return message(0, newSequence(opExtendedResponse, newInteger(tagEnumerated, 52)))
+		}},
+		{"unexpected operation", func(id int64) *packet { return message(id, ldapResult(opBindResponse, 0, "")) }},
+		{"result without code", func(id int64) *packet { return message(id, newSequence(opSearchDone)) }},
+		{"malformed paged control", func(id int64) *packet {
+			return message(id, ldapResult(opSearchDone, 0, ""), newSequence(tagSequence,
+				newOctetString(tagOctetString, pagedResultsOID),
+				newOctetString(tagOctetString, "\x30\x80"),
+			))
+		}},
+	}
+	for _, tt := range tests {
+		c := fakeServer(t, func(id int64, op *packet) []*packet {
+			return []*packet{tt.response(id)}
+		})
+		if _, err := c.SearchPaged("dc=example,dc=com", "(uid=*)", nil, 10); err == nil {
+			t.Errorf("%s: SearchPaged succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package mailer sends plain text emails over SMTP.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer sends emails
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPConfig configures an SMTP server
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Empty sends without authentication
+	Password string
+	From     string // Sender address, optionally with a name: DMS <dms@example.com>
+	Timeout  time.Duration
+}
+
+// SMTPMailer sends emails through an SMTP server. Connections are upgraded
+// with STARTTLS whenever the server offers it, and credentials are only
+// sent over TLS.
+type SMTPMailer struct {
+	config SMTPConfig
+	from   *mail.Address
+}
+
+// NewSMTPMailer creates a new SMTP mailer
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+	return &SMTPMailer{config: config, from: from}, nil
+}
+
+// Send sends a plain text email to one recipient
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	message, err := m.compose(recipient, subject, body)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	conn, err := net.DialTimeout("tcp", addr, m.config.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(m.config.Timeout))
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.config.Host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if m.config.Username != "" {
+		// PlainAuth refuses to send credentials without TLS, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	if err := client.Rcpt(recipient.Address); err != nil {
+		return fmt.Errorf("failed to set recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// compose builds the message with its headers
+func (m *SMTPMailer) compose(to *mail.Address, subject, body string) ([]byte, error) {
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, errors.New("subject must be a single line")
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate message ID: %w", err)
+	}
+	domain := m.from.Address[strings.LastIndex(m.from.Address, "@")+1:]
+
+	var message bytes.Buffer
+	message.WriteString("From: " + m.from.String() + "\r\n")
+	message.WriteString("To: " + to.String() + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("Message-ID: <" + hex.EncodeToString(id) + "@" + domain + ">\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&message)
+	if _, err := w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return message.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamavChunkSize is the size of the chunks streamed to clamd
+const clamavChunkSize = 64 << 10
+
+// ClamAVScanner scans content with a clamd daemon over its INSTREAM command
+type ClamAVScanner struct {
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner creates a scanner for the clamd daemon listening on
+// address, either host:port or the path of a Unix socket
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	return &ClamAVScanner{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// Name returns the scanner name
+func (s *ClamAVScanner) Name() string {
+	return "clamav"
+}
+
+// Scan streams data to clamd and parses its verdict
+func (s *ClamAVScanner) Scan(ctx context.Context, data []byte) (*Result, error) {
+	network := "tcp"
+	if strings.HasPrefix(s.address, "/") {
+		network = "unix"
+	}
+
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send scan command: %w", err)
+	}
+
+	// Each chunk is prefixed with its length; a zero length ends the stream
+	size := make([]byte, 4)
+	for offset := 0; offset < len(data); offset += clamavChunkSize {
+		end := min(offset+clamavChunkSize, len(data))
+		binary.BigEndian.PutUint32(size, uint32(end-offset))
+		if _, err := conn.Write(size); err != nil {
+			return nil, fmt.Errorf("failed to stream content: %w", err)
+		}
+		if _, err := conn.Write(data[offset:end]); err != nil {
+			return nil, fmt.Errorf("failed to stream content: %w", err)
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return nil, fmt.Errorf("failed to stream content: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan result: %w", err)
+	}
+
+	return parseClamAVReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamAVReply parses a reply such as "stream: OK" or
+// "stream: Eicar-Signature FOUND"
+func parseClamAVReply(reply string) (*Result, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+
+	switch {
+	case verdict == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &Result{
+			Infected:  true,
+			Signature: strings.TrimSuffix(verdict, " FOUND"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("clamd scan failed: %s", verdict)
+	}
+}
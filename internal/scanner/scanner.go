@@ -0,0 +1,17 @@
+package scanner
+
+import "context"
+
+// Result is the outcome of scanning content for malware
+type Result struct {
+	Infected  bool
+	Signature string // Name of the detected malware
+}
+
+// Scanner checks file content for malware
+type Scanner interface {
+	// Scan scans data and reports whether it is infected
+	Scan(ctx context.Context, data []byte) (*Result, error)
+	// Name returns the scanner name for logging
+	Name() string
+}
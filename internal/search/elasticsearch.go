@@ -0,0 +1,270 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// maxIndexedContent is the maximum number of content bytes sent to the index
+const maxIndexedContent = 1 << 20
+
+// ElasticsearchClient indexes and searches documents in Elasticsearch or OpenSearch
+type ElasticsearchClient struct {
+	client   *http.Client
+	baseURL  string
+	index    string
+	username string
+	password string
+}
+
+// NewElasticsearchClient creates a new Elasticsearch client
+func NewElasticsearchClient(baseURL, index, username, password string) *ElasticsearchClient {
+	return &ElasticsearchClient{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		index:    index,
+		username: username,
+		password: password,
+	}
+}
+
+// Hit represents a single search hit
+type Hit struct {
+	DocumentID uint
+	Score      float64
+}
+
+// Filter restricts search hits to documents a user may read
+type Filter struct {
+	OrganizationID uint
+	MaxAccessLevel models.AccessLevel
+	CreatedBy      uint
+	SharedIDs      []uint
+	Unrestricted   bool
+	DocumentIDs    []uint // When not nil, only these documents match
+}
+
+// EnsureIndex creates the index with its mapping if it doesn't exist yet
+func (c *ElasticsearchClient) EnsureIndex(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodHead, "/"+c.index, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	mapping := map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"title":           map[string]string{"type": "text"},
+				"description":     map[string]string{"type": "text"},
+				"tags":            map[string]string{"type": "text"},
+				"content":         map[string]string{"type": "text"},
+				"file_name":       map[string]string{"type": "keyword"},
+				"category":        map[string]string{"type": "keyword"},
+				"mime_type":       map[string]string{"type": "keyword"},
+				"access_level":    map[string]string{"type": "integer"},
+				"created_by":      map[string]string{"type": "long"},
+				"organization_id": map[string]string{"type": "long"},
+				"created_at":      map[string]string{"type": "date"},
+				"updated_at":      map[string]string{"type": "date"},
+			},
+		},
+	}
+
+	resp, err = c.do(ctx, http.MethodPut, "/"+c.index, mapping)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp, "create index")
+}
+
+// IndexDocument creates or updates the indexed representation of a document.
+// An empty content leaves previously indexed content untouched.
+func (c *ElasticsearchClient) IndexDocument(ctx context.Context, doc *models.Document, content string) error {
+	fields := map[string]interface{}{
+		"title":           doc.Title,
+		"description":     doc.Description,
+		"tags":            doc.Tags,
+		"file_name":       doc.FileName,
+		"category":        doc.Category,
+		"mime_type":       doc.MimeType,
+		"access_level":    doc.AccessLevel,
+		"created_by":      doc.CreatedBy,
+		"organization_id": doc.OrganizationID,
+		"created_at":      doc.CreatedAt,
+		"updated_at":      doc.UpdatedAt,
+	}
+	if content != "" {
+		if len(content) > maxIndexedContent {
+			content = content[:maxIndexedContent]
+		}
+		fields["content"] = content
+	}
+
+	body := map[string]interface{}{
+		"doc":           fields,
+		"doc_as_upsert": true,
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/"+c.index+"/_update/"+strconv.FormatUint(uint64(doc.ID), 10), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp, "index document")
+}
+
+// DeleteDocument removes a document from the index
+func (c *ElasticsearchClient) DeleteDocument(ctx context.Context, id uint) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/"+c.index+"/_doc/"+strconv.FormatUint(uint64(id), 10), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkResponse(resp, "delete document")
+}
+
+// Search runs a relevance-ranked query over metadata and content
+func (c *ElasticsearchClient) Search(ctx context.Context, query string, filter Filter, from, size int) ([]Hit, int64, error) {
+	boolQuery := map[string]interface{}{
+		"must": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  query,
+				"fields": []string{"title^3", "description^2", "tags^2", "file_name", "content"},
+			},
+		},
+	}
+
+	// Even unrestricted searches stay within the organization
+	filters := []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"organization_id": filter.OrganizationID}},
+	}
+	if !filter.Unrestricted {
+		should := []interface{}{
+			map[string]interface{}{"range": map[string]interface{}{"access_level": map[string]interface{}{"lte": filter.MaxAccessLevel}}},
+			map[string]interface{}{"term": map[string]interface{}{"created_by": filter.CreatedBy}},
+		}
+		if len(filter.SharedIDs) > 0 {
+			should = append(should, map[string]interface{}{"ids": map[string]interface{}{"values": documentIDs(filter.SharedIDs)}})
+		}
+		filters = append(filters, map[string]interface{}{
+			"bool": map[string]interface{}{"should": should, "minimum_should_match": 1},
+		})
+	}
+
+	if filter.DocumentIDs != nil {
+		filters = append(filters, map[string]interface{}{"ids": map[string]interface{}{"values": documentIDs(filter.DocumentIDs)}})
+	}
+	boolQuery["filter"] = filters
+
+	body := map[string]interface{}{
+		"query":   map[string]interface{}{"bool": boolQuery},
+		"from":    from,
+		"size":    size,
+		"_source": false,
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/"+c.index+"/_search", body)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, "search"); err != nil {
+		return nil, 0, err
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				ID    string  `json:"_id"`
+				Score float64 `json:"_score"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	hits := make([]Hit, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		id, err := strconv.ParseUint(hit.ID, 10, 64)
+		if err != nil {
+			continue
+		}
+		hits = append(hits, Hit{DocumentID: uint(id), Score: hit.Score})
+	}
+
+	return hits, result.Hits.Total.Value, nil
+}
+
+// do sends a JSON request to Elasticsearch
+func (c *ElasticsearchClient) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach elasticsearch: %w", err)
+	}
+	return resp, nil
+}
+
+// checkResponse converts non-2xx responses to errors
+func checkResponse(resp *http.Response, operation string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("elasticsearch %s failed with status %d: %s", operation, resp.StatusCode, message)
+}
+
+// documentIDs formats document IDs as index document IDs
+func documentIDs(ids []uint) []string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, strconv.FormatUint(uint64(id), 10))
+	}
+	return values
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxEmbeddingInput is the maximum number of characters sent for embedding
+const maxEmbeddingInput = 8000
+
+// EmbeddingClient computes text embeddings through an OpenAI-compatible API
+type EmbeddingClient struct {
+	client *http.Client
+	url    string
+	apiKey string
+	model  string
+}
+
+// NewEmbeddingClient creates a new embedding client
+func NewEmbeddingClient(url, apiKey, model string) *EmbeddingClient {
+	return &EmbeddingClient{
+		client: &http.Client{Timeout: 30 * time.Second},
+		url:    url,
+		apiKey: apiKey,
+		model:  model,
+	}
+}
+
+// Model returns the embedding model name
+func (c *EmbeddingClient) Model() string {
+	return c.model
+}
+
+// Embed returns the embedding vector for a text
+func (c *EmbeddingClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	if runes := []rune(text); len(runes) > maxEmbeddingInput {
+		text = string(runes[:maxEmbeddingInput])
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"model": c.model,
+		"input": text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach embedding service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embedding service returned status %d: %s", resp.StatusCode, message)
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+
+	if len(result.Data) == 0 || len(result.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("embedding service returned no embedding")
+	}
+
+	return result.Data[0].Embedding, nil
+}
+
+// VectorLiteral formats an embedding as a pgvector literal
+func VectorLiteral(vector []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, value := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(value), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
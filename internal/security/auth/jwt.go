@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,6 +14,22 @@ import (
 	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
 )
 
+// Client types with a special meaning
+const (
+	DefaultClientType = "web"     // Logins that don't name a client type
+	ServiceClientType = "service" // OAuth clients; users can't sign in as one
+)
+
+// Token types, kept apart so a refresh token can't be used as an access
+// token or the other way around
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
+// ErrUnknownClientType is returned for a client type without an audience
+var ErrUnknownClientType = errors.New("unknown client type")
+
 // Claims represents JWT claims
 type Claims struct {
 	UserID     uint   `json:"user_id"`
@@ -16,25 +37,80 @@ type Claims struct {
 	Email      string `json:"email"`
 	Role       string `json:"role"`
 	Department string `json:"department"`
+	ClientType string `json:"client_type,omitempty"` // Selects the audience
+	TokenType  string `json:"token_type,omitempty"`  // AccessTokenType or RefreshTokenType
+	Scope      string `json:"scope,omitempty"`       // Space-separated scopes of service tokens
+	// AuthTime is when the user last entered a password or used a security
+	// key, carried over when tokens are refreshed
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// AuthenticatedAt returns the auth_time claim, or the zero time for tokens
+// without one such as service tokens
+func (c *Claims) AuthenticatedAt() time.Time {
+	if c.AuthTime == nil {
+		return time.Time{}
+	}
+	return c.AuthTime.Time
+}
+
 // TokenService handles JWT token operations
 type TokenService struct {
 	secretKey   []byte
 	tokenExpiry time.Duration
+	issuer      string
+	audiences   map[string]string // Client type to audience
 }
 
-// NewTokenService creates a new token service
-func NewTokenService(cfg *config.Config) *TokenService {
+// NewTokenService creates a new token service. Tokens are issued by
+// cfg.JWTIssuer for the audience of the client type they are issued to.
+func NewTokenService(cfg *config.Config) (*TokenService, error) {
+	if cfg.JWTIssuer == "" {
+		return nil, fmt.Errorf("JWT issuer is required")
+	}
+
+	audiences := make(map[string]string, len(cfg.JWTAudiences))
+	for _, pair := range cfg.JWTAudiences {
+		clientType, audience, ok := strings.Cut(pair, "=")
+		clientType, audience = strings.TrimSpace(clientType), strings.TrimSpace(audience)
+		if !ok || clientType == "" || audience == "" {
+			return nil, fmt.Errorf("invalid JWT audience %q, expected client_type=audience", pair)
+		}
+		audiences[clientType] = audience
+	}
+	if _, ok := audiences[DefaultClientType]; !ok {
+		return nil, fmt.Errorf("JWT audience of the %s client type is required", DefaultClientType)
+	}
+
 	return &TokenService{
 		secretKey:   []byte(cfg.JWTSecret),
 		tokenExpiry: time.Duration(cfg.TokenExpiry) * time.Minute,
-	}
+		issuer:      cfg.JWTIssuer,
+		audiences:   audiences,
+	}, nil
+}
+
+// HasClientType reports whether tokens can be issued to clientType
+func (ts *TokenService) HasClientType(clientType string) bool {
+	_, ok := ts.audiences[clientType]
+	return ok
 }
 
-// GenerateToken generates a new JWT token for a user
-func (ts *TokenService) GenerateToken(user *models.User) (string, error) {
+// GenerateToken generates a new JWT token for a user signed in with a
+// client of clientType who last authenticated at authTime. Each token gets a
+// unique ID so it can be revoked on its own.
+func (ts *TokenService) GenerateToken(user *models.User, clientType string, authTime time.Time) (string, error) {
+	audience, ok := ts.audiences[clientType]
+	if !ok || clientType == ServiceClientType {
+		return "", ErrUnknownClientType
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
 	now := time.Now()
 	claims := &Claims{
 		UserID:     user.ID,
@@ -42,12 +118,17 @@ func (ts *TokenService) GenerateToken(user *models.User) (string, error) {
 		Email:      user.Email,
 		Role:       string(user.Role),
 		Department: user.Department,
+		ClientType: clientType,
+		TokenType:  AccessTokenType,
+		AuthTime:   authTimeClaim(authTime),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(ts.tokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
-			Issuer:    "datamanagement-system",
+			Issuer:    ts.issuer,
+			Audience:  jwt.ClaimStrings{audience},
 			Subject:   fmt.Sprintf("user:%d", user.ID),
+			ID:        hex.EncodeToString(id),
 		},
 	}
 
@@ -55,17 +136,27 @@ func (ts *TokenService) GenerateToken(user *models.User) (string, error) {
 	return token.SignedString(ts.secretKey)
 }
 
-// GenerateRefreshToken generates a refresh token
-func (ts *TokenService) GenerateRefreshToken(user *models.User, expiry time.Duration) (string, error) {
+// GenerateRefreshToken generates a refresh token for a client of clientType.
+// The access tokens it refreshes keep authTime.
+func (ts *TokenService) GenerateRefreshToken(user *models.User, clientType string, authTime time.Time, expiry time.Duration) (string, error) {
+	audience, ok := ts.audiences[clientType]
+	if !ok || clientType == ServiceClientType {
+		return "", ErrUnknownClientType
+	}
+
 	now := time.Now()
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
+		UserID:     user.ID,
+		Username:   user.Username,
+		ClientType: clientType,
+		TokenType:  RefreshTokenType,
+		AuthTime:   authTimeClaim(authTime),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
-			Issuer:    "datamanagement-system",
+			Issuer:    ts.issuer,
+			Audience:  jwt.ClaimStrings{audience},
 			Subject:   fmt.Sprintf("refresh:%d", user.ID),
 		},
 	}
@@ -74,24 +165,110 @@ func (ts *TokenService) GenerateRefreshToken(user *models.User, expiry time.Dura
 	return token.SignedString(ts.secretKey)
 }
 
-// ValidateToken validates and parses a JWT token
+// GenerateServiceToken generates a token of the OAuth client clientID,
+// acting as its service account user, limited to scopes
+func (ts *TokenService) GenerateServiceToken(user *models.User, clientID string, scopes []string, expiry time.Duration) (string, error) {
+	audience, ok := ts.audiences[ServiceClientType]
+	if !ok {
+		return "", ErrUnknownClientType
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID:     user.ID,
+		Username:   user.Username,
+		Role:       string(user.Role),
+		Department: user.Department,
+		ClientType: ServiceClientType,
+		TokenType:  AccessTokenType,
+		Scope:      strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    ts.issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			Subject:   "client:" + clientID,
+			ID:        hex.EncodeToString(id),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(ts.secretKey)
+}
+
+// authTimeClaim returns the auth_time claim of authTime, leaving it out
+// when the time is unknown
+func authTimeClaim(authTime time.Time) *jwt.NumericDate {
+	if authTime.IsZero() {
+		return nil
+	}
+	return jwt.NewNumericDate(authTime)
+}
+
+// ValidateToken validates and parses an access token. The token must come
+// from the configured issuer, be meant for the audience of its client type
+// and carry an ID it can be revoked by; refresh tokens are refused.
 func (ts *TokenService) ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := ts.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != AccessTokenType || strings.HasPrefix(claims.Subject, "refresh:") {
+		return nil, fmt.Errorf("invalid token type")
+	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("token ID is required")
+	}
+
+	return claims, nil
+}
+
+// ValidateRefreshToken validates and parses a refresh token, refusing
+// access tokens
+func (ts *TokenService) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	claims, err := ts.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(claims.Subject, "refresh:") {
+		return nil, fmt.Errorf("invalid token type")
+	}
+
+	return claims, nil
+}
+
+// parseToken checks the signature, issuer and audience of a token
+func (ts *TokenService) parseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return ts.secretKey, nil
-	})
+	}, jwt.WithIssuer(ts.issuer))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	audience, ok := ts.audiences[claims.ClientType]
+	if !ok || !slices.Contains(claims.Audience, audience) {
+		return nil, fmt.Errorf("invalid token audience")
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return claims, nil
 }
 
 // ExtractClaims extracts claims from a token without validation (for expired tokens)
@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -150,3 +151,293 @@ func GenerateRandomString(n int) (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
+
+// DataKeySize is the size in bytes of per-document data keys (AES-256)
+const DataKeySize = 32
+
+// RootKeyVersion is the key version of the root master key itself. Data keys
+// wrapped before any rotation took place use this version.
+const RootKeyVersion = 0
+
+// EnvelopeService implements envelope encryption: every payload is encrypted
+// with its own random data key, and only the data key is encrypted (wrapped)
+// with the master key. Rotating the master key therefore only requires
+// re-wrapping data keys instead of re-encrypting every payload.
+//
+// The service keeps a keyring of versioned key-encryption keys. Version 0 is
+// the root key supplied by the key provider; rotated keys are random keys
+// wrapped by the root key and loaded into the keyring at startup. After the
+// root key changes, the previous one still unwraps stored keys until they are
+// re-wrapped under the new root.
+type EnvelopeService struct {
+	mu            sync.RWMutex
+	root          *EncryptionService
+	previousRoots []*EncryptionService
+	keys          map[int]*EncryptionService
+	current       int
+}
+
+// NewEnvelopeService creates a new envelope service using the given master key
+func NewEnvelopeService(masterKey string) *EnvelopeService {
+	root := NewEncryptionService(masterKey)
+	return &EnvelopeService{
+		root:    root,
+		keys:    map[int]*EncryptionService{RootKeyVersion: root},
+		current: RootKeyVersion,
+	}
+}
+
+// SetPreviousRootKeys sets the root keys in use before the current one. Keys
+// wrapped by them still unwrap, and RewrapKEK moves them to the current root.
+func (es *EnvelopeService) SetPreviousRootKeys(masterKeys ...string) {
+	roots := make([]*EncryptionService, 0, len(masterKeys))
+	for _, masterKey := range masterKeys {
+		roots = append(roots, NewEncryptionService(masterKey))
+	}
+
+	es.mu.Lock()
+	es.previousRoots = roots
+	es.mu.Unlock()
+}
+
+// unwrapKEK unwraps a stored key-encryption key with the root key, or with a
+// previous root key in which case previous is true
+func (es *EnvelopeService) unwrapKEK(wrappedKey string) (material string, previous bool, err error) {
+	material, err = es.root.DecryptString(wrappedKey)
+	if err == nil {
+		return material, false, nil
+	}
+
+	es.mu.RLock()
+	roots := es.previousRoots
+	es.mu.RUnlock()
+
+	for _, root := range roots {
+		if material, rootErr := root.DecryptString(wrappedKey); rootErr == nil {
+			return material, true, nil
+		}
+	}
+	return "", false, err
+}
+
+// CreateKey generates a new key-encryption key for the given version, adds it
+// to the keyring and returns it wrapped by the root key for storage
+func (es *EnvelopeService) CreateKey(version int) (string, error) {
+	material, err := GenerateRandomString(DataKeySize)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	wrapped, err := es.root.EncryptString(material)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap key: %w", err)
+	}
+
+	es.mu.Lock()
+	es.keys[version] = NewEncryptionService(material)
+	es.mu.Unlock()
+
+	return wrapped, nil
+}
+
+// LoadKey unwraps a stored key-encryption key and adds it to the keyring
+func (es *EnvelopeService) LoadKey(version int, wrappedKey string) error {
+	material, _, err := es.unwrapKEK(wrappedKey)
+	if err != nil {
+		return fmt.Errorf("failed to unwrap key version %d: %w", version, err)
+	}
+
+	es.mu.Lock()
+	es.keys[version] = NewEncryptionService(material)
+	es.mu.Unlock()
+
+	return nil
+}
+
+// RewrapKEK re-wraps a stored key-encryption key wrapped by a previous root
+// key under the current root. It reports whether the key changed; keys
+// already wrapped by the current root are returned as they are.
+func (es *EnvelopeService) RewrapKEK(wrappedKey string) (string, bool, error) {
+	material, previous, err := es.unwrapKEK(wrappedKey)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to unwrap key: %w", err)
+	}
+	if !previous {
+		return wrappedKey, false, nil
+	}
+
+	rewrapped, err := es.root.EncryptString(material)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to wrap key: %w", err)
+	}
+	return rewrapped, true, nil
+}
+
+// UnloadKey removes a retired key version from the keyring once no data key
+// is wrapped by it anymore. Neither the root key nor the current version can
+// be unloaded.
+func (es *EnvelopeService) UnloadKey(version int) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if version == RootKeyVersion || version == es.current {
+		return fmt.Errorf("key version %d is in use", version)
+	}
+	delete(es.keys, version)
+	return nil
+}
+
+// SetCurrentVersion selects the key version used to wrap new data keys
+func (es *EnvelopeService) SetCurrentVersion(version int) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if _, ok := es.keys[version]; !ok {
+		return fmt.Errorf("unknown key version %d", version)
+	}
+	es.current = version
+	return nil
+}
+
+// CurrentVersion returns the key version used to wrap new data keys
+func (es *EnvelopeService) CurrentVersion() int {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.current
+}
+
+// GenerateDataKey generates a new random data key
+func (es *EnvelopeService) GenerateDataKey() ([]byte, error) {
+	return GenerateRandomBytes(DataKeySize)
+}
+
+// WrapKey encrypts a data key with the current master key and returns the
+// wrapped key together with the key version used
+func (es *EnvelopeService) WrapKey(dataKey []byte) (string, int, error) {
+	es.mu.RLock()
+	version := es.current
+	master := es.keys[version]
+	es.mu.RUnlock()
+
+	wrapped, err := master.Encrypt(dataKey)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return wrapped, version, nil
+}
+
+// UnwrapKey decrypts a data key with the master key of the given version
+func (es *EnvelopeService) UnwrapKey(wrappedKey string, version int) ([]byte, error) {
+	es.mu.RLock()
+	master, ok := es.keys[version]
+	roots := es.previousRoots
+	es.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key version %d", version)
+	}
+
+	dataKey, err := master.Decrypt(wrappedKey)
+	if err != nil && version == RootKeyVersion {
+		// Data keys wrapped by the root key before it changed
+		for _, root := range roots {
+			if key, rootErr := root.Decrypt(wrappedKey); rootErr == nil {
+				dataKey, err = key, nil
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	if len(dataKey) != DataKeySize {
+		return nil, fmt.Errorf("invalid data key size")
+	}
+	return dataKey, nil
+}
+
+// RewrapKey re-wraps a data key with the current master key
+func (es *EnvelopeService) RewrapKey(wrappedKey string, version int) (string, int, error) {
+	dataKey, err := es.UnwrapKey(wrappedKey, version)
+	if err != nil {
+		return "", 0, err
+	}
+	return es.WrapKey(dataKey)
+}
+
+// Seal encrypts data with a freshly generated data key and returns the
+// ciphertext together with the wrapped data key and its key version
+func (es *EnvelopeService) Seal(data []byte) ([]byte, string, int, error) {
+	dataKey, err := es.GenerateDataKey()
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	ciphertext, err := encryptWithKey(dataKey, data)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	wrappedKey, version, err := es.WrapKey(dataKey)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	return ciphertext, wrappedKey, version, nil
+}
+
+// Open decrypts data sealed by Seal using its wrapped data key
+func (es *EnvelopeService) Open(ciphertext []byte, wrappedKey string, version int) ([]byte, error) {
+	dataKey, err := es.UnwrapKey(wrappedKey, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptWithKey(dataKey, ciphertext)
+}
+
+// encryptWithKey encrypts data using AES-GCM and returns nonce||ciphertext
+func encryptWithKey(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decryptWithKey decrypts nonce||ciphertext produced by encryptWithKey
+func decryptWithKey(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRewrapKEKMovesKeysToTheNewRoot(t *testing.T) {
+	old := NewEnvelopeService("old root key")
+	wrappedKEK, err := old.CreateKey(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.SetCurrentVersion(1); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, wrappedKey, version, err := old.Seal([]byte("document"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	es := NewEnvelopeService("new root key")
+	if err := es.LoadKey(1, wrappedKEK); err == nil {
+		t.Fatal("LoadKey unwrapped a key of another root without the previous root")
+	}
+
+	es.SetPreviousRootKeys("old root key")
+	rewrapped, changed, err := es.RewrapKEK(wrappedKEK)
+	if err != nil || !changed {
+		t.Fatalf("RewrapKEK = %v, %v", changed, err)
+	}
+	if _, changed, err := es.RewrapKEK(rewrapped); err != nil || changed {
+		t.Errorf("RewrapKEK(current root) = %v, %v", changed, err)
+	}
+
+	// The re-wrapped key loads without the previous root
+	es.SetPreviousRootKeys()
+	if err := es.LoadKey(1, rewrapped); err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	plaintext, err := es.Open(ciphertext, wrappedKey, version)
+	if err != nil || !bytes.Equal(plaintext, []byte("document")) {
+		t.Errorf("Open = %q, %v", plaintext, err)
+	}
+}
+
+func TestUnwrapKeyOfTheRootVersionFallsBackToPreviousRoots(t *testing.T) {
+	old := NewEnvelopeService("old root key")
+	ciphertext, wrappedKey, version, err := old.Seal([]byte("document"))
+	if err != nil || version != RootKeyVersion {
+		t.Fatalf("Seal = %d, %v", version, err)
+	}
+
+	es := NewEnvelopeService("new root key")
+	if _, err := es.Open(ciphertext, wrappedKey, version); err == nil {
+		t.Fatal("Open succeeded without the previous root")
+	}
+	es.SetPreviousRootKeys("old root key")
+	if _, err := es.Open(ciphertext, wrappedKey, version); err != nil {
+		t.Errorf("Open: %v", err)
+	}
+}
+
+func TestUnloadKey(t *testing.T) {
+	es := NewEnvelopeService("root key")
+	for version := 1; version <= 2; version++ {
+		if _, err := es.CreateKey(version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := es.SetCurrentVersion(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := es.UnloadKey(RootKeyVersion); err == nil {
+		t.Error("UnloadKey unloaded the root key")
+	}
+	if err := es.UnloadKey(2); err == nil {
+		t.Error("UnloadKey unloaded the current version")
+	}
+	if err := es.UnloadKey(1); err != nil {
+		t.Fatalf("UnloadKey(1): %v", err)
+	}
+	if err := es.SetCurrentVersion(1); err == nil {
+		t.Error("version 1 is still in the keyring")
+	}
+}
@@ -0,0 +1,157 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AWSKMSKeyProvider unwraps the master key with the AWS KMS Decrypt API.
+// Only the KMS ciphertext blob is kept in the configuration; the plaintext
+// key exists solely in memory.
+type AWSKMSKeyProvider struct {
+	client          *http.Client
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	ciphertextBlob  string
+}
+
+// NewAWSKMSKeyProvider creates a new AWS KMS key provider
+func NewAWSKMSKeyProvider(client *http.Client, region, accessKeyID, secretAccessKey, sessionToken, ciphertextBlob string) *AWSKMSKeyProvider {
+	return &AWSKMSKeyProvider{
+		client:          client,
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		sessionToken:    sessionToken,
+		ciphertextBlob:  ciphertextBlob,
+	}
+}
+
+// kmsDecryptResponse represents the KMS Decrypt response
+type kmsDecryptResponse struct {
+	KeyId     string `json:"KeyId"`
+	Plaintext string `json:"Plaintext"`
+}
+
+// MasterKey decrypts the configured ciphertext blob with KMS
+func (p *AWSKMSKeyProvider) MasterKey(ctx context.Context) (string, error) {
+	if p.ciphertextBlob == "" {
+		return "", fmt.Errorf("kms encrypted key is not configured")
+	}
+	if p.accessKeyID == "" || p.secretAccessKey == "" {
+		return "", fmt.Errorf("aws credentials are not configured")
+	}
+
+	body, err := json.Marshal(map[string]string{"CiphertextBlob": p.ciphertextBlob})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal kms request: %w", err)
+	}
+
+	host := fmt.Sprintf("kms.%s.amazonaws.com", p.region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create kms request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+
+	p.signRequest(req, host, body, time.Now().UTC())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach kms: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("kms returned status %d: %s", resp.StatusCode, message)
+	}
+
+	var result kmsDecryptResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode kms response: %w", err)
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(result.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode kms plaintext: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// Name returns the provider name
+func (p *AWSKMSKeyProvider) Name() string {
+	return "awskms"
+}
+
+// signRequest signs the request with AWS Signature Version 4
+func (p *AWSKMSKeyProvider) signRequest(req *http.Request, host string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.sessionToken)
+	}
+
+	// Canonical headers must be sorted by name
+	headers := [][2]string{
+		{"content-type", req.Header.Get("Content-Type")},
+		{"host", host},
+		{"x-amz-date", amzDate},
+	}
+	if p.sessionToken != "" {
+		headers = append(headers, [2]string{"x-amz-security-token", p.sessionToken})
+	}
+	headers = append(headers, [2]string{"x-amz-target", req.Header.Get("X-Amz-Target")})
+
+	var canonicalHeaders string
+	names := make([]string, 0, len(headers))
+	for _, header := range headers {
+		canonicalHeaders += header[0] + ":" + header[1] + "\n"
+		names = append(names, header[0])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := "POST\n/\n\n" + canonicalHeaders + "\n" + signedHeaders + "\n" + payloadHash
+
+	credentialScope := dateStamp + "/" + p.region + "/kms/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + credentialScope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+p.secretAccessKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, p.region)
+	signingKey = hmacSHA256(signingKey, "kms")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKeyID, credentialScope, signedHeaders, signature,
+	))
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,52 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/config"
+)
+
+// KeyProvider supplies the master encryption key
+type KeyProvider interface {
+	// MasterKey fetches or unwraps the master key
+	MasterKey(ctx context.Context) (string, error)
+	// Name returns the provider name for logging
+	Name() string
+}
+
+// NewKeyProvider creates the key provider selected in the configuration
+func NewKeyProvider(cfg *config.Config) (KeyProvider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	switch cfg.KeyProvider {
+	case "", "env":
+		return &EnvKeyProvider{key: cfg.EncryptionKey}, nil
+	case "vault":
+		return NewVaultKeyProvider(client, cfg.VaultAddr, cfg.VaultToken, cfg.VaultKeyPath, cfg.VaultKeyField), nil
+	case "awskms":
+		return NewAWSKMSKeyProvider(client, cfg.AWSRegion, cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey, cfg.AWSSessionToken, cfg.KMSEncryptedKey), nil
+	default:
+		return nil, fmt.Errorf("unknown key provider: %s", cfg.KeyProvider)
+	}
+}
+
+// EnvKeyProvider returns the master key from the environment configuration
+type EnvKeyProvider struct {
+	key string
+}
+
+// MasterKey returns the configured key
+func (p *EnvKeyProvider) MasterKey(ctx context.Context) (string, error) {
+	if p.key == "" {
+		return "", fmt.Errorf("encryption key is not configured")
+	}
+	return p.key, nil
+}
+
+// Name returns the provider name
+func (p *EnvKeyProvider) Name() string {
+	return "env"
+}
@@ -0,0 +1,76 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// VaultKeyProvider reads the master key from a HashiCorp Vault KV v2 secret
+type VaultKeyProvider struct {
+	client *http.Client
+	addr   string
+	token  string
+	path   string
+	field  string
+}
+
+// NewVaultKeyProvider creates a new Vault key provider
+func NewVaultKeyProvider(client *http.Client, addr, token, path, field string) *VaultKeyProvider {
+	return &VaultKeyProvider{
+		client: client,
+		addr:   strings.TrimRight(addr, "/"),
+		token:  token,
+		path:   strings.Trim(path, "/"),
+		field:  field,
+	}
+}
+
+// vaultSecretResponse represents a KV v2 read response
+type vaultSecretResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+}
+
+// MasterKey reads the master key from Vault
+func (p *VaultKeyProvider) MasterKey(ctx context.Context) (string, error) {
+	if p.token == "" {
+		return "", fmt.Errorf("vault token is not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+"/v1/"+p.path, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+
+	var secret vaultSecretResponse
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %w", err)
+	}
+
+	key, ok := secret.Data.Data[p.field].(string)
+	if !ok || key == "" {
+		return "", fmt.Errorf("vault secret has no field %q", p.field)
+	}
+
+	return key, nil
+}
+
+// Name returns the provider name
+func (p *VaultKeyProvider) Name() string {
+	return "vault"
+}
@@ -0,0 +1,189 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cborMap is a CBOR map for encodeCBOR, keeping its keys in order
+type cborMap [][2]interface{}
+
+// encodeCBOR encodes the values WebAuthn uses, for building test input
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		out := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case cborMap:
+		out := cborHead(5, uint64(len(v)))
+		for _, pair := range v {
+			out = append(out, encodeCBOR(pair[0])...)
+			out = append(out, encodeCBOR(pair[1])...)
+		}
+		return out
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	}
+	panic("unsupported CBOR test value")
+}
+
+// cborHead encodes the initial bytes of a CBOR item
+func cborHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// The examples of RFC 8949 appendix A within what WebAuthn uses
+func TestDecodeCBORExamples(t *testing.T) {
+	tests := []struct {
+		encoded string
+		want    interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1864", int64(100)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"29", int64(-10)},
+		{"3863", int64(-100)},
+		{"3903e7", int64(-1000)},
+		{"40", []byte{}},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6161", "a"},
+		{"6449455446", "IETF"},
+		{"62c3bc", "ü"},
+		{"80", []interface{}{}},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"a0", map[interface{}]interface{}{}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"c11a514b67b0", int64(1363896240)},
+	}
+	for _, tt := range tests {
+		got, rest, err := decodeCBOR(mustHex(t, tt.encoded))
+		if err != nil {
+			t.Errorf("decodeCBOR(%s): %v", tt.encoded, err)
+			continue
+		}
+		if len(rest) != 0 {
+			t.Errorf("decodeCBOR(%s) left %x", tt.encoded, rest)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("decodeCBOR(%s) = %#v, want %#v", tt.encoded, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeCBORReturnsRest(t *testing.T) {
+	got, rest, err := decodeCBOR(mustHex(t, "0102"))
+	if err != nil || got != int64(1) || !bytes.Equal(rest, []byte{2}) {
+		t.Errorf("decodeCBOR = %v, %x, %v", got, rest, err)
+	}
+}
+
+func TestDecodeCBORRejectsMalformedInput(t *testing.T) {
+	deep := strings.Repeat("81", maxCBORDepth+2) + "00"
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"truncated length", "19ff"},
+		{"truncated byte string", "4401"},
+		{"byte string length overflow", "5bffffffffffffffff00"},
+		{"text string length overflow", "7bffffffffffffffff"},
+		{"array length overflow", "9bffffffffffffffff"},
+		{"map length overflow", "bbffffffffffffffff"},
+		{"array longer than the data", "830102"},
+		{"map missing a value", "a101"},
+		{"integer overflow", "1bffffffffffffffff"},
+		{"negative integer overflow", "3bffffffffffffffff"},
+		{"indefinite-length byte string", "5f42010243030405ff"},
+		{"indefinite-length array", "9f01ff"},
+		{"reserved additional information", "1c"},
+		{"float", "f93c00"},
+		{"unsupported simple value", "f8ff"},
+		{"array map key", "a18001"},
+		{"deep nesting", deep},
+		{"tag without content", "c1"},
+	}
+	for _, tt := range tests {
+		if _, _, err := decodeCBOR(mustHex(t, tt.encoded)); err == nil {
+			t.Errorf("%s: decodeCBOR(%s) succeeded", tt.name, tt.encoded)
+		}
+	}
+}
+
+func TestDecodeCBORAcceptsNestingUpToTheLimit(t *testing.T) {
+	encoded := strings.Repeat("81", maxCBORDepth) + "00"
+	if _, _, err := decodeCBOR(mustHex(t, encoded)); err != nil {
+		t.Errorf("decodeCBOR(%d levels): %v", maxCBORDepth, err)
+	}
+}
+
+func TestEncodeCBORRoundTrip(t *testing.T) {
+	value := cborMap{
+		{1, 2},
+		{-1, []byte{0xaa}},
+		{"fmt", "none"},
+		{"list", []interface{}{1000000, -1000, true}},
+	}
+	decoded, rest, err := decodeCBOR(encodeCBOR(value))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decodeCBOR = %v, %x", err, rest)
+	}
+	want := map[interface{}]interface{}{
+		int64(1):  int64(2),
+		int64(-1): []byte{0xaa},
+		"fmt":     "none",
+		"list":    []interface{}{int64(1000000), int64(-1000), true},
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("round trip = %#v, want %#v", decoded, want)
+	}
+}
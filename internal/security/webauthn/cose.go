@@ -0,0 +1,125 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms accepted for credentials, in order of preference
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms are the COSE algorithms offered to authenticators
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1 // EC2 and OKP
+	coseX         = -2
+	coseY         = -3
+	coseModulus   = -1 // RSA
+	coseExponent  = -2
+
+	coseKeyTypeOKP   = 1
+	coseKeyTypeEC2   = 2
+	coseKeyTypeRSA   = 3
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// publicKey is a credential public key decoded from its COSE form
+type publicKey struct {
+	algorithm int
+	key       crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE_Key
+func parsePublicKey(data []byte) (*publicKey, []byte, error) {
+	decoded, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid credential public key: %w", err)
+	}
+	params, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil, errors.New("invalid credential public key")
+	}
+
+	keyType, _ := params[int64(coseKeyType)].(int64)
+	algorithm, _ := params[int64(coseAlgorithm)].(int64)
+
+	switch {
+	case keyType == coseKeyTypeEC2 && algorithm == AlgES256:
+		curve, _ := params[int64(coseCurve)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		y, _ := params[int64(coseY)].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, nil, errors.New("invalid ES256 public key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, nil, errors.New("ES256 public key is not on the curve")
+		}
+		return &publicKey{algorithm: AlgES256, key: key}, rest, nil
+	case keyType == coseKeyTypeOKP && algorithm == AlgEdDSA:
+		curve, _ := params[int64(coseCurve)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("invalid EdDSA public key")
+		}
+		return &publicKey{algorithm: AlgEdDSA, key: ed25519.PublicKey(x)}, rest, nil
+	case keyType == coseKeyTypeRSA && algorithm == AlgRS256:
+		n, _ := params[int64(coseModulus)].([]byte)
+		e, _ := params[int64(coseExponent)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, nil, errors.New("invalid RS256 public key")
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		return &publicKey{algorithm: AlgRS256, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, rest, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported credential key type %d with algorithm %d", keyType, algorithm)
+}
+
+// verify checks a signature over data
+func (k *publicKey) verify(data, signature []byte) error {
+	return verifySignature(k.algorithm, k.key, data, signature)
+}
+
+// verifySignature checks a signature of a COSE algorithm over data
+func verifySignature(algorithm int, key crypto.PublicKey, data, signature []byte) error {
+	digest := sha256.Sum256(data)
+	switch algorithm {
+	case AlgES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if ok && ecdsa.VerifyASN1(ecKey, digest[:], signature) {
+			return nil
+		}
+	case AlgEdDSA:
+		edKey, ok := key.(ed25519.PublicKey)
+		if ok && ed25519.Verify(edKey, data, signature) {
+			return nil
+		}
+	case AlgRS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if ok && rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported signature algorithm %d", algorithm)
+	}
+	return ErrInvalidSignature
+}
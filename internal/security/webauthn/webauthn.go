@@ -0,0 +1,329 @@
+// Package webauthn verifies the registration and authentication ceremonies
+// of WebAuthn (FIDO2) security keys and passkeys.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Flags of the authenticator data
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature doesn't verify
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrSignCount is returned when an authenticator's signature counter
+	// went backwards, a sign of a cloned authenticator
+	ErrSignCount = errors.New("signature counter did not increase")
+)
+
+// RelyingParty identifies this server to authenticators
+type RelyingParty struct {
+	ID      string   // Domain of the web application, such as dms.example.com
+	Name    string   // Shown by authenticators
+	Origins []string // Origins of the web application, such as https://dms.example.com
+}
+
+// Credential is a verified newly registered credential
+type Credential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key
+	SignCount uint32
+	AAGUID    []byte // Model of the authenticator, zeros when not attested
+	Format    string // Attestation statement format
+	Verified  bool   // The user was verified, such as with a PIN or biometrics
+}
+
+// Assertion is a verified authentication
+type Assertion struct {
+	SignCount uint32
+	Verified  bool
+}
+
+// NewChallenge returns a random challenge
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// DecodeBase64 decodes the base64url values of WebAuthn responses, with or
+// without padding
+func DecodeBase64(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// EncodeBase64 encodes a value as unpadded base64url
+func EncodeBase64(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// clientData is the client data collected by the browser
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// ClientChallenge returns the challenge of client data so the ceremony it
+// answers can be looked up
+func ClientChallenge(clientDataJSON []byte) ([]byte, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return nil, fmt.Errorf("invalid client data: %w", err)
+	}
+	return DecodeBase64(data.Challenge)
+}
+
+// checkClientData verifies the type, challenge and origin of client data
+func (rp *RelyingParty) checkClientData(clientDataJSON []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return fmt.Errorf("invalid client data: %w", err)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("client data is for %q, not %q", data.Type, ceremony)
+	}
+	received, err := DecodeBase64(data.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return errors.New("challenge does not match")
+	}
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", data.Origin)
+}
+
+// authenticatorData is the data signed by the authenticator
+type authenticatorData struct {
+	raw          []byte
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// parseAuthenticatorData decodes authenticator data
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+	data := &authenticatorData{
+		raw:       raw,
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	if data.flags&flagAttested == 0 {
+		return data, nil
+	}
+
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data is too short")
+	}
+	data.aaguid = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength > 1023 || len(rest) < idLength {
+		return nil, errors.New("invalid credential ID")
+	}
+	data.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	_, after, err := parsePublicKey(rest)
+	if err != nil {
+		return nil, err
+	}
+	data.publicKey = rest[:len(rest)-len(after)]
+	if len(after) > 0 && data.flags&flagExtensions == 0 {
+		return nil, errors.New("unexpected data after the credential public key")
+	}
+	return data, nil
+}
+
+// check verifies the relying party and user flags of authenticator data
+func (rp *RelyingParty) check(data *authenticatorData, requireVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data.rpIDHash, rpIDHash[:]) {
+		return errors.New("authenticator data is for another relying party")
+	}
+	if data.flags&flagUserPresent == 0 {
+		return errors.New("user was not present")
+	}
+	if requireVerification && data.flags&flagUserVerified == 0 {
+		return errors.New("user was not verified")
+	}
+	return nil
+}
+
+// VerifyRegistration verifies the response to a credential creation and
+// returns the new credential. Attestation statements are checked for
+// consistency but not against trusted roots.
+func (rp *RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte, requireVerification bool) (*Credential, error) {
+	if err := rp.checkClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	object, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid attestation object")
+	}
+	format, _ := object["fmt"].(string)
+	statement, _ := object["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := object["authData"].([]byte)
+
+	data, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.check(data, requireVerification); err != nil {
+		return nil, err
+	}
+	if data.flags&flagAttested == 0 {
+		return nil, errors.New("no credential was attested")
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := verifyAttestation(format, statement, data, clientDataHash[:]); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        data.credentialID,
+		PublicKey: data.publicKey,
+		SignCount: data.signCount,
+		AAGUID:    data.aaguid,
+		Format:    format,
+		Verified:  data.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// verifyAttestation checks an attestation statement
+func verifyAttestation(format string, statement map[interface{}]interface{}, data *authenticatorData, clientDataHash []byte) error {
+	signed := append(append([]byte{}, data.raw...), clientDataHash...)
+
+	switch format {
+	case "none":
+		return nil
+	case "packed":
+		algorithm, _ := statement["alg"].(int64)
+		signature, _ := statement["sig"].([]byte)
+		certificates, _ := statement["x5c"].([]interface{})
+		if len(certificates) == 0 {
+			// Self attestation is signed with the credential itself
+			key, _, err := parsePublicKey(data.publicKey)
+			if err != nil {
+				return err
+			}
+			if int(algorithm) != key.algorithm {
+				return errors.New("self attestation algorithm does not match the credential")
+			}
+			return key.verify(signed, signature)
+		}
+		certificate, err := attestationCertificate(certificates)
+		if err != nil {
+			return err
+		}
+		return verifySignature(int(algorithm), certificate.PublicKey, signed, signature)
+	case "fido-u2f":
+		signature, _ := statement["sig"].([]byte)
+		certificates, _ := statement["x5c"].([]interface{})
+		certificate, err := attestationCertificate(certificates)
+		if err != nil {
+			return err
+		}
+		key, _, err := parsePublicKey(data.publicKey)
+		if err != nil {
+			return err
+		}
+		ecKey, ok := key.key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("fido-u2f credentials must be ES256 keys")
+		}
+		// U2F signs the registration in its own format
+		u2fSigned := []byte{0x00}
+		u2fSigned = append(u2fSigned, data.rpIDHash...)
+		u2fSigned = append(u2fSigned, clientDataHash...)
+		u2fSigned = append(u2fSigned, data.credentialID...)
+		u2fSigned = append(u2fSigned, elliptic.Marshal(elliptic.P256(), ecKey.X, ecKey.Y)...)
+		return verifySignature(AlgES256, certificate.PublicKey, u2fSigned, signature)
+	}
+	return fmt.Errorf("unsupported attestation format %q", format)
+}
+
+// attestationCertificate parses the leaf of an attestation certificate chain
+func attestationCertificate(certificates []interface{}) (*x509.Certificate, error) {
+	if len(certificates) == 0 {
+		return nil, errors.New("attestation has no certificate")
+	}
+	der, ok := certificates[0].([]byte)
+	if !ok {
+		return nil, errors.New("invalid attestation certificate")
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation certificate: %w", err)
+	}
+	return certificate, nil
+}
+
+// VerifyAssertion verifies the response to an authentication with a
+// registered credential. signCount is the counter stored with the
+// credential.
+func (rp *RelyingParty) VerifyAssertion(challenge, credentialPublicKey []byte, signCount uint32, clientDataJSON, rawAuthData, signature []byte, requireVerification bool) (*Assertion, error) {
+	if err := rp.checkClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return nil, err
+	}
+
+	data, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.check(data, requireVerification); err != nil {
+		return nil, err
+	}
+
+	key, _, err := parsePublicKey(credentialPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := key.verify(append(append([]byte{}, rawAuthData...), clientDataHash[:]...), signature); err != nil {
+		return nil, err
+	}
+
+	// Authenticators without a counter always report zero
+	if (data.signCount != 0 || signCount != 0) && data.signCount <= signCount {
+		return nil, ErrSignCount
+	}
+
+	return &Assertion{SignCount: data.signCount, Verified: data.flags&flagUserVerified != 0}, nil
+}
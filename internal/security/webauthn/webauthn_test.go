@@ -0,0 +1,323 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var testRP = &RelyingParty{ID: "dms.example.com", Name: "DMS", Origins: []string{"https://dms.example.com"}}
+
+// testAuthenticator signs ceremonies like a security key with an ES256
+// credential
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthenticator{key: key, credentialID: []byte("credential-1")}
+}
+
+// coseKey returns the credential public key as a COSE_Key
+func (a *testAuthenticator) coseKey() []byte {
+	return encodeCBOR(cborMap{
+		{coseKeyType, coseKeyTypeEC2},
+		{coseAlgorithm, AlgES256},
+		{coseCurve, coseCurveP256},
+		{coseX, a.key.X.FillBytes(make([]byte, 32))},
+		{coseY, a.key.Y.FillBytes(make([]byte, 32))},
+	})
+}
+
+// authData builds authenticator data, with the attested credential when
+// attested is set
+func (a *testAuthenticator) authData(rpID string, flags byte, signCount uint32, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+// sign signs authenticator data and client data as in an assertion
+func (a *testAuthenticator) sign(t *testing.T, authData, clientDataJSON []byte) []byte {
+	t.Helper()
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+func clientDataJSON(ceremony string, challenge []byte, origin string) []byte {
+	data, _ := json.Marshal(clientData{Type: ceremony, Challenge: EncodeBase64(challenge), Origin: origin})
+	return data
+}
+
+func attestationObject(format string, statement cborMap, authData []byte) []byte {
+	return encodeCBOR(cborMap{{"fmt", format}, {"attStmt", statement}, {"authData", authData}})
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	challenge := []byte("registration-challenge")
+	client := clientDataJSON("webauthn.create", challenge, "https://dms.example.com")
+	authData := authenticator.authData(testRP.ID, flagUserPresent|flagUserVerified|flagAttested, 0, true)
+
+	credential, err := testRP.VerifyRegistration(challenge, client, attestationObject("none", cborMap{}, authData), true)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if string(credential.ID) != string(authenticator.credentialID) || !credential.Verified || credential.Format != "none" {
+		t.Errorf("credential = %+v", credential)
+	}
+	if _, _, err := parsePublicKey(credential.PublicKey); err != nil {
+		t.Errorf("stored public key: %v", err)
+	}
+}
+
+func TestVerifyRegistrationPackedSelfAttestation(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	challenge := []byte("registration-challenge")
+	client := clientDataJSON("webauthn.create", challenge, "https://dms.example.com")
+	authData := authenticator.authData(testRP.ID, flagUserPresent|flagAttested, 0, true)
+	signature := authenticator.sign(t, authData, client)
+
+	object := attestationObject("packed", cborMap{{"alg", AlgES256}, {"sig", signature}}, authData)
+	if _, err := testRP.VerifyRegistration(challenge, client, object, false); err != nil {
+		t.Errorf("VerifyRegistration: %v", err)
+	}
+
+	signature[len(signature)-1] ^= 0xff
+	object = attestationObject("packed", cborMap{{"alg", AlgES256}, {"sig", signature}}, authData)
+	if _, err := testRP.VerifyRegistration(challenge, client, object, false); err == nil {
+		t.Error("VerifyRegistration accepted a bad self attestation signature")
+	}
+
+	object = attestationObject("packed", cborMap{{"alg", AlgRS256}, {"sig", signature}}, authData)
+	if _, err := testRP.VerifyRegistration(challenge, client, object, false); err == nil {
+		t.Error("VerifyRegistration accepted a self attestation with another algorithm")
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	challenge := []byte("registration-challenge")
+	origin := "https://dms.example.com"
+	goodFlags := byte(flagUserPresent | flagUserVerified | flagAttested)
+
+	trailing := append(authenticator.authData(testRP.ID, goodFlags, 0, true), 0xa0)
+
+	tests := []struct {
+		name     string
+		client   []byte
+		authData []byte
+		format   string
+	}{
+		{"other challenge", clientDataJSON("webauthn.create", []byte("other"), origin), authenticator.authData(testRP.ID, goodFlags, 0, true), "none"},
+		{"other origin", clientDataJSON("webauthn.create", challenge, "https://evil.example.com"), authenticator.authData(testRP.ID, goodFlags, 0, true), "none"},
+		{"assertion client data", clientDataJSON("webauthn.get", challenge, origin), authenticator.authData(testRP.ID, goodFlags, 0, true), "none"},
+		{"malformed client data", []byte("{"), authenticator.authData(testRP.ID, goodFlags, 0, true), "none"},
+		{"other relying party", clientDataJSON("webauthn.create", challenge, origin), authenticator.authData("evil.example.com", goodFlags, 0, true), "none"},
+		{"user not present", clientDataJSON("webauthn.create", challenge, origin), authenticator.authData(testRP.ID, flagUserVerified|flagAttested, 0, true), "none"},
+		{"user not verified", clientDataJSON("webauthn.create", challenge, origin), authenticator.authData(testRP.ID, flagUserPresent|flagAttested, 0, true), "none"},
+		{"no attested credential", clientDataJSON("webauthn.create", challenge, origin), authenticator.authData(testRP.ID, flagUserPresent|flagUserVerified, 0, false), "none"},
+		{"attested flag without credential", clientDataJSON("webauthn.create", challenge, origin), authenticator.authData(testRP.ID, goodFlags, 0, false), "none"},
+		{"data after the key without extensions", clientDataJSON("webauthn.create", challenge, origin), trailing, "none"},
+		{"short authenticator data", clientDataJSON("webauthn.create", challenge, origin), make([]byte, 36), "none"},
+		{"unsupported format", clientDataJSON("webauthn.create", challenge, origin), authenticator.authData(testRP.ID, goodFlags, 0, true), "tpm"},
+		{"packed without signature", clientDataJSON("webauthn.create", challenge, origin), authenticator.authData(testRP.ID, goodFlags, 0, true), "packed"},
+		{"fido-u2f without certificate", clientDataJSON("webauthn.create", challenge, origin), authenticator.authData(testRP.ID, goodFlags, 0, true), "fido-u2f"},
+	}
+	for _, tt := range tests {
+		object := attestationObject(tt.format, cborMap{}, tt.authData)
+		if _, err := testRP.VerifyRegistration(challenge, tt.client, object, true); err == nil {
+			t.Errorf("%s: VerifyRegistration succeeded", tt.name)
+		}
+	}
+
+	client := clientDataJSON("webauthn.create", challenge, origin)
+	if _, err := testRP.VerifyRegistration(challenge, client, []byte{0x9b, 0xff}, true); err == nil {
+		t.Error("VerifyRegistration accepted a malformed attestation object")
+	}
+	if _, err := testRP.VerifyRegistration(challenge, client, encodeCBOR([]interface{}{1}), true); err == nil {
+		t.Error("VerifyRegistration accepted an attestation object that isn't a map")
+	}
+}
+
+func TestParseAuthenticatorDataRejectsLongCredentialIDs(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	data := authenticator.authData(testRP.ID, flagUserPresent|flagAttested, 0, true)
+	// Claim a credential ID longer than the data
+	binary.BigEndian.PutUint16(data[37+16:], 0xffff)
+	if _, err := parseAuthenticatorData(data); err == nil {
+		t.Error("parseAuthenticatorData accepted a credential ID longer than the data")
+	}
+}
+
+// assertion returns the client data, authenticator data and signature of an
+// authentication
+func (a *testAuthenticator) assertion(t *testing.T, challenge []byte, flags byte, signCount uint32) ([]byte, []byte, []byte) {
+	client := clientDataJSON("webauthn.get", challenge, "https://dms.example.com")
+	authData := a.authData(testRP.ID, flags, signCount, false)
+	return client, authData, a.sign(t, authData, client)
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	challenge := []byte("login-challenge")
+	client, authData, signature := authenticator.assertion(t, challenge, flagUserPresent|flagUserVerified, 8)
+
+	assertion, err := testRP.VerifyAssertion(challenge, authenticator.coseKey(), 7, client, authData, signature, true)
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+	if assertion.SignCount != 8 || !assertion.Verified {
+		t.Errorf("assertion = %+v", assertion)
+	}
+}
+
+func TestVerifyAssertionSignCount(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	challenge := []byte("login-challenge")
+
+	tests := []struct {
+		name     string
+		stored   uint32
+		received uint32
+		err      error
+	}{
+		{"increased", 5, 6, nil},
+		{"no counter", 0, 0, nil},
+		{"first use", 0, 1, nil},
+		{"repeated", 5, 5, ErrSignCount},
+		{"rolled back", 5, 4, ErrSignCount},
+		{"reset to zero", 5, 0, ErrSignCount},
+	}
+	for _, tt := range tests {
+		client, authData, signature := authenticator.assertion(t, challenge, flagUserPresent, tt.received)
+		_, err := testRP.VerifyAssertion(challenge, authenticator.coseKey(), tt.stored, client, authData, signature, false)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: VerifyAssertion = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	challenge := []byte("login-challenge")
+	key := authenticator.coseKey()
+
+	client, authData, signature := authenticator.assertion(t, challenge, flagUserPresent, 1)
+	if _, err := testRP.VerifyAssertion([]byte("other"), key, 0, client, authData, signature, false); err == nil {
+		t.Error("VerifyAssertion accepted another challenge")
+	}
+
+	bad := append([]byte{}, signature...)
+	bad[len(bad)-1] ^= 0xff
+	if _, err := testRP.VerifyAssertion(challenge, key, 0, client, authData, bad, false); err == nil {
+		t.Error("VerifyAssertion accepted a bad signature")
+	}
+
+	other := newTestAuthenticator(t)
+	if _, err := testRP.VerifyAssertion(challenge, other.coseKey(), 0, client, authData, signature, false); err == nil {
+		t.Error("VerifyAssertion accepted another credential's signature")
+	}
+
+	// The signature covers the authenticator data, so flags can't be raised
+	tampered := append([]byte{}, authData...)
+	tampered[32] |= flagUserVerified
+	if _, err := testRP.VerifyAssertion(challenge, key, 0, client, tampered, signature, false); err == nil {
+		t.Error("VerifyAssertion accepted tampered authenticator data")
+	}
+
+	if _, err := testRP.VerifyAssertion(challenge, key, 0, client, authData, signature, true); err == nil {
+		t.Error("VerifyAssertion accepted an unverified user when verification is required")
+	}
+
+	client, authData, signature = authenticator.assertion(t, challenge, flagUserVerified, 1)
+	if _, err := testRP.VerifyAssertion(challenge, key, 0, client, authData, signature, false); err == nil {
+		t.Error("VerifyAssertion accepted an absent user")
+	}
+
+	client = clientDataJSON("webauthn.get", challenge, "https://dms.example.com")
+	authData = authenticator.authData("evil.example.com", flagUserPresent, 1, false)
+	signature = authenticator.sign(t, authData, client)
+	if _, err := testRP.VerifyAssertion(challenge, key, 0, client, authData, signature, false); err == nil {
+		t.Error("VerifyAssertion accepted another relying party")
+	}
+
+	client = clientDataJSON("webauthn.get", challenge, "https://dms.example.com.evil.com")
+	authData = authenticator.authData(testRP.ID, flagUserPresent, 1, false)
+	signature = authenticator.sign(t, authData, client)
+	if _, err := testRP.VerifyAssertion(challenge, key, 0, client, authData, signature, false); err == nil {
+		t.Error("VerifyAssertion accepted another origin")
+	}
+
+	client = clientDataJSON("webauthn.create", challenge, "https://dms.example.com")
+	signature = authenticator.sign(t, authData, client)
+	if _, err := testRP.VerifyAssertion(challenge, key, 0, client, authData, signature, false); err == nil {
+		t.Error("VerifyAssertion accepted registration client data")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	if _, _, err := parsePublicKey(authenticator.coseKey()); err != nil {
+		t.Errorf("parsePublicKey(ES256): %v", err)
+	}
+
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	okp := encodeCBOR(cborMap{{coseKeyType, coseKeyTypeOKP}, {coseAlgorithm, AlgEdDSA}, {coseCurve, coseCurveEd25519}, {coseX, []byte(edKey)}})
+	if _, _, err := parsePublicKey(okp); err != nil {
+		t.Errorf("parsePublicKey(EdDSA): %v", err)
+	}
+
+	x := authenticator.key.X.FillBytes(make([]byte, 32))
+	y := authenticator.key.Y.FillBytes(make([]byte, 32))
+	offCurve := append([]byte{}, y...)
+	offCurve[31] ^= 0x01
+
+	tests := []struct {
+		name string
+		key  cborMap
+	}{
+		{"point off the curve", cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgES256}, {coseCurve, coseCurveP256}, {coseX, x}, {coseY, offCurve}}},
+		{"other curve", cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgES256}, {coseCurve, 2}, {coseX, x}, {coseY, y}}},
+		{"short coordinate", cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgES256}, {coseCurve, coseCurveP256}, {coseX, x[:31]}, {coseY, y}}},
+		{"algorithm of another key type", cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgEdDSA}, {coseCurve, coseCurveP256}, {coseX, x}, {coseY, y}}},
+		{"short RSA modulus", cborMap{{coseKeyType, coseKeyTypeRSA}, {coseAlgorithm, AlgRS256}, {coseModulus, make([]byte, 128)}, {coseExponent, []byte{1, 0, 1}}}},
+		{"long RSA exponent", cborMap{{coseKeyType, coseKeyTypeRSA}, {coseAlgorithm, AlgRS256}, {coseModulus, make([]byte, 256)}, {coseExponent, make([]byte, 5)}}},
+		{"unsupported algorithm", cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, -35}, {coseCurve, 2}, {coseX, x}, {coseY, y}}},
+	}
+	for _, tt := range tests {
+		if _, _, err := parsePublicKey(encodeCBOR(tt.key)); err == nil {
+			t.Errorf("%s: parsePublicKey succeeded", tt.name)
+		}
+	}
+	if _, _, err := parsePublicKey(encodeCBOR([]interface{}{1})); err == nil {
+		t.Error("parsePublicKey accepted an array")
+	}
+}
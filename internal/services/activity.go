@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// ActivityType groups audit actions into feed categories
+type ActivityType string
+
+const (
+	ActivityView    ActivityType = "view"
+	ActivityEdit    ActivityType = "edit"
+	ActivityShare   ActivityType = "share"
+	ActivityComment ActivityType = "comment"
+)
+
+// activityActions maps feed categories to the audit actions they cover
+var activityActions = map[ActivityType][]string{
+	ActivityView: {
+		"document_view",
+		"document_download",
+	},
+	ActivityEdit: {
+		"document_create",
+		"document_update",
+		"document_delete",
+		"document_restore",
+		"document_version_create",
+		"document_version_restore",
+		"document_move",
+		"document_copy",
+		"document_tag_set",
+		"document_tag_add",
+		"document_untag",
+		"document_metadata_update",
+	},
+	ActivityShare: {
+		"permission_grant",
+		"permission_revoke",
+	},
+	ActivityComment: {
+		"comment_create",
+	},
+}
+
+// ActivityItem is a lightweight feed entry derived from an audit log
+type ActivityItem struct {
+	ID            uint         `json:"id"`
+	Type          ActivityType `json:"type" gorm:"-"`
+	Action        string       `json:"action"`
+	ResourceType  string       `json:"resource_type"`
+	ResourceID    string       `json:"resource_id"`
+	DocumentID    *uint        `json:"document_id,omitempty"`
+	DocumentTitle string       `json:"document_title,omitempty"`
+	Timestamp     time.Time    `json:"timestamp"`
+}
+
+// ValidActivityType reports whether t is a known feed category
+func ValidActivityType(t ActivityType) bool {
+	_, ok := activityActions[t]
+	return ok
+}
+
+// GetUserActivity returns the user's most recent document activity, newest
+// first, optionally limited to some feed types. Only entries older than the
+// cursor (an activity ID) are returned when cursor is not zero. The returned
+// cursor is zero when there are no further entries.
+func (s *AuditService) GetUserActivity(ctx context.Context, userID uint, types []ActivityType, cursor uint, limit int) ([]ActivityItem, uint, error) {
+	if len(types) == 0 {
+		types = []ActivityType{ActivityView, ActivityEdit, ActivityShare, ActivityComment}
+	}
+
+	actionTypes := make(map[string]ActivityType)
+	var actions []string
+	for _, t := range types {
+		for _, action := range activityActions[t] {
+			actionTypes[action] = t
+			actions = append(actions, action)
+		}
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.AuditLog{}).
+		Select("audit_logs.id, audit_logs.action, audit_logs.resource_type, audit_logs.resource_id, "+
+			"audit_logs.document_id, documents.title AS document_title, audit_logs.timestamp").
+		Joins("LEFT JOIN documents ON documents.id = audit_logs.document_id").
+		Where("audit_logs.user_id = ?", userID).
+		Where("audit_logs.action IN ?", actions)
+	if cursor != 0 {
+		query = query.Where("audit_logs.id < ?", cursor)
+	}
+
+	// Fetch one extra entry to know whether another page exists
+	var items []ActivityItem
+	if err := query.Order("audit_logs.id DESC").Limit(limit + 1).Scan(&items).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get activity: %w", err)
+	}
+
+	var next uint
+	if len(items) > limit {
+		items = items[:limit]
+		next = items[limit-1].ID
+	}
+
+	for i := range items {
+		items[i].Type = actionTypes[items[i].Action]
+	}
+
+	return items, next, nil
+}
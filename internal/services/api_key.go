@@ -0,0 +1,163 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key, telling keys apart from access tokens
+const APIKeyPrefix = "dms_"
+
+// apiKeyTouchInterval is how stale the last use of a key may get before it
+// is recorded again, sparing a write on every request
+const apiKeyTouchInterval = time.Minute
+
+var (
+	// ErrInvalidAPIKey is returned for unknown, revoked or expired API keys
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyNotFound is returned when a user has no such API key
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKeyService issues and checks personal API keys
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{
+		db: database.GetDB(),
+	}
+}
+
+// Create issues a new API key for a user. The key is only ever returned
+// here; just its hash is stored.
+func (s *APIKeyService) Create(userID uint, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:len(APIKeyPrefix)+8],
+		KeyHash:   hashSecret(key),
+		Scopes:    strings.Join(normalized, ","),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.db.Create(apiKey).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to save API key: %w", err)
+	}
+	return apiKey, key, nil
+}
+
+// GetKeys lists a user's API keys that haven't been revoked, newest first
+func (s *APIKeyService) GetKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC, id DESC").
+		Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// GetKey retrieves an API key, revoked or not, of a user of the organization
+func (s *APIKeyService) GetKey(organizationID, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Where("user_id IN (?)", s.db.Model(&models.User{}).Select("id").Where("organization_id = ?", organizationID)).
+		First(&key, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return &key, nil
+}
+
+// SetQuota sets the daily quotas of an API key of a user of the
+// organization; nil uses the defaults and 0 is unlimited
+func (s *APIKeyService) SetQuota(organizationID, id uint, dailyRequests, dailyBandwidthMB *int64) (*models.APIKey, error) {
+	key, err := s.GetKey(organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(key).Select("daily_request_quota", "daily_bandwidth_quota_mb").Updates(&models.APIKey{
+		DailyRequestQuota:     dailyRequests,
+		DailyBandwidthQuotaMB: dailyBandwidthMB,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to set API key quota: %w", err)
+	}
+	key.DailyRequestQuota, key.DailyBandwidthQuotaMB = dailyRequests, dailyBandwidthMB
+	return key, nil
+}
+
+// Revoke stops one of a user's API keys from working
+func (s *APIKeyService) Revoke(userID, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL", userID).First(&key, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	if err := s.db.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return &key, nil
+}
+
+// Authenticate looks up an API key presented from ipAddress, recording its
+// use. It returns ErrInvalidAPIKey for unknown, revoked or expired keys.
+func (s *APIKeyService) Authenticate(key, ipAddress string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := s.db.Where("key_hash = ?", hashSecret(key)).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(now)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval || apiKey.LastUsedIP != ipAddress {
+		if err := s.db.Model(&apiKey).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ipAddress,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("failed to record API key use: %w", err)
+		}
+	}
+	return &apiKey, nil
+}
+
+// Scopes returns the scopes of an API key
+func (s *APIKeyService) Scopes(apiKey *models.APIKey) []string {
+	return strings.Split(apiKey.Scopes, ",")
+}
+
+// hashSecret returns the stored form of a random secret such as an API
+// key; such secrets are too long for a plain hash to be brute forced
+func hashSecret(secret string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(secret)))
+}
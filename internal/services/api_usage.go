@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxUsageDays is the longest usage history returned at once
+const MaxUsageDays = 90
+
+// APICredential is an API key or OAuth client whose usage is counted,
+// with its daily quotas resolved; 0 is unlimited
+type APICredential struct {
+	Type             string
+	ID               uint
+	DailyRequests    int64
+	DailyBandwidthMB int64
+}
+
+// QuotaError is returned when a credential used up a daily quota. The
+// quota resets at ResetAt, the next UTC midnight.
+type QuotaError struct {
+	Quota   string // requests or bandwidth
+	Limit   int64
+	ResetAt time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("daily %s quota of %d exceeded", e.Quota, e.Limit)
+}
+
+// APIUsageReport is the usage of a credential over recent days
+type APIUsageReport struct {
+	CredentialType   string            `json:"credential_type"`
+	CredentialID     uint              `json:"credential_id"`
+	DailyRequests    int64             `json:"daily_request_quota"`      // 0 is unlimited
+	DailyBandwidthMB int64             `json:"daily_bandwidth_quota_mb"` // 0 is unlimited
+	Today            models.APIUsage   `json:"today"`
+	Days             []models.APIUsage `json:"days"` // Newest first; days without requests are left out
+}
+
+// APIUsageService counts the requests and bandwidth of API keys and OAuth
+// clients and enforces their daily quotas
+type APIUsageService struct {
+	db                      *gorm.DB
+	defaultDailyRequests    int64
+	defaultDailyBandwidthMB int64
+}
+
+// NewAPIUsageService creates a new API usage service. The default quotas
+// apply to credentials without their own; 0 is unlimited.
+func NewAPIUsageService(defaultDailyRequests, defaultDailyBandwidthMB int64) *APIUsageService {
+	return &APIUsageService{
+		db:                      database.GetDB(),
+		defaultDailyRequests:    defaultDailyRequests,
+		defaultDailyBandwidthMB: defaultDailyBandwidthMB,
+	}
+}
+
+// ForAPIKey returns the credential of an API key
+func (s *APIUsageService) ForAPIKey(apiKey *models.APIKey) APICredential {
+	return s.credential(models.CredentialAPIKey, apiKey.ID, apiKey.DailyRequestQuota, apiKey.DailyBandwidthQuotaMB)
+}
+
+// ForServiceAccount returns the credential of the OAuth client acting as
+// the service account userID
+func (s *APIUsageService) ForServiceAccount(userID uint) (APICredential, error) {
+	var client models.OAuthClient
+	if err := s.db.Where("user_id = ?", userID).First(&client).Error; err != nil {
+		return APICredential{}, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+	return s.ForOAuthClient(&client), nil
+}
+
+// ForOAuthClient returns the credential of an OAuth client
+func (s *APIUsageService) ForOAuthClient(client *models.OAuthClient) APICredential {
+	return s.credential(models.CredentialOAuthClient, client.ID, client.DailyRequestQuota, client.DailyBandwidthQuotaMB)
+}
+
+// credential resolves the quotas of a credential
+func (s *APIUsageService) credential(credentialType string, id uint, dailyRequests, dailyBandwidthMB *int64) APICredential {
+	credential := APICredential{
+		Type:             credentialType,
+		ID:               id,
+		DailyRequests:    s.defaultDailyRequests,
+		DailyBandwidthMB: s.defaultDailyBandwidthMB,
+	}
+	if dailyRequests != nil {
+		credential.DailyRequests = *dailyRequests
+	}
+	if dailyBandwidthMB != nil {
+		credential.DailyBandwidthMB = *dailyBandwidthMB
+	}
+	return credential
+}
+
+// CheckQuota returns a *QuotaError when a credential has used up one of
+// its quotas today
+func (s *APIUsageService) CheckQuota(credential APICredential) error {
+	if credential.DailyRequests <= 0 && credential.DailyBandwidthMB <= 0 {
+		return nil
+	}
+
+	today, err := s.today(credential)
+	if err != nil {
+		return err
+	}
+
+	resetAt := usageDay(time.Now()).AddDate(0, 0, 1)
+	if credential.DailyRequests > 0 && today.Requests >= credential.DailyRequests {
+		return &QuotaError{Quota: "requests", Limit: credential.DailyRequests, ResetAt: resetAt}
+	}
+	if credential.DailyBandwidthMB > 0 && today.BytesIn+today.BytesOut >= credential.DailyBandwidthMB<<20 {
+		return &QuotaError{Quota: "bandwidth", Limit: credential.DailyBandwidthMB, ResetAt: resetAt}
+	}
+	return nil
+}
+
+// Record counts a request of a credential and the bytes it moved
+func (s *APIUsageService) Record(credential APICredential, bytesIn, bytesOut int64) error {
+	usage := models.APIUsage{
+		CredentialType: credential.Type,
+		CredentialID:   credential.ID,
+		Day:            usageDay(time.Now()),
+		Requests:       1,
+		BytesIn:        bytesIn,
+		BytesOut:       bytesOut,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "credential_type"}, {Name: "credential_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":  gorm.Expr("api_usages.requests + 1"),
+			"bytes_in":  gorm.Expr("api_usages.bytes_in + ?", bytesIn),
+			"bytes_out": gorm.Expr("api_usages.bytes_out + ?", bytesOut),
+		}),
+	}).Create(&usage).Error; err != nil {
+		return fmt.Errorf("failed to record API usage: %w", err)
+	}
+	return nil
+}
+
+// GetReport returns the usage of a credential over the last days
+func (s *APIUsageService) GetReport(credential APICredential, days int) (*APIUsageReport, error) {
+	if days < 1 || days > MaxUsageDays {
+		days = MaxUsageDays
+	}
+
+	report := &APIUsageReport{
+		CredentialType:   credential.Type,
+		CredentialID:     credential.ID,
+		DailyRequests:    credential.DailyRequests,
+		DailyBandwidthMB: credential.DailyBandwidthMB,
+	}
+	if err := s.db.Where("credential_type = ? AND credential_id = ? AND day > ?",
+		credential.Type, credential.ID, usageDay(time.Now()).AddDate(0, 0, -days)).
+		Order("day DESC").
+		Find(&report.Days).Error; err != nil {
+		return nil, fmt.Errorf("failed to get API usage: %w", err)
+	}
+
+	today, err := s.today(credential)
+	if err != nil {
+		return nil, err
+	}
+	report.Today = *today
+	return report, nil
+}
+
+// today returns a credential's usage so far today
+func (s *APIUsageService) today(credential APICredential) (*models.APIUsage, error) {
+	day := usageDay(time.Now())
+	var usage models.APIUsage
+	err := s.db.Where("credential_type = ? AND credential_id = ? AND day = ?", credential.Type, credential.ID, day).First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.APIUsage{CredentialType: credential.Type, CredentialID: credential.ID, Day: day}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API usage: %w", err)
+	}
+	return &usage, nil
+}
+
+// usageDay returns the UTC day usage at t counts toward
+func usageDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -10,9 +12,91 @@ import (
 	"gorm.io/gorm"
 )
 
+// auditBatchSize is the number of audit entries read per batch when going
+// through all entries matching a filter
+const auditBatchSize = 1000
+
+// AuditLogFilter selects audit log entries of an organization. Empty fields
+// don't filter; To is exclusive.
+type AuditLogFilter struct {
+	UserID       *uint
+	DocumentID   *uint
+	Actions      []string // Any of them
+	ResourceType string
+	ResourceID   string
+	IPAddress    string
+	Query        string // Text contained in the details, user agent or IP address, ignoring case
+	From         time.Time
+	To           time.Time
+}
+
+// apply adds the filter conditions to an audit logs query
+func (f *AuditLogFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.UserID != nil {
+		db = db.Where("audit_logs.user_id = ?", *f.UserID)
+	}
+	if f.DocumentID != nil {
+		db = db.Where("audit_logs.document_id = ?", *f.DocumentID)
+	}
+	if len(f.Actions) > 0 {
+		db = db.Where("audit_logs.action IN ?", f.Actions)
+	}
+	if f.ResourceType != "" {
+		db = db.Where("audit_logs.resource_type = ?", f.ResourceType)
+	}
+	if f.ResourceID != "" {
+		db = db.Where("audit_logs.resource_id = ?", f.ResourceID)
+	}
+	if f.IPAddress != "" {
+		db = db.Where("audit_logs.ip_address = ?", f.IPAddress)
+	}
+	if f.Query != "" {
+		pattern := "%" + escapeLike(f.Query) + "%"
+		db = db.Where("(audit_logs.details ILIKE @pattern ESCAPE '\\' OR audit_logs.user_agent ILIKE @pattern ESCAPE '\\' OR audit_logs.ip_address ILIKE @pattern ESCAPE '\\')",
+			sql.Named("pattern", pattern))
+	}
+	if !f.From.IsZero() {
+		db = db.Where("audit_logs.timestamp >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		db = db.Where("audit_logs.timestamp < ?", f.To)
+	}
+	return db
+}
+
+// withUsers preloads the users of audit entries, including deleted ones
+func withUsers(db *gorm.DB) *gorm.DB {
+	return db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	})
+}
+
+// securityActions are the audit actions reported as security events
+var securityActions = []string{
+	"login_success",
+	"login_failed",
+	"logout",
+	"password_change",
+	"account_locked",
+	"permission_denied",
+	"unauthorized_access",
+	"malware_detected",
+	"dlp_upload_blocked",
+	"dlp_access_level_raised",
+	"classification_override",
+	"document_access_level_change",
+}
+
+// AuditObserver is notified of audit entries once they are stored, such as
+// to stream them elsewhere. AuditLogged must not block.
+type AuditObserver interface {
+	AuditLogged(entry *models.AuditLog)
+}
+
 // AuditService handles audit logging
 type AuditService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	observers []AuditObserver
 }
 
 // NewAuditService creates a new audit service
@@ -22,18 +106,91 @@ func NewAuditService() *AuditService {
 	}
 }
 
-// LogAction logs an action to the audit trail
+// AddObserver registers an observer notified of every stored audit entry
+func (s *AuditService) AddObserver(observer AuditObserver) {
+	s.observers = append(s.observers, observer)
+}
+
+// Published notifies the observers of stored entries. Entries stored outside
+// the audit service, in the transaction of the change they record, are
+// published by the caller after it commits.
+func (s *AuditService) Published(entries ...*models.AuditLog) {
+	for _, observer := range s.observers {
+		for _, entry := range entries {
+			observer.AuditLogged(entry)
+		}
+	}
+}
+
+// LogAction logs an action to the audit trail of the acting user's
+// organization
 func (s *AuditService) LogAction(userID uint, documentID *uint, action, resourceType, resourceID, ipAddress, userAgent string, details map[string]interface{}) error {
+	auditLog, err := NewAuditEntry(userID, documentID, action, resourceType, resourceID, ipAddress, userAgent, details)
+	if err != nil {
+		return err
+	}
+	if auditLog.OrganizationID, err = s.organizationOf(userID); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(auditLog).Error; err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	s.Published(auditLog)
+	return nil
+}
+
+// LogAnonymousAction logs an action of a visitor who isn't signed in, such
+// as reading the public portal of an organization
+func (s *AuditService) LogAnonymousAction(organizationID uint, documentID *uint, action, resourceType, resourceID, ipAddress, userAgent string, details map[string]interface{}) error {
+	auditLog, err := NewAuditEntry(0, documentID, action, resourceType, resourceID, ipAddress, userAgent, details)
+	if err != nil {
+		return err
+	}
+	auditLog.OrganizationID = organizationID
+
+	if err := s.db.Create(auditLog).Error; err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	s.Published(auditLog)
+	return nil
+}
+
+// organizationOf returns the organization of a user. Actions of unknown
+// users, such as failed logins with unknown usernames, belong to the default
+// organization.
+func (s *AuditService) organizationOf(userID uint) (uint, error) {
+	var organizations []uint
+	if err := s.db.Unscoped().Model(&models.User{}).Where("id = ?", userID).Pluck("organization_id", &organizations).Error; err != nil {
+		return 0, fmt.Errorf("failed to get user organization: %w", err)
+	}
+	if len(organizations) > 0 && organizations[0] != 0 {
+		return organizations[0], nil
+	}
+
+	organization, err := database.DefaultOrganization()
+	if err != nil {
+		return 0, err
+	}
+	return organization.ID, nil
+}
+
+// NewAuditEntry builds an audit log entry without storing it, for changes
+// that store their audit trail in the same transaction. The caller sets the
+// organization.
+func NewAuditEntry(userID uint, documentID *uint, action, resourceType, resourceID, ipAddress, userAgent string, details map[string]interface{}) (*models.AuditLog, error) {
 	var detailsJSON string
 	if details != nil {
 		detailsBytes, err := json.Marshal(details)
 		if err != nil {
-			return fmt.Errorf("failed to marshal details: %w", err)
+			return nil, fmt.Errorf("failed to marshal details: %w", err)
 		}
 		detailsJSON = string(detailsBytes)
 	}
 
-	auditLog := &models.AuditLog{
+	return &models.AuditLog{
 		UserID:       userID,
 		DocumentID:   documentID,
 		Action:       action,
@@ -43,27 +200,21 @@ func (s *AuditService) LogAction(userID uint, documentID *uint, action, resource
 		UserAgent:    userAgent,
 		Details:      detailsJSON,
 		Timestamp:    time.Now(),
-	}
-
-	if err := s.db.Create(auditLog).Error; err != nil {
-		return fmt.Errorf("failed to create audit log: %w", err)
-	}
-
-	return nil
+	}, nil
 }
 
 // GetUserAuditLogs retrieves audit logs for a specific user
-func (s *AuditService) GetUserAuditLogs(userID uint, page, limit int) ([]models.AuditLog, int64, error) {
+func (s *AuditService) GetUserAuditLogs(ctx context.Context, userID uint, page, limit int) ([]models.AuditLog, int64, error) {
 	var logs []models.AuditLog
 	var total int64
 
 	offset := (page - 1) * limit
 
-	if err := s.db.Model(&models.AuditLog{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+	if err := s.db.WithContext(ctx).Model(&models.AuditLog{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
 	}
 
-	if err := s.db.Where("user_id = ?", userID).
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).
 		Order("timestamp DESC").
 		Offset(offset).
 		Limit(limit).
@@ -77,17 +228,17 @@ func (s *AuditService) GetUserAuditLogs(userID uint, page, limit int) ([]models.
 }
 
 // GetDocumentAuditLogs retrieves audit logs for a specific document
-func (s *AuditService) GetDocumentAuditLogs(documentID uint, page, limit int) ([]models.AuditLog, int64, error) {
+func (s *AuditService) GetDocumentAuditLogs(ctx context.Context, documentID uint, page, limit int) ([]models.AuditLog, int64, error) {
 	var logs []models.AuditLog
 	var total int64
 
 	offset := (page - 1) * limit
 
-	if err := s.db.Model(&models.AuditLog{}).Where("document_id = ?", documentID).Count(&total).Error; err != nil {
+	if err := s.db.WithContext(ctx).Model(&models.AuditLog{}).Where("document_id = ?", documentID).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
 	}
 
-	if err := s.db.Where("document_id = ?", documentID).
+	if err := s.db.WithContext(ctx).Where("document_id = ?", documentID).
 		Order("timestamp DESC").
 		Offset(offset).
 		Limit(limit).
@@ -100,18 +251,18 @@ func (s *AuditService) GetDocumentAuditLogs(documentID uint, page, limit int) ([
 	return logs, total, nil
 }
 
-// GetAllAuditLogs retrieves all audit logs with pagination
-func (s *AuditService) GetAllAuditLogs(page, limit int) ([]models.AuditLog, int64, error) {
+// GetAllAuditLogs retrieves the audit logs of an organization with pagination
+func (s *AuditService) GetAllAuditLogs(ctx context.Context, organizationID uint, page, limit int) ([]models.AuditLog, int64, error) {
 	var logs []models.AuditLog
 	var total int64
 
 	offset := (page - 1) * limit
 
-	if err := s.db.Model(&models.AuditLog{}).Count(&total).Error; err != nil {
+	if err := s.db.WithContext(ctx).Model(&models.AuditLog{}).Scopes(OfOrganization("audit_logs", organizationID)).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
 	}
 
-	if err := s.db.Order("timestamp DESC").
+	if err := s.db.WithContext(ctx).Scopes(OfOrganization("audit_logs", organizationID)).Order("timestamp DESC").
 		Offset(offset).
 		Limit(limit).
 		Preload("User").
@@ -123,18 +274,71 @@ func (s *AuditService) GetAllAuditLogs(page, limit int) ([]models.AuditLog, int6
 	return logs, total, nil
 }
 
-// GetAuditLogsByAction retrieves audit logs by action type
-func (s *AuditService) GetAuditLogsByAction(action string, page, limit int) ([]models.AuditLog, int64, error) {
+// GetAuditLogs retrieves a page of an organization's audit logs matching
+// the filter, newest first
+func (s *AuditService) GetAuditLogs(organizationID uint, filter *AuditLogFilter, page, limit int) ([]models.AuditLog, int64, error) {
 	var logs []models.AuditLog
 	var total int64
 
 	offset := (page - 1) * limit
+	query := s.db.Model(&models.AuditLog{}).
+		Scopes(OfOrganization("audit_logs", organizationID), filter.apply).
+		Session(&gorm.Session{})
 
-	if err := s.db.Model(&models.AuditLog{}).Where("action = ?", action).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
 	}
 
-	if err := s.db.Where("action = ?", action).
+	if err := query.Scopes(withUsers).
+		Order("timestamp DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get audit logs: %w", err)
+	}
+
+	return logs, total, nil
+}
+
+// EachAuditLog calls fn with each of an organization's audit logs matching
+// the filter, oldest first, reading them in batches so any number of them
+// can be exported. It stops at the first error of fn.
+func (s *AuditService) EachAuditLog(organizationID uint, filter *AuditLogFilter, fn func(*models.AuditLog) error) error {
+	var lastID uint
+	for {
+		var logs []models.AuditLog
+		if err := s.db.Scopes(OfOrganization("audit_logs", organizationID), filter.apply, withUsers).
+			Where("audit_logs.id > ?", lastID).
+			Order("audit_logs.id ASC").
+			Limit(auditBatchSize).
+			Find(&logs).Error; err != nil {
+			return fmt.Errorf("failed to get audit logs: %w", err)
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+
+		for i := range logs {
+			lastID = logs[i].ID
+			if err := fn(&logs[i]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// GetAuditLogsByAction retrieves audit logs of an organization by action type
+func (s *AuditService) GetAuditLogsByAction(ctx context.Context, organizationID uint, action string, page, limit int) ([]models.AuditLog, int64, error) {
+	var logs []models.AuditLog
+	var total int64
+
+	offset := (page - 1) * limit
+
+	if err := s.db.WithContext(ctx).Model(&models.AuditLog{}).Scopes(OfOrganization("audit_logs", organizationID)).Where("action = ?", action).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	if err := s.db.WithContext(ctx).Scopes(OfOrganization("audit_logs", organizationID)).Where("action = ?", action).
 		Order("timestamp DESC").
 		Offset(offset).
 		Limit(limit).
@@ -147,20 +351,20 @@ func (s *AuditService) GetAuditLogsByAction(action string, page, limit int) ([]m
 	return logs, total, nil
 }
 
-// GetAuditLogsByDateRange retrieves audit logs within a date range
-func (s *AuditService) GetAuditLogsByDateRange(startDate, endDate time.Time, page, limit int) ([]models.AuditLog, int64, error) {
+// GetAuditLogsByDateRange retrieves audit logs of an organization within a date range
+func (s *AuditService) GetAuditLogsByDateRange(ctx context.Context, organizationID uint, startDate, endDate time.Time, page, limit int) ([]models.AuditLog, int64, error) {
 	var logs []models.AuditLog
 	var total int64
 
 	offset := (page - 1) * limit
 
-	if err := s.db.Model(&models.AuditLog{}).
+	if err := s.db.WithContext(ctx).Model(&models.AuditLog{}).Scopes(OfOrganization("audit_logs", organizationID)).
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
 	}
 
-	if err := s.db.Where("timestamp BETWEEN ? AND ?", startDate, endDate).
+	if err := s.db.WithContext(ctx).Scopes(OfOrganization("audit_logs", organizationID)).Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Order("timestamp DESC").
 		Offset(offset).
 		Limit(limit).
@@ -173,30 +377,20 @@ func (s *AuditService) GetAuditLogsByDateRange(startDate, endDate time.Time, pag
 	return logs, total, nil
 }
 
-// GetSecurityEvents retrieves security-related audit logs
-func (s *AuditService) GetSecurityEvents(page, limit int) ([]models.AuditLog, int64, error) {
-	securityActions := []string{
-		"login_success",
-		"login_failed",
-		"logout",
-		"password_change",
-		"account_locked",
-		"permission_denied",
-		"unauthorized_access",
-	}
-
+// GetSecurityEvents retrieves security-related audit logs of an organization
+func (s *AuditService) GetSecurityEvents(organizationID uint, page, limit int) ([]models.AuditLog, int64, error) {
 	var logs []models.AuditLog
 	var total int64
 
 	offset := (page - 1) * limit
 
-	if err := s.db.Model(&models.AuditLog{}).
+	if err := s.db.Model(&models.AuditLog{}).Scopes(OfOrganization("audit_logs", organizationID)).
 		Where("action IN ?", securityActions).
 		Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count security events: %w", err)
 	}
 
-	if err := s.db.Where("action IN ?", securityActions).
+	if err := s.db.Scopes(OfOrganization("audit_logs", organizationID)).Where("action IN ?", securityActions).
 		Order("timestamp DESC").
 		Offset(offset).
 		Limit(limit).
@@ -208,12 +402,12 @@ func (s *AuditService) GetSecurityEvents(page, limit int) ([]models.AuditLog, in
 	return logs, total, nil
 }
 
-// GetFailedLoginAttempts retrieves failed login attempts
-func (s *AuditService) GetFailedLoginAttempts(hours int) ([]models.AuditLog, error) {
+// GetFailedLoginAttempts retrieves failed login attempts in an organization
+func (s *AuditService) GetFailedLoginAttempts(organizationID uint, hours int) ([]models.AuditLog, error) {
 	since := time.Now().Add(time.Duration(-hours) * time.Hour)
 
 	var logs []models.AuditLog
-	if err := s.db.Where("action = ? AND timestamp > ?", "login_failed", since).
+	if err := s.db.Scopes(OfOrganization("audit_logs", organizationID)).Where("action = ? AND timestamp > ?", "login_failed", since).
 		Order("timestamp DESC").
 		Preload("User").
 		Find(&logs).Error; err != nil {
@@ -223,8 +417,8 @@ func (s *AuditService) GetFailedLoginAttempts(hours int) ([]models.AuditLog, err
 	return logs, nil
 }
 
-// GetSuspiciousActivity detects suspicious activity patterns
-func (s *AuditService) GetSuspiciousActivity(hours int) ([]models.AuditLog, error) {
+// GetSuspiciousActivity detects suspicious activity patterns in an organization
+func (s *AuditService) GetSuspiciousActivity(organizationID uint, hours int) ([]models.AuditLog, error) {
 	since := time.Now().Add(time.Duration(-hours) * time.Hour)
 
 	suspiciousActions := []string{
@@ -235,7 +429,7 @@ func (s *AuditService) GetSuspiciousActivity(hours int) ([]models.AuditLog, erro
 	}
 
 	var logs []models.AuditLog
-	if err := s.db.Where("action IN ? AND timestamp > ?", suspiciousActions, since).
+	if err := s.db.Scopes(OfOrganization("audit_logs", organizationID)).Where("action IN ? AND timestamp > ?", suspiciousActions, since).
 		Order("timestamp DESC").
 		Preload("User").
 		Find(&logs).Error; err != nil {
@@ -245,13 +439,13 @@ func (s *AuditService) GetSuspiciousActivity(hours int) ([]models.AuditLog, erro
 	return logs, nil
 }
 
-// GetAuditStatistics returns audit statistics
-func (s *AuditService) GetAuditStatistics(days int) (map[string]interface{}, error) {
+// GetAuditStatistics returns audit statistics of an organization
+func (s *AuditService) GetAuditStatistics(organizationID uint, days int) (map[string]interface{}, error) {
 	since := time.Now().AddDate(0, 0, -days)
 
 	// Count total actions
 	var totalActions int64
-	if err := s.db.Model(&models.AuditLog{}).
+	if err := s.db.Model(&models.AuditLog{}).Scopes(OfOrganization("audit_logs", organizationID)).
 		Where("timestamp > ?", since).
 		Count(&totalActions).Error; err != nil {
 		return nil, fmt.Errorf("failed to count total actions: %w", err)
@@ -262,7 +456,7 @@ func (s *AuditService) GetAuditStatistics(days int) (map[string]interface{}, err
 		Action string
 		Count  int64
 	}
-	if err := s.db.Model(&models.AuditLog{}).
+	if err := s.db.Model(&models.AuditLog{}).Scopes(OfOrganization("audit_logs", organizationID)).
 		Select("action, COUNT(*) as count").
 		Where("timestamp > ?", since).
 		Group("action").
@@ -272,7 +466,7 @@ func (s *AuditService) GetAuditStatistics(days int) (map[string]interface{}, err
 
 	// Count unique users
 	var uniqueUsers int64
-	if err := s.db.Model(&models.AuditLog{}).
+	if err := s.db.Model(&models.AuditLog{}).Scopes(OfOrganization("audit_logs", organizationID)).
 		Where("timestamp > ?", since).
 		Distinct("user_id").
 		Count(&uniqueUsers).Error; err != nil {
@@ -281,7 +475,7 @@ func (s *AuditService) GetAuditStatistics(days int) (map[string]interface{}, err
 
 	// Count failed logins
 	var failedLogins int64
-	if err := s.db.Model(&models.AuditLog{}).
+	if err := s.db.Model(&models.AuditLog{}).Scopes(OfOrganization("audit_logs", organizationID)).
 		Where("action = ? AND timestamp > ?", "login_failed", since).
 		Count(&failedLogins).Error; err != nil {
 		return nil, fmt.Errorf("failed to count failed logins: %w", err)
@@ -295,3 +489,55 @@ func (s *AuditService) GetAuditStatistics(days int) (map[string]interface{}, err
 		"period_days":   days,
 	}, nil
 }
+
+// LoginEvent is a successful or failed login of a user
+type LoginEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Success   bool      `json:"success"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Method    string    `json:"method,omitempty"` // How a successful login authenticated
+	Reason    string    `json:"reason,omitempty"` // Why a login failed
+}
+
+// GetLoginHistory retrieves the logins of a user, newest first. Failed
+// logins with unknown usernames belong to no user and aren't included.
+func (s *AuditService) GetLoginHistory(userID uint, page, limit int) ([]LoginEvent, int64, error) {
+	var logs []models.AuditLog
+	var total int64
+
+	offset := (page - 1) * limit
+	query := s.db.Model(&models.AuditLog{}).
+		Where("user_id = ? AND action IN ?", userID, []string{"login_success", "login_failed"}).
+		Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count logins: %w", err)
+	}
+
+	if err := query.Order("timestamp DESC, id DESC").Offset(offset).Limit(limit).Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get logins: %w", err)
+	}
+
+	events := make([]LoginEvent, len(logs))
+	for i, log := range logs {
+		var details struct {
+			Method string `json:"method"`
+			Reason string `json:"reason"`
+		}
+		if log.Details != "" {
+			// Unreadable details only lose the method or reason
+			_ = json.Unmarshal([]byte(log.Details), &details)
+		}
+		events[i] = LoginEvent{
+			Timestamp: log.Timestamp,
+			Success:   log.Action == "login_success",
+			IPAddress: log.IPAddress,
+			UserAgent: log.UserAgent,
+			Method:    details.Method,
+			Reason:    details.Reason,
+		}
+	}
+
+	return events, total, nil
+}
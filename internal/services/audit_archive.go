@@ -0,0 +1,264 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/archive"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// auditArchiveBatchSize is the largest number of audit entries in one
+// archive file
+const auditArchiveBatchSize = 10000
+
+var (
+	// ErrAuditArchiveNotFound is returned when an archive doesn't exist in
+	// the organization
+	ErrAuditArchiveNotFound = errors.New("audit archive not found")
+	// ErrAuditArchiveCorrupt is returned when an archive file is missing or
+	// doesn't match its recorded hash
+	ErrAuditArchiveCorrupt = errors.New("audit archive is missing or altered")
+)
+
+// AuditArchiveService moves audit entries older than the retention window
+// out of the database into compressed files in the archive store, and finds
+// and reads them back
+type AuditArchiveService struct {
+	db           *gorm.DB
+	store        archive.Store
+	auditService *AuditService
+	retention    time.Duration
+	interval     time.Duration
+}
+
+// NewAuditArchiveService creates a new audit archive service
+func NewAuditArchiveService(store archive.Store, auditService *AuditService, retention, interval time.Duration) *AuditArchiveService {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	return &AuditArchiveService{
+		db:           database.GetDB(),
+		store:        store,
+		auditService: auditService,
+		retention:    retention,
+		interval:     interval,
+	}
+}
+
+// Start archives expired entries immediately and then on every interval in
+// the background
+func (s *AuditArchiveService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			archived, err := s.ArchiveExpired()
+			if err != nil {
+				log.Printf("Audit archival failed: %v", err)
+			} else if archived > 0 {
+				log.Printf("Audit archival moved %d entries to %s storage", archived, s.store.Name())
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ArchiveExpired archives every organization's audit entries older than the
+// retention window and returns how many were moved
+func (s *AuditArchiveService) ArchiveExpired() (int, error) {
+	cutoff := time.Now().Add(-s.retention)
+
+	var organizations []uint
+	if err := s.db.Model(&models.AuditLog{}).
+		Where("timestamp < ?", cutoff).
+		Distinct().
+		Pluck("organization_id", &organizations).Error; err != nil {
+		return 0, fmt.Errorf("failed to get organizations with expired audit logs: %w", err)
+	}
+
+	archived := 0
+	for _, organizationID := range organizations {
+		for {
+			count, err := s.archiveBatch(organizationID, cutoff)
+			if err != nil {
+				return archived, err
+			}
+			if count == 0 {
+				break
+			}
+			archived += count
+		}
+	}
+	return archived, nil
+}
+
+// archiveBatch writes the oldest of the organization's entries before cutoff
+// to one archive file and removes them from the database once the file is
+// stored and read back intact
+func (s *AuditArchiveService) archiveBatch(organizationID uint, cutoff time.Time) (int, error) {
+	var logs []models.AuditLog
+	if err := s.db.Scopes(withUsers).
+		Where("organization_id = ? AND timestamp < ?", organizationID, cutoff).
+		Order("id ASC").
+		Limit(auditArchiveBatchSize).
+		Find(&logs).Error; err != nil {
+		return 0, fmt.Errorf("failed to get expired audit logs: %w", err)
+	}
+	if len(logs) == 0 {
+		return 0, nil
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(writer)
+	ids := make([]uint, 0, len(logs))
+	record := &models.AuditArchive{
+		OrganizationID: organizationID,
+		FirstEntryID:   logs[0].ID,
+		LastEntryID:    logs[len(logs)-1].ID,
+		FirstTimestamp: logs[0].Timestamp,
+		LastTimestamp:  logs[0].Timestamp,
+		Count:          len(logs),
+		Store:          s.store.Name(),
+	}
+	for i := range logs {
+		if err := encoder.Encode(NewAuditExportEntry(&logs[i])); err != nil {
+			return 0, fmt.Errorf("failed to encode audit log: %w", err)
+		}
+		ids = append(ids, logs[i].ID)
+
+		// IDs are assigned in order of creation, but timestamps can tie or
+		// come from clocks of several servers
+		if logs[i].Timestamp.Before(record.FirstTimestamp) {
+			record.FirstTimestamp = logs[i].Timestamp
+		}
+		if logs[i].Timestamp.After(record.LastTimestamp) {
+			record.LastTimestamp = logs[i].Timestamp
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to compress audit archive: %w", err)
+	}
+
+	data := buf.Bytes()
+	record.Size = int64(len(data))
+	record.SHA256 = sha256Hex(data)
+	record.Key = fmt.Sprintf("audit/%d/%s/%d-%d.ndjson.gz", organizationID,
+		record.FirstTimestamp.UTC().Format("2006/01"), record.FirstEntryID, record.LastEntryID)
+
+	ctx := context.Background()
+	if err := s.store.Put(ctx, record.Key, data); err != nil {
+		return 0, fmt.Errorf("failed to store audit archive: %w", err)
+	}
+	stored, err := s.store.Get(ctx, record.Key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read back audit archive: %w", err)
+	}
+	if sha256Hex(stored) != record.SHA256 {
+		return 0, fmt.Errorf("failed to store audit archive %s: %w", record.Key, ErrAuditArchiveCorrupt)
+	}
+
+	// Audit log entries only leave the database once archived, and only the
+	// maintenance role can delete them
+	err = database.AuditMaintenanceDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return fmt.Errorf("failed to create audit archive: %w", err)
+		}
+		if err := tx.Exec(`DELETE FROM audit_logs WHERE id IN ?`, ids).Error; err != nil {
+			return fmt.Errorf("failed to delete archived audit logs: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.auditService.LogAnonymousAction(organizationID, nil, "audit_archive", "audit_archive", strconv.Itoa(int(record.ID)), "", "", map[string]interface{}{
+		"first_entry_id": record.FirstEntryID,
+		"last_entry_id":  record.LastEntryID,
+		"entries":        record.Count,
+		"key":            record.Key,
+		"sha256":         record.SHA256,
+		"retention_days": int(s.retention.Hours() / 24),
+	})
+
+	return len(logs), nil
+}
+
+// GetForOrganization retrieves a page of the organization's archives holding
+// entries from from until to, oldest first. Zero times leave the range open.
+func (s *AuditArchiveService) GetForOrganization(organizationID uint, from, to time.Time, page, limit int) ([]models.AuditArchive, int64, error) {
+	var archives []models.AuditArchive
+	var total int64
+
+	offset := (page - 1) * limit
+	query := s.db.Model(&models.AuditArchive{}).Where("organization_id = ?", organizationID)
+	if !from.IsZero() {
+		query = query.Where("last_timestamp >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("first_timestamp < ?", to)
+	}
+	query = query.Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit archives: %w", err)
+	}
+
+	if err := query.Order("first_timestamp ASC, id ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&archives).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get audit archives: %w", err)
+	}
+
+	return archives, total, nil
+}
+
+// Get retrieves an archive of the organization
+func (s *AuditArchiveService) Get(organizationID, id uint) (*models.AuditArchive, error) {
+	var record models.AuditArchive
+	if err := s.db.Where("id = ? AND organization_id = ?", id, organizationID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAuditArchiveNotFound
+		}
+		return nil, fmt.Errorf("failed to get audit archive: %w", err)
+	}
+	return &record, nil
+}
+
+// Read returns the gzipped NDJSON file of an archive after checking it
+// against its recorded hash
+func (s *AuditArchiveService) Read(record *models.AuditArchive) ([]byte, error) {
+	data, err := s.store.Get(context.Background(), record.Key)
+	if err != nil {
+		if errors.Is(err, archive.ErrNotFound) {
+			return nil, ErrAuditArchiveCorrupt
+		}
+		return nil, fmt.Errorf("failed to read audit archive: %w", err)
+	}
+	if sha256Hex(data) != record.SHA256 {
+		return nil, ErrAuditArchiveCorrupt
+	}
+	return data, nil
+}
+
+// sha256Hex returns the hex SHA-256 hash of data
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/signature"
+)
+
+// AuditExportType is the JWS type of signed audit exports
+const AuditExportType = "audit-export+json"
+
+// MaxAuditExportPeriod is the longest period a signed audit export covers
+const MaxAuditExportPeriod = 366 * 24 * time.Hour
+
+// ErrInvalidAuditExport is returned when verifying something that isn't a
+// signed audit export
+var ErrInvalidAuditExport = errors.New("not a signed audit export")
+
+// AuditExportEntry is an audit log entry in an export
+type AuditExportEntry struct {
+	ID           uint            `json:"id"`
+	Timestamp    time.Time       `json:"timestamp"`
+	UserID       uint            `json:"user_id"`
+	Username     string          `json:"username,omitempty"`
+	DocumentID   *uint           `json:"document_id"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	IPAddress    string          `json:"ip_address"`
+	UserAgent    string          `json:"user_agent"`
+	Details      json.RawMessage `json:"details,omitempty"`
+}
+
+// NewAuditExportEntry converts an audit log entry for export. Details that
+// aren't JSON are kept as a string.
+func NewAuditExportEntry(log *models.AuditLog) AuditExportEntry {
+	entry := AuditExportEntry{
+		ID:           log.ID,
+		Timestamp:    log.Timestamp.UTC(),
+		UserID:       log.UserID,
+		Username:     log.User.Username,
+		DocumentID:   log.DocumentID,
+		Action:       log.Action,
+		ResourceType: log.ResourceType,
+		ResourceID:   log.ResourceID,
+		IPAddress:    log.IPAddress,
+		UserAgent:    log.UserAgent,
+	}
+	if log.Details != "" {
+		if json.Valid([]byte(log.Details)) {
+			entry.Details = json.RawMessage(log.Details)
+		} else {
+			entry.Details, _ = json.Marshal(log.Details)
+		}
+	}
+	return entry
+}
+
+// AuditExport is the signed content of an audit export: an organization's
+// audit entries from From until To, oldest first
+type AuditExport struct {
+	OrganizationID uint               `json:"organization_id"`
+	From           time.Time          `json:"from"`
+	To             time.Time          `json:"to"`
+	GeneratedAt    time.Time          `json:"generated_at"`
+	GeneratedBy    uint               `json:"generated_by"`
+	Count          int                `json:"count"`
+	Entries        []AuditExportEntry `json:"entries"`
+}
+
+// AuditExportVerification is the result of checking a signed audit export
+type AuditExportVerification struct {
+	Valid             bool       `json:"valid"`   // The signature checks out, and with CA certificates, the chain
+	Trusted           bool       `json:"trusted"` // The chain was checked against the CA certificates
+	ServerCertificate bool       `json:"server_certificate"`
+	Signer            string     `json:"signer,omitempty"`
+	Fingerprint       string     `json:"fingerprint,omitempty"`
+	SignedAt          *time.Time `json:"signed_at,omitempty"`
+	OrganizationID    uint       `json:"organization_id,omitempty"`
+	From              *time.Time `json:"from,omitempty"`
+	To                *time.Time `json:"to,omitempty"`
+	Count             int        `json:"count"`
+	SHA256            string     `json:"sha256,omitempty"` // Of the payload
+	Error             string     `json:"error,omitempty"`
+}
+
+// AuditExportService bundles an organization's audit entries for a period
+// into a JWS signed with the server's certificate, so exported evidence can
+// be verified later without the database
+type AuditExportService struct {
+	auditService *AuditService
+	signer       *signature.Signer
+	roots        *x509.CertPool
+}
+
+// NewAuditExportService creates a new audit export service signing with
+// signer. Verified exports are checked against the CA certificates in
+// roots; with nil only signatures are.
+func NewAuditExportService(auditService *AuditService, signer *signature.Signer, roots *x509.CertPool) *AuditExportService {
+	return &AuditExportService{
+		auditService: auditService,
+		signer:       signer,
+		roots:        roots,
+	}
+}
+
+// Export signs the organization's audit entries from from until to as a
+// JWS in compact serialization
+func (s *AuditExportService) Export(organizationID uint, from, to time.Time, userID uint) (string, *AuditExport, error) {
+	export := &AuditExport{
+		OrganizationID: organizationID,
+		From:           from.UTC(),
+		To:             to.UTC(),
+		GeneratedAt:    time.Now().UTC(),
+		GeneratedBy:    userID,
+		Entries:        make([]AuditExportEntry, 0),
+	}
+
+	filter := &AuditLogFilter{From: from, To: to}
+	err := s.auditService.EachAuditLog(organizationID, filter, func(log *models.AuditLog) error {
+		export.Entries = append(export.Entries, NewAuditExportEntry(log))
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	export.Count = len(export.Entries)
+
+	payload, err := json.Marshal(export)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode audit export: %w", err)
+	}
+
+	token, err := s.signer.SignJWS(payload, AuditExportType, "application/json", export.GeneratedAt)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign audit export: %w", err)
+	}
+	return token, export, nil
+}
+
+// Verify checks the signature of an audit export and, when CA certificates
+// are configured, its certificate chain as of the time of signing.
+// Signatures that don't check out are reported in the result.
+func (s *AuditExportService) Verify(token string) (*AuditExportVerification, error) {
+	verification := &AuditExportVerification{Trusted: s.roots != nil}
+
+	header, payload, certificates, err := signature.VerifyJWS(token, s.roots)
+	if err != nil {
+		verification.Error = err.Error()
+		return verification, nil
+	}
+	if header.Type != AuditExportType {
+		return nil, ErrInvalidAuditExport
+	}
+
+	var export AuditExport
+	if err := json.Unmarshal(payload, &export); err != nil {
+		return nil, ErrInvalidAuditExport
+	}
+
+	leaf := certificates[0]
+	signedAt := time.Unix(header.IssuedAt, 0).UTC()
+	verification.Valid = true
+	verification.Signer = leaf.Subject.String()
+	verification.Fingerprint = signature.Fingerprint(leaf)
+	verification.ServerCertificate = verification.Fingerprint == signature.Fingerprint(s.signer.Certificates()[0])
+	verification.SignedAt = &signedAt
+	verification.OrganizationID = export.OrganizationID
+	verification.From = &export.From
+	verification.To = &export.To
+	verification.Count = len(export.Entries)
+	verification.SHA256 = fmt.Sprintf("%x", sha256.Sum256(payload))
+	return verification, nil
+}
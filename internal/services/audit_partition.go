@@ -0,0 +1,83 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+)
+
+// AuditPartitionService maintains the monthly partitions of the audit log:
+// it creates them ahead of time and, with a retention window, drops the
+// ones entirely before it once archival emptied them
+type AuditPartitionService struct {
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewAuditPartitionService creates a new audit partition service. A zero
+// retention keeps every partition.
+func NewAuditPartitionService(retention, interval time.Duration) *AuditPartitionService {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	return &AuditPartitionService{
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start maintains the partitions on every interval in the background. The
+// migration already created them for the current months.
+func (s *AuditPartitionService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			created, dropped, err := s.Maintain()
+			if err != nil {
+				log.Printf("Audit log partition maintenance failed: %v", err)
+				continue
+			}
+			if created > 0 || dropped > 0 {
+				log.Printf("Audit log partition maintenance created %d and dropped %d partitions", created, dropped)
+			}
+		}
+	}()
+}
+
+// Maintain creates the partitions of the coming months and drops empty
+// partitions past the retention window, returning how many of each
+func (s *AuditPartitionService) Maintain() (int, int, error) {
+	created, err := database.EnsureAuditPartitions(time.Now(), database.AuditPartitionMonthsAhead)
+	if err != nil {
+		return created, 0, err
+	}
+	if s.retention <= 0 {
+		return created, 0, nil
+	}
+
+	partitions, err := database.AuditPartitions()
+	if err != nil {
+		return created, 0, err
+	}
+
+	// Partitions still holding entries wait for archival to move them
+	cutoff := time.Now().Add(-s.retention)
+	dropped := 0
+	for _, partition := range partitions {
+		if partition.To.After(cutoff) {
+			break
+		}
+		ok, err := database.DropAuditPartition(partition)
+		if err != nil {
+			return created, dropped, err
+		}
+		if ok {
+			dropped++
+		}
+	}
+	return created, dropped, nil
+}
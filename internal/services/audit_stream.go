@@ -0,0 +1,50 @@
+package services
+
+import (
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/siem"
+)
+
+// Severities of audit events streamed to a SIEM, on the CEF scale of 0 to 10
+const (
+	auditEventSeverity    = 3
+	securityEventSeverity = 7
+)
+
+// AuditStreamService streams every stored audit entry to a SIEM in near real
+// time
+type AuditStreamService struct {
+	streamer *siem.Streamer
+}
+
+// NewAuditStreamService creates a new audit stream service publishing to
+// streamer
+func NewAuditStreamService(streamer *siem.Streamer) *AuditStreamService {
+	return &AuditStreamService{streamer: streamer}
+}
+
+// AuditLogged queues an audit entry for the SIEM
+func (s *AuditStreamService) AuditLogged(entry *models.AuditLog) {
+	severity := auditEventSeverity
+	for _, action := range securityActions {
+		if entry.Action == action {
+			severity = securityEventSeverity
+			break
+		}
+	}
+
+	s.streamer.Publish(siem.Event{
+		ID:             entry.ID,
+		Timestamp:      entry.Timestamp.UTC(),
+		OrganizationID: entry.OrganizationID,
+		UserID:         entry.UserID,
+		DocumentID:     entry.DocumentID,
+		Action:         entry.Action,
+		ResourceType:   entry.ResourceType,
+		ResourceID:     entry.ResourceID,
+		IPAddress:      entry.IPAddress,
+		UserAgent:      entry.UserAgent,
+		Details:        entry.Details,
+		Severity:       severity,
+	})
+}
@@ -0,0 +1,486 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// Action represents an operation on a document
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionWrite  Action = "write"
+	ActionDelete Action = "delete"
+	ActionShare  Action = "share"
+	// Retrieving the original file, and printing; both need read access and
+	// may be withheld from readers by their explicit permissions
+	ActionDownload Action = "download"
+	ActionPrint    Action = "print"
+)
+
+// ReadOnly reports whether the action leaves the document unchanged
+func (a Action) ReadOnly() bool {
+	return a == ActionRead || a == ActionDownload || a == ActionPrint
+}
+
+// AuthorizationService evaluates document permissions. Users never have
+// access to documents of another organization. Within their organization a
+// user may perform an action on a document when any of the following applies:
+//   - the user is an admin or the document's owner
+//   - an explicit permission granted to the user, their role, their
+//     department or one of their groups allows the action. Permissions set on
+//     a folder are inherited by everything it contains; the most specific
+//     level holding permissions for the user (the document itself, then the
+//     nearest folder) overrides the levels above it
+//   - reading: the document's access level is within the user's clearance
+//   - writing: the user is a manager of the owner's department and the
+//     document is within the manager's clearance
+//
+// Readers may download and print a document unless the explicit permissions
+// at the most specific level granting them read all withhold it. Admins and
+// owners are never restricted, except that nobody may write or delete a
+// locked document.
+type AuthorizationService struct {
+	db *gorm.DB
+}
+
+// NewAuthorizationService creates a new authorization service
+func NewAuthorizationService() *AuthorizationService {
+	return &AuthorizationService{
+		db: database.GetDB(),
+	}
+}
+
+// MaxAccessLevel returns the highest access level a role may read
+func MaxAccessLevel(role models.Role) models.AccessLevel {
+	switch role {
+	case models.RoleAdmin:
+		return models.AccessTopSecret
+	case models.RoleManager:
+		return models.AccessRestricted
+	case models.RoleEmployee:
+		return models.AccessInternal
+	default:
+		return models.AccessPublic
+	}
+}
+
+// CanRead checks whether the user may read the document
+func (s *AuthorizationService) CanRead(user *models.User, doc *models.Document) (bool, error) {
+	return s.Can(user, doc, ActionRead)
+}
+
+// CanWrite checks whether the user may modify the document
+func (s *AuthorizationService) CanWrite(user *models.User, doc *models.Document) (bool, error) {
+	return s.Can(user, doc, ActionWrite)
+}
+
+// CanDelete checks whether the user may delete the document
+func (s *AuthorizationService) CanDelete(user *models.User, doc *models.Document) (bool, error) {
+	return s.Can(user, doc, ActionDelete)
+}
+
+// CanDownload checks whether the user may retrieve the original file
+func (s *AuthorizationService) CanDownload(user *models.User, doc *models.Document) (bool, error) {
+	return s.Can(user, doc, ActionDownload)
+}
+
+// CanShare checks whether the user may grant others access to the document
+func (s *AuthorizationService) CanShare(user *models.User, doc *models.Document) (bool, error) {
+	return s.Can(user, doc, ActionShare)
+}
+
+// CanShareByLink checks whether a share link created by the user may still
+// serve the document. Links carry their creator's access: the creator must
+// still be active, the document within their clearance, and they must still
+// be allowed to share and download it.
+func (s *AuthorizationService) CanShareByLink(creator *models.User, doc *models.Document) (bool, error) {
+	if !creator.IsActive || doc.AccessLevel > MaxAccessLevel(creator.Role) {
+		return false, nil
+	}
+	for _, action := range []Action{ActionShare, ActionDownload} {
+		allowed, err := s.Can(creator, doc, action)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Access sources explain why a user has access to a document
+const (
+	AccessSourceAdmin             = "admin"
+	AccessSourceOwner             = "owner"
+	AccessSourceAccessLevel       = "access_level"
+	AccessSourceDepartmentManager = "department_manager"
+	AccessSourceUserGrant         = "user_grant"
+	AccessSourceRoleGrant         = "role_grant"
+	AccessSourceDepartmentGrant   = "department_grant"
+	AccessSourceGroupGrant        = "group_grant"
+)
+
+// maxFolderDepth bounds folder hierarchy traversal
+const maxFolderDepth = 64
+
+// scopedPermission is a permission with the distance from the resource it
+// applies to: 0 for the document itself, 1 for its folder, 2 for the parent
+// folder and so on
+type scopedPermission struct {
+	models.Permission
+	Depth int
+}
+
+// documentAccess is the effective access of a user to a document
+type documentAccess struct {
+	read, write, delete, share bool
+	// Set when the explicit permissions granting read withhold downloading
+	// or printing
+	noDownload, noPrint bool
+	sources             []string
+}
+
+// allows checks whether the access permits the action
+func (a *documentAccess) allows(action Action) bool {
+	switch action {
+	case ActionRead:
+		return a.read
+	case ActionWrite:
+		return a.write
+	case ActionDelete:
+		return a.delete
+	case ActionShare:
+		return a.share
+	case ActionDownload:
+		return a.read && !a.noDownload
+	case ActionPrint:
+		return a.read && !a.noPrint
+	}
+	return false
+}
+
+// grant adds the given rights and records their source
+func (a *documentAccess) grant(source string, read, write, delete, share bool) {
+	if !read && !write && !delete && !share {
+		return
+	}
+	a.read = a.read || read
+	a.write = a.write || write
+	a.delete = a.delete || delete
+	a.share = a.share || share
+	a.sources = append(a.sources, source)
+}
+
+// grantExplicit adds the rights of the explicit permissions matching the
+// user, who belongs to the given groups. Only the most specific level holding
+// permissions for the user counts, so a document permission overrides
+// everything inherited from its folders.
+func (a *documentAccess) grantExplicit(user *models.User, groups []uint, permissions []scopedPermission) {
+	type match struct {
+		source     string
+		permission *scopedPermission
+	}
+
+	var matches []match
+	nearest := -1
+	for i := range permissions {
+		permission := &permissions[i]
+
+		var source string
+		switch {
+		case permission.UserID != nil && *permission.UserID == user.ID:
+			source = AccessSourceUserGrant
+		case permission.Role != nil && *permission.Role == user.Role:
+			source = AccessSourceRoleGrant
+		case permission.Department != nil && user.Department != "" && *permission.Department == user.Department:
+			source = AccessSourceDepartmentGrant
+		case permission.GroupID != nil && slices.Contains(groups, *permission.GroupID):
+			source = AccessSourceGroupGrant
+		default:
+			continue
+		}
+
+		matches = append(matches, match{source, permission})
+		if nearest < 0 || permission.Depth < nearest {
+			nearest = permission.Depth
+		}
+	}
+
+	var reads, downloads, prints bool
+	for _, m := range matches {
+		if m.permission.Depth != nearest {
+			continue
+		}
+		a.grant(m.source, m.permission.CanRead, m.permission.CanWrite, m.permission.CanDelete, m.permission.CanShare)
+		if m.permission.CanRead {
+			reads = true
+			downloads = downloads || allowed(m.permission.CanDownload)
+			prints = prints || allowed(m.permission.CanPrint)
+		}
+	}
+	if reads {
+		a.noDownload, a.noPrint = !downloads, !prints
+	}
+}
+
+// allowed reads an optional permission flag, which allows when unset
+func allowed(flag *bool) bool {
+	return flag == nil || *flag
+}
+
+// evaluate computes the effective access of a user, who belongs to the given
+// groups, to a document from the document owner's department and the
+// permissions applying to the document. Guests get read access at most.
+func evaluate(user *models.User, groups []uint, doc *models.Document, ownerDepartment string, permissions []scopedPermission) *documentAccess {
+	access := &documentAccess{}
+	if doc.OrganizationID != user.OrganizationID {
+		return access
+	}
+
+	if user.Role == models.RoleAdmin {
+		access.grant(AccessSourceAdmin, true, true, true, true)
+	}
+	if doc.CreatedBy == user.ID {
+		access.grant(AccessSourceOwner, true, true, true, true)
+	}
+
+	withinClearance := doc.AccessLevel <= MaxAccessLevel(user.Role)
+	if withinClearance {
+		access.grant(AccessSourceAccessLevel, true, false, false, false)
+	}
+	if withinClearance && user.Role == models.RoleManager && user.Department != "" && ownerDepartment == user.Department {
+		access.grant(AccessSourceDepartmentManager, true, true, false, false)
+	}
+
+	access.grantExplicit(user, groups, permissions)
+	if user.Role == models.RoleAdmin || doc.CreatedBy == user.ID {
+		access.noDownload, access.noPrint = false, false
+	}
+
+	// Guests only read, whatever they were granted
+	if user.Role == models.RoleGuest {
+		access.write, access.delete, access.share = false, false, false
+	}
+
+	return access
+}
+
+// Can evaluates whether the user may perform the action on the document
+func (s *AuthorizationService) Can(user *models.User, doc *models.Document, action Action) (bool, error) {
+	if doc.OrganizationID != user.OrganizationID {
+		return false, nil
+	}
+	if user.Role == models.RoleGuest && !action.ReadOnly() {
+		return false, nil
+	}
+	if doc.LockedAt != nil && (action == ActionWrite || action == ActionDelete) {
+		return false, nil
+	}
+
+	// Fast paths that need no lookups
+	if user.Role == models.RoleAdmin || doc.CreatedBy == user.ID {
+		return true, nil
+	}
+	if action == ActionRead && doc.AccessLevel <= MaxAccessLevel(user.Role) {
+		return true, nil
+	}
+
+	var ownerDepartment string
+	if action == ActionWrite && user.Role == models.RoleManager && user.Department != "" {
+		if err := s.db.Model(&models.User{}).
+			Where("id = ?", doc.CreatedBy).
+			Pluck("department", &ownerDepartment).Error; err != nil {
+			return false, fmt.Errorf("failed to get document owner: %w", err)
+		}
+	}
+
+	permissions, err := s.documentPermissions(doc, user)
+	if err != nil {
+		return false, err
+	}
+	groups, err := s.userGroups(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return evaluate(user, groups, doc, ownerDepartment, permissions).allows(action), nil
+}
+
+// CanOnFolder evaluates whether the user may perform the action on a folder
+// of their organization: admins and the folder's creator always may, anyone
+// else needs an explicit permission on the folder or one of its ancestors.
+// Guests may only read.
+func (s *AuthorizationService) CanOnFolder(user *models.User, folder *models.Folder, action Action) (bool, error) {
+	if folder.OrganizationID != user.OrganizationID {
+		return false, nil
+	}
+	if user.Role == models.RoleGuest && !action.ReadOnly() {
+		return false, nil
+	}
+	if user.Role == models.RoleAdmin || folder.CreatedBy == user.ID {
+		return true, nil
+	}
+
+	permissions, err := s.scopedPermissions(nil, &folder.ID, user)
+	if err != nil {
+		return false, err
+	}
+	groups, err := s.userGroups(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	access := &documentAccess{}
+	access.grantExplicit(user, groups, permissions)
+
+	return access.allows(action), nil
+}
+
+// userGroups returns the IDs of the groups the user belongs to
+func (s *AuthorizationService) userGroups(userID uint) ([]uint, error) {
+	var groups []uint
+	if err := s.db.Model(&models.GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user groups: %w", err)
+	}
+	return groups, nil
+}
+
+// documentPermissions loads the permissions set on the document and inherited
+// from its folders. When user is nil, permissions of all principals are returned.
+func (s *AuthorizationService) documentPermissions(doc *models.Document, user *models.User) ([]scopedPermission, error) {
+	return s.scopedPermissions(&doc.ID, doc.FolderID, user)
+}
+
+// scopedPermissions loads, in a single query, the permissions set on a
+// document and on a folder and all of its ancestors. Either may be nil.
+func (s *AuthorizationService) scopedPermissions(documentID, folderID *uint, user *models.User) ([]scopedPermission, error) {
+	var principal string
+	var principalArgs []interface{}
+	if user != nil {
+		var condition string
+		condition, principalArgs = principalCondition(user)
+		principal = " AND " + condition
+	}
+
+	query := `WITH RECURSIVE ancestors(id, parent_id, depth) AS (
+			SELECT id, parent_id, 1 FROM folders WHERE id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT folders.id, folders.parent_id, ancestors.depth + 1 FROM folders
+			JOIN ancestors ON folders.id = ancestors.parent_id
+			WHERE folders.deleted_at IS NULL AND ancestors.depth < ?
+		)
+		SELECT permissions.*, 0 AS depth FROM permissions
+		WHERE permissions.document_id = ? AND permissions.deleted_at IS NULL` + principal + `
+		UNION ALL
+		SELECT permissions.*, ancestors.depth FROM permissions
+		JOIN ancestors ON permissions.folder_id = ancestors.id
+		WHERE permissions.deleted_at IS NULL` + principal
+
+	args := []interface{}{folderID, maxFolderDepth, documentID}
+	args = append(args, principalArgs...)
+	args = append(args, principalArgs...)
+
+	var permissions []scopedPermission
+	if err := s.db.Raw(query, args...).Scan(&permissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+// AccessibleDocuments restricts a documents query to rows of the user's
+// organization the user may read: documents within the user's access level,
+// their own documents, and documents shared with the user, their role, their
+// department or their groups
+func AccessibleDocuments(user *models.User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("documents.organization_id = ?", user.OrganizationID)
+		if user.Role == models.RoleAdmin {
+			return db
+		}
+
+		shared, args := sharedCondition(user)
+		args = append([]interface{}{MaxAccessLevel(user.Role), user.ID}, args...)
+
+		return db.Where("documents.access_level <= ? OR documents.created_by = ? OR "+shared, args...)
+	}
+}
+
+// UserContext returns a context whose queries run for the user under
+// row-level security, for requests and work done on the user's behalf
+func UserContext(ctx context.Context, user *models.User) context.Context {
+	return database.ContextWithPrincipal(ctx, principalOf(user))
+}
+
+// principalOf returns the row-level security principal of a user
+func principalOf(user *models.User) database.Principal {
+	return database.Principal{
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+		Role:           string(user.Role),
+		Department:     user.Department,
+		MaxAccessLevel: int(MaxAccessLevel(user.Role)),
+		Unrestricted:   user.Role == models.RoleAdmin,
+	}
+}
+
+// sharedWithUser selects documents of the user's organization shared with
+// the user, their role, their department or their groups
+func sharedWithUser(db *gorm.DB, user *models.User) *gorm.DB {
+	shared, args := sharedCondition(user)
+	return db.Model(&models.Document{}).
+		Scopes(OfOrganization("documents", user.OrganizationID)).Where(shared, args...)
+}
+
+// sharedCondition matches documents readable through explicit permissions. A
+// document is shared when its own permissions for the user's principals allow
+// reading or, lacking those, when the nearest folder above it holding such
+// permissions does. Readable folders are found by walking down from folders
+// granting read, stopping at subfolders with permissions of their own.
+func sharedCondition(user *models.User) (string, []interface{}) {
+	principal, principalArgs := principalCondition(user)
+
+	condition := `(documents.id IN (
+			SELECT document_id FROM permissions
+			WHERE document_id IS NOT NULL AND can_read = true AND deleted_at IS NULL AND ` + principal + `
+		) OR (documents.id NOT IN (
+			SELECT document_id FROM permissions
+			WHERE document_id IS NOT NULL AND deleted_at IS NULL AND ` + principal + `
+		) AND documents.folder_id IN (
+			WITH RECURSIVE readable_folders(id, depth) AS (
+				SELECT folder_id, 1 FROM permissions
+				WHERE folder_id IS NOT NULL AND can_read = true AND deleted_at IS NULL AND ` + principal + `
+				UNION
+				SELECT folders.id, readable_folders.depth + 1 FROM folders
+				JOIN readable_folders ON folders.parent_id = readable_folders.id
+				WHERE folders.deleted_at IS NULL AND readable_folders.depth < ? AND folders.id NOT IN (
+					SELECT folder_id FROM permissions
+					WHERE folder_id IS NOT NULL AND deleted_at IS NULL AND ` + principal + `
+				)
+			)
+			SELECT id FROM readable_folders
+		)))`
+
+	var args []interface{}
+	args = append(args, principalArgs...)
+	args = append(args, principalArgs...)
+	args = append(args, principalArgs...)
+	args = append(args, maxFolderDepth)
+	args = append(args, principalArgs...)
+
+	return condition, args
+}
+
+// principalCondition matches permissions granted to the user, their role,
+// their department or their groups
+func principalCondition(user *models.User) (string, []interface{}) {
+	const group = "group_id IN (SELECT group_id FROM group_members WHERE user_id = ?)"
+	if user.Department == "" {
+		return "(user_id = ? OR role = ? OR " + group + ")", []interface{}{user.ID, user.Role, user.ID}
+	}
+	return "(user_id = ? OR role = ? OR department = ? OR " + group + ")", []interface{}{user.ID, user.Role, user.Department, user.ID}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+func TestCanRefusesGuestChanges(t *testing.T) {
+	s := &AuthorizationService{}
+	guest := &models.User{ID: 1, Role: models.RoleGuest, OrganizationID: 1}
+	// Even a document the guest owns
+	doc := &models.Document{ID: 1, CreatedBy: guest.ID, OrganizationID: 1}
+
+	for _, action := range []Action{ActionWrite, ActionDelete, ActionShare} {
+		allowed, err := s.Can(guest, doc, action)
+		if err != nil {
+			t.Fatalf("Can(%s): %v", action, err)
+		}
+		if allowed {
+			t.Errorf("guest may %s their own document", action)
+		}
+	}
+
+	allowed, err := s.Can(guest, doc, ActionRead)
+	if err != nil {
+		t.Fatalf("Can(read): %v", err)
+	}
+	if !allowed {
+		t.Error("guest may not read their own document")
+	}
+}
+
+func TestCanOnFolderRefusesGuestChanges(t *testing.T) {
+	s := &AuthorizationService{}
+	guest := &models.User{ID: 1, Role: models.RoleGuest, OrganizationID: 1}
+	folder := &models.Folder{ID: 1, CreatedBy: guest.ID, OrganizationID: 1}
+
+	for _, action := range []Action{ActionWrite, ActionDelete, ActionShare} {
+		allowed, err := s.CanOnFolder(guest, folder, action)
+		if err != nil {
+			t.Fatalf("CanOnFolder(%s): %v", action, err)
+		}
+		if allowed {
+			t.Errorf("guest may %s their own folder", action)
+		}
+	}
+}
+
+func TestEvaluateLimitsGuestGrantsToReading(t *testing.T) {
+	guest := &models.User{ID: 1, Role: models.RoleGuest, OrganizationID: 1}
+	doc := &models.Document{ID: 1, CreatedBy: 2, OrganizationID: 1, AccessLevel: models.AccessConfidential}
+
+	userID := guest.ID
+	role := models.RoleGuest
+	grants := []scopedPermission{
+		{Permission: models.Permission{UserID: &userID, CanRead: true, CanWrite: true, CanDelete: true, CanShare: true}},
+		{Permission: models.Permission{Role: &role, CanRead: true, CanWrite: true, CanDelete: true, CanShare: true}},
+	}
+
+	access := evaluate(guest, nil, doc, "", grants)
+	if !access.allows(ActionRead) || !access.allows(ActionDownload) {
+		t.Error("guest granted read may not read the document")
+	}
+	for _, action := range []Action{ActionWrite, ActionDelete, ActionShare} {
+		if access.allows(action) {
+			t.Errorf("guest granted %s may %s the document", action, action)
+		}
+	}
+}
+
+func TestCanShareByLinkFollowsTheCreator(t *testing.T) {
+	s := &AuthorizationService{}
+	employee := &models.User{ID: 1, Role: models.RoleEmployee, OrganizationID: 1, IsActive: true}
+	inactive := &models.User{ID: 1, Role: models.RoleEmployee, OrganizationID: 1}
+	guest := &models.User{ID: 1, Role: models.RoleGuest, OrganizationID: 1, IsActive: true}
+	admin := &models.User{ID: 2, Role: models.RoleAdmin, OrganizationID: 1, IsActive: true}
+
+	internal := &models.Document{ID: 1, CreatedBy: 1, OrganizationID: 1, AccessLevel: models.AccessInternal}
+	// Raised above the creator's clearance after the link was created
+	restricted := &models.Document{ID: 1, CreatedBy: 1, OrganizationID: 1, AccessLevel: models.AccessRestricted}
+	otherOrganization := &models.Document{ID: 1, CreatedBy: 1, OrganizationID: 2, AccessLevel: models.AccessInternal}
+
+	tests := []struct {
+		name    string
+		creator *models.User
+		doc     *models.Document
+		want    bool
+	}{
+		{"owner within clearance", employee, internal, true},
+		{"deactivated owner", inactive, internal, false},
+		{"owner beyond clearance", employee, restricted, false},
+		{"owner turned guest", guest, internal, false},
+		{"document of another organization", employee, otherOrganization, false},
+		{"admin", admin, restricted, true},
+	}
+	for _, tt := range tests {
+		allowed, err := s.CanShareByLink(tt.creator, tt.doc)
+		if err != nil {
+			t.Fatalf("%s: CanShareByLink: %v", tt.name, err)
+		}
+		if allowed != tt.want {
+			t.Errorf("%s: CanShareByLink = %v, want %v", tt.name, allowed, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/tagging"
+	"gorm.io/gorm"
+)
+
+// maxTaggingCandidates bounds the known tags sent to taggers
+const maxTaggingCandidates = 1000
+
+// ErrSuggestionReviewed is returned when a suggestion was already accepted or rejected
+var ErrSuggestionReviewed = errors.New("suggestion has already been reviewed")
+
+// AutoTagService runs uploaded document text through a tagger and stores the
+// proposed tags and categories as suggestions for users to confirm. With
+// auto-apply enabled, suggestions scoring at least the minimum score are
+// applied right away.
+type AutoTagService struct {
+	db              *gorm.DB
+	documentService *DocumentService
+	tagger          tagging.Tagger
+	autoApply       bool
+	minScore        float64
+}
+
+// NewAutoTagService creates a new auto-tagging service. tagger may be nil when
+// auto-tagging is disabled; stored suggestions can still be reviewed.
+func NewAutoTagService(documentService *DocumentService, tagger tagging.Tagger, autoApply bool, minScore float64) *AutoTagService {
+	return &AutoTagService{
+		db:              database.GetDB(),
+		documentService: documentService,
+		tagger:          tagger,
+		autoApply:       autoApply,
+		minScore:        minScore,
+	}
+}
+
+// IndexDocument analyzes newly uploaded content. Metadata-only updates carry
+// no content and are ignored.
+func (s *AutoTagService) IndexDocument(ctx context.Context, doc *models.Document, content string) error {
+	if s.tagger == nil || content == "" {
+		return nil
+	}
+
+	// The indexed snapshot may be stale by now
+	current, err := s.documentService.GetByID(ctx, doc.ID)
+	if err != nil {
+		return err
+	}
+
+	input := &tagging.Input{
+		Text: strings.Join([]string{current.Title, current.Description, content}, "\n"),
+	}
+	if err := s.db.Model(&models.Tag{}).Order("usage_count DESC").Limit(maxTaggingCandidates).Pluck("name", &input.Tags).Error; err != nil {
+		return fmt.Errorf("failed to get tags: %w", err)
+	}
+	if err := s.db.Model(&models.Category{}).Scopes(OfOrganization("categories", current.OrganizationID)).Order("name ASC").Pluck("name", &input.Categories).Error; err != nil {
+		return fmt.Errorf("failed to get categories: %w", err)
+	}
+
+	suggested, err := s.tagger.Suggest(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to suggest tags: %w", err)
+	}
+
+	suggestions, err := s.store(current, suggested)
+	if err != nil {
+		return err
+	}
+
+	if s.autoApply {
+		s.apply(ctx, current, suggestions)
+	}
+
+	return nil
+}
+
+// DeleteDocument keeps suggestions of trashed documents so they survive a restore
+func (s *AutoTagService) DeleteDocument(ctx context.Context, id uint) error {
+	return nil
+}
+
+// store replaces the pending suggestions of a document, skipping values the
+// document already has and values reviewed before
+func (s *AutoTagService) store(doc *models.Document, suggested []tagging.Suggestion) ([]models.TagSuggestion, error) {
+	var reviewed []models.TagSuggestion
+	if err := s.db.Where("document_id = ? AND status <> ?", doc.ID, models.SuggestionPending).Find(&reviewed).Error; err != nil {
+		return nil, fmt.Errorf("failed to get suggestions: %w", err)
+	}
+
+	skip := make(map[string]bool)
+	for _, suggestion := range reviewed {
+		skip[string(suggestion.Kind)+":"+suggestion.Value] = true
+	}
+	for _, tag := range ParseTags(doc.Tags) {
+		skip[string(models.SuggestionTag)+":"+tag] = true
+	}
+	if doc.Category != "" {
+		skip[string(models.SuggestionCategory)+":"+doc.Category] = true
+	}
+
+	suggestions := make([]models.TagSuggestion, 0, len(suggested))
+	for _, suggestion := range suggested {
+		value := strings.TrimSpace(suggestion.Value)
+		key := string(suggestion.Kind) + ":" + value
+		if value == "" || len(value) > maxTagLength || skip[key] {
+			continue
+		}
+		skip[key] = true
+
+		suggestions = append(suggestions, models.TagSuggestion{
+			DocumentID: doc.ID,
+			Kind:       suggestion.Kind,
+			Value:      value,
+			Score:      suggestion.Score,
+			Source:     s.tagger.Name(),
+			Status:     models.SuggestionPending,
+		})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ? AND status = ?", doc.ID, models.SuggestionPending).Delete(&models.TagSuggestion{}).Error; err != nil {
+			return err
+		}
+		if len(suggestions) == 0 {
+			return nil
+		}
+		return tx.Create(&suggestions).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store suggestions: %w", err)
+	}
+
+	return suggestions, nil
+}
+
+// apply accepts the confident suggestions on behalf of the document's owner.
+// A category is only set when the document has none.
+func (s *AutoTagService) apply(ctx context.Context, doc *models.Document, suggestions []models.TagSuggestion) {
+	var best *models.TagSuggestion
+	for i := range suggestions {
+		suggestion := &suggestions[i]
+		if suggestion.Score < s.minScore {
+			continue
+		}
+
+		switch suggestion.Kind {
+		case models.SuggestionTag:
+			if err := s.accept(ctx, doc, suggestion, nil); err != nil {
+				log.Printf("Failed to auto-apply tag %q to document %d: %v", suggestion.Value, doc.ID, err)
+			}
+		case models.SuggestionCategory:
+			if doc.Category == "" && (best == nil || suggestion.Score > best.Score) {
+				best = suggestion
+			}
+		}
+	}
+
+	if best != nil {
+		if err := s.accept(ctx, doc, best, nil); err != nil {
+			log.Printf("Failed to auto-apply category %q to document %d: %v", best.Value, doc.ID, err)
+		}
+	}
+}
+
+// GetSuggestions retrieves the suggestions of a document, optionally limited
+// to a status, best first
+func (s *AutoTagService) GetSuggestions(documentID uint, status models.SuggestionStatus) ([]models.TagSuggestion, error) {
+	query := s.db.Where("document_id = ?", documentID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var suggestions []models.TagSuggestion
+	if err := query.Order("kind ASC, score DESC, id ASC").Find(&suggestions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get suggestions: %w", err)
+	}
+	return suggestions, nil
+}
+
+// GetSuggestion retrieves a suggestion of a document
+func (s *AutoTagService) GetSuggestion(documentID, id uint) (*models.TagSuggestion, error) {
+	var suggestion models.TagSuggestion
+	if err := s.db.Where("document_id = ?", documentID).First(&suggestion, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get suggestion: %w", err)
+	}
+	return &suggestion, nil
+}
+
+// Accept applies a pending suggestion to the document
+func (s *AutoTagService) Accept(ctx context.Context, doc *models.Document, suggestion *models.TagSuggestion, userID uint) error {
+	if suggestion.Status != models.SuggestionPending {
+		return ErrSuggestionReviewed
+	}
+	return s.accept(ctx, doc, suggestion, &userID)
+}
+
+// accept applies a suggestion and marks it accepted. A nil reviewer means the
+// suggestion was applied automatically.
+func (s *AutoTagService) accept(ctx context.Context, doc *models.Document, suggestion *models.TagSuggestion, reviewerID *uint) error {
+	switch suggestion.Kind {
+	case models.SuggestionTag:
+		userID := doc.CreatedBy
+		if reviewerID != nil {
+			userID = *reviewerID
+		}
+		if err := s.documentService.AddTags(ctx, doc, []string{suggestion.Value}, userID); err != nil {
+			return err
+		}
+	case models.SuggestionCategory:
+		doc.Category = suggestion.Value
+		if err := s.documentService.Update(ctx, doc); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown suggestion kind: %s", suggestion.Kind)
+	}
+
+	return s.review(suggestion, models.SuggestionAccepted, reviewerID)
+}
+
+// Reject dismisses a pending suggestion; it will not be suggested again
+func (s *AutoTagService) Reject(suggestion *models.TagSuggestion, userID uint) error {
+	if suggestion.Status != models.SuggestionPending {
+		return ErrSuggestionReviewed
+	}
+	return s.review(suggestion, models.SuggestionRejected, &userID)
+}
+
+// review records the outcome of a suggestion
+func (s *AutoTagService) review(suggestion *models.TagSuggestion, status models.SuggestionStatus, reviewerID *uint) error {
+	now := time.Now()
+	suggestion.Status = status
+	suggestion.AutoApplied = reviewerID == nil
+	suggestion.ReviewedBy = reviewerID
+	suggestion.ReviewedAt = &now
+
+	if err := s.db.Model(suggestion).Select("status", "auto_applied", "reviewed_by", "reviewed_at").Updates(suggestion).Error; err != nil {
+		return fmt.Errorf("failed to update suggestion: %w", err)
+	}
+	return nil
+}
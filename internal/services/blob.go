@@ -0,0 +1,299 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// blobGracePeriod is how long an unreferenced blob is kept before garbage
+// collection removes it, so uploads still being committed keep their blob
+const blobGracePeriod = time.Hour
+
+// ErrBlobNotFound is returned when a referenced blob no longer exists
+var ErrBlobNotFound = errors.New("blob not found")
+
+// storeBlob encrypts and stores content unless a blob with the same content
+// already exists, and returns the blob. The blob is not referenced yet; the
+// caller retains it in the transaction creating the referencing rows.
+func (s *DocumentService) storeBlob(content []byte) (*models.Blob, error) {
+	hash := s.hasher.SHA256(content)
+
+	var blob models.Blob
+	err := s.db.Where("hash = ?", hash).First(&blob).Error
+	if err == nil {
+		return &blob, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+
+	// Encrypt the content with its own data key
+	ciphertext, dataKey, keyVersion, err := s.envelope.Seal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt document: %w", err)
+	}
+
+	path, err := s.storage.SaveBlob(hash, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store document: %w", err)
+	}
+
+	blob = models.Blob{
+		Hash:       hash,
+		Path:       path,
+		Size:       int64(len(content)),
+		DataKey:    dataKey,
+		KeyVersion: keyVersion,
+	}
+	if s.scanning {
+		blob.ScanStatus = models.ScanPending
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&blob)
+	if result.Error != nil {
+		s.storage.Delete(path)
+		return nil, fmt.Errorf("failed to create blob: %w", result.Error)
+	}
+
+	// Another upload stored the same content first; use its blob
+	if result.RowsAffected == 0 {
+		s.storage.Delete(path)
+		blob = models.Blob{}
+		if err := s.db.Where("hash = ?", hash).First(&blob).Error; err != nil {
+			return nil, fmt.Errorf("failed to get blob: %w", err)
+		}
+	}
+
+	return &blob, nil
+}
+
+// retainBlobs adds a reference to the blob of each hash, once per occurrence
+func retainBlobs(tx *gorm.DB, hashes ...string) error {
+	for hash, count := range countHashes(hashes) {
+		result := tx.Model(&models.Blob{}).
+			Where("hash = ?", hash).
+			Updates(map[string]interface{}{
+				"ref_count":  gorm.Expr("ref_count + ?", count),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: %s", ErrBlobNotFound, hash)
+		}
+	}
+	return nil
+}
+
+// releaseBlobs drops a reference to the blob of each hash, once per occurrence
+func releaseBlobs(tx *gorm.DB, hashes ...string) error {
+	for hash, count := range countHashes(hashes) {
+		if err := tx.Model(&models.Blob{}).
+			Where("hash = ?", hash).
+			Updates(map[string]interface{}{
+				"ref_count":  gorm.Expr("GREATEST(ref_count - ?, 0)", count),
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countHashes counts the occurrences of each non-empty hash
+func countHashes(hashes []string) map[string]int {
+	counts := make(map[string]int, len(hashes))
+	for _, hash := range hashes {
+		if hash != "" {
+			counts[hash]++
+		}
+	}
+	return counts
+}
+
+// deleteUnreferencedBlobs removes the blobs of the given hashes that nothing
+// refers to anymore, along with their files
+func (s *DocumentService) deleteUnreferencedBlobs(hashes []string) {
+	for hash := range countHashes(hashes) {
+		var blob models.Blob
+		result := s.db.Clauses(clause.Returning{}).
+			Where("hash = ? AND ref_count = 0", hash).
+			Delete(&blob)
+		if result.Error != nil {
+			log.Printf("Failed to delete blob %s: %v", hash, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := s.storage.Delete(blob.Path); err != nil {
+			log.Printf("Failed to delete stored file %s: %v", blob.Path, err)
+		}
+
+		s.releaseRenditions(hash)
+	}
+}
+
+// releaseRenditions removes the renditions of deleted source content. Their
+// blobs are left unreferenced for garbage collection.
+func (s *DocumentService) releaseRenditions(sourceHash string) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var renditions []models.Rendition
+		if err := tx.Clauses(clause.Returning{}).
+			Where("source_hash = ?", sourceHash).
+			Delete(&renditions).Error; err != nil {
+			return err
+		}
+
+		hashes := make([]string, 0, len(renditions))
+		for _, rendition := range renditions {
+			hashes = append(hashes, rendition.BlobHash)
+		}
+		return releaseBlobs(tx, hashes...)
+	})
+	if err != nil {
+		log.Printf("Failed to release renditions of blob %s: %v", sourceHash, err)
+	}
+}
+
+// CollectGarbage removes blobs that have been unreferenced for longer than
+// the grace period, such as those left by failed uploads, and returns how many
+// were removed
+func (s *DocumentService) CollectGarbage() (int, error) {
+	var hashes []string
+	if err := s.db.Model(&models.Blob{}).
+		Where("ref_count = 0 AND updated_at < ?", time.Now().Add(-blobGracePeriod)).
+		Pluck("hash", &hashes).Error; err != nil {
+		return 0, fmt.Errorf("failed to get unreferenced blobs: %w", err)
+	}
+
+	s.deleteUnreferencedBlobs(hashes)
+
+	return len(hashes), nil
+}
+
+// blobReference is a document or version row pointing at stored content
+type blobReference struct {
+	ID          uint
+	FilePath    string
+	FileSize    int64
+	DataKey     string
+	KeyVersion  int
+	IsEncrypted bool
+}
+
+// MigrateBlobs moves files stored before content-addressable storage into
+// blobs. Rows with the same content hash are pointed at a single file and the
+// duplicates are deleted.
+func (s *DocumentService) MigrateBlobs() error {
+	db := database.AsSystem(s.db)
+
+	var hashes []string
+	if err := db.Raw(`
+		SELECT file_hash FROM documents WHERE file_path <> '' AND file_hash <> ''
+		UNION
+		SELECT file_hash FROM document_versions WHERE file_path <> '' AND file_hash <> ''
+		EXCEPT
+		SELECT hash FROM blobs`).Scan(&hashes).Error; err != nil {
+		return fmt.Errorf("failed to get documents without blobs: %w", err)
+	}
+
+	for _, hash := range hashes {
+		if err := s.migrateBlob(db, hash); err != nil {
+			return fmt.Errorf("failed to migrate files with hash %s: %w", hash, err)
+		}
+	}
+
+	if len(hashes) > 0 {
+		log.Printf("Migrated %d stored files to blobs", len(hashes))
+	}
+
+	return nil
+}
+
+// migrateBlob creates the blob of a content hash from the rows referencing it
+func (s *DocumentService) migrateBlob(db *gorm.DB, hash string) error {
+	var documents, versions []blobReference
+	if err := db.Unscoped().Model(&models.Document{}).
+		Select("id, file_path, file_size, data_key, key_version, is_encrypted").
+		Where("file_hash = ? AND file_path <> ''", hash).
+		Scan(&documents).Error; err != nil {
+		return err
+	}
+	if err := db.Unscoped().Model(&models.DocumentVersion{}).
+		Select("id, file_path, file_size, data_key, key_version, TRUE AS is_encrypted").
+		Where("file_hash = ? AND file_path <> ''", hash).
+		Scan(&versions).Error; err != nil {
+		return err
+	}
+
+	references := append(documents, versions...)
+	if len(references) == 0 {
+		return nil
+	}
+
+	// Prefer an encrypted file as the shared copy
+	source := references[0]
+	for _, reference := range references {
+		if reference.IsEncrypted && reference.DataKey != "" {
+			source = reference
+			break
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		blob := &models.Blob{
+			Hash:       hash,
+			Path:       source.FilePath,
+			Size:       source.FileSize,
+			DataKey:    source.DataKey,
+			KeyVersion: source.KeyVersion,
+			RefCount:   len(references),
+		}
+		if err := tx.Create(blob).Error; err != nil {
+			return err
+		}
+
+		fields := map[string]interface{}{
+			"file_path":   source.FilePath,
+			"data_key":    source.DataKey,
+			"key_version": source.KeyVersion,
+		}
+		if err := tx.Model(&models.DocumentVersion{}).Unscoped().
+			Where("file_hash = ? AND file_path <> ''", hash).
+			UpdateColumns(fields).Error; err != nil {
+			return err
+		}
+
+		fields["is_encrypted"] = source.DataKey != ""
+		return tx.Model(&models.Document{}).Unscoped().
+			Where("file_hash = ? AND file_path <> ''", hash).
+			UpdateColumns(fields).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// Delete the duplicate files now that every row shares one
+	deleted := map[string]bool{source.FilePath: true}
+	for _, reference := range references {
+		if deleted[reference.FilePath] {
+			continue
+		}
+		deleted[reference.FilePath] = true
+
+		if err := s.storage.Delete(reference.FilePath); err != nil {
+			log.Printf("Failed to delete duplicate file %s: %v", reference.FilePath, err)
+		}
+	}
+
+	return nil
+}
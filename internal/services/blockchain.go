@@ -0,0 +1,648 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/blockchain"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// ErrBlockchainTransactionNotFound is returned for unknown transactions and
+// transactions of documents in other organizations
+var ErrBlockchainTransactionNotFound = errors.New("blockchain transaction not found")
+
+// ChainVerification is the result of checking the chain's integrity
+type ChainVerification struct {
+	Valid             bool         `json:"valid"`
+	Blocks            int          `json:"blocks"`
+	TotalTransactions int          `json:"total_transactions"`
+	Consensus         string       `json:"consensus"`
+	Difficulty        int          `json:"difficulty"`
+	LatestBlockHash   string       `json:"latest_block_hash"`
+	Pending           int          `json:"pending"` // Transactions waiting to be mined
+	Anchors           *AnchorCheck `json:"anchors"`
+}
+
+// BlockchainTransaction is a transaction with the block it was mined in
+type BlockchainTransaction struct {
+	blockchain.Transaction
+	BlockIndex int64  `json:"block_index"`
+	BlockHash  string `json:"block_hash"`
+}
+
+// BlockchainService records document operations on the private blockchain.
+// Operations wait in a pool of pending transactions until the background
+// miner batches them into a block. The chain does its own locking; mu guards
+// the pool and the creation of blockchain records.
+type BlockchainService struct {
+	db                   *gorm.DB
+	chain                *blockchain.Blockchain
+	mineInterval         time.Duration
+	maxBlockTransactions int
+
+	mu      sync.Mutex
+	pending []blockchain.Transaction
+	wake    chan struct{}
+}
+
+// NewBlockchainService creates a new blockchain service, loading the chain
+// and the pending transactions stored in the database and validating the
+// chain. A database without blocks starts a fresh chain. Pending
+// transactions are mined every mineInterval, or as soon as
+// maxBlockTransactions of them are waiting. The mining difficulty is
+// adjusted towards targetMiningTime per block, up to maxDifficulty. With an
+// authority, blocks are signed by it instead of mined.
+func NewBlockchainService(mineInterval time.Duration, maxBlockTransactions int, targetMiningTime time.Duration, maxDifficulty int, authority *blockchain.Authority) (*BlockchainService, error) {
+	if mineInterval <= 0 {
+		mineInterval = 10 * time.Second
+	}
+	if maxBlockTransactions <= 0 {
+		maxBlockTransactions = 100
+	}
+
+	s := &BlockchainService{
+		db:                   database.GetDB(),
+		mineInterval:         mineInterval,
+		maxBlockTransactions: maxBlockTransactions,
+		wake:                 make(chan struct{}, 1),
+	}
+
+	chain, err := s.load(authority)
+	if err != nil {
+		return nil, err
+	}
+	s.chain = chain
+	s.chain.SetDifficultyTarget(targetMiningTime, maxDifficulty)
+
+	if s.pending, err = s.loadPending(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// load restores the chain from its stored blocks, storing the genesis block
+// of a new chain when there are none
+func (s *BlockchainService) load(authority *blockchain.Authority) (*blockchain.Blockchain, error) {
+	var rows []models.BlockchainBlock
+	if err := s.db.Order("block_number").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get blockchain blocks: %w", err)
+	}
+
+	if len(rows) == 0 {
+		chain := blockchain.NewBlockchain(authority)
+		genesis := chain.LatestBlock()
+		if err := s.saveBlock(s.db, &genesis); err != nil {
+			return nil, err
+		}
+		if _, err := s.signedFrom(authority, nil); err != nil {
+			return nil, err
+		}
+		return chain, nil
+	}
+
+	blocks := make([]blockchain.Block, 0, len(rows))
+	for _, row := range rows {
+		var block blockchain.Block
+		if err := json.Unmarshal([]byte(row.Data), &block); err != nil {
+			return nil, fmt.Errorf("failed to decode block %d: %w", row.BlockNumber, err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	signedFrom, err := s.signedFrom(authority, blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := blockchain.LoadBlockchain(blocks, authority, signedFrom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blockchain: %w", err)
+	}
+	return chain, nil
+}
+
+// signedFrom returns the index of the first block the authority must have
+// signed. The first time the authority seals blocks it takes over from the
+// next block, or from the first signed one, and the index is recorded so a
+// chain later rewritten with mined blocks is refused.
+func (s *BlockchainService) signedFrom(authority *blockchain.Authority, blocks []blockchain.Block) (int64, error) {
+	if authority == nil {
+		return 0, nil
+	}
+
+	var activation models.BlockchainAuthority
+	err := s.db.Order("id").First(&activation).Error
+	if err == nil {
+		return activation.BlockNumber, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("failed to get blockchain authority: %w", err)
+	}
+
+	activation = models.BlockchainAuthority{
+		KeyID:       authority.KeyID(),
+		BlockNumber: int64(len(blocks)),
+	}
+	for _, block := range blocks {
+		if block.Signature != "" {
+			activation.BlockNumber = block.Index
+			break
+		}
+	}
+	if err := s.db.Create(&activation).Error; err != nil {
+		return 0, fmt.Errorf("failed to record blockchain authority: %w", err)
+	}
+	return activation.BlockNumber, nil
+}
+
+// saveBlock stores a mined block
+func (s *BlockchainService) saveBlock(tx *gorm.DB, block *blockchain.Block) error {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("failed to encode block: %w", err)
+	}
+
+	row := &models.BlockchainBlock{
+		BlockNumber: block.Index,
+		Hash:        block.Hash,
+		Data:        string(data),
+	}
+	if err := tx.Create(row).Error; err != nil {
+		return fmt.Errorf("failed to save block: %w", err)
+	}
+	return nil
+}
+
+// RecordDocumentAction queues a transaction for a document operation to be
+// mined into the next block and returns its ID. The blockchain record is
+// created once the transaction is mined.
+func (s *BlockchainService) RecordDocumentAction(documentID, userID uint, action string, data map[string]interface{}) (string, error) {
+	tx, err := NewDocumentTransaction(documentID, userID, action, data)
+	if err != nil {
+		return "", err
+	}
+	if err := s.QueueTransaction(tx); err != nil {
+		return "", err
+	}
+	return tx.ID, nil
+}
+
+// NewDocumentTransaction builds the transaction of a document operation
+// without queueing it, for changes that write it to the outbox
+func NewDocumentTransaction(documentID, userID uint, action string, data map[string]interface{}) (blockchain.Transaction, error) {
+	tx := blockchain.CreateDocumentTransaction(blockchain.GenerateTransactionID(documentID, userID, action), documentID, userID, action, data)
+
+	// Store the data as it will be read back, so the transaction hashes the
+	// same before and after a restart
+	encoded, err := json.Marshal(tx)
+	if err != nil {
+		return blockchain.Transaction{}, fmt.Errorf("failed to encode blockchain transaction: %w", err)
+	}
+	tx = blockchain.Transaction{}
+	if err := json.Unmarshal(encoded, &tx); err != nil {
+		return blockchain.Transaction{}, fmt.Errorf("failed to decode blockchain transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// QueueTransaction queues a transaction to be mined into the next block. A
+// transaction already queued or mined isn't queued again, so the outbox can
+// deliver one more than once.
+func (s *BlockchainService) QueueTransaction(tx blockchain.Transaction) error {
+	encoded, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("failed to encode blockchain transaction: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var existing int64
+	if err := s.db.Model(&models.BlockchainPendingTransaction{}).Where("transaction_id = ?", tx.ID).Count(&existing).Error; err != nil {
+		return fmt.Errorf("failed to check blockchain transaction: %w", err)
+	}
+	if existing == 0 {
+		if err := s.db.Model(&models.BlockchainRecord{}).Where("transaction_id = ?", tx.ID).Count(&existing).Error; err != nil {
+			return fmt.Errorf("failed to check blockchain transaction: %w", err)
+		}
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	if err := s.db.Create(&models.BlockchainPendingTransaction{
+		TransactionID: tx.ID,
+		Data:          string(encoded),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to queue blockchain transaction: %w", err)
+	}
+
+	s.pending = append(s.pending, tx)
+	if len(s.pending) >= s.maxBlockTransactions {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// LatestBlock returns the latest block of the chain
+func (s *BlockchainService) LatestBlock() blockchain.Block {
+	return s.chain.LatestBlock()
+}
+
+// GetBlocks returns a page of the chain's blocks, newest first, with the
+// total number of blocks. Transactions of documents outside the organization
+// keep only their ID, time and hash, which still lets the blocks be checked.
+func (s *BlockchainService) GetBlocks(ctx context.Context, organizationID uint, page, limit int) ([]blockchain.Block, int64, error) {
+	total := s.chain.Len()
+	end := int64(total - (page-1)*limit)
+	window := s.chain.Blocks(end-int64(limit), end)
+
+	blocks := make([]blockchain.Block, 0, len(window))
+	for i := len(window) - 1; i >= 0; i-- {
+		block := window[i]
+		block.Transactions = append([]blockchain.Transaction(nil), block.Transactions...)
+		blocks = append(blocks, block)
+	}
+
+	var documentIDs []uint
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			if tx.DocumentID != 0 {
+				documentIDs = append(documentIDs, tx.DocumentID)
+			}
+		}
+	}
+	if len(documentIDs) == 0 {
+		return blocks, int64(total), nil
+	}
+
+	var ownIDs []uint
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.Document{}).
+		Where("id IN ? AND organization_id = ?", documentIDs, organizationID).
+		Pluck("id", &ownIDs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get block documents: %w", err)
+	}
+	own := make(map[uint]bool, len(ownIDs))
+	for _, id := range ownIDs {
+		own[id] = true
+	}
+
+	for i := range blocks {
+		for j, tx := range blocks[i].Transactions {
+			if tx.DocumentID != 0 && !own[tx.DocumentID] {
+				blocks[i].Transactions[j] = blockchain.Transaction{
+					ID:        tx.ID,
+					Timestamp: tx.Timestamp,
+					Hash:      tx.Hash,
+				}
+			}
+		}
+	}
+
+	return blocks, int64(total), nil
+}
+
+// GetTransaction returns a transaction of a document in the organization
+// along with its block
+func (s *BlockchainService) GetTransaction(ctx context.Context, organizationID uint, id string) (*BlockchainTransaction, error) {
+	var found *BlockchainTransaction
+	for _, block := range s.chain.Blocks(0, int64(s.chain.Len())) {
+		for _, tx := range block.Transactions {
+			if tx.ID == id {
+				found = &BlockchainTransaction{Transaction: tx, BlockIndex: block.Index, BlockHash: block.Hash}
+			}
+		}
+	}
+
+	if found == nil || found.DocumentID == 0 {
+		return nil, ErrBlockchainTransactionNotFound
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.Document{}).
+		Where("id = ? AND organization_id = ?", found.DocumentID, organizationID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to get transaction document: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrBlockchainTransactionNotFound
+	}
+
+	return found, nil
+}
+
+// GetDocumentHistory returns the transactions recorded for a document,
+// oldest first, along with their blocks
+func (s *BlockchainService) GetDocumentHistory(documentID uint) []BlockchainTransaction {
+	history := make([]BlockchainTransaction, 0)
+	for _, block := range s.chain.Blocks(0, int64(s.chain.Len())) {
+		for _, tx := range block.Transactions {
+			if tx.DocumentID == documentID {
+				history = append(history, BlockchainTransaction{Transaction: tx, BlockIndex: block.Index, BlockHash: block.Hash})
+			}
+		}
+	}
+	return history
+}
+
+// RecordedFileHashes returns the file hash recorded on the chain for each
+// version of each document, from the mined transactions carrying both. A
+// later transaction for the same version takes precedence.
+func (s *BlockchainService) RecordedFileHashes() map[uint]map[int]string {
+	hashes := make(map[uint]map[int]string)
+	for _, block := range s.chain.Blocks(0, int64(s.chain.Len())) {
+		for _, tx := range block.Transactions {
+			fileHash, ok := tx.Data["file_hash"].(string)
+			if !ok || fileHash == "" || tx.DocumentID == 0 {
+				continue
+			}
+			// Numbers are float64 once the transaction has been through JSON
+			version, ok := tx.Data["version"].(float64)
+			if !ok {
+				continue
+			}
+
+			if hashes[tx.DocumentID] == nil {
+				hashes[tx.DocumentID] = make(map[int]string)
+			}
+			hashes[tx.DocumentID][int(version)] = fileHash
+		}
+	}
+	return hashes
+}
+
+// Verify checks the hashes, links and proof of work of the whole chain, and
+// that the blocks anchored externally still have their anchored hashes
+func (s *BlockchainService) Verify() (*ChainVerification, error) {
+	blocks := s.chain.Blocks(0, int64(s.chain.Len()))
+
+	anchors, err := s.checkAnchors(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &ChainVerification{
+		Valid:           s.chain.ValidateChain(),
+		Blocks:          len(blocks),
+		Consensus:       s.chain.Consensus(),
+		Difficulty:      s.chain.Difficulty(),
+		LatestBlockHash: blocks[len(blocks)-1].Hash,
+		Pending:         s.PendingTransactions(),
+		Anchors:         anchors,
+	}
+	for _, block := range blocks {
+		verification.TotalTransactions += len(block.Transactions)
+	}
+	verification.Valid = verification.Valid && len(anchors.Mismatched) == 0
+	return verification, nil
+}
+
+// reconcileBatchSize is the number of blockchain records updated per batch
+// when reconciling
+const reconcileBatchSize = 500
+
+// ReconciliationIssue is a blockchain record or transaction that doesn't
+// match the other side
+type ReconciliationIssue struct {
+	RecordID      *uint  `json:"record_id,omitempty"`
+	TransactionID string `json:"transaction_id"`
+	DocumentID    uint   `json:"document_id"`
+	BlockNumber   int64  `json:"block_number"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// ReconciliationReport is the result of cross-checking the blockchain
+// records against the chain. Orphaned records have no transaction on the
+// chain, mismatched ones differ from their transaction and missing entries
+// are transactions without a record.
+type ReconciliationReport struct {
+	CheckedAt    time.Time             `json:"checked_at"`
+	Records      int                   `json:"records"`
+	Transactions int                   `json:"transactions"`
+	Verified     int                   `json:"verified"`
+	Orphaned     []ReconciliationIssue `json:"orphaned"`
+	Mismatched   []ReconciliationIssue `json:"mismatched"`
+	Missing      []ReconciliationIssue `json:"missing"`
+}
+
+// chainTransaction is a transaction on the chain with its block
+type chainTransaction struct {
+	tx    blockchain.Transaction
+	block blockchain.Block
+}
+
+// Reconcile cross-checks every blockchain record against the chain, marking
+// the records that match their transaction as verified and the others as
+// not. The report covers documents of the organization, or all documents
+// for 0.
+func (s *BlockchainService) Reconcile(ctx context.Context, organizationID uint) (*ReconciliationReport, error) {
+	// Records are created under the lock, so every record up to the latest
+	// one at the time of the snapshot has its block in it
+	s.mu.Lock()
+	transactions := make(map[string]chainTransaction)
+	for _, block := range s.chain.Blocks(0, int64(s.chain.Len())) {
+		for _, tx := range block.Transactions {
+			if tx.DocumentID != 0 {
+				transactions[tx.ID] = chainTransaction{tx: tx, block: block}
+			}
+		}
+	}
+	var lastID uint
+	err := s.db.WithContext(ctx).Model(&models.BlockchainRecord{}).Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error
+	s.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blockchain records: %w", err)
+	}
+
+	var records []models.BlockchainRecord
+	if err := s.db.WithContext(ctx).Where("id <= ?", lastID).Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get blockchain records: %w", err)
+	}
+
+	reported, err := s.reportedDocuments(ctx, organizationID, records, transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconciliationReport{
+		CheckedAt:  time.Now(),
+		Orphaned:   make([]ReconciliationIssue, 0),
+		Mismatched: make([]ReconciliationIssue, 0),
+		Missing:    make([]ReconciliationIssue, 0),
+	}
+
+	var verified, unverified []uint
+	recorded := make(map[string]bool, len(records))
+	for i := range records {
+		record := &records[i]
+		recorded[record.TransactionID] = true
+
+		issue := ReconciliationIssue{
+			RecordID:      &record.ID,
+			TransactionID: record.TransactionID,
+			DocumentID:    record.DocumentID,
+			BlockNumber:   record.BlockNumber,
+		}
+
+		onChain, found := transactions[record.TransactionID]
+		reason := ""
+		if found {
+			reason = recordMismatch(record, onChain)
+		}
+		if found && reason == "" {
+			verified = append(verified, record.ID)
+		} else {
+			unverified = append(unverified, record.ID)
+		}
+
+		if !reported(record.DocumentID) {
+			continue
+		}
+		report.Records++
+		switch {
+		case !found:
+			report.Orphaned = append(report.Orphaned, issue)
+		case reason != "":
+			issue.Reason = reason
+			report.Mismatched = append(report.Mismatched, issue)
+		default:
+			report.Verified++
+		}
+	}
+
+	for id, onChain := range transactions {
+		if !reported(onChain.tx.DocumentID) {
+			continue
+		}
+		report.Transactions++
+		if !recorded[id] {
+			report.Missing = append(report.Missing, ReconciliationIssue{
+				TransactionID: id,
+				DocumentID:    onChain.tx.DocumentID,
+				BlockNumber:   onChain.block.Index,
+			})
+		}
+	}
+
+	sort.Slice(report.Missing, func(i, j int) bool {
+		return report.Missing[i].BlockNumber < report.Missing[j].BlockNumber
+	})
+
+	if err := s.markVerified(verified, true); err != nil {
+		return nil, err
+	}
+	if err := s.markVerified(unverified, false); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// reportedDocuments returns whether a document belongs in the reconciliation
+// report of the organization, or of all organizations for 0
+func (s *BlockchainService) reportedDocuments(ctx context.Context, organizationID uint, records []models.BlockchainRecord, transactions map[string]chainTransaction) (func(documentID uint) bool, error) {
+	if organizationID == 0 {
+		return func(uint) bool { return true }, nil
+	}
+
+	var documentIDs []uint
+	for _, record := range records {
+		documentIDs = append(documentIDs, record.DocumentID)
+	}
+	for _, onChain := range transactions {
+		documentIDs = append(documentIDs, onChain.tx.DocumentID)
+	}
+
+	own := make(map[uint]bool)
+	for start := 0; start < len(documentIDs); start += reconcileBatchSize {
+		end := start + reconcileBatchSize
+		if end > len(documentIDs) {
+			end = len(documentIDs)
+		}
+
+		var ids []uint
+		if err := s.db.WithContext(ctx).Unscoped().Model(&models.Document{}).
+			Where("id IN ? AND organization_id = ?", documentIDs[start:end], organizationID).
+			Pluck("id", &ids).Error; err != nil {
+			return nil, fmt.Errorf("failed to get record documents: %w", err)
+		}
+		for _, id := range ids {
+			own[id] = true
+		}
+	}
+
+	return func(documentID uint) bool { return own[documentID] }, nil
+}
+
+// recordMismatch describes how a record differs from its transaction on the
+// chain, or returns "" when they match
+func recordMismatch(record *models.BlockchainRecord, onChain chainTransaction) string {
+	switch {
+	case record.BlockNumber != onChain.block.Index:
+		return "block number differs"
+	case record.BlockHash != onChain.block.Hash:
+		return "block hash differs"
+	case record.PreviousHash != onChain.block.PreviousHash:
+		return "previous hash differs"
+	case record.DataHash != onChain.tx.Hash:
+		return "data hash differs"
+	case record.DocumentID != onChain.tx.DocumentID:
+		return "document differs"
+	case record.UserID != onChain.tx.UserID:
+		return "user differs"
+	case record.Action != onChain.tx.Action:
+		return "action differs"
+	}
+	return ""
+}
+
+// markVerified sets whether the records match the chain
+func (s *BlockchainService) markVerified(ids []uint, verified bool) error {
+	for start := 0; start < len(ids); start += reconcileBatchSize {
+		end := start + reconcileBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if err := s.db.Model(&models.BlockchainRecord{}).
+			Where("id IN ? AND is_verified <> ?", ids[start:end], verified).
+			Update("is_verified", verified).Error; err != nil {
+			return fmt.Errorf("failed to mark blockchain records: %w", err)
+		}
+	}
+	return nil
+}
+
+// StartReconciliation reconciles the records with the chain on every
+// interval in the background, logging any discrepancies
+func (s *BlockchainService) StartReconciliation(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := s.Reconcile(database.SystemContext(context.Background()), 0)
+			if err != nil {
+				log.Printf("Blockchain reconciliation failed: %v", err)
+				continue
+			}
+			if len(report.Orphaned)+len(report.Mismatched)+len(report.Missing) > 0 {
+				log.Printf("Blockchain reconciliation found %d orphaned, %d mismatched and %d missing records", len(report.Orphaned), len(report.Mismatched), len(report.Missing))
+			}
+		}
+	}()
+}
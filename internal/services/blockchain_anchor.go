@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/blockchain"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// anchorServiceOpenTimestamps names anchors made with OpenTimestamps
+const anchorServiceOpenTimestamps = "opentimestamps"
+
+// ErrBlockchainAnchorNotFound is returned for unknown anchors
+var ErrBlockchainAnchorNotFound = errors.New("blockchain anchor not found")
+
+// AnchorCheck compares an anchored block hash with the chain
+type AnchorCheck struct {
+	Anchors    int     `json:"anchors"`
+	Mismatched []int64 `json:"mismatched"` // Numbers of blocks whose hash no longer matches their anchor
+}
+
+// Anchor records the latest block's hash with the external timestamping
+// service, unless it was already anchored. It returns nil when there was
+// nothing new to anchor.
+func (s *BlockchainService) Anchor(ctx context.Context, client *blockchain.TimestampClient) (*models.BlockchainAnchor, error) {
+	latest := s.chain.LatestBlock()
+
+	var count int64
+	if err := s.db.Model(&models.BlockchainAnchor{}).
+		Where("block_number = ? AND block_hash = ?", latest.Index, latest.Hash).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check blockchain anchors: %w", err)
+	}
+	if count > 0 {
+		return nil, nil
+	}
+
+	digest, err := hex.DecodeString(latest.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode block hash: %w", err)
+	}
+
+	calendar, receipt, err := client.Stamp(ctx, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to anchor block %d: %w", latest.Index, err)
+	}
+
+	anchor := &models.BlockchainAnchor{
+		BlockNumber: latest.Index,
+		BlockHash:   latest.Hash,
+		Service:     anchorServiceOpenTimestamps,
+		Calendar:    calendar,
+		Receipt:     receipt,
+	}
+	if err := s.db.Create(anchor).Error; err != nil {
+		return nil, fmt.Errorf("failed to save blockchain anchor: %w", err)
+	}
+	return anchor, nil
+}
+
+// StartAnchoring anchors the latest block on every interval in the
+// background
+func (s *BlockchainService) StartAnchoring(client *blockchain.TimestampClient, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			anchor, err := s.Anchor(ctx, client)
+			cancel()
+			if err != nil {
+				log.Printf("Blockchain anchoring failed: %v", err)
+			} else if anchor != nil {
+				log.Printf("Anchored block %d with %s", anchor.BlockNumber, anchor.Calendar)
+			}
+		}
+	}()
+}
+
+// GetAnchors retrieves the anchors made, newest first
+func (s *BlockchainService) GetAnchors(page, limit int) ([]models.BlockchainAnchor, int64, error) {
+	var anchors []models.BlockchainAnchor
+	var total int64
+
+	offset := (page - 1) * limit
+
+	if err := s.db.Model(&models.BlockchainAnchor{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count blockchain anchors: %w", err)
+	}
+
+	if err := s.db.Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&anchors).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get blockchain anchors: %w", err)
+	}
+
+	return anchors, total, nil
+}
+
+// GetAnchorProof returns an anchor with its detached OpenTimestamps proof
+func (s *BlockchainService) GetAnchorProof(id uint) (*models.BlockchainAnchor, []byte, error) {
+	var anchor models.BlockchainAnchor
+	if err := s.db.First(&anchor, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrBlockchainAnchorNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get blockchain anchor: %w", err)
+	}
+
+	digest, err := hex.DecodeString(anchor.BlockHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode anchored hash: %w", err)
+	}
+	return &anchor, blockchain.DetachedTimestamp(digest, anchor.Receipt), nil
+}
+
+// checkAnchors compares every anchored hash with the block now on the chain
+func (s *BlockchainService) checkAnchors(blocks []blockchain.Block) (*AnchorCheck, error) {
+	var anchors []models.BlockchainAnchor
+	if err := s.db.Select("block_number", "block_hash").Order("block_number").Find(&anchors).Error; err != nil {
+		return nil, fmt.Errorf("failed to get blockchain anchors: %w", err)
+	}
+
+	check := &AnchorCheck{
+		Anchors:    len(anchors),
+		Mismatched: make([]int64, 0),
+	}
+	for _, anchor := range anchors {
+		if anchor.BlockNumber >= int64(len(blocks)) || blocks[anchor.BlockNumber].Hash != anchor.BlockHash {
+			check.Mismatched = append(check.Mismatched, anchor.BlockNumber)
+		}
+	}
+	return check, nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/blockchain"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// loadPending restores the transactions queued but not yet mined, oldest
+// first
+func (s *BlockchainService) loadPending() ([]blockchain.Transaction, error) {
+	var rows []models.BlockchainPendingTransaction
+	if err := s.db.Order("id").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get pending blockchain transactions: %w", err)
+	}
+
+	pending := make([]blockchain.Transaction, 0, len(rows))
+	for _, row := range rows {
+		var tx blockchain.Transaction
+		if err := json.Unmarshal([]byte(row.Data), &tx); err != nil {
+			return nil, fmt.Errorf("failed to decode pending transaction %s: %w", row.TransactionID, err)
+		}
+		pending = append(pending, tx)
+	}
+	return pending, nil
+}
+
+// PendingTransactions returns the number of transactions waiting to be mined
+func (s *BlockchainService) PendingTransactions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.pending)
+}
+
+// Start mines the pending transactions in the background, on every mine
+// interval and whenever a full block's worth is waiting
+func (s *BlockchainService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.mineInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.wake:
+			}
+
+			for {
+				mined, err := s.MinePending()
+				if err != nil {
+					log.Printf("Blockchain mining failed: %v", err)
+					break
+				}
+				if mined < s.maxBlockTransactions {
+					break
+				}
+			}
+		}
+	}()
+}
+
+// MinePending mines up to a block's worth of pending transactions into a new
+// block, storing the block and the transactions' blockchain records. It
+// returns the number of transactions mined. Only the miner adds blocks, so
+// the proof of work runs without holding the lock.
+func (s *BlockchainService) MinePending() (int, error) {
+	s.mu.Lock()
+	count := len(s.pending)
+	if count > s.maxBlockTransactions {
+		count = s.maxBlockTransactions
+	}
+	transactions := append([]blockchain.Transaction(nil), s.pending[:count]...)
+	s.mu.Unlock()
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	block := s.chain.PrepareBlock(transactions)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, count)
+	records := make([]models.BlockchainRecord, 0, count)
+	for _, tx := range block.Transactions {
+		ids = append(ids, tx.ID)
+		records = append(records, models.BlockchainRecord{
+			TransactionID: tx.ID,
+			BlockHash:     block.Hash,
+			BlockNumber:   block.Index,
+			DocumentID:    tx.DocumentID,
+			UserID:        tx.UserID,
+			Action:        tx.Action,
+			DataHash:      tx.Hash,
+			PreviousHash:  block.PreviousHash,
+			Timestamp:     tx.Timestamp,
+			IsVerified:    true,
+		})
+	}
+
+	if err := s.db.Transaction(func(db *gorm.DB) error {
+		if err := s.saveBlock(db, &block); err != nil {
+			return err
+		}
+		if err := db.Create(&records).Error; err != nil {
+			return fmt.Errorf("failed to create blockchain records: %w", err)
+		}
+		if err := db.Where("transaction_id IN ?", ids).Delete(&models.BlockchainPendingTransaction{}).Error; err != nil {
+			return fmt.Errorf("failed to remove pending transactions: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := s.chain.AppendBlock(block); err != nil {
+		return 0, err
+	}
+	s.pending = s.pending[count:]
+
+	return count, nil
+}
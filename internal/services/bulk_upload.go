@@ -0,0 +1,261 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"golang.org/x/text/encoding/japanese"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidArchive is returned when an uploaded archive can't be read as a zip file
+	ErrInvalidArchive = errors.New("file is not a valid zip archive")
+	// ErrArchiveTooLarge is returned when an archive has too many files or expands beyond the size limit
+	ErrArchiveTooLarge = errors.New("archive exceeds the bulk upload limits")
+)
+
+// BulkUploadService extracts uploaded zip archives into documents in the
+// background. Each file goes through the same checks as a single upload, and
+// its outcome is recorded for the per-file report.
+type BulkUploadService struct {
+	db                  *gorm.DB
+	intake              *DocumentIntake
+	notificationService *NotificationService
+	auditService        *AuditService
+	maxFiles            int
+	maxTotalSize        int64
+}
+
+// NewBulkUploadService creates a new bulk upload service. Archives may hold
+// at most maxFiles files expanding to maxTotalSize bytes in total.
+func NewBulkUploadService(
+	intake *DocumentIntake,
+	notificationService *NotificationService,
+	auditService *AuditService,
+	maxFiles int,
+	maxTotalSize int64,
+) *BulkUploadService {
+	return &BulkUploadService{
+		db:                  database.GetDB(),
+		intake:              intake,
+		notificationService: notificationService,
+		auditService:        auditService,
+		maxFiles:            maxFiles,
+		maxTotalSize:        maxTotalSize,
+	}
+}
+
+// archiveEntry is a file of an archive with its UTF-8 path
+type archiveEntry struct {
+	file *zip.File
+	path string
+}
+
+// Start validates an archive, records the bulk upload and extracts it in the
+// background. Files larger than maxFileSize are rejected individually.
+func (s *BulkUploadService) Start(upload *models.BulkUpload, user *models.User, archive []byte, maxFileSize int64, ipAddress, userAgent string) error {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return ErrInvalidArchive
+	}
+
+	var entries []archiveEntry
+	var totalSize uint64
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || skipArchiveEntry(file.Name) {
+			continue
+		}
+		entries = append(entries, archiveEntry{file: file, path: archiveEntryName(file)})
+		totalSize += file.UncompressedSize64
+	}
+	if len(entries) > s.maxFiles || totalSize > uint64(s.maxTotalSize) {
+		return ErrArchiveTooLarge
+	}
+
+	upload.UserID = user.ID
+	upload.Status = models.BulkUploadProcessing
+	upload.TotalFiles = len(entries)
+	if err := s.db.Create(upload).Error; err != nil {
+		return fmt.Errorf("failed to create bulk upload: %w", err)
+	}
+
+	s.auditService.LogAction(user.ID, nil, "bulk_upload_started", "bulk_upload", strconv.Itoa(int(upload.ID)), ipAddress, userAgent, map[string]interface{}{
+		"file_name":   upload.FileName,
+		"folder_id":   upload.FolderID,
+		"total_files": upload.TotalFiles,
+	})
+
+	// The caller keeps its copy of the upload for the response
+	processed := *upload
+	batch := NewIntakeBatch(user, processed.FolderID, map[string]interface{}{"bulk_upload_id": processed.ID})
+	batch.Category = processed.Category
+	batch.Tags = processed.Tags
+	batch.AccessLevel = processed.AccessLevel
+	batch.AccessLevelReason = processed.AccessLevelReason
+	batch.IPAddress = ipAddress
+	batch.UserAgent = userAgent
+	go s.process(&processed, batch, entries, maxFileSize)
+
+	return nil
+}
+
+// archiveEntryName returns the name of an archive entry as UTF-8. Archives
+// made on Japanese Windows store Shift_JIS names without flagging them.
+func archiveEntryName(file *zip.File) string {
+	if !file.NonUTF8 && utf8.ValidString(file.Name) {
+		return file.Name
+	}
+	if name, err := japanese.ShiftJIS.NewDecoder().String(file.Name); err == nil {
+		return name
+	}
+	return file.Name
+}
+
+// skipArchiveEntry reports whether an archive entry is operating system
+// metadata rather than user content
+func skipArchiveEntry(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || base == ".DS_Store" || base == "Thumbs.db" || base == "desktop.ini"
+}
+
+// process creates a document for every file of an archive, recording each outcome
+func (s *BulkUploadService) process(upload *models.BulkUpload, batch *IntakeBatch, entries []archiveEntry, maxFileSize int64) {
+	for _, entry := range entries {
+		item := &models.BulkUploadItem{BulkUploadID: upload.ID, Path: entry.path}
+
+		doc, err := s.processEntry(batch, entry, maxFileSize)
+		if err != nil {
+			item.Error = err.Error()
+			upload.FailedFiles++
+		} else {
+			item.DocumentID = &doc.ID
+			item.Succeeded = true
+			upload.SucceededFiles++
+		}
+
+		if err := s.db.Create(item).Error; err != nil {
+			log.Printf("Failed to record bulk upload %d item %q: %v", upload.ID, entry.path, err)
+		}
+		s.db.Model(upload).Updates(map[string]interface{}{
+			"succeeded_files": upload.SucceededFiles,
+			"failed_files":    upload.FailedFiles,
+		})
+	}
+
+	now := time.Now()
+	upload.Status = models.BulkUploadCompleted
+	upload.CompletedAt = &now
+	if err := s.db.Model(upload).Select("status", "completed_at").Updates(upload).Error; err != nil {
+		log.Printf("Failed to complete bulk upload %d: %v", upload.ID, err)
+	}
+
+	if err := s.notificationService.Notify([]models.Notification{{
+		UserID:  upload.UserID,
+		Type:    models.NotificationBulkUpload,
+		Message: fmt.Sprintf("%s: %d of %d files uploaded, %d failed", upload.FileName, upload.SucceededFiles, upload.TotalFiles, upload.FailedFiles),
+	}}); err != nil {
+		log.Printf("Failed to notify user %d of bulk upload %d: %v", upload.UserID, upload.ID, err)
+	}
+
+	s.auditService.LogAction(upload.UserID, nil, "bulk_upload_completed", "bulk_upload", strconv.Itoa(int(upload.ID)), batch.IPAddress, batch.UserAgent, map[string]interface{}{
+		"total_files":     upload.TotalFiles,
+		"succeeded_files": upload.SucceededFiles,
+		"failed_files":    upload.FailedFiles,
+	})
+}
+
+// processEntry creates the document of one archive file. The returned error
+// is reported to the user.
+func (s *BulkUploadService) processEntry(batch *IntakeBatch, entry archiveEntry, maxFileSize int64) (*models.Document, error) {
+	if entry.file.UncompressedSize64 > uint64(maxFileSize) {
+		return nil, errors.New("file exceeds the maximum upload size")
+	}
+
+	if err := s.intake.CheckName(path.Base(entry.path)); err != nil {
+		return nil, err
+	}
+
+	content, err := readArchiveFile(entry.file, maxFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.intake.Create(batch, entry.path, content)
+}
+
+// readArchiveFile reads an archive file, guarding against entries that
+// expand beyond their declared size
+func readArchiveFile(file *zip.File, maxSize int64) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, errors.New("failed to read file")
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, errors.New("failed to read file")
+	}
+	if int64(len(content)) > maxSize {
+		return nil, errors.New("file exceeds the maximum upload size")
+	}
+	return content, nil
+}
+
+// GetAllForUser retrieves the bulk uploads of a user, newest first
+func (s *BulkUploadService) GetAllForUser(userID uint, page, limit int) ([]models.BulkUpload, int64, error) {
+	query := s.db.Model(&models.BulkUpload{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count bulk uploads: %w", err)
+	}
+
+	var uploads []models.BulkUpload
+	if err := query.Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&uploads).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get bulk uploads: %w", err)
+	}
+
+	return uploads, total, nil
+}
+
+// GetForUser retrieves a bulk upload of a user with its per-file results
+func (s *BulkUploadService) GetForUser(userID, id uint) (*models.BulkUpload, error) {
+	var upload models.BulkUpload
+	if err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Where("user_id = ?", userID).First(&upload, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get bulk upload: %w", err)
+	}
+	return &upload, nil
+}
+
+// FailInterrupted marks bulk uploads cut short by a restart as failed; their
+// archives only lived in memory
+func (s *BulkUploadService) FailInterrupted() error {
+	if err := s.db.Model(&models.BulkUpload{}).
+		Where("status = ?", models.BulkUploadProcessing).
+		Updates(map[string]interface{}{
+			"status":       models.BulkUploadFailed,
+			"error":        "interrupted by a server restart",
+			"completed_at": time.Now(),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to update interrupted bulk uploads: %w", err)
+	}
+	return nil
+}
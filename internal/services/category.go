@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCategoryInUse is returned when deleting a category that still has subcategories or documents
+	ErrCategoryInUse = errors.New("category has subcategories or documents")
+	// ErrCategoryCycle is returned when a category would become its own ancestor
+	ErrCategoryCycle = errors.New("category cannot be nested under itself or a subcategory")
+)
+
+// CategoryWithCounts is a category with the number of documents filed under
+// it directly and including all of its subcategories
+type CategoryWithCounts struct {
+	models.Category
+	DocumentCount      int64 `json:"document_count"`
+	TotalDocumentCount int64 `json:"total_document_count"`
+}
+
+// CategoryService handles category-related business logic
+type CategoryService struct {
+	db *gorm.DB
+}
+
+// NewCategoryService creates a new category service
+func NewCategoryService() *CategoryService {
+	return &CategoryService{
+		db: database.GetDB(),
+	}
+}
+
+// GetAll retrieves the categories of an organization with their document counts
+func (s *CategoryService) GetAll(ctx context.Context, organizationID uint) ([]CategoryWithCounts, error) {
+	var categories []models.Category
+	if err := s.db.WithContext(ctx).Scopes(OfOrganization("categories", organizationID)).Order("name ASC").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+
+	var counts []struct {
+		Category string
+		Count    int64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Document{}).Scopes(OfOrganization("documents", organizationID)).
+		Select("category, COUNT(*) AS count").
+		Where("category <> ''").
+		Group("category").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count documents by category: %w", err)
+	}
+
+	direct := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		direct[count.Category] = count.Count
+	}
+
+	parents := make(map[uint]*uint, len(categories))
+	for _, category := range categories {
+		parents[category.ID] = category.ParentID
+	}
+
+	// Add each category's documents to itself and all of its ancestors
+	totals := make(map[uint]int64, len(categories))
+	for _, category := range categories {
+		count := direct[category.Name]
+		id, depth := &category.ID, 0
+		for id != nil && depth <= len(categories) {
+			totals[*id] += count
+			id, depth = parents[*id], depth+1
+		}
+	}
+
+	results := make([]CategoryWithCounts, 0, len(categories))
+	for _, category := range categories {
+		results = append(results, CategoryWithCounts{
+			Category:           category,
+			DocumentCount:      direct[category.Name],
+			TotalDocumentCount: totals[category.ID],
+		})
+	}
+
+	return results, nil
+}
+
+// GetByID retrieves a category by ID
+func (s *CategoryService) GetByID(id uint) (*models.Category, error) {
+	var category models.Category
+	if err := s.db.First(&category, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+	return &category, nil
+}
+
+// Create creates a new category
+func (s *CategoryService) Create(category *models.Category) error {
+	if err := s.db.Create(category).Error; err != nil {
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+	return nil
+}
+
+// Update updates a category. Documents refer to categories by name, so a
+// rename is carried over to all documents of its organization, including
+// those the user can't see.
+func (s *CategoryService) Update(category *models.Category, previousName string) error {
+	if category.ParentID != nil {
+		nested, err := s.isWithin(*category.ParentID, category.ID)
+		if err != nil {
+			return err
+		}
+		if nested {
+			return ErrCategoryCycle
+		}
+	}
+
+	err := database.AsSystem(s.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(category).Error; err != nil {
+			return err
+		}
+
+		if previousName == category.Name {
+			return nil
+		}
+		return tx.Unscoped().Model(&models.Document{}).
+			Where("organization_id = ? AND category = ?", category.OrganizationID, previousName).
+			UpdateColumn("category", category.Name).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+	return nil
+}
+
+// isWithin checks whether a category is the ancestor category or nested below it
+func (s *CategoryService) isWithin(categoryID, ancestorID uint) (bool, error) {
+	var count int64
+	err := s.db.Raw(`WITH RECURSIVE ancestors(id, parent_id, depth) AS (
+			SELECT id, parent_id, 1 FROM categories WHERE id = ?
+			UNION ALL
+			SELECT categories.id, categories.parent_id, ancestors.depth + 1 FROM categories
+			JOIN ancestors ON categories.id = ancestors.parent_id
+			WHERE ancestors.depth < ?
+		)
+		SELECT COUNT(*) FROM ancestors WHERE id = ?`, categoryID, maxFolderDepth, ancestorID).
+		Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to get category ancestors: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Delete soft deletes a category that has no subcategories and no documents
+func (s *CategoryService) Delete(category *models.Category) error {
+	var children, documents int64
+	if err := s.db.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+		return fmt.Errorf("failed to count subcategories: %w", err)
+	}
+	// Documents the user can't see count as well
+	if err := database.AsSystem(s.db).Model(&models.Document{}).Where("organization_id = ? AND category = ?", category.OrganizationID, category.Name).Count(&documents).Error; err != nil {
+		return fmt.Errorf("failed to count documents: %w", err)
+	}
+	if children > 0 || documents > 0 {
+		return ErrCategoryInUse
+	}
+
+	if err := s.db.Delete(category).Error; err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	return nil
+}
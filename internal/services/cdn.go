@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/cdn"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// cdnMaxAccessLevel is the most restricted access level served through the CDN
+const cdnMaxAccessLevel = models.AccessInternal
+
+// ErrCDNNotAllowed is returned for documents too restricted for the CDN
+var ErrCDNNotAllowed = errors.New("document may not be served through the CDN")
+
+// CDNService issues signed CDN URLs for document downloads. The CDN fetches
+// content from the blob origin endpoint and caches it by content hash, so
+// repeated downloads don't reach the server.
+type CDNService struct {
+	db              *gorm.DB
+	documentService *DocumentService
+	signer          cdn.URLSigner
+	baseURL         string
+	ttl             time.Duration
+}
+
+// NewCDNService creates a new CDN service issuing URLs under baseURL that
+// expire after ttl
+func NewCDNService(documentService *DocumentService, signer cdn.URLSigner, baseURL string, ttl time.Duration) *CDNService {
+	return &CDNService{
+		db:              database.GetDB(),
+		documentService: documentService,
+		signer:          signer,
+		baseURL:         strings.TrimRight(baseURL, "/"),
+		ttl:             ttl,
+	}
+}
+
+// Allowed reports whether a document may be served through the CDN
+func (s *CDNService) Allowed(doc *models.Document) bool {
+	return doc.AccessLevel <= cdnMaxAccessLevel && doc.FilePath != "" && doc.FileHash != "" &&
+		doc.ScanStatus != models.ScanPending && doc.ScanStatus != models.ScanInfected
+}
+
+// SignedURL returns a signed CDN URL for the current content of a document
+// and when it expires
+func (s *CDNService) SignedURL(doc *models.Document) (string, time.Time, error) {
+	if !s.Allowed(doc) {
+		return "", time.Time{}, ErrCDNNotAllowed
+	}
+
+	expires := time.Now().Add(s.ttl)
+	rawURL := fmt.Sprintf("%s/cdn/blobs/%s/%s", s.baseURL, doc.FileHash, url.PathEscape(doc.FileName))
+
+	signed, err := s.signer.SignURL(rawURL, expires)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign CDN URL: %w", err)
+	}
+
+	return signed, expires, nil
+}
+
+// ReadOriginContent returns the content with the given hash for the CDN
+// origin, along with a document it belongs to. Only content of documents
+// that may be served through the CDN is returned.
+func (s *CDNService) ReadOriginContent(hash string) (*models.Document, []byte, error) {
+	// Requests come from the CDN rather than a user, so the query runs as the
+	// system and selects only content cleared for the CDN itself
+	var doc models.Document
+	if err := database.AsSystem(s.db).Where("file_hash = ? AND access_level <= ? AND file_path <> ''", hash, cdnMaxAccessLevel).
+		Where("COALESCE(scan_status, '') NOT IN ?", []models.ScanStatus{models.ScanPending, models.ScanInfected}).
+		Order("id ASC").
+		First(&doc).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	content, err := s.documentService.ReadContent(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &doc, content, nil
+}
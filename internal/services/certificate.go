@@ -0,0 +1,439 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/signature"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCertificateNotFound is returned for unknown user certificates
+	ErrCertificateNotFound = errors.New("certificate not found")
+	// ErrCertificateExists is returned when issuing a certificate to a user
+	// who still has a valid one
+	ErrCertificateExists = errors.New("user already has a valid signing certificate")
+	// ErrCertificateRevoked is returned when renewing or revoking a revoked
+	// certificate
+	ErrCertificateRevoked = errors.New("certificate is revoked")
+	// ErrNoSigningCertificate is returned when signing with the issued
+	// certificate of a user who has no valid one
+	ErrNoSigningCertificate = errors.New("user has no valid signing certificate")
+)
+
+// revocationReasonCodes are the CRL reason codes of RFC 5280
+var revocationReasonCodes = map[models.RevocationReason]int{
+	models.RevocationUnspecified:          0,
+	models.RevocationKeyCompromise:        1,
+	models.RevocationAffiliationChanged:   3,
+	models.RevocationSuperseded:           4,
+	models.RevocationCessationOfOperation: 5,
+}
+
+// ValidRevocationReason reports whether reason is a known revocation reason
+func ValidRevocationReason(reason models.RevocationReason) bool {
+	_, ok := revocationReasonCodes[reason]
+	return ok
+}
+
+// CertificateStatus is the revocation status of a certificate issued by the
+// internal CA, like an OCSP response
+type CertificateStatus struct {
+	SerialNumber     string                  `json:"serial_number"`
+	Status           string                  `json:"status"` // good, revoked or unknown
+	NotAfter         *time.Time              `json:"not_after,omitempty"`
+	RevokedAt        *time.Time              `json:"revoked_at,omitempty"`
+	RevocationReason models.RevocationReason `json:"revocation_reason,omitempty"`
+	ProducedAt       time.Time               `json:"produced_at"`
+}
+
+// CertificateService issues signing certificates to users from the internal
+// CA, renews and revokes them, and publishes their revocation status. Each
+// certificate's private key is generated on the server and stored sealed
+// with its own data key, so users sign with it through the server.
+type CertificateService struct {
+	db            *gorm.DB
+	ca            *signature.CA
+	envelope      *crypto.EnvelopeService
+	validity      time.Duration
+	crlValidity   time.Duration
+	renewalWindow time.Duration
+
+	mu         sync.Mutex
+	crl        []byte
+	crlUpdated time.Time
+}
+
+// NewCertificateService creates a new certificate service issuing
+// certificates valid for validity and CRLs valid for crlValidity.
+// Certificates are due for renewal renewalWindow before they expire.
+func NewCertificateService(ca *signature.CA, envelope *crypto.EnvelopeService, validity, crlValidity, renewalWindow time.Duration) *CertificateService {
+	if validity <= 0 {
+		validity = 365 * 24 * time.Hour
+	}
+	if crlValidity <= 0 {
+		crlValidity = 24 * time.Hour
+	}
+
+	return &CertificateService{
+		db:            database.GetDB(),
+		ca:            ca,
+		envelope:      envelope,
+		validity:      validity,
+		crlValidity:   crlValidity,
+		renewalWindow: renewalWindow,
+	}
+}
+
+// CACertificates returns the PEM certificates of the internal CA
+func (s *CertificateService) CACertificates() string {
+	return signature.EncodeCertificates(s.ca.Certificates())
+}
+
+// Issue issues a signing certificate to a user who has no valid one
+func (s *CertificateService) Issue(user *models.User, issuedBy uint) (*models.UserCertificate, error) {
+	var record *models.UserCertificate
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Serialize issuing certificates to the user
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, user.ID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.UserCertificate{}).
+			Where("user_id = ? AND revoked_at IS NULL AND not_after > ?", user.ID, time.Now()).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrCertificateExists
+		}
+
+		var err error
+		record, err = s.issue(tx, user, issuedBy)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrCertificateExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to issue certificate: %w", err)
+	}
+	return record, nil
+}
+
+// Renew issues a new certificate to the holder of a certificate and revokes
+// the old one as superseded
+func (s *CertificateService) Renew(user *models.User, id uint, issuedBy uint) (*models.UserCertificate, error) {
+	var record *models.UserCertificate
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var old models.UserCertificate
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", user.ID).
+			First(&old, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCertificateNotFound
+			}
+			return err
+		}
+		if old.RevokedAt != nil {
+			return ErrCertificateRevoked
+		}
+
+		var err error
+		record, err = s.issue(tx, user, issuedBy)
+		if err != nil {
+			return err
+		}
+		record.RenewedFromID = &old.ID
+		if err := tx.Model(record).Update("renewed_from_id", old.ID).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&old).Updates(map[string]interface{}{
+			"revoked_at":        record.CreatedAt,
+			"revocation_reason": models.RevocationSuperseded,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, ErrCertificateNotFound) || errors.Is(err, ErrCertificateRevoked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to renew certificate: %w", err)
+	}
+
+	s.invalidateCRL()
+	return record, nil
+}
+
+// issue generates a key pair, has the CA certify it for the user and stores
+// both, the key sealed
+func (s *CertificateService) issue(tx *gorm.DB, user *models.User, issuedBy uint) (*models.UserCertificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	subject := pkix.Name{CommonName: strings.TrimSpace(user.FirstName + " " + user.LastName)}
+	if subject.CommonName == "" {
+		subject.CommonName = user.Username
+	}
+	var organization models.Organization
+	if err := tx.Select("name").First(&organization, user.OrganizationID).Error; err == nil && organization.Name != "" {
+		subject.Organization = []string{organization.Name}
+	}
+
+	now := time.Now()
+	certificate, err := s.ca.Issue(subject, user.Email, key.Public(), now.Add(-time.Minute), now.Add(s.validity))
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	sealed, dataKey, keyVersion, err := s.envelope.Seal(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	record := &models.UserCertificate{
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+		SerialNumber:   certificate.SerialNumber.Text(16),
+		Subject:        certificate.Subject.String(),
+		Email:          user.Email,
+		Certificate:    signature.EncodeCertificates(append([]*x509.Certificate{certificate}, s.ca.Certificates()...)),
+		Fingerprint:    signature.Fingerprint(certificate),
+		PrivateKey:     base64.StdEncoding.EncodeToString(sealed),
+		DataKey:        dataKey,
+		KeyVersion:     keyVersion,
+		NotBefore:      certificate.NotBefore,
+		NotAfter:       certificate.NotAfter,
+		IssuedBy:       issuedBy,
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Revoke revokes a certificate of the organization
+func (s *CertificateService) Revoke(organizationID, id uint, reason models.RevocationReason) (*models.UserCertificate, error) {
+	var record models.UserCertificate
+	if err := s.db.Where("organization_id = ?", organizationID).First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCertificateNotFound
+		}
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+
+	now := time.Now()
+	result := s.db.Model(&record).
+		Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{
+			"revoked_at":        now,
+			"revocation_reason": reason,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to revoke certificate: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCertificateRevoked
+	}
+
+	record.RevokedAt = &now
+	record.RevocationReason = reason
+	s.invalidateCRL()
+	return &record, nil
+}
+
+// Get returns a certificate of the organization
+func (s *CertificateService) Get(organizationID, id uint) (*models.UserCertificate, error) {
+	var record models.UserCertificate
+	if err := s.db.Where("organization_id = ?", organizationID).First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCertificateNotFound
+		}
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+	return &record, nil
+}
+
+// GetForUser lists a user's certificates, newest first
+func (s *CertificateService) GetForUser(userID uint) ([]models.UserCertificate, error) {
+	certificates := make([]models.UserCertificate, 0)
+	if err := s.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Find(&certificates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get certificates: %w", err)
+	}
+	return certificates, nil
+}
+
+// GetForOrganization lists a page of the organization's certificates,
+// newest first
+func (s *CertificateService) GetForOrganization(organizationID uint, page, limit int) ([]models.UserCertificate, int64, error) {
+	query := s.db.Model(&models.UserCertificate{}).Where("organization_id = ?", organizationID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count certificates: %w", err)
+	}
+
+	certificates := make([]models.UserCertificate, 0)
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&certificates).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get certificates: %w", err)
+	}
+	return certificates, total, nil
+}
+
+// RenewalDue reports whether a valid certificate expires within the renewal
+// window
+func (s *CertificateService) RenewalDue(record *models.UserCertificate) bool {
+	return record.RevokedAt == nil && time.Until(record.NotAfter) <= s.renewalWindow
+}
+
+// Signer returns a signer with the user's newest valid certificate and its
+// private key
+func (s *CertificateService) Signer(userID uint) (*signature.Signer, error) {
+	var record models.UserCertificate
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND not_before <= ? AND not_after > ?", userID, time.Now(), time.Now()).
+		Order("not_after DESC").
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoSigningCertificate
+		}
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(record.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	der, err := s.envelope.Open(sealed, record.DataKey, record.KeyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key: %w", err)
+	}
+
+	certificates, err := signature.ParseCertificates([]byte(record.Certificate))
+	if err != nil {
+		return nil, err
+	}
+	return signature.NewSigner(der, certificates)
+}
+
+// GetByFingerprint returns the certificate with a fingerprint, or nil when
+// the internal CA didn't issue it
+func (s *CertificateService) GetByFingerprint(fingerprint string) (*models.UserCertificate, error) {
+	var record models.UserCertificate
+	if err := s.db.Where("fingerprint = ?", fingerprint).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+	return &record, nil
+}
+
+// Status returns the revocation status of the certificate with a hex serial
+// number
+func (s *CertificateService) Status(serialNumber string) (*CertificateStatus, error) {
+	serial, ok := new(big.Int).SetString(serialNumber, 16)
+	if !ok {
+		return nil, ErrCertificateNotFound
+	}
+
+	status := &CertificateStatus{
+		SerialNumber: serial.Text(16),
+		Status:       "unknown",
+		ProducedAt:   time.Now(),
+	}
+
+	var record models.UserCertificate
+	if err := s.db.Where("serial_number = ?", status.SerialNumber).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return status, nil
+		}
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+
+	status.Status = "good"
+	status.NotAfter = &record.NotAfter
+	if record.RevokedAt != nil {
+		status.Status = "revoked"
+		status.RevokedAt = record.RevokedAt
+		status.RevocationReason = record.RevocationReason
+	}
+	return status, nil
+}
+
+// RevocationList returns the DER CRL of the revoked certificates that
+// haven't expired yet. The CRL is created again after revocations and
+// halfway through its validity.
+func (s *CertificateService) RevocationList() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.crl != nil && now.Sub(s.crlUpdated) < s.crlValidity/2 {
+		return s.crl, nil
+	}
+
+	var revoked []models.UserCertificate
+	if err := s.db.Select("serial_number", "revoked_at", "revocation_reason").
+		Where("revoked_at IS NOT NULL AND not_after > ?", now).
+		Order("revoked_at ASC").
+		Find(&revoked).Error; err != nil {
+		return nil, fmt.Errorf("failed to get revoked certificates: %w", err)
+	}
+
+	entries := make([]x509.RevocationListEntry, 0, len(revoked))
+	for _, record := range revoked {
+		serial, ok := new(big.Int).SetString(record.SerialNumber, 16)
+		if !ok {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: *record.RevokedAt,
+			ReasonCode:     revocationReasonCodes[record.RevocationReason],
+		})
+	}
+
+	// CRL numbers increase with time
+	crl, err := s.ca.RevocationList(entries, big.NewInt(now.UnixNano()), now, now.Add(s.crlValidity))
+	if err != nil {
+		return nil, err
+	}
+
+	s.crl = crl
+	s.crlUpdated = now
+	return crl, nil
+}
+
+// invalidateCRL has the next CRL request create the CRL again
+func (s *CertificateService) invalidateCRL() {
+	s.mu.Lock()
+	s.crl = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// ClassificationInput describes an upload to classify
+type ClassificationInput struct {
+	Title       string
+	Description string
+	Category    string
+	Department  string // Uploader's department
+	FileName    string
+	MimeType    string
+	Content     []byte
+}
+
+// Classification is the access level suggested for an upload and the rules
+// that suggested it. AccessLevel is zero when no rule matched.
+type Classification struct {
+	AccessLevel models.AccessLevel `json:"suggested_access_level"`
+	Rules       []string           `json:"rules"`
+}
+
+// ClassificationService suggests access levels for uploads from the
+// classification rules set up by admins
+type ClassificationService struct {
+	db *gorm.DB
+}
+
+// NewClassificationService creates a new classification service
+func NewClassificationService() *ClassificationService {
+	return &ClassificationService{
+		db: database.GetDB(),
+	}
+}
+
+// Classify returns the highest access level suggested by the active rules
+// matching an upload
+func (s *ClassificationService) Classify(input *ClassificationInput) (*Classification, error) {
+	var rules []models.ClassificationRule
+	if err := s.db.Where("is_active = ?", true).Order("access_level DESC, id ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to get classification rules: %w", err)
+	}
+
+	classification := &Classification{Rules: []string{}}
+	if len(rules) == 0 {
+		return classification, nil
+	}
+
+	// Content is only extracted when a rule looks at keywords
+	var text string
+	textLoaded := false
+
+	for _, rule := range rules {
+		if rule.Category != "" && !strings.EqualFold(rule.Category, input.Category) {
+			continue
+		}
+		if rule.Department != "" && !strings.EqualFold(rule.Department, input.Department) {
+			continue
+		}
+		if keywords := ParseKeywords(rule.Keywords); len(keywords) > 0 {
+			if !textLoaded {
+				text = strings.ToLower(strings.Join([]string{
+					input.Title,
+					input.Description,
+					input.FileName,
+					extractUploadText(input.MimeType, input.FileName, input.Content),
+				}, "\n"))
+				textLoaded = true
+			}
+			if !containsAny(text, keywords) {
+				continue
+			}
+		}
+
+		classification.AccessLevel = max(classification.AccessLevel, rule.AccessLevel)
+		classification.Rules = append(classification.Rules, rule.Name)
+	}
+
+	return classification, nil
+}
+
+// containsAny reports whether lowercased text contains any of the keywords
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseKeywords splits a comma-separated keyword list, dropping blanks
+func ParseKeywords(value string) []string {
+	var keywords []string
+	for _, keyword := range strings.Split(value, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// GetRules retrieves all classification rules, highest access level first
+func (s *ClassificationService) GetRules() ([]models.ClassificationRule, error) {
+	var rules []models.ClassificationRule
+	if err := s.db.Order("access_level DESC, id ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to get classification rules: %w", err)
+	}
+	return rules, nil
+}
+
+// GetRule retrieves a classification rule by ID
+func (s *ClassificationService) GetRule(id uint) (*models.ClassificationRule, error) {
+	var rule models.ClassificationRule
+	if err := s.db.First(&rule, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get classification rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// CreateRule creates a classification rule
+func (s *ClassificationService) CreateRule(rule *models.ClassificationRule) error {
+	if err := s.db.Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create classification rule: %w", err)
+	}
+	return nil
+}
+
+// UpdateRule saves changes to a classification rule
+func (s *ClassificationService) UpdateRule(rule *models.ClassificationRule) error {
+	if err := s.db.Save(rule).Error; err != nil {
+		return fmt.Errorf("failed to update classification rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteRule deletes a classification rule
+func (s *ClassificationService) DeleteRule(rule *models.ClassificationRule) error {
+	if err := s.db.Delete(rule).Error; err != nil {
+		return fmt.Errorf("failed to delete classification rule: %w", err)
+	}
+	return nil
+}
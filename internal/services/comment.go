@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// mentionPattern matches @username mentions not preceded by a word character,
+// so e-mail addresses are not taken for mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.\-]{1,50})`)
+
+// ParseMentions returns the usernames mentioned in a text, without duplicates
+func ParseMentions(text string) []string {
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// Trailing punctuation ends the sentence rather than the username
+		if username := strings.TrimRight(match[1], ".-"); username != "" {
+			usernames = append(usernames, username)
+		}
+	}
+	return uniqueStrings(usernames)
+}
+
+// CommentService handles document comments and notifies mentioned users
+type CommentService struct {
+	db                  *gorm.DB
+	authService         *AuthorizationService
+	notificationService *NotificationService
+	subscriptionService *SubscriptionService
+}
+
+// NewCommentService creates a new comment service
+func NewCommentService(authService *AuthorizationService, notificationService *NotificationService, subscriptionService *SubscriptionService) *CommentService {
+	return &CommentService{
+		db:                  database.GetDB(),
+		authService:         authService,
+		notificationService: notificationService,
+		subscriptionService: subscriptionService,
+	}
+}
+
+// GetByDocument retrieves the comments of a document, oldest first
+func (s *CommentService) GetByDocument(documentID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	if err := s.db.Preload("User").
+		Where("document_id = ?", documentID).
+		Order("created_at ASC, id ASC").
+		Find(&comments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	return comments, nil
+}
+
+// GetByID retrieves a comment of a document
+func (s *CommentService) GetByID(documentID, id uint) (*models.Comment, error) {
+	var comment models.Comment
+	if err := s.db.Preload("User").Where("document_id = ?", documentID).First(&comment, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	return &comment, nil
+}
+
+// Create adds a comment to a document and notifies the users it mentions and
+// the document's subscribers
+func (s *CommentService) Create(doc *models.Document, comment *models.Comment, author *models.User) error {
+	comment.DocumentID = doc.ID
+	comment.UserID = author.ID
+	if err := s.db.Create(comment).Error; err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	comment.User = *author
+
+	// Mentioned subscribers already got a mention
+	mentioned := s.notifyMentions(doc, comment, author, "")
+	s.subscriptionService.Publish(doc, &SubscriptionEvent{
+		Type:      models.NotificationComment,
+		ActorID:   author.ID,
+		CommentID: &comment.ID,
+		Message:   fmt.Sprintf("%s commented on %q", author.Username, doc.Title),
+	}, mentioned...)
+
+	return nil
+}
+
+// Update changes the content of a comment, notifying only users mentioned
+// for the first time
+func (s *CommentService) Update(doc *models.Document, comment *models.Comment, author *models.User, previousContent string) error {
+	if err := s.db.Model(comment).Update("content", comment.Content).Error; err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	s.notifyMentions(doc, comment, author, previousContent)
+
+	return nil
+}
+
+// Delete removes a comment
+func (s *CommentService) Delete(comment *models.Comment) error {
+	if err := s.db.Delete(comment).Error; err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+	return nil
+}
+
+// notifyMentions notifies active users mentioned in the comment but not in
+// its previous content and returns their IDs. Users who may not read the
+// document are skipped so mentions don't disclose it.
+func (s *CommentService) notifyMentions(doc *models.Document, comment *models.Comment, author *models.User, previousContent string) []uint {
+	previous := make(map[string]bool)
+	for _, username := range ParseMentions(previousContent) {
+		previous[username] = true
+	}
+
+	var usernames []string
+	for _, username := range ParseMentions(comment.Content) {
+		if !previous[username] && username != author.Username {
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	var users []models.User
+	if err := s.db.Where("organization_id = ? AND username IN ? AND is_active = ?", doc.OrganizationID, usernames, true).Find(&users).Error; err != nil {
+		log.Printf("Failed to resolve mentions in comment %d: %v", comment.ID, err)
+		return nil
+	}
+
+	var notified []uint
+	notifications := make([]models.Notification, 0, len(users))
+	for i := range users {
+		allowed, err := s.authService.CanRead(&users[i], doc)
+		if err != nil {
+			log.Printf("Failed to check access of user %d to document %d: %v", users[i].ID, doc.ID, err)
+			continue
+		}
+		if !allowed {
+			continue
+		}
+
+		notified = append(notified, users[i].ID)
+		notifications = append(notifications, models.Notification{
+			UserID:     users[i].ID,
+			Type:       models.NotificationMention,
+			ActorID:    &author.ID,
+			DocumentID: &doc.ID,
+			CommentID:  &comment.ID,
+			Message:    fmt.Sprintf("%s mentioned you in a comment on %q", author.Username, doc.Title),
+		})
+	}
+
+	if err := s.notificationService.Notify(notifications); err != nil {
+		log.Printf("Failed to notify mentions in comment %d: %v", comment.ID, err)
+		return nil
+	}
+
+	return notified
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/converter"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// formatPDF is the rendition format of PDF conversions
+	formatPDF = "pdf"
+	// mimeTypePDF is the content type of PDF files
+	mimeTypePDF = "application/pdf"
+)
+
+// ErrNotConvertible is returned for documents that can't be converted to PDF
+var ErrNotConvertible = errors.New("document can't be converted to PDF")
+
+// conversion is a conversion in progress that concurrent requests wait for
+type conversion struct {
+	done chan struct{}
+	pdf  []byte
+	err  error
+}
+
+// ConversionService renders office documents to PDF so they can be viewed
+// without native applications. Renditions are converted once per content
+// and cached as blobs.
+type ConversionService struct {
+	db              *gorm.DB
+	documentService *DocumentService
+	converter       converter.Converter
+	timeout         time.Duration
+	onUpload        bool
+
+	mu       sync.Mutex
+	inflight map[string]*conversion
+}
+
+// NewConversionService creates a new conversion service. With onUpload,
+// documents are converted in the background when uploaded rather than on
+// first view.
+func NewConversionService(documentService *DocumentService, converter converter.Converter, timeout time.Duration, onUpload bool) *ConversionService {
+	return &ConversionService{
+		db:              database.GetDB(),
+		documentService: documentService,
+		converter:       converter,
+		timeout:         timeout,
+		onUpload:        onUpload,
+		inflight:        make(map[string]*conversion),
+	}
+}
+
+// Convertible reports whether a PDF of the document can be provided
+func (s *ConversionService) Convertible(doc *models.Document) bool {
+	return doc.MimeType == mimeTypePDF || converter.Convertible(doc.FileName)
+}
+
+// IsOriginal reports whether the PDF of the document is its original file
+func (s *ConversionService) IsOriginal(doc *models.Document) bool {
+	return doc.MimeType == mimeTypePDF
+}
+
+// GetPDF returns the document as a PDF, converting it if no rendition of its
+// content exists yet. PDF documents are returned as they are.
+func (s *ConversionService) GetPDF(doc *models.Document) ([]byte, error) {
+	if doc.MimeType == mimeTypePDF {
+		return s.documentService.ReadContent(doc)
+	}
+	if !converter.Convertible(doc.FileName) {
+		return nil, ErrNotConvertible
+	}
+
+	var rendition models.Rendition
+	err := s.db.Where("source_hash = ? AND format = ?", doc.FileHash, formatPDF).First(&rendition).Error
+	if err == nil {
+		return s.readRendition(&rendition)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get rendition: %w", err)
+	}
+
+	return s.convert(doc)
+}
+
+// convert converts the document content to PDF and stores the rendition.
+// Concurrent requests for the same content share one conversion.
+func (s *ConversionService) convert(doc *models.Document) ([]byte, error) {
+	s.mu.Lock()
+	if running, ok := s.inflight[doc.FileHash]; ok {
+		s.mu.Unlock()
+		<-running.done
+		return running.pdf, running.err
+	}
+	running := &conversion{done: make(chan struct{})}
+	s.inflight[doc.FileHash] = running
+	s.mu.Unlock()
+
+	running.pdf, running.err = s.convertAndStore(doc)
+
+	s.mu.Lock()
+	delete(s.inflight, doc.FileHash)
+	s.mu.Unlock()
+	close(running.done)
+
+	return running.pdf, running.err
+}
+
+// convertAndStore runs the converter and stores the result as a rendition
+func (s *ConversionService) convertAndStore(doc *models.Document) ([]byte, error) {
+	content, err := s.documentService.ReadContent(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	pdf, err := s.converter.ConvertToPDF(ctx, doc.FileName, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert document: %w", err)
+	}
+
+	blob, err := s.documentService.storeBlob(pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Rendition{
+			SourceHash: doc.FileHash,
+			Format:     formatPDF,
+			BlobHash:   blob.Hash,
+			Size:       blob.Size,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return retainBlobs(tx, blob.Hash)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store rendition: %w", err)
+	}
+
+	return pdf, nil
+}
+
+// readRendition reads and decrypts the stored content of a rendition
+func (s *ConversionService) readRendition(rendition *models.Rendition) ([]byte, error) {
+	var blob models.Blob
+	if err := s.db.Where("hash = ?", rendition.BlobHash).First(&blob).Error; err != nil {
+		return nil, fmt.Errorf("failed to get rendition blob: %w", err)
+	}
+	if blob.ScanStatus == models.ScanInfected {
+		return nil, fmt.Errorf("rendition %d is quarantined", rendition.ID)
+	}
+
+	return s.documentService.readEncrypted(blob.Path, blob.DataKey, blob.KeyVersion)
+}
+
+// IndexDocument converts newly uploaded content in the background when
+// conversion on upload is enabled. Content awaiting or failing its virus
+// scan is left to be converted on first view.
+func (s *ConversionService) IndexDocument(ctx context.Context, doc *models.Document, content string) error {
+	if !s.onUpload || doc.MimeType == mimeTypePDF || !converter.Convertible(doc.FileName) {
+		return nil
+	}
+	if doc.ScanStatus == models.ScanPending || doc.ScanStatus == models.ScanInfected {
+		return nil
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Rendition{}).
+		Where("source_hash = ? AND format = ?", doc.FileHash, formatPDF).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check rendition: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err := s.convert(doc)
+	return err
+}
+
+// DeleteDocument keeps renditions, which are removed with their source content
+func (s *ConversionService) DeleteDocument(ctx context.Context, id uint) error {
+	return nil
+}
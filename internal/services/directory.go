@@ -0,0 +1,82 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// directoryRoles lists roles from most to least privileged; a user in the
+// groups of several roles gets the first
+var directoryRoles = []models.Role{models.RoleAdmin, models.RoleManager, models.RoleEmployee, models.RoleGuest}
+
+// DirectoryMapping maps directory groups to roles and departments. Groups
+// are matched case-insensitively by common name, so "CN=DMS Admins,OU=Groups,
+// DC=example,DC=com" matches "DMS Admins".
+type DirectoryMapping struct {
+	RoleGroups       map[models.Role][]string
+	DepartmentGroups []DepartmentGroup // The first group the user is in applies
+	DefaultRole      models.Role       // Role of users in no mapped group; empty refuses them
+}
+
+// DepartmentGroup assigns the members of a group to a department
+type DepartmentGroup struct {
+	Group      string
+	Department string
+}
+
+// Role returns the role of a member of groups, empty when the user may not sign in
+func (m *DirectoryMapping) Role(groups []string) models.Role {
+	for _, role := range directoryRoles {
+		for _, group := range m.RoleGroups[role] {
+			if memberOf(groups, group) {
+				return role
+			}
+		}
+	}
+	return m.DefaultRole
+}
+
+// Department returns the department of a member of groups, empty when no
+// group maps to one
+func (m *DirectoryMapping) Department(groups []string) string {
+	for _, mapping := range m.DepartmentGroups {
+		if memberOf(groups, mapping.Group) {
+			return mapping.Department
+		}
+	}
+	return ""
+}
+
+// memberOf reports whether groups, given as DNs or names, include name
+func memberOf(groups []string, name string) bool {
+	for _, group := range groups {
+		if strings.EqualFold(groupName(group), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupName returns the common name of a group DN, or the value itself when
+// it isn't a CN-first DN
+func groupName(dn string) string {
+	if len(dn) < 3 || !strings.EqualFold(dn[:3], "cn=") {
+		return dn
+	}
+	var name strings.Builder
+	for i := 3; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			if i+1 < len(dn) {
+				i++
+				name.WriteByte(dn[i])
+			}
+		case ',':
+			return name.String()
+		default:
+			name.WriteByte(dn[i])
+		}
+	}
+	return name.String()
+}
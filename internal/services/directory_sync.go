@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/directory"
+	"gorm.io/gorm"
+)
+
+// Actions of a directory sync on a user
+const (
+	DirectorySyncLinked      = "linked"
+	DirectorySyncUpdated     = "updated"
+	DirectorySyncDeactivated = "deactivated"
+)
+
+// ErrDirectorySyncRunning is returned when a sync is started during another
+var ErrDirectorySyncRunning = errors.New("a directory sync is already running")
+
+// DirectorySyncChange is the change of one user in a directory sync
+type DirectorySyncChange struct {
+	UserID   uint              `json:"user_id"`
+	Username string            `json:"username"`
+	Action   string            `json:"action"`
+	Reason   string            `json:"reason,omitempty"` // Why a user was deactivated
+	From     map[string]string `json:"from,omitempty"`
+	To       map[string]string `json:"to,omitempty"`
+}
+
+// DirectorySyncReport is a sync run with its changes
+type DirectorySyncReport struct {
+	models.DirectorySyncRun
+	Changes []DirectorySyncChange `json:"changes"`
+}
+
+// DirectorySyncService keeps the users of a corporate directory in line
+// with it: their role and department follow their groups, and users who
+// left the directory, were disabled there or are in no mapped group are
+// deactivated. It manages the users with the directory as their auth
+// source. Directories that don't authenticate users, such as Okta, also
+// take over local accounts with the email address of one of their users.
+// Deactivated users are not reactivated; that is left to administrators.
+type DirectorySyncService struct {
+	db          *gorm.DB
+	userService *UserService
+	source      directory.Source
+	mapping     DirectoryMapping
+	interval    time.Duration
+	dryRun      bool
+	running     sync.Mutex
+}
+
+// NewDirectorySyncService creates a new directory sync service running every
+// interval; 0 only syncs on demand. dryRun makes the scheduled runs only
+// report their changes.
+func NewDirectorySyncService(userService *UserService, source directory.Source, mapping DirectoryMapping, interval time.Duration, dryRun bool) *DirectorySyncService {
+	return &DirectorySyncService{
+		db:          database.GetDB(),
+		userService: userService,
+		source:      source,
+		mapping:     mapping,
+		interval:    interval,
+		dryRun:      dryRun,
+	}
+}
+
+// Start runs the scheduled syncs in the background
+func (s *DirectorySyncService) Start() {
+	if s.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			report, err := s.Run(context.Background(), s.dryRun, nil)
+			if err != nil {
+				log.Printf("Directory sync failed: %v", err)
+			} else {
+				log.Printf("Directory sync with %s: %d linked, %d updated, %d deactivated (dry run: %t)",
+					report.Source, report.Linked, report.Updated, report.Deactivated, report.DryRun)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Run syncs the users with the directory and saves the report. Dry runs
+// report the changes without applying them. The report of a failed sync is
+// returned along with the error when it could be saved.
+func (s *DirectorySyncService) Run(ctx context.Context, dryRun bool, triggeredBy *uint) (*DirectorySyncReport, error) {
+	if !s.running.TryLock() {
+		return nil, ErrDirectorySyncRunning
+	}
+	defer s.running.Unlock()
+
+	run := models.DirectorySyncRun{
+		Source:      s.source.Name(),
+		DryRun:      dryRun,
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now(),
+	}
+	changes, syncErr := s.sync(ctx, &run, dryRun)
+	if changes == nil {
+		changes = []DirectorySyncChange{}
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	if syncErr != nil {
+		run.Error = syncErr.Error()
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode directory sync changes: %w", err)
+	}
+	run.Changes = string(encoded)
+	if err := s.db.Create(&run).Error; err != nil {
+		return nil, fmt.Errorf("failed to save directory sync report: %w", err)
+	}
+
+	return &DirectorySyncReport{DirectorySyncRun: run, Changes: changes}, syncErr
+}
+
+// sync compares the managed users with the directory and applies the
+// changes unless dryRun is set
+func (s *DirectorySyncService) sync(ctx context.Context, run *models.DirectorySyncRun, dryRun bool) ([]DirectorySyncChange, error) {
+	entries, err := s.source.Users(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory users: %w", err)
+	}
+	// An empty listing is more likely a misconfigured filter than an empty company
+	if len(entries) == 0 {
+		return nil, errors.New("directory returned no users; nothing was changed")
+	}
+	run.DirectoryUsers = len(entries)
+
+	byUsername := make(map[string]*directory.User, len(entries))
+	byEmail := make(map[string]*directory.User, len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Username != "" {
+			byUsername[strings.ToLower(entry.Username)] = entry
+		}
+		if entry.Email != "" {
+			byEmail[strings.ToLower(entry.Email)] = entry
+		}
+	}
+
+	// LDAP users sign in with the directory, so local accounts, which
+	// would lose their passwords, are not taken over
+	authSource := s.source.Name()
+	link := authSource != models.AuthSourceLDAP
+
+	query := s.db.Where("auth_source = ?", authSource)
+	if link {
+		query = s.db.Where("auth_source IN ?", []string{authSource, models.AuthSourceLocal})
+	}
+	var users []models.User
+	if err := query.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	var changes []DirectorySyncChange
+	for i := range users {
+		user := &users[i]
+		linking := user.AuthSource != authSource
+
+		entry := byUsername[strings.ToLower(user.Username)]
+		if entry == nil || linking {
+			entry = byEmail[strings.ToLower(user.Email)]
+		}
+		if linking && (entry == nil || entry.Disabled || s.mapping.Role(entry.Groups) == "") {
+			continue
+		}
+
+		change, err := s.syncUser(user, entry, authSource, linking, dryRun)
+		if err != nil {
+			return changes, err
+		}
+		if change == nil {
+			continue
+		}
+		changes = append(changes, *change)
+		switch change.Action {
+		case DirectorySyncLinked:
+			run.Linked++
+		case DirectorySyncUpdated:
+			run.Updated++
+		case DirectorySyncDeactivated:
+			run.Deactivated++
+		}
+	}
+	return changes, nil
+}
+
+// syncUser brings a user in line with its directory entry, nil when it
+// left the directory. It returns nil when nothing changes.
+func (s *DirectorySyncService) syncUser(user *models.User, entry *directory.User, authSource string, linking, dryRun bool) (*DirectorySyncChange, error) {
+	var role models.Role
+	reason := ""
+	switch {
+	case entry == nil:
+		reason = "not_in_directory"
+	case entry.Disabled:
+		reason = "disabled_in_directory"
+	default:
+		if role = s.mapping.Role(entry.Groups); role == "" {
+			reason = "no_mapped_group"
+		}
+	}
+
+	if reason != "" {
+		if !user.IsActive {
+			return nil, nil
+		}
+		if !dryRun {
+			if err := s.userService.DeactivateUser(user.ID); err != nil {
+				return nil, err
+			}
+		}
+		return &DirectorySyncChange{UserID: user.ID, Username: user.Username, Action: DirectorySyncDeactivated, Reason: reason}, nil
+	}
+
+	department := s.mapping.Department(entry.Groups)
+	if department == "" {
+		department = entry.Department
+	}
+
+	change := &DirectorySyncChange{UserID: user.ID, Username: user.Username, Action: DirectorySyncUpdated, From: map[string]string{}, To: map[string]string{}}
+	if user.Role != role {
+		change.From["role"], change.To["role"] = string(user.Role), string(role)
+	}
+	if user.Department != department {
+		change.From["department"], change.To["department"] = user.Department, department
+	}
+	if linking {
+		change.Action = DirectorySyncLinked
+	} else if len(change.To) == 0 {
+		return nil, nil
+	}
+
+	if !dryRun {
+		if err := s.db.Model(user).
+			Select("role", "department", "auth_source").
+			Updates(&models.User{Role: role, Department: department, AuthSource: authSource}).Error; err != nil {
+			return nil, fmt.Errorf("failed to update user %d: %w", user.ID, err)
+		}
+	}
+	return change, nil
+}
+
+// GetRuns retrieves the sync reports without their changes, newest first
+func (s *DirectorySyncService) GetRuns(page, limit int) ([]models.DirectorySyncRun, int64, error) {
+	query := s.db.Model(&models.DirectorySyncRun{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count directory syncs: %w", err)
+	}
+
+	var runs []models.DirectorySyncRun
+	if err := query.Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&runs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get directory syncs: %w", err)
+	}
+
+	return runs, total, nil
+}
+
+// GetRun retrieves a sync report with its changes
+func (s *DirectorySyncService) GetRun(id uint) (*DirectorySyncReport, error) {
+	var run models.DirectorySyncRun
+	if err := s.db.First(&run, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get directory sync: %w", err)
+	}
+
+	report := &DirectorySyncReport{DirectorySyncRun: run, Changes: []DirectorySyncChange{}}
+	if run.Changes != "" {
+		if err := json.Unmarshal([]byte(run.Changes), &report.Changes); err != nil {
+			return nil, fmt.Errorf("failed to decode directory sync changes: %w", err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/dlp"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"gorm.io/gorm"
+)
+
+// DLPService scans uploaded text and PDF content for sensitive data. Uploads
+// with findings are either rejected or have their document's access level
+// raised, and the findings are recorded.
+type DLPService struct {
+	db       *gorm.DB
+	detector *dlp.Detector
+	hasher   *crypto.HashService
+	action   models.DLPAction
+	minLevel models.AccessLevel
+}
+
+// NewDLPService creates a new DLP service. detector may be nil when DLP is
+// disabled; documents with findings are raised to at least minLevel unless
+// action blocks them.
+func NewDLPService(detector *dlp.Detector, action models.DLPAction, minLevel models.AccessLevel) *DLPService {
+	return &DLPService{
+		db:       database.GetDB(),
+		detector: detector,
+		hasher:   crypto.NewHashService(),
+		action:   action,
+		minLevel: minLevel,
+	}
+}
+
+// Scan returns the sensitive data found in uploaded content. Content that
+// isn't text or PDF is not scanned.
+func (s *DLPService) Scan(mimeType, fileName string, content []byte) []dlp.Finding {
+	if s.detector == nil {
+		return nil
+	}
+
+	text := extractUploadText(mimeType, fileName, content)
+	if text == "" {
+		return nil
+	}
+
+	return s.detector.Scan(text)
+}
+
+// Action returns what is done about uploads with findings
+func (s *DLPService) Action() models.DLPAction {
+	return s.action
+}
+
+// RaisedLevel returns the access level a document with findings must have
+func (s *DLPService) RaisedLevel(level models.AccessLevel) models.AccessLevel {
+	return max(level, s.minLevel)
+}
+
+// RecordBlocked records the findings of a rejected upload. documentID is set
+// for rejected versions of an existing document.
+func (s *DLPService) RecordBlocked(findings []dlp.Finding, documentID *uint, userID uint, fileName string, content []byte) error {
+	return s.record(findings, models.DLPFinding{
+		DocumentID: documentID,
+		UserID:     userID,
+		FileName:   fileName,
+		FileHash:   s.hasher.SHA256(content),
+		Action:     models.DLPActionBlock,
+	})
+}
+
+// RecordRaised records the findings of a stored document whose access level
+// was raised to at least the minimum level
+func (s *DLPService) RecordRaised(findings []dlp.Finding, doc *models.Document, userID uint) error {
+	return s.record(findings, models.DLPFinding{
+		DocumentID:  &doc.ID,
+		Version:     doc.Version,
+		UserID:      userID,
+		FileName:    doc.FileName,
+		FileHash:    doc.FileHash,
+		Action:      models.DLPActionRaise,
+		AccessLevel: doc.AccessLevel,
+	})
+}
+
+// record stores one finding row per rule based on template
+func (s *DLPService) record(findings []dlp.Finding, template models.DLPFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	rows := make([]models.DLPFinding, 0, len(findings))
+	for _, finding := range findings {
+		row := template
+		row.Rule = finding.Rule
+		row.Count = finding.Count
+		row.Sample = finding.Sample
+		rows = append(rows, row)
+	}
+
+	if err := s.db.Create(&rows).Error; err != nil {
+		return fmt.Errorf("failed to record DLP findings: %w", err)
+	}
+	return nil
+}
+
+// DLPFindingFilter narrows the findings listed
+type DLPFindingFilter struct {
+	OrganizationID uint // Of the uploading user
+	DocumentID     *uint
+	UserID         *uint
+	Rule           string
+	Action         models.DLPAction
+}
+
+// GetFindings retrieves DLP findings, newest first
+func (s *DLPService) GetFindings(filter *DLPFindingFilter, page, limit int) ([]models.DLPFinding, int64, error) {
+	query := s.db.Model(&models.DLPFinding{}).
+		Where("user_id IN (?)", s.db.Model(&models.User{}).Unscoped().Select("id").Where("organization_id = ?", filter.OrganizationID))
+	if filter.DocumentID != nil {
+		query = query.Where("document_id = ?", *filter.DocumentID)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Rule != "" {
+		query = query.Where("rule = ?", filter.Rule)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count DLP findings: %w", err)
+	}
+
+	var findings []models.DLPFinding
+	if err := query.Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&findings).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get DLP findings: %w", err)
+	}
+
+	return findings, total, nil
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/storage"
+	"gorm.io/gorm"
+)
+
+// DocumentIndexer receives document changes for external search indexes
+type DocumentIndexer interface {
+	// IndexDocument creates or updates a document; an empty content keeps
+	// previously indexed content
+	IndexDocument(ctx context.Context, doc *models.Document, content string) error
+	// DeleteDocument removes a document from the index
+	DeleteDocument(ctx context.Context, id uint) error
+}
+
+// indexTimeout bounds background index updates
+const indexTimeout = 30 * time.Second
+
+// DocumentService handles document-related business logic
+type DocumentService struct {
+	db       *gorm.DB
+	storage  *storage.LocalStorage
+	envelope *crypto.EnvelopeService
+	hasher   *crypto.HashService
+	indexers []DocumentIndexer
+	scanning bool
+}
+
+// NewDocumentService creates a new document service
+func NewDocumentService(storage *storage.LocalStorage, envelope *crypto.EnvelopeService) *DocumentService {
+	return &DocumentService{
+		db:       database.GetDB(),
+		storage:  storage,
+		envelope: envelope,
+		hasher:   crypto.NewHashService(),
+	}
+}
+
+// Create stores the file content and creates the document record. Content
+// that is already stored is shared rather than stored again.
+func (s *DocumentService) Create(ctx context.Context, doc *models.Document, content []byte) error {
+	blob, err := s.storeBlob(content)
+	if err != nil {
+		return err
+	}
+
+	doc.FileHash = blob.Hash
+	doc.FileSize = int64(len(content))
+	doc.FilePath = blob.Path
+	doc.DataKey = blob.DataKey
+	doc.KeyVersion = blob.KeyVersion
+	doc.IsEncrypted = blob.DataKey != ""
+	doc.ScanStatus = blob.ScanStatus
+
+	// An unreferenced blob left by a failed transaction is garbage collected
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(doc).Error; err != nil {
+			return err
+		}
+
+		if err := syncDocumentTags(tx, doc, ParseTags(doc.Tags), doc.CreatedBy); err != nil {
+			return err
+		}
+
+		// Every document starts with its first version in the history
+		if err := tx.Create(newVersionSnapshot(doc, "Initial version")).Error; err != nil {
+			return err
+		}
+
+		// Referenced by both the document and its first version
+		return retainBlobs(tx, doc.FileHash, doc.FileHash)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create document: %w", err)
+	}
+
+	s.index(doc, extractText(doc.MimeType, doc.FileName, content))
+
+	return nil
+}
+
+// EnableScanning marks newly stored content as pending a virus scan
+func (s *DocumentService) EnableScanning() {
+	s.scanning = true
+}
+
+// AddIndexer registers an indexer notified of document changes
+func (s *DocumentService) AddIndexer(indexer DocumentIndexer) {
+	s.indexers = append(s.indexers, indexer)
+}
+
+// index pushes a document to all indexers in the background
+func (s *DocumentService) index(doc *models.Document, content string) {
+	if len(s.indexers) == 0 {
+		return
+	}
+
+	snapshot := *doc
+	go func() {
+		ctx, cancel := context.WithTimeout(database.SystemContext(context.Background()), indexTimeout)
+		defer cancel()
+
+		for _, indexer := range s.indexers {
+			if err := indexer.IndexDocument(ctx, &snapshot, content); err != nil {
+				log.Printf("Failed to index document %d: %v", snapshot.ID, err)
+			}
+		}
+	}()
+}
+
+// unindex removes a document from all indexers in the background
+func (s *DocumentService) unindex(id uint) {
+	if len(s.indexers) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(database.SystemContext(context.Background()), indexTimeout)
+		defer cancel()
+
+		for _, indexer := range s.indexers {
+			if err := indexer.DeleteDocument(ctx, id); err != nil {
+				log.Printf("Failed to remove document %d from index: %v", id, err)
+			}
+		}
+	}()
+}
+
+// GetByID retrieves a document by ID
+func (s *DocumentService) GetByID(ctx context.Context, id uint) (*models.Document, error) {
+	var doc models.Document
+	if err := s.db.WithContext(ctx).First(&doc, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	return &doc, nil
+}
+
+// GetAccessible retrieves documents the user may see matching the filter with pagination
+func (s *DocumentService) GetAccessible(ctx context.Context, user *models.User, filter *DocumentFilter, page, limit int) ([]models.Document, int64, error) {
+	var docs []models.Document
+	var total int64
+
+	offset := (page - 1) * limit
+
+	query := s.db.WithContext(ctx).Model(&models.Document{}).
+		Scopes(AccessibleDocuments(user), filter.Apply).
+		Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	if err := query.Order(filter.Order()).
+		Offset(offset).
+		Limit(limit).
+		Find(&docs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	return docs, total, nil
+}
+
+// Update updates document metadata
+func (s *DocumentService) Update(ctx context.Context, doc *models.Document) error {
+	if err := s.db.WithContext(ctx).Save(doc).Error; err != nil {
+		return fmt.Errorf("failed to update document: %w", err)
+	}
+
+	s.index(doc, "")
+
+	return nil
+}
+
+// Delete soft deletes a document, moving it to the trash
+func (s *DocumentService) Delete(ctx context.Context, id, userID uint) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Document{}).Where("id = ?", id).Update("deleted_by", userID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Document{}, id).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+
+	s.unindex(id)
+
+	return nil
+}
+
+// ReadContent reads and decrypts the file content of a document
+func (s *DocumentService) ReadContent(doc *models.Document) ([]byte, error) {
+	if !doc.IsEncrypted {
+		data, err := s.storage.Read(doc.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document: %w", err)
+		}
+		return data, nil
+	}
+
+	return s.readEncrypted(doc.FilePath, doc.DataKey, doc.KeyVersion)
+}
+
+// readEncrypted reads a stored file and decrypts it with its wrapped data key
+func (s *DocumentService) readEncrypted(path, dataKey string, keyVersion int) ([]byte, error) {
+	data, err := s.storage.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+
+	if dataKey == "" {
+		return nil, fmt.Errorf("document has no data key")
+	}
+
+	content, err := s.envelope.Open(data, dataKey, keyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt document: %w", err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// BatchOperation is a change applied to many documents at once
+type BatchOperation string
+
+const (
+	BatchTag         BatchOperation = "tag"          // Add tags
+	BatchMove        BatchOperation = "move"         // Move to a folder
+	BatchAccessLevel BatchOperation = "access_level" // Change the access level
+	BatchDelete      BatchOperation = "delete"       // Move to the trash
+)
+
+// BatchChange describes a batch operation and its parameters
+type BatchChange struct {
+	Operation   BatchOperation
+	Tags        []string           // Normalized tags to add
+	FolderID    *uint              // Target folder of a move, nil for none
+	AccessLevel models.AccessLevel // New access level
+}
+
+// ApplyBatch applies a change to all documents in a single transaction,
+// writing their audit entries and blockchain actions to the outbox in it;
+// either every document is changed or none is
+func (s *DocumentService) ApplyBatch(ctx context.Context, docs []*models.Document, change *BatchChange, userID uint, auditEntries []*models.AuditLog, chainActions []ChainAction) error {
+	ids := make([]uint, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		documents := tx.Model(&models.Document{}).Where("id IN ?", ids)
+
+		switch change.Operation {
+		case BatchTag:
+			for _, doc := range docs {
+				if err := syncDocumentTags(tx, doc, NormalizeTags(append(ParseTags(doc.Tags), change.Tags...)), userID); err != nil {
+					return err
+				}
+			}
+		case BatchMove:
+			if err := documents.Update("folder_id", change.FolderID).Error; err != nil {
+				return err
+			}
+		case BatchAccessLevel:
+			if err := documents.Update("access_level", change.AccessLevel).Error; err != nil {
+				return err
+			}
+		case BatchDelete:
+			if err := documents.Update("deleted_by", userID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.Document{}, ids).Error; err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown batch operation: %s", change.Operation)
+		}
+
+		if err := EnqueueAudit(tx, auditEntries...); err != nil {
+			return err
+		}
+		for _, action := range chainActions {
+			if _, err := EnqueueBlockchain(tx, action.DocumentID, userID, action.Action, action.Data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply batch %s: %w", change.Operation, err)
+	}
+
+	for _, doc := range docs {
+		switch change.Operation {
+		case BatchMove:
+			doc.FolderID = change.FolderID
+		case BatchAccessLevel:
+			doc.AccessLevel = change.AccessLevel
+		case BatchDelete:
+			s.unindex(doc.ID)
+			continue
+		}
+		s.index(doc, "")
+	}
+
+	return nil
+}
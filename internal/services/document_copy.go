@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// CopyOptions controls how a document is duplicated
+type CopyOptions struct {
+	FolderID           *uint
+	Title              string // Defaults to the source title
+	IncludeVersions    bool
+	IncludePermissions bool
+}
+
+// Move places a document in a folder, or outside any folder when folderID is nil
+func (s *DocumentService) Move(ctx context.Context, doc *models.Document, folderID *uint) error {
+	if err := s.db.WithContext(ctx).Model(doc).Update("folder_id", folderID).Error; err != nil {
+		return fmt.Errorf("failed to move document: %w", err)
+	}
+	doc.FolderID = folderID
+
+	s.index(doc, "")
+
+	return nil
+}
+
+// Copy duplicates a document owned by userID. The copy shares the stored
+// content of the source, so only new versions uploaded to either document
+// take up additional storage.
+func (s *DocumentService) Copy(ctx context.Context, doc *models.Document, opts CopyOptions, userID uint) (*models.Document, error) {
+	sources := []models.DocumentVersion{*newVersionSnapshot(doc, fmt.Sprintf("Copied from document %d", doc.ID))}
+	if opts.IncludeVersions {
+		var err error
+		if sources, err = s.versionHistory(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	versions := make([]models.DocumentVersion, 0, len(sources))
+	hashes := make([]string, 0, len(sources)+1)
+	for _, source := range sources {
+		version := source
+		version.ID = 0
+		if !opts.IncludeVersions {
+			version.Version = 1
+			version.CreatedBy = userID
+		}
+		versions = append(versions, version)
+		hashes = append(hashes, version.FileHash)
+	}
+
+	current := versions[len(versions)-1]
+	title := opts.Title
+	if title == "" {
+		title = doc.Title
+	}
+
+	copied := &models.Document{
+		OrganizationID: doc.OrganizationID,
+		Title:          title,
+		Description:    doc.Description,
+		FileName:       current.FileName,
+		FilePath:       current.FilePath,
+		FileHash:       current.FileHash,
+		FileSize:       current.FileSize,
+		MimeType:       current.MimeType,
+		Category:       doc.Category,
+		Tags:           doc.Tags,
+		AccessLevel:    doc.AccessLevel,
+		FolderID:       opts.FolderID,
+		IsEncrypted:    doc.IsEncrypted,
+		DataKey:        current.DataKey,
+		KeyVersion:     current.KeyVersion,
+		ScanStatus:     doc.ScanStatus,
+		Version:        current.Version,
+		CreatedBy:      userID,
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(copied).Error; err != nil {
+			return err
+		}
+
+		for i := range versions {
+			versions[i].DocumentID = copied.ID
+		}
+		if err := tx.Create(&versions).Error; err != nil {
+			return err
+		}
+		if err := retainBlobs(tx, append(hashes, copied.FileHash)...); err != nil {
+			return err
+		}
+
+		if err := syncDocumentTags(tx, copied, ParseTags(doc.Tags), userID); err != nil {
+			return err
+		}
+		if err := copyDocumentMetadata(tx, doc.ID, copied.ID, userID); err != nil {
+			return err
+		}
+
+		if !opts.IncludePermissions {
+			return nil
+		}
+
+		var permissions []models.Permission
+		if err := tx.Where("document_id = ?", doc.ID).Find(&permissions).Error; err != nil {
+			return err
+		}
+		for i := range permissions {
+			permissions[i].ID = 0
+			permissions[i].DocumentID = &copied.ID
+			permissions[i].GrantedBy = userID
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+		return tx.Create(&permissions).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy document: %w", err)
+	}
+
+	// Index the copy's content without failing the copy if it can't be read
+	content, err := s.ReadContent(copied)
+	if err != nil {
+		log.Printf("Failed to read content of copied document %d: %v", copied.ID, err)
+	}
+	s.index(copied, extractText(copied.MimeType, copied.FileName, content))
+
+	return copied, nil
+}
+
+// versionHistory returns all versions of a document, oldest first, including
+// the current state of documents created before version history was kept
+func (s *DocumentService) versionHistory(doc *models.Document) ([]models.DocumentVersion, error) {
+	var versions []models.DocumentVersion
+	if err := s.db.Where("document_id = ?", doc.ID).
+		Order("version ASC").
+		Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
+	}
+
+	if len(versions) == 0 || versions[len(versions)-1].Version != doc.Version {
+		versions = append(versions, *newVersionSnapshot(doc, "Initial version"))
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// documentSortColumns maps accepted sort keys to columns
+var documentSortColumns = map[string]string{
+	"created_at":   "documents.created_at",
+	"updated_at":   "documents.updated_at",
+	"title":        "documents.title",
+	"file_size":    "documents.file_size",
+	"access_level": "documents.access_level",
+	"category":     "documents.category",
+}
+
+// MetadataOperator compares a custom metadata value with a filter value
+type MetadataOperator string
+
+const (
+	MetadataEquals  MetadataOperator = "eq"
+	MetadataAtLeast MetadataOperator = "min"
+	MetadataAtMost  MetadataOperator = "max"
+)
+
+// metadataComparisons maps metadata operators to SQL comparisons
+var metadataComparisons = map[MetadataOperator]string{
+	MetadataEquals:  "=",
+	MetadataAtLeast: ">=",
+	MetadataAtMost:  "<=",
+}
+
+// MetadataCondition matches documents by a custom metadata value. Conditions
+// are resolved to typed values by MetadataService.ResolveFilter; unresolved
+// conditions compare the value as text.
+type MetadataCondition struct {
+	Key      string
+	Operator MetadataOperator
+	Value    string
+
+	fieldID uint
+	column  string
+	value   interface{}
+}
+
+// DocumentFilter holds combinable criteria for listing documents
+type DocumentFilter struct {
+	Category       string
+	Tags           []string
+	MatchAllTags   bool
+	MinAccessLevel models.AccessLevel
+	MaxAccessLevel models.AccessLevel
+	CreatedBy      uint
+	FolderID       *uint // Zero selects documents outside any folder
+	Department     string
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	UpdatedAfter   *time.Time
+	UpdatedBefore  *time.Time
+	MinSize        int64
+	MaxSize        int64
+	MimeType       string // Exact type or a wildcard such as "image/*"
+	Metadata       []MetadataCondition
+	SortBy         string
+	SortDesc       bool
+}
+
+// Validate checks the filter for unsupported values
+func (f *DocumentFilter) Validate() error {
+	if f.SortBy != "" {
+		if _, ok := documentSortColumns[f.SortBy]; !ok {
+			return fmt.Errorf("unsupported sort field: %s", f.SortBy)
+		}
+	}
+	if f.MinAccessLevel != 0 && f.MaxAccessLevel != 0 && f.MinAccessLevel > f.MaxAccessLevel {
+		return fmt.Errorf("min access level exceeds max access level")
+	}
+	if f.MinSize > 0 && f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return fmt.Errorf("min size exceeds max size")
+	}
+	for _, condition := range f.Metadata {
+		if _, ok := metadataComparisons[condition.Operator]; !ok {
+			return fmt.Errorf("unsupported metadata operator: %s", condition.Operator)
+		}
+	}
+	return nil
+}
+
+// Apply adds the filter conditions to a documents query
+func (f *DocumentFilter) Apply(db *gorm.DB) *gorm.DB {
+	if f.Category != "" {
+		db = db.Where("documents.category = ?", f.Category)
+	}
+
+	if len(f.Tags) > 0 {
+		tagged := db.Session(&gorm.Session{NewDB: true}).
+			Table("document_tags").
+			Select("document_tags.document_id").
+			Joins("JOIN tags ON tags.id = document_tags.tag_id").
+			Where("tags.name IN ?", f.Tags)
+		if f.MatchAllTags {
+			tagged = tagged.Group("document_tags.document_id").
+				Having("COUNT(DISTINCT tags.name) = ?", len(uniqueStrings(f.Tags)))
+		}
+		db = db.Where("documents.id IN (?)", tagged)
+	}
+
+	if f.FolderID != nil {
+		if *f.FolderID == 0 {
+			db = db.Where("documents.folder_id IS NULL")
+		} else {
+			db = db.Where("documents.folder_id = ?", *f.FolderID)
+		}
+	}
+
+	if f.MinAccessLevel != 0 {
+		db = db.Where("documents.access_level >= ?", f.MinAccessLevel)
+	}
+	if f.MaxAccessLevel != 0 {
+		db = db.Where("documents.access_level <= ?", f.MaxAccessLevel)
+	}
+
+	if f.CreatedBy != 0 {
+		db = db.Where("documents.created_by = ?", f.CreatedBy)
+	}
+	if f.Department != "" {
+		db = db.Where("documents.created_by IN (?)",
+			db.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Select("id").Where("department = ?", f.Department))
+	}
+
+	if f.CreatedAfter != nil {
+		db = db.Where("documents.created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		db = db.Where("documents.created_at <= ?", *f.CreatedBefore)
+	}
+	if f.UpdatedAfter != nil {
+		db = db.Where("documents.updated_at >= ?", *f.UpdatedAfter)
+	}
+	if f.UpdatedBefore != nil {
+		db = db.Where("documents.updated_at <= ?", *f.UpdatedBefore)
+	}
+
+	if f.MinSize > 0 {
+		db = db.Where("documents.file_size >= ?", f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		db = db.Where("documents.file_size <= ?", f.MaxSize)
+	}
+
+	if f.MimeType != "" {
+		if strings.HasSuffix(f.MimeType, "/*") {
+			db = db.Where("documents.mime_type LIKE ? ESCAPE '\\'", escapeLike(strings.TrimSuffix(f.MimeType, "*"))+"%")
+		} else {
+			db = db.Where("documents.mime_type = ?", f.MimeType)
+		}
+	}
+
+	for _, condition := range f.Metadata {
+		matching := db.Session(&gorm.Session{NewDB: true}).
+			Model(&models.DocumentMetadata{}).
+			Select("document_metadata.document_id")
+		if condition.column != "" {
+			matching = matching.Where("document_metadata.field_id = ?", condition.fieldID).
+				Where("document_metadata."+condition.column+" "+metadataComparisons[condition.Operator]+" ?", condition.value)
+		} else {
+			matching = matching.Joins("JOIN metadata_fields ON metadata_fields.id = document_metadata.field_id").
+				Where("metadata_fields.key = ?", condition.Key).
+				Where("document_metadata.text_value "+metadataComparisons[condition.Operator]+" ?", condition.Value)
+		}
+		db = db.Where("documents.id IN (?)", matching)
+	}
+
+	return db
+}
+
+// Order returns the ORDER BY clause for the filter
+func (f *DocumentFilter) Order() string {
+	column, ok := documentSortColumns[f.SortBy]
+	if !ok {
+		return "documents.created_at DESC"
+	}
+
+	if f.SortDesc {
+		return column + " DESC"
+	}
+	return column + " ASC"
+}
+
+// escapeLike escapes LIKE wildcards in user input
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
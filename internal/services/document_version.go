@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/textdiff"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// newVersionSnapshot creates a version record from the current state of a document
+func newVersionSnapshot(doc *models.Document, changeLog string) *models.DocumentVersion {
+	return &models.DocumentVersion{
+		DocumentID:  doc.ID,
+		Version:     doc.Version,
+		Title:       doc.Title,
+		Description: doc.Description,
+		FileName:    doc.FileName,
+		FilePath:    doc.FilePath,
+		FileHash:    doc.FileHash,
+		FileSize:    doc.FileSize,
+		MimeType:    doc.MimeType,
+		DataKey:     doc.DataKey,
+		KeyVersion:  doc.KeyVersion,
+		ChangeLog:   changeLog,
+		CreatedBy:   doc.CreatedBy,
+	}
+}
+
+// ensureInitialVersion records the current state of documents created before
+// version history was kept, so the history always contains the current file
+func ensureInitialVersion(tx *gorm.DB, doc *models.Document) error {
+	var count int64
+	if err := tx.Model(&models.DocumentVersion{}).
+		Where("document_id = ? AND version = ?", doc.ID, doc.Version).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	if err := tx.Create(newVersionSnapshot(doc, "Initial version")).Error; err != nil {
+		return err
+	}
+	return retainBlobs(tx, doc.FileHash)
+}
+
+// CreateVersion uploads new content for a document, recording it as the next version
+func (s *DocumentService) CreateVersion(ctx context.Context, doc *models.Document, content []byte, fileName, mimeType, changeLog string, userID uint) (*models.DocumentVersion, error) {
+	blob, err := s.storeBlob(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileName == "" {
+		fileName = doc.FileName
+	}
+
+	version := &models.DocumentVersion{
+		DocumentID:  doc.ID,
+		Title:       doc.Title,
+		Description: doc.Description,
+		FileName:    fileName,
+		FilePath:    blob.Path,
+		FileHash:    blob.Hash,
+		FileSize:    int64(len(content)),
+		MimeType:    mimeType,
+		DataKey:     blob.DataKey,
+		KeyVersion:  blob.KeyVersion,
+		ChangeLog:   changeLog,
+		CreatedBy:   userID,
+	}
+
+	if err := s.applyVersion(ctx, doc, version); err != nil {
+		return nil, err
+	}
+
+	s.index(doc, extractText(doc.MimeType, doc.FileName, content))
+
+	return version, nil
+}
+
+// RestoreVersion makes an earlier version current again by recording it as a
+// new version, so the history itself is never rewritten
+func (s *DocumentService) RestoreVersion(ctx context.Context, doc *models.Document, versionNumber int, userID uint) (*models.DocumentVersion, error) {
+	source, err := s.GetVersion(doc.ID, versionNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	version := &models.DocumentVersion{
+		DocumentID:  doc.ID,
+		Title:       source.Title,
+		Description: source.Description,
+		FileName:    source.FileName,
+		FilePath:    source.FilePath,
+		FileHash:    source.FileHash,
+		FileSize:    source.FileSize,
+		MimeType:    source.MimeType,
+		DataKey:     source.DataKey,
+		KeyVersion:  source.KeyVersion,
+		ChangeLog:   fmt.Sprintf("Restored from version %d", versionNumber),
+		CreatedBy:   userID,
+	}
+
+	if err := s.applyVersion(ctx, doc, version); err != nil {
+		return nil, err
+	}
+
+	s.index(doc, "")
+
+	return version, nil
+}
+
+// applyVersion stores the version as the document's next version and makes it current
+func (s *DocumentService) applyVersion(ctx context.Context, doc *models.Document, version *models.DocumentVersion) error {
+	var scanStatus models.ScanStatus
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the document row so concurrent uploads get distinct version numbers
+		var current models.Document
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, doc.ID).Error; err != nil {
+			return err
+		}
+
+		if err := ensureInitialVersion(tx, &current); err != nil {
+			return err
+		}
+
+		version.Version = current.Version + 1
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+
+		// The new version and the document now refer to the version's blob
+		if err := retainBlobs(tx, version.FileHash, version.FileHash); err != nil {
+			return err
+		}
+		if err := releaseBlobs(tx, current.FileHash); err != nil {
+			return err
+		}
+
+		// The document takes on the scan status of its new content
+		var blob models.Blob
+		if err := tx.Select("scan_status").Where("hash = ?", version.FileHash).First(&blob).Error; err != nil {
+			return err
+		}
+		scanStatus = blob.ScanStatus
+
+		return tx.Model(&current).Updates(map[string]interface{}{
+			"title":       version.Title,
+			"description": version.Description,
+			"file_name":   version.FileName,
+			"file_path":   version.FilePath,
+			"file_hash":   version.FileHash,
+			"file_size":   version.FileSize,
+			"mime_type":   version.MimeType,
+			"data_key":    version.DataKey,
+			"key_version": version.KeyVersion,
+			"scan_status": scanStatus,
+			"version":     version.Version,
+			// The new content hasn't been checked yet
+			"integrity_status":     "",
+			"integrity_issue":      "",
+			"integrity_checked_at": nil,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create document version: %w", err)
+	}
+
+	doc.Title = version.Title
+	doc.Description = version.Description
+	doc.FileName = version.FileName
+	doc.FilePath = version.FilePath
+	doc.FileHash = version.FileHash
+	doc.FileSize = version.FileSize
+	doc.MimeType = version.MimeType
+	doc.DataKey = version.DataKey
+	doc.KeyVersion = version.KeyVersion
+	doc.ScanStatus = scanStatus
+	doc.IntegrityStatus = ""
+	doc.IntegrityIssue = ""
+	doc.IntegrityCheckedAt = nil
+	doc.Version = version.Version
+
+	return nil
+}
+
+// GetVersions retrieves the version history of a document, newest first
+func (s *DocumentService) GetVersions(documentID uint) ([]models.DocumentVersion, error) {
+	var versions []models.DocumentVersion
+	if err := s.db.Where("document_id = ?", documentID).
+		Order("version DESC").
+		Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetVersion retrieves a specific version of a document
+func (s *DocumentService) GetVersion(documentID uint, versionNumber int) (*models.DocumentVersion, error) {
+	var version models.DocumentVersion
+	if err := s.db.Where("document_id = ? AND version = ?", documentID, versionNumber).
+		First(&version).Error; err != nil {
+		return nil, fmt.Errorf("failed to get document version: %w", err)
+	}
+	return &version, nil
+}
+
+// ReadVersionContent reads and decrypts the file content of a document version
+func (s *DocumentService) ReadVersionContent(version *models.DocumentVersion) ([]byte, error) {
+	return s.readEncrypted(version.FilePath, version.DataKey, version.KeyVersion)
+}
+
+// VersionFieldChange represents a changed metadata field between two versions
+type VersionFieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// VersionDiff describes the differences between two document versions
+type VersionDiff struct {
+	DocumentID  uint                          `json:"document_id"`
+	FromVersion int                           `json:"from_version"`
+	ToVersion   int                           `json:"to_version"`
+	Changes     map[string]VersionFieldChange `json:"changes"`
+	// ContentDiff is a unified diff, only available for text documents
+	ContentDiff          string `json:"content_diff,omitempty"`
+	ContentDiffAvailable bool   `json:"content_diff_available"`
+	ContentDiffError     string `json:"content_diff_error,omitempty"`
+}
+
+// DiffVersions compares two versions of a document
+func (s *DocumentService) DiffVersions(documentID uint, from, to int) (*VersionDiff, error) {
+	a, err := s.GetVersion(documentID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.GetVersion(documentID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &VersionDiff{
+		DocumentID:  documentID,
+		FromVersion: from,
+		ToVersion:   to,
+		Changes:     make(map[string]VersionFieldChange),
+	}
+
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"title", a.Title, b.Title},
+		{"description", a.Description, b.Description},
+		{"file_name", a.FileName, b.FileName},
+		{"file_size", a.FileSize, b.FileSize},
+		{"file_hash", a.FileHash, b.FileHash},
+		{"mime_type", a.MimeType, b.MimeType},
+	}
+	for _, field := range fields {
+		if field.from != field.to {
+			diff.Changes[field.name] = VersionFieldChange{From: field.from, To: field.to}
+		}
+	}
+
+	// Text diff only makes sense when both sides are text
+	if a.FileHash == b.FileHash || !isTextDocument(a.MimeType, a.FileName) || !isTextDocument(b.MimeType, b.FileName) {
+		return diff, nil
+	}
+
+	contentA, err := s.ReadVersionContent(a)
+	if err != nil {
+		return nil, err
+	}
+	contentB, err := s.ReadVersionContent(b)
+	if err != nil {
+		return nil, err
+	}
+
+	textA := extractText(a.MimeType, a.FileName, contentA)
+	textB := extractText(b.MimeType, b.FileName, contentB)
+
+	unified, err := textdiff.Unified(textA, textB, fmt.Sprintf("v%d/%s", from, a.FileName), fmt.Sprintf("v%d/%s", to, b.FileName), 3)
+	if err != nil {
+		diff.ContentDiffError = err.Error()
+		return diff, nil
+	}
+
+	diff.ContentDiff = unified
+	diff.ContentDiffAvailable = true
+
+	return diff, nil
+}
@@ -0,0 +1,468 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/filetype"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/storage"
+	"gorm.io/gorm"
+)
+
+// exportManifestName is the name of the manifest inside export archives
+const exportManifestName = "manifest.json"
+
+var (
+	// ErrExportNotReady is returned when downloading an export that isn't completed
+	ErrExportNotReady = errors.New("export is not ready for download")
+	// ErrExportTooLarge is returned when an export has more documents than allowed
+	ErrExportTooLarge = errors.New("too many documents to export")
+)
+
+// ExportManifest describes the contents of an export archive
+type ExportManifest struct {
+	ExportID   uint                     `json:"export_id"`
+	ExportedBy string                   `json:"exported_by"`
+	ExportedAt time.Time                `json:"exported_at"`
+	Documents  []ExportManifestDocument `json:"documents"`
+	Skipped    []ExportSkippedDocument  `json:"skipped"`
+}
+
+// ExportManifestDocument is the metadata of an exported document and its
+// path within the archive
+type ExportManifestDocument struct {
+	ID          uint               `json:"id"`
+	Path        string             `json:"path"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	FileName    string             `json:"file_name"`
+	FileHash    string             `json:"file_hash"`
+	FileSize    int64              `json:"file_size"`
+	MimeType    string             `json:"mime_type"`
+	Category    string             `json:"category"`
+	Tags        []string           `json:"tags"`
+	AccessLevel models.AccessLevel `json:"access_level"`
+	FolderID    *uint              `json:"folder_id"`
+	Version     int                `json:"version"`
+	CreatedBy   uint               `json:"created_by"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	Watermarked bool               `json:"watermarked"`
+}
+
+// ExportSkippedDocument is a requested document left out of an export
+type ExportSkippedDocument struct {
+	ID     uint   `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// ExportService builds zip archives of document sets in the background. The
+// archives are encrypted at rest and deleted after their retention period.
+type ExportService struct {
+	db                  *gorm.DB
+	documentService     *DocumentService
+	authService         *AuthorizationService
+	stepUpPolicy        *StepUpPolicy
+	justificationPolicy *JustificationPolicy
+	watermarkService    *WatermarkService
+	notificationService *NotificationService
+	auditService        *AuditService
+	storage             *storage.LocalStorage
+	envelope            *crypto.EnvelopeService
+	ttl                 time.Duration
+	maxDocuments        int
+	maxSize             int64
+}
+
+// NewExportService creates a new export service. Archives are kept for ttl
+// and hold at most maxDocuments documents totalling maxSize bytes.
+func NewExportService(
+	documentService *DocumentService,
+	authService *AuthorizationService,
+	stepUpPolicy *StepUpPolicy,
+	justificationPolicy *JustificationPolicy,
+	watermarkService *WatermarkService,
+	notificationService *NotificationService,
+	auditService *AuditService,
+	storage *storage.LocalStorage,
+	envelope *crypto.EnvelopeService,
+	ttl time.Duration,
+	maxDocuments int,
+	maxSize int64,
+) *ExportService {
+	return &ExportService{
+		db:                  database.GetDB(),
+		documentService:     documentService,
+		authService:         authService,
+		stepUpPolicy:        stepUpPolicy,
+		justificationPolicy: justificationPolicy,
+		watermarkService:    watermarkService,
+		notificationService: notificationService,
+		auditService:        auditService,
+		storage:             storage,
+		envelope:            envelope,
+		ttl:                 ttl,
+		maxDocuments:        maxDocuments,
+		maxSize:             maxSize,
+	}
+}
+
+// MaxDocuments returns the number of documents an export may hold
+func (s *ExportService) MaxDocuments() int {
+	return s.maxDocuments
+}
+
+// Start records an export of the documents and builds its archive in the
+// background. Access is checked again for every document when it is exported;
+// documents needing a fresh authentication are only exported when the user
+// authenticated at authTime recently enough, and documents needing a
+// justification only when the user gave a reason, which is audited.
+func (s *ExportService) Start(user *models.User, documentIDs []uint, authTime time.Time, reason, ipAddress, userAgent string) (*models.ExportJob, error) {
+	reason = strings.TrimSpace(reason)
+
+	if len(documentIDs) > s.maxDocuments {
+		return nil, ErrExportTooLarge
+	}
+
+	ids, err := json.Marshal(documentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode document IDs: %w", err)
+	}
+
+	job := &models.ExportJob{
+		UserID:         user.ID,
+		Status:         models.ExportProcessing,
+		DocumentIDs:    string(ids),
+		TotalDocuments: len(documentIDs),
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+
+	details := map[string]interface{}{"document_ids": documentIDs}
+	if reason != "" {
+		details["justification"] = reason
+	}
+	s.auditService.LogAction(user.ID, nil, "export_started", "export", strconv.Itoa(int(job.ID)), ipAddress, userAgent, details)
+
+	// The caller keeps its copy of the job for the response
+	processed := *job
+	go s.run(&processed, user, documentIDs, s.stepUpPolicy.Fresh(authTime), reason, ipAddress, userAgent)
+
+	return job, nil
+}
+
+// run builds, encrypts and stores the archive of an export job
+func (s *ExportService) run(job *models.ExportJob, user *models.User, documentIDs []uint, steppedUp bool, reason, ipAddress, userAgent string) {
+	archive, manifest, err := s.buildArchive(job, user, documentIDs, steppedUp, reason, ipAddress, userAgent)
+	if err == nil {
+		err = s.store(job, archive)
+	}
+
+	now := time.Now()
+	job.CompletedAt = &now
+	job.ExportedDocuments = len(manifest.Documents)
+	job.SkippedDocuments = len(manifest.Skipped)
+	if err != nil {
+		log.Printf("Export %d failed: %v", job.ID, err)
+		job.Status = models.ExportFailed
+		job.Error = err.Error()
+	} else {
+		expiresAt := now.Add(s.ttl)
+		job.Status = models.ExportCompleted
+		job.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Model(job).
+		Select("status", "exported_documents", "skipped_documents", "file_path", "file_size", "data_key", "key_version", "error", "expires_at", "completed_at").
+		Updates(job).Error; err != nil {
+		log.Printf("Failed to update export %d: %v", job.ID, err)
+	}
+
+	message := fmt.Sprintf("Your export of %d documents is ready to download", job.ExportedDocuments)
+	if job.Status == models.ExportFailed {
+		message = "Your export failed"
+	}
+	if err := s.notificationService.Notify([]models.Notification{{
+		UserID:  job.UserID,
+		Type:    models.NotificationExportReady,
+		Message: message,
+	}}); err != nil {
+		log.Printf("Failed to notify user %d of export %d: %v", job.UserID, job.ID, err)
+	}
+
+	s.auditService.LogAction(job.UserID, nil, "export_"+string(job.Status), "export", strconv.Itoa(int(job.ID)), ipAddress, userAgent, map[string]interface{}{
+		"exported_documents": job.ExportedDocuments,
+		"skipped_documents":  job.SkippedDocuments,
+	})
+}
+
+// buildArchive writes the permitted documents and the manifest into a zip
+// archive. Documents that can't be exported are listed as skipped.
+func (s *ExportService) buildArchive(job *models.ExportJob, user *models.User, documentIDs []uint, steppedUp bool, reason, ipAddress, userAgent string) ([]byte, *ExportManifest, error) {
+	manifest := &ExportManifest{
+		ExportID:   job.ID,
+		ExportedBy: user.Username,
+		ExportedAt: time.Now(),
+		Documents:  []ExportManifestDocument{},
+		Skipped:    []ExportSkippedDocument{},
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+
+	var size int64
+	for _, id := range documentIDs {
+		doc, content, watermarked, skipped := s.readDocument(id, user, steppedUp, reason != "")
+		if skipped == "" && size+int64(len(content)) > s.maxSize {
+			skipped = "export size limit reached"
+		}
+		if skipped != "" {
+			manifest.Skipped = append(manifest.Skipped, ExportSkippedDocument{ID: id, Reason: skipped})
+			continue
+		}
+
+		name := path.Join("documents", strconv.Itoa(int(doc.ID))+"_"+exportFileName(doc.FileName))
+		file, err := writer.Create(name)
+		if err != nil {
+			return nil, manifest, fmt.Errorf("failed to write archive: %w", err)
+		}
+		if _, err := file.Write(content); err != nil {
+			return nil, manifest, fmt.Errorf("failed to write archive: %w", err)
+		}
+		size += int64(len(content))
+
+		manifest.Documents = append(manifest.Documents, ExportManifestDocument{
+			ID:          doc.ID,
+			Path:        name,
+			Title:       doc.Title,
+			Description: doc.Description,
+			FileName:    doc.FileName,
+			FileHash:    doc.FileHash,
+			FileSize:    doc.FileSize,
+			MimeType:    doc.MimeType,
+			Category:    doc.Category,
+			Tags:        ParseTags(doc.Tags),
+			AccessLevel: doc.AccessLevel,
+			FolderID:    doc.FolderID,
+			Version:     doc.Version,
+			CreatedBy:   doc.CreatedBy,
+			CreatedAt:   doc.CreatedAt,
+			UpdatedAt:   doc.UpdatedAt,
+			Watermarked: watermarked,
+		})
+
+		details := map[string]interface{}{"export_id": job.ID}
+		if watermarked {
+			details["watermarked"] = true
+		}
+		if reason != "" {
+			details["justification"] = reason
+		}
+		s.auditService.LogAction(user.ID, &doc.ID, "document_export", "document", strconv.Itoa(int(doc.ID)), ipAddress, userAgent, details)
+	}
+
+	file, err := writer.Create(exportManifestName)
+	if err != nil {
+		return nil, manifest, fmt.Errorf("failed to write archive: %w", err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, manifest, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, manifest, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return buf.Bytes(), manifest, nil
+}
+
+// readDocument reads the content of a document for an export under the same
+// rules as a download. A non-empty reason tells why it was skipped.
+func (s *ExportService) readDocument(id uint, user *models.User, steppedUp, justified bool) (*models.Document, []byte, bool, string) {
+	// The export runs in the background on the user's behalf
+	doc, err := s.documentService.GetByID(UserContext(context.Background(), user), id)
+	if err != nil {
+		return nil, nil, false, "document not found"
+	}
+
+	allowed, err := s.authService.Can(user, doc, ActionDownload)
+	if err != nil {
+		return nil, nil, false, "failed to check permissions"
+	}
+	if !allowed {
+		return nil, nil, false, "insufficient permissions"
+	}
+	if !steppedUp && s.stepUpPolicy.Required(doc) {
+		return nil, nil, false, "fresh authentication required"
+	}
+	if !justified && s.justificationPolicy.Required(doc) {
+		return nil, nil, false, "reason required"
+	}
+
+	switch doc.ScanStatus {
+	case models.ScanPending:
+		return nil, nil, false, "awaiting virus scan"
+	case models.ScanInfected:
+		return nil, nil, false, "quarantined"
+	}
+
+	content, err := s.documentService.ReadContent(doc)
+	if err != nil {
+		return nil, nil, false, "failed to read document"
+	}
+
+	if !s.watermarkService.Applies(doc) || filetype.Detect(content) != "application/pdf" {
+		return doc, content, false, ""
+	}
+	if content, err = s.watermarkService.Stamp(content, doc, user); err != nil {
+		return nil, nil, false, "failed to watermark document"
+	}
+	return doc, content, true, ""
+}
+
+// exportFileName makes a document's file name safe to use inside an archive
+func exportFileName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+// store encrypts an export archive and saves it
+func (s *ExportService) store(job *models.ExportJob, archive []byte) error {
+	ciphertext, dataKey, keyVersion, err := s.envelope.Seal(archive)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt export: %w", err)
+	}
+
+	filePath, err := s.storage.SaveExport(job.ID, ciphertext)
+	if err != nil {
+		return err
+	}
+
+	job.FilePath = filePath
+	job.FileSize = int64(len(archive))
+	job.DataKey = dataKey
+	job.KeyVersion = keyVersion
+	return nil
+}
+
+// GetAllForUser retrieves the exports of a user, newest first
+func (s *ExportService) GetAllForUser(userID uint, page, limit int) ([]models.ExportJob, int64, error) {
+	query := s.db.Model(&models.ExportJob{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count exports: %w", err)
+	}
+
+	var jobs []models.ExportJob
+	if err := query.Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get exports: %w", err)
+	}
+
+	return jobs, total, nil
+}
+
+// GetForUser retrieves an export of a user
+func (s *ExportService) GetForUser(userID, id uint) (*models.ExportJob, error) {
+	var job models.ExportJob
+	if err := s.db.Where("user_id = ?", userID).First(&job, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get export: %w", err)
+	}
+	return &job, nil
+}
+
+// ReadArchive reads and decrypts the archive of a completed export
+func (s *ExportService) ReadArchive(job *models.ExportJob) ([]byte, error) {
+	if job.Status != models.ExportCompleted {
+		return nil, ErrExportNotReady
+	}
+
+	data, err := s.storage.Read(job.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export: %w", err)
+	}
+
+	archive, err := s.envelope.Open(data, job.DataKey, job.KeyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt export: %w", err)
+	}
+	return archive, nil
+}
+
+// DeleteExpired removes the archives of exports past their retention period
+func (s *ExportService) DeleteExpired() (int, error) {
+	var jobs []models.ExportJob
+	if err := s.db.Where("status = ? AND expires_at < ?", models.ExportCompleted, time.Now()).Find(&jobs).Error; err != nil {
+		return 0, fmt.Errorf("failed to get expired exports: %w", err)
+	}
+
+	deleted := 0
+	for i := range jobs {
+		if err := s.storage.Delete(jobs[i].FilePath); err != nil {
+			log.Printf("Failed to delete archive of export %d: %v", jobs[i].ID, err)
+			continue
+		}
+		if err := s.db.Model(&jobs[i]).Updates(map[string]interface{}{
+			"status":    models.ExportExpired,
+			"file_path": "",
+			"data_key":  "",
+		}).Error; err != nil {
+			return deleted, fmt.Errorf("failed to expire export: %w", err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// StartCleanup periodically deletes expired export archives
+func (s *ExportService) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deleted, err := s.DeleteExpired()
+			if err != nil {
+				log.Printf("Export cleanup failed: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Export cleanup removed %d expired archives", deleted)
+			}
+		}
+	}()
+}
+
+// FailInterrupted marks exports cut short by a restart as failed
+func (s *ExportService) FailInterrupted() error {
+	if err := s.db.Model(&models.ExportJob{}).
+		Where("status = ?", models.ExportProcessing).
+		Updates(map[string]interface{}{
+			"status":       models.ExportFailed,
+			"error":        "interrupted by a server restart",
+			"completed_at": time.Now(),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to update interrupted exports: %w", err)
+	}
+	return nil
+}
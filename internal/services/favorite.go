@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// ErrFavoriteNotFound is returned when removing a document the user hasn't bookmarked
+var ErrFavoriteNotFound = errors.New("favorite not found")
+
+// FavoriteService manages users' document bookmarks
+type FavoriteService struct {
+	db *gorm.DB
+}
+
+// NewFavoriteService creates a new favorite service
+func NewFavoriteService() *FavoriteService {
+	return &FavoriteService{
+		db: database.GetDB(),
+	}
+}
+
+// GetForUser retrieves the user's favorites in their order. Documents the
+// user may no longer read or that were deleted are left out.
+func (s *FavoriteService) GetForUser(ctx context.Context, user *models.User) ([]models.Favorite, error) {
+	var favorites []models.Favorite
+	if err := s.db.WithContext(ctx).Joins("Document").
+		Where("favorites.user_id = ?", user.ID).
+		Where("favorites.document_id IN (?)", s.db.WithContext(ctx).Model(&models.Document{}).Select("documents.id").Scopes(AccessibleDocuments(user))).
+		Order("favorites.position ASC, favorites.id ASC").
+		Find(&favorites).Error; err != nil {
+		return nil, fmt.Errorf("failed to get favorites: %w", err)
+	}
+	return favorites, nil
+}
+
+// Add bookmarks a document for the user at the given position, or at the end
+// of the list when position is nil. Adding a favorite twice moves it.
+func (s *FavoriteService) Add(userID, documentID uint, position *int) (*models.Favorite, error) {
+	var favorite models.Favorite
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND document_id = ?", userID, documentID).First(&favorite).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			var count int64
+			if err := tx.Model(&models.Favorite{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+				return err
+			}
+			favorite = models.Favorite{UserID: userID, DocumentID: documentID, Position: int(count)}
+			if err := tx.Create(&favorite).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+
+		if position == nil {
+			return nil
+		}
+		return moveFavorite(tx, &favorite, *position)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add favorite: %w", err)
+	}
+	return &favorite, nil
+}
+
+// Reorder places the given documents first, in the given order, followed by
+// the user's remaining favorites in their current order
+func (s *FavoriteService) Reorder(userID uint, documentIDs []uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var favorites []models.Favorite
+		if err := tx.Where("user_id = ?", userID).Order("position ASC, id ASC").Find(&favorites).Error; err != nil {
+			return err
+		}
+
+		byDocument := make(map[uint]*models.Favorite, len(favorites))
+		for i := range favorites {
+			byDocument[favorites[i].DocumentID] = &favorites[i]
+		}
+
+		placed := make(map[uint]bool, len(documentIDs))
+		list := make([]*models.Favorite, 0, len(favorites))
+		for _, id := range documentIDs {
+			if favorite, ok := byDocument[id]; ok && !placed[id] {
+				list = append(list, favorite)
+				placed[id] = true
+			}
+		}
+		for i := range favorites {
+			if !placed[favorites[i].DocumentID] {
+				list = append(list, &favorites[i])
+			}
+		}
+
+		return renumberFavorites(tx, list)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reorder favorites: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the user's bookmark of a document
+func (s *FavoriteService) Remove(userID, documentID uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND document_id = ?", userID, documentID).Delete(&models.Favorite{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrFavoriteNotFound
+		}
+
+		// Close the gap left in the list
+		var favorites []models.Favorite
+		if err := tx.Where("user_id = ?", userID).Order("position ASC, id ASC").Find(&favorites).Error; err != nil {
+			return err
+		}
+		list := make([]*models.Favorite, 0, len(favorites))
+		for i := range favorites {
+			list = append(list, &favorites[i])
+		}
+		return renumberFavorites(tx, list)
+	})
+	if errors.Is(err, ErrFavoriteNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove favorite: %w", err)
+	}
+	return nil
+}
+
+// moveFavorite moves a favorite to a position within the user's list
+func moveFavorite(tx *gorm.DB, favorite *models.Favorite, position int) error {
+	var favorites []models.Favorite
+	if err := tx.Where("user_id = ? AND id <> ?", favorite.UserID, favorite.ID).
+		Order("position ASC, id ASC").
+		Find(&favorites).Error; err != nil {
+		return err
+	}
+
+	if position < 0 {
+		position = 0
+	}
+	if position > len(favorites) {
+		position = len(favorites)
+	}
+
+	list := make([]*models.Favorite, 0, len(favorites)+1)
+	for i := range favorites {
+		if i == position {
+			list = append(list, favorite)
+		}
+		list = append(list, &favorites[i])
+	}
+	if position == len(favorites) {
+		list = append(list, favorite)
+	}
+
+	return renumberFavorites(tx, list)
+}
+
+// renumberFavorites stores consecutive positions for the favorites in list order
+func renumberFavorites(tx *gorm.DB, favorites []*models.Favorite) error {
+	for i, favorite := range favorites {
+		if favorite.Position == i {
+			continue
+		}
+		if err := tx.Model(favorite).Update("position", i).Error; err != nil {
+			return err
+		}
+		favorite.Position = i
+	}
+	return nil
+}
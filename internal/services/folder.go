@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrFolderNotEmpty is returned when deleting a folder that still has contents
+	ErrFolderNotEmpty = errors.New("folder is not empty")
+	// ErrFolderCycle is returned when a folder would become its own ancestor
+	ErrFolderCycle = errors.New("folder cannot be moved into itself or a subfolder")
+)
+
+// FolderService handles folder-related business logic
+type FolderService struct {
+	db *gorm.DB
+}
+
+// NewFolderService creates a new folder service
+func NewFolderService() *FolderService {
+	return &FolderService{
+		db: database.GetDB(),
+	}
+}
+
+// Create creates a new folder
+func (s *FolderService) Create(folder *models.Folder) error {
+	if err := s.db.Create(folder).Error; err != nil {
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a folder by ID
+func (s *FolderService) GetByID(id uint) (*models.Folder, error) {
+	var folder models.Folder
+	if err := s.db.First(&folder, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get folder: %w", err)
+	}
+	return &folder, nil
+}
+
+// GetChildren retrieves the subfolders of a folder, or the top-level folders
+// of an organization when parentID is nil
+func (s *FolderService) GetChildren(organizationID uint, parentID *uint) ([]models.Folder, error) {
+	query := s.db.Scopes(OfOrganization("folders", organizationID)).Order("name ASC")
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+
+	var folders []models.Folder
+	if err := query.Find(&folders).Error; err != nil {
+		return nil, fmt.Errorf("failed to get folders: %w", err)
+	}
+	return folders, nil
+}
+
+// Update updates a folder, refusing to move it below itself
+func (s *FolderService) Update(folder *models.Folder) error {
+	if folder.ParentID != nil {
+		isDescendant, err := s.IsWithin(*folder.ParentID, folder.ID)
+		if err != nil {
+			return err
+		}
+		if isDescendant {
+			return ErrFolderCycle
+		}
+	}
+
+	if err := s.db.Save(folder).Error; err != nil {
+		return fmt.Errorf("failed to update folder: %w", err)
+	}
+	return nil
+}
+
+// IsWithin checks whether a folder is the ancestor folder or lies below it
+func (s *FolderService) IsWithin(folderID, ancestorID uint) (bool, error) {
+	var count int64
+	err := s.db.Raw(`WITH RECURSIVE ancestors(id, parent_id, depth) AS (
+			SELECT id, parent_id, 1 FROM folders WHERE id = ?
+			UNION ALL
+			SELECT folders.id, folders.parent_id, ancestors.depth + 1 FROM folders
+			JOIN ancestors ON folders.id = ancestors.parent_id
+			WHERE ancestors.depth < ?
+		)
+		SELECT COUNT(*) FROM ancestors WHERE id = ?`, folderID, maxFolderDepth, ancestorID).
+		Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to get folder ancestors: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Delete soft deletes an empty folder along with its permissions
+func (s *FolderService) Delete(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var children, documents int64
+		if err := tx.Model(&models.Folder{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return fmt.Errorf("failed to count subfolders: %w", err)
+		}
+		// Documents the user can't see count as well
+		if err := database.AsSystem(tx).Model(&models.Document{}).Where("folder_id = ?", id).Count(&documents).Error; err != nil {
+			return fmt.Errorf("failed to count documents: %w", err)
+		}
+		if children > 0 || documents > 0 {
+			return ErrFolderNotEmpty
+		}
+
+		if err := tx.Where("folder_id = ?", id).Delete(&models.Permission{}).Error; err != nil {
+			return fmt.Errorf("failed to delete folder permissions: %w", err)
+		}
+		if err := tx.Where("folder_id = ?", id).Delete(&models.Subscription{}).Error; err != nil {
+			return fmt.Errorf("failed to delete folder subscriptions: %w", err)
+		}
+		if err := tx.Delete(&models.Folder{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete folder: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGroupNotFound is returned for unknown groups
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrGroupExists is returned when a group name is taken
+	ErrGroupExists = errors.New("group already exists")
+	// ErrNotGroupMember is returned when removing a user who isn't a member
+	ErrNotGroupMember = errors.New("user is not a member of the group")
+)
+
+// GroupWithCount is a group with its number of members
+type GroupWithCount struct {
+	models.Group
+	MemberCount int64 `json:"member_count"`
+}
+
+// GroupService manages groups of users that documents can be shared with
+type GroupService struct {
+	db *gorm.DB
+}
+
+// NewGroupService creates a new group service
+func NewGroupService() *GroupService {
+	return &GroupService{
+		db: database.GetDB(),
+	}
+}
+
+// GetGroups lists the groups of an organization with their member counts
+func (s *GroupService) GetGroups(organizationID uint) ([]GroupWithCount, error) {
+	var groups []GroupWithCount
+	if err := s.db.Model(&models.Group{}).Scopes(OfOrganization("groups", organizationID)).
+		Select("groups.*, (SELECT COUNT(*) FROM group_members WHERE group_members.group_id = groups.id) AS member_count").
+		Order("name ASC").
+		Scan(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+	return groups, nil
+}
+
+// GetGroup retrieves a group
+func (s *GroupService) GetGroup(id uint) (*models.Group, error) {
+	var group models.Group
+	if err := s.db.First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	return &group, nil
+}
+
+// GetMembers lists the members of a group with their users
+func (s *GroupService) GetMembers(groupID uint) ([]models.GroupMember, error) {
+	var members []models.GroupMember
+	if err := s.db.Preload("User").Where("group_id = ?", groupID).Order("id ASC").Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	return members, nil
+}
+
+// nameTaken checks whether another group of the organization has the name
+func (s *GroupService) nameTaken(organizationID uint, name string, excludeID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.Group{}).
+		Where("organization_id = ? AND name = ? AND id <> ?", organizationID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to get group: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create adds a group
+func (s *GroupService) Create(group *models.Group) error {
+	taken, err := s.nameTaken(group.OrganizationID, group.Name, 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrGroupExists
+	}
+
+	if err := s.db.Create(group).Error; err != nil {
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+	return nil
+}
+
+// Update saves the name and description of a group
+func (s *GroupService) Update(group *models.Group) error {
+	taken, err := s.nameTaken(group.OrganizationID, group.Name, group.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrGroupExists
+	}
+
+	if err := s.db.Model(group).Select("name", "description").Updates(group).Error; err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a group along with its memberships and the permissions
+// granted to it
+func (s *GroupService) Delete(group *models.Group) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.Permission{}).Error; err != nil {
+			return fmt.Errorf("failed to delete group permissions: %w", err)
+		}
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.GroupMember{}).Error; err != nil {
+			return fmt.Errorf("failed to delete group members: %w", err)
+		}
+		if err := tx.Delete(group).Error; err != nil {
+			return fmt.Errorf("failed to delete group: %w", err)
+		}
+		return nil
+	})
+}
+
+// AddMember adds a user to a group. Adding a member again changes nothing.
+func (s *GroupService) AddMember(groupID, userID, addedBy uint) (*models.GroupMember, error) {
+	member := &models.GroupMember{GroupID: groupID, UserID: userID, AddedBy: addedBy}
+	if err := s.db.Where("group_id = ? AND user_id = ?", groupID, userID).FirstOrCreate(member).Error; err != nil {
+		return nil, fmt.Errorf("failed to add group member: %w", err)
+	}
+	return member, nil
+}
+
+// RemoveMember removes a user from a group
+func (s *GroupService) RemoveMember(groupID, userID uint) error {
+	result := s.db.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&models.GroupMember{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove group member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotGroupMember
+	}
+	return nil
+}
+
+// GetUserGroups lists the groups a user belongs to
+func (s *GroupService) GetUserGroups(userID uint) ([]models.Group, error) {
+	var groups []models.Group
+	if err := s.db.Where("id IN (?)", s.db.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Order("name ASC").
+		Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user groups: %w", err)
+	}
+	return groups, nil
+}
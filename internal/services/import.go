@@ -0,0 +1,576 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/importer"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"gorm.io/gorm"
+)
+
+// importAuthorizationTTL is how long a user has to grant access after
+// starting an authorization
+const importAuthorizationTTL = 10 * time.Minute
+
+// importMetadataFields are the metadata fields preserving the details of
+// imported files at their source
+var importMetadataFields = []models.MetadataField{
+	{Key: "source_author", Label: "Source author", Type: models.MetadataText, Description: "Creator or owner of the file at the import source"},
+	{Key: "source_modified_at", Label: "Source modified at", Type: models.MetadataDate, Description: "Last modification of the file at the import source"},
+	{Key: "source_modified_by", Label: "Source modified by", Type: models.MetadataText, Description: "Last editor of the file at the import source"},
+	{Key: "source_url", Label: "Source URL", Type: models.MetadataText, Description: "Location of the file at the import source"},
+}
+
+var (
+	// ErrUnknownImportProvider is returned for providers that aren't configured
+	ErrUnknownImportProvider = errors.New("unknown import provider")
+	// ErrImportNotConnected is returned when the user hasn't authorized the provider
+	ErrImportNotConnected = errors.New("import provider is not connected")
+	// ErrInvalidImportState is returned for unknown or expired authorization callbacks
+	ErrInvalidImportState = errors.New("invalid or expired authorization state")
+	// ErrImportFolderUnavailable is returned when a selected folder can't be read from the provider
+	ErrImportFolderUnavailable = errors.New("selected folder can't be read")
+)
+
+// ImportService connects users to external sources through OAuth and pulls
+// the files of selected folders into documents in the background. Files go
+// through the same checks as a bulk upload; files unchanged since an earlier
+// import of the same user are skipped. The author and modification date of
+// each file at the source are kept as metadata.
+type ImportService struct {
+	db                  *gorm.DB
+	intake              *DocumentIntake
+	metadataService     *MetadataService
+	notificationService *NotificationService
+	auditService        *AuditService
+	envelope            *crypto.EnvelopeService
+	hasher              *crypto.HashService
+	providers           map[string]importer.Provider
+	maxFiles            int
+}
+
+// NewImportService creates a new import service for the given providers.
+// An import may hold at most maxFiles files.
+func NewImportService(
+	intake *DocumentIntake,
+	metadataService *MetadataService,
+	notificationService *NotificationService,
+	auditService *AuditService,
+	envelope *crypto.EnvelopeService,
+	providers []importer.Provider,
+	maxFiles int,
+) *ImportService {
+	byName := make(map[string]importer.Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+
+	return &ImportService{
+		db:                  database.GetDB(),
+		intake:              intake,
+		metadataService:     metadataService,
+		notificationService: notificationService,
+		auditService:        auditService,
+		envelope:            envelope,
+		hasher:              crypto.NewHashService(),
+		providers:           byName,
+		maxFiles:            maxFiles,
+	}
+}
+
+// EnsureMetadataFields defines the metadata fields of imported files
+func (s *ImportService) EnsureMetadataFields() error {
+	for _, field := range importMetadataFields {
+		if err := s.metadataService.EnsureField(&field); err != nil {
+			return fmt.Errorf("failed to define metadata field %s: %w", field.Key, err)
+		}
+	}
+	return nil
+}
+
+// Providers returns the names of the configured providers
+func (s *ImportService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetConnections retrieves the providers a user has connected
+func (s *ImportService) GetConnections(userID uint) ([]models.ImportConnection, error) {
+	var connections []models.ImportConnection
+	if err := s.db.Where("user_id = ? AND connected_at IS NOT NULL", userID).
+		Order("provider ASC").
+		Find(&connections).Error; err != nil {
+		return nil, fmt.Errorf("failed to get import connections: %w", err)
+	}
+	return connections, nil
+}
+
+// AuthorizationTTL returns how long a user has to grant access
+func (s *ImportService) AuthorizationTTL() time.Duration {
+	return importAuthorizationTTL
+}
+
+// Authorize starts connecting a provider and returns the URL where the user
+// grants access, along with a binding of the authorization to the browser
+// that started it, which the callback must present. An existing connection is
+// kept until access is granted again.
+func (s *ImportService) Authorize(userID uint, providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownImportProvider
+	}
+
+	state, err := crypto.GenerateRandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	expiresAt := time.Now().Add(importAuthorizationTTL)
+
+	connection := models.ImportConnection{UserID: userID, Provider: providerName}
+	if err := s.db.Where(&connection).FirstOrCreate(&connection).Error; err != nil {
+		return "", "", fmt.Errorf("failed to get import connection: %w", err)
+	}
+	if err := s.db.Model(&connection).Updates(map[string]interface{}{
+		"state":            state,
+		"state_expires_at": expiresAt,
+	}).Error; err != nil {
+		return "", "", fmt.Errorf("failed to update import connection: %w", err)
+	}
+
+	return provider.OAuth().AuthCodeURL(state), hashSecret(state), nil
+}
+
+// CompleteAuthorization exchanges the code of an authorization callback for
+// tokens and stores them sealed with the connection. The binding returned by
+// Authorize must match the state, so that a callback URL opened in another
+// browser connects nothing.
+func (s *ImportService) CompleteAuthorization(ctx context.Context, state, binding, code string) (*models.ImportConnection, error) {
+	if subtle.ConstantTimeCompare([]byte(hashSecret(state)), []byte(binding)) != 1 {
+		return nil, ErrInvalidImportState
+	}
+
+	var connection models.ImportConnection
+	if err := s.db.Where("state = ? AND state_expires_at > ?", state, time.Now()).First(&connection).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidImportState
+		}
+		return nil, fmt.Errorf("failed to get import connection: %w", err)
+	}
+
+	provider, ok := s.providers[connection.Provider]
+	if !ok {
+		return nil, ErrUnknownImportProvider
+	}
+
+	token, err := provider.OAuth().Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	account, err := provider.NewSource(provider.OAuth().TokenSource(token, nil)).Account(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s account: %w", connection.Provider, err)
+	}
+
+	now := time.Now()
+	connection.Account = account
+	connection.State = ""
+	connection.StateExpiresAt = nil
+	connection.ConnectedAt = &now
+	if err := s.sealTokens(&connection, token); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&connection).
+		Select("account", "state", "state_expires_at", "tokens", "data_key", "key_version", "connected_at").
+		Updates(&connection).Error; err != nil {
+		return nil, fmt.Errorf("failed to update import connection: %w", err)
+	}
+
+	return &connection, nil
+}
+
+// Disconnect deletes a user's connection and its tokens
+func (s *ImportService) Disconnect(userID uint, providerName string) error {
+	result := s.db.Where("user_id = ? AND provider = ?", userID, providerName).Delete(&models.ImportConnection{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete import connection: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrImportNotConnected
+	}
+	return nil
+}
+
+// sealTokens encrypts OAuth tokens into a connection
+func (s *ImportService) sealTokens(connection *models.ImportConnection, token *importer.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to encode tokens: %w", err)
+	}
+
+	ciphertext, dataKey, keyVersion, err := s.envelope.Seal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt tokens: %w", err)
+	}
+
+	connection.Tokens = base64.StdEncoding.EncodeToString(ciphertext)
+	connection.DataKey = dataKey
+	connection.KeyVersion = keyVersion
+	return nil
+}
+
+// source opens the tokens of a user's connection and returns a source
+// reading with them. Refreshed tokens are stored back.
+func (s *ImportService) source(userID uint, providerName string) (importer.Source, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownImportProvider
+	}
+
+	var connection models.ImportConnection
+	if err := s.db.Where("user_id = ? AND provider = ? AND connected_at IS NOT NULL", userID, providerName).
+		First(&connection).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImportNotConnected
+		}
+		return nil, fmt.Errorf("failed to get import connection: %w", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(connection.Tokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tokens: %w", err)
+	}
+	data, err := s.envelope.Open(ciphertext, connection.DataKey, connection.KeyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt tokens: %w", err)
+	}
+	var token importer.Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to decode tokens: %w", err)
+	}
+
+	tokens := provider.OAuth().TokenSource(&token, func(refreshed *importer.Token) {
+		if err := s.sealTokens(&connection, refreshed); err != nil {
+			log.Printf("Failed to store refreshed %s tokens of user %d: %v", providerName, userID, err)
+			return
+		}
+		if err := s.db.Model(&connection).Select("tokens", "data_key", "key_version").Updates(&connection).Error; err != nil {
+			log.Printf("Failed to store refreshed %s tokens of user %d: %v", providerName, userID, err)
+		}
+	})
+	return provider.NewSource(tokens), nil
+}
+
+// Folders lists the subfolders of a folder in a user's connected source,
+// the top level for ""
+func (s *ImportService) Folders(ctx context.Context, userID uint, providerName, parentID string) ([]importer.Folder, error) {
+	source, err := s.source(userID, providerName)
+	if err != nil {
+		return nil, err
+	}
+	return source.Folders(ctx, parentID)
+}
+
+// Start records an import of the selected folders and pulls their files in
+// the background. Files larger than maxFileSize are rejected individually.
+func (s *ImportService) Start(job *models.ImportJob, folders []models.ImportFolder, user *models.User, maxFileSize int64, ipAddress, userAgent string) error {
+	source, err := s.source(user.ID, job.Provider)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the folders, which also checks they are readable
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i := range folders {
+		folder, err := source.Folder(ctx, folders[i].ID)
+		if err != nil {
+			log.Printf("Failed to get %s folder %s of user %d: %v", job.Provider, folders[i].ID, user.ID, err)
+			return fmt.Errorf("%w: %s", ErrImportFolderUnavailable, folders[i].ID)
+		}
+		folders[i].ID = folder.ID
+		folders[i].Name = folder.Name
+	}
+
+	selected, err := json.Marshal(folders)
+	if err != nil {
+		return fmt.Errorf("failed to encode folders: %w", err)
+	}
+
+	job.UserID = user.ID
+	job.Folders = string(selected)
+	job.Status = models.ImportProcessing
+	if err := s.db.Create(job).Error; err != nil {
+		return fmt.Errorf("failed to create import: %w", err)
+	}
+
+	s.auditService.LogAction(user.ID, nil, "import_started", "import", strconv.Itoa(int(job.ID)), ipAddress, userAgent, map[string]interface{}{
+		"provider":  job.Provider,
+		"folders":   folders,
+		"folder_id": job.FolderID,
+	})
+
+	// The caller keeps its copy of the job for the response
+	processed := *job
+	go s.run(&processed, user, source, folders, maxFileSize, ipAddress, userAgent)
+
+	return nil
+}
+
+// importFile is a file of an import with the batch of its selected folder
+type importFile struct {
+	file  *importer.File
+	batch *IntakeBatch
+	path  string
+}
+
+// run lists the files of the selected folders and imports them one by one
+func (s *ImportService) run(job *models.ImportJob, user *models.User, source importer.Source, folders []models.ImportFolder, maxFileSize int64, ipAddress, userAgent string) {
+	// The import runs in the background on the user's behalf
+	ctx := UserContext(context.Background(), user)
+
+	files, err := s.list(ctx, job, user, source, folders, ipAddress, userAgent)
+	if err == nil {
+		job.TotalFiles = len(files)
+		s.db.Model(job).Update("total_files", job.TotalFiles)
+
+		for _, file := range files {
+			item := s.importFile(ctx, job, source, file, maxFileSize)
+			switch item.Status {
+			case models.ImportItemImported:
+				job.ImportedFiles++
+			case models.ImportItemSkipped:
+				job.SkippedFiles++
+			default:
+				job.FailedFiles++
+			}
+
+			if err := s.db.Create(item).Error; err != nil {
+				log.Printf("Failed to record import %d item %q: %v", job.ID, item.Path, err)
+			}
+			s.db.Model(job).Updates(map[string]interface{}{
+				"imported_files": job.ImportedFiles,
+				"skipped_files":  job.SkippedFiles,
+				"failed_files":   job.FailedFiles,
+			})
+		}
+	}
+
+	now := time.Now()
+	job.CompletedAt = &now
+	job.Status = models.ImportCompleted
+	message := fmt.Sprintf("Import finished: %d of %d files imported, %d unchanged, %d failed", job.ImportedFiles, job.TotalFiles, job.SkippedFiles, job.FailedFiles)
+	if err != nil {
+		log.Printf("Import %d failed: %v", job.ID, err)
+		job.Status = models.ImportFailed
+		job.Error = err.Error()
+		message = "Your import failed: " + job.Error
+	}
+	if err := s.db.Model(job).Select("status", "error", "completed_at").Updates(job).Error; err != nil {
+		log.Printf("Failed to complete import %d: %v", job.ID, err)
+	}
+
+	if err := s.notificationService.Notify([]models.Notification{{
+		UserID:  job.UserID,
+		Type:    models.NotificationImportCompleted,
+		Message: message,
+	}}); err != nil {
+		log.Printf("Failed to notify user %d of import %d: %v", job.UserID, job.ID, err)
+	}
+
+	s.auditService.LogAction(job.UserID, nil, "import_"+string(job.Status), "import", strconv.Itoa(int(job.ID)), ipAddress, userAgent, map[string]interface{}{
+		"total_files":    job.TotalFiles,
+		"imported_files": job.ImportedFiles,
+		"skipped_files":  job.SkippedFiles,
+		"failed_files":   job.FailedFiles,
+	})
+}
+
+// list walks the selected folders. Each folder is imported as a folder of
+// its name with its own category.
+func (s *ImportService) list(ctx context.Context, job *models.ImportJob, user *models.User, source importer.Source, folders []models.ImportFolder, ipAddress, userAgent string) ([]importFile, error) {
+	var files []importFile
+	for _, folder := range folders {
+		batch := NewIntakeBatch(user, job.FolderID, map[string]interface{}{"import_id": job.ID})
+		batch.Category = folder.Category
+		if batch.Category == "" {
+			batch.Category = job.Category
+		}
+		batch.Tags = job.Tags
+		batch.AccessLevel = job.AccessLevel
+		batch.AccessLevelReason = job.AccessLevelReason
+		batch.IPAddress = ipAddress
+		batch.UserAgent = userAgent
+
+		err := source.Walk(ctx, folder.ID, func(file *importer.File) error {
+			if len(files) >= s.maxFiles {
+				return fmt.Errorf("the selected folders hold more than %d files", s.maxFiles)
+			}
+			files = append(files, importFile{
+				file:  file,
+				batch: batch,
+				path:  path.Join(folder.Name, file.Path),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list folder %q: %w", folder.Name, err)
+		}
+	}
+	return files, nil
+}
+
+// importFile downloads a file and creates its document unless an earlier
+// import of the user already brought in the same content
+func (s *ImportService) importFile(ctx context.Context, job *models.ImportJob, source importer.Source, file importFile, maxFileSize int64) *models.ImportItem {
+	item := &models.ImportItem{
+		ImportJobID: job.ID,
+		ExternalID:  file.file.ID,
+		Path:        file.path,
+		Status:      models.ImportItemFailed,
+	}
+
+	if err := s.intake.CheckName(file.file.Name); err != nil {
+		item.Error = err.Error()
+		return item
+	}
+
+	content, err := source.Download(ctx, file.file, maxFileSize)
+	if err != nil {
+		if errors.Is(err, importer.ErrFileTooLarge) {
+			item.Error = err.Error()
+		} else {
+			log.Printf("Failed to download %q of import %d: %v", file.path, job.ID, err)
+			item.Error = "failed to download file"
+		}
+		return item
+	}
+	item.FileHash = s.hasher.SHA256(content)
+
+	documentID, err := s.previousImport(ctx, job, file.file.ID, item.FileHash)
+	if err != nil {
+		item.Error = "failed to check earlier imports"
+		return item
+	}
+	if documentID != nil {
+		item.DocumentID = documentID
+		item.Status = models.ImportItemSkipped
+		return item
+	}
+
+	doc, err := s.intake.Create(file.batch, file.path, content)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+
+	if values := sourceMetadata(file.file); len(values) > 0 {
+		if err := s.metadataService.SetDocumentMetadata(doc.ID, values, job.UserID); err != nil {
+			log.Printf("Failed to set source metadata of document %d: %v", doc.ID, err)
+		}
+	}
+
+	item.DocumentID = &doc.ID
+	item.Status = models.ImportItemImported
+	return item
+}
+
+// sourceMetadata returns the metadata values of the details a source
+// reported for a file
+func sourceMetadata(file *importer.File) map[string]interface{} {
+	values := map[string]interface{}{}
+	if file.Author != "" {
+		values["source_author"] = file.Author
+	}
+	if !file.ModifiedAt.IsZero() {
+		values["source_modified_at"] = file.ModifiedAt.UTC().Format(time.RFC3339)
+	}
+	if file.ModifiedBy != "" {
+		values["source_modified_by"] = file.ModifiedBy
+	}
+	if file.WebURL != "" {
+		values["source_url"] = file.WebURL
+	}
+	return values
+}
+
+// previousImport returns the document an earlier import of the job's user
+// created from the same file and content, if it still exists
+func (s *ImportService) previousImport(ctx context.Context, job *models.ImportJob, externalID, fileHash string) (*uint, error) {
+	var documentIDs []uint
+	if err := s.db.WithContext(ctx).Model(&models.ImportItem{}).
+		Joins("JOIN import_jobs ON import_jobs.id = import_items.import_job_id").
+		Joins("JOIN documents ON documents.id = import_items.document_id AND documents.deleted_at IS NULL").
+		Where("import_jobs.user_id = ? AND import_jobs.provider = ?", job.UserID, job.Provider).
+		Where("import_items.external_id = ? AND import_items.file_hash = ? AND import_items.status = ?", externalID, fileHash, models.ImportItemImported).
+		Limit(1).
+		Pluck("import_items.document_id", &documentIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get earlier imports: %w", err)
+	}
+	if len(documentIDs) == 0 {
+		return nil, nil
+	}
+	return &documentIDs[0], nil
+}
+
+// GetAllForUser retrieves the imports of a user, newest first
+func (s *ImportService) GetAllForUser(userID uint, page, limit int) ([]models.ImportJob, int64, error) {
+	query := s.db.Model(&models.ImportJob{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count imports: %w", err)
+	}
+
+	var jobs []models.ImportJob
+	if err := query.Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get imports: %w", err)
+	}
+
+	return jobs, total, nil
+}
+
+// GetForUser retrieves an import of a user with its per-file results
+func (s *ImportService) GetForUser(userID, id uint) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Where("user_id = ?", userID).First(&job, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get import: %w", err)
+	}
+	return &job, nil
+}
+
+// FailInterrupted marks imports cut short by a restart as failed. Running
+// the import again skips the files it already brought in.
+func (s *ImportService) FailInterrupted() error {
+	if err := s.db.Model(&models.ImportJob{}).
+		Where("status = ?", models.ImportProcessing).
+		Updates(map[string]interface{}{
+			"status":       models.ImportFailed,
+			"error":        "interrupted by a server restart",
+			"completed_at": time.Now(),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to update interrupted imports: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/ingest"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"gorm.io/gorm"
+)
+
+// IngestSource is a drop location with the settings of the documents
+// created from its files
+type IngestSource struct {
+	Drop        ingest.Drop
+	Owner       string // Username of the user the documents are created as
+	FolderID    *uint  // Folder the drop's root maps to; subdirectories become folders below it
+	Category    string
+	Department  string             // Granted read access; empty uses the owner's department
+	AccessLevel models.AccessLevel // 0 classifies each file
+}
+
+// EmailIngest is where the attachments of emails sent to an address are
+// filed. The sender's address identifies the owning user.
+type EmailIngest struct {
+	Address     string // Recipient address, such as docs@example.com
+	FolderID    *uint
+	Category    string
+	AccessLevel models.AccessLevel // 0 classifies each file
+}
+
+// emailIngestSource identifies emails in ingest records and audit details
+const emailIngestSource = "email"
+
+var (
+	// ErrEmailRecipient is returned for emails not sent to the ingest address
+	ErrEmailRecipient = errors.New("message was not sent to the ingest address")
+	// ErrUnknownSender is returned when the sender's address isn't an active user's
+	ErrUnknownSender = errors.New("sender is not an active user")
+)
+
+// IngestedFileFilter narrows a listing of ingested files
+type IngestedFileFilter struct {
+	Source    string
+	Succeeded *bool
+}
+
+// IngestService collects the files deposited in drop locations by scanners
+// and other systems and creates documents from them, putting each through
+// the same checks as a bulk upload. Collected files are removed from the
+// drop; rejected files stay there and are not retried until they change.
+// The attachments of inbound emails are filed the same way.
+type IngestService struct {
+	db                  *gorm.DB
+	intake              *DocumentIntake
+	userService         *UserService
+	notificationService *NotificationService
+	hasher              *crypto.HashService
+	sources             []IngestSource
+	email               *EmailIngest
+	interval            time.Duration
+	minAge              time.Duration
+	maxFileSize         int64
+}
+
+// NewIngestService creates a new ingest service polling sources every
+// interval. Files modified within minAge may still be written and wait for
+// the next poll. email may be nil when inbound emails aren't accepted.
+func NewIngestService(
+	intake *DocumentIntake,
+	userService *UserService,
+	notificationService *NotificationService,
+	sources []IngestSource,
+	email *EmailIngest,
+	interval, minAge time.Duration,
+	maxFileSize int64,
+) *IngestService {
+	return &IngestService{
+		db:                  database.GetDB(),
+		intake:              intake,
+		userService:         userService,
+		notificationService: notificationService,
+		hasher:              crypto.NewHashService(),
+		sources:             sources,
+		email:               email,
+		interval:            interval,
+		minAge:              minAge,
+		maxFileSize:         maxFileSize,
+	}
+}
+
+// AcceptsEmail reports whether inbound emails are filed
+func (s *IngestService) AcceptsEmail() bool {
+	return s.email != nil
+}
+
+// Start polls the sources in the background
+func (s *IngestService) Start() {
+	if len(s.sources) == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			for i := range s.sources {
+				ingested, err := s.Poll(&s.sources[i])
+				if err != nil {
+					log.Printf("Ingest from %s failed: %v", s.sources[i].Drop.Name(), err)
+				} else if ingested > 0 {
+					log.Printf("Ingest from %s created %d documents", s.sources[i].Drop.Name(), ingested)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Poll collects the files waiting in a source and returns how many
+// documents were created
+func (s *IngestService) Poll(source *IngestSource) (int, error) {
+	owner, err := s.userService.GetByUsername(source.Owner)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get owner %q: %w", source.Owner, err)
+	}
+	if !owner.IsActive {
+		return 0, fmt.Errorf("owner %q is not active", source.Owner)
+	}
+
+	session, err := source.Drop.Open()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open drop: %w", err)
+	}
+	defer session.Close()
+
+	files, err := session.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list drop: %w", err)
+	}
+
+	batch := NewIntakeBatch(owner, source.FolderID, map[string]interface{}{"ingest_source": source.Drop.Name()})
+	batch.Category = source.Category
+	batch.Department = source.Department
+	batch.AccessLevel = source.AccessLevel
+
+	ingested := 0
+	cutoff := time.Now().Add(-s.minAge)
+	for _, file := range files {
+		if file.ModifiedAt.After(cutoff) {
+			continue
+		}
+		if s.collect(source, session, batch, file) {
+			ingested++
+		}
+	}
+	return ingested, nil
+}
+
+// collect creates the document of a file unless the same file was already
+// collected, and reports whether a document was created
+func (s *IngestService) collect(source *IngestSource, session ingest.Session, batch *IntakeBatch, file ingest.File) bool {
+	name := source.Drop.Name()
+	modifiedAt := file.ModifiedAt.Truncate(time.Microsecond)
+
+	var previous models.IngestedFile
+	err := s.db.Where("source = ? AND path = ? AND size = ? AND modified_at = ?", name, file.Path, file.Size, modifiedAt).
+		Order("id DESC").
+		First(&previous).Error
+	if err == nil {
+		// A collected file whose removal failed is removed now
+		if previous.Succeeded {
+			s.remove(name, session, file.Path)
+		}
+		return false
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Failed to check earlier ingests of %q from %s: %v", file.Path, name, err)
+		return false
+	}
+
+	record := &models.IngestedFile{
+		Source:     name,
+		Path:       file.Path,
+		Size:       file.Size,
+		ModifiedAt: modifiedAt,
+	}
+
+	if err := s.intake.CheckName(path.Base(file.Path)); err != nil {
+		record.Error = err.Error()
+		s.record(record)
+		return false
+	}
+
+	content, err := session.Read(file.Path, s.maxFileSize)
+	if err != nil {
+		if !errors.Is(err, ingest.ErrFileTooLarge) {
+			// Read failures are retried with the next poll
+			log.Printf("Failed to read %q from %s: %v", file.Path, name, err)
+			return false
+		}
+		record.Error = err.Error()
+		s.record(record)
+		return false
+	}
+	record.FileHash = s.hasher.SHA256(content)
+
+	doc, err := s.intake.Create(batch, file.Path, content)
+	if err != nil {
+		record.Error = err.Error()
+		s.record(record)
+		return false
+	}
+
+	record.DocumentID = &doc.ID
+	record.Succeeded = true
+	s.record(record)
+	s.remove(name, session, file.Path)
+	return true
+}
+
+// IngestEmail files the attachments of an inbound email as documents of
+// the user with the sender's address, who is notified of the outcome
+func (s *IngestService) IngestEmail(message *ingest.Message, ipAddress, userAgent string) (*models.User, []models.IngestedFile, error) {
+	if s.email == nil {
+		return nil, nil, ErrEmailRecipient
+	}
+
+	addressed := false
+	for _, recipient := range message.Recipients {
+		if strings.EqualFold(recipient, s.email.Address) {
+			addressed = true
+			break
+		}
+	}
+	if !addressed {
+		return nil, nil, ErrEmailRecipient
+	}
+
+	user, err := s.userService.GetByEmail(message.From)
+	if err != nil || !user.IsActive {
+		return nil, nil, ErrUnknownSender
+	}
+
+	batch := NewIntakeBatch(user, s.email.FolderID, map[string]interface{}{
+		"ingest_source": emailIngestSource,
+		"email_subject": message.Subject,
+	})
+	batch.Category = s.email.Category
+	batch.AccessLevel = s.email.AccessLevel
+	batch.IPAddress = ipAddress
+	batch.UserAgent = userAgent
+
+	receivedAt := time.Now()
+	files := make([]models.IngestedFile, 0, len(message.Attachments))
+	failed := 0
+	for _, attachment := range message.Attachments {
+		file := s.fileAttachment(batch, attachment, receivedAt)
+		if !file.Succeeded {
+			failed++
+		}
+		files = append(files, *file)
+	}
+
+	text := fmt.Sprintf("%d of %d attachments of your email %q were filed as documents", len(files)-failed, len(files), message.Subject)
+	if len(files) == 0 {
+		text = fmt.Sprintf("Your email %q had no attachments to file", message.Subject)
+	}
+	if err := s.notificationService.Notify([]models.Notification{{
+		UserID:  user.ID,
+		Type:    models.NotificationEmailIngested,
+		Message: text,
+	}}); err != nil {
+		log.Printf("Failed to notify user %d of an ingested email: %v", user.ID, err)
+	}
+
+	return user, files, nil
+}
+
+// fileAttachment creates the document of an email attachment and records the outcome
+func (s *IngestService) fileAttachment(batch *IntakeBatch, attachment ingest.Attachment, receivedAt time.Time) *models.IngestedFile {
+	// Attachments are filed directly in the target folder
+	name := path.Base(strings.ReplaceAll(attachment.Name, "\\", "/"))
+	if name == "." || name == ".." || name == "/" {
+		name = "attachment"
+	}
+
+	record := &models.IngestedFile{
+		Source:     emailIngestSource,
+		Path:       name,
+		Size:       int64(len(attachment.Content)),
+		ModifiedAt: receivedAt,
+		FileHash:   s.hasher.SHA256(attachment.Content),
+	}
+	defer s.record(record)
+
+	if record.Size > s.maxFileSize {
+		record.Error = ingest.ErrFileTooLarge.Error()
+		return record
+	}
+
+	doc, err := s.intake.Create(batch, name, attachment.Content)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+
+	record.DocumentID = &doc.ID
+	record.Succeeded = true
+	return record
+}
+
+// record stores the outcome of collecting a file
+func (s *IngestService) record(file *models.IngestedFile) {
+	if err := s.db.Create(file).Error; err != nil {
+		log.Printf("Failed to record ingest of %q from %s: %v", file.Path, file.Source, err)
+	}
+}
+
+// remove deletes a collected file from the drop
+func (s *IngestService) remove(name string, session ingest.Session, filePath string) {
+	if err := session.Remove(filePath); err != nil {
+		log.Printf("Failed to remove %q from %s: %v", filePath, name, err)
+	}
+}
+
+// GetFiles retrieves the outcomes of collected files, newest first
+func (s *IngestService) GetFiles(filter *IngestedFileFilter, page, limit int) ([]models.IngestedFile, int64, error) {
+	query := s.db.Model(&models.IngestedFile{})
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.Succeeded != nil {
+		query = query.Where("succeeded = ?", *filter.Succeeded)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count ingested files: %w", err)
+	}
+
+	var files []models.IngestedFile
+	if err := query.Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&files).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get ingested files: %w", err)
+	}
+
+	return files, total, nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/filetype"
+)
+
+// DocumentIntake creates documents from files arriving in bulk, from zip
+// archives or external sources, putting each through the same checks as a
+// single upload
+type DocumentIntake struct {
+	documentService       *DocumentService
+	folderService         *FolderService
+	authService           *AuthorizationService
+	classificationService *ClassificationService
+	dlpService            *DLPService
+	auditService          *AuditService
+	fileTypes             *filetype.Policy
+}
+
+// NewDocumentIntake creates a new document intake
+func NewDocumentIntake(
+	documentService *DocumentService,
+	folderService *FolderService,
+	authService *AuthorizationService,
+	classificationService *ClassificationService,
+	dlpService *DLPService,
+	auditService *AuditService,
+	fileTypes *filetype.Policy,
+) *DocumentIntake {
+	return &DocumentIntake{
+		documentService:       documentService,
+		folderService:         folderService,
+		authService:           authService,
+		classificationService: classificationService,
+		dlpService:            dlpService,
+		auditService:          auditService,
+		fileTypes:             fileTypes,
+	}
+}
+
+// IntakeBatch holds what the files of one bulk upload or import share
+type IntakeBatch struct {
+	User              *models.User
+	Category          string
+	Tags              string
+	AccessLevel       models.AccessLevel // 0 classifies each file
+	AccessLevelReason string
+	IPAddress         string
+	UserAgent         string
+
+	// Department the files belong to, classified as the department's and
+	// granted read access. Empty uses the user's department.
+	Department string
+
+	// Source identifies the bulk upload or import in audit details
+	Source map[string]interface{}
+
+	// Folders created or found for the batch's directories, by path
+	folders map[string]*uint
+}
+
+// NewIntakeBatch creates a batch whose files are placed below folderID
+func NewIntakeBatch(user *models.User, folderID *uint, source map[string]interface{}) *IntakeBatch {
+	return &IntakeBatch{
+		User:    user,
+		Source:  source,
+		folders: map[string]*uint{"": folderID},
+	}
+}
+
+// details adds the batch source to audit details
+func (b *IntakeBatch) details(values map[string]interface{}) map[string]interface{} {
+	for key, value := range b.Source {
+		values[key] = value
+	}
+	return values
+}
+
+// department returns the department the batch's files belong to
+func (b *IntakeBatch) department() string {
+	if b.Department != "" {
+		return b.Department
+	}
+	return b.User.Department
+}
+
+// CheckName rejects files by name before their content is read
+func (s *DocumentIntake) CheckName(fileName string) error {
+	return s.fileTypes.CheckName(fileName)
+}
+
+// Create creates the document of a file at a slash-separated path of the
+// batch, creating folders for its directories. The returned error is
+// reported to the user.
+func (s *DocumentIntake) Create(batch *IntakeBatch, filePath string, content []byte) (*models.Document, error) {
+	user := batch.User
+
+	// Files can't escape the target folder
+	name := path.Clean(strings.ReplaceAll(filePath, "\\", "/"))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return nil, errors.New("invalid path")
+	}
+
+	fileName := path.Base(name)
+	if err := s.fileTypes.CheckName(fileName); err != nil {
+		return nil, err
+	}
+
+	mimeType, err := s.fileTypes.Check(fileName, content)
+	if err != nil {
+		return nil, err
+	}
+
+	folderID, err := s.ensureFolder(batch, path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &models.Document{
+		OrganizationID: user.OrganizationID,
+		Title:          fileName,
+		FileName:       fileName,
+		MimeType:       mimeType,
+		Category:       batch.Category,
+		Tags:           batch.Tags,
+		AccessLevel:    batch.AccessLevel,
+		FolderID:       folderID,
+		Version:        1,
+		CreatedBy:      user.ID,
+	}
+
+	classification, err := s.classificationService.Classify(&ClassificationInput{
+		Title:      doc.Title,
+		Category:   doc.Category,
+		Department: batch.department(),
+		FileName:   doc.FileName,
+		MimeType:   doc.MimeType,
+		Content:    content,
+	})
+	if err != nil {
+		return nil, errors.New("failed to classify document")
+	}
+	switch {
+	case doc.AccessLevel == 0:
+		doc.AccessLevel = max(models.AccessInternal, classification.AccessLevel)
+	case doc.AccessLevel < classification.AccessLevel && strings.TrimSpace(batch.AccessLevelReason) == "":
+		return nil, fmt.Errorf("access level is below the suggested classification %d", classification.AccessLevel)
+	}
+
+	findings := s.dlpService.Scan(doc.MimeType, doc.FileName, content)
+	if len(findings) > 0 && s.dlpService.Action() == models.DLPActionBlock {
+		if err := s.dlpService.RecordBlocked(findings, nil, user.ID, fileName, content); err != nil {
+			log.Printf("Failed to record DLP findings of %q: %v", name, err)
+		}
+		s.auditService.LogAction(user.ID, nil, "dlp_upload_blocked", "document", "", batch.IPAddress, batch.UserAgent, batch.details(map[string]interface{}{
+			"file_name": fileName,
+			"findings":  findings,
+		}))
+		return nil, errors.New("file contains sensitive content")
+	}
+	classifiedLevel := doc.AccessLevel
+	if len(findings) > 0 {
+		doc.AccessLevel = s.dlpService.RaisedLevel(doc.AccessLevel)
+	}
+
+	if err := s.documentService.Create(UserContext(context.Background(), user), doc, content); err != nil {
+		return nil, errors.New("failed to create document")
+	}
+
+	if len(findings) > 0 {
+		s.dlpService.RecordRaised(findings, doc, user.ID)
+		s.auditService.LogAction(user.ID, &doc.ID, "dlp_access_level_raised", "document", strconv.Itoa(int(doc.ID)), batch.IPAddress, batch.UserAgent, map[string]interface{}{
+			"version":        doc.Version,
+			"findings":       findings,
+			"previous_level": classifiedLevel,
+			"access_level":   doc.AccessLevel,
+		})
+	}
+
+	details := batch.details(map[string]interface{}{
+		"title":     doc.Title,
+		"file_name": doc.FileName,
+		"file_hash": doc.FileHash,
+		"path":      name,
+	})
+	if len(classification.Rules) > 0 {
+		details["suggested_access_level"] = classification.AccessLevel
+		details["classification_rules"] = classification.Rules
+	}
+	s.auditService.LogAction(user.ID, &doc.ID, "document_create", "document", strconv.Itoa(int(doc.ID)), batch.IPAddress, batch.UserAgent, details)
+
+	if batch.Department != "" {
+		s.grantDepartment(batch, doc)
+	}
+
+	if doc.AccessLevel < classification.AccessLevel {
+		s.auditService.LogAction(user.ID, &doc.ID, "classification_override", "document", strconv.Itoa(int(doc.ID)), batch.IPAddress, batch.UserAgent, batch.details(map[string]interface{}{
+			"access_level":           doc.AccessLevel,
+			"suggested_access_level": classification.AccessLevel,
+			"rules":                  classification.Rules,
+			"reason":                 strings.TrimSpace(batch.AccessLevelReason),
+		}))
+	}
+
+	return doc, nil
+}
+
+// grantDepartment gives the batch's department read access to a document.
+// The document is created; failing to grant access doesn't undo it.
+func (s *DocumentIntake) grantDepartment(batch *IntakeBatch, doc *models.Document) {
+	department := batch.Department
+	permission := &models.Permission{
+		DocumentID: &doc.ID,
+		Department: &department,
+		CanRead:    true,
+		GrantedBy:  batch.User.ID,
+	}
+	if err := s.authService.SetPermission(UserContext(context.Background(), batch.User), permission); err != nil {
+		log.Printf("Failed to grant department %q access to document %d: %v", department, doc.ID, err)
+		return
+	}
+
+	s.auditService.LogAction(batch.User.ID, &doc.ID, "permission_grant", "document", strconv.Itoa(int(doc.ID)), batch.IPAddress, batch.UserAgent, batch.details(map[string]interface{}{
+		"permission_id": permission.ID,
+		"department":    department,
+		"can_read":      true,
+	}))
+}
+
+// ensureFolder resolves the folder of a batch directory, reusing a folder
+// of the same name the user can write to or creating it
+func (s *DocumentIntake) ensureFolder(batch *IntakeBatch, dir string) (*uint, error) {
+	if dir == "." {
+		dir = ""
+	}
+	if folderID, ok := batch.folders[dir]; ok {
+		return folderID, nil
+	}
+
+	parentID, err := s.ensureFolder(batch, path.Dir(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	name := path.Base(dir)
+	children, err := s.folderService.GetChildren(batch.User.OrganizationID, parentID)
+	if err != nil {
+		return nil, errors.New("failed to get folders")
+	}
+	for i := range children {
+		if children[i].Name != name {
+			continue
+		}
+		allowed, err := s.authService.CanOnFolder(batch.User, &children[i], ActionWrite)
+		if err != nil {
+			return nil, errors.New("failed to check folder permissions")
+		}
+		if !allowed {
+			return nil, fmt.Errorf("insufficient permissions on folder %q", dir)
+		}
+		batch.folders[dir] = &children[i].ID
+		return batch.folders[dir], nil
+	}
+
+	folder := &models.Folder{OrganizationID: batch.User.OrganizationID, Name: name, ParentID: parentID, CreatedBy: batch.User.ID}
+	if err := s.folderService.Create(folder); err != nil {
+		return nil, fmt.Errorf("failed to create folder %q", dir)
+	}
+
+	s.auditService.LogAction(batch.User.ID, nil, "folder_create", "folder", strconv.Itoa(int(folder.ID)), batch.IPAddress, batch.UserAgent, batch.details(map[string]interface{}{
+		"name":      folder.Name,
+		"parent_id": folder.ParentID,
+	}))
+
+	batch.folders[dir] = &folder.ID
+	return batch.folders[dir], nil
+}
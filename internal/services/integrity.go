@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// integrityBatchSize is the number of documents checked per batch
+const integrityBatchSize = 100
+
+// ErrIntegrityCheckRunning is returned when a check is started while another
+// one is still running
+var ErrIntegrityCheckRunning = errors.New("integrity check already running")
+
+// flaggedIntegrityStatuses are the statuses of documents whose file failed
+// the check
+var flaggedIntegrityStatuses = []models.IntegrityStatus{
+	models.IntegrityHashMismatch,
+	models.IntegrityChainMismatch,
+	models.IntegrityUnreadable,
+}
+
+// IntegrityService re-hashes stored files in the background and compares
+// them with their documents' file hashes and, when the blockchain is
+// enabled, the hashes recorded on it. Documents failing the check are
+// flagged with the reason and recorded as a security event.
+type IntegrityService struct {
+	db                *gorm.DB
+	documentService   *DocumentService
+	blockchainService *BlockchainService
+	auditService      *AuditService
+	interval          time.Duration
+
+	running sync.Mutex
+}
+
+// IntegrityRun summarizes a check of stored files
+type IntegrityRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Checked    int       `json:"checked"`
+	Verified   int       `json:"verified"`
+	Flagged    int       `json:"flagged"`
+}
+
+// IntegrityIssue is a document whose file failed the check
+type IntegrityIssue struct {
+	DocumentID uint                   `json:"document_id"`
+	Title      string                 `json:"title"`
+	Version    int                    `json:"version"`
+	FileHash   string                 `json:"file_hash"`
+	Status     models.IntegrityStatus `json:"status"`
+	Issue      string                 `json:"issue"`
+	CheckedAt  *time.Time             `json:"checked_at"`
+}
+
+// IntegrityReport counts an organization's documents by the result of their
+// latest check and lists a page of the flagged ones, most recently checked
+// first
+type IntegrityReport struct {
+	Documents     int64            `json:"documents"`
+	Unchecked     int64            `json:"unchecked"`
+	Verified      int64            `json:"verified"`
+	HashMismatch  int64            `json:"hash_mismatch"`
+	ChainMismatch int64            `json:"chain_mismatch"`
+	Unreadable    int64            `json:"unreadable"`
+	LastCheckedAt *time.Time       `json:"last_checked_at"`
+	Issues        []IntegrityIssue `json:"issues"`
+	Total         int64            `json:"total"` // Flagged documents
+	Page          int              `json:"page"`
+	Limit         int              `json:"limit"`
+}
+
+// NewIntegrityService creates a new integrity service checking every
+// document on every interval. blockchainService may be nil when the
+// blockchain is disabled.
+func NewIntegrityService(documentService *DocumentService, blockchainService *BlockchainService, auditService *AuditService, interval time.Duration) *IntegrityService {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	return &IntegrityService{
+		db:                database.GetDB(),
+		documentService:   documentService,
+		blockchainService: blockchainService,
+		auditService:      auditService,
+		interval:          interval,
+	}
+}
+
+// Start checks every document on every interval in the background
+func (s *IntegrityService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			run, err := s.VerifyDocuments(database.SystemContext(context.Background()), 0)
+			if err != nil {
+				log.Printf("Integrity check failed: %v", err)
+				continue
+			}
+			if run.Flagged > 0 {
+				log.Printf("Integrity check flagged %d of %d documents", run.Flagged, run.Checked)
+			}
+		}
+	}()
+}
+
+// fileCheck is the result of reading and hashing a stored file
+type fileCheck struct {
+	hash string
+	err  error
+}
+
+// VerifyDocuments checks the stored files of the organization's documents,
+// or of all documents for 0, and records the result on each. Documents
+// sharing a file have it read once.
+func (s *IntegrityService) VerifyDocuments(ctx context.Context, organizationID uint) (*IntegrityRun, error) {
+	if !s.running.TryLock() {
+		return nil, ErrIntegrityCheckRunning
+	}
+	defer s.running.Unlock()
+
+	run := &IntegrityRun{StartedAt: time.Now()}
+
+	var recorded map[uint]map[int]string
+	if s.blockchainService != nil {
+		recorded = s.blockchainService.RecordedFileHashes()
+	}
+	files := make(map[string]fileCheck)
+
+	var lastID uint
+	for {
+		query := s.db.WithContext(ctx).Where("purged_at IS NULL AND id > ?", lastID)
+		if organizationID != 0 {
+			query = query.Where("organization_id = ?", organizationID)
+		}
+
+		var docs []models.Document
+		if err := query.Order("id ASC").Limit(integrityBatchSize).Find(&docs).Error; err != nil {
+			return run, fmt.Errorf("failed to get documents: %w", err)
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		for i := range docs {
+			doc := &docs[i]
+			lastID = doc.ID
+
+			file, ok := files[doc.FilePath]
+			if !ok {
+				file = s.hashFile(doc)
+				files[doc.FilePath] = file
+			}
+
+			status, issue := integrityResult(doc, file, recorded[doc.ID])
+			if err := s.record(ctx, doc, status, issue); err != nil {
+				log.Printf("Integrity check: failed to record result of document %d: %v", doc.ID, err)
+				continue
+			}
+
+			run.Checked++
+			if status == models.IntegrityVerified {
+				run.Verified++
+			} else {
+				run.Flagged++
+			}
+		}
+	}
+
+	run.FinishedAt = time.Now()
+	return run, nil
+}
+
+// hashFile reads and decrypts a document's stored file and hashes it
+func (s *IntegrityService) hashFile(doc *models.Document) fileCheck {
+	content, err := s.documentService.ReadContent(doc)
+	if err != nil {
+		return fileCheck{err: err}
+	}
+	return fileCheck{hash: s.documentService.hasher.SHA256(content)}
+}
+
+// integrityResult compares a document's file with its file hash and the
+// hash recorded on the blockchain for its version, if any
+func integrityResult(doc *models.Document, file fileCheck, recorded map[int]string) (models.IntegrityStatus, string) {
+	if file.err != nil {
+		if errors.Is(file.err, os.ErrNotExist) {
+			return models.IntegrityUnreadable, "file is missing"
+		}
+		return models.IntegrityUnreadable, "file can't be read or decrypted"
+	}
+
+	if file.hash != doc.FileHash {
+		return models.IntegrityHashMismatch, fmt.Sprintf("file hashes to %s", file.hash)
+	}
+
+	if chainHash, ok := recorded[doc.Version]; ok && chainHash != doc.FileHash {
+		return models.IntegrityChainMismatch, fmt.Sprintf("blockchain records %s for version %d", chainHash, doc.Version)
+	}
+
+	return models.IntegrityVerified, ""
+}
+
+// record stores the result of checking a document, unless its content
+// changed meanwhile, and records a security event when the document is newly
+// flagged
+func (s *IntegrityService) record(ctx context.Context, doc *models.Document, status models.IntegrityStatus, issue string) error {
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&models.Document{}).
+		Where("id = ? AND file_hash = ?", doc.ID, doc.FileHash).
+		UpdateColumns(map[string]interface{}{
+			"integrity_status":     status,
+			"integrity_issue":      issue,
+			"integrity_checked_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 || status == models.IntegrityVerified || status == doc.IntegrityStatus {
+		return nil
+	}
+
+	log.Printf("Integrity check flagged document %d: %s (%s)", doc.ID, status, issue)
+
+	s.auditService.LogAction(0, &doc.ID, "integrity_violation", "document", strconv.Itoa(int(doc.ID)), "", "", map[string]interface{}{
+		"title":     doc.Title,
+		"version":   doc.Version,
+		"file_hash": doc.FileHash,
+		"status":    status,
+		"issue":     issue,
+	})
+	return nil
+}
+
+// GetReport returns the integrity report of the organization's documents
+// with a page of the flagged ones
+func (s *IntegrityService) GetReport(ctx context.Context, organizationID uint, page, limit int) (*IntegrityReport, error) {
+	var counts []struct {
+		IntegrityStatus models.IntegrityStatus
+		Count           int64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Document{}).
+		Select("integrity_status, COUNT(*) AS count").
+		Where("organization_id = ? AND purged_at IS NULL", organizationID).
+		Group("integrity_status").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	report := &IntegrityReport{
+		Issues: make([]IntegrityIssue, 0),
+		Page:   page,
+		Limit:  limit,
+	}
+	for _, count := range counts {
+		report.Documents += count.Count
+		switch count.IntegrityStatus {
+		case models.IntegrityVerified:
+			report.Verified = count.Count
+		case models.IntegrityHashMismatch:
+			report.HashMismatch = count.Count
+		case models.IntegrityChainMismatch:
+			report.ChainMismatch = count.Count
+		case models.IntegrityUnreadable:
+			report.Unreadable = count.Count
+		default:
+			report.Unchecked += count.Count
+		}
+	}
+	report.Total = report.HashMismatch + report.ChainMismatch + report.Unreadable
+
+	var lastChecked struct {
+		CheckedAt *time.Time
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Document{}).
+		Select("MAX(integrity_checked_at) AS checked_at").
+		Where("organization_id = ?", organizationID).
+		Scan(&lastChecked).Error; err != nil {
+		return nil, fmt.Errorf("failed to get last integrity check: %w", err)
+	}
+	report.LastCheckedAt = lastChecked.CheckedAt
+
+	var docs []models.Document
+	if err := s.db.WithContext(ctx).Where("organization_id = ? AND purged_at IS NULL AND integrity_status IN ?", organizationID, flaggedIntegrityStatuses).
+		Order("integrity_checked_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&docs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get flagged documents: %w", err)
+	}
+	for _, doc := range docs {
+		report.Issues = append(report.Issues, IntegrityIssue{
+			DocumentID: doc.ID,
+			Title:      doc.Title,
+			Version:    doc.Version,
+			FileHash:   doc.FileHash,
+			Status:     doc.IntegrityStatus,
+			Issue:      doc.IntegrityIssue,
+			CheckedAt:  doc.IntegrityCheckedAt,
+		})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"gorm.io/gorm"
+)
+
+// rewrapBatchSize is the number of documents re-wrapped per batch
+const rewrapBatchSize = 100
+
+// keyRotationLockID is the Postgres advisory lock held while a rotation runs,
+// so that only one instance rotates at a time
+const keyRotationLockID int64 = 0x6b6579726f74 // "keyrot"
+
+// ErrKeyRotationRunning is returned when starting a rotation while another
+// one is running on any instance
+var ErrKeyRotationRunning = errors.New("a key rotation is already running")
+
+// KeyRotationService handles master key rotation and data key re-wrapping
+type KeyRotationService struct {
+	db           *gorm.DB
+	envelope     *crypto.EnvelopeService
+	auditService *AuditService
+}
+
+// NewKeyRotationService creates a new key rotation service
+func NewKeyRotationService(envelope *crypto.EnvelopeService, auditService *AuditService) *KeyRotationService {
+	return &KeyRotationService{
+		db:           database.GetDB(),
+		envelope:     envelope,
+		auditService: auditService,
+	}
+}
+
+// LoadKeys loads the stored master keys into the keyring and activates the
+// current one. Retired keys no data key is wrapped by anymore are left out.
+// Keys still wrapped by a previous root key are re-wrapped under the current
+// one first.
+func (s *KeyRotationService) LoadKeys() error {
+	var keys []models.EncryptionKey
+	if err := s.db.Order("version ASC").Find(&keys).Error; err != nil {
+		return fmt.Errorf("failed to get encryption keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if !key.IsActive {
+			used, err := s.versionInUse(key.Version)
+			if err != nil {
+				return err
+			}
+			if !used {
+				continue
+			}
+		}
+
+		wrappedKey, rewrapped, err := s.envelope.RewrapKEK(key.WrappedKey)
+		if err != nil {
+			return fmt.Errorf("failed to unwrap key version %d: %w", key.Version, err)
+		}
+		if rewrapped {
+			if err := s.db.Model(&models.EncryptionKey{}).
+				Where("id = ? AND wrapped_key = ?", key.ID, key.WrappedKey).
+				Update("wrapped_key", wrappedKey).Error; err != nil {
+				return fmt.Errorf("failed to re-wrap key version %d: %w", key.Version, err)
+			}
+			log.Printf("Key version %d re-wrapped under the current root key", key.Version)
+		}
+
+		if err := s.envelope.LoadKey(key.Version, wrappedKey); err != nil {
+			return err
+		}
+		if key.IsActive {
+			if err := s.envelope.SetCurrentVersion(key.Version); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// StartRotation creates a new master key version and starts re-wrapping all
+// document data keys in the background
+func (s *KeyRotationService) StartRotation(userID uint, ipAddress, userAgent string) (*models.KeyRotationJob, error) {
+	lock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.rotateKey(userID)
+	if err != nil {
+		s.unlock(lock)
+		return nil, err
+	}
+
+	s.auditService.LogAction(userID, nil, "key_rotation_started", "encryption_key", strconv.Itoa(job.ToVersion), ipAddress, userAgent, map[string]interface{}{
+		"job_id":       job.ID,
+		"from_version": job.FromVersion,
+		"to_version":   job.ToVersion,
+	})
+
+	go s.rewrapAll(job, lock, ipAddress, userAgent)
+
+	return job, nil
+}
+
+// rotateKey stores a new active key version and records the rotation job
+func (s *KeyRotationService) rotateKey(userID uint) (*models.KeyRotationJob, error) {
+	fromVersion := s.envelope.CurrentVersion()
+
+	var maxVersion int
+	if err := s.db.Model(&models.EncryptionKey{}).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&maxVersion).Error; err != nil {
+		return nil, fmt.Errorf("failed to get latest key version: %w", err)
+	}
+	toVersion := maxVersion + 1
+
+	wrappedKey, err := s.envelope.CreateKey(toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &models.KeyRotationJob{
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Status:      models.KeyRotationRunning,
+		StartedBy:   userID,
+		StartedAt:   now,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EncryptionKey{}).
+			Where("is_active = ?", true).
+			Updates(map[string]interface{}{"is_active": false, "retired_at": now}).Error; err != nil {
+			return err
+		}
+
+		key := &models.EncryptionKey{
+			Version:    toVersion,
+			WrappedKey: wrappedKey,
+			IsActive:   true,
+			CreatedBy:  userID,
+		}
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(job).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store new key version: %w", err)
+	}
+
+	// New data keys are wrapped with the new version from now on
+	if err := s.envelope.SetCurrentVersion(toVersion); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// wrappedKeyTables lists tables holding wrapped data keys. Table access
+// bypasses soft-delete scopes, so deleted rows that can still be restored
+// are re-wrapped too.
+var wrappedKeyTables = []string{"blobs", "documents", "document_versions", "upload_chunks", "export_jobs", "import_connections", "user_certificates"}
+
+// wrappedKeyRow is the subset of columns needed to re-wrap a data key
+type wrappedKeyRow struct {
+	ID         uint
+	DataKey    string
+	KeyVersion int
+}
+
+// rewrapAll re-wraps every data key of blobs, documents and document versions that
+// isn't on the job's target version, then unloads the retired key versions.
+// It runs as the system to reach every document and releases the rotation
+// lock when done.
+func (s *KeyRotationService) rewrapAll(job *models.KeyRotationJob, lock *sql.Conn, ipAddress, userAgent string) {
+	defer s.unlock(lock)
+
+	for _, table := range wrappedKeyTables {
+		var count int64
+		if err := database.AsSystem(s.db).Table(table).
+			Where("key_version <> ? AND data_key <> ''", job.ToVersion).
+			Count(&count).Error; err != nil {
+			s.failJob(job, err)
+			return
+		}
+		job.TotalDocuments += count
+	}
+	s.db.Model(job).Update("total_documents", job.TotalDocuments)
+
+	for _, table := range wrappedKeyTables {
+		if err := s.rewrapTable(job, table); err != nil {
+			s.failJob(job, err)
+			return
+		}
+	}
+
+	now := time.Now()
+	job.Status = models.KeyRotationCompleted
+	job.CompletedAt = &now
+	if job.FailedDocuments > 0 {
+		job.Status = models.KeyRotationFailed
+		job.Error = fmt.Sprintf("%d data keys could not be re-wrapped", job.FailedDocuments)
+	}
+	s.db.Save(job)
+
+	details := map[string]interface{}{
+		"job_id":              job.ID,
+		"processed_documents": job.ProcessedDocuments,
+		"failed_documents":    job.FailedDocuments,
+	}
+	// Retired keys stay loaded while data keys they wrap are left
+	if job.FailedDocuments == 0 {
+		unloaded, err := s.unloadRetiredKeys()
+		if err != nil {
+			log.Printf("Key rotation job %d: failed to unload retired keys: %v", job.ID, err)
+		}
+		details["unloaded_versions"] = unloaded
+	}
+
+	s.auditService.LogAction(job.StartedBy, nil, "key_rotation_"+string(job.Status), "encryption_key", strconv.Itoa(job.ToVersion), ipAddress, userAgent, details)
+}
+
+// unloadRetiredKeys removes the retired key versions no data key is wrapped
+// by from the keyring and returns them
+func (s *KeyRotationService) unloadRetiredKeys() ([]int, error) {
+	var versions []int
+	if err := s.db.Model(&models.EncryptionKey{}).
+		Where("is_active = ?", false).
+		Order("version ASC").
+		Pluck("version", &versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get retired keys: %w", err)
+	}
+
+	unloaded := []int{}
+	for _, version := range versions {
+		used, err := s.versionInUse(version)
+		if err != nil {
+			return unloaded, err
+		}
+		if used {
+			continue
+		}
+		if err := s.envelope.UnloadKey(version); err != nil {
+			return unloaded, err
+		}
+		unloaded = append(unloaded, version)
+	}
+	return unloaded, nil
+}
+
+// versionInUse reports whether any data key is wrapped by a key version
+func (s *KeyRotationService) versionInUse(version int) (bool, error) {
+	for _, table := range wrappedKeyTables {
+		var ids []uint
+		if err := database.AsSystem(s.db).Table(table).
+			Where("key_version = ? AND data_key <> ''", version).
+			Limit(1).
+			Pluck("id", &ids).Error; err != nil {
+			return false, fmt.Errorf("failed to check key version %d: %w", version, err)
+		}
+		if len(ids) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rewrapTable re-wraps the data keys of a single table in batches
+func (s *KeyRotationService) rewrapTable(job *models.KeyRotationJob, table string) error {
+	db := database.AsSystem(s.db)
+
+	var lastID uint
+	for {
+		var rows []wrappedKeyRow
+		if err := db.Table(table).
+			Select("id", "data_key", "key_version").
+			Where("id > ? AND key_version <> ? AND data_key <> ''", lastID, job.ToVersion).
+			Order("id ASC").
+			Limit(rewrapBatchSize).
+			Find(&rows).Error; err != nil {
+			return err
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+
+			wrappedKey, version, err := s.envelope.RewrapKey(row.DataKey, row.KeyVersion)
+			if err == nil {
+				err = db.Table(table).
+					Where("id = ? AND key_version = ?", row.ID, row.KeyVersion).
+					UpdateColumns(map[string]interface{}{
+						"data_key":    wrappedKey,
+						"key_version": version,
+					}).Error
+			}
+
+			if err != nil {
+				log.Printf("Key rotation: failed to re-wrap data key of %s %d: %v", table, row.ID, err)
+				job.FailedDocuments++
+				continue
+			}
+			job.ProcessedDocuments++
+		}
+
+		s.db.Model(job).Updates(map[string]interface{}{
+			"processed_documents": job.ProcessedDocuments,
+			"failed_documents":    job.FailedDocuments,
+		})
+	}
+}
+
+// failJob marks a rotation job as failed
+func (s *KeyRotationService) failJob(job *models.KeyRotationJob, err error) {
+	log.Printf("Key rotation job %d failed: %v", job.ID, err)
+
+	now := time.Now()
+	job.Status = models.KeyRotationFailed
+	job.Error = err.Error()
+	job.CompletedAt = &now
+	s.db.Save(job)
+}
+
+// lock takes the rotation advisory lock on a connection of its own, which
+// holds it until unlock. It returns ErrKeyRotationRunning when another
+// session holds it.
+func (s *KeyRotationService) lock() (*sql.Conn, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(context.Background(), "SELECT pg_try_advisory_lock($1)", keyRotationLockID).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to lock key rotation: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, ErrKeyRotationRunning
+	}
+	return conn, nil
+}
+
+// unlock releases the rotation advisory lock and its connection. A
+// connection that failed to unlock is discarded, which ends its session and
+// the lock with it.
+func (s *KeyRotationService) unlock(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", keyRotationLockID); err != nil {
+		log.Printf("Failed to unlock key rotation: %v", err)
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	conn.Close()
+}
+
+// GetJob retrieves a rotation job by ID
+func (s *KeyRotationService) GetJob(id uint) (*models.KeyRotationJob, error) {
+	var job models.KeyRotationJob
+	if err := s.db.First(&job, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get key rotation job: %w", err)
+	}
+	return &job, nil
+}
+
+// GetJobs retrieves rotation jobs with pagination
+func (s *KeyRotationService) GetJobs(page, limit int) ([]models.KeyRotationJob, int64, error) {
+	var jobs []models.KeyRotationJob
+	var total int64
+
+	offset := (page - 1) * limit
+
+	if err := s.db.Model(&models.KeyRotationJob{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count key rotation jobs: %w", err)
+	}
+
+	if err := s.db.Order("started_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get key rotation jobs: %w", err)
+	}
+
+	return jobs, total, nil
+}
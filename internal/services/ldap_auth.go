@@ -0,0 +1,166 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/ldap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDirectoryCredentials is returned when the directory rejects a login
+	ErrDirectoryCredentials = errors.New("invalid directory credentials")
+	// ErrDirectoryNoRole is returned for directory users in no group mapped to a role
+	ErrDirectoryNoRole = errors.New("directory user has no mapped role")
+)
+
+// LDAPConfig describes the directory users authenticate against
+type LDAPConfig struct {
+	URL            string // ldaps://host[:port], or ldap://host[:port] with StartTLS
+	StartTLS       bool
+	TLSConfig      *tls.Config
+	BindDN         string // Service account that looks up users; empty binds anonymously
+	BindPassword   string
+	BaseDN         string
+	UserFilter     string // Finds a user by login name, such as (sAMAccountName=%s)
+	GroupAttribute string // Attribute listing the user's groups, such as memberOf
+	Timeout        time.Duration
+	Mapping        DirectoryMapping
+}
+
+// ldapUserAttributes are the profile attributes read from the directory
+var ldapUserAttributes = []string{"mail", "givenName", "sn", "department"}
+
+// LDAPAuthService authenticates users against an LDAP directory such as
+// Active Directory and provisions them as local users on their first login.
+// Their role and department follow the directory's groups on every login.
+type LDAPAuthService struct {
+	db          *gorm.DB
+	userService *UserService
+	config      LDAPConfig
+}
+
+// NewLDAPAuthService creates a new LDAP authentication service
+func NewLDAPAuthService(userService *UserService, config LDAPConfig) *LDAPAuthService {
+	return &LDAPAuthService{
+		db:          database.GetDB(),
+		userService: userService,
+		config:      config,
+	}
+}
+
+// Authenticate verifies a username and password with the directory and
+// returns the provisioned local user, reporting whether it was created.
+// Usernames taken by local accounts fail with ErrUserExists.
+func (s *LDAPAuthService) Authenticate(username, password string) (*models.User, bool, error) {
+	conn, err := ldap.Dial(s.config.URL, s.config.StartTLS, s.config.TLSConfig, s.config.Timeout)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to connect to directory: %w", err)
+	}
+	defer conn.Close()
+
+	if s.config.BindDN != "" {
+		if err := conn.Bind(s.config.BindDN, s.config.BindPassword); err != nil {
+			return nil, false, fmt.Errorf("failed to bind service account: %w", err)
+		}
+	}
+
+	attributes := append([]string{s.config.GroupAttribute}, ldapUserAttributes...)
+	filter := fmt.Sprintf(s.config.UserFilter, ldap.EscapeFilter(username))
+	entries, err := conn.Search(s.config.BaseDN, filter, attributes, 2)
+	if err != nil && !isSizeLimitExceeded(err) {
+		return nil, false, fmt.Errorf("failed to look up user: %w", err)
+	}
+	// Unknown and ambiguous usernames are both refused
+	if len(entries) != 1 {
+		return nil, false, ErrDirectoryCredentials
+	}
+	entry := entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsInvalidCredentials(err) {
+			return nil, false, ErrDirectoryCredentials
+		}
+		return nil, false, err
+	}
+
+	groups := entry.Values(s.config.GroupAttribute)
+	role := s.config.Mapping.Role(groups)
+	if role == "" {
+		return nil, false, ErrDirectoryNoRole
+	}
+	department := s.config.Mapping.Department(groups)
+	if department == "" {
+		department = entry.Value("department")
+	}
+
+	profile := &models.User{
+		Username:   strings.ToLower(username),
+		Email:      entry.Value("mail"),
+		FirstName:  entry.Value("givenName"),
+		LastName:   entry.Value("sn"),
+		Role:       role,
+		Department: department,
+	}
+	if profile.Email == "" {
+		return nil, false, fmt.Errorf("directory user %q has no email address", username)
+	}
+	return s.provision(profile)
+}
+
+// provision creates or updates the local user of a directory user
+func (s *LDAPAuthService) provision(profile *models.User) (*models.User, bool, error) {
+	var user models.User
+	err := s.db.Unscoped().Where("username = ?", profile.Username).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var conflicts int64
+	if err := s.db.Unscoped().Model(&models.User{}).
+		Where("email = ? AND username <> ?", profile.Email, profile.Username).
+		Count(&conflicts).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to check existing users: %w", err)
+	}
+	if conflicts > 0 {
+		return nil, false, ErrUserExists
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Directory users have no local password
+		profile.AuthSource = models.AuthSourceLDAP
+		profile.IsActive = true
+		if err := s.userService.Create(profile); err != nil {
+			return nil, false, err
+		}
+		return profile, true, nil
+	}
+
+	if user.AuthSource != models.AuthSourceLDAP || user.DeletedAt.Valid {
+		return nil, false, ErrUserExists
+	}
+
+	user.Email = profile.Email
+	user.FirstName = profile.FirstName
+	user.LastName = profile.LastName
+	user.Role = profile.Role
+	user.Department = profile.Department
+	if err := s.db.Model(&user).
+		Select("email", "first_name", "last_name", "role", "department").
+		Updates(&user).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to update user: %w", err)
+	}
+	return &user, false, nil
+}
+
+// isSizeLimitExceeded reports whether a search returned more entries than requested
+func isSizeLimitExceeded(err error) bool {
+	var result *ldap.ResultError
+	return errors.As(err, &result) && result.Code == ldap.ResultSizeLimitExceeded
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/mailer"
+	"gorm.io/gorm"
+)
+
+// LoginAlertService remembers the devices users sign in from and emails
+// them when a login comes from a new one
+type LoginAlertService struct {
+	db     *gorm.DB
+	mailer mailer.Mailer
+}
+
+// NewLoginAlertService creates a new login alert service
+func NewLoginAlertService(mailer mailer.Mailer) *LoginAlertService {
+	return &LoginAlertService{
+		db:     database.GetDB(),
+		mailer: mailer,
+	}
+}
+
+// CheckLogin records the device of a successful login and, when the user
+// signed in before from other devices only, emails them about it unless
+// they turned alerts off. It reports whether the device was new. The email
+// is sent in the background.
+func (s *LoginAlertService) CheckLogin(user *models.User, ipAddress, userAgent string) (bool, error) {
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	fingerprint := fmt.Sprintf("%x", sha256.Sum256([]byte(ipAddress+"\n"+userAgent)))
+	now := time.Now()
+
+	var device models.KnownDevice
+	err := s.db.Where("user_id = ? AND fingerprint = ?", user.ID, fingerprint).First(&device).Error
+	if err == nil {
+		if err := s.db.Model(&device).Update("last_seen_at", now).Error; err != nil {
+			return false, fmt.Errorf("failed to update device: %w", err)
+		}
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("failed to get device: %w", err)
+	}
+
+	// The first device of a user is no news to them
+	var known int64
+	if err := s.db.Model(&models.KnownDevice{}).Where("user_id = ?", user.ID).Count(&known).Error; err != nil {
+		return false, fmt.Errorf("failed to count devices: %w", err)
+	}
+
+	device = models.KnownDevice{
+		UserID:      user.ID,
+		Fingerprint: fingerprint,
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+	}
+	if err := s.db.Create(&device).Error; err != nil {
+		return false, fmt.Errorf("failed to save device: %w", err)
+	}
+	if known == 0 || !user.LoginAlerts {
+		return true, nil
+	}
+
+	name := user.FirstName
+	if name == "" {
+		name = user.Username
+	}
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Your account was just signed in to from a device or network it hasn't been used from before:\n\n"+
+		"Time:       %s\n"+
+		"IP address: %s\n"+
+		"Browser:    %s\n\n"+
+		"If this was you, there is nothing to do. Otherwise change your password right away and sign out your other sessions.\n",
+		name, now.Format(time.RFC1123), ipAddress, userAgent)
+
+	go func() {
+		if err := s.mailer.Send(user.Email, "New sign-in to your account", body); err != nil {
+			log.Printf("Failed to email login alert to user %d: %v", user.ID, err)
+		}
+	}()
+	return true, nil
+}
+
+// SetAlerts turns a user's login alerts on or off
+func (s *LoginAlertService) SetAlerts(userID uint, enabled bool) error {
+	if err := s.db.Model(&models.User{}).Where("id = ?", userID).Update("login_alerts", enabled).Error; err != nil {
+		return fmt.Errorf("failed to update login alerts: %w", err)
+	}
+	return nil
+}
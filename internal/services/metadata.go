@@ -0,0 +1,392 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidMetadata is returned for invalid field definitions, unknown fields and mistyped values
+	ErrInvalidMetadata = errors.New("invalid metadata")
+	// ErrMetadataFieldExists is returned when a field key is already defined
+	ErrMetadataFieldExists = errors.New("metadata field key already exists")
+	// ErrMetadataFieldInUse is returned when changing the type of a field documents have values for
+	ErrMetadataFieldInUse = errors.New("metadata field type cannot change while documents have values")
+)
+
+// metadataKeyPattern restricts field keys to lower-case identifiers
+var metadataKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// metadataColumns maps field types to the value column they are stored in
+var metadataColumns = map[models.MetadataFieldType]string{
+	models.MetadataText:    "text_value",
+	models.MetadataSelect:  "text_value",
+	models.MetadataNumber:  "number_value",
+	models.MetadataDate:    "date_value",
+	models.MetadataBoolean: "boolean_value",
+}
+
+// MetadataService manages custom metadata field definitions and their values on documents
+type MetadataService struct {
+	db *gorm.DB
+}
+
+// NewMetadataService creates a new metadata service
+func NewMetadataService() *MetadataService {
+	return &MetadataService{
+		db: database.GetDB(),
+	}
+}
+
+// GetFields retrieves field definitions. With a department, only fields for
+// that department and for all departments are returned.
+func (s *MetadataService) GetFields(department string) ([]models.MetadataField, error) {
+	query := s.db.Order("key ASC")
+	if department != "" {
+		query = query.Where("department = '' OR department = ?", department)
+	}
+
+	var fields []models.MetadataField
+	if err := query.Find(&fields).Error; err != nil {
+		return nil, fmt.Errorf("failed to get metadata fields: %w", err)
+	}
+	return fields, nil
+}
+
+// GetField retrieves a field definition by ID
+func (s *MetadataService) GetField(id uint) (*models.MetadataField, error) {
+	var field models.MetadataField
+	if err := s.db.First(&field, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get metadata field: %w", err)
+	}
+	return &field, nil
+}
+
+// CreateField defines a new metadata field
+func (s *MetadataService) CreateField(field *models.MetadataField) error {
+	if err := validateMetadataField(field); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.MetadataField{}).Where("key = ?", field.Key).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check metadata field: %w", err)
+	}
+	if count > 0 {
+		return ErrMetadataFieldExists
+	}
+
+	if err := s.db.Create(field).Error; err != nil {
+		return fmt.Errorf("failed to create metadata field: %w", err)
+	}
+	return nil
+}
+
+// EnsureField defines a field unless one with its key exists. An existing
+// definition is kept as administrators may have changed it.
+func (s *MetadataService) EnsureField(field *models.MetadataField) error {
+	if err := s.CreateField(field); err != nil && !errors.Is(err, ErrMetadataFieldExists) {
+		return err
+	}
+	return nil
+}
+
+// UpdateField updates a field definition. Its key is fixed; its type may only
+// change while no document has a value for it.
+func (s *MetadataService) UpdateField(field *models.MetadataField, previousType models.MetadataFieldType) error {
+	if err := validateMetadataField(field); err != nil {
+		return err
+	}
+
+	if metadataColumns[field.Type] != metadataColumns[previousType] {
+		var count int64
+		if err := s.db.Model(&models.DocumentMetadata{}).Where("field_id = ?", field.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check metadata values: %w", err)
+		}
+		if count > 0 {
+			return ErrMetadataFieldInUse
+		}
+	}
+
+	if err := s.db.Save(field).Error; err != nil {
+		return fmt.Errorf("failed to update metadata field: %w", err)
+	}
+	return nil
+}
+
+// DeleteField removes a field definition along with its values on all documents
+func (s *MetadataService) DeleteField(field *models.MetadataField) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("field_id = ?", field.ID).Delete(&models.DocumentMetadata{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(field).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete metadata field: %w", err)
+	}
+	return nil
+}
+
+// validateMetadataField checks a field definition
+func validateMetadataField(field *models.MetadataField) error {
+	if !metadataKeyPattern.MatchString(field.Key) {
+		return fmt.Errorf("%w: key must be lower-case letters, digits and underscores", ErrInvalidMetadata)
+	}
+	if _, ok := metadataColumns[field.Type]; !ok {
+		return fmt.Errorf("%w: unsupported field type %q", ErrInvalidMetadata, field.Type)
+	}
+
+	if field.Type != models.MetadataSelect {
+		field.Options = ""
+		return nil
+	}
+
+	options, err := metadataOptions(field)
+	if err != nil || len(options) == 0 {
+		return fmt.Errorf("%w: select fields need a JSON array of options", ErrInvalidMetadata)
+	}
+	return nil
+}
+
+// NormalizeOptions encodes the allowed values of a select field, trimming
+// whitespace and dropping empty and duplicate values
+func NormalizeOptions(options []string) string {
+	values := make([]string, 0, len(options))
+	for _, option := range options {
+		if option = strings.TrimSpace(option); option != "" {
+			values = append(values, option)
+		}
+	}
+	data, _ := json.Marshal(uniqueStrings(values))
+	return string(data)
+}
+
+// metadataOptions returns the allowed values of a select field
+func metadataOptions(field *models.MetadataField) ([]string, error) {
+	var options []string
+	if err := json.Unmarshal([]byte(field.Options), &options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// GetDocumentMetadata retrieves the metadata values of a document keyed by field key
+func (s *MetadataService) GetDocumentMetadata(documentID uint) (map[string]interface{}, error) {
+	var values []models.DocumentMetadata
+	if err := s.db.Preload("Field").Where("document_id = ?", documentID).Find(&values).Error; err != nil {
+		return nil, fmt.Errorf("failed to get document metadata: %w", err)
+	}
+
+	metadata := make(map[string]interface{}, len(values))
+	for _, value := range values {
+		metadata[value.Field.Key] = metadataValue(&value)
+	}
+	return metadata, nil
+}
+
+// metadataValue returns the typed value of a metadata entry
+func metadataValue(value *models.DocumentMetadata) interface{} {
+	switch {
+	case value.TextValue != nil:
+		return *value.TextValue
+	case value.NumberValue != nil:
+		return *value.NumberValue
+	case value.DateValue != nil:
+		return value.DateValue.Format("2006-01-02")
+	case value.BooleanValue != nil:
+		return *value.BooleanValue
+	}
+	return nil
+}
+
+// SetDocumentMetadata sets metadata values of a document by field key. A nil
+// value removes the field from the document; fields not given are kept.
+func (s *MetadataService) SetDocumentMetadata(documentID uint, values map[string]interface{}, userID uint) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	var fields []models.MetadataField
+	if err := s.db.Where("key IN ?", keys).Find(&fields).Error; err != nil {
+		return fmt.Errorf("failed to get metadata fields: %w", err)
+	}
+	byKey := make(map[string]*models.MetadataField, len(fields))
+	for i := range fields {
+		byKey[fields[i].Key] = &fields[i]
+	}
+
+	var removed []uint
+	var updated []models.DocumentMetadata
+	for key, raw := range values {
+		field, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidMetadata, key)
+		}
+
+		if raw == nil {
+			removed = append(removed, field.ID)
+			continue
+		}
+
+		value, err := convertMetadataValue(field, raw)
+		if err != nil {
+			return err
+		}
+		value.DocumentID = documentID
+		value.UpdatedBy = userID
+		updated = append(updated, *value)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(removed) > 0 {
+			if err := tx.Where("document_id = ? AND field_id IN ?", documentID, removed).Delete(&models.DocumentMetadata{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(updated) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&updated).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update document metadata: %w", err)
+	}
+	return nil
+}
+
+// convertMetadataValue converts a decoded JSON value to a metadata entry of the field's type
+func convertMetadataValue(field *models.MetadataField, raw interface{}) (*models.DocumentMetadata, error) {
+	value := &models.DocumentMetadata{FieldID: field.ID}
+	invalid := fmt.Errorf("%w: %s must be a %s value", ErrInvalidMetadata, field.Key, field.Type)
+
+	switch field.Type {
+	case models.MetadataText, models.MetadataSelect:
+		text, ok := raw.(string)
+		if !ok {
+			return nil, invalid
+		}
+		if field.Type == models.MetadataSelect {
+			options, _ := metadataOptions(field)
+			if !containsString(options, text) {
+				return nil, fmt.Errorf("%w: %s must be one of %s", ErrInvalidMetadata, field.Key, strings.Join(options, ", "))
+			}
+		}
+		value.TextValue = &text
+	case models.MetadataNumber:
+		number, ok := raw.(float64)
+		if !ok {
+			return nil, invalid
+		}
+		value.NumberValue = &number
+	case models.MetadataDate:
+		text, ok := raw.(string)
+		if !ok {
+			return nil, invalid
+		}
+		date, err := parseMetadataDate(text)
+		if err != nil {
+			return nil, invalid
+		}
+		value.DateValue = &date
+	case models.MetadataBoolean:
+		boolean, ok := raw.(bool)
+		if !ok {
+			return nil, invalid
+		}
+		value.BooleanValue = &boolean
+	default:
+		return nil, invalid
+	}
+
+	return value, nil
+}
+
+// parseMetadataValue parses a filter value of the field's type
+func parseMetadataValue(field *models.MetadataField, raw string) (interface{}, error) {
+	switch field.Type {
+	case models.MetadataNumber:
+		return strconv.ParseFloat(raw, 64)
+	case models.MetadataDate:
+		return parseMetadataDate(raw)
+	case models.MetadataBoolean:
+		return strconv.ParseBool(raw)
+	}
+	return raw, nil
+}
+
+// parseMetadataDate parses a date in RFC 3339 or YYYY-MM-DD format
+func parseMetadataDate(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// containsString reports whether the value is in the list
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveFilter resolves the metadata conditions of a filter to their fields
+// and parses the compared values according to the field types
+func (s *MetadataService) ResolveFilter(filter *DocumentFilter) error {
+	for i := range filter.Metadata {
+		condition := &filter.Metadata[i]
+
+		var field models.MetadataField
+		if err := s.db.Where("key = ?", condition.Key).First(&field).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: unknown field %q", ErrInvalidMetadata, condition.Key)
+			}
+			return fmt.Errorf("failed to get metadata field: %w", err)
+		}
+
+		if condition.Operator != MetadataEquals && field.Type != models.MetadataNumber && field.Type != models.MetadataDate {
+			return fmt.Errorf("%w: range filters need a number or date field, %s is %s", ErrInvalidMetadata, field.Key, field.Type)
+		}
+
+		value, err := parseMetadataValue(&field, condition.Value)
+		if err != nil {
+			return fmt.Errorf("%w: invalid %s value for %s", ErrInvalidMetadata, field.Type, field.Key)
+		}
+
+		condition.fieldID = field.ID
+		condition.column = metadataColumns[field.Type]
+		condition.value = value
+	}
+	return nil
+}
+
+// copyDocumentMetadata duplicates the metadata values of a document onto another
+func copyDocumentMetadata(tx *gorm.DB, fromID, toID, userID uint) error {
+	var values []models.DocumentMetadata
+	if err := tx.Where("document_id = ?", fromID).Find(&values).Error; err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	for i := range values {
+		values[i].DocumentID = toID
+		values[i].UpdatedBy = userID
+	}
+	return tx.Create(&values).Error
+}
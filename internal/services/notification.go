@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// NotificationFilter selects notifications of a user
+type NotificationFilter struct {
+	Type       models.NotificationType
+	UnreadOnly bool
+}
+
+// apply adds the filter conditions to a notifications query
+func (f *NotificationFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.Type != "" {
+		db = db.Where("type = ?", f.Type)
+	}
+	if f.UnreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+	return db
+}
+
+// NotificationService stores notifications and tracks whether users have read them
+type NotificationService struct {
+	db *gorm.DB
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService() *NotificationService {
+	return &NotificationService{
+		db: database.GetDB(),
+	}
+}
+
+// Notify stores notifications
+func (s *NotificationService) Notify(notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	if err := s.db.Create(&notifications).Error; err != nil {
+		return fmt.Errorf("failed to create notifications: %w", err)
+	}
+	return nil
+}
+
+// GetForUser retrieves the notifications of a user matching the filter, newest first
+func (s *NotificationService) GetForUser(userID uint, filter *NotificationFilter, page, limit int) ([]models.Notification, int64, error) {
+	var notifications []models.Notification
+	var total int64
+
+	offset := (page - 1) * limit
+
+	query := s.db.Model(&models.Notification{}).
+		Where("user_id = ?", userID).
+		Scopes(filter.apply).
+		Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get notifications: %w", err)
+	}
+
+	return notifications, total, nil
+}
+
+// MarkRead marks a notification of the user as read
+func (s *NotificationService) MarkRead(userID, id uint) (*models.Notification, error) {
+	var notification models.Notification
+	if err := s.db.Where("user_id = ?", userID).First(&notification, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := s.db.Model(&notification).Update("read_at", now).Error; err != nil {
+			return nil, fmt.Errorf("failed to update notification: %w", err)
+		}
+	}
+
+	return &notification, nil
+}
+
+// MarkAllRead marks all unread notifications of the user matching the filter
+// as read and returns how many were updated
+func (s *NotificationService) MarkAllRead(userID uint, filter *NotificationFilter) (int64, error) {
+	result := s.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Scopes(filter.apply).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to update notifications: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
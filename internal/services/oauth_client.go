@@ -0,0 +1,190 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidClient is returned for unknown or revoked OAuth clients and
+	// wrong secrets
+	ErrInvalidClient = errors.New("invalid client")
+	// ErrClientNotFound is returned when there is no such OAuth client
+	ErrClientNotFound = errors.New("OAuth client not found")
+)
+
+// OAuthClientService registers OAuth clients and authenticates them for the
+// client credentials grant
+type OAuthClientService struct {
+	db *gorm.DB
+}
+
+// NewOAuthClientService creates a new OAuth client service
+func NewOAuthClientService() *OAuthClientService {
+	return &OAuthClientService{
+		db: database.GetDB(),
+	}
+}
+
+// Create registers an OAuth client along with its service account, which
+// has role and department in the organization. Tokens of the client get at most scopes. The
+// secret is only ever returned here.
+func (s *OAuthClientService) Create(organizationID uint, name string, role models.Role, department string, scopes []string, createdBy uint) (*models.OAuthClient, string, error) {
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id := make([]byte, 16)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate client ID: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate client secret: %w", err)
+	}
+	clientID := hex.EncodeToString(id)
+	clientSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	client := &models.OAuthClient{
+		ClientID:   clientID,
+		SecretHash: hashSecret(clientSecret),
+		Name:       name,
+		Scopes:     strings.Join(normalized, ","),
+		CreatedBy:  createdBy,
+		User: models.User{
+			OrganizationID: organizationID,
+			Username:       "svc-" + clientID,
+			Email:          clientID + "@service.invalid",
+			FirstName:      name,
+			Role:           role,
+			Department:     department,
+			IsActive:       true,
+			AuthSource:     models.AuthSourceService,
+		},
+	}
+	if err := s.db.Create(client).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to save OAuth client: %w", err)
+	}
+	return client, clientSecret, nil
+}
+
+// ofOrganization restricts a query on OAuth clients to those whose service
+// account belongs to the organization
+func (s *OAuthClientService) ofOrganization(organizationID uint) *gorm.DB {
+	return s.db.Where("user_id IN (?)", s.db.Model(&models.User{}).Unscoped().Select("id").Where("organization_id = ?", organizationID))
+}
+
+// GetClients lists the OAuth clients of an organization that haven't been
+// revoked
+func (s *OAuthClientService) GetClients(organizationID uint) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	if err := s.ofOrganization(organizationID).Preload("User").Where("revoked_at IS NULL").Order("created_at DESC, id DESC").Find(&clients).Error; err != nil {
+		return nil, fmt.Errorf("failed to get OAuth clients: %w", err)
+	}
+	return clients, nil
+}
+
+// GetClient retrieves an OAuth client of an organization, revoked or not,
+// with its service account
+func (s *OAuthClientService) GetClient(organizationID, id uint) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := s.ofOrganization(organizationID).Preload("User").First(&client, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClientNotFound
+		}
+		return nil, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+	return &client, nil
+}
+
+// SetQuota sets the daily quotas of an OAuth client of an organization; nil
+// uses the defaults and 0 is unlimited
+func (s *OAuthClientService) SetQuota(organizationID, id uint, dailyRequests, dailyBandwidthMB *int64) (*models.OAuthClient, error) {
+	client, err := s.GetClient(organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(client).Select("daily_request_quota", "daily_bandwidth_quota_mb").Updates(&models.OAuthClient{
+		DailyRequestQuota:     dailyRequests,
+		DailyBandwidthQuotaMB: dailyBandwidthMB,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to set OAuth client quota: %w", err)
+	}
+	client.DailyRequestQuota, client.DailyBandwidthQuotaMB = dailyRequests, dailyBandwidthMB
+	return client, nil
+}
+
+// Revoke stops an OAuth client of an organization from getting tokens and
+// deactivates its service account, refusing the tokens it already has
+func (s *OAuthClientService) Revoke(organizationID, id uint) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := s.ofOrganization(organizationID).Where("revoked_at IS NULL").First(&client, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClientNotFound
+		}
+		return nil, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&client).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", client.UserID).Updates(map[string]interface{}{
+			"is_active":         false,
+			"tokens_revoked_at": tokenRevocationTime(),
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke OAuth client: %w", err)
+	}
+	return &client, nil
+}
+
+// Authenticate checks the credentials of an OAuth client and the scopes it
+// asks for, all of its scopes when none are asked for. It returns the
+// client, with its service account, and the scopes granted.
+func (s *OAuthClientService) Authenticate(clientID, clientSecret string, requested []string) (*models.OAuthClient, []string, error) {
+	var client models.OAuthClient
+	if err := s.db.Preload("User").Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidClient
+		}
+		return nil, nil, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashSecret(clientSecret))) != 1 ||
+		client.RevokedAt != nil || !client.User.IsActive {
+		return nil, nil, ErrInvalidClient
+	}
+
+	allowed := strings.Split(client.Scopes, ",")
+	granted := allowed
+	if len(requested) > 0 {
+		var err error
+		if granted, err = normalizeScopes(requested); err != nil {
+			return nil, nil, err
+		}
+		for _, scope := range granted {
+			if !slices.Contains(allowed, scope) {
+				return nil, nil, &ScopeError{Scope: scope}
+			}
+		}
+	}
+
+	if err := s.db.Model(&client).Update("last_used_at", time.Now()).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to record OAuth client use: %w", err)
+	}
+	return &client, granted, nil
+}
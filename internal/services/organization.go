@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// organizationSlugPattern restricts organization slugs to what is easy to
+// type when registering
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+var (
+	// ErrOrganizationNotFound is returned for unknown organizations
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrOrganizationExists is returned when an organization name or slug is taken
+	ErrOrganizationExists = errors.New("organization name or slug is already taken")
+	// ErrInvalidOrganizationSlug is returned for slugs that aren't lowercase
+	// letters, digits and hyphens
+	ErrInvalidOrganizationSlug = errors.New("slugs must be 2-50 lowercase letters, digits or hyphens")
+	// ErrLastOrganizationAdmin is returned when moving the last active admin
+	// out of an organization
+	ErrLastOrganizationAdmin = errors.New("the last active admin of an organization can't leave it")
+)
+
+// OrganizationService manages the organizations hosted by the deployment
+type OrganizationService struct {
+	db *gorm.DB
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService() *OrganizationService {
+	return &OrganizationService{
+		db: database.GetDB(),
+	}
+}
+
+// OfOrganization restricts a query on table to rows of an organization
+func OfOrganization(table string, organizationID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(table+".organization_id = ?", organizationID)
+	}
+}
+
+// GetAll lists all organizations
+func (s *OrganizationService) GetAll() ([]models.Organization, error) {
+	var organizations []models.Organization
+	if err := s.db.Order("is_default DESC, name ASC").Find(&organizations).Error; err != nil {
+		return nil, fmt.Errorf("failed to get organizations: %w", err)
+	}
+	return organizations, nil
+}
+
+// GetByID retrieves an organization
+func (s *OrganizationService) GetByID(id uint) (*models.Organization, error) {
+	var organization models.Organization
+	if err := s.db.First(&organization, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &organization, nil
+}
+
+// GetBySlug retrieves an organization by its slug
+func (s *OrganizationService) GetBySlug(slug string) (*models.Organization, error) {
+	var organization models.Organization
+	if err := s.db.Where("slug = ?", slug).First(&organization).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &organization, nil
+}
+
+// GetBySlugOrDefault retrieves the organization with the slug, or the
+// default organization without one
+func (s *OrganizationService) GetBySlugOrDefault(slug string) (*models.Organization, error) {
+	if slug == "" {
+		return database.DefaultOrganization()
+	}
+	return s.GetBySlug(slug)
+}
+
+// IsDefault reports whether an organization is the default one, whose
+// administrators manage the others
+func (s *OrganizationService) IsDefault(id uint) (bool, error) {
+	organization, err := s.GetByID(id)
+	if err != nil {
+		return false, err
+	}
+	return organization.IsDefault, nil
+}
+
+// taken checks whether another organization has the name or slug
+func (s *OrganizationService) taken(name, slug string, excludeID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.Organization{}).
+		Where("(name = ? OR slug = ?) AND id <> ?", name, slug, excludeID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create adds an organization
+func (s *OrganizationService) Create(name, slug string) (*models.Organization, error) {
+	if !organizationSlugPattern.MatchString(slug) {
+		return nil, ErrInvalidOrganizationSlug
+	}
+	taken, err := s.taken(name, slug, 0)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrOrganizationExists
+	}
+
+	organization := &models.Organization{Name: name, Slug: slug}
+	if err := s.db.Create(organization).Error; err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+	return organization, nil
+}
+
+// Rename changes the name of an organization; its slug stays
+func (s *OrganizationService) Rename(organization *models.Organization, name string) error {
+	organization.Name = name
+	taken, err := s.taken(organization.Name, organization.Slug, organization.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrOrganizationExists
+	}
+
+	if err := s.db.Model(organization).Update("name", name).Error; err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+	return nil
+}
+
+// SetUserOrganization moves a user to another organization. Documents the
+// user created stay in their organization. The last active admin of an
+// organization stays in it.
+func (s *OrganizationService) SetUserOrganization(user *models.User, organizationID uint) error {
+	if _, err := s.GetByID(organizationID); err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if user.Role == models.RoleAdmin && user.IsActive && user.OrganizationID != organizationID {
+			var admins int64
+			if err := tx.Model(&models.User{}).
+				Where("organization_id = ? AND role = ? AND is_active = ?", user.OrganizationID, models.RoleAdmin, true).
+				Count(&admins).Error; err != nil {
+				return fmt.Errorf("failed to count admins: %w", err)
+			}
+			if admins <= 1 {
+				return ErrLastOrganizationAdmin
+			}
+		}
+
+		// Memberships of groups in the previous organization end
+		if err := tx.Where("user_id = ? AND group_id IN (?)", user.ID,
+			tx.Model(&models.Group{}).Select("id").Where("organization_id = ?", user.OrganizationID)).
+			Delete(&models.GroupMember{}).Error; err != nil {
+			return fmt.Errorf("failed to remove group memberships: %w", err)
+		}
+		if err := tx.Model(user).Update("organization_id", organizationID).Error; err != nil {
+			return fmt.Errorf("failed to set user organization: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	user.OrganizationID = organizationID
+	return nil
+}
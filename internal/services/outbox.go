@@ -0,0 +1,285 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/blockchain"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// outboxBatchSize is the number of events delivered per transaction
+	outboxBatchSize = 100
+	// outboxMaxBackoff caps the wait between attempts to deliver an event
+	outboxMaxBackoff = time.Hour
+	// outboxRetention is how long delivered events are kept
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// ErrOutboxEventNotFound is returned when an outbox event doesn't exist or
+// hasn't failed
+var ErrOutboxEventNotFound = errors.New("failed outbox event not found")
+
+// EnqueueAudit writes audit entries to the outbox in tx, the transaction of
+// the change they record. They are stored once it commits.
+func EnqueueAudit(tx *gorm.DB, entries ...*models.AuditLog) error {
+	payloads := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		payloads = append(payloads, entry)
+	}
+	return database.EnqueueOutbox(tx, models.OutboxAudit, payloads...)
+}
+
+// EnqueueBlockchain writes the blockchain transaction of a document
+// operation to the outbox in tx and returns its ID. It is queued for mining
+// once the change commits.
+func EnqueueBlockchain(tx *gorm.DB, documentID, userID uint, action string, data map[string]interface{}) (string, error) {
+	transaction, err := NewDocumentTransaction(documentID, userID, action, data)
+	if err != nil {
+		return "", err
+	}
+	if err := database.EnqueueOutbox(tx, models.OutboxBlockchain, transaction); err != nil {
+		return "", err
+	}
+	return transaction.ID, nil
+}
+
+// ChainAction is a document operation to record on the blockchain along
+// with a change
+type ChainAction struct {
+	DocumentID uint
+	Action     string
+	Data       map[string]interface{}
+}
+
+// OutboxDispatcher delivers the events written to the outbox: audit entries
+// are stored and published, and blockchain transactions queued for mining.
+// Failed deliveries are retried with backoff until the maximum attempts.
+type OutboxDispatcher struct {
+	db                *gorm.DB
+	auditService      *AuditService
+	blockchainService *BlockchainService
+	interval          time.Duration
+	maxAttempts       int
+}
+
+// NewOutboxDispatcher creates a new outbox dispatcher. blockchainService is
+// nil when the blockchain is disabled.
+func NewOutboxDispatcher(auditService *AuditService, blockchainService *BlockchainService, interval time.Duration, maxAttempts int) *OutboxDispatcher {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+
+	return &OutboxDispatcher{
+		db:                database.GetDB(),
+		auditService:      auditService,
+		blockchainService: blockchainService,
+		interval:          interval,
+		maxAttempts:       maxAttempts,
+	}
+}
+
+// Start delivers the outbox on every interval in the background, and drops
+// delivered events past the retention
+func (d *OutboxDispatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			for {
+				delivered, err := d.Dispatch()
+				if err != nil {
+					log.Printf("Outbox dispatch failed: %v", err)
+					break
+				}
+				if delivered < outboxBatchSize {
+					break
+				}
+			}
+
+			if err := d.db.Where("dispatched_at < ?", time.Now().Add(-outboxRetention)).Delete(&models.OutboxEvent{}).Error; err != nil {
+				log.Printf("Failed to drop delivered outbox events: %v", err)
+			}
+		}
+	}()
+}
+
+// Dispatch delivers a batch of due events, oldest first, and returns how
+// many were attempted. Events locked by another dispatcher are skipped.
+// Audit entries are stored in the transaction marking them delivered, so
+// they are stored exactly once.
+func (d *OutboxDispatcher) Dispatch() (int, error) {
+	var events []models.OutboxEvent
+	var published []*models.AuditLog
+
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("id").
+			Limit(outboxBatchSize).
+			Find(&events).Error; err != nil {
+			return fmt.Errorf("failed to get outbox events: %w", err)
+		}
+
+		for i := range events {
+			event := &events[i]
+
+			// Each event is delivered in a savepoint so a failure doesn't
+			// abort the others
+			var entry *models.AuditLog
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				var err error
+				entry, err = d.deliver(tx, event)
+				return err
+			})
+			if err != nil {
+				if err := d.markFailed(tx, event, err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			now := time.Now()
+			if err := tx.Model(event).Updates(map[string]interface{}{
+				"dispatched_at": now,
+				"attempts":      event.Attempts + 1,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to mark outbox event delivered: %w", err)
+			}
+			if entry != nil {
+				published = append(published, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	d.auditService.Published(published...)
+	return len(events), nil
+}
+
+// deliver delivers one event, returning the audit entry it stored if any
+func (d *OutboxDispatcher) deliver(tx *gorm.DB, event *models.OutboxEvent) (*models.AuditLog, error) {
+	switch event.Kind {
+	case models.OutboxAudit:
+		var entry models.AuditLog
+		if err := json.Unmarshal([]byte(event.Payload), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode audit entry: %w", err)
+		}
+		entry.ID = 0
+		if err := tx.Omit(clause.Associations).Create(&entry).Error; err != nil {
+			return nil, fmt.Errorf("failed to create audit log: %w", err)
+		}
+		return &entry, nil
+
+	case models.OutboxBlockchain:
+		// Transactions of a disabled blockchain have nowhere to go
+		if d.blockchainService == nil {
+			return nil, nil
+		}
+		var transaction blockchain.Transaction
+		if err := json.Unmarshal([]byte(event.Payload), &transaction); err != nil {
+			return nil, fmt.Errorf("failed to decode blockchain transaction: %w", err)
+		}
+		return nil, d.blockchainService.QueueTransaction(transaction)
+
+	default:
+		return nil, fmt.Errorf("unknown outbox event kind: %s", event.Kind)
+	}
+}
+
+// markFailed records a failed delivery and schedules the next attempt with
+// exponential backoff, or gives up after the maximum attempts
+func (d *OutboxDispatcher) markFailed(tx *gorm.DB, event *models.OutboxEvent, cause error) error {
+	attempts := event.Attempts + 1
+	message := cause.Error()
+	if len(message) > 500 {
+		message = message[:500]
+	}
+
+	updates := map[string]interface{}{
+		"attempts":   attempts,
+		"last_error": message,
+	}
+	if attempts >= d.maxAttempts {
+		updates["failed_at"] = time.Now()
+		log.Printf("Outbox event %d (%s) failed %d times, giving up: %v", event.ID, event.Kind, attempts, cause)
+	} else {
+		backoff := d.interval << attempts
+		if backoff > outboxMaxBackoff || backoff <= 0 {
+			backoff = outboxMaxBackoff
+		}
+		updates["next_attempt_at"] = time.Now().Add(backoff)
+	}
+
+	if err := tx.Model(event).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to record outbox event failure: %w", err)
+	}
+	return nil
+}
+
+// OutboxStatus counts the events waiting to be delivered and those given up
+// on
+type OutboxStatus struct {
+	Pending int64 `json:"pending"`
+	Failed  int64 `json:"failed"`
+}
+
+// Status counts the events waiting and given up on
+func (d *OutboxDispatcher) Status() (*OutboxStatus, error) {
+	var status OutboxStatus
+	if err := d.db.Model(&models.OutboxEvent{}).Where("dispatched_at IS NULL AND failed_at IS NULL").Count(&status.Pending).Error; err != nil {
+		return nil, fmt.Errorf("failed to count pending outbox events: %w", err)
+	}
+	if err := d.db.Model(&models.OutboxEvent{}).Where("failed_at IS NOT NULL").Count(&status.Failed).Error; err != nil {
+		return nil, fmt.Errorf("failed to count failed outbox events: %w", err)
+	}
+	return &status, nil
+}
+
+// GetFailed retrieves a page of the events given up on, oldest first
+func (d *OutboxDispatcher) GetFailed(page, limit int) ([]models.OutboxEvent, int64, error) {
+	var events []models.OutboxEvent
+	var total int64
+
+	query := d.db.Model(&models.OutboxEvent{}).Where("failed_at IS NOT NULL")
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count failed outbox events: %w", err)
+	}
+	if err := query.Order("id").Offset((page - 1) * limit).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get failed outbox events: %w", err)
+	}
+	return events, total, nil
+}
+
+// Retry schedules an event given up on for delivery again, with its
+// attempts reset
+func (d *OutboxDispatcher) Retry(id uint) error {
+	result := d.db.Model(&models.OutboxEvent{}).
+		Where("id = ? AND failed_at IS NOT NULL", id).
+		Updates(map[string]interface{}{
+			"failed_at":       nil,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to retry outbox event: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrOutboxEventNotFound
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCurrentPassword is returned when the current password is wrong
+var ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+
+// PasswordChangeService changes the passwords of signed in users
+type PasswordChangeService struct {
+	db              *gorm.DB
+	passwordService *crypto.PasswordService
+	policyService   *PasswordPolicyService
+}
+
+// NewPasswordChangeService creates a new password change service
+func NewPasswordChangeService(passwordService *crypto.PasswordService, policyService *PasswordPolicyService) *PasswordChangeService {
+	return &PasswordChangeService{
+		db:              database.GetDB(),
+		passwordService: passwordService,
+		policyService:   policyService,
+	}
+}
+
+// ChangePassword replaces a user's password after checking the current one
+// and the password policy. Every refresh token of the user but keepToken,
+// the session making the change, is revoked.
+func (s *PasswordChangeService) ChangePassword(user *models.User, currentPassword, newPassword, keepToken string) error {
+	if user.AuthSource != models.AuthSourceLocal {
+		return ErrPasswordResetNotAllowed
+	}
+	if err := s.passwordService.VerifyPassword(currentPassword, user.Password); err != nil {
+		return ErrInvalidCurrentPassword
+	}
+	if err := s.policyService.Validate(newPassword, user); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.passwordService.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.policyService.recordHistory(tx, user); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password":             hashedPassword,
+			"must_change_password": false,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND is_revoked = ? AND token <> ?", user.ID, false, keepToken).
+			Update("is_revoked", true).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+	return nil
+}
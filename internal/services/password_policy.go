@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"gorm.io/gorm"
+)
+
+// MaxPasswordLength is the longest password accepted; bcrypt ignores
+// anything past 72 bytes
+const MaxPasswordLength = 72
+
+// minIdentifierLength is the shortest username or email name a password
+// may not contain, so short names don't rule out common syllables
+const minIdentifierLength = 3
+
+// commonPasswords are refused whatever the policy's banned list
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "12345", "1234567", "password", "password1",
+	"password123", "passw0rd", "p@ssw0rd", "p@ssword", "qwerty", "qwerty123", "qwertyuiop", "1q2w3e4r",
+	"1qaz2wsx", "abc123", "abcd1234", "111111", "000000", "123123", "654321", "666666", "888888",
+	"iloveyou", "admin", "admin123", "administrator", "welcome", "welcome1", "welcome123", "letmein",
+	"monkey", "dragon", "football", "baseball", "sunshine", "princess", "master", "shadow", "superman",
+	"trustno1", "changeme", "secret", "default", "login", "guest", "root", "test", "test123",
+	"zaq12wsx", "asdfghjkl", "starwars", "whatever", "freedom", "michael", "jennifer", "summer2024",
+	"winter2024", "spring2024", "autumn2024", "company123", "password!", "password1!", "qwerty1!",
+}
+
+// PasswordPolicy lists the rules new passwords must follow
+type PasswordPolicy struct {
+	MinLength          int  `json:"min_length"`
+	MaxLength          int  `json:"max_length"`
+	RequireUppercase   bool `json:"require_uppercase"`
+	RequireLowercase   bool `json:"require_lowercase"`
+	RequireDigit       bool `json:"require_digit"`
+	RequireSymbol      bool `json:"require_symbol"`
+	DisallowCommon     bool `json:"disallow_common"`      // Always true
+	DisallowIdentifier bool `json:"disallow_identifiers"` // Always true: no username or email name
+	HistoryDepth       int  `json:"history_depth"`        // Recent passwords, current included, that can't be reused; 0 allows reuse
+}
+
+// PasswordPolicyError lists the rules a password breaks
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+// PasswordPolicyService checks new passwords against the password policy
+// and keeps the password history of users
+type PasswordPolicyService struct {
+	db              *gorm.DB
+	passwordService *crypto.PasswordService
+	policy          PasswordPolicy
+	banned          map[string]bool
+}
+
+// NewPasswordPolicyService creates a new password policy service. The
+// passwords in bannedFile, one per line, are refused on top of the common
+// ones; an empty path only uses the common ones.
+func NewPasswordPolicyService(passwordService *crypto.PasswordService, policy PasswordPolicy, bannedFile string) (*PasswordPolicyService, error) {
+	policy.MaxLength = MaxPasswordLength
+	policy.DisallowCommon = true
+	policy.DisallowIdentifier = true
+	if policy.MinLength < 1 || policy.MinLength > MaxPasswordLength {
+		return nil, fmt.Errorf("password minimum length must be between 1 and %d", MaxPasswordLength)
+	}
+	if policy.HistoryDepth < 0 {
+		return nil, fmt.Errorf("password history depth must not be negative")
+	}
+
+	banned := make(map[string]bool, len(commonPasswords))
+	for _, password := range commonPasswords {
+		banned[password] = true
+	}
+	if bannedFile != "" {
+		file, err := os.Open(bannedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open banned passwords: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if password := strings.TrimSpace(scanner.Text()); password != "" {
+				banned[strings.ToLower(password)] = true
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read banned passwords: %w", err)
+		}
+	}
+
+	return &PasswordPolicyService{
+		db:              database.GetDB(),
+		passwordService: passwordService,
+		policy:          policy,
+		banned:          banned,
+	}, nil
+}
+
+// Policy returns the password policy
+func (s *PasswordPolicyService) Policy() PasswordPolicy {
+	return s.policy
+}
+
+// Validate checks a new password of a user, returning a *PasswordPolicyError
+// listing every rule it breaks. Existing users may not reuse their recent
+// passwords.
+func (s *PasswordPolicyService) Validate(password string, user *models.User) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < s.policy.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", s.policy.MinLength))
+	}
+	if len(password) > MaxPasswordLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", MaxPasswordLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if s.policy.RequireUppercase && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if s.policy.RequireLowercase && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if s.policy.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if s.policy.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if s.banned[lowered] {
+		violations = append(violations, "is too common")
+	}
+	if user != nil {
+		emailName, _, _ := strings.Cut(user.Email, "@")
+		for _, identifier := range []string{user.Username, emailName} {
+			identifier = strings.ToLower(identifier)
+			if len(identifier) >= minIdentifierLength && strings.Contains(lowered, identifier) {
+				violations = append(violations, "must not contain your username or email address")
+				break
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	// Comparing hashes is slow, so only passwords otherwise fine get here
+	if user != nil && user.ID != 0 && s.policy.HistoryDepth > 0 {
+		reused, err := s.reused(user, password)
+		if err != nil {
+			return err
+		}
+		if reused {
+			return &PasswordPolicyError{Violations: []string{fmt.Sprintf("must not be one of your last %d passwords", s.policy.HistoryDepth)}}
+		}
+	}
+	return nil
+}
+
+// reused reports whether a password is the user's current one or one of
+// the previous ones still remembered
+func (s *PasswordPolicyService) reused(user *models.User, password string) (bool, error) {
+	if user.Password != "" && s.passwordService.VerifyPassword(password, user.Password) == nil {
+		return true, nil
+	}
+	if s.policy.HistoryDepth == 1 {
+		return false, nil
+	}
+
+	var history []models.PasswordHistory
+	if err := s.db.Where("user_id = ?", user.ID).
+		Order("created_at DESC, id DESC").
+		Limit(s.policy.HistoryDepth - 1).
+		Find(&history).Error; err != nil {
+		return false, fmt.Errorf("failed to get password history: %w", err)
+	}
+	for _, previous := range history {
+		if s.passwordService.VerifyPassword(password, previous.PasswordHash) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordHistory remembers the current password of a user about to be
+// replaced within tx, forgetting passwords beyond the history depth
+func (s *PasswordPolicyService) recordHistory(tx *gorm.DB, user *models.User) error {
+	if s.policy.HistoryDepth <= 1 || user.Password == "" {
+		// The current password is all that's checked
+		return nil
+	}
+
+	if err := tx.Create(&models.PasswordHistory{UserID: user.ID, PasswordHash: user.Password}).Error; err != nil {
+		return fmt.Errorf("failed to save password history: %w", err)
+	}
+
+	var keep []uint
+	if err := tx.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", user.ID).
+		Order("created_at DESC, id DESC").
+		Limit(s.policy.HistoryDepth-1).
+		Pluck("id", &keep).Error; err != nil {
+		return fmt.Errorf("failed to get password history: %w", err)
+	}
+	if err := tx.Where("user_id = ? AND id NOT IN ?", user.ID, keep).Delete(&models.PasswordHistory{}).Error; err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+	return nil
+}
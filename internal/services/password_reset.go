@@ -0,0 +1,226 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/mailer"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidResetToken is returned for forged, expired or used reset tokens
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+	// ErrPasswordResetRateLimited is returned when a user requested too many resets
+	ErrPasswordResetRateLimited = errors.New("too many password reset requests")
+	// ErrPasswordResetNotAllowed is returned for accounts whose password isn't managed here
+	ErrPasswordResetNotAllowed = errors.New("password reset is not available for this account")
+)
+
+// PasswordResetConfig configures password resets
+type PasswordResetConfig struct {
+	Secret     string        // Signs the tokens
+	TTL        time.Duration // How long an emailed link works
+	ResetURL   string        // Page of the web application taking ?token=
+	MaxPerHour int           // Reset emails a user may request per hour
+}
+
+// PasswordResetService emails signed, single-use password reset links
+type PasswordResetService struct {
+	db              *gorm.DB
+	userService     *UserService
+	passwordService *crypto.PasswordService
+	policyService   *PasswordPolicyService
+	mailer          mailer.Mailer
+	config          PasswordResetConfig
+}
+
+// NewPasswordResetService creates a new password reset service
+func NewPasswordResetService(userService *UserService, passwordService *crypto.PasswordService, policyService *PasswordPolicyService, mailer mailer.Mailer, config PasswordResetConfig) *PasswordResetService {
+	return &PasswordResetService{
+		db:              database.GetDB(),
+		userService:     userService,
+		passwordService: passwordService,
+		policyService:   policyService,
+		mailer:          mailer,
+		config:          config,
+	}
+}
+
+// RequestReset emails a reset link to the user with the email address. The
+// user is returned for auditing, nil for unknown addresses, which callers
+// must not reveal. The email is sent in the background.
+func (s *PasswordResetService) RequestReset(email, clientIP string) (*models.User, error) {
+	user, err := s.userService.GetByEmail(strings.TrimSpace(email))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !user.IsActive || user.AuthSource != models.AuthSourceLocal {
+		return user, ErrPasswordResetNotAllowed
+	}
+
+	var recent int64
+	if err := s.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-time.Hour)).
+		Count(&recent).Error; err != nil {
+		return nil, fmt.Errorf("failed to count reset requests: %w", err)
+	}
+	if recent >= int64(s.config.MaxPerHour) {
+		return user, ErrPasswordResetRateLimited
+	}
+
+	expiresAt := time.Now().Add(s.config.TTL)
+	token, err := s.signToken(user.ID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	record := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashResetToken(token),
+		RequestIP: clientIP,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save reset token: %w", err)
+	}
+
+	name := user.FirstName
+	if name == "" {
+		name = user.Username
+	}
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"A password reset was requested for your account. Open the link below within %d minutes to choose a new password:\n\n"+
+		"%s?token=%s\n\n"+
+		"If you did not request it, ignore this email; your password stays unchanged.\n",
+		name, int(s.config.TTL.Minutes()), s.config.ResetURL, url.QueryEscape(token))
+
+	// Sending in the background keeps known and unknown addresses equally fast
+	go func() {
+		if err := s.mailer.Send(user.Email, "Reset your password", body); err != nil {
+			log.Printf("Failed to email password reset to user %d: %v", user.ID, err)
+		}
+	}()
+	return user, nil
+}
+
+// Reset sets a new password with a reset token. The password must meet the
+// password policy. Every reset token and refresh token of the user stops
+// working, and a lockout is lifted.
+func (s *PasswordResetService) Reset(token, newPassword string) (*models.User, error) {
+	userID, err := s.verifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userService.GetByID(userID)
+	if err != nil || !user.IsActive || user.AuthSource != models.AuthSourceLocal {
+		return nil, ErrInvalidResetToken
+	}
+
+	// The token stays usable when the password is refused
+	if err := s.policyService.Validate(newPassword, user); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.passwordService.HashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Only an unused token matches, so concurrent resets can't both use one
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("token_hash = ? AND user_id = ? AND used_at IS NULL AND expires_at > ?", hashResetToken(token), user.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := s.policyService.recordHistory(tx, user); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password":             hashedPassword,
+			"must_change_password": false,
+			"login_attempts":       0,
+			"locked_until":         nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND is_revoked = ?", user.ID, false).
+			Update("is_revoked", true).Error
+	})
+	if errors.Is(err, ErrInvalidResetToken) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset password: %w", err)
+	}
+	return user, nil
+}
+
+// signToken creates a token naming the user and its expiry, signed so forged
+// tokens are refused before any lookup
+func (s *PasswordResetService) signToken(userID uint, expiresAt time.Time) (string, error) {
+	payload := make([]byte, 32)
+	binary.BigEndian.PutUint64(payload[:8], uint64(userID))
+	binary.BigEndian.PutUint64(payload[8:16], uint64(expiresAt.Unix()))
+	if _, err := rand.Read(payload[16:]); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload)), nil
+}
+
+// verifyToken checks the signature and expiry of a token and returns its user
+func (s *PasswordResetService) verifyToken(token string) (uint, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, ErrInvalidResetToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) != 32 {
+		return 0, ErrInvalidResetToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, s.sign(payload)) {
+		return 0, ErrInvalidResetToken
+	}
+	if time.Now().Unix() > int64(binary.BigEndian.Uint64(payload[8:16])) {
+		return 0, ErrInvalidResetToken
+	}
+	return uint(binary.BigEndian.Uint64(payload[:8])), nil
+}
+
+// sign computes the signature of a token payload
+func (s *PasswordResetService) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte("password-reset:"+s.config.Secret))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// hashResetToken hashes a token for storage
+func hashResetToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidPrincipal is returned when a permission doesn't name exactly one
+// user, role, department or group
+var ErrInvalidPrincipal = errors.New("exactly one of user_id, role, department or group_id is required")
+
+// GetDocumentPermissions retrieves the permissions set directly on a document
+func (s *AuthorizationService) GetDocumentPermissions(documentID uint) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := s.db.Where("document_id = ?", documentID).Order("id ASC").Find(&permissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+// GetFolderPermissions retrieves the permissions set directly on a folder
+func (s *AuthorizationService) GetFolderPermissions(folderID uint) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := s.db.Where("folder_id = ?", folderID).Order("id ASC").Find(&permissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+// SetPermission creates the permission, or replaces the rights of an existing
+// permission for the same document or folder and principal. Granting to a
+// group that doesn't exist in the organization of the document or folder
+// returns ErrGroupNotFound.
+func (s *AuthorizationService) SetPermission(ctx context.Context, permission *models.Permission) error {
+	principals := 0
+	for _, set := range []bool{permission.UserID != nil, permission.Role != nil, permission.Department != nil, permission.GroupID != nil} {
+		if set {
+			principals++
+		}
+	}
+	if principals != 1 || (permission.DocumentID == nil) == (permission.FolderID == nil) {
+		return ErrInvalidPrincipal
+	}
+
+	if permission.GroupID != nil {
+		// The group must belong to the organization of the document or folder
+		resource := s.db.WithContext(ctx).Model(&models.Document{}).Select("organization_id").Where("id = ?", permission.DocumentID)
+		if permission.FolderID != nil {
+			resource = s.db.WithContext(ctx).Model(&models.Folder{}).Select("organization_id").Where("id = ?", permission.FolderID)
+		}
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.Group{}).
+			Where("id = ? AND organization_id = (?)", *permission.GroupID, resource).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to get group: %w", err)
+		}
+		if count == 0 {
+			return ErrGroupNotFound
+		}
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.Permission{})
+		if permission.DocumentID != nil {
+			query = query.Where("document_id = ?", *permission.DocumentID)
+		} else {
+			query = query.Where("folder_id = ?", *permission.FolderID)
+		}
+		switch {
+		case permission.UserID != nil:
+			query = query.Where("user_id = ?", *permission.UserID)
+		case permission.Role != nil:
+			query = query.Where("role = ?", *permission.Role)
+		case permission.GroupID != nil:
+			query = query.Where("group_id = ?", *permission.GroupID)
+		default:
+			query = query.Where("department = ?", *permission.Department)
+		}
+
+		var existing models.Permission
+		err := query.First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.Create(permission).Error; err != nil {
+				return fmt.Errorf("failed to create permission: %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get permission: %w", err)
+		}
+
+		permission.ID = existing.ID
+		permission.CreatedAt = existing.CreatedAt
+		if err := tx.Save(permission).Error; err != nil {
+			return fmt.Errorf("failed to update permission: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetPermission retrieves a permission by ID
+func (s *AuthorizationService) GetPermission(id uint) (*models.Permission, error) {
+	var permission models.Permission
+	if err := s.db.First(&permission, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get permission: %w", err)
+	}
+	return &permission, nil
+}
+
+// RevokePermission soft deletes a permission
+func (s *AuthorizationService) RevokePermission(id uint) error {
+	if err := s.db.Delete(&models.Permission{}, id).Error; err != nil {
+		return fmt.Errorf("failed to revoke permission: %w", err)
+	}
+	return nil
+}
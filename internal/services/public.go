@@ -0,0 +1,51 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// published selects the public documents of an organization, which anyone
+// may read without signing in. With row-level security the query runs for a
+// visitor cleared for public documents only.
+func (s *DocumentService) published(organizationID uint) *gorm.DB {
+	visitor := database.Principal{OrganizationID: organizationID, MaxAccessLevel: int(models.AccessPublic)}
+	return database.WithPrincipal(s.db, visitor).Model(&models.Document{}).
+		Scopes(OfOrganization("documents", organizationID)).
+		Where("documents.access_level = ?", models.AccessPublic)
+}
+
+// GetPublic retrieves the public documents of an organization with pagination
+func (s *DocumentService) GetPublic(organizationID uint, page, limit int) ([]models.Document, int64, error) {
+	var docs []models.Document
+	var total int64
+
+	offset := (page - 1) * limit
+
+	query := s.published(organizationID).Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count public documents: %w", err)
+	}
+
+	if err := query.Order("documents.updated_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&docs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get public documents: %w", err)
+	}
+
+	return docs, total, nil
+}
+
+// GetPublicByID retrieves a public document of an organization by ID
+func (s *DocumentService) GetPublicByID(organizationID, id uint) (*models.Document, error) {
+	var doc models.Document
+	if err := s.published(organizationID).Where("documents.id = ?", id).First(&doc).Error; err != nil {
+		return nil, fmt.Errorf("failed to get public document: %w", err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued at a time
+const RecoveryCodeCount = 10
+
+// recoveryCodeAlphabet leaves out characters easily confused with others
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// ErrInvalidRecoveryCode is returned for unknown or already used recovery codes
+var ErrInvalidRecoveryCode = errors.New("invalid recovery code")
+
+// RecoveryCodeService issues and redeems one-time MFA recovery codes
+type RecoveryCodeService struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeService creates a new recovery code service
+func NewRecoveryCodeService() *RecoveryCodeService {
+	return &RecoveryCodeService{
+		db: database.GetDB(),
+	}
+}
+
+// Generate issues new recovery codes for a user, replacing any previous
+// ones. The codes are only ever returned here.
+func (s *RecoveryCodeService) Generate(userID uint) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	records := make([]models.MFARecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		records[i] = models.MFARecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// Remaining counts the unused recovery codes of a user
+func (s *RecoveryCodeService) Remaining(userID uint) (int64, error) {
+	var count int64
+	if err := s.db.Model(&models.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// Redeem uses up one of a user's recovery codes
+func (s *RecoveryCodeService) Redeem(userID uint, code string) error {
+	result := s.db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to redeem recovery code: %w", result.Error)
+	}
+	// Only unused codes match, so concurrent logins can't both redeem one
+	if result.RowsAffected == 0 {
+		return ErrInvalidRecoveryCode
+	}
+	return nil
+}
+
+// Delete removes a user's recovery codes, once they no longer use MFA
+func (s *RecoveryCodeService) Delete(userID uint) error {
+	if err := s.db.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+// newRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	random := make([]byte, 10)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	var code strings.Builder
+	for i, b := range random {
+		if i == 5 {
+			code.WriteByte('-')
+		}
+		// The modulo bias over 31 characters is negligible for one-time codes
+		code.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+	}
+	return code.String(), nil
+}
+
+// hashRecoveryCode hashes a code as entered, ignoring case, spaces and dashes
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(normalized)))
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUserExists is returned when the username or email is taken
+	ErrUserExists = errors.New("username or email is already registered")
+	// ErrEmailDomainNotAllowed is returned for emails outside the allowed domains
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed")
+	// ErrRegistrationNotFound is returned for unknown users
+	ErrRegistrationNotFound = errors.New("registration not found")
+	// ErrNotPendingRegistration is returned for users not awaiting approval
+	ErrNotPendingRegistration = errors.New("user is not awaiting approval")
+)
+
+// RegistrationService handles self-service registration. Registered users
+// stay inactive until an administrator approves them.
+type RegistrationService struct {
+	db                  *gorm.DB
+	userService         *UserService
+	notificationService *NotificationService
+	allowedDomains      []string
+}
+
+// NewRegistrationService creates a new registration service. Without
+// allowedDomains any email address may register.
+func NewRegistrationService(userService *UserService, notificationService *NotificationService, allowedDomains []string) *RegistrationService {
+	return &RegistrationService{
+		db:                  database.GetDB(),
+		userService:         userService,
+		notificationService: notificationService,
+		allowedDomains:      allowedDomains,
+	}
+}
+
+// Register creates an inactive employee of the organization with the slug,
+// or of the default organization without one, awaiting approval and notifies
+// the organization's administrators. The password must already be hashed.
+func (s *RegistrationService) Register(user *models.User, organizationSlug string) error {
+	if !s.domainAllowed(user.Email) {
+		return ErrEmailDomainNotAllowed
+	}
+
+	if organizationSlug != "" {
+		var organization models.Organization
+		if err := s.db.Where("slug = ?", organizationSlug).First(&organization).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return fmt.Errorf("failed to get organization: %w", err)
+		}
+		user.OrganizationID = organization.ID
+	}
+
+	var existing int64
+	if err := s.db.Unscoped().Model(&models.User{}).
+		Where("username = ? OR email = ?", user.Username, user.Email).
+		Count(&existing).Error; err != nil {
+		return fmt.Errorf("failed to check existing users: %w", err)
+	}
+	if existing > 0 {
+		return ErrUserExists
+	}
+
+	user.Role = models.RoleEmployee
+	user.IsActive = false
+	user.PendingApproval = true
+	if err := s.userService.Create(user); err != nil {
+		return err
+	}
+
+	admins, err := s.userService.GetUsersByRole(models.RoleAdmin)
+	if err != nil {
+		log.Printf("Failed to get administrators to notify of registration %d: %v", user.ID, err)
+		return nil
+	}
+	notifications := make([]models.Notification, 0, len(admins))
+	for _, admin := range admins {
+		if !admin.IsActive || admin.OrganizationID != user.OrganizationID {
+			continue
+		}
+		notifications = append(notifications, models.Notification{
+			UserID:  admin.ID,
+			Type:    models.NotificationRegistration,
+			ActorID: &user.ID,
+			Message: fmt.Sprintf("%s (%s) registered and is awaiting approval", user.Username, user.Email),
+		})
+	}
+	if err := s.notificationService.Notify(notifications); err != nil {
+		log.Printf("Failed to notify administrators of registration %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// domainAllowed reports whether an email address may register
+func (s *RegistrationService) domainAllowed(email string) bool {
+	if len(s.allowedDomains) == 0 {
+		return true
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, allowed := range s.allowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPending retrieves the registrations of an organization awaiting
+// approval, oldest first
+func (s *RegistrationService) GetPending(organizationID uint, page, limit int) ([]models.User, int64, error) {
+	query := s.db.Model(&models.User{}).Scopes(OfOrganization("users", organizationID)).Where("pending_approval = ?", true)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count registrations: %w", err)
+	}
+
+	var users []models.User
+	if err := query.Order("id ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get registrations: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// Approve activates a registered user of an organization, optionally with
+// another role or department than the one registered
+func (s *RegistrationService) Approve(organizationID, id uint, role models.Role, department *string) (*models.User, error) {
+	user, err := s.pending(organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.IsActive = true
+	user.PendingApproval = false
+	if role != "" {
+		user.Role = role
+	}
+	if department != nil {
+		user.Department = *department
+	}
+	if err := s.db.Model(user).Select("is_active", "pending_approval", "role", "department").Updates(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to approve registration: %w", err)
+	}
+
+	if err := s.notificationService.Notify([]models.Notification{{
+		UserID:  user.ID,
+		Type:    models.NotificationRegistration,
+		Message: "Your account was approved",
+	}}); err != nil {
+		log.Printf("Failed to notify user %d of approval: %v", user.ID, err)
+	}
+	return user, nil
+}
+
+// Reject declines a registration to an organization; the account stays
+// inactive
+func (s *RegistrationService) Reject(organizationID, id uint) (*models.User, error) {
+	user, err := s.pending(organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.PendingApproval = false
+	if err := s.db.Model(user).Update("pending_approval", false).Error; err != nil {
+		return nil, fmt.Errorf("failed to reject registration: %w", err)
+	}
+	return user, nil
+}
+
+// pending retrieves a user of an organization awaiting approval
+func (s *RegistrationService) pending(organizationID, id uint) (*models.User, error) {
+	user, err := s.userService.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRegistrationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.OrganizationID != organizationID {
+		return nil, ErrRegistrationNotFound
+	}
+	if !user.PendingApproval {
+		return nil, ErrNotPendingRegistration
+	}
+	return user, nil
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// roleNamePattern restricts role names to what fits the users' role column
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,19}$`)
+
+// builtInRoles are the roles the system is built around
+var builtInRoles = []models.Role{models.RoleAdmin, models.RoleManager, models.RoleEmployee, models.RoleGuest}
+
+var (
+	// ErrRoleNotFound is returned for unknown roles
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleExists is returned when creating a role whose name is taken
+	ErrRoleExists = errors.New("role already exists")
+	// ErrInvalidRoleName is returned for role names that aren't lowercase
+	// letters, digits and underscores
+	ErrInvalidRoleName = errors.New("role names must be 2-20 lowercase letters, digits or underscores")
+	// ErrUnknownCapability is returned for capabilities that don't exist
+	ErrUnknownCapability = errors.New("unknown capability")
+	// ErrBuiltInRole is returned when deleting a built-in role or changing the admin role
+	ErrBuiltInRole = errors.New("built-in role can't be changed")
+	// ErrRoleInUse is returned when deleting a role users still have
+	ErrRoleInUse = errors.New("role is assigned to users")
+	// ErrLastAdmin is returned when taking the admin role from the last active
+	// admin of an organization
+	ErrLastAdmin = errors.New("the last active admin can't lose the admin role")
+	// ErrOwnRole is returned when users change their own role
+	ErrOwnRole = errors.New("users can't change their own role")
+	// ErrAdminRoleRequired is returned when non-admins give or take the
+	// admin role
+	ErrAdminRoleRequired = errors.New("only admins can give or take the admin role")
+	// ErrCapabilityNotHeld is returned when giving or taking a role with
+	// capabilities the user changing it doesn't hold
+	ErrCapabilityNotHeld = errors.New("role has capabilities you don't hold")
+)
+
+// RoleService manages the roles users can be given and the capabilities
+// they hold
+type RoleService struct {
+	db *gorm.DB
+}
+
+// NewRoleService creates a new role service
+func NewRoleService() *RoleService {
+	return &RoleService{
+		db: database.GetDB(),
+	}
+}
+
+// normalizeCapabilities checks capabilities, returning them without
+// duplicates in the order of models.Capabilities
+func normalizeCapabilities(capabilities []string) ([]string, error) {
+	for _, capability := range capabilities {
+		if !slices.Contains(models.Capabilities, strings.TrimSpace(capability)) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCapability, capability)
+		}
+	}
+
+	normalized := []string{}
+	for _, capability := range models.Capabilities {
+		if slices.ContainsFunc(capabilities, func(c string) bool { return strings.TrimSpace(c) == capability }) {
+			normalized = append(normalized, capability)
+		}
+	}
+	return normalized, nil
+}
+
+// GetRoles lists the roles, built-in ones first
+func (s *RoleService) GetRoles() ([]models.RoleDefinition, error) {
+	var roles []models.RoleDefinition
+	if err := s.db.Order("built_in DESC, name").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+	return roles, nil
+}
+
+// GetRole retrieves a role
+func (s *RoleService) GetRole(id uint) (*models.RoleDefinition, error) {
+	var role models.RoleDefinition
+	if err := s.db.First(&role, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return &role, nil
+}
+
+// Exists reports whether users can be given the role. The built-in roles
+// always exist.
+func (s *RoleService) Exists(name models.Role) (bool, error) {
+	if slices.Contains(builtInRoles, name) {
+		return true, nil
+	}
+
+	var count int64
+	if err := s.db.Model(&models.RoleDefinition{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to get role: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create adds a role with the capabilities
+func (s *RoleService) Create(name models.Role, description string, capabilities []string) (*models.RoleDefinition, error) {
+	if !roleNamePattern.MatchString(string(name)) {
+		return nil, ErrInvalidRoleName
+	}
+	normalized, err := normalizeCapabilities(capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.Exists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrRoleExists
+	}
+
+	role := &models.RoleDefinition{
+		Name:         name,
+		Description:  description,
+		Capabilities: strings.Join(normalized, ","),
+	}
+	if err := s.db.Create(role).Error; err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+	return role, nil
+}
+
+// Update changes the description and capabilities of a role. Nil leaves a
+// field unchanged. The admin role can't be changed.
+func (s *RoleService) Update(id uint, description *string, capabilities []string) (*models.RoleDefinition, error) {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return nil, err
+	}
+	if role.Name == models.RoleAdmin {
+		return nil, ErrBuiltInRole
+	}
+
+	if description != nil {
+		role.Description = *description
+	}
+	if capabilities != nil {
+		normalized, err := normalizeCapabilities(capabilities)
+		if err != nil {
+			return nil, err
+		}
+		role.Capabilities = strings.Join(normalized, ",")
+	}
+
+	if err := s.db.Model(role).Select("description", "capabilities").Updates(role).Error; err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+	return role, nil
+}
+
+// Delete removes a role no user has, along with the permissions granted to
+// it. Built-in roles can't be deleted.
+func (s *RoleService) Delete(id uint) (*models.RoleDefinition, error) {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return nil, err
+	}
+	if role.BuiltIn || slices.Contains(builtInRoles, role.Name) {
+		return nil, ErrBuiltInRole
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var users int64
+		if err := tx.Model(&models.User{}).Where("role = ?", role.Name).Count(&users).Error; err != nil {
+			return fmt.Errorf("failed to count users: %w", err)
+		}
+		if users > 0 {
+			return ErrRoleInUse
+		}
+
+		if err := tx.Where("role = ?", role.Name).Delete(&models.Permission{}).Error; err != nil {
+			return fmt.Errorf("failed to delete role permissions: %w", err)
+		}
+		if err := tx.Delete(role).Error; err != nil {
+			return fmt.Errorf("failed to delete role: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// Capabilities returns the capabilities of a role; admins hold all of them
+func (s *RoleService) Capabilities(name models.Role) ([]string, error) {
+	if name == models.RoleAdmin {
+		return slices.Clone(models.Capabilities), nil
+	}
+
+	var role models.RoleDefinition
+	if err := s.db.Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	if role.Capabilities == "" {
+		return []string{}, nil
+	}
+	return strings.Split(role.Capabilities, ","), nil
+}
+
+// HasCapability reports whether a role holds a capability
+func (s *RoleService) HasCapability(name models.Role, capability string) (bool, error) {
+	capabilities, err := s.Capabilities(name)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(capabilities, capability), nil
+}
+
+// SetUserRole gives a user a role on behalf of actor. Actors don't change
+// their own role, only admins give or take the admin role, and actors can't
+// give or take roles with capabilities they don't hold themselves. The last
+// active admin of an organization keeps theirs.
+func (s *RoleService) SetUserRole(actor, user *models.User, name models.Role) error {
+	exists, err := s.Exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRoleNotFound
+	}
+
+	held, err := s.Capabilities(actor.Role)
+	if err != nil {
+		return err
+	}
+	current, err := s.Capabilities(user.Role)
+	if err != nil {
+		return err
+	}
+	granted, err := s.Capabilities(name)
+	if err != nil {
+		return err
+	}
+	if err := checkRoleChange(actor, user, name, held, current, granted); err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if user.Role == models.RoleAdmin && name != models.RoleAdmin && user.IsActive {
+			var admins int64
+			if err := tx.Model(&models.User{}).
+				Where("organization_id = ? AND role = ? AND is_active = ?", user.OrganizationID, models.RoleAdmin, true).
+				Count(&admins).Error; err != nil {
+				return fmt.Errorf("failed to count admins: %w", err)
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		if err := tx.Model(user).Update("role", name).Error; err != nil {
+			return fmt.Errorf("failed to set user role: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	user.Role = name
+	return nil
+}
+
+// checkRoleChange refuses role changes actor may not make: of their own
+// role, to or from admin unless actor is an admin, and to or from roles with
+// capabilities beyond held, those of actor
+func checkRoleChange(actor, user *models.User, name models.Role, held, current, granted []string) error {
+	if actor.ID == user.ID {
+		return ErrOwnRole
+	}
+	if (user.Role == models.RoleAdmin || name == models.RoleAdmin) && actor.Role != models.RoleAdmin {
+		return ErrAdminRoleRequired
+	}
+	for _, capability := range append(slices.Clone(current), granted...) {
+		if !slices.Contains(held, capability) {
+			return fmt.Errorf("%w: %s", ErrCapabilityNotHeld, capability)
+		}
+	}
+	return nil
+}
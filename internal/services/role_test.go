@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+func TestCheckRoleChangeRefusesOwnRole(t *testing.T) {
+	admin := &models.User{ID: 1, Role: models.RoleAdmin}
+	all := slices.Clone(models.Capabilities)
+
+	err := checkRoleChange(admin, admin, models.RoleEmployee, all, all, nil)
+	if !errors.Is(err, ErrOwnRole) {
+		t.Errorf("checkRoleChange(own role) = %v, want %v", err, ErrOwnRole)
+	}
+}
+
+func TestCheckRoleChangeLeavesAdminRoleToAdmins(t *testing.T) {
+	manager := &models.User{ID: 1, Role: "role_manager"}
+	held := []string{models.CapabilityManageRoles}
+	all := slices.Clone(models.Capabilities)
+
+	employee := &models.User{ID: 2, Role: models.RoleEmployee}
+	if err := checkRoleChange(manager, employee, models.RoleAdmin, held, nil, all); !errors.Is(err, ErrAdminRoleRequired) {
+		t.Errorf("checkRoleChange(grant admin) = %v, want %v", err, ErrAdminRoleRequired)
+	}
+
+	admin := &models.User{ID: 3, Role: models.RoleAdmin}
+	if err := checkRoleChange(manager, admin, models.RoleEmployee, held, all, nil); !errors.Is(err, ErrAdminRoleRequired) {
+		t.Errorf("checkRoleChange(revoke admin) = %v, want %v", err, ErrAdminRoleRequired)
+	}
+
+	actor := &models.User{ID: 4, Role: models.RoleAdmin}
+	if err := checkRoleChange(actor, employee, models.RoleAdmin, all, nil, all); err != nil {
+		t.Errorf("checkRoleChange(admin grants admin) = %v", err)
+	}
+}
+
+func TestCheckRoleChangeRefusesCapabilitiesNotHeld(t *testing.T) {
+	manager := &models.User{ID: 1, Role: "role_manager"}
+	held := []string{models.CapabilityManageRoles, models.CapabilityViewStats}
+	employee := &models.User{ID: 2, Role: models.RoleEmployee}
+
+	tests := []struct {
+		name    string
+		current []string
+		granted []string
+		refused bool
+	}{
+		{"subset", nil, []string{models.CapabilityViewStats}, false},
+		{"same capabilities", nil, held, false},
+		{"grants more", nil, []string{models.CapabilityViewStats, models.CapabilityManageKeys}, true},
+		{"takes more", []string{models.CapabilityPurgeDocuments}, nil, true},
+	}
+	for _, tt := range tests {
+		err := checkRoleChange(manager, employee, "custom", held, tt.current, tt.granted)
+		if tt.refused && !errors.Is(err, ErrCapabilityNotHeld) {
+			t.Errorf("%s: checkRoleChange = %v, want %v", tt.name, err, ErrCapabilityNotHeld)
+		}
+		if !tt.refused && err != nil {
+			t.Errorf("%s: checkRoleChange = %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ScopeAreas are the parts of the API that API keys and OAuth clients can
+// be granted, each as <area>:read for reading and <area>:write for
+// anything. Neither ever reaches the /auth routes, so they can't manage
+// accounts or credentials.
+var ScopeAreas = []string{
+	"documents", "uploads", "bulk-uploads", "exports", "imports", "folders", "tags", "notifications",
+	"subscriptions", "favorites", "activity", "stats", "metadata-fields", "categories", "groups", "admin",
+}
+
+// ScopeError is returned for scopes that don't exist, or with an empty
+// Scope when none are given
+type ScopeError struct {
+	Scope string
+}
+
+func (e *ScopeError) Error() string {
+	if e.Scope == "" {
+		return "at least one scope is required"
+	}
+	return fmt.Sprintf("unknown scope: %s", e.Scope)
+}
+
+// normalizeScopes checks scopes, returning them lowercased without
+// duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	var normalized []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		area, access, _ := strings.Cut(scope, ":")
+		if !slices.Contains(ScopeAreas, area) || (access != "read" && access != "write") {
+			return nil, &ScopeError{Scope: scope}
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, &ScopeError{}
+	}
+	return normalized, nil
+}
+
+// ScopesAllow reports whether scopes let a request read, or with write
+// also change, an area of the API. Write access implies read access.
+func ScopesAllow(scopes []string, area string, write bool) bool {
+	for _, scope := range scopes {
+		scopeArea, access, _ := strings.Cut(scope, ":")
+		if scopeArea == area && (access == "write" || !write) {
+			return true
+		}
+	}
+	return false
+}
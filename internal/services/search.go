@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/search"
+	"gorm.io/gorm"
+)
+
+// DocumentSearchResult represents a document matched by a search with its rank
+type DocumentSearchResult struct {
+	models.Document
+	Rank float64 `json:"rank"`
+}
+
+// SearchService handles document search
+type SearchService struct {
+	db      *gorm.DB
+	elastic *search.ElasticsearchClient
+}
+
+// NewSearchService creates a new search service. When elastic is nil, search
+// is served by Postgres full-text search.
+func NewSearchService(elastic *search.ElasticsearchClient) *SearchService {
+	return &SearchService{
+		db:      database.GetDB(),
+		elastic: elastic,
+	}
+}
+
+// Search performs a ranked full-text search limited to documents the user may
+// read and, when filter is not nil, matching the filter
+func (s *SearchService) Search(ctx context.Context, user *models.User, query string, filter *DocumentFilter, page, limit int) ([]DocumentSearchResult, int64, error) {
+	if filter == nil {
+		filter = &DocumentFilter{}
+	}
+	if s.elastic != nil {
+		return s.searchElastic(ctx, user, query, filter, page, limit)
+	}
+	return s.searchPostgres(ctx, user, query, filter, page, limit)
+}
+
+// searchPostgres searches document title, description and tags using the
+// generated tsvector column
+func (s *SearchService) searchPostgres(ctx context.Context, user *models.User, query string, filter *DocumentFilter, page, limit int) ([]DocumentSearchResult, int64, error) {
+	var results []DocumentSearchResult
+	var total int64
+
+	offset := (page - 1) * limit
+
+	base := s.db.WithContext(ctx).Model(&models.Document{}).
+		Scopes(AccessibleDocuments(user), filter.Apply).
+		Where("documents.search_vector @@ websearch_to_tsquery('simple', ?)", query).
+		Session(&gorm.Session{})
+
+	if err := base.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	if err := base.
+		Select("documents.*, ts_rank(documents.search_vector, websearch_to_tsquery('simple', ?)) AS rank", query).
+		Order("rank DESC, documents.created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&results).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search documents: %w", err)
+	}
+
+	return results, total, nil
+}
+
+// searchElastic searches document metadata and extracted content in
+// Elasticsearch. Filters on custom metadata, which is not indexed, are
+// resolved in Postgres and passed to the index as a set of document IDs.
+func (s *SearchService) searchElastic(ctx context.Context, user *models.User, query string, documentFilter *DocumentFilter, page, limit int) ([]DocumentSearchResult, int64, error) {
+	filter := search.Filter{
+		OrganizationID: user.OrganizationID,
+		MaxAccessLevel: MaxAccessLevel(user.Role),
+		CreatedBy:      user.ID,
+		Unrestricted:   user.Role == models.RoleAdmin,
+	}
+
+	if !filter.Unrestricted {
+		if err := sharedWithUser(s.db.WithContext(ctx), user).Pluck("documents.id", &filter.SharedIDs).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to get shared documents: %w", err)
+		}
+	}
+
+	if len(documentFilter.Metadata) > 0 {
+		filter.DocumentIDs = []uint{}
+		if err := s.db.WithContext(ctx).Model(&models.Document{}).
+			Scopes(OfOrganization("documents", user.OrganizationID), documentFilter.Apply).
+			Pluck("documents.id", &filter.DocumentIDs).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to filter documents: %w", err)
+		}
+		if len(filter.DocumentIDs) == 0 {
+			return []DocumentSearchResult{}, 0, nil
+		}
+	}
+
+	hits, total, err := s.elastic.Search(ctx, query, filter, (page-1)*limit, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search documents: %w", err)
+	}
+
+	if len(hits) == 0 {
+		return []DocumentSearchResult{}, total, nil
+	}
+
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.DocumentID)
+	}
+
+	var docs []models.Document
+	if err := s.db.WithContext(ctx).Where("id IN ? AND organization_id = ?", ids, user.OrganizationID).Find(&docs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	byID := make(map[uint]models.Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+
+	// Keep the relevance order of the index, skipping stale entries
+	results := make([]DocumentSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if doc, ok := byID[hit.DocumentID]; ok {
+			results = append(results, DocumentSearchResult{Document: doc, Rank: hit.Score})
+		}
+	}
+
+	return results, total, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/alerting"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// notificationAttempts is how many times delivery to a channel is tried
+const notificationAttempts = 3
+
+// SecurityAlertNotificationService notifies the configured channels of
+// security alerts of at least a severity as they are raised, acknowledged
+// and resolved
+type SecurityAlertNotificationService struct {
+	notifiers   []alerting.Notifier
+	minSeverity models.AlertSeverity
+	alertURL    string
+}
+
+// NewSecurityAlertNotificationService creates a new security alert
+// notification service. alertURL is the page showing an alert, taking
+// ?id=, or empty.
+func NewSecurityAlertNotificationService(notifiers []alerting.Notifier, minSeverity models.AlertSeverity, alertURL string) (*SecurityAlertNotificationService, error) {
+	if severityRank(minSeverity) < 0 {
+		return nil, fmt.Errorf("unknown alert severity: %s", minSeverity)
+	}
+	if alertURL != "" {
+		if parsed, err := url.Parse(alertURL); err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid security alert URL: %s", alertURL)
+		}
+	}
+
+	return &SecurityAlertNotificationService{
+		notifiers:   notifiers,
+		minSeverity: minSeverity,
+		alertURL:    alertURL,
+	}, nil
+}
+
+// Notify sends an event of an alert to every channel in the background.
+// Alerts below the minimum severity aren't notified.
+func (s *SecurityAlertNotificationService) Notify(event string, alert *models.SecurityAlert) {
+	if severityRank(alert.Severity) < severityRank(s.minSeverity) {
+		return
+	}
+
+	notification := alerting.Alert{
+		ID:             alert.ID,
+		OrganizationID: alert.OrganizationID,
+		Rule:           alert.Rule,
+		Severity:       string(alert.Severity),
+		Summary:        alert.Summary,
+		UserID:         alert.UserID,
+		IPAddress:      alert.IPAddress,
+		EventCount:     alert.EventCount,
+		FirstSeenAt:    alert.FirstSeenAt,
+		LastSeenAt:     alert.LastSeenAt,
+		Details:        alert.Details,
+	}
+	if s.alertURL != "" {
+		notification.URL = s.alertURL + "?id=" + strconv.Itoa(int(alert.ID))
+	}
+
+	for _, notifier := range s.notifiers {
+		go s.deliver(notifier, event, notification)
+	}
+}
+
+// deliver sends the event to one channel, retrying failures with backoff
+func (s *SecurityAlertNotificationService) deliver(notifier alerting.Notifier, event string, alert alerting.Alert) {
+	backoff := 5 * time.Second
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := notifier.Notify(ctx, event, alert)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt == notificationAttempts {
+			log.Printf("Failed to notify %s of security alert %d over %s: %v", event, alert.ID, notifier.Name(), err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 4
+	}
+}
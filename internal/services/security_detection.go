@@ -0,0 +1,607 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/alerting"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// Detection rules raising security alerts
+const (
+	RuleFailedLogins        = "failed_logins"
+	RuleOffHoursDownload    = "off_hours_download"
+	RuleMassDownload        = "mass_download"
+	RulePrivilegeEscalation = "privilege_escalation"
+	RuleChainInvalid        = "chain_invalid"
+)
+
+const (
+	// failedLoginWindow is the period failed logins from an IP are counted in
+	failedLoginWindow = 15 * time.Minute
+	// massDownloadWindow is the period downloads of a user are counted in
+	massDownloadWindow = time.Hour
+	// offHoursMergeWindow is how long after an off-hours download further
+	// ones add to its alert
+	offHoursMergeWindow = 12 * time.Hour
+	// chainMergeWindow is how long after the chain was last found invalid
+	// finding it so again updates the unresolved alert
+	chainMergeWindow = 24 * time.Hour
+)
+
+// downloadActions are the audit actions of users taking content out
+var downloadActions = []string{"document_download", "export_download"}
+
+// ErrSecurityAlertNotFound is returned when an alert doesn't exist in the
+// organization
+var ErrSecurityAlertNotFound = errors.New("security alert not found")
+
+// SecurityDetectionConfig holds the thresholds of the detection rules
+type SecurityDetectionConfig struct {
+	Interval              time.Duration
+	FailedLoginThreshold  int // Failed logins from an IP within 15 minutes
+	MassDownloadThreshold int // Downloads by a user within an hour
+	BusinessHoursStart    int // Hour of day business hours start at, on weekdays
+	BusinessHoursEnd      int
+	Location              *time.Location // Of business hours
+}
+
+// SecurityDetectionService looks for suspicious activity in the audit log on
+// an interval and records it as security alerts
+type SecurityDetectionService struct {
+	db                *gorm.DB
+	roleService       *RoleService
+	auditService      *AuditService
+	blockchainService *BlockchainService
+	notifications     *SecurityAlertNotificationService
+	config            SecurityDetectionConfig
+	lastRun           time.Time
+}
+
+// NewSecurityDetectionService creates a new security detection service. The
+// chain is only validated with a blockchain service, and alerts are only
+// notified with a notification service.
+func NewSecurityDetectionService(roleService *RoleService, auditService *AuditService, blockchainService *BlockchainService, notifications *SecurityAlertNotificationService, config SecurityDetectionConfig) *SecurityDetectionService {
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Minute
+	}
+	if config.Location == nil {
+		config.Location = time.UTC
+	}
+
+	return &SecurityDetectionService{
+		db:                database.GetDB(),
+		roleService:       roleService,
+		auditService:      auditService,
+		blockchainService: blockchainService,
+		notifications:     notifications,
+		config:            config,
+		lastRun:           time.Now().Add(-config.Interval),
+	}
+}
+
+// Start runs the detection rules on every interval in the background
+func (s *SecurityDetectionService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			raised, err := s.Detect()
+			if err != nil {
+				log.Printf("Security detection failed: %v", err)
+				continue
+			}
+			if raised > 0 {
+				log.Printf("Security detection raised %d alerts", raised)
+			}
+		}
+	}()
+}
+
+// Detect runs every rule over the audit entries since the previous run and
+// returns how many new alerts were raised
+func (s *SecurityDetectionService) Detect() (int, error) {
+	now := time.Now()
+	since := s.lastRun
+
+	rules := []func(since, now time.Time) (int, error){
+		s.detectFailedLogins,
+		s.detectMassDownloads,
+		s.detectOffHoursDownloads,
+		s.detectPrivilegeEscalation,
+		s.detectChainFailure,
+	}
+
+	raised := 0
+	for _, rule := range rules {
+		count, err := rule(since, now)
+		raised += count
+		if err != nil {
+			return raised, err
+		}
+	}
+
+	s.lastRun = now
+	return raised, nil
+}
+
+// activityCount is the number of audit entries of a subject in a period
+type activityCount struct {
+	OrganizationID uint
+	UserID         uint
+	IPAddress      string
+	Count          int
+	FirstSeen      time.Time
+	LastSeen       time.Time
+}
+
+// detectFailedLogins alerts on IPs with many failed logins within the window,
+// a sign of password guessing
+func (s *SecurityDetectionService) detectFailedLogins(since, now time.Time) (int, error) {
+	var counts []activityCount
+	if err := s.db.Model(&models.AuditLog{}).
+		Select("organization_id, ip_address, COUNT(*) AS count, MIN(timestamp) AS first_seen, MAX(timestamp) AS last_seen").
+		Where("action = ? AND ip_address <> '' AND timestamp > ?", "login_failed", now.Add(-failedLoginWindow)).
+		Group("organization_id, ip_address").
+		Having("COUNT(*) >= ?", s.config.FailedLoginThreshold).
+		Scan(&counts).Error; err != nil {
+		return 0, fmt.Errorf("failed to count failed logins: %w", err)
+	}
+
+	raised := 0
+	for _, count := range counts {
+		severity := models.AlertSeverityHigh
+		if count.Count >= 5*s.config.FailedLoginThreshold {
+			severity = models.AlertSeverityCritical
+		}
+		created, err := s.raise(&models.SecurityAlert{
+			OrganizationID: count.OrganizationID,
+			Rule:           RuleFailedLogins,
+			Severity:       severity,
+			DedupKey:       "ip:" + count.IPAddress,
+			IPAddress:      count.IPAddress,
+			Summary:        fmt.Sprintf("%d failed logins from %s within %d minutes", count.Count, count.IPAddress, int(failedLoginWindow.Minutes())),
+			EventCount:     count.Count,
+			FirstSeenAt:    count.FirstSeen,
+			LastSeenAt:     count.LastSeen,
+		}, failedLoginWindow, nil)
+		if err != nil {
+			return raised, err
+		}
+		if created {
+			raised++
+		}
+	}
+	return raised, nil
+}
+
+// detectMassDownloads alerts on users downloading many documents within the
+// window, a sign of data being taken out
+func (s *SecurityDetectionService) detectMassDownloads(since, now time.Time) (int, error) {
+	var counts []activityCount
+	if err := s.db.Model(&models.AuditLog{}).
+		Select("organization_id, user_id, COUNT(*) AS count, MIN(timestamp) AS first_seen, MAX(timestamp) AS last_seen").
+		Where("action IN ? AND user_id <> 0 AND timestamp > ?", downloadActions, now.Add(-massDownloadWindow)).
+		Group("organization_id, user_id").
+		Having("COUNT(*) >= ?", s.config.MassDownloadThreshold).
+		Scan(&counts).Error; err != nil {
+		return 0, fmt.Errorf("failed to count downloads: %w", err)
+	}
+
+	raised := 0
+	for _, count := range counts {
+		userID := count.UserID
+		created, err := s.raise(&models.SecurityAlert{
+			OrganizationID: count.OrganizationID,
+			Rule:           RuleMassDownload,
+			Severity:       models.AlertSeverityHigh,
+			DedupKey:       "user:" + strconv.Itoa(int(userID)),
+			UserID:         &userID,
+			Summary:        fmt.Sprintf("User %d downloaded %d times within an hour", userID, count.Count),
+			EventCount:     count.Count,
+			FirstSeenAt:    count.FirstSeen,
+			LastSeenAt:     count.LastSeen,
+		}, massDownloadWindow, nil)
+		if err != nil {
+			return raised, err
+		}
+		if created {
+			raised++
+		}
+	}
+	return raised, nil
+}
+
+// detectOffHoursDownloads alerts on users downloading outside business
+// hours or on weekends
+func (s *SecurityDetectionService) detectOffHoursDownloads(since, now time.Time) (int, error) {
+	var entries []models.AuditLog
+	if err := s.db.Where("action IN ? AND user_id <> 0 AND timestamp > ? AND timestamp <= ?", downloadActions, since, now).
+		Order("timestamp ASC").
+		Find(&entries).Error; err != nil {
+		return 0, fmt.Errorf("failed to get downloads: %w", err)
+	}
+
+	// Group the off-hours downloads of each user
+	type subject struct{ organizationID, userID uint }
+	grouped := make(map[subject][]models.AuditLog)
+	var order []subject
+	for _, entry := range entries {
+		if s.duringBusinessHours(entry.Timestamp) {
+			continue
+		}
+		key := subject{entry.OrganizationID, entry.UserID}
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], entry)
+	}
+
+	raised := 0
+	for _, key := range order {
+		downloads := grouped[key]
+		ids := make([]uint, 0, len(downloads))
+		for _, entry := range downloads {
+			ids = append(ids, entry.ID)
+		}
+
+		userID := key.userID
+		first, last := downloads[0], downloads[len(downloads)-1]
+		created, err := s.raise(&models.SecurityAlert{
+			OrganizationID: key.organizationID,
+			Rule:           RuleOffHoursDownload,
+			Severity:       models.AlertSeverityMedium,
+			DedupKey:       "user:" + strconv.Itoa(int(userID)),
+			UserID:         &userID,
+			IPAddress:      last.IPAddress,
+			Summary:        fmt.Sprintf("User %d downloaded outside business hours", userID),
+			EventCount:     len(downloads),
+			FirstSeenAt:    first.Timestamp,
+			LastSeenAt:     last.Timestamp,
+		}, offHoursMergeWindow, ids)
+		if err != nil {
+			return raised, err
+		}
+		if created {
+			raised++
+		}
+	}
+	return raised, nil
+}
+
+// duringBusinessHours reports whether a time falls on a weekday within
+// business hours
+func (s *SecurityDetectionService) duringBusinessHours(t time.Time) bool {
+	local := t.In(s.config.Location)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	return local.Hour() >= s.config.BusinessHoursStart && local.Hour() < s.config.BusinessHoursEnd
+}
+
+// detectPrivilegeEscalation alerts on users given a role with capabilities
+// they didn't have
+func (s *SecurityDetectionService) detectPrivilegeEscalation(since, now time.Time) (int, error) {
+	var entries []models.AuditLog
+	if err := s.db.Where("action = ? AND timestamp > ? AND timestamp <= ?", "user_role_change", since, now).
+		Order("timestamp ASC").
+		Find(&entries).Error; err != nil {
+		return 0, fmt.Errorf("failed to get role changes: %w", err)
+	}
+
+	raised := 0
+	for _, entry := range entries {
+		var details struct {
+			Username     string      `json:"username"`
+			PreviousRole models.Role `json:"previous_role"`
+			Role         models.Role `json:"role"`
+		}
+		if err := json.Unmarshal([]byte(entry.Details), &details); err != nil {
+			continue
+		}
+
+		previous, err := s.roleService.Capabilities(details.PreviousRole)
+		if err != nil {
+			return raised, err
+		}
+		current, err := s.roleService.Capabilities(details.Role)
+		if err != nil {
+			return raised, err
+		}
+		var gained []string
+		for _, capability := range current {
+			if !slices.Contains(previous, capability) {
+				gained = append(gained, capability)
+			}
+		}
+		if len(gained) == 0 {
+			continue
+		}
+
+		severity := models.AlertSeverityHigh
+		if details.Role == models.RoleAdmin {
+			severity = models.AlertSeverityCritical
+		}
+
+		var userID *uint
+		if id, err := strconv.ParseUint(entry.ResourceID, 10, 64); err == nil {
+			target := uint(id)
+			userID = &target
+		}
+
+		evidence, _ := json.Marshal(map[string]interface{}{
+			"changed_by":    entry.UserID,
+			"previous_role": details.PreviousRole,
+			"role":          details.Role,
+			"gained":        gained,
+		})
+		created, err := s.raise(&models.SecurityAlert{
+			OrganizationID: entry.OrganizationID,
+			Rule:           RulePrivilegeEscalation,
+			Severity:       severity,
+			DedupKey:       "entry:" + strconv.Itoa(int(entry.ID)),
+			UserID:         userID,
+			IPAddress:      entry.IPAddress,
+			Summary:        fmt.Sprintf("%s was given the %s role by user %d", details.Username, details.Role, entry.UserID),
+			Details:        string(evidence),
+			EventCount:     1,
+			FirstSeenAt:    entry.Timestamp,
+			LastSeenAt:     entry.Timestamp,
+		}, 0, []uint{entry.ID})
+		if err != nil {
+			return raised, err
+		}
+		if created {
+			raised++
+		}
+	}
+	return raised, nil
+}
+
+// detectChainFailure alerts every organization when the blockchain fails
+// validation, as its records no longer prove the history of documents
+func (s *SecurityDetectionService) detectChainFailure(since, now time.Time) (int, error) {
+	if s.blockchainService == nil {
+		return 0, nil
+	}
+
+	verification, err := s.blockchainService.Verify()
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify blockchain: %w", err)
+	}
+	if verification.Valid {
+		return 0, nil
+	}
+
+	var organizationIDs []uint
+	if err := s.db.Model(&models.Organization{}).Pluck("id", &organizationIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to get organizations: %w", err)
+	}
+
+	evidence, _ := json.Marshal(map[string]interface{}{
+		"blocks":             verification.Blocks,
+		"latest_block_hash":  verification.LatestBlockHash,
+		"anchors_mismatched": verification.Anchors.Mismatched,
+	})
+	raised := 0
+	for _, organizationID := range organizationIDs {
+		created, err := s.raise(&models.SecurityAlert{
+			OrganizationID: organizationID,
+			Rule:           RuleChainInvalid,
+			Severity:       models.AlertSeverityCritical,
+			DedupKey:       "chain",
+			Summary:        "The blockchain failed validation",
+			Details:        string(evidence),
+			EventCount:     1,
+			FirstSeenAt:    now,
+			LastSeenAt:     now,
+		}, chainMergeWindow, nil)
+		if err != nil {
+			return raised, err
+		}
+		if created {
+			raised++
+		}
+	}
+	return raised, nil
+}
+
+// raise records an alert unless one of the same rule and key exists. With a
+// merge window, activity continuing within it updates the unresolved alert
+// instead; entry IDs add to its evidence. It reports whether a new alert was
+// raised, which is audited and notified.
+func (s *SecurityDetectionService) raise(alert *models.SecurityAlert, merge time.Duration, entryIDs []uint) (bool, error) {
+	created := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("organization_id = ? AND rule = ? AND dedup_key = ?", alert.OrganizationID, alert.Rule, alert.DedupKey)
+		if merge > 0 {
+			query = query.Where("status <> ? AND last_seen_at >= ?", models.AlertStatusResolved, alert.FirstSeenAt.Add(-merge))
+		}
+
+		var existing models.SecurityAlert
+		err := query.Order("id DESC").First(&existing).Error
+		if err == nil {
+			if merge == 0 {
+				return nil
+			}
+			return s.merge(tx, &existing, alert, entryIDs)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get security alert: %w", err)
+		}
+
+		if alert.Details == "" && len(entryIDs) > 0 {
+			evidence, _ := json.Marshal(map[string]interface{}{"entries": entryIDs})
+			alert.Details = string(evidence)
+		}
+		alert.Status = models.AlertStatusOpen
+		if err := tx.Create(alert).Error; err != nil {
+			return fmt.Errorf("failed to create security alert: %w", err)
+		}
+		created = true
+		return nil
+	})
+	if err != nil || !created {
+		return false, err
+	}
+
+	s.auditService.LogAnonymousAction(alert.OrganizationID, nil, "security_alert", "security_alert", strconv.Itoa(int(alert.ID)), alert.IPAddress, "", map[string]interface{}{
+		"rule":     alert.Rule,
+		"severity": alert.Severity,
+		"summary":  alert.Summary,
+		"user_id":  alert.UserID,
+	})
+	if s.notifications != nil {
+		s.notifications.Notify(alerting.EventTrigger, alert)
+	}
+	return true, nil
+}
+
+// merge updates an unresolved alert with continuing activity. Counts over a
+// window replace the alert's count when higher; listed entries add to it.
+func (s *SecurityDetectionService) merge(tx *gorm.DB, existing, alert *models.SecurityAlert, entryIDs []uint) error {
+	updates := map[string]interface{}{}
+	if alert.LastSeenAt.After(existing.LastSeenAt) {
+		updates["last_seen_at"] = alert.LastSeenAt
+	}
+	if alert.IPAddress != "" {
+		updates["ip_address"] = alert.IPAddress
+	}
+	if severityRank(alert.Severity) > severityRank(existing.Severity) {
+		updates["severity"] = alert.Severity
+	}
+
+	if len(entryIDs) > 0 {
+		var evidence struct {
+			Entries []uint `json:"entries"`
+		}
+		json.Unmarshal([]byte(existing.Details), &evidence)
+		for _, id := range entryIDs {
+			if !slices.Contains(evidence.Entries, id) {
+				evidence.Entries = append(evidence.Entries, id)
+			}
+		}
+		encoded, _ := json.Marshal(evidence)
+		updates["details"] = string(encoded)
+		updates["event_count"] = len(evidence.Entries)
+	} else if alert.EventCount > existing.EventCount {
+		updates["event_count"] = alert.EventCount
+		updates["summary"] = alert.Summary
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := tx.Model(existing).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update security alert: %w", err)
+	}
+	return nil
+}
+
+// severityRank orders severities from low to critical
+func severityRank(severity models.AlertSeverity) int {
+	return slices.Index([]models.AlertSeverity{
+		models.AlertSeverityLow,
+		models.AlertSeverityMedium,
+		models.AlertSeverityHigh,
+		models.AlertSeverityCritical,
+	}, severity)
+}
+
+// SecurityAlertFilter narrows the alerts listed
+type SecurityAlertFilter struct {
+	Rule     string
+	Severity models.AlertSeverity
+	Status   models.AlertStatus
+	UserID   *uint
+}
+
+// apply adds the filter conditions to a security alerts query
+func (f *SecurityAlertFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.Rule != "" {
+		db = db.Where("rule = ?", f.Rule)
+	}
+	if f.Severity != "" {
+		db = db.Where("severity = ?", f.Severity)
+	}
+	if f.Status != "" {
+		db = db.Where("status = ?", f.Status)
+	}
+	if f.UserID != nil {
+		db = db.Where("user_id = ?", *f.UserID)
+	}
+	return db
+}
+
+// GetAlerts retrieves a page of the organization's security alerts matching
+// the filter, most recently seen first
+func (s *SecurityDetectionService) GetAlerts(organizationID uint, filter *SecurityAlertFilter, page, limit int) ([]models.SecurityAlert, int64, error) {
+	var alerts []models.SecurityAlert
+	var total int64
+
+	query := s.db.Model(&models.SecurityAlert{}).
+		Where("organization_id = ?", organizationID).
+		Scopes(filter.apply).
+		Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count security alerts: %w", err)
+	}
+
+	if err := query.Order("last_seen_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&alerts).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get security alerts: %w", err)
+	}
+
+	return alerts, total, nil
+}
+
+// GetAlert retrieves an alert of the organization
+func (s *SecurityDetectionService) GetAlert(organizationID, id uint) (*models.SecurityAlert, error) {
+	var alert models.SecurityAlert
+	if err := s.db.Where("id = ? AND organization_id = ?", id, organizationID).First(&alert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSecurityAlertNotFound
+		}
+		return nil, fmt.Errorf("failed to get security alert: %w", err)
+	}
+	return &alert, nil
+}
+
+// Review moves an alert of the organization to acknowledged or resolved,
+// notifying the channels
+func (s *SecurityDetectionService) Review(organizationID, id uint, status models.AlertStatus, userID uint) (*models.SecurityAlert, error) {
+	alert, err := s.GetAlert(organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.db.Model(alert).Updates(map[string]interface{}{
+		"status":      status,
+		"reviewed_by": userID,
+		"reviewed_at": now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update security alert: %w", err)
+	}
+
+	if s.notifications != nil {
+		event := alerting.EventAcknowledge
+		if status == models.AlertStatusResolved {
+			event = alerting.EventResolve
+		}
+		s.notifications.Notify(event, alert)
+	}
+	return alert, nil
+}
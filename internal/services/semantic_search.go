@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/search"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SemanticSearchService indexes document embeddings in pgvector and serves
+// similarity-ranked search
+type SemanticSearchService struct {
+	db         *gorm.DB
+	embeddings *search.EmbeddingClient
+	dimensions int
+}
+
+// NewSemanticSearchService creates a new semantic search service
+func NewSemanticSearchService(embeddings *search.EmbeddingClient, dimensions int) *SemanticSearchService {
+	return &SemanticSearchService{
+		db:         database.GetDB(),
+		embeddings: embeddings,
+		dimensions: dimensions,
+	}
+}
+
+// EnsureSchema creates the pgvector extension, embeddings table and index
+func (s *SemanticSearchService) EnsureSchema() error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS document_embeddings (
+			document_id bigint PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+			model varchar(100) NOT NULL,
+			embedding vector(%d) NOT NULL,
+			updated_at timestamptz NOT NULL DEFAULT now()
+		)`, s.dimensions),
+		`CREATE INDEX IF NOT EXISTS idx_document_embeddings_embedding
+			ON document_embeddings USING hnsw (embedding vector_cosine_ops)`,
+	}
+
+	for _, statement := range statements {
+		if err := s.db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to prepare embeddings schema: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// IndexDocument computes and stores the embedding of a document. Metadata-only
+// updates re-embed the document only if it has no embedding yet.
+func (s *SemanticSearchService) IndexDocument(ctx context.Context, doc *models.Document, content string) error {
+	if content == "" {
+		var count int64
+		if err := s.db.Table("document_embeddings").Where("document_id = ?", doc.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	text := strings.Join([]string{doc.Title, doc.Description, doc.Tags, content}, "\n")
+
+	vector, err := s.embeddings.Embed(ctx, text)
+	if err != nil {
+		return err
+	}
+	if len(vector) != s.dimensions {
+		return fmt.Errorf("embedding has %d dimensions, expected %d", len(vector), s.dimensions)
+	}
+
+	return s.db.WithContext(ctx).Exec(`
+		INSERT INTO document_embeddings (document_id, model, embedding, updated_at)
+		VALUES (?, ?, ?::vector, now())
+		ON CONFLICT (document_id) DO UPDATE
+		SET model = EXCLUDED.model, embedding = EXCLUDED.embedding, updated_at = EXCLUDED.updated_at`,
+		doc.ID, s.embeddings.Model(), search.VectorLiteral(vector),
+	).Error
+}
+
+// DeleteDocument removes the embedding of a document
+func (s *SemanticSearchService) DeleteDocument(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Exec("DELETE FROM document_embeddings WHERE document_id = ?", id).Error
+}
+
+// Search returns the documents most similar to the query, limited to
+// documents the user may read. Rank is the cosine similarity.
+func (s *SemanticSearchService) Search(ctx context.Context, user *models.User, query string, limit int) ([]DocumentSearchResult, error) {
+	vector, err := s.embeddings.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	literal := search.VectorLiteral(vector)
+
+	var results []DocumentSearchResult
+	if err := s.db.WithContext(ctx).
+		Model(&models.Document{}).
+		Scopes(AccessibleDocuments(user)).
+		Joins("JOIN document_embeddings ON document_embeddings.document_id = documents.id").
+		Select("documents.*, 1 - (document_embeddings.embedding <=> ?::vector) AS rank", literal).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "document_embeddings.embedding <=> ?::vector",
+			Vars: []interface{}{literal},
+		}}).
+		Limit(limit).
+		Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SessionLimitAction is what happens to a login past the session limit
+type SessionLimitAction string
+
+const (
+	SessionLimitReject       SessionLimitAction = "reject"        // The new login is refused
+	SessionLimitRevokeOldest SessionLimitAction = "revoke_oldest" // The oldest sessions are signed out
+)
+
+var (
+	// ErrSessionLimitReached is returned when a user already has the
+	// maximum number of active sessions and new logins are rejected
+	ErrSessionLimitReached = errors.New("maximum number of active sessions reached")
+	// ErrSessionNotFound is returned when a user has no such active session
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// SessionService keeps the sessions, i.e. the refresh tokens, of users
+// within the configured limit
+type SessionService struct {
+	db          *gorm.DB
+	maxSessions int // 0 is unlimited
+	limitAction SessionLimitAction
+}
+
+// NewSessionService creates a new session service. maxSessions is the
+// number of active refresh tokens a user may hold, 0 for no limit.
+func NewSessionService(maxSessions int, limitAction SessionLimitAction) (*SessionService, error) {
+	if maxSessions < 0 {
+		return nil, fmt.Errorf("maximum sessions must not be negative")
+	}
+	if limitAction != SessionLimitReject && limitAction != SessionLimitRevokeOldest {
+		return nil, fmt.Errorf("unknown session limit action: %s", limitAction)
+	}
+	return &SessionService{
+		db:          database.GetDB(),
+		maxSessions: maxSessions,
+		limitAction: limitAction,
+	}, nil
+}
+
+// Start saves the refresh token of a new session signed in from ipAddress
+// and userAgent. When the user is at the session limit it returns
+// ErrSessionLimitReached or revokes the oldest sessions to make room,
+// depending on the limit action. It returns the number of sessions revoked.
+func (s *SessionService) Start(userID uint, token string, expiresAt time.Time, ipAddress, userAgent string) (int, error) {
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+
+	revoked := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if s.maxSessions > 0 {
+			// Concurrent logins of the same user take turns
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error; err != nil {
+				return err
+			}
+
+			var active []uint
+			if err := tx.Model(&models.RefreshToken{}).
+				Where("user_id = ? AND is_revoked = ? AND expires_at > ?", userID, false, time.Now()).
+				Order("created_at, id").
+				Pluck("id", &active).Error; err != nil {
+				return err
+			}
+
+			if excess := len(active) - s.maxSessions + 1; excess > 0 {
+				if s.limitAction == SessionLimitReject {
+					return ErrSessionLimitReached
+				}
+				if err := tx.Model(&models.RefreshToken{}).
+					Where("id IN ?", active[:excess]).
+					Update("is_revoked", true).Error; err != nil {
+					return err
+				}
+				revoked = excess
+			}
+		}
+
+		return tx.Create(&models.RefreshToken{
+			UserID:    userID,
+			Token:     token,
+			ExpiresAt: expiresAt,
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+		}).Error
+	})
+	if errors.Is(err, ErrSessionLimitReached) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to start session: %w", err)
+	}
+	return revoked, nil
+}
+
+// Touch records that a session's refresh token was just used
+func (s *SessionService) Touch(token string) error {
+	if err := s.db.Model(&models.RefreshToken{}).Where("token = ?", token).Update("last_used_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+// GetSessions lists a user's active sessions, newest first
+func (s *SessionService) GetSessions(userID uint) ([]models.RefreshToken, error) {
+	var sessions []models.RefreshToken
+	if err := s.db.Where("user_id = ? AND is_revoked = ? AND expires_at > ?", userID, false, time.Now()).
+		Order("created_at DESC, id DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// Revoke signs out one of a user's active sessions
+func (s *SessionService) Revoke(userID, id uint) (*models.RefreshToken, error) {
+	var session models.RefreshToken
+	if err := s.db.Where("user_id = ? AND is_revoked = ? AND expires_at > ?", userID, false, time.Now()).
+		First(&session, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if err := s.db.Model(&session).Update("is_revoked", true).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return &session, nil
+}
+
+// tokenRevocationTime returns the time tokens are revoked at, in whole
+// seconds like the iat of the tokens it is compared with
+func tokenRevocationTime() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// RevokeAll signs a user out everywhere: every refresh token is revoked and
+// every access token issued so far is refused. It returns the number of
+// sessions revoked.
+func (s *SessionService) RevokeAll(userID uint) (int64, error) {
+	var revoked int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("tokens_revoked_at", tokenRevocationTime()).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND is_revoked = ?", userID, false).
+			Update("is_revoked", true)
+		revoked = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return revoked, nil
+}
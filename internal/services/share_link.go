@@ -0,0 +1,182 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"gorm.io/gorm"
+)
+
+// minShareLinkPasswordLength is the shortest password a share link accepts
+const minShareLinkPasswordLength = 8
+
+var (
+	// ErrShareLinkNotFound is returned for unknown, revoked or expired share
+	// links and for links to deleted documents
+	ErrShareLinkNotFound = errors.New("share link not found")
+	// ErrShareLinkPasswordRequired is returned when opening a protected share
+	// link without a password
+	ErrShareLinkPasswordRequired = errors.New("share link password required")
+	// ErrInvalidShareLinkPassword is returned for a wrong share link password
+	ErrInvalidShareLinkPassword = errors.New("invalid share link password")
+	// ErrShareLinkPasswordTooShort is returned when protecting a link with a
+	// password shorter than minShareLinkPasswordLength
+	ErrShareLinkPasswordTooShort = fmt.Errorf("share link passwords must be at least %d characters", minShareLinkPasswordLength)
+)
+
+// ShareLinkService issues and opens links downloading a document without an
+// account, for sharing files with people outside the organization
+type ShareLinkService struct {
+	db              *gorm.DB
+	passwordService *crypto.PasswordService
+	maxTTL          time.Duration
+}
+
+// NewShareLinkService creates a new share link service. Links expire after
+// maxTTL at the latest.
+func NewShareLinkService(passwordService *crypto.PasswordService, maxTTL time.Duration) *ShareLinkService {
+	return &ShareLinkService{
+		db:              database.GetDB(),
+		passwordService: passwordService,
+		maxTTL:          maxTTL,
+	}
+}
+
+// MaxTTL returns how long share links stay valid at most
+func (s *ShareLinkService) MaxTTL() time.Duration {
+	return s.maxTTL
+}
+
+// Create issues a share link to a document, valid for ttl capped at the
+// maximum. A non-empty password, stored hashed, must then be given to
+// download. The token is only ever returned here; just its hash is stored.
+func (s *ShareLinkService) Create(documentID, createdBy uint, password string, ttl time.Duration) (*models.ShareLink, string, error) {
+	if ttl <= 0 || ttl > s.maxTTL {
+		ttl = s.maxTTL
+	}
+
+	var passwordHash string
+	if password != "" {
+		if len(password) < minShareLinkPasswordLength {
+			return nil, "", ErrShareLinkPasswordTooShort
+		}
+		hash, err := s.passwordService.HashPassword(password)
+		if err != nil {
+			return nil, "", err
+		}
+		passwordHash = hash
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate share link: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	link := &models.ShareLink{
+		DocumentID:   documentID,
+		CreatedBy:    createdBy,
+		Prefix:       token[:8],
+		TokenHash:    hashSecret(token),
+		PasswordHash: passwordHash,
+		ExpiresAt:    time.Now().Add(ttl),
+	}
+	if err := s.db.Create(link).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to save share link: %w", err)
+	}
+	return link, token, nil
+}
+
+// GetForDocument lists the share links of a document that haven't been
+// revoked or expired, newest first
+func (s *ShareLinkService) GetForDocument(documentID uint) ([]models.ShareLink, error) {
+	var links []models.ShareLink
+	if err := s.db.Where("document_id = ? AND revoked_at IS NULL AND expires_at > ?", documentID, time.Now()).
+		Order("created_at DESC, id DESC").
+		Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to get share links: %w", err)
+	}
+	return links, nil
+}
+
+// Revoke stops a share link of a document from working
+func (s *ShareLinkService) Revoke(documentID, id uint) (*models.ShareLink, error) {
+	var link models.ShareLink
+	if err := s.db.Where("document_id = ? AND revoked_at IS NULL", documentID).First(&link, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrShareLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+	if err := s.db.Model(&link).Update("revoked_at", time.Now()).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	return &link, nil
+}
+
+// Resolve looks up a valid share link by its token along with its document,
+// without checking the password. It returns ErrShareLinkNotFound for
+// unknown, revoked or expired links and links to deleted documents.
+func (s *ShareLinkService) Resolve(token string) (*models.ShareLink, *models.Document, error) {
+	var link models.ShareLink
+	if err := s.db.Where("token_hash = ?", hashSecret(token)).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrShareLinkNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+	if link.RevokedAt != nil || !link.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrShareLinkNotFound
+	}
+
+	// A valid link grants access to its document by itself, without a user
+	var doc models.Document
+	if err := database.AsSystem(s.db).First(&doc, link.DocumentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrShareLinkNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get shared document: %w", err)
+	}
+	return &link, &doc, nil
+}
+
+// Creator returns the user who created a share link
+func (s *ShareLinkService) Creator(link *models.ShareLink) (*models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, link.CreatedBy).Error; err != nil {
+		return nil, fmt.Errorf("failed to get share link creator: %w", err)
+	}
+	return &user, nil
+}
+
+// CheckPassword verifies the password given to open a share link. Links
+// without a password accept any.
+func (s *ShareLinkService) CheckPassword(link *models.ShareLink, password string) error {
+	if link.PasswordHash == "" {
+		return nil
+	}
+	if password == "" {
+		return ErrShareLinkPasswordRequired
+	}
+	if err := s.passwordService.VerifyPassword(password, link.PasswordHash); err != nil {
+		return ErrInvalidShareLinkPassword
+	}
+	return nil
+}
+
+// RecordDownload counts a download through a share link
+func (s *ShareLinkService) RecordDownload(link *models.ShareLink) error {
+	if err := s.db.Model(link).Updates(map[string]interface{}{
+		"download_count":     gorm.Expr("download_count + 1"),
+		"last_downloaded_at": time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to record share link download: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/signature"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSignatureNotFound is returned for unknown document signatures
+	ErrSignatureNotFound = errors.New("signature not found")
+	// ErrSignatureExists is returned when the certificate already signed the
+	// document version
+	ErrSignatureExists = errors.New("document version is already signed with this certificate")
+	// ErrVersionNotFound is returned when signing a version a document doesn't have
+	ErrVersionNotFound = errors.New("document version not found")
+	// ErrServerSigningDisabled is returned for server signatures when no
+	// signing certificate is configured
+	ErrServerSigningDisabled = errors.New("server signing is not configured")
+)
+
+// SignatureVerification is the result of checking a document signature
+// against the stored file
+type SignatureVerification struct {
+	SignatureID uint      `json:"signature_id"`
+	Valid       bool      `json:"valid"`   // Signature matches the stored file and, with CA certificates, the chain checks out
+	Trusted     bool      `json:"trusted"` // Certificate chain was checked against the CA certificates
+	Subject     string    `json:"subject"`
+	CheckedAt   time.Time `json:"checked_at"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// SignatureService signs document versions with X.509 certificates and
+// checks the signatures. Users sign with their own certificate and key or
+// with the certificate the internal CA issued them; the server signs with
+// its configured certificate.
+type SignatureService struct {
+	db                 *gorm.DB
+	documentService    *DocumentService
+	certificateService *CertificateService
+	signer             *signature.Signer
+	roots              *x509.CertPool
+}
+
+// NewSignatureService creates a new signature service. signer may be nil
+// when server signing is disabled, and certificateService when the internal
+// CA is. Certificates are checked against the CA certificates in roots; with
+// nil only signatures are.
+func NewSignatureService(documentService *DocumentService, certificateService *CertificateService, signer *signature.Signer, roots *x509.CertPool) *SignatureService {
+	return &SignatureService{
+		db:                 database.GetDB(),
+		documentService:    documentService,
+		certificateService: certificateService,
+		signer:             signer,
+		roots:              roots,
+	}
+}
+
+// SignVersion stores a user's signature over the file of a document version
+// after checking it, and the certificate chain when CA certificates are
+// configured
+func (s *SignatureService) SignVersion(doc *models.Document, versionNumber int, certificates []*x509.Certificate, sig []byte, userID uint) (*models.DocumentSignature, error) {
+	fileHash, content, err := s.versionFile(doc, versionNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := signature.Verify(content, sig, certificates, s.roots, now); err != nil {
+		return nil, err
+	}
+	if err := s.checkRevocation(certificates[0], now); err != nil {
+		return nil, err
+	}
+
+	return s.save(doc, versionNumber, fileHash, certificates, sig, false, userID)
+}
+
+// IssuedSignVersion signs the file of a document version with the
+// certificate the internal CA issued the user
+func (s *SignatureService) IssuedSignVersion(doc *models.Document, versionNumber int, userID uint) (*models.DocumentSignature, error) {
+	if s.certificateService == nil {
+		return nil, ErrNoSigningCertificate
+	}
+
+	signer, err := s.certificateService.Signer(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileHash, content, err := s.versionFile(doc, versionNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, _, err := signer.Sign(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(doc, versionNumber, fileHash, signer.Certificates(), sig, false, userID)
+}
+
+// checkRevocation fails for certificates of the internal CA revoked by the
+// time, or ever for a compromised key
+func (s *SignatureService) checkRevocation(certificate *x509.Certificate, at time.Time) error {
+	if s.certificateService == nil {
+		return nil
+	}
+
+	issued, err := s.certificateService.GetByFingerprint(signature.Fingerprint(certificate))
+	if err != nil {
+		return err
+	}
+	if issued == nil || issued.RevokedAt == nil {
+		return nil
+	}
+	if issued.RevocationReason == models.RevocationKeyCompromise || !issued.RevokedAt.After(at) {
+		return fmt.Errorf("%w: certificate was revoked (%s)", signature.ErrUntrustedCertificate, issued.RevocationReason)
+	}
+	return nil
+}
+
+// ServerSignVersion signs the file of a document version with the server's
+// certificate on behalf of the user
+func (s *SignatureService) ServerSignVersion(doc *models.Document, versionNumber int, userID uint) (*models.DocumentSignature, error) {
+	if s.signer == nil {
+		return nil, ErrServerSigningDisabled
+	}
+
+	fileHash, content, err := s.versionFile(doc, versionNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, _, err := s.signer.Sign(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(doc, versionNumber, fileHash, s.signer.Certificates(), sig, true, userID)
+}
+
+// versionFile returns the file hash and content of a document version
+func (s *SignatureService) versionFile(doc *models.Document, versionNumber int) (string, []byte, error) {
+	version, err := s.documentService.GetVersion(doc.ID, versionNumber)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, err
+		}
+		// Documents never versioned have no row for their first version
+		if versionNumber != doc.Version {
+			return "", nil, ErrVersionNotFound
+		}
+		content, err := s.documentService.ReadContent(doc)
+		return doc.FileHash, content, err
+	}
+
+	content, err := s.documentService.ReadVersionContent(version)
+	return version.FileHash, content, err
+}
+
+// save stores a checked signature
+func (s *SignatureService) save(doc *models.Document, versionNumber int, fileHash string, certificates []*x509.Certificate, sig []byte, serverSigned bool, userID uint) (*models.DocumentSignature, error) {
+	leaf := certificates[0]
+	algorithm, err := signature.Algorithm(leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.DocumentSignature{
+		DocumentID:   doc.ID,
+		Version:      versionNumber,
+		FileHash:     fileHash,
+		Algorithm:    algorithm.String(),
+		Signature:    sig,
+		Certificates: signature.EncodeCertificates(certificates),
+		Subject:      leaf.Subject.String(),
+		Issuer:       leaf.Issuer.String(),
+		SerialNumber: leaf.SerialNumber.Text(16),
+		Fingerprint:  signature.Fingerprint(leaf),
+		ServerSigned: serverSigned,
+		SignedBy:     userID,
+	}
+
+	var count int64
+	if err := s.db.Model(&models.DocumentSignature{}).
+		Where("document_id = ? AND version = ? AND fingerprint = ?", doc.ID, versionNumber, record.Fingerprint).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check signatures: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrSignatureExists
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save signature: %w", err)
+	}
+	return record, nil
+}
+
+// GetForDocument lists the signatures of a document's versions, newest first
+func (s *SignatureService) GetForDocument(documentID uint) ([]models.DocumentSignature, error) {
+	signatures := make([]models.DocumentSignature, 0)
+	if err := s.db.Where("document_id = ?", documentID).
+		Order("created_at DESC, id DESC").
+		Find(&signatures).Error; err != nil {
+		return nil, fmt.Errorf("failed to get signatures: %w", err)
+	}
+	return signatures, nil
+}
+
+// Verify checks a document signature against the version's stored file, and
+// the certificate chain as of the time of signing when CA certificates are
+// configured
+func (s *SignatureService) Verify(doc *models.Document, id uint) (*SignatureVerification, error) {
+	var record models.DocumentSignature
+	if err := s.db.Where("document_id = ?", doc.ID).First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSignatureNotFound
+		}
+		return nil, fmt.Errorf("failed to get signature: %w", err)
+	}
+
+	verification := &SignatureVerification{
+		SignatureID: record.ID,
+		Trusted:     s.roots != nil,
+		Subject:     record.Subject,
+		CheckedAt:   time.Now(),
+	}
+
+	fileHash, content, err := s.versionFile(doc, record.Version)
+	if err != nil {
+		verification.Error = "the version's file can't be read"
+		return verification, nil
+	}
+	if fileHash != record.FileHash {
+		verification.Error = "the version's file hash differs from the signed one"
+		return verification, nil
+	}
+
+	certificates, err := signature.ParseCertificates([]byte(record.Certificates))
+	if err == nil {
+		err = signature.Verify(content, record.Signature, certificates, s.roots, record.CreatedAt)
+	}
+	if err == nil {
+		err = s.checkRevocation(certificates[0], record.CreatedAt)
+	}
+	if err != nil {
+		verification.Error = err.Error()
+		return verification, nil
+	}
+
+	verification.Valid = true
+	return verification, nil
+}
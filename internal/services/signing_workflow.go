@@ -0,0 +1,650 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/mailer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSigningWorkflowNotFound is returned for unknown signing workflows
+	ErrSigningWorkflowNotFound = errors.New("signing workflow not found")
+	// ErrSigningWorkflowInProgress is returned when starting a workflow on a
+	// document that already has one in progress
+	ErrSigningWorkflowInProgress = errors.New("document already has a signing workflow in progress")
+	// ErrSigningWorkflowClosed is returned when acting on a workflow that is
+	// no longer in progress
+	ErrSigningWorkflowClosed = errors.New("signing workflow is no longer in progress")
+	// ErrInvalidSigners is returned for workflows without signers, with a
+	// signer twice or with a signer who can't read the document
+	ErrInvalidSigners = errors.New("invalid signers")
+	// ErrNotPendingSigner is returned when someone other than the signer
+	// whose turn it is signs or declines
+	ErrNotPendingSigner = errors.New("it isn't the user's turn to sign")
+	// ErrDocumentChanged is returned when signing a document that changed
+	// since the workflow started
+	ErrDocumentChanged = errors.New("document changed since the signing workflow started")
+	// ErrWrongSigningMethod is returned when signing with another method than
+	// the one the signer was asked for
+	ErrWrongSigningMethod = errors.New("signer must sign with another method")
+	// ErrSignerIdentityMismatch is returned when the name or certificate a
+	// signer signs with isn't theirs
+	ErrSignerIdentityMismatch = errors.New("signature doesn't identify the signer")
+)
+
+// SignerRequest is a signer to add to a signing workflow
+type SignerRequest struct {
+	UserID uint
+	Method models.SigningMethod
+}
+
+// ClickSignature is a signer's confirmation of a document with their name.
+// AuthenticatedAt is when the signer last entered a password or used a
+// security key.
+type ClickSignature struct {
+	SignedName      string
+	AuthenticatedAt time.Time
+	IPAddress       string
+	UserAgent       string
+}
+
+// SigningWorkflowService collects the signatures of several users on a
+// document version, one after the other. Signers sign by confirming with
+// their name shortly after authenticating, or with their X.509 certificate.
+// Once everyone has signed the document is locked. Every step is recorded
+// on the blockchain when it is enabled.
+type SigningWorkflowService struct {
+	db                  *gorm.DB
+	documentService     *DocumentService
+	signatureService    *SignatureService
+	authService         *AuthorizationService
+	notificationService *NotificationService
+	blockchainService   *BlockchainService
+	mailer              mailer.Mailer
+}
+
+// NewSigningWorkflowService creates a new signing workflow service.
+// blockchainService may be nil when the blockchain is disabled, and mailer
+// when signers are only notified in the application.
+func NewSigningWorkflowService(documentService *DocumentService, signatureService *SignatureService, authService *AuthorizationService, notificationService *NotificationService, blockchainService *BlockchainService, mailer mailer.Mailer) *SigningWorkflowService {
+	return &SigningWorkflowService{
+		db:                  database.GetDB(),
+		documentService:     documentService,
+		signatureService:    signatureService,
+		authService:         authService,
+		notificationService: notificationService,
+		blockchainService:   blockchainService,
+		mailer:              mailer,
+	}
+}
+
+// Create starts a signing workflow on the document's current version. The
+// signers sign in the given order; the first one is notified right away.
+func (s *SigningWorkflowService) Create(ctx context.Context, doc *models.Document, creator *models.User, signers []SignerRequest, message string) (*models.SigningWorkflow, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("%w: at least one signer is required", ErrInvalidSigners)
+	}
+
+	users := make([]models.User, 0, len(signers))
+	seen := make(map[uint]bool)
+	for _, signer := range signers {
+		if signer.Method != models.SigningMethodClick && signer.Method != models.SigningMethodCertificate {
+			return nil, fmt.Errorf("%w: unknown signing method %q", ErrInvalidSigners, signer.Method)
+		}
+		if seen[signer.UserID] {
+			return nil, fmt.Errorf("%w: user %d is listed twice", ErrInvalidSigners, signer.UserID)
+		}
+		seen[signer.UserID] = true
+
+		var user models.User
+		if err := s.db.WithContext(ctx).Where("organization_id = ? AND is_active = ?", doc.OrganizationID, true).First(&user, signer.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: user %d not found", ErrInvalidSigners, signer.UserID)
+			}
+			return nil, fmt.Errorf("failed to get signer: %w", err)
+		}
+		allowed, err := s.authService.CanRead(&user, doc)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: user %d can't read the document", ErrInvalidSigners, signer.UserID)
+		}
+		users = append(users, user)
+	}
+
+	now := time.Now()
+	workflow := &models.SigningWorkflow{
+		DocumentID: doc.ID,
+		Version:    doc.Version,
+		FileHash:   doc.FileHash,
+		Message:    message,
+		Status:     models.SigningWorkflowInProgress,
+		CreatedBy:  creator.ID,
+	}
+	for i, signer := range signers {
+		status := models.SignerWaiting
+		var notifiedAt *time.Time
+		if i == 0 {
+			status = models.SignerPending
+			notifiedAt = &now
+		}
+		workflow.Signers = append(workflow.Signers, models.SigningWorkflowSigner{
+			UserID:     signer.UserID,
+			Position:   i + 1,
+			Method:     signer.Method,
+			Status:     status,
+			NotifiedAt: notifiedAt,
+		})
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serialize workflows on the document
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Document{}, doc.ID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.SigningWorkflow{}).
+			Where("document_id = ? AND status = ?", doc.ID, models.SigningWorkflowInProgress).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrSigningWorkflowInProgress
+		}
+
+		if err := tx.Create(workflow).Error; err != nil {
+			return err
+		}
+		_, err := s.recordOnChain(tx, workflow, creator.ID, "signing_workflow_start", map[string]interface{}{
+			"workflow_id": workflow.ID,
+			"signers":     signerIDs(workflow.Signers),
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrSigningWorkflowInProgress) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create signing workflow: %w", err)
+	}
+
+	for i := range workflow.Signers {
+		workflow.Signers[i].User = users[i]
+	}
+
+	s.notifySigner(doc, workflow, &workflow.Signers[0], creator)
+
+	return workflow, nil
+}
+
+// GetForDocument lists a document's signing workflows with their signers,
+// newest first
+func (s *SigningWorkflowService) GetForDocument(documentID uint) ([]models.SigningWorkflow, error) {
+	workflows := make([]models.SigningWorkflow, 0)
+	if err := s.withSigners(s.db).
+		Where("document_id = ?", documentID).
+		Order("created_at DESC, id DESC").
+		Find(&workflows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get signing workflows: %w", err)
+	}
+	return workflows, nil
+}
+
+// Get returns a signing workflow of a document with its signers
+func (s *SigningWorkflowService) Get(documentID, id uint) (*models.SigningWorkflow, error) {
+	var workflow models.SigningWorkflow
+	if err := s.withSigners(s.db).Where("document_id = ?", documentID).First(&workflow, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSigningWorkflowNotFound
+		}
+		return nil, fmt.Errorf("failed to get signing workflow: %w", err)
+	}
+	return &workflow, nil
+}
+
+// GetPendingForUser lists the workflows waiting for the user's signature,
+// oldest first
+func (s *SigningWorkflowService) GetPendingForUser(userID uint) ([]models.SigningWorkflow, error) {
+	workflows := make([]models.SigningWorkflow, 0)
+	if err := s.withSigners(s.db).
+		Where("status = ? AND id IN (?)", models.SigningWorkflowInProgress,
+			s.db.Model(&models.SigningWorkflowSigner{}).Select("workflow_id").Where("user_id = ? AND status = ?", userID, models.SignerPending)).
+		Order("created_at ASC, id ASC").
+		Find(&workflows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get signing requests: %w", err)
+	}
+	return workflows, nil
+}
+
+// withSigners preloads the signers of workflows, in signing order
+func (s *SigningWorkflowService) withSigners(db *gorm.DB) *gorm.DB {
+	return db.Preload("Signers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Preload("Signers.User")
+}
+
+// SignClick signs for the user by confirming with their name, which must be
+// their full name or username
+func (s *SigningWorkflowService) SignClick(ctx context.Context, doc *models.Document, workflowID uint, user *models.User, sig ClickSignature) (*models.SigningWorkflow, error) {
+	if !namesMatch(sig.SignedName, user) {
+		return nil, fmt.Errorf("%w: the signed name isn't the user's name", ErrSignerIdentityMismatch)
+	}
+
+	return s.sign(ctx, doc, workflowID, user, models.SigningMethodClick, func(workflow *models.SigningWorkflow, signer *models.SigningWorkflowSigner, now time.Time) error {
+		authenticatedAt := sig.AuthenticatedAt
+		signer.SignedName = strings.TrimSpace(sig.SignedName)
+		signer.AuthenticatedAt = &authenticatedAt
+		signer.IPAddress = sig.IPAddress
+		signer.UserAgent = truncate(sig.UserAgent, 500)
+		signer.Evidence = clickEvidence(workflow, signer, user, now)
+		return nil
+	})
+}
+
+// SignCertificate signs for the user with their X.509 certificate, whose
+// email address must be the user's. The signature is stored with the
+// document version's other signatures.
+func (s *SigningWorkflowService) SignCertificate(ctx context.Context, doc *models.Document, workflowID uint, user *models.User, certificates []*x509.Certificate, sig []byte, ipAddress, userAgent string) (*models.SigningWorkflow, error) {
+	if !certificateIdentifies(certificates[0], user) {
+		return nil, fmt.Errorf("%w: the certificate isn't issued to the user's email address", ErrSignerIdentityMismatch)
+	}
+
+	return s.sign(ctx, doc, workflowID, user, models.SigningMethodCertificate, func(workflow *models.SigningWorkflow, signer *models.SigningWorkflowSigner, now time.Time) error {
+		record, err := s.signatureService.SignVersion(doc, workflow.Version, certificates, sig, user.ID)
+		if err != nil {
+			return err
+		}
+		return linkSignature(workflow, signer, record, ipAddress, userAgent)
+	})
+}
+
+// SignIssued signs for the user with the certificate the internal CA issued
+// them
+func (s *SigningWorkflowService) SignIssued(ctx context.Context, doc *models.Document, workflowID uint, user *models.User, ipAddress, userAgent string) (*models.SigningWorkflow, error) {
+	return s.sign(ctx, doc, workflowID, user, models.SigningMethodCertificate, func(workflow *models.SigningWorkflow, signer *models.SigningWorkflowSigner, now time.Time) error {
+		record, err := s.signatureService.IssuedSignVersion(doc, workflow.Version, user.ID)
+		if err != nil {
+			return err
+		}
+		return linkSignature(workflow, signer, record, ipAddress, userAgent)
+	})
+}
+
+// linkSignature links a certificate signature to its signer
+func linkSignature(workflow *models.SigningWorkflow, signer *models.SigningWorkflowSigner, record *models.DocumentSignature, ipAddress, userAgent string) error {
+	if record.FileHash != workflow.FileHash {
+		return ErrDocumentChanged
+	}
+
+	signer.SignatureID = &record.ID
+	signer.IPAddress = ipAddress
+	signer.UserAgent = truncate(userAgent, 500)
+	signer.Evidence = record.Fingerprint
+	return nil
+}
+
+// sign records the signature of the pending signer, collected by collect,
+// and passes the turn to the next signer or completes the workflow and locks
+// the document after the last one
+func (s *SigningWorkflowService) sign(ctx context.Context, doc *models.Document, workflowID uint, user *models.User, method models.SigningMethod, collect func(*models.SigningWorkflow, *models.SigningWorkflowSigner, time.Time) error) (*models.SigningWorkflow, error) {
+	var workflow models.SigningWorkflow
+	var signer, next *models.SigningWorkflowSigner
+	now := time.Now()
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		signer, err = s.lockPendingSigner(tx, doc, workflowID, user, &workflow)
+		if err != nil {
+			return err
+		}
+		if signer.Method != method {
+			return ErrWrongSigningMethod
+		}
+
+		var current models.Document
+		if err := tx.Select("id", "version", "file_hash").First(&current, doc.ID).Error; err != nil {
+			return err
+		}
+		if current.Version != workflow.Version || current.FileHash != workflow.FileHash {
+			return ErrDocumentChanged
+		}
+
+		if err := collect(&workflow, signer, now); err != nil {
+			return err
+		}
+		signer.Status = models.SignerSigned
+		signer.SignedAt = &now
+
+		data := map[string]interface{}{
+			"workflow_id": workflow.ID,
+			"signer_id":   signer.ID,
+			"method":      signer.Method,
+			"evidence":    signer.Evidence,
+		}
+		if signer.SignatureID != nil {
+			data["signature_id"] = *signer.SignatureID
+		}
+		if signer.TransactionID, err = s.recordOnChain(tx, &workflow, user.ID, "document_sign", data); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(signer).Error; err != nil {
+			return err
+		}
+
+		for i := range workflow.Signers {
+			if workflow.Signers[i].Status == models.SignerWaiting {
+				next = &workflow.Signers[i]
+				break
+			}
+		}
+		if next != nil {
+			next.Status = models.SignerPending
+			next.NotifiedAt = &now
+			return tx.Model(next).Updates(map[string]interface{}{
+				"status":      next.Status,
+				"notified_at": now,
+			}).Error
+		}
+
+		workflow.Status = models.SigningWorkflowCompleted
+		workflow.CompletedAt = &now
+		if err := tx.Model(&workflow).Updates(map[string]interface{}{
+			"status":       workflow.Status,
+			"completed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if _, err := s.recordOnChain(tx, &workflow, user.ID, "signing_workflow_complete", map[string]interface{}{
+			"workflow_id": workflow.ID,
+			"signers":     signerIDs(workflow.Signers),
+		}); err != nil {
+			return err
+		}
+
+		reason := fmt.Sprintf("Signed in signing workflow %d", workflow.ID)
+		return tx.Model(&models.Document{}).Where("id = ?", doc.ID).UpdateColumns(map[string]interface{}{
+			"locked_at":   now,
+			"lock_reason": reason,
+		}).Error
+	})
+	if err != nil {
+		return nil, s.workflowError(err, "failed to sign")
+	}
+
+	if next != nil {
+		s.notifySigner(doc, &workflow, next, user)
+		return s.Get(doc.ID, workflow.ID)
+	}
+
+	s.notifyCreator(doc, &workflow, user, fmt.Sprintf("Everyone signed %q; the document is now locked", doc.Title))
+
+	return s.Get(doc.ID, workflow.ID)
+}
+
+// Decline ends the workflow because the pending signer refuses to sign
+func (s *SigningWorkflowService) Decline(doc *models.Document, workflowID uint, user *models.User, reason string) (*models.SigningWorkflow, error) {
+	var workflow models.SigningWorkflow
+	var signer *models.SigningWorkflowSigner
+	now := time.Now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		signer, err = s.lockPendingSigner(tx, doc, workflowID, user, &workflow)
+		if err != nil {
+			return err
+		}
+
+		signer.Status = models.SignerDeclined
+		signer.DeclineReason = reason
+		signer.DeclinedAt = &now
+		if err := tx.Omit(clause.Associations).Save(signer).Error; err != nil {
+			return err
+		}
+
+		workflow.Status = models.SigningWorkflowDeclined
+		workflow.CompletedAt = &now
+		if err := tx.Model(&workflow).Updates(map[string]interface{}{
+			"status":       workflow.Status,
+			"completed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		_, err = s.recordOnChain(tx, &workflow, user.ID, "signing_workflow_decline", map[string]interface{}{
+			"workflow_id": workflow.ID,
+			"signer_id":   signer.ID,
+			"reason":      reason,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, s.workflowError(err, "failed to decline")
+	}
+
+	s.notifyCreator(doc, &workflow, user, fmt.Sprintf("%s declined to sign %q", user.Username, doc.Title))
+
+	return s.Get(doc.ID, workflow.ID)
+}
+
+// Cancel ends a workflow still in progress
+func (s *SigningWorkflowService) Cancel(doc *models.Document, workflowID uint, user *models.User) (*models.SigningWorkflow, error) {
+	var workflow models.SigningWorkflow
+	now := time.Now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockWorkflow(tx, doc, workflowID, &workflow); err != nil {
+			return err
+		}
+
+		workflow.Status = models.SigningWorkflowCancelled
+		workflow.CompletedAt = &now
+		if err := tx.Model(&workflow).Updates(map[string]interface{}{
+			"status":       workflow.Status,
+			"completed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		_, err := s.recordOnChain(tx, &workflow, user.ID, "signing_workflow_cancel", map[string]interface{}{
+			"workflow_id": workflow.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, s.workflowError(err, "failed to cancel")
+	}
+
+	return s.Get(doc.ID, workflow.ID)
+}
+
+// lockWorkflow locks a document's workflow in progress for the transaction
+// and loads it with its signers
+func (s *SigningWorkflowService) lockWorkflow(tx *gorm.DB, doc *models.Document, workflowID uint, workflow *models.SigningWorkflow) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("document_id = ?", doc.ID).
+		First(workflow, workflowID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSigningWorkflowNotFound
+		}
+		return err
+	}
+	if workflow.Status != models.SigningWorkflowInProgress {
+		return ErrSigningWorkflowClosed
+	}
+
+	return tx.Where("workflow_id = ?", workflow.ID).Order("position ASC").Find(&workflow.Signers).Error
+}
+
+// lockPendingSigner locks a document's workflow in progress and returns the
+// user's entry among its signers when it is their turn
+func (s *SigningWorkflowService) lockPendingSigner(tx *gorm.DB, doc *models.Document, workflowID uint, user *models.User, workflow *models.SigningWorkflow) (*models.SigningWorkflowSigner, error) {
+	if err := s.lockWorkflow(tx, doc, workflowID, workflow); err != nil {
+		return nil, err
+	}
+
+	for i := range workflow.Signers {
+		signer := &workflow.Signers[i]
+		if signer.Status == models.SignerPending {
+			if signer.UserID != user.ID {
+				return nil, ErrNotPendingSigner
+			}
+			return signer, nil
+		}
+	}
+	return nil, ErrNotPendingSigner
+}
+
+// workflowError passes on the errors callers handle and wraps the others
+func (s *SigningWorkflowService) workflowError(err error, action string) error {
+	for _, known := range []error{ErrSigningWorkflowNotFound, ErrSigningWorkflowClosed, ErrNotPendingSigner, ErrWrongSigningMethod, ErrDocumentChanged} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	return fmt.Errorf("%s: %w", action, err)
+}
+
+// recordOnChain writes a workflow event with the signed version to the
+// outbox in the workflow's transaction, to be recorded on the blockchain
+// once it commits. It returns the transaction ID, or "" when the blockchain
+// is disabled.
+func (s *SigningWorkflowService) recordOnChain(tx *gorm.DB, workflow *models.SigningWorkflow, userID uint, action string, data map[string]interface{}) (string, error) {
+	if s.blockchainService == nil {
+		return "", nil
+	}
+
+	data["version"] = workflow.Version
+	data["file_hash"] = workflow.FileHash
+	return EnqueueBlockchain(tx, workflow.DocumentID, userID, action, data)
+}
+
+// notifySigner tells a signer it is their turn, in the application and, when
+// a mailer is configured, by email
+func (s *SigningWorkflowService) notifySigner(doc *models.Document, workflow *models.SigningWorkflow, signer *models.SigningWorkflowSigner, actor *models.User) {
+	text := fmt.Sprintf("%s asks you to sign %q (version %d)", actor.Username, doc.Title, workflow.Version)
+	if err := s.notificationService.Notify([]models.Notification{{
+		UserID:     signer.UserID,
+		Type:       models.NotificationSignatureRequest,
+		ActorID:    &actor.ID,
+		DocumentID: &doc.ID,
+		Message:    truncate(text, 500),
+	}}); err != nil {
+		log.Printf("Failed to notify signer %d of signing workflow %d: %v", signer.UserID, workflow.ID, err)
+	}
+
+	if s.mailer == nil {
+		return
+	}
+
+	var user models.User
+	if err := s.db.First(&user, signer.UserID).Error; err != nil {
+		log.Printf("Failed to get signer %d of signing workflow %d: %v", signer.UserID, workflow.ID, err)
+		return
+	}
+	name := user.FirstName
+	if name == "" {
+		name = user.Username
+	}
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"%s asks you to sign the document %q (version %d).\n\n", name, actor.Username, doc.Title, workflow.Version)
+	if workflow.Message != "" {
+		body += workflow.Message + "\n\n"
+	}
+	body += "Open your signing requests in the document management system to review and sign it.\n"
+
+	go func() {
+		if err := s.mailer.Send(user.Email, "Signature requested: "+doc.Title, body); err != nil {
+			log.Printf("Failed to email signing request to user %d: %v", user.ID, err)
+		}
+	}()
+}
+
+// notifyCreator tells the creator of a workflow how it ended
+func (s *SigningWorkflowService) notifyCreator(doc *models.Document, workflow *models.SigningWorkflow, actor *models.User, text string) {
+	if err := s.notificationService.Notify([]models.Notification{{
+		UserID:     workflow.CreatedBy,
+		Type:       models.NotificationSigningUpdate,
+		ActorID:    &actor.ID,
+		DocumentID: &doc.ID,
+		Message:    truncate(text, 500),
+	}}); err != nil {
+		log.Printf("Failed to notify user %d of signing workflow %d: %v", workflow.CreatedBy, workflow.ID, err)
+	}
+}
+
+// clickEvidence hashes what a click signature binds together: the signed
+// file, the signer's identity and authentication, and the time of signing
+func clickEvidence(workflow *models.SigningWorkflow, signer *models.SigningWorkflowSigner, user *models.User, signedAt time.Time) string {
+	fields := []string{
+		strconv.Itoa(int(workflow.ID)),
+		strconv.Itoa(int(workflow.DocumentID)),
+		strconv.Itoa(workflow.Version),
+		workflow.FileHash,
+		strconv.Itoa(int(user.ID)),
+		user.Username,
+		user.Email,
+		signer.SignedName,
+		signedAt.UTC().Format(time.RFC3339Nano),
+		signer.AuthenticatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(fields, "\n"))))
+}
+
+// namesMatch reports whether a signed name is the user's full name or
+// username, ignoring case and surrounding spaces
+func namesMatch(signedName string, user *models.User) bool {
+	signedName = strings.Join(strings.Fields(signedName), " ")
+	if signedName == "" {
+		return false
+	}
+	fullName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	return strings.EqualFold(signedName, fullName) || strings.EqualFold(signedName, user.Username)
+}
+
+// certificateIdentifies reports whether a certificate is issued to the
+// user's email address
+func certificateIdentifies(certificate *x509.Certificate, user *models.User) bool {
+	for _, email := range certificate.EmailAddresses {
+		if strings.EqualFold(email, user.Email) {
+			return true
+		}
+	}
+	for _, name := range certificate.Subject.Names {
+		// emailAddress attribute (1.2.840.113549.1.9.1) of older certificates
+		if name.Type.String() == "1.2.840.113549.1.9.1" {
+			if email, ok := name.Value.(string); ok && strings.EqualFold(email, user.Email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// signerIDs returns the user IDs of signers in signing order
+func signerIDs(signers []models.SigningWorkflowSigner) []uint {
+	ids := make([]uint, 0, len(signers))
+	for _, signer := range signers {
+		ids = append(ids, signer.UserID)
+	}
+	return ids
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
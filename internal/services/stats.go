@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// TrendInterval is the bucket size of an upload trend
+type TrendInterval string
+
+const (
+	TrendDaily   TrendInterval = "day"
+	TrendWeekly  TrendInterval = "week"
+	TrendMonthly TrendInterval = "month"
+)
+
+// ValidTrendInterval reports whether interval is a supported bucket size
+func ValidTrendInterval(interval TrendInterval) bool {
+	switch interval {
+	case TrendDaily, TrendWeekly, TrendMonthly:
+		return true
+	}
+	return false
+}
+
+// StatGroup counts documents and their size sharing a value
+type StatGroup struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+	Bytes int64  `json:"bytes"`
+}
+
+// AccessLevelStat counts documents and their size of an access level
+type AccessLevelStat struct {
+	AccessLevel models.AccessLevel `json:"access_level"`
+	Count       int64              `json:"count"`
+	Bytes       int64              `json:"bytes"`
+}
+
+// TrendPoint counts documents uploaded in the period starting at Period
+type TrendPoint struct {
+	Period time.Time `json:"period"`
+	Count  int64     `json:"count"`
+	Bytes  int64     `json:"bytes"`
+}
+
+// StorageStats is the storage used by document files in bytes
+type StorageStats struct {
+	DocumentBytes int64 `json:"document_bytes"`
+	VersionBytes  int64 `json:"version_bytes"`
+	TrashBytes    int64 `json:"trash_bytes"`
+	TotalBytes    int64 `json:"total_bytes"`
+}
+
+// DocumentStats summarizes the document collection for dashboards. Counts
+// cover documents outside the trash; the upload trend covers every upload in
+// the period, including documents deleted since.
+type DocumentStats struct {
+	TotalDocuments int64             `json:"total_documents"`
+	TrashDocuments int64             `json:"trash_documents"`
+	Storage        StorageStats      `json:"storage"`
+	ByCategory     []StatGroup       `json:"by_category"`
+	ByAccessLevel  []AccessLevelStat `json:"by_access_level"`
+	ByDepartment   []StatGroup       `json:"by_department"`
+	UploadTrend    []TrendPoint      `json:"upload_trend"`
+	TrendInterval  TrendInterval     `json:"trend_interval"`
+	TrendFrom      time.Time         `json:"trend_from"`
+	TrendTo        time.Time         `json:"trend_to"`
+}
+
+// StatsService computes aggregate statistics
+type StatsService struct {
+	db *gorm.DB
+}
+
+// NewStatsService creates a new stats service
+func NewStatsService() *StatsService {
+	return &StatsService{
+		db: database.GetDB(),
+	}
+}
+
+// GetDocumentStats returns document statistics of an organization with
+// uploads between from and to bucketed by interval
+func (s *StatsService) GetDocumentStats(ctx context.Context, organizationID uint, interval TrendInterval, from, to time.Time) (*DocumentStats, error) {
+	stats := &DocumentStats{
+		TrendInterval: interval,
+		TrendFrom:     from,
+		TrendTo:       to,
+	}
+
+	var totals struct {
+		Count int64
+		Bytes int64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Document{}).Scopes(OfOrganization("documents", organizationID)).
+		Select("COUNT(*) AS count, COALESCE(SUM(file_size), 0) AS bytes").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+	stats.TotalDocuments = totals.Count
+	stats.Storage.DocumentBytes = totals.Bytes
+
+	// Trashed documents keep their files until they are purged
+	var trash struct {
+		Count int64
+		Bytes int64
+	}
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.Document{}).Scopes(OfOrganization("documents", organizationID)).
+		Select("COUNT(*) AS count, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("deleted_at IS NOT NULL AND purged_at IS NULL").
+		Scan(&trash).Error; err != nil {
+		return nil, fmt.Errorf("failed to count trashed documents: %w", err)
+	}
+	stats.TrashDocuments = trash.Count
+	stats.Storage.TrashBytes = trash.Bytes
+
+	if err := s.db.WithContext(ctx).Model(&models.DocumentVersion{}).
+		Select("COALESCE(SUM(document_versions.file_size), 0)").
+		Joins("JOIN documents ON documents.id = document_versions.document_id").
+		Where("documents.organization_id = ?", organizationID).
+		Scan(&stats.Storage.VersionBytes).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum version sizes: %w", err)
+	}
+	stats.Storage.TotalBytes = stats.Storage.DocumentBytes + stats.Storage.VersionBytes + stats.Storage.TrashBytes
+
+	stats.ByCategory = []StatGroup{}
+	if err := s.db.WithContext(ctx).Model(&models.Document{}).Scopes(OfOrganization("documents", organizationID)).
+		Select("category AS key, COUNT(*) AS count, COALESCE(SUM(file_size), 0) AS bytes").
+		Group("category").
+		Order("count DESC, key").
+		Scan(&stats.ByCategory).Error; err != nil {
+		return nil, fmt.Errorf("failed to count documents by category: %w", err)
+	}
+
+	stats.ByAccessLevel = []AccessLevelStat{}
+	if err := s.db.WithContext(ctx).Model(&models.Document{}).Scopes(OfOrganization("documents", organizationID)).
+		Select("access_level, COUNT(*) AS count, COALESCE(SUM(file_size), 0) AS bytes").
+		Group("access_level").
+		Order("access_level").
+		Scan(&stats.ByAccessLevel).Error; err != nil {
+		return nil, fmt.Errorf("failed to count documents by access level: %w", err)
+	}
+
+	// Documents belong to the department of their creator
+	stats.ByDepartment = []StatGroup{}
+	if err := s.db.WithContext(ctx).Model(&models.Document{}).Scopes(OfOrganization("documents", organizationID)).
+		Select("COALESCE(users.department, '') AS key, COUNT(*) AS count, COALESCE(SUM(documents.file_size), 0) AS bytes").
+		Joins("LEFT JOIN users ON users.id = documents.created_by").
+		Group("COALESCE(users.department, '')").
+		Order("count DESC, key").
+		Scan(&stats.ByDepartment).Error; err != nil {
+		return nil, fmt.Errorf("failed to count documents by department: %w", err)
+	}
+
+	stats.UploadTrend = []TrendPoint{}
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.Document{}).Scopes(OfOrganization("documents", organizationID)).
+		Select("date_trunc(?, created_at) AS period, COUNT(*) AS count, COALESCE(SUM(file_size), 0) AS bytes", string(interval)).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("period").
+		Order("period").
+		Scan(&stats.UploadTrend).Error; err != nil {
+		return nil, fmt.Errorf("failed to get upload trend: %w", err)
+	}
+
+	return stats, nil
+}
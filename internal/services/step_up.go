@@ -0,0 +1,45 @@
+package services
+
+import (
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// StepUpPolicy decides which documents may only be read shortly after the
+// user entered a password or used a security key
+type StepUpPolicy struct {
+	maxAge   time.Duration
+	minLevel models.AccessLevel
+}
+
+// NewStepUpPolicy creates a step-up policy for documents at minLevel or
+// above. An authentication counts as fresh for maxAge; 0 turns step-up off.
+func NewStepUpPolicy(maxAge time.Duration, minLevel models.AccessLevel) *StepUpPolicy {
+	return &StepUpPolicy{
+		maxAge:   maxAge,
+		minLevel: minLevel,
+	}
+}
+
+// MaxAge returns how long an authentication counts as fresh
+func (p *StepUpPolicy) MaxAge() time.Duration {
+	return p.maxAge
+}
+
+// Required reports whether reading the document needs a fresh authentication
+func (p *StepUpPolicy) Required(doc *models.Document) bool {
+	return p.maxAge > 0 && doc.AccessLevel >= p.minLevel
+}
+
+// Fresh reports whether an authentication at authTime is recent enough.
+// The zero time, as for API keys, is never fresh.
+func (p *StepUpPolicy) Fresh(authTime time.Time) bool {
+	return !authTime.IsZero() && time.Since(authTime) <= p.maxAge
+}
+
+// Allows reports whether a user who authenticated at authTime may read the
+// document without stepping up
+func (p *StepUpPolicy) Allows(doc *models.Document, authTime time.Time) bool {
+	return !p.Required(doc) || p.Fresh(authTime)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// StorageGrouping selects how storage usage is aggregated
+type StorageGrouping string
+
+const (
+	GroupByDepartment StorageGrouping = "department"
+	GroupByCreator    StorageGrouping = "creator"
+)
+
+// ValidStorageGrouping reports whether grouping is supported
+func ValidStorageGrouping(grouping StorageGrouping) bool {
+	return grouping == GroupByDepartment || grouping == GroupByCreator
+}
+
+// StorageUsage is the document count and file bytes held by a department or
+// creator. Creator fields are only set when grouping by creator.
+type StorageUsage struct {
+	Department string `json:"department"`
+	CreatorID  uint   `json:"creator_id,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Documents  int64  `json:"documents"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// MonthlyStorageUsage is the storage held at the end of a month, along with
+// what was uploaded during it
+type MonthlyStorageUsage struct {
+	Month time.Time `json:"month"`
+	StorageUsage
+	UploadedDocuments int64 `json:"uploaded_documents"`
+	UploadedBytes     int64 `json:"uploaded_bytes"`
+}
+
+// storageGroupColumns returns the select and group by columns for a grouping
+func storageGroupColumns(grouping StorageGrouping) (string, string) {
+	if grouping == GroupByCreator {
+		return "COALESCE(users.department, '') AS department, documents.created_by AS creator_id, COALESCE(users.username, '') AS username",
+			"COALESCE(users.department, ''), documents.created_by, COALESCE(users.username, '')"
+	}
+	return "COALESCE(users.department, '') AS department", "COALESCE(users.department, '')"
+}
+
+// GetStorageUsage returns the storage currently held in an organization per
+// department or creator. Trashed documents count until they are purged, since their files
+// are still stored.
+func (s *StatsService) GetStorageUsage(ctx context.Context, organizationID uint, grouping StorageGrouping) ([]StorageUsage, error) {
+	columns, group := storageGroupColumns(grouping)
+
+	usage := []StorageUsage{}
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.Document{}).
+		Select(columns+", COUNT(*) AS documents, COALESCE(SUM(documents.file_size), 0) AS bytes").
+		Joins("LEFT JOIN users ON users.id = documents.created_by").
+		Where("documents.organization_id = ? AND documents.purged_at IS NULL", organizationID).
+		Group(group).
+		Order("bytes DESC, department").
+		Scan(&usage).Error; err != nil {
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// GetMonthlyStorageUsage returns, for each month from the month of from to
+// the month of to, the storage held in an organization at the end of the
+// month per department or creator. Months without stored documents are omitted.
+func (s *StatsService) GetMonthlyStorageUsage(ctx context.Context, organizationID uint, grouping StorageGrouping, from, to time.Time) ([]MonthlyStorageUsage, error) {
+	columns, group := storageGroupColumns(grouping)
+
+	query := fmt.Sprintf(`
+		SELECT months.month AS month, %s,
+			COUNT(documents.id) AS documents,
+			COALESCE(SUM(documents.file_size), 0) AS bytes,
+			COUNT(documents.id) FILTER (WHERE documents.created_at >= months.month) AS uploaded_documents,
+			COALESCE(SUM(documents.file_size) FILTER (WHERE documents.created_at >= months.month), 0) AS uploaded_bytes
+		FROM generate_series(date_trunc('month', ?::timestamptz), date_trunc('month', ?::timestamptz), interval '1 month') AS months(month)
+		JOIN documents ON documents.organization_id = ?
+			AND documents.created_at < months.month + interval '1 month'
+			AND (documents.purged_at IS NULL OR documents.purged_at >= months.month + interval '1 month')
+		LEFT JOIN users ON users.id = documents.created_by
+		GROUP BY months.month, %s
+		ORDER BY months.month, bytes DESC, department`, columns, group)
+
+	usage := []MonthlyStorageUsage{}
+	if err := s.db.WithContext(ctx).Raw(query, from, to, organizationID).Scan(&usage).Error; err != nil {
+		return nil, fmt.Errorf("failed to get monthly storage usage: %w", err)
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSubscription is returned when a subscription targets neither or both of a document and a folder
+var ErrInvalidSubscription = errors.New("subscription must target either a document or a folder")
+
+// subscriptionColumns maps notification types to the subscription flag enabling them
+var subscriptionColumns = map[models.NotificationType]string{
+	models.NotificationNewVersion:       "notify_versions",
+	models.NotificationPermissionChange: "notify_permissions",
+	models.NotificationComment:          "notify_comments",
+}
+
+// SubscriptionEvent describes a change subscribers are notified of
+type SubscriptionEvent struct {
+	Type      models.NotificationType
+	ActorID   uint
+	CommentID *uint
+	Message   string
+}
+
+// SubscriptionService manages document and folder subscriptions and fans
+// events out to subscribers as notifications
+type SubscriptionService struct {
+	db                  *gorm.DB
+	authService         *AuthorizationService
+	notificationService *NotificationService
+}
+
+// NewSubscriptionService creates a new subscription service
+func NewSubscriptionService(authService *AuthorizationService, notificationService *NotificationService) *SubscriptionService {
+	return &SubscriptionService{
+		db:                  database.GetDB(),
+		authService:         authService,
+		notificationService: notificationService,
+	}
+}
+
+// GetForUser retrieves the subscriptions of a user
+func (s *SubscriptionService) GetForUser(userID uint) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// GetByID retrieves a subscription of a user
+func (s *SubscriptionService) GetByID(userID, id uint) (*models.Subscription, error) {
+	var subscription models.Subscription
+	if err := s.db.Where("user_id = ?", userID).First(&subscription, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return &subscription, nil
+}
+
+// Subscribe creates a subscription, or updates the events of the user's
+// existing subscription to the same document or folder
+func (s *SubscriptionService) Subscribe(subscription *models.Subscription) error {
+	if (subscription.DocumentID == nil) == (subscription.FolderID == nil) {
+		return ErrInvalidSubscription
+	}
+
+	query := s.db.Where("user_id = ?", subscription.UserID)
+	if subscription.DocumentID != nil {
+		query = query.Where("document_id = ?", *subscription.DocumentID)
+	} else {
+		query = query.Where("folder_id = ?", *subscription.FolderID)
+	}
+
+	var existing models.Subscription
+	err := query.First(&existing).Error
+	switch {
+	case err == nil:
+		subscription.ID = existing.ID
+		subscription.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	if err := s.db.Save(subscription).Error; err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return nil
+}
+
+// Update saves the events of a subscription
+func (s *SubscriptionService) Update(subscription *models.Subscription) error {
+	if err := s.db.Model(subscription).
+		Select("notify_versions", "notify_permissions", "notify_comments").
+		Updates(subscription).Error; err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a subscription
+func (s *SubscriptionService) Delete(subscription *models.Subscription) error {
+	if err := s.db.Delete(subscription).Error; err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	return nil
+}
+
+// Publish notifies users subscribed to the document, or to a folder containing
+// it, of an event. The actor, excluded users and users who may no longer read
+// the document are skipped.
+func (s *SubscriptionService) Publish(doc *models.Document, event *SubscriptionEvent, exclude ...uint) {
+	userIDs, err := s.subscribers(&doc.ID, doc.FolderID, event.Type)
+	if err != nil {
+		log.Printf("Failed to get subscribers of document %d: %v", doc.ID, err)
+		return
+	}
+
+	s.notify(userIDs, event, &doc.ID, exclude, func(user *models.User) (bool, error) {
+		return s.authService.CanRead(user, doc)
+	})
+}
+
+// PublishFolder notifies users subscribed to the folder or one of its
+// ancestors of an event concerning the folder
+func (s *SubscriptionService) PublishFolder(folder *models.Folder, event *SubscriptionEvent) {
+	userIDs, err := s.subscribers(nil, &folder.ID, event.Type)
+	if err != nil {
+		log.Printf("Failed to get subscribers of folder %d: %v", folder.ID, err)
+		return
+	}
+
+	s.notify(userIDs, event, nil, nil, func(user *models.User) (bool, error) {
+		return s.authService.CanOnFolder(user, folder, ActionRead)
+	})
+}
+
+// subscribers returns the users subscribed to the event on the document or on
+// the folder and its ancestors. Either may be nil.
+func (s *SubscriptionService) subscribers(documentID, folderID *uint, eventType models.NotificationType) ([]uint, error) {
+	column, ok := subscriptionColumns[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported subscription event: %s", eventType)
+	}
+
+	query := `WITH RECURSIVE ancestors(id, parent_id, depth) AS (
+			SELECT id, parent_id, 1 FROM folders WHERE id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT folders.id, folders.parent_id, ancestors.depth + 1 FROM folders
+			JOIN ancestors ON folders.id = ancestors.parent_id
+			WHERE folders.deleted_at IS NULL AND ancestors.depth < ?
+		)
+		SELECT DISTINCT user_id FROM subscriptions
+		WHERE ` + column + ` = true AND (document_id = ? OR folder_id IN (SELECT id FROM ancestors))`
+
+	var userIDs []uint
+	if err := s.db.Raw(query, folderID, maxFolderDepth, documentID).Scan(&userIDs).Error; err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// notify creates the event's notification for each active user passing the access check
+func (s *SubscriptionService) notify(userIDs []uint, event *SubscriptionEvent, documentID *uint, exclude []uint, canRead func(*models.User) (bool, error)) {
+	skip := map[uint]bool{event.ActorID: true}
+	for _, id := range exclude {
+		skip[id] = true
+	}
+
+	var ids []uint
+	for _, id := range userIDs {
+		if !skip[id] {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	var users []models.User
+	if err := s.db.Where("id IN ? AND is_active = ?", ids, true).Find(&users).Error; err != nil {
+		log.Printf("Failed to get subscribers: %v", err)
+		return
+	}
+
+	notifications := make([]models.Notification, 0, len(users))
+	for i := range users {
+		allowed, err := canRead(&users[i])
+		if err != nil {
+			log.Printf("Failed to check access of subscriber %d: %v", users[i].ID, err)
+			continue
+		}
+		if !allowed {
+			continue
+		}
+
+		notifications = append(notifications, models.Notification{
+			UserID:     users[i].ID,
+			Type:       event.Type,
+			ActorID:    &event.ActorID,
+			DocumentID: documentID,
+			CommentID:  event.CommentID,
+			Message:    event.Message,
+		})
+	}
+
+	if err := s.notificationService.Notify(notifications); err != nil {
+		log.Printf("Failed to notify subscribers: %v", err)
+	}
+}
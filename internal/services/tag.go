@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxTagLength matches the size of the tag name column
+const maxTagLength = 100
+
+// ParseTags reads tag names from a JSON array or a comma-separated list
+func ParseTags(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+
+	var names []string
+	if err := json.Unmarshal([]byte(raw), &names); err != nil {
+		names = strings.Split(raw, ",")
+	}
+	return NormalizeTags(names)
+}
+
+// NormalizeTags trims whitespace from tag names and drops empty, overlong and
+// duplicate names
+func NormalizeTags(names []string) []string {
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" && len(name) <= maxTagLength {
+			tags = append(tags, name)
+		}
+	}
+	return uniqueStrings(tags)
+}
+
+// uniqueStrings returns the values without duplicates, keeping their order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// encodeTags serializes tag names into the JSON array kept on the document
+func encodeTags(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(names)
+	return string(data)
+}
+
+// findOrCreateTags resolves tag names to tags, creating missing ones and
+// reviving deleted ones
+func findOrCreateTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		var tag models.Tag
+		err := tx.Unscoped().Where("name = ?", name).First(&tag).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			tag = models.Tag{Name: name}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag).Error; err != nil {
+				return nil, err
+			}
+			if tag.ID == 0 {
+				// Created concurrently
+				if err := tx.Where("name = ?", name).First(&tag).Error; err != nil {
+					return nil, err
+				}
+			}
+		case err != nil:
+			return nil, err
+		case tag.DeletedAt.Valid:
+			if err := tx.Unscoped().Model(&tag).Update("deleted_at", nil).Error; err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// recountTags refreshes the usage count of the given tags from document_tags
+func recountTags(tx *gorm.DB, tagIDs []uint) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	return tx.Exec(`UPDATE tags SET usage_count = (
+			SELECT COUNT(*) FROM document_tags WHERE document_tags.tag_id = tags.id
+		) WHERE id IN ?`, tagIDs).Error
+}
+
+// syncDocumentTags replaces the tags of a document with the named tags,
+// updating the document's tag list and the usage counts of affected tags
+func syncDocumentTags(tx *gorm.DB, doc *models.Document, names []string, userID uint) error {
+	tags, err := findOrCreateTags(tx, names)
+	if err != nil {
+		return err
+	}
+
+	var previous []uint
+	if err := tx.Model(&models.DocumentTag{}).Where("document_id = ?", doc.ID).Pluck("tag_id", &previous).Error; err != nil {
+		return err
+	}
+
+	// Keep existing links so their creation time still tells when the tag was applied
+	removed := tx.Where("document_id = ?", doc.ID)
+	if len(tags) > 0 {
+		tagIDs := make([]uint, 0, len(tags))
+		for _, tag := range tags {
+			tagIDs = append(tagIDs, tag.ID)
+		}
+		removed = removed.Where("tag_id NOT IN ?", tagIDs)
+	}
+	if err := removed.Delete(&models.DocumentTag{}).Error; err != nil {
+		return err
+	}
+
+	affected := previous
+	if len(tags) > 0 {
+		links := make([]models.DocumentTag, 0, len(tags))
+		for _, tag := range tags {
+			links = append(links, models.DocumentTag{DocumentID: doc.ID, TagID: tag.ID, CreatedBy: userID})
+			affected = append(affected, tag.ID)
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+			return err
+		}
+	}
+
+	doc.Tags = encodeTags(names)
+	if err := tx.Unscoped().Model(&models.Document{}).Where("id = ?", doc.ID).UpdateColumn("tags", doc.Tags).Error; err != nil {
+		return err
+	}
+
+	return recountTags(tx, affected)
+}
+
+// removeDocumentTags unlinks all tags of a document and refreshes their usage counts
+func removeDocumentTags(tx *gorm.DB, documentID uint) error {
+	var tagIDs []uint
+	if err := tx.Model(&models.DocumentTag{}).Where("document_id = ?", documentID).Pluck("tag_id", &tagIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("document_id = ?", documentID).Delete(&models.DocumentTag{}).Error; err != nil {
+		return err
+	}
+	return recountTags(tx, tagIDs)
+}
+
+// SetTags replaces the tags of a document
+func (s *DocumentService) SetTags(ctx context.Context, doc *models.Document, names []string, userID uint) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return syncDocumentTags(tx, doc, names, userID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update document tags: %w", err)
+	}
+
+	s.index(doc, "")
+
+	return nil
+}
+
+// AddTags adds tags to a document, keeping its existing tags
+func (s *DocumentService) AddTags(ctx context.Context, doc *models.Document, names []string, userID uint) error {
+	return s.SetTags(ctx, doc, NormalizeTags(append(ParseTags(doc.Tags), names...)), userID)
+}
+
+// RemoveTag removes a tag from a document
+func (s *DocumentService) RemoveTag(ctx context.Context, doc *models.Document, name string, userID uint) error {
+	current := ParseTags(doc.Tags)
+	remaining := make([]string, 0, len(current))
+	for _, tag := range current {
+		if tag != name {
+			remaining = append(remaining, tag)
+		}
+	}
+	return s.SetTags(ctx, doc, remaining, userID)
+}
+
+// TagService handles tag-related business logic
+type TagService struct {
+	db *gorm.DB
+}
+
+// NewTagService creates a new tag service
+func NewTagService() *TagService {
+	return &TagService{
+		db: database.GetDB(),
+	}
+}
+
+// usedInOrganization restricts a tag query to tags applied to documents of
+// an organization. Tags are shared, so names used by other organizations
+// aren't revealed.
+func usedInOrganization(organizationID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`EXISTS (SELECT 1 FROM document_tags
+			JOIN documents ON documents.id = document_tags.document_id
+			WHERE document_tags.tag_id = tags.id AND documents.organization_id = ?)`, organizationID)
+	}
+}
+
+// GetAll retrieves the tags used in an organization ordered by name
+func (s *TagService) GetAll(ctx context.Context, organizationID uint) ([]models.Tag, error) {
+	var tags []models.Tag
+	if err := s.db.WithContext(ctx).Scopes(usedInOrganization(organizationID)).Order("name ASC").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return tags, nil
+}
+
+// TrendingTag is a tag with the number of documents it was recently applied to
+type TrendingTag struct {
+	models.Tag
+	RecentCount int64 `json:"recent_count"`
+}
+
+// Suggest retrieves tags used in an organization whose name starts with the
+// prefix, most used first
+func (s *TagService) Suggest(ctx context.Context, organizationID uint, prefix string, limit int) ([]models.Tag, error) {
+	var tags []models.Tag
+	if err := s.db.WithContext(ctx).Scopes(usedInOrganization(organizationID)).Where("name ILIKE ? ESCAPE '\\'", escapeLike(prefix)+"%").
+		Order("usage_count DESC, name ASC").
+		Limit(limit).
+		Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to suggest tags: %w", err)
+	}
+	return tags, nil
+}
+
+// Trending retrieves the tags applied to the most documents of an
+// organization since the given time
+func (s *TagService) Trending(ctx context.Context, organizationID uint, since time.Time, limit int) ([]TrendingTag, error) {
+	var tags []TrendingTag
+	if err := s.db.WithContext(ctx).Model(&models.Tag{}).
+		Select("tags.*, COUNT(document_tags.document_id) AS recent_count").
+		Joins("JOIN document_tags ON document_tags.tag_id = tags.id").
+		Joins("JOIN documents ON documents.id = document_tags.document_id").
+		Where("documents.organization_id = ? AND document_tags.created_at >= ?", organizationID, since).
+		Group("tags.id").
+		Order("recent_count DESC, tags.usage_count DESC, tags.name ASC").
+		Limit(limit).
+		Scan(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to get trending tags: %w", err)
+	}
+	return tags, nil
+}
+
+// MigrateLegacyTags links documents to tags from the JSON tag lists stored on
+// documents before the document_tags table existed
+func (s *TagService) MigrateLegacyTags() error {
+	db := database.AsSystem(s.db)
+
+	var docs []models.Document
+	if err := db.Unscoped().
+		Select("id", "tags", "created_by").
+		Where("tags <> '' AND NOT EXISTS (SELECT 1 FROM document_tags WHERE document_tags.document_id = documents.id)").
+		Find(&docs).Error; err != nil {
+		return fmt.Errorf("failed to get documents with legacy tags: %w", err)
+	}
+
+	for i := range docs {
+		doc := &docs[i]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return syncDocumentTags(tx, doc, ParseTags(doc.Tags), doc.CreatedBy)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate tags of document %d: %w", doc.ID, err)
+		}
+	}
+
+	if len(docs) > 0 {
+		log.Printf("Migrated tags of %d documents", len(docs))
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/dlp"
+)
+
+// textExtensions lists file extensions treated as plain text regardless of MIME type
+var textExtensions = map[string]bool{
+	".txt":  true,
+	".md":   true,
+	".csv":  true,
+	".tsv":  true,
+	".json": true,
+	".xml":  true,
+	".yaml": true,
+	".yml":  true,
+	".log":  true,
+	".html": true,
+}
+
+// isTextDocument reports whether a document's content can be read as text
+func isTextDocument(mimeType, fileName string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+
+	switch {
+	case strings.HasPrefix(mimeType, "text/"):
+		return true
+	case mimeType == "application/json", mimeType == "application/xml", mimeType == "application/x-yaml":
+		return true
+	}
+
+	return textExtensions[strings.ToLower(filepath.Ext(fileName))]
+}
+
+// extractText returns the textual content of a document, or an empty string
+// when the content is not text
+func extractText(mimeType, fileName string, content []byte) string {
+	if !isTextDocument(mimeType, fileName) || !utf8.Valid(content) {
+		return ""
+	}
+	return string(content)
+}
+
+// extractUploadText returns the textual content of an upload for inspection,
+// falling back to the text drawn by a PDF
+func extractUploadText(mimeType, fileName string, content []byte) string {
+	text := extractText(mimeType, fileName, content)
+	if text == "" && bytes.HasPrefix(content, []byte("%PDF-")) {
+		text = dlp.ExtractPDFText(content)
+	}
+	return text
+}
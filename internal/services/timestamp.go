@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/blockchain"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/timestamp"
+	"gorm.io/gorm"
+)
+
+// ErrTimestampNotFound is returned for unknown trusted timestamps
+var ErrTimestampNotFound = errors.New("timestamp not found")
+
+// TimestampStatus is a trusted timestamp with the result of checking it
+type TimestampStatus struct {
+	*models.TrustedTimestamp
+	Verified bool   `json:"verified"`          // Signature, hash and, with CA certificates, chain check out
+	Current  bool   `json:"current,omitempty"` // Hash is the document's current file hash
+	Error    string `json:"error,omitempty"`
+}
+
+// TimestampService obtains RFC 3161 timestamps for document and block
+// hashes from a timestamping authority, as legal evidence of existence
+type TimestampService struct {
+	db     *gorm.DB
+	client *timestamp.Client
+	roots  *x509.CertPool
+}
+
+// NewTimestampService creates a new timestamp service. Tokens must chain to
+// the CA certificates in roots, or to the system roots with nil.
+func NewTimestampService(client *timestamp.Client, roots *x509.CertPool) *TimestampService {
+	return &TimestampService{
+		db:     database.GetDB(),
+		client: client,
+		roots:  roots,
+	}
+}
+
+// StampDocument timestamps the file hash of the document's current version
+func (s *TimestampService) StampDocument(ctx context.Context, doc *models.Document, userID uint) (*models.TrustedTimestamp, error) {
+	record := &models.TrustedTimestamp{
+		DocumentID: &doc.ID,
+		Version:    doc.Version,
+		CreatedBy:  userID,
+	}
+	if err := s.stamp(ctx, doc.FileHash, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// StampBlock timestamps a block's hash
+func (s *TimestampService) StampBlock(ctx context.Context, block blockchain.Block, userID uint) (*models.TrustedTimestamp, error) {
+	record := &models.TrustedTimestamp{
+		BlockNumber: &block.Index,
+		CreatedBy:   userID,
+	}
+	if err := s.stamp(ctx, block.Hash, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// stamp requests a timestamp for a hex SHA-256 hash and stores it in record
+func (s *TimestampService) stamp(ctx context.Context, hash string, record *models.TrustedTimestamp) error {
+	digest, err := hex.DecodeString(hash)
+	if err != nil || len(digest) != 32 {
+		return fmt.Errorf("invalid SHA-256 hash %q", hash)
+	}
+
+	token, err := s.client.Timestamp(ctx, digest)
+	if err != nil {
+		return fmt.Errorf("failed to get timestamp: %w", err)
+	}
+
+	record.Hash = hash
+	record.Authority = s.client.URL()
+	record.SerialNumber = token.SerialNumber
+	record.Policy = token.Policy
+	record.GenTime = token.GenTime
+	record.Token = token.Raw
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to save timestamp: %w", err)
+	}
+	return nil
+}
+
+// check parses a stored token and verifies it timestamps the stored hash
+func (s *TimestampService) check(record *models.TrustedTimestamp) TimestampStatus {
+	status := TimestampStatus{TrustedTimestamp: record}
+
+	digest, err := hex.DecodeString(record.Hash)
+	if err != nil {
+		status.Error = "invalid hash"
+		return status
+	}
+
+	token, err := timestamp.ParseToken(record.Token)
+	if err == nil {
+		err = token.Verify(digest, s.roots)
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Verified = true
+	return status
+}
+
+// GetForDocument lists the timestamps of a document's versions, newest
+// first, checking each
+func (s *TimestampService) GetForDocument(doc *models.Document) ([]TimestampStatus, error) {
+	var records []models.TrustedTimestamp
+	if err := s.db.Where("document_id = ?", doc.ID).
+		Order("created_at DESC, id DESC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get timestamps: %w", err)
+	}
+
+	statuses := make([]TimestampStatus, 0, len(records))
+	for i := range records {
+		status := s.check(&records[i])
+		status.Current = records[i].Hash == doc.FileHash
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// GetForBlocks lists the timestamps of blocks, newest first, checking each
+func (s *TimestampService) GetForBlocks(page, limit int) ([]TimestampStatus, int64, error) {
+	var records []models.TrustedTimestamp
+	var total int64
+
+	offset := (page - 1) * limit
+
+	query := s.db.Model(&models.TrustedTimestamp{}).Where("block_number IS NOT NULL").Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count timestamps: %w", err)
+	}
+
+	if err := query.Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get timestamps: %w", err)
+	}
+
+	statuses := make([]TimestampStatus, 0, len(records))
+	for i := range records {
+		statuses = append(statuses, s.check(&records[i]))
+	}
+	return statuses, total, nil
+}
+
+// GetDocumentTimestamp retrieves a timestamp of a document
+func (s *TimestampService) GetDocumentTimestamp(documentID, id uint) (*models.TrustedTimestamp, error) {
+	return s.get(s.db.Where("document_id = ?", documentID), id)
+}
+
+// GetBlockTimestamp retrieves a timestamp of a block
+func (s *TimestampService) GetBlockTimestamp(id uint) (*models.TrustedTimestamp, error) {
+	return s.get(s.db.Where("block_number IS NOT NULL"), id)
+}
+
+// get retrieves a timestamp by ID from the query
+func (s *TimestampService) get(query *gorm.DB, id uint) (*models.TrustedTimestamp, error) {
+	var record models.TrustedTimestamp
+	if err := query.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTimestampNotFound
+		}
+		return nil, fmt.Errorf("failed to get timestamp: %w", err)
+	}
+	return &record, nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TokenDenylistService keeps the access tokens refused before they expire,
+// so logouts take effect at once
+type TokenDenylistService struct {
+	db *gorm.DB
+}
+
+// NewTokenDenylistService creates a new token denylist service
+func NewTokenDenylistService() *TokenDenylistService {
+	return &TokenDenylistService{
+		db: database.GetDB(),
+	}
+}
+
+// Revoke refuses the access token with ID jti from now until it expires
+func (s *TokenDenylistService) Revoke(jti string, userID uint, expiresAt time.Time) error {
+	if jti == "" || !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether the access token with ID jti was revoked
+func (s *TokenDenylistService) IsRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+
+	var count int64
+	if err := s.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check token: %w", err)
+	}
+	return count > 0, nil
+}
+
+// DeleteExpired forgets revoked tokens that have expired anyway
+func (s *TokenDenylistService) DeleteExpired() (int64, error) {
+	result := s.db.Where("expires_at <= ?", time.Now()).Delete(&models.RevokedToken{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired revoked tokens: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// StartCleanup periodically forgets revoked tokens that have expired
+func (s *TokenDenylistService) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := s.DeleteExpired(); err != nil {
+				log.Printf("Revoked token cleanup failed: %v", err)
+			}
+		}
+	}()
+}
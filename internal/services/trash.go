@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// trashed selects soft-deleted documents that haven't been purged yet
+func (s *DocumentService) trashed(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Unscoped().Model(&models.Document{}).
+		Where("documents.deleted_at IS NOT NULL AND documents.purged_at IS NULL")
+}
+
+// GetTrash retrieves documents in the trash with pagination. Admins see all
+// trashed documents of their organization, other users those they own or
+// deleted themselves.
+func (s *DocumentService) GetTrash(ctx context.Context, user *models.User, page, limit int) ([]models.Document, int64, error) {
+	var docs []models.Document
+	var total int64
+
+	offset := (page - 1) * limit
+
+	query := s.trashed(ctx).Scopes(OfOrganization("documents", user.OrganizationID))
+	if user.Role != models.RoleAdmin {
+		query = query.Where("documents.created_by = ? OR documents.deleted_by = ?", user.ID, user.ID)
+	}
+	query = query.Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count trashed documents: %w", err)
+	}
+
+	if err := query.Order("documents.deleted_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&docs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get trashed documents: %w", err)
+	}
+
+	return docs, total, nil
+}
+
+// GetTrashedByID retrieves a document in the trash by ID
+func (s *DocumentService) GetTrashedByID(ctx context.Context, id uint) (*models.Document, error) {
+	var doc models.Document
+	if err := s.trashed(ctx).Where("documents.id = ?", id).First(&doc).Error; err != nil {
+		return nil, fmt.Errorf("failed to get trashed document: %w", err)
+	}
+	return &doc, nil
+}
+
+// Restore takes a document out of the trash
+func (s *DocumentService) Restore(ctx context.Context, doc *models.Document) error {
+	if err := s.db.WithContext(ctx).Unscoped().Model(doc).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"deleted_by": nil,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to restore document: %w", err)
+	}
+	doc.DeletedAt = gorm.DeletedAt{}
+	doc.DeletedBy = nil
+
+	s.index(doc, "")
+
+	return nil
+}
+
+// Purge permanently removes a trashed document's content: its files unless
+// other documents share them, data keys, versions, permissions, tags,
+// metadata and comments. The document row
+// is kept as a tombstone so audit logs and blockchain records referencing it
+// stay valid.
+func (s *DocumentService) Purge(ctx context.Context, doc *models.Document) error {
+	var hashes []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.DocumentVersion{}).
+			Where("document_id = ? AND file_path <> ''", doc.ID).
+			Pluck("file_hash", &hashes).Error; err != nil {
+			return err
+		}
+		if doc.FilePath != "" {
+			hashes = append(hashes, doc.FileHash)
+		}
+		if err := releaseBlobs(tx, hashes...); err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("document_id = ?", doc.ID).Delete(&models.DocumentVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("document_id = ?", doc.ID).Delete(&models.Permission{}).Error; err != nil {
+			return err
+		}
+		if err := removeDocumentTags(tx, doc.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", doc.ID).Delete(&models.TagSuggestion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", doc.ID).Delete(&models.DocumentMetadata{}).Error; err != nil {
+			return err
+		}
+		comments := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.Comment{}).Select("id").Where("document_id = ?", doc.ID)
+		if err := tx.Model(&models.Notification{}).Where("comment_id IN (?)", comments).Update("comment_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("document_id = ?", doc.ID).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", doc.ID).Delete(&models.Subscription{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", doc.ID).Delete(&models.Favorite{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Unscoped().Model(doc).Updates(map[string]interface{}{
+			"description": "",
+			"tags":        "",
+			"file_path":   "",
+			"data_key":    "",
+			"purged_at":   &now,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge document: %w", err)
+	}
+
+	s.deleteUnreferencedBlobs(hashes)
+	s.unindex(doc.ID)
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize is the number of expired documents purged per batch
+const purgeBatchSize = 100
+
+// TrashPurgeService permanently removes documents that stayed in the trash
+// longer than the retention window
+type TrashPurgeService struct {
+	db              *gorm.DB
+	documentService *DocumentService
+	auditService    *AuditService
+	retention       time.Duration
+	interval        time.Duration
+}
+
+// NewTrashPurgeService creates a new trash purge service
+func NewTrashPurgeService(documentService *DocumentService, auditService *AuditService, retention, interval time.Duration) *TrashPurgeService {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &TrashPurgeService{
+		db:              database.GetDB(),
+		documentService: documentService,
+		auditService:    auditService,
+		retention:       retention,
+		interval:        interval,
+	}
+}
+
+// Start runs the purge immediately and then on every interval in the
+// background, collecting unreferenced blobs after each run
+func (s *TrashPurgeService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			purged, err := s.PurgeExpired()
+			if err != nil {
+				log.Printf("Trash purge failed: %v", err)
+			} else if purged > 0 {
+				log.Printf("Trash purge removed %d documents", purged)
+			}
+
+			if collected, err := s.documentService.CollectGarbage(); err != nil {
+				log.Printf("Blob garbage collection failed: %v", err)
+			} else if collected > 0 {
+				log.Printf("Blob garbage collection removed %d blobs", collected)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// PurgeExpired purges every document deleted before the retention window and
+// records a purge audit entry for each
+func (s *TrashPurgeService) PurgeExpired() (int, error) {
+	ctx := database.SystemContext(context.Background())
+	cutoff := time.Now().Add(-s.retention)
+	purged := 0
+
+	var lastID uint
+	for {
+		var docs []models.Document
+		if err := s.documentService.trashed(ctx).
+			Where("documents.deleted_at < ? AND documents.id > ?", cutoff, lastID).
+			Order("documents.id ASC").
+			Limit(purgeBatchSize).
+			Find(&docs).Error; err != nil {
+			return purged, fmt.Errorf("failed to get expired documents: %w", err)
+		}
+
+		if len(docs) == 0 {
+			return purged, nil
+		}
+
+		for i := range docs {
+			doc := &docs[i]
+			lastID = doc.ID
+
+			if err := s.documentService.Purge(ctx, doc); err != nil {
+				log.Printf("Trash purge: failed to purge document %d: %v", doc.ID, err)
+				continue
+			}
+			purged++
+
+			// Attribute the purge to whoever trashed the document
+			userID := doc.CreatedBy
+			if doc.DeletedBy != nil {
+				userID = *doc.DeletedBy
+			}
+			s.auditService.LogAction(userID, &doc.ID, "document_purge", "document", strconv.Itoa(int(doc.ID)), "", "", map[string]interface{}{
+				"title":          doc.Title,
+				"file_hash":      doc.FileHash,
+				"deleted_at":     doc.DeletedAt.Time,
+				"retention_days": int(s.retention.Hours() / 24),
+				"scheduled":      true,
+			})
+		}
+	}
+}
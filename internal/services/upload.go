@@ -0,0 +1,301 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUploadOffsetMismatch is returned when a chunk doesn't start where the
+	// received data ends; the client should resume from the session's offset
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrUploadTooLarge is returned when a file exceeds the maximum upload
+	// size or a chunk exceeds the declared file size
+	ErrUploadTooLarge = errors.New("upload too large")
+	// ErrUploadIncomplete is returned when completing an upload missing data
+	ErrUploadIncomplete = errors.New("upload is incomplete")
+	// ErrUploadNotPending is returned for sessions being or already completed
+	ErrUploadNotPending = errors.New("upload is not pending")
+	// ErrUploadChecksum is returned when the assembled file doesn't match the
+	// expected hash
+	ErrUploadChecksum = errors.New("upload checksum mismatch")
+)
+
+// UploadService handles resumable chunked uploads. Chunks are encrypted at
+// rest like documents and assembled into the document once complete.
+type UploadService struct {
+	db       *gorm.DB
+	storage  *storage.LocalStorage
+	envelope *crypto.EnvelopeService
+	hasher   *crypto.HashService
+	ttl      time.Duration
+	maxSize  int64
+	maxChunk int64
+}
+
+// NewUploadService creates a new upload service whose sessions expire after
+// ttl without activity. Files may be up to maxSize bytes, sent in chunks of
+// up to maxChunk bytes.
+func NewUploadService(storage *storage.LocalStorage, envelope *crypto.EnvelopeService, ttl time.Duration, maxSize, maxChunk int64) *UploadService {
+	return &UploadService{
+		db:       database.GetDB(),
+		storage:  storage,
+		envelope: envelope,
+		hasher:   crypto.NewHashService(),
+		ttl:      ttl,
+		maxSize:  maxSize,
+		maxChunk: maxChunk,
+	}
+}
+
+// MaxChunkSize returns the maximum size of a chunk in bytes
+func (s *UploadService) MaxChunkSize() int64 {
+	return s.maxChunk
+}
+
+// Create starts an upload session
+func (s *UploadService) Create(session *models.UploadSession) error {
+	if session.TotalSize > s.maxSize {
+		return ErrUploadTooLarge
+	}
+
+	session.Status = models.UploadPending
+	session.ReceivedSize = 0
+	session.ExpiresAt = time.Now().Add(s.ttl)
+
+	if err := s.db.Create(session).Error; err != nil {
+		return fmt.Errorf("failed to create upload session: %w", err)
+	}
+	return nil
+}
+
+// GetForUser retrieves an unexpired upload session of a user
+func (s *UploadService) GetForUser(userID, id uint) (*models.UploadSession, error) {
+	var session models.UploadSession
+	if err := s.db.Where("id = ? AND user_id = ? AND expires_at > ?", id, userID, time.Now()).
+		First(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	return &session, nil
+}
+
+// AppendChunk stores data received at offset, which must equal the size
+// received so far, and returns the updated session. Each chunk extends the
+// session's expiry.
+func (s *UploadService) AppendChunk(session *models.UploadSession, offset int64, data []byte) (*models.UploadSession, error) {
+	var updated models.UploadSession
+	var path string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the session so concurrent chunks can't both claim the offset
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&updated, session.ID).Error; err != nil {
+			return err
+		}
+		if updated.Status != models.UploadPending {
+			return ErrUploadNotPending
+		}
+		if offset != updated.ReceivedSize {
+			return ErrUploadOffsetMismatch
+		}
+		if offset+int64(len(data)) > updated.TotalSize {
+			return ErrUploadTooLarge
+		}
+
+		ciphertext, dataKey, keyVersion, err := s.envelope.Seal(data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt chunk: %w", err)
+		}
+
+		if path, err = s.storage.SaveChunk(updated.ID, offset, ciphertext); err != nil {
+			return err
+		}
+
+		chunk := &models.UploadChunk{
+			SessionID:  updated.ID,
+			Offset:     offset,
+			Size:       int64(len(data)),
+			Path:       path,
+			DataKey:    dataKey,
+			KeyVersion: keyVersion,
+		}
+		if err := tx.Create(chunk).Error; err != nil {
+			return err
+		}
+
+		updated.ReceivedSize += chunk.Size
+		updated.ExpiresAt = time.Now().Add(s.ttl)
+		return tx.Model(&updated).Updates(map[string]interface{}{
+			"received_size": updated.ReceivedSize,
+			"expires_at":    updated.ExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		if path != "" {
+			s.storage.Delete(path)
+		}
+		if errors.Is(err, ErrUploadNotPending) || errors.Is(err, ErrUploadOffsetMismatch) || errors.Is(err, ErrUploadTooLarge) {
+			return &updated, err
+		}
+		return nil, fmt.Errorf("failed to store upload chunk: %w", err)
+	}
+
+	return &updated, nil
+}
+
+// Assemble claims a fully received session for completion and returns the
+// decrypted file. The caller creates the document from it and then calls
+// Finish, or Release when creating the document fails.
+func (s *UploadService) Assemble(session *models.UploadSession) ([]byte, error) {
+	result := s.db.Model(&models.UploadSession{}).
+		Where("id = ? AND status = ? AND received_size = total_size", session.ID, models.UploadPending).
+		Update("status", models.UploadAssembling)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to claim upload session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if session.Status == models.UploadPending && session.ReceivedSize < session.TotalSize {
+			return nil, ErrUploadIncomplete
+		}
+		return nil, ErrUploadNotPending
+	}
+	session.Status = models.UploadAssembling
+
+	content, err := s.readChunks(session)
+	if err == nil && session.ExpectedHash != "" && s.hasher.SHA256(content) != session.ExpectedHash {
+		err = ErrUploadChecksum
+	}
+	if err != nil {
+		s.Release(session)
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// readChunks decrypts the chunks of a session and joins them in order
+func (s *UploadService) readChunks(session *models.UploadSession) ([]byte, error) {
+	var chunks []models.UploadChunk
+	if err := s.db.Where("session_id = ?", session.ID).Order("chunk_offset ASC").Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get upload chunks: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(int(session.TotalSize))
+	for _, chunk := range chunks {
+		if chunk.Offset != int64(buf.Len()) {
+			return nil, fmt.Errorf("upload chunk at offset %d is missing", buf.Len())
+		}
+
+		ciphertext, err := s.storage.Read(chunk.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upload chunk: %w", err)
+		}
+		data, err := s.envelope.Open(ciphertext, chunk.DataKey, chunk.KeyVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt upload chunk: %w", err)
+		}
+		buf.Write(data)
+	}
+
+	if int64(buf.Len()) != session.TotalSize {
+		return nil, ErrUploadIncomplete
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Release returns a session claimed by Assemble to pending so completion can
+// be retried
+func (s *UploadService) Release(session *models.UploadSession) {
+	if err := s.db.Model(session).Update("status", models.UploadPending).Error; err != nil {
+		log.Printf("Failed to release upload session %d: %v", session.ID, err)
+	}
+}
+
+// Finish marks a session as completed with the document it produced and
+// removes its chunks
+func (s *UploadService) Finish(session *models.UploadSession, documentID uint) error {
+	if err := s.db.Model(session).Updates(map[string]interface{}{
+		"status":      models.UploadCompleted,
+		"document_id": documentID,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to complete upload session: %w", err)
+	}
+
+	s.deleteChunks(session.ID)
+
+	return nil
+}
+
+// Abort cancels an upload session and removes its chunks
+func (s *UploadService) Abort(session *models.UploadSession) error {
+	result := s.db.Where("id = ? AND status = ?", session.ID, models.UploadPending).Delete(&models.UploadSession{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete upload session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadNotPending
+	}
+
+	s.deleteChunks(session.ID)
+
+	return nil
+}
+
+// deleteChunks removes the chunk records and files of a session
+func (s *UploadService) deleteChunks(sessionID uint) {
+	if err := s.db.Where("session_id = ?", sessionID).Delete(&models.UploadChunk{}).Error; err != nil {
+		log.Printf("Failed to delete chunks of upload session %d: %v", sessionID, err)
+		return
+	}
+	if err := s.storage.DeleteChunks(sessionID); err != nil {
+		log.Printf("Failed to delete chunk files of upload session %d: %v", sessionID, err)
+	}
+}
+
+// DeleteExpired removes expired sessions that aren't being completed along
+// with their chunks, and returns how many were removed
+func (s *UploadService) DeleteExpired() (int, error) {
+	var ids []uint
+	if err := s.db.Model(&models.UploadSession{}).
+		Where("expires_at < ? AND status <> ?", time.Now(), models.UploadAssembling).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to get expired upload sessions: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := s.db.Delete(&models.UploadSession{}, id).Error; err != nil {
+			log.Printf("Failed to delete upload session %d: %v", id, err)
+			continue
+		}
+		s.deleteChunks(id)
+	}
+
+	return len(ids), nil
+}
+
+// StartCleanup removes expired sessions on every interval in the background
+func (s *UploadService) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deleted, err := s.DeleteExpired()
+			if err != nil {
+				log.Printf("Upload cleanup failed: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Upload cleanup removed %d expired sessions", deleted)
+			}
+		}
+	}()
+}
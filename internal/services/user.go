@@ -48,8 +48,16 @@ func (s *UserService) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-// Create creates a new user
+// Create creates a new user. Users without an organization join the default
+// one.
 func (s *UserService) Create(user *models.User) error {
+	if user.OrganizationID == 0 {
+		organization, err := database.DefaultOrganization()
+		if err != nil {
+			return err
+		}
+		user.OrganizationID = organization.ID
+	}
 	if err := s.db.Create(user).Error; err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -167,6 +175,42 @@ func (s *UserService) RevokeRefreshToken(token string) error {
 	return nil
 }
 
+// RevokeAllRefreshTokens revokes every refresh token of a user
+func (s *UserService) RevokeAllRefreshTokens(userID uint) error {
+	if err := s.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND is_revoked = ?", userID, false).
+		Update("is_revoked", true).Error; err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// RequirePasswordChange makes a user choose a new password before doing
+// anything else and signs them out of every session
+func (s *UserService) RequirePasswordChange(userID uint) (*models.User, error) {
+	user, err := s.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.AuthSource != models.AuthSourceLocal {
+		return nil, ErrPasswordResetNotAllowed
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("must_change_password", true).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND is_revoked = ?", userID, false).
+			Update("is_revoked", true).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to require password change: %w", err)
+	}
+	return user, nil
+}
+
 // GetUsersByRole retrieves users by role
 func (s *UserService) GetUsersByRole(role models.Role) ([]models.User, error) {
 	var users []models.User
@@ -194,10 +238,21 @@ func (s *UserService) ActivateUser(userID uint) error {
 	return nil
 }
 
-// DeactivateUser deactivates a user account
+// DeactivateUser deactivates a user account and revokes its tokens, so the
+// user is signed out at once
 func (s *UserService) DeactivateUser(userID uint) error {
-	if err := s.db.Model(&models.User{}).Where("id = ?", userID).
-		Update("is_active", false).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"is_active":         false,
+			"tokens_revoked_at": tokenRevocationTime(),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND is_revoked = ?", userID, false).
+			Update("is_revoked", true).Error
+	})
+	if err != nil {
 		return fmt.Errorf("failed to deactivate user: %w", err)
 	}
 	return nil
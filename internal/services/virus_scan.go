@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/scanner"
+	"gorm.io/gorm"
+)
+
+const (
+	// scanBatchSize is the number of pending blobs scanned per batch
+	scanBatchSize = 20
+	// scanTimeout bounds the scan of a single blob
+	scanTimeout = 5 * time.Minute
+)
+
+// VirusScanService scans uploaded content for malware in the background.
+// Documents are pending until their content is scanned and can't be
+// downloaded meanwhile; infected content is moved to quarantine and its
+// uploaders are notified.
+type VirusScanService struct {
+	db                  *gorm.DB
+	documentService     *DocumentService
+	scanner             scanner.Scanner
+	notificationService *NotificationService
+	auditService        *AuditService
+	interval            time.Duration
+}
+
+// NewVirusScanService creates a new virus scan service checking for pending
+// content on every interval
+func NewVirusScanService(documentService *DocumentService, scanner scanner.Scanner, notificationService *NotificationService, auditService *AuditService, interval time.Duration) *VirusScanService {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	return &VirusScanService{
+		db:                  database.GetDB(),
+		documentService:     documentService,
+		scanner:             scanner,
+		notificationService: notificationService,
+		auditService:        auditService,
+		interval:            interval,
+	}
+}
+
+// Start scans pending content immediately and then on every interval in
+// the background
+func (s *VirusScanService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.ScanPending(); err != nil {
+				log.Printf("Virus scan failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ScanPending scans all blobs pending a scan, applies the results to their
+// documents and returns how many blobs were scanned. Blobs that fail to scan
+// stay pending and are retried on the next run.
+func (s *VirusScanService) ScanPending() (int, error) {
+	scanned := 0
+	lastID := uint(0)
+	for {
+		var blobs []models.Blob
+		if err := s.db.Where("scan_status = ? AND id > ?", models.ScanPending, lastID).
+			Order("id ASC").
+			Limit(scanBatchSize).
+			Find(&blobs).Error; err != nil {
+			return scanned, fmt.Errorf("failed to get pending blobs: %w", err)
+		}
+		if len(blobs) == 0 {
+			break
+		}
+
+		for i := range blobs {
+			lastID = blobs[i].ID
+			if err := s.scanBlob(&blobs[i]); err != nil {
+				log.Printf("Failed to scan blob %s: %v", blobs[i].Hash, err)
+				continue
+			}
+			scanned++
+		}
+	}
+
+	return scanned, s.updateDocuments()
+}
+
+// scanBlob scans the content of a blob and records the result, moving
+// infected content to quarantine
+func (s *VirusScanService) scanBlob(blob *models.Blob) error {
+	content, err := s.documentService.readEncrypted(blob.Path, blob.DataKey, blob.KeyVersion)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+	defer cancel()
+
+	result, err := s.scanner.Scan(ctx, content)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if !result.Infected {
+		return s.db.Model(blob).Updates(map[string]interface{}{
+			"scan_status": models.ScanClean,
+			"scanned_at":  now,
+		}).Error
+	}
+
+	path, err := s.documentService.storage.Quarantine(blob.Path)
+	if err != nil {
+		return err
+	}
+
+	return database.AsSystem(s.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(blob).Updates(map[string]interface{}{
+			"path":        path,
+			"scan_status": models.ScanInfected,
+			"signature":   result.Signature,
+			"scanned_at":  now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.DocumentVersion{}).Unscoped().
+			Where("file_hash = ?", blob.Hash).
+			UpdateColumn("file_path", path).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Document{}).Unscoped().
+			Where("file_hash = ?", blob.Hash).
+			UpdateColumn("file_path", path).Error
+	})
+}
+
+// scannedDocument is a pending document whose content has been scanned
+type scannedDocument struct {
+	ID         uint
+	Title      string
+	FileName   string
+	FileHash   string
+	ScanStatus models.ScanStatus
+	Signature  string
+	UploadedBy uint
+}
+
+// updateDocuments gives pending documents the scan status of their content
+// once it has been scanned, and reports documents found infected. Documents
+// are updated separately from blobs so a document stored while its content
+// was being scanned doesn't stay pending. Scans run in the background, as
+// the system.
+func (s *VirusScanService) updateDocuments() error {
+	var documents []scannedDocument
+	if err := database.AsSystem(s.db).Raw(`
+		UPDATE documents AS d
+		SET scan_status = b.scan_status, updated_at = NOW()
+		FROM blobs AS b
+		WHERE d.file_hash = b.hash AND d.scan_status = ? AND b.scan_status <> ?
+		RETURNING d.id, d.title, d.file_name, d.file_hash, d.scan_status, b.signature,
+			COALESCE((SELECT v.created_by FROM document_versions AS v
+				WHERE v.document_id = d.id AND v.version = d.version LIMIT 1), d.created_by) AS uploaded_by`,
+		models.ScanPending, models.ScanPending).Scan(&documents).Error; err != nil {
+		return fmt.Errorf("failed to update document scan status: %w", err)
+	}
+
+	for i := range documents {
+		if documents[i].ScanStatus == models.ScanInfected {
+			s.reportInfected(&documents[i])
+		}
+	}
+
+	return nil
+}
+
+// reportInfected notifies the uploader of an infected document and records a
+// security event
+func (s *VirusScanService) reportInfected(doc *scannedDocument) {
+	log.Printf("Malware %s detected in document %d; content quarantined", doc.Signature, doc.ID)
+
+	documentID := doc.ID
+	if err := s.notificationService.Notify([]models.Notification{{
+		UserID:     doc.UploadedBy,
+		Type:       models.NotificationMalwareDetected,
+		DocumentID: &documentID,
+		Message:    fmt.Sprintf("Malware (%s) was detected in %q; the file has been quarantined", doc.Signature, doc.Title),
+	}}); err != nil {
+		log.Printf("Failed to notify user %d of malware in document %d: %v", doc.UploadedBy, doc.ID, err)
+	}
+
+	s.auditService.LogAction(doc.UploadedBy, &documentID, "malware_detected", "document", strconv.Itoa(int(doc.ID)), "", "", map[string]interface{}{
+		"file_name": doc.FileName,
+		"file_hash": doc.FileHash,
+		"signature": doc.Signature,
+		"scanner":   s.scanner.Name(),
+	})
+}
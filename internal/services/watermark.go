@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/watermark"
+)
+
+// watermarkTimeout bounds stamping a single download
+const watermarkTimeout = time.Minute
+
+// WatermarkService stamps PDFs downloaded at the configured access levels
+// with the downloader, time and document so leaked copies can be traced
+type WatermarkService struct {
+	stamper watermark.Stamper
+	levels  map[models.AccessLevel]bool
+}
+
+// NewWatermarkService creates a new watermark service for documents at the
+// given access levels. stamper may be nil when watermarking is disabled.
+func NewWatermarkService(stamper watermark.Stamper, levels []models.AccessLevel) *WatermarkService {
+	set := make(map[models.AccessLevel]bool, len(levels))
+	for _, level := range levels {
+		set[level] = true
+	}
+
+	return &WatermarkService{
+		stamper: stamper,
+		levels:  set,
+	}
+}
+
+// Available reports whether documents can be watermarked at all
+func (s *WatermarkService) Available() bool {
+	return s.stamper != nil
+}
+
+// Applies reports whether downloads of the document must be watermarked
+func (s *WatermarkService) Applies(doc *models.Document) bool {
+	return s.stamper != nil && s.levels[doc.AccessLevel]
+}
+
+// Stamp watermarks a PDF of the document downloaded by user
+func (s *WatermarkService) Stamp(pdf []byte, doc *models.Document, user *models.User) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), watermarkTimeout)
+	defer cancel()
+
+	lines := []string{
+		user.Username,
+		time.Now().UTC().Format("2006-01-02 15:04:05 UTC"),
+		fmt.Sprintf("Document %d", doc.ID),
+	}
+
+	stamped, err := s.stamper.Stamp(ctx, pdf, lines)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watermark document: %w", err)
+	}
+	return stamped, nil
+}
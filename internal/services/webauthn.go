@@ -0,0 +1,454 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/webauthn"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrWebAuthnChallenge is returned for unknown, used or expired challenges
+	ErrWebAuthnChallenge = errors.New("webauthn challenge is invalid or expired")
+	// ErrWebAuthnCredentialNotFound is returned for unknown credentials
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+	// ErrWebAuthnCredentialExists is returned when a credential is registered twice
+	ErrWebAuthnCredentialExists = errors.New("webauthn credential is already registered")
+	// ErrWebAuthnVerification is returned when a ceremony's response doesn't verify
+	ErrWebAuthnVerification = errors.New("webauthn verification failed")
+)
+
+// WebAuthnCredentialDescriptor identifies a credential to the browser
+type WebAuthnCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// WebAuthnCreationOptions are the options of navigator.credentials.create,
+// with binary values base64url encoded
+type WebAuthnCreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// WebAuthnCredentialParameter is a credential type and algorithm offered to authenticators
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// WebAuthnRequestOptions are the options of navigator.credentials.get,
+// with binary values base64url encoded
+type WebAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	Timeout          int64                          `json:"timeout"`
+	RPID             string                         `json:"rpId"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// WebAuthnAssertion is a browser's response to an authentication, decoded
+type WebAuthnAssertion struct {
+	CredentialID      string
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte // Empty unless a discoverable credential was used
+}
+
+// WebAuthnLogin is the outcome of an authentication
+type WebAuthnLogin struct {
+	UserID     uint
+	Ceremony   string
+	Credential *models.WebAuthnCredential
+}
+
+// WebAuthnService registers security keys and passkeys and verifies logins
+// with them, either confirming a password or, for passkeys that verify the
+// user, on their own
+type WebAuthnService struct {
+	db      *gorm.DB
+	rp      webauthn.RelyingParty
+	timeout time.Duration
+}
+
+// NewWebAuthnService creates a new WebAuthn service whose challenges expire
+// after timeout
+func NewWebAuthnService(rp webauthn.RelyingParty, timeout time.Duration) *WebAuthnService {
+	return &WebAuthnService{
+		db:      database.GetDB(),
+		rp:      rp,
+		timeout: timeout,
+	}
+}
+
+// userHandle is the user ID given to authenticators
+func userHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+// BeginRegistration starts registering a credential for a user
+func (s *WebAuthnService) BeginRegistration(user *models.User) (*WebAuthnCreationOptions, error) {
+	challenge, err := s.newChallenge(models.WebAuthnRegistration, &user.ID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.descriptors(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &WebAuthnCreationOptions{
+		Challenge:          challenge,
+		Timeout:            s.timeout.Milliseconds(),
+		ExcludeCredentials: existing,
+		Attestation:        "none",
+	}
+	options.RP.ID = s.rp.ID
+	options.RP.Name = s.rp.Name
+	options.User.ID = webauthn.EncodeBase64(userHandle(user.ID))
+	options.User.Name = user.Username
+	options.User.DisplayName = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if options.User.DisplayName == "" {
+		options.User.DisplayName = user.Username
+	}
+	for _, algorithm := range webauthn.SupportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, WebAuthnCredentialParameter{Type: "public-key", Alg: algorithm})
+	}
+	options.AuthenticatorSelection.ResidentKey = "preferred"
+	options.AuthenticatorSelection.UserVerification = "preferred"
+	return options, nil
+}
+
+// FinishRegistration verifies the browser's response to a registration and
+// stores the credential
+func (s *WebAuthnService) FinishRegistration(user *models.User, name string, clientDataJSON, attestationObject []byte, transports []string) (*models.WebAuthnCredential, error) {
+	challenge, err := s.consumeClientChallenge(clientDataJSON, models.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID == nil || *challenge.UserID != user.ID {
+		return nil, ErrWebAuthnChallenge
+	}
+
+	raw, err := webauthn.DecodeBase64(challenge.Challenge)
+	if err != nil {
+		return nil, ErrWebAuthnChallenge
+	}
+	verified, err := s.rp.VerifyRegistration(raw, clientDataJSON, attestationObject, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebAuthnVerification, err)
+	}
+
+	credentialID := webauthn.EncodeBase64(verified.ID)
+	var existing int64
+	if err := s.db.Model(&models.WebAuthnCredential{}).Where("credential_id = ?", credentialID).Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to check credentials: %w", err)
+	}
+	if existing > 0 {
+		return nil, ErrWebAuthnCredentialExists
+	}
+
+	if name == "" {
+		name = "Security key"
+	}
+	credential := &models.WebAuthnCredential{
+		UserID:       user.ID,
+		CredentialID: credentialID,
+		PublicKey:    verified.PublicKey,
+		SignCount:    verified.SignCount,
+		AAGUID:       formatAAGUID(verified.AAGUID),
+		Name:         name,
+		Transports:   strings.Join(transports, ","),
+	}
+	if err := s.db.Create(credential).Error; err != nil {
+		return nil, fmt.Errorf("failed to save credential: %w", err)
+	}
+	return credential, nil
+}
+
+// formatAAGUID formats an authenticator model ID as a UUID
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(aaguid)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// HasCredentials reports whether a user registered any credential
+func (s *WebAuthnService) HasCredentials(userID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to count credentials: %w", err)
+	}
+	return count > 0, nil
+}
+
+// BeginSecondFactor starts confirming a verified password with one of the
+// user's credentials
+func (s *WebAuthnService) BeginSecondFactor(user *models.User) (*WebAuthnRequestOptions, error) {
+	return s.beginUserChallenge(models.WebAuthnSecondFactor, user)
+}
+
+// BeginStepUp starts authenticating a signed-in user again with one of
+// their credentials
+func (s *WebAuthnService) BeginStepUp(user *models.User) (*WebAuthnRequestOptions, error) {
+	return s.beginUserChallenge(models.WebAuthnStepUp, user)
+}
+
+// beginUserChallenge starts a ceremony that one of the user's credentials
+// must answer
+func (s *WebAuthnService) beginUserChallenge(ceremony string, user *models.User) (*WebAuthnRequestOptions, error) {
+	challenge, err := s.newChallenge(ceremony, &user.ID)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := s.descriptors(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthnRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.timeout.Milliseconds(),
+		RPID:             s.rp.ID,
+		AllowCredentials: allowed,
+		UserVerification: "preferred",
+	}, nil
+}
+
+// BeginPasswordless starts a login with a passkey, which identifies the user
+func (s *WebAuthnService) BeginPasswordless() (*WebAuthnRequestOptions, error) {
+	challenge, err := s.newChallenge(models.WebAuthnPasswordless, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthnRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.timeout.Milliseconds(),
+		RPID:             s.rp.ID,
+		AllowCredentials: []WebAuthnCredentialDescriptor{},
+		UserVerification: "required",
+	}, nil
+}
+
+// FinishLogin verifies the browser's response to a second factor or
+// passwordless login. Passwordless logins require the authenticator to have
+// verified the user. The login is returned along with verification errors
+// once the user is known, so failed attempts can be counted.
+func (s *WebAuthnService) FinishLogin(assertion *WebAuthnAssertion) (*WebAuthnLogin, error) {
+	return s.verifyAssertion(assertion, models.WebAuthnSecondFactor, models.WebAuthnPasswordless)
+}
+
+// FinishStepUp verifies the browser's response to a step-up of userID. As
+// with FinishLogin, the login is returned along with verification errors.
+func (s *WebAuthnService) FinishStepUp(userID uint, assertion *WebAuthnAssertion) (*WebAuthnLogin, error) {
+	login, err := s.verifyAssertion(assertion, models.WebAuthnStepUp)
+	if login != nil && login.UserID != userID {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	return login, err
+}
+
+// verifyAssertion verifies an assertion answering a challenge of one of the
+// ceremonies and records the use of the credential
+func (s *WebAuthnService) verifyAssertion(assertion *WebAuthnAssertion, ceremonies ...string) (*WebAuthnLogin, error) {
+	challenge, err := s.consumeClientChallenge(assertion.ClientDataJSON, ceremonies...)
+	if err != nil {
+		return nil, err
+	}
+
+	var credential models.WebAuthnCredential
+	if err := s.db.Where("credential_id = ?", assertion.CredentialID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnCredentialNotFound
+		}
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+
+	login := &WebAuthnLogin{UserID: credential.UserID, Ceremony: challenge.Ceremony, Credential: &credential}
+	if challenge.UserID != nil && *challenge.UserID != credential.UserID {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	if len(assertion.UserHandle) > 0 && string(assertion.UserHandle) != string(userHandle(credential.UserID)) {
+		return login, fmt.Errorf("%w: user handle does not match the credential", ErrWebAuthnVerification)
+	}
+
+	raw, err := webauthn.DecodeBase64(challenge.Challenge)
+	if err != nil {
+		return nil, ErrWebAuthnChallenge
+	}
+	verified, err := s.rp.VerifyAssertion(raw, credential.PublicKey, credential.SignCount,
+		assertion.ClientDataJSON, assertion.AuthenticatorData, assertion.Signature,
+		challenge.Ceremony == models.WebAuthnPasswordless)
+	if err != nil {
+		return login, fmt.Errorf("%w: %v", ErrWebAuthnVerification, err)
+	}
+
+	now := time.Now()
+	credential.SignCount = verified.SignCount
+	credential.LastUsedAt = &now
+	if err := s.db.Model(&credential).Select("sign_count", "last_used_at").Updates(&credential).Error; err != nil {
+		return nil, fmt.Errorf("failed to update credential: %w", err)
+	}
+	return login, nil
+}
+
+// CancelSecondFactor gives up a pending second factor challenge, such as
+// when a recovery code is used instead, and returns the user it was for
+func (s *WebAuthnService) CancelSecondFactor(challenge string) (uint, error) {
+	pending, err := s.consumeChallenge(challenge, models.WebAuthnSecondFactor)
+	if err != nil {
+		return 0, err
+	}
+	if pending.UserID == nil {
+		return 0, ErrWebAuthnChallenge
+	}
+	return *pending.UserID, nil
+}
+
+// GetCredentials retrieves the credentials of a user
+func (s *WebAuthnService) GetCredentials(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	if err := s.db.Where("user_id = ?", userID).Order("id ASC").Find(&credentials).Error; err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// RenameCredential changes the name of a user's credential
+func (s *WebAuthnService) RenameCredential(userID, id uint, name string) (*models.WebAuthnCredential, error) {
+	credential, err := s.credential(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	credential.Name = name
+	if err := s.db.Model(credential).Update("name", name).Error; err != nil {
+		return nil, fmt.Errorf("failed to rename credential: %w", err)
+	}
+	return credential, nil
+}
+
+// DeleteCredential removes a user's credential. Without credentials left,
+// password logins no longer need a second factor.
+func (s *WebAuthnService) DeleteCredential(userID, id uint) (*models.WebAuthnCredential, error) {
+	credential, err := s.credential(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Delete(credential).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return credential, nil
+}
+
+// credential retrieves a credential of a user
+func (s *WebAuthnService) credential(userID, id uint) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := s.db.Where("user_id = ?", userID).First(&credential, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnCredentialNotFound
+		}
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return &credential, nil
+}
+
+// descriptors lists a user's credentials for the browser
+func (s *WebAuthnService) descriptors(userID uint) ([]WebAuthnCredentialDescriptor, error) {
+	credentials, err := s.GetCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	descriptors := make([]WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptor := WebAuthnCredentialDescriptor{Type: "public-key", ID: credential.CredentialID}
+		if credential.Transports != "" {
+			descriptor.Transports = strings.Split(credential.Transports, ",")
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors, nil
+}
+
+// newChallenge stores a challenge for a ceremony and returns it encoded.
+// Expired challenges are cleaned up on the way.
+func (s *WebAuthnService) newChallenge(ceremony string, userID *uint) (string, error) {
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.WebAuthnChallenge{}).Error; err != nil {
+		return "", fmt.Errorf("failed to delete expired challenges: %w", err)
+	}
+
+	raw, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+	challenge := &models.WebAuthnChallenge{
+		Challenge: webauthn.EncodeBase64(raw),
+		Ceremony:  ceremony,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.timeout),
+	}
+	if err := s.db.Create(challenge).Error; err != nil {
+		return "", fmt.Errorf("failed to save challenge: %w", err)
+	}
+	return challenge.Challenge, nil
+}
+
+// consumeClientChallenge looks up and removes the challenge answered by
+// client data, which must be for one of the ceremonies
+func (s *WebAuthnService) consumeClientChallenge(clientDataJSON []byte, ceremonies ...string) (*models.WebAuthnChallenge, error) {
+	raw, err := webauthn.ClientChallenge(clientDataJSON)
+	if err != nil {
+		return nil, ErrWebAuthnChallenge
+	}
+	return s.consumeChallenge(webauthn.EncodeBase64(raw), ceremonies...)
+}
+
+// consumeChallenge looks up and removes an encoded challenge, which must be
+// for one of the ceremonies
+func (s *WebAuthnService) consumeChallenge(encoded string, ceremonies ...string) (*models.WebAuthnChallenge, error) {
+	var challenge models.WebAuthnChallenge
+	if err := s.db.Where("challenge = ? AND ceremony IN ? AND expires_at > ?", encoded, ceremonies, time.Now()).
+		First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnChallenge
+		}
+		return nil, fmt.Errorf("failed to get challenge: %w", err)
+	}
+
+	// Deleting it only once makes concurrent answers fail
+	result := s.db.Delete(&challenge)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to delete challenge: %w", result.Error)
+	}
+	if result.RowsAffected != 1 {
+		return nil, ErrWebAuthnChallenge
+	}
+	return &challenge, nil
+}
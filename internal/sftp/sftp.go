@@ -0,0 +1,434 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP version 3 packet types (draft-ietf-secsh-filexfer-02)
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRemove   = 13
+	fxpRename   = 18
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpVersion3 = 3
+)
+
+// Status codes and attribute flags
+const (
+	statusOK  = 0
+	statusEOF = 1
+
+	attrSize        = 0x00000001
+	attrUIDGID      = 0x00000002
+	attrPermissions = 0x00000004
+	attrACModTime   = 0x00000008
+	attrExtended    = 0x80000000
+
+	openRead = 0x00000001
+)
+
+const (
+	// readChunkSize is the amount requested per read; servers commonly cap reads at 32 KiB
+	readChunkSize = 32 * 1024
+	// maxPacketSize bounds the packets accepted from the server
+	maxPacketSize = 256 * 1024
+)
+
+// ErrFileTooLarge is returned when a file exceeds the size limit of a read
+var ErrFileTooLarge = errors.New("file exceeds the maximum size")
+
+// StatusError is a failure reported by the server
+type StatusError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sftp status %d: %s", e.Code, e.Message)
+}
+
+// FileInfo describes a directory entry
+type FileInfo struct {
+	Name    string
+	Size    int64
+	Mode    uint32 // Unix mode including the file type bits
+	ModTime time.Time
+}
+
+// IsDir reports whether the entry is a directory
+func (f *FileInfo) IsDir() bool {
+	return f.Mode&0170000 == 0040000
+}
+
+// IsRegular reports whether the entry is a regular file
+func (f *FileInfo) IsRegular() bool {
+	return f.Mode&0170000 == 0100000
+}
+
+// Client is a minimal SFTP version 3 client that lists, reads, renames and
+// removes files. Requests are sent one at a time.
+type Client struct {
+	mu      sync.Mutex
+	conn    *ssh.Client
+	session *ssh.Session
+	in      io.WriteCloser
+	out     io.Reader
+	nextID  uint32
+}
+
+// Dial connects to an SSH server and starts its SFTP subsystem
+func Dial(addr string, config *ssh.ClientConfig) (*Client, error) {
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	client, err := newClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// newClient starts the SFTP subsystem on a connection and negotiates the version
+func newClient(conn *ssh.Client) (*Client, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session: %w", err)
+	}
+	in, err := session.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session input: %w", err)
+	}
+	out, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session output: %w", err)
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return nil, fmt.Errorf("failed to start sftp subsystem: %w", err)
+	}
+
+	c := &Client{conn: conn, session: session, in: in, out: out}
+
+	init := binary.BigEndian.AppendUint32(nil, fxpVersion3)
+	if err := c.writePacket(fxpInit, init); err != nil {
+		return nil, err
+	}
+	typ, data, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if typ != fxpVersion || len(data) < 4 {
+		return nil, fmt.Errorf("unexpected sftp packet %d during initialization", typ)
+	}
+	if version := binary.BigEndian.Uint32(data); version < fxpVersion3 {
+		return nil, fmt.Errorf("unsupported sftp version %d", version)
+	}
+	return c, nil
+}
+
+// Close ends the SFTP session and the connection
+func (c *Client) Close() error {
+	c.session.Close()
+	return c.conn.Close()
+}
+
+// ReadDir lists a directory, without its "." and ".." entries
+func (c *Client) ReadDir(path string) ([]FileInfo, error) {
+	handle, err := c.openHandle(fxpOpendir, appendString(nil, path))
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(handle)
+
+	var entries []FileInfo
+	for {
+		typ, data, err := c.request(fxpReaddir, appendString(nil, handle))
+		if err != nil {
+			return nil, err
+		}
+		if typ == fxpStatus {
+			if err := statusError(data); err != nil && !isEOF(err) {
+				return nil, err
+			}
+			return entries, nil
+		}
+		if typ != fxpName {
+			return nil, fmt.Errorf("unexpected sftp packet %d listing %s", typ, path)
+		}
+
+		d := decoder(data)
+		count := d.uint32()
+		// A listing without entries would be requested again forever
+		if d.err == nil && count == 0 {
+			return nil, fmt.Errorf("empty sftp listing of %s", path)
+		}
+		for i := uint32(0); i < count && d.err == nil; i++ {
+			name := d.string()
+			d.string() // long name
+			info := d.attrs()
+			info.Name = name
+			if name != "." && name != ".." {
+				entries = append(entries, info)
+			}
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+	}
+}
+
+// ReadFile reads a whole file, failing with ErrFileTooLarge beyond maxSize bytes
+func (c *Client) ReadFile(path string, maxSize int64) ([]byte, error) {
+	payload := appendString(nil, path)
+	payload = binary.BigEndian.AppendUint32(payload, openRead)
+	payload = binary.BigEndian.AppendUint32(payload, 0) // No attributes
+	handle, err := c.openHandle(fxpOpen, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(handle)
+
+	var content []byte
+	for {
+		payload := appendString(nil, handle)
+		payload = binary.BigEndian.AppendUint64(payload, uint64(len(content)))
+		payload = binary.BigEndian.AppendUint32(payload, readChunkSize)
+		typ, data, err := c.request(fxpRead, payload)
+		if err != nil {
+			return nil, err
+		}
+		if typ == fxpStatus {
+			if err := statusError(data); err != nil && !isEOF(err) {
+				return nil, err
+			}
+			return content, nil
+		}
+		if typ != fxpData {
+			return nil, fmt.Errorf("unexpected sftp packet %d reading %s", typ, path)
+		}
+
+		d := decoder(data)
+		chunk := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		// So would a read without data
+		if len(chunk) == 0 {
+			return nil, fmt.Errorf("empty sftp read of %s", path)
+		}
+		if int64(len(content)+len(chunk)) > maxSize {
+			return nil, ErrFileTooLarge
+		}
+		content = append(content, chunk...)
+	}
+}
+
+// Rename moves a file; the target must not exist
+func (c *Client) Rename(oldPath, newPath string) error {
+	return c.simple(fxpRename, appendString(appendString(nil, oldPath), newPath))
+}
+
+// Remove deletes a file
+func (c *Client) Remove(path string) error {
+	return c.simple(fxpRemove, appendString(nil, path))
+}
+
+// simple sends a request answered by a status only
+func (c *Client) simple(typ byte, payload []byte) error {
+	respType, data, err := c.request(typ, payload)
+	if err != nil {
+		return err
+	}
+	if respType != fxpStatus {
+		return fmt.Errorf("unexpected sftp packet %d", respType)
+	}
+	return statusError(data)
+}
+
+// openHandle sends a request answered by a file or directory handle
+func (c *Client) openHandle(typ byte, payload []byte) (string, error) {
+	respType, data, err := c.request(typ, payload)
+	if err != nil {
+		return "", err
+	}
+	switch respType {
+	case fxpHandle:
+		d := decoder(data)
+		handle := d.string()
+		return handle, d.err
+	case fxpStatus:
+		if err := statusError(data); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("unexpected sftp packet %d", respType)
+}
+
+// closeHandle releases a handle, ignoring failures
+func (c *Client) closeHandle(handle string) {
+	c.simple(fxpClose, appendString(nil, handle))
+}
+
+// request sends a request and returns the type and payload of its
+// response after the request ID
+func (c *Client) request(typ byte, payload []byte) (byte, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	id := c.nextID
+	if err := c.writePacket(typ, append(binary.BigEndian.AppendUint32(nil, id), payload...)); err != nil {
+		return 0, nil, err
+	}
+
+	respType, data, err := c.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 4 || binary.BigEndian.Uint32(data) != id {
+		return 0, nil, fmt.Errorf("unexpected sftp response for request %d", id)
+	}
+	return respType, data[4:], nil
+}
+
+// writePacket sends a length-prefixed packet
+func (c *Client) writePacket(typ byte, payload []byte) error {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	packet = append(packet, typ)
+	packet = append(packet, payload...)
+	if _, err := c.in.Write(packet); err != nil {
+		return fmt.Errorf("failed to send sftp packet: %w", err)
+	}
+	return nil
+}
+
+// readPacket receives a length-prefixed packet
+func (c *Client) readPacket() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.out, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("failed to receive sftp packet: %w", err)
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > maxPacketSize {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", length)
+	}
+
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(c.out, data); err != nil {
+		return 0, nil, fmt.Errorf("failed to receive sftp packet: %w", err)
+	}
+	return header[4], data, nil
+}
+
+// statusError returns the error of a status response, nil for success
+func statusError(data []byte) error {
+	d := decoder(data)
+	code := d.uint32()
+	message := d.string()
+	if d.err != nil {
+		return d.err
+	}
+	if code == statusOK {
+		return nil
+	}
+	return &StatusError{Code: code, Message: message}
+}
+
+// isEOF reports whether err is the end-of-file status
+func isEOF(err error) bool {
+	var status *StatusError
+	return errors.As(err, &status) && status.Code == statusEOF
+}
+
+// appendString appends an SSH string
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// packetDecoder reads fields of a packet, keeping the first error
+type packetDecoder struct {
+	data []byte
+	err  error
+}
+
+func decoder(data []byte) *packetDecoder {
+	return &packetDecoder{data: data}
+}
+
+func (d *packetDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.data) < n {
+		d.err = errors.New("malformed sftp packet")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *packetDecoder) uint32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *packetDecoder) uint64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *packetDecoder) string() string {
+	return string(d.take(int(d.uint32())))
+}
+
+// attrs reads a file attributes structure
+func (d *packetDecoder) attrs() FileInfo {
+	var info FileInfo
+	flags := d.uint32()
+	if flags&attrSize != 0 {
+		info.Size = int64(d.uint64())
+	}
+	if flags&attrUIDGID != 0 {
+		d.uint32()
+		d.uint32()
+	}
+	if flags&attrPermissions != 0 {
+		info.Mode = d.uint32()
+	}
+	if flags&attrACModTime != 0 {
+		d.uint32() // access time
+		info.ModTime = time.Unix(int64(d.uint32()), 0)
+	}
+	if flags&attrExtended != 0 {
+		count := d.uint32()
+		for i := uint32(0); i < count && d.err == nil; i++ {
+			d.string()
+			d.string()
+		}
+	}
+	return info
+}
@@ -0,0 +1,251 @@
+package sftp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer serves the packets of a Client with the responses handle
+// returns, each a packet type and payload after the request ID
+func fakeServer(t *testing.T, handle func(typ byte, payload []byte) (byte, []byte)) *Client {
+	t.Helper()
+	requests, clientIn := io.Pipe()
+	clientOut, responses := io.Pipe()
+	t.Cleanup(func() {
+		clientIn.Close()
+		responses.Close()
+	})
+
+	go func() {
+		server := &Client{in: responses, out: requests}
+		for {
+			typ, data, err := server.readPacket()
+			if err != nil || len(data) < 4 {
+				return
+			}
+			respType, payload := handle(typ, data[4:])
+			if err := server.writePacket(respType, append(data[:4:4], payload...)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return &Client{in: clientIn, out: clientOut}
+}
+
+func status(code uint32, message string) (byte, []byte) {
+	payload := binary.BigEndian.AppendUint32(nil, code)
+	payload = appendString(payload, message)
+	return fxpStatus, appendString(payload, "en")
+}
+
+// name encodes a directory entry with size, permissions and times
+func name(filename string, size uint64, mode, mtime uint32) []byte {
+	b := appendString(nil, filename)
+	b = appendString(b, "-rw-r--r-- 1 user group "+filename)
+	b = binary.BigEndian.AppendUint32(b, attrSize|attrPermissions|attrACModTime)
+	b = binary.BigEndian.AppendUint64(b, size)
+	b = binary.BigEndian.AppendUint32(b, mode)
+	b = binary.BigEndian.AppendUint32(b, mtime)
+	return binary.BigEndian.AppendUint32(b, mtime)
+}
+
+func TestReadDir(t *testing.T) {
+	listed := false
+	c := fakeServer(t, func(typ byte, payload []byte) (byte, []byte) {
+		switch typ {
+		case fxpOpendir:
+			return fxpHandle, appendString(nil, "dir-handle")
+		case fxpReaddir:
+			if listed {
+				return status(statusEOF, "end of directory")
+			}
+			listed = true
+			entries := binary.BigEndian.AppendUint32(nil, 4)
+			entries = append(entries, name(".", 0, 0040755, 0)...)
+			entries = append(entries, name("..", 0, 0040755, 0)...)
+			entries = append(entries, name("report.pdf", 1234, 0100644, 1700000000)...)
+			entries = append(entries, name("archive", 0, 0040700, 1700000000)...)
+			return fxpName, entries
+		case fxpClose:
+			return status(statusOK, "")
+		}
+		return status(8, "unsupported")
+	})
+
+	entries, err := c.ReadDir("/incoming")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	file, dir := entries[0], entries[1]
+	if file.Name != "report.pdf" || file.Size != 1234 || !file.IsRegular() || !file.ModTime.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("file = %+v", file)
+	}
+	if dir.Name != "archive" || !dir.IsDir() || dir.IsRegular() {
+		t.Errorf("directory = %+v", dir)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	content := strings.Repeat("x", readChunkSize+10)
+	c := fakeServer(t, func(typ byte, payload []byte) (byte, []byte) {
+		switch typ {
+		case fxpOpen:
+			return fxpHandle, appendString(nil, "file-handle")
+		case fxpRead:
+			d := decoder(payload)
+			d.string()
+			offset, length := d.uint64(), d.uint32()
+			if offset >= uint64(len(content)) {
+				return status(statusEOF, "end of file")
+			}
+			end := min(offset+uint64(length), uint64(len(content)))
+			return fxpData, appendString(nil, content[offset:end])
+		case fxpClose:
+			return status(statusOK, "")
+		}
+		return status(8, "unsupported")
+	})
+
+	got, err := c.ReadFile("/incoming/report.pdf", int64(len(content)))
+	if err != nil || !bytes.Equal(got, []byte(content)) {
+		t.Fatalf("ReadFile = %d bytes, %v", len(got), err)
+	}
+	if _, err := c.ReadFile("/incoming/report.pdf", int64(len(content)-1)); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("ReadFile over the limit = %v, want %v", err, ErrFileTooLarge)
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	c := fakeServer(t, func(typ byte, payload []byte) (byte, []byte) {
+		if typ == fxpRemove {
+			return status(statusOK, "")
+		}
+		return status(2, "no such file")
+	})
+
+	if err := c.Remove("/incoming/a"); err != nil {
+		t.Errorf("Remove: %v", err)
+	}
+	err := c.Rename("/incoming/a", "/done/a")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != 2 || statusErr.Message != "no such file" {
+		t.Errorf("Rename = %v", err)
+	}
+	if _, err := c.ReadDir("/missing"); !errors.As(err, &statusErr) {
+		t.Errorf("ReadDir = %v", err)
+	}
+}
+
+func TestRejectsMalformedResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  func(typ byte, payload []byte) (byte, []byte)
+		readDir bool
+	}{
+		{"handle without a string", func(typ byte, payload []byte) (byte, []byte) {
+			return fxpHandle, []byte{0, 0, 0, 9}
+		}, true},
+		{"data instead of a handle", func(typ byte, payload []byte) (byte, []byte) {
+			return fxpData, appendString(nil, "x")
+		}, true},
+		{"truncated status", func(typ byte, payload []byte) (byte, []byte) {
+			return fxpStatus, []byte{0, 0}
+		}, true},
+		{"names beyond the packet", func(typ byte, payload []byte) (byte, []byte) {
+			if typ == fxpOpendir {
+				return fxpHandle, appendString(nil, "h")
+			}
+			if typ == fxpClose {
+				return status(statusOK, "")
+			}
+			return fxpName, binary.BigEndian.AppendUint32(nil, 2)
+		}, true},
+		{"empty listing", func(typ byte, payload []byte) (byte, []byte) {
+			if typ == fxpOpendir {
+				return fxpHandle, appendString(nil, "h")
+			}
+			if typ == fxpClose {
+				return status(statusOK, "")
+			}
+			return fxpName, binary.BigEndian.AppendUint32(nil, 0)
+		}, true},
+		{"empty read", func(typ byte, payload []byte) (byte, []byte) {
+			if typ == fxpOpen {
+				return fxpHandle, appendString(nil, "h")
+			}
+			if typ == fxpClose {
+				return status(statusOK, "")
+			}
+			return fxpData, appendString(nil, "")
+		}, false},
+		{"data length beyond the packet", func(typ byte, payload []byte) (byte, []byte) {
+			if typ == fxpOpen {
+				return fxpHandle, appendString(nil, "h")
+			}
+			if typ == fxpClose {
+				return status(statusOK, "")
+			}
+			return fxpData, []byte{0xff, 0xff, 0xff, 0xff}
+		}, false},
+	}
+	for _, tt := range tests {
+		c := fakeServer(t, tt.handle)
+		var err error
+		if tt.readDir {
+			_, err = c.ReadDir("/incoming")
+		} else {
+			_, err = c.ReadFile("/incoming/a", 1<<20)
+		}
+		if err == nil {
+			t.Errorf("%s: succeeded", tt.name)
+		}
+	}
+}
+
+func TestReadPacketRejectsInvalidLengths(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+	}{
+		{"empty packet", []byte{0, 0, 0, 0, fxpStatus}},
+		{"packet over the limit", append(binary.BigEndian.AppendUint32(nil, maxPacketSize+1), fxpData)},
+		{"truncated header", []byte{0, 0}},
+		{"truncated payload", []byte{0, 0, 0, 9, fxpStatus, 0}},
+	}
+	for _, tt := range tests {
+		c := &Client{out: bytes.NewReader(tt.packet)}
+		if _, _, err := c.readPacket(); err == nil {
+			t.Errorf("%s: readPacket succeeded", tt.name)
+		}
+	}
+}
+
+func TestRequestRejectsOtherRequestIDs(t *testing.T) {
+	requests, clientIn := io.Pipe()
+	clientOut, responses := io.Pipe()
+	defer clientIn.Close()
+	defer responses.Close()
+
+	go func() {
+		server := &Client{in: responses, out: requests}
+		if _, _, err := server.readPacket(); err != nil {
+			return
+		}
+		payload := binary.BigEndian.AppendUint32(nil, 42)
+		server.writePacket(fxpStatus, append(payload, 0, 0, 0, 0, 0, 0, 0, 0))
+	}()
+
+	c := &Client{in: clientIn, out: clientOut}
+	if err := c.Remove("/incoming/a"); err == nil {
+		t.Error("Remove accepted the response to another request")
+	}
+}
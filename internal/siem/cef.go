@@ -0,0 +1,79 @@
+package siem
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// CEF header fields identifying this system as the source of events
+const (
+	cefVendor  = "nshmdayo"
+	cefProduct = "In-house Data Management"
+	cefVersion = "1.0"
+)
+
+// cefHeaderEscaper escapes the pipes and backslashes of CEF header fields
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+
+// cefValueEscaper escapes the backslashes, equal signs and line breaks of CEF
+// extension values
+var cefValueEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+// FormatCEF formats an event in ArcSight Common Event Format. The action is
+// the signature ID and name; organization and document IDs are custom
+// number fields.
+func FormatCEF(event Event) string {
+	var b strings.Builder
+	b.WriteString("CEF:0|")
+	for _, field := range []string{cefVendor, cefProduct, cefVersion, event.Action, event.Action} {
+		b.WriteString(cefHeaderEscaper.Replace(field))
+		b.WriteByte('|')
+	}
+	b.WriteString(strconv.Itoa(event.Severity))
+	b.WriteByte('|')
+
+	extensions := [][2]string{
+		{"rt", strconv.FormatInt(event.Timestamp.UnixMilli(), 10)},
+		{"externalId", strconv.FormatUint(uint64(event.ID), 10)},
+		{"act", event.Action},
+		{"suid", strconv.FormatUint(uint64(event.UserID), 10)},
+		{"cn1Label", "organizationId"},
+		{"cn1", strconv.FormatUint(uint64(event.OrganizationID), 10)},
+	}
+	if event.DocumentID != nil {
+		extensions = append(extensions,
+			[2]string{"cn2Label", "documentId"},
+			[2]string{"cn2", strconv.FormatUint(uint64(*event.DocumentID), 10)})
+	}
+	if event.ResourceType != "" {
+		extensions = append(extensions,
+			[2]string{"cs1Label", "resourceType"},
+			[2]string{"cs1", event.ResourceType})
+	}
+	if event.ResourceID != "" {
+		extensions = append(extensions,
+			[2]string{"cs2Label", "resourceId"},
+			[2]string{"cs2", event.ResourceID})
+	}
+	// src must be an IP address
+	if net.ParseIP(event.IPAddress) != nil {
+		extensions = append(extensions, [2]string{"src", event.IPAddress})
+	}
+	if event.UserAgent != "" {
+		extensions = append(extensions, [2]string{"requestClientApplication", event.UserAgent})
+	}
+	if event.Details != "" {
+		extensions = append(extensions, [2]string{"msg", event.Details})
+	}
+
+	for i, extension := range extensions {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(extension[0])
+		b.WriteByte('=')
+		b.WriteString(cefValueEscaper.Replace(extension[1]))
+	}
+	return b.String()
+}
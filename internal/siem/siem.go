@@ -0,0 +1,52 @@
+package siem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/config"
+)
+
+// ErrRejected is returned when a SIEM refuses events as invalid, so sending
+// them again can't succeed
+var ErrRejected = errors.New("events rejected by the SIEM")
+
+// Event is an audit log entry as streamed to a SIEM
+type Event struct {
+	ID             uint      `json:"id"`
+	Timestamp      time.Time `json:"timestamp"`
+	OrganizationID uint      `json:"organization_id"`
+	UserID         uint      `json:"user_id"`
+	DocumentID     *uint     `json:"document_id,omitempty"`
+	Action         string    `json:"action"`
+	ResourceType   string    `json:"resource_type"`
+	ResourceID     string    `json:"resource_id,omitempty"`
+	IPAddress      string    `json:"ip_address,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	Details        string    `json:"details,omitempty"`
+	Severity       int       `json:"severity"` // 0 to 10 as in CEF
+}
+
+// Sink delivers audit events to a SIEM
+type Sink interface {
+	// Send delivers a batch of events in order
+	Send(ctx context.Context, events []Event) error
+	// Name returns the sink name for logging
+	Name() string
+}
+
+// NewSink creates the sink selected in the configuration
+func NewSink(cfg *config.Config) (Sink, error) {
+	switch cfg.SIEMSink {
+	case "syslog":
+		return NewSyslogSink(cfg.SIEMSyslogNetwork, cfg.SIEMSyslogAddress)
+	case "hec":
+		client := &http.Client{Timeout: 30 * time.Second}
+		return NewHECSink(client, cfg.SIEMHECURL, cfg.SIEMHECToken, cfg.SIEMHECIndex, cfg.SIEMHECSourceType)
+	default:
+		return nil, fmt.Errorf("unknown SIEM sink: %s", cfg.SIEMSink)
+	}
+}
@@ -0,0 +1,109 @@
+package siem
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// streamBatchSize is the largest number of events sent at once
+	streamBatchSize = 100
+	// streamFlushInterval is how long events wait for a batch to fill
+	streamFlushInterval = time.Second
+	// streamSendTimeout bounds each attempt to send a batch
+	streamSendTimeout = 30 * time.Second
+	// streamMaxBackoff is the longest wait between attempts while the SIEM
+	// is unreachable
+	streamMaxBackoff = time.Minute
+)
+
+// Streamer buffers events in memory and sends them to a sink in batches in
+// the background, retrying with backoff while the sink is unreachable.
+// Events published while the buffer is full are dropped and counted; they
+// remain in the audit log.
+type Streamer struct {
+	sink    Sink
+	events  chan Event
+	dropped atomic.Int64
+}
+
+// NewStreamer creates a new streamer buffering up to bufferSize events
+func NewStreamer(sink Sink, bufferSize int) *Streamer {
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+
+	return &Streamer{
+		sink:   sink,
+		events: make(chan Event, bufferSize),
+	}
+}
+
+// Publish queues an event without blocking
+func (s *Streamer) Publish(event Event) {
+	select {
+	case s.events <- event:
+	default:
+		if s.dropped.Add(1) == 1 {
+			log.Printf("SIEM buffer is full, dropping audit events until %s catches up", s.sink.Name())
+		}
+	}
+}
+
+// Start sends queued events in the background
+func (s *Streamer) Start() {
+	go func() {
+		ticker := time.NewTicker(streamFlushInterval)
+		defer ticker.Stop()
+
+		batch := make([]Event, 0, streamBatchSize)
+		for {
+			select {
+			case event := <-s.events:
+				batch = append(batch, event)
+				if len(batch) < streamBatchSize {
+					continue
+				}
+			case <-ticker.C:
+				if len(batch) == 0 {
+					continue
+				}
+			}
+			s.send(batch)
+			batch = batch[:0]
+		}
+	}()
+}
+
+// send delivers a batch, retrying until it is accepted or rejected
+func (s *Streamer) send(batch []Event) {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), streamSendTimeout)
+		err := s.sink.Send(ctx, batch)
+		cancel()
+
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("SIEM %s is reachable again after %d attempts", s.sink.Name(), attempt)
+			}
+			if dropped := s.dropped.Swap(0); dropped > 0 {
+				log.Printf("SIEM buffer was full, %d audit events were not streamed", dropped)
+			}
+			return
+		}
+		if errors.Is(err, ErrRejected) {
+			log.Printf("SIEM %s rejected %d audit events: %v", s.sink.Name(), len(batch), err)
+			return
+		}
+
+		if attempt == 1 {
+			log.Printf("Failed to stream audit events to SIEM %s, retrying: %v", s.sink.Name(), err)
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, streamMaxBackoff)
+	}
+}
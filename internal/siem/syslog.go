@@ -0,0 +1,117 @@
+package siem
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// syslogFacility is the syslog facility of audit events, local0
+const syslogFacility = 16
+
+// syslogAppName is the APP-NAME of syslog messages
+const syslogAppName = "datamanagement"
+
+// SyslogSink sends events as CEF messages in RFC 5424 syslog format over
+// UDP, TCP or TLS. Stream transports separate messages with newlines, which
+// CEF escapes inside messages.
+type SyslogSink struct {
+	network  string
+	address  string
+	hostname string
+	conn     net.Conn
+}
+
+// NewSyslogSink creates a new syslog sink. The connection is opened on the
+// first send and reopened after errors.
+func NewSyslogSink(network, address string) (*SyslogSink, error) {
+	switch network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("invalid syslog network: %s", network)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid syslog address: %w", err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogSink{
+		network:  network,
+		address:  address,
+		hostname: hostname,
+	}, nil
+}
+
+// Send writes each event as a syslog message
+func (s *SyslogSink) Send(ctx context.Context, events []Event) error {
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		s.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	for _, event := range events {
+		message := s.format(event)
+		if s.network != "udp" {
+			message += "\n"
+		}
+		if _, err := s.conn.Write([]byte(message)); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return fmt.Errorf("failed to write to syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+// Name returns the sink name
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+// dial opens a connection to the syslog server
+func (s *SyslogSink) dial(ctx context.Context) (net.Conn, error) {
+	if s.network == "tls" {
+		dialer := &tls.Dialer{Config: &tls.Config{MinVersion: tls.VersionTLS12}}
+		return dialer.DialContext(ctx, "tcp", s.address)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, s.network, s.address)
+}
+
+// format builds the RFC 5424 message of an event with the action as MSGID.
+// Security events are sent as warnings, others as notices.
+func (s *SyslogSink) format(event Event) string {
+	severity := 5
+	if event.Severity >= 7 {
+		severity = 4
+	}
+
+	msgID := event.Action
+	if msgID == "" || len(msgID) > 32 || strings.ContainsAny(msgID, " =]\"") {
+		msgID = "-"
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		syslogFacility*8+severity,
+		event.Timestamp.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		syslogAppName,
+		os.Getpid(),
+		msgID,
+		FormatCEF(event))
+}
@@ -0,0 +1,102 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// CA issues signing certificates and revocation lists with the key of a CA
+// certificate
+type CA struct {
+	signer *Signer
+}
+
+// LoadCA creates a CA from a PEM file holding its certificate, optionally
+// followed by its intermediates, and a PEM file holding the matching private
+// key. The certificate must be allowed to sign certificates and CRLs.
+func LoadCA(certPath, keyPath string) (*CA, error) {
+	signer, err := LoadSigner(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate := signer.certificates[0]
+	if !certificate.IsCA {
+		return nil, fmt.Errorf("CA certificate is not a CA")
+	}
+	if certificate.KeyUsage != 0 && certificate.KeyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != x509.KeyUsageCertSign|x509.KeyUsageCRLSign {
+		return nil, fmt.Errorf("CA certificate may not sign certificates and CRLs")
+	}
+
+	return &CA{signer: signer}, nil
+}
+
+// Certificates returns the CA's certificate followed by its intermediates
+func (ca *CA) Certificates() []*x509.Certificate {
+	return ca.signer.certificates
+}
+
+// Issue issues a certificate for signing documents to the holder of
+// publicKey, valid from notBefore until notAfter
+func (ca *CA) Issue(subject pkix.Name, email string, publicKey crypto.PublicKey, notBefore, notAfter time.Time) (*x509.Certificate, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               subject,
+		EmailAddresses:        []string{email},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		BasicConstraintsValid: true,
+	}
+
+	issuer := ca.signer.certificates[0]
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, publicKey, ca.signer.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate: %w", err)
+	}
+	return x509.ParseCertificate(der)
+}
+
+// RevocationList creates a DER CRL listing the revoked certificates, valid
+// from thisUpdate until nextUpdate
+func (ca *CA) RevocationList(revoked []x509.RevocationListEntry, number *big.Int, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificateEntries: revoked,
+		Number:                    number,
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                nextUpdate,
+	}, ca.signer.certificates[0], ca.signer.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CRL: %w", err)
+	}
+	return der, nil
+}
+
+// NewSigner creates a signer from a DER private key in any of the usual
+// encodings and its certificate, optionally followed by its intermediates
+func NewSigner(keyDER []byte, certificates []*x509.Certificate) (*Signer, error) {
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no signer certificate")
+	}
+
+	key, err := parsePrivateKey(keyDER)
+	if err != nil {
+		return nil, err
+	}
+	if !publicKeysEqual(key.Public(), certificates[0].PublicKey) {
+		return nil, fmt.Errorf("signing key doesn't match the signing certificate")
+	}
+
+	return &Signer{key: key, certificates: certificates}, nil
+}
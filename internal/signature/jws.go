@@ -0,0 +1,174 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// JWSHeader is the protected header of a JWS made by a Signer. X5C holds
+// the signer's certificate chain and IssuedAt when it was signed.
+type JWSHeader struct {
+	Algorithm   string   `json:"alg"`
+	Type        string   `json:"typ,omitempty"`
+	ContentType string   `json:"cty,omitempty"`
+	X5C         []string `json:"x5c"`
+	IssuedAt    int64    `json:"iat"`
+}
+
+// jwsAlgorithm returns the JWS algorithm of signatures made with a
+// certificate's key
+func jwsAlgorithm(certificate *x509.Certificate) (string, error) {
+	algorithm, err := Algorithm(certificate)
+	if err != nil {
+		return "", err
+	}
+
+	switch algorithm {
+	case x509.SHA256WithRSA:
+		return "RS256", nil
+	case x509.ECDSAWithSHA256:
+		// ES256 is defined for P-256 only
+		if certificate.PublicKey.(*ecdsa.PublicKey).Curve != elliptic.P256() {
+			return "", fmt.Errorf("JWS needs a P-256 key for ECDSA")
+		}
+		return "ES256", nil
+	default:
+		return "EdDSA", nil
+	}
+}
+
+// SignJWS signs payload as a JWS in compact serialization with the
+// signer's certificate chain in the header
+func (s *Signer) SignJWS(payload []byte, typ, contentType string, issuedAt time.Time) (string, error) {
+	algorithm, err := jwsAlgorithm(s.certificates[0])
+	if err != nil {
+		return "", err
+	}
+
+	header := JWSHeader{
+		Algorithm:   algorithm,
+		Type:        typ,
+		ContentType: contentType,
+		IssuedAt:    issuedAt.Unix(),
+	}
+	for _, certificate := range s.certificates {
+		header.X5C = append(header.X5C, base64.StdEncoding.EncodeToString(certificate.Raw))
+	}
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWS header: %w", err)
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, _, err := s.Sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+
+	if algorithm == "ES256" {
+		if sig, err = ecdsaRaw(sig); err != nil {
+			return "", err
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyJWS checks a JWS in compact serialization made by SignJWS and
+// returns its header, payload and signer certificates. When roots are given
+// the certificate must chain to one of them as of the time of signing.
+func VerifyJWS(token string, roots *x509.CertPool) (*JWSHeader, []byte, []*x509.Certificate, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, fmt.Errorf("JWS must have three parts")
+	}
+
+	encodedHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode JWS header: %w", err)
+	}
+	var header JWSHeader
+	if err := json.Unmarshal(encodedHeader, &header); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode JWS header: %w", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode JWS payload: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode JWS signature: %w", err)
+	}
+
+	if len(header.X5C) == 0 {
+		return nil, nil, nil, fmt.Errorf("JWS has no certificate chain")
+	}
+	certificates := make([]*x509.Certificate, 0, len(header.X5C))
+	for _, encoded := range header.X5C {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to decode JWS certificate: %w", err)
+		}
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to parse JWS certificate: %w", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	algorithm, err := jwsAlgorithm(certificates[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if header.Algorithm != algorithm {
+		return nil, nil, nil, ErrInvalidSignature
+	}
+	if algorithm == "ES256" {
+		if sig, err = ecdsaDER(sig); err != nil {
+			return nil, nil, nil, ErrInvalidSignature
+		}
+	}
+
+	input := parts[0] + "." + parts[1]
+	if err := Verify([]byte(input), sig, certificates, roots, time.Unix(header.IssuedAt, 0)); err != nil {
+		return nil, nil, nil, err
+	}
+	return &header, payload, certificates, nil
+}
+
+// ecdsaSignature is the ASN.1 form of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// ecdsaRaw converts an ASN.1 P-256 signature to the fixed size r || s form
+// of JWS
+func ecdsaRaw(der []byte) ([]byte, error) {
+	var sig ecdsaSignature
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+	}
+
+	raw := make([]byte, 64)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:])
+	return raw, nil
+}
+
+// ecdsaDER converts a JWS r || s P-256 signature to its ASN.1 form
+func ecdsaDER(raw []byte) ([]byte, error) {
+	if len(raw) != 64 {
+		return nil, fmt.Errorf("ES256 signatures are 64 bytes")
+	}
+	return asn1.Marshal(ecdsaSignature{
+		R: new(big.Int).SetBytes(raw[:32]),
+		S: new(big.Int).SetBytes(raw[32:]),
+	})
+}
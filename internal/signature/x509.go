@@ -0,0 +1,212 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Errors returned when checking a signature
+var (
+	// ErrInvalidSignature is returned when a signature doesn't verify with
+	// the signer's certificate
+	ErrInvalidSignature = errors.New("signature is invalid")
+	// ErrUntrustedCertificate is returned when the signer's certificate
+	// doesn't chain to a trusted CA certificate
+	ErrUntrustedCertificate = errors.New("signer certificate is not trusted")
+)
+
+// Signer signs content with the server's private key and X.509 certificate
+type Signer struct {
+	key          crypto.Signer
+	certificates []*x509.Certificate
+}
+
+// LoadSigner creates a signer from a PEM file holding the certificate,
+// optionally followed by its intermediates, and a PEM file holding the
+// matching PKCS #8, PKCS #1 or SEC 1 private key
+func LoadSigner(certPath, keyPath string) (*Signer, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing certificate: %w", err)
+	}
+	certificates, err := ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key is not PEM encoded")
+	}
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !publicKeysEqual(key.Public(), certificates[0].PublicKey) {
+		return nil, fmt.Errorf("signing key doesn't match the signing certificate")
+	}
+
+	return &Signer{key: key, certificates: certificates}, nil
+}
+
+// parsePrivateKey parses a DER private key in any of the usual encodings
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("signing key can't sign")
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("failed to parse signing key")
+}
+
+// publicKeysEqual reports whether two public keys are the same
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
+
+// Certificates returns the signer's certificate followed by its
+// intermediates
+func (s *Signer) Certificates() []*x509.Certificate {
+	return s.certificates
+}
+
+// Sign signs content, returning the signature and its algorithm
+func (s *Signer) Sign(content []byte) ([]byte, x509.SignatureAlgorithm, error) {
+	algorithm, err := Algorithm(s.certificates[0])
+	if err != nil {
+		return nil, x509.UnknownSignatureAlgorithm, err
+	}
+
+	var signature []byte
+	if algorithm == x509.PureEd25519 {
+		signature, err = s.key.Sign(rand.Reader, content, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(content)
+		signature, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, x509.UnknownSignatureAlgorithm, fmt.Errorf("failed to sign: %w", err)
+	}
+	return signature, algorithm, nil
+}
+
+// Algorithm returns the algorithm of signatures made with a certificate's
+// key: SHA-256 with RSA PKCS #1 v1.5 or ECDSA, or Ed25519. These are what
+// `openssl dgst -sha256 -sign` and `openssl pkeyutl -sign -rawin` produce.
+func Algorithm(certificate *x509.Certificate) (x509.SignatureAlgorithm, error) {
+	switch certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA, nil
+	case *ecdsa.PublicKey:
+		return x509.ECDSAWithSHA256, nil
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signer key type %T", certificate.PublicKey)
+}
+
+// Verify checks that signature was made over content with the key of the
+// first certificate, and when roots are given, that the certificate chains
+// to one of them through the others and was valid at the time
+func Verify(content, signature []byte, certificates []*x509.Certificate, roots *x509.CertPool, at time.Time) error {
+	if len(certificates) == 0 {
+		return fmt.Errorf("no signer certificate")
+	}
+	leaf := certificates[0]
+
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment) == 0 {
+		return fmt.Errorf("%w: certificate may not be used for signatures", ErrUntrustedCertificate)
+	}
+
+	algorithm, err := Algorithm(leaf)
+	if err != nil {
+		return err
+	}
+	if err := leaf.CheckSignature(algorithm, content, signature); err != nil {
+		return ErrInvalidSignature
+	}
+
+	if roots == nil {
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("%w: %v", ErrUntrustedCertificate, err)
+	}
+	return nil
+}
+
+// ParseCertificates parses the PEM certificates in data, in order
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+	return certificates, nil
+}
+
+// EncodeCertificates encodes certificates as PEM, in order
+func EncodeCertificates(certificates []*x509.Certificate) string {
+	var encoded strings.Builder
+	for _, certificate := range certificates {
+		encoded.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+	}
+	return encoded.String()
+}
+
+// Fingerprint returns the hex SHA-256 fingerprint of a certificate
+func Fingerprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	return fmt.Sprintf("%x", sum)
+}
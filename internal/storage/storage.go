@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/security/crypto"
+)
+
+// LocalStorage stores files on the local filesystem
+type LocalStorage struct {
+	basePath string
+}
+
+// NewLocalStorage creates a new local storage rooted at basePath
+func NewLocalStorage(basePath string) (*LocalStorage, error) {
+	if err := os.MkdirAll(basePath, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return &LocalStorage{
+		basePath: basePath,
+	}, nil
+}
+
+// SaveBlob writes the data of a blob with the given content hash and
+// returns its storage path. Blobs are spread over directories by hash prefix;
+// a random suffix keeps concurrent writers of the same content apart.
+func (s *LocalStorage) SaveBlob(hash string, data []byte) (string, error) {
+	if len(hash) < 4 || strings.ContainsAny(hash, `/\.`) {
+		return "", fmt.Errorf("invalid blob hash")
+	}
+
+	suffix, err := crypto.GenerateRandomString(8)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate file name: %w", err)
+	}
+
+	relPath := filepath.Join("blobs", hash[:2], hash[2:4], hash+"-"+suffix)
+	fullPath := filepath.Join(s.basePath, relPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// Write to a temporary file first so a blob is never read half-written
+	tmpPath := fullPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o640); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return relPath, nil
+}
+
+// SaveChunk writes a chunk of an upload session's file starting at offset
+// and returns its storage path
+func (s *LocalStorage) SaveChunk(sessionID uint, offset int64, data []byte) (string, error) {
+	relPath := filepath.Join("uploads", strconv.FormatUint(uint64(sessionID), 10), strconv.FormatInt(offset, 10))
+	fullPath := filepath.Join(s.basePath, relPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	if err := os.WriteFile(fullPath, data, 0o640); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return relPath, nil
+}
+
+// DeleteChunks removes all stored chunks of an upload session
+func (s *LocalStorage) DeleteChunks(sessionID uint) error {
+	fullPath := filepath.Join(s.basePath, "uploads", strconv.FormatUint(uint64(sessionID), 10))
+	if err := os.RemoveAll(fullPath); err != nil {
+		return fmt.Errorf("failed to delete upload chunks: %w", err)
+	}
+	return nil
+}
+
+// SaveExport writes the archive of an export job and returns its storage path
+func (s *LocalStorage) SaveExport(jobID uint, data []byte) (string, error) {
+	relPath := filepath.Join("exports", strconv.FormatUint(uint64(jobID), 10)+".zip")
+	fullPath := filepath.Join(s.basePath, relPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	if err := os.WriteFile(fullPath, data, 0o640); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return relPath, nil
+}
+
+// Quarantine moves a stored file into the quarantine directory and returns
+// its new storage path
+func (s *LocalStorage) Quarantine(path string) (string, error) {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	relPath := filepath.Join("quarantine", filepath.Base(fullPath))
+	quarantinePath := filepath.Join(s.basePath, relPath)
+
+	if err := os.MkdirAll(filepath.Dir(quarantinePath), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	if err := os.Rename(fullPath, quarantinePath); err != nil {
+		return "", fmt.Errorf("failed to quarantine file: %w", err)
+	}
+
+	return relPath, nil
+}
+
+// Read returns the contents of a stored file
+func (s *LocalStorage) Read(path string) ([]byte, error) {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, nil
+}
+
+// Delete removes a stored file
+func (s *LocalStorage) Delete(path string) error {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// resolve converts a storage path to an absolute path inside basePath
+func (s *LocalStorage) resolve(path string) (string, error) {
+	fullPath := filepath.Join(s.basePath, filepath.Clean("/"+path))
+	if !strings.HasPrefix(fullPath, filepath.Clean(s.basePath)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid storage path")
+	}
+	return fullPath, nil
+}
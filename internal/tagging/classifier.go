@@ -0,0 +1,106 @@
+package tagging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// maxClassifierInput is the maximum number of characters sent for classification
+const maxClassifierInput = 8000
+
+// ClassifierClient suggests tags and categories through an external
+// classification model. The service receives
+//
+//	{"model": "...", "text": "...", "tags": [...], "categories": [...]}
+//
+// where tags and categories list the names already in use, and responds with
+//
+//	{"tags": [{"name": "...", "score": 0.9}], "categories": [{"name": "...", "score": 0.8}]}
+type ClassifierClient struct {
+	client *http.Client
+	url    string
+	apiKey string
+	model  string
+}
+
+// NewClassifierClient creates a new classifier client
+func NewClassifierClient(url, apiKey, model string) *ClassifierClient {
+	return &ClassifierClient{
+		client: &http.Client{Timeout: 30 * time.Second},
+		url:    url,
+		apiKey: apiKey,
+		model:  model,
+	}
+}
+
+// Name identifies the tagger
+func (c *ClassifierClient) Name() string {
+	return "model:" + c.model
+}
+
+// Suggest sends the input to the classification service
+func (c *ClassifierClient) Suggest(ctx context.Context, input *Input) ([]Suggestion, error) {
+	text := input.Text
+	if runes := []rune(text); len(runes) > maxClassifierInput {
+		text = string(runes[:maxClassifierInput])
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"model":      c.model,
+		"text":       text,
+		"tags":       input.Tags,
+		"categories": input.Categories,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal classification request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create classification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach classification service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("classification service returned status %d: %s", resp.StatusCode, message)
+	}
+
+	type label struct {
+		Name  string  `json:"name"`
+		Score float64 `json:"score"`
+	}
+	var result struct {
+		Tags       []label `json:"tags"`
+		Categories []label `json:"categories"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode classification response: %w", err)
+	}
+
+	suggestions := make([]Suggestion, 0, len(result.Tags)+len(result.Categories))
+	for _, tag := range result.Tags {
+		suggestions = append(suggestions, Suggestion{Kind: models.SuggestionTag, Value: tag.Name, Score: tag.Score})
+	}
+	for _, category := range result.Categories {
+		suggestions = append(suggestions, Suggestion{Kind: models.SuggestionCategory, Value: category.Name, Score: category.Score})
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,161 @@
+package tagging
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// Keyword extraction tuning
+const (
+	minKeywordLength    = 4
+	minKeywordFrequency = 2
+	maxKeywordScore     = 0.5 // New keywords never outrank known tags
+	knownMatchSaturate  = 3   // Occurrences at which a known name scores 1
+)
+
+// stopWords are frequent words that make poor tags
+var stopWords = map[string]bool{
+	"about": true, "after": true, "also": true, "because": true, "been": true,
+	"before": true, "being": true, "between": true, "both": true, "could": true,
+	"does": true, "each": true, "from": true, "have": true, "here": true,
+	"into": true, "more": true, "most": true, "must": true, "only": true,
+	"other": true, "over": true, "same": true, "shall": true, "should": true,
+	"some": true, "such": true, "than": true, "that": true, "their": true,
+	"them": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "those": true, "through": true, "under": true, "upon": true,
+	"very": true, "were": true, "what": true, "when": true, "where": true,
+	"which": true, "while": true, "will": true, "with": true, "within": true,
+	"would": true, "your": true,
+}
+
+// KeywordTagger suggests tags and categories by keyword extraction: known tag
+// and category names found in the text, followed by the most frequent words
+type KeywordTagger struct {
+	maxTags int
+}
+
+// NewKeywordTagger creates a keyword tagger suggesting at most maxTags tags
+func NewKeywordTagger(maxTags int) *KeywordTagger {
+	return &KeywordTagger{maxTags: maxTags}
+}
+
+// Name identifies the tagger
+func (t *KeywordTagger) Name() string {
+	return "keywords"
+}
+
+// Suggest extracts suggestions from the input text
+func (t *KeywordTagger) Suggest(ctx context.Context, input *Input) ([]Suggestion, error) {
+	words := tokenize(input.Text)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	frequency := make(map[string]int)
+	for _, word := range words {
+		frequency[word]++
+	}
+	joined := " " + strings.Join(words, " ") + " "
+
+	var suggestions []Suggestion
+	known := make(map[string]bool)
+
+	// Known tags found in the text
+	for _, tag := range input.Tags {
+		key := strings.Join(tokenize(tag), " ")
+		if key == "" || known[key] {
+			continue
+		}
+		known[key] = true
+		if count := strings.Count(joined, " "+key+" "); count > 0 {
+			suggestions = append(suggestions, Suggestion{Kind: models.SuggestionTag, Value: tag, Score: matchScore(count)})
+		}
+	}
+	sortSuggestions(suggestions)
+	if len(suggestions) > t.maxTags {
+		suggestions = suggestions[:t.maxTags]
+	}
+
+	// Frequent words not yet used as tags
+	var keywords []string
+	for word, count := range frequency {
+		if count >= minKeywordFrequency && !known[word] && isKeyword(word) {
+			keywords = append(keywords, word)
+		}
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if frequency[keywords[i]] != frequency[keywords[j]] {
+			return frequency[keywords[i]] > frequency[keywords[j]]
+		}
+		return keywords[i] < keywords[j]
+	})
+	for _, word := range keywords {
+		if len(suggestions) >= t.maxTags {
+			break
+		}
+		score := maxKeywordScore * float64(frequency[word]) / float64(frequency[keywords[0]])
+		suggestions = append(suggestions, Suggestion{Kind: models.SuggestionTag, Value: word, Score: score})
+	}
+
+	// The category mentioned most often
+	var category *Suggestion
+	for _, name := range input.Categories {
+		key := strings.Join(tokenize(name), " ")
+		if key == "" {
+			continue
+		}
+		if count := strings.Count(joined, " "+key+" "); count > 0 {
+			if score := matchScore(count); category == nil || score > category.Score {
+				category = &Suggestion{Kind: models.SuggestionCategory, Value: name, Score: score}
+			}
+		}
+	}
+	if category != nil {
+		suggestions = append(suggestions, *category)
+	}
+
+	return suggestions, nil
+}
+
+// tokenize splits text into lower-case words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// isKeyword reports whether a word may become a new tag
+func isKeyword(word string) bool {
+	if utf8.RuneCountInString(word) < minKeywordLength || stopWords[word] {
+		return false
+	}
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchScore scores a known name by how often it occurs in the text
+func matchScore(count int) float64 {
+	if count >= knownMatchSaturate {
+		return 1
+	}
+	return maxKeywordScore + (1-maxKeywordScore)*float64(count)/knownMatchSaturate
+}
+
+// sortSuggestions orders suggestions by descending score, then by value
+func sortSuggestions(suggestions []Suggestion) {
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Value < suggestions[j].Value
+	})
+}
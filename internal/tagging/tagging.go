@@ -0,0 +1,32 @@
+// Package tagging suggests tags and categories for document text
+package tagging
+
+import (
+	"context"
+
+	"github.com/nshmdayo/in-house-datamanagement-system-sample/internal/database/models"
+)
+
+// Suggestion is a tag or category proposed for a document with a confidence
+// score between 0 and 1
+type Suggestion struct {
+	Kind  models.SuggestionKind
+	Value string
+	Score float64
+}
+
+// Input is the text to analyze along with the tags and categories already in
+// use, which taggers prefer over inventing new names
+type Input struct {
+	Text       string
+	Tags       []string
+	Categories []string
+}
+
+// Tagger analyzes document text and proposes tags and categories
+type Tagger interface {
+	// Name identifies the tagger in stored suggestions
+	Name() string
+	// Suggest returns suggestions for the input
+	Suggest(ctx context.Context, input *Input) ([]Suggestion, error)
+}
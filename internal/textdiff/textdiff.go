@@ -0,0 +1,204 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCells bounds the size of the LCS table (lines of a times lines of b,
+// after trimming the common prefix and suffix)
+const maxCells = 4_000_000
+
+// ErrTooLarge is returned when the inputs are too large to diff
+var ErrTooLarge = fmt.Errorf("inputs are too large to diff")
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is a single line-level edit
+type op struct {
+	kind opKind
+	a    int // line index in a
+	b    int // line index in b
+}
+
+// Unified returns a unified diff of a and b with the given number of context lines.
+// It returns an empty string when the inputs are equal.
+func Unified(a, b, nameA, nameB string, context int) (string, error) {
+	if a == b {
+		return "", nil
+	}
+
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+
+	ops, err := diffLines(linesA, linesB)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+
+	for _, hunk := range groupHunks(ops, context) {
+		writeHunk(&out, hunk, linesA, linesB)
+	}
+
+	return out.String(), nil
+}
+
+// splitLines splits text into lines without their line terminators
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a minimal line edit script using the longest common subsequence
+func diffLines(a, b []string) ([]op, error) {
+	// Trim common prefix and suffix to keep the LCS table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	n, m := len(midA), len(midB)
+
+	if int64(n+1)*int64(m+1) > maxCells {
+		return nil, ErrTooLarge
+	}
+
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+	width := m + 1
+	lcs := make([]int32, (n+1)*width)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else if lcs[(i+1)*width+j] >= lcs[i*width+j+1] {
+				lcs[i*width+j] = lcs[(i+1)*width+j]
+			} else {
+				lcs[i*width+j] = lcs[i*width+j+1]
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, op{kind: opEqual, a: i, b: i})
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && midA[i] == midB[j]:
+			ops = append(ops, op{kind: opEqual, a: prefix + i, b: prefix + j})
+			i++
+			j++
+		case i < n && (j == m || lcs[(i+1)*width+j] >= lcs[i*width+j+1]):
+			ops = append(ops, op{kind: opDelete, a: prefix + i, b: prefix + j})
+			i++
+		default:
+			ops = append(ops, op{kind: opInsert, a: prefix + i, b: prefix + j})
+			j++
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, op{kind: opEqual, a: len(a) - suffix + k, b: len(b) - suffix + k})
+	}
+
+	return ops, nil
+}
+
+// groupHunks splits an edit script into hunks of changes with surrounding context
+func groupHunks(ops []op, context int) [][]op {
+	var hunks [][]op
+
+	start := -1
+	end := -1
+	for idx, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+
+		from := idx - context
+		if from < 0 {
+			from = 0
+		}
+		to := idx + context + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		if start >= 0 && from <= end {
+			end = to
+			continue
+		}
+
+		if start >= 0 {
+			hunks = append(hunks, ops[start:end])
+		}
+		start, end = from, to
+	}
+
+	if start >= 0 {
+		hunks = append(hunks, ops[start:end])
+	}
+
+	return hunks
+}
+
+// writeHunk writes a single hunk with its header
+func writeHunk(out *strings.Builder, hunk []op, a, b []string) {
+	startA, startB := hunk[0].a, hunk[0].b
+	countA, countB := 0, 0
+	for _, o := range hunk {
+		switch o.kind {
+		case opEqual:
+			countA++
+			countB++
+		case opDelete:
+			countA++
+		case opInsert:
+			countB++
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB))
+
+	for _, o := range hunk {
+		switch o.kind {
+		case opEqual:
+			out.WriteString(" " + a[o.a] + "\n")
+		case opDelete:
+			out.WriteString("-" + a[o.a] + "\n")
+		case opInsert:
+			out.WriteString("+" + b[o.b] + "\n")
+		}
+	}
+}
+
+// hunkRange formats a hunk range in unified diff notation (1-based)
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,443 @@
+package timestamp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxResponseSize is the largest response accepted from a timestamping
+// authority
+const maxResponseSize = 256 * 1024
+
+var (
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidMessageDigest   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+)
+
+// Errors returned when checking a timestamp token
+var (
+	// ErrImprintMismatch is returned when a token timestamps another hash
+	ErrImprintMismatch = errors.New("timestamp token is for a different hash")
+	// ErrInvalidSignature is returned when the token's signature doesn't
+	// verify with the signer's certificate
+	ErrInvalidSignature = errors.New("timestamp token signature is invalid")
+)
+
+// messageImprint is the hash a timestamp is requested for
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// timeStampReq is an RFC 3161 TimeStampReq
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional,default:false"`
+}
+
+// pkiStatusInfo reports whether a timestamp was granted
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+// timeStampResp is an RFC 3161 TimeStampResp
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// contentInfo is a CMS ContentInfo
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// signedData is a CMS SignedData
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// encapsulatedContentInfo carries the signed TSTInfo
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+// signerInfo is a CMS SignerInfo
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// issuerAndSerialNumber identifies a signer's certificate
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// attribute is a CMS signed attribute
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// accuracy is how far the timestamp may be off
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// tstInfo is the signed content of a timestamp token
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional,default:false"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// Token is a parsed RFC 3161 timestamp token whose signature has been
+// checked
+type Token struct {
+	Raw           []byte
+	GenTime       time.Time
+	SerialNumber  string
+	Policy        string
+	HashedMessage []byte
+	Signer        *x509.Certificate
+	Certificates  []*x509.Certificate
+
+	nonce *big.Int
+}
+
+// Client requests timestamps from an RFC 3161 timestamping authority
+type Client struct {
+	client *http.Client
+	url    string
+}
+
+// NewClient creates a new client for the timestamping authority at url
+func NewClient(url string) *Client {
+	return &Client{
+		client: &http.Client{Timeout: 30 * time.Second},
+		url:    url,
+	}
+}
+
+// URL returns the timestamping authority's URL
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Timestamp requests a signed timestamp for a SHA-256 digest
+func (c *Client) Timestamp(ctx context.Context, digest []byte) (*Token, error) {
+	if len(digest) != 32 {
+		return nil, fmt.Errorf("digest must be 32 bytes, got %d", len(digest))
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	request, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest,
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode timestamp request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create timestamp request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach timestamping authority: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("timestamping authority returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamp response: %w", err)
+	}
+	if len(body) > maxResponseSize {
+		return nil, fmt.Errorf("timestamp response is too large")
+	}
+
+	var response timeStampResp
+	if _, err := asn1.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("invalid timestamp response: %w", err)
+	}
+	// 0 is granted, 1 granted with modifications
+	if response.Status.Status > 1 {
+		return nil, fmt.Errorf("timestamp request rejected with status %d", response.Status.Status)
+	}
+	if len(response.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp response has no token")
+	}
+
+	token, err := ParseToken(response.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(token.HashedMessage, digest) {
+		return nil, ErrImprintMismatch
+	}
+	if token.nonce == nil || token.nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("timestamp token nonce doesn't match the request")
+	}
+
+	return token, nil
+}
+
+// ParseToken decodes a DER timestamp token and checks that it is signed by
+// the certificate it names
+func ParseToken(raw []byte) (*Token, error) {
+	var info contentInfo
+	if _, err := asn1.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("invalid timestamp token: %w", err)
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("timestamp token is not signed data")
+	}
+
+	var signed signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		return nil, fmt.Errorf("invalid timestamp signed data: %w", err)
+	}
+	if !signed.EncapContentInfo.EContentType.Equal(oidTSTInfo) || len(signed.EncapContentInfo.EContent) == 0 {
+		return nil, fmt.Errorf("timestamp token has no timestamp info")
+	}
+	if len(signed.SignerInfos) != 1 {
+		return nil, fmt.Errorf("timestamp token has %d signers", len(signed.SignerInfos))
+	}
+
+	var tst tstInfo
+	if _, err := asn1.Unmarshal(signed.EncapContentInfo.EContent, &tst); err != nil {
+		return nil, fmt.Errorf("invalid timestamp info: %w", err)
+	}
+
+	var certificates []*x509.Certificate
+	if len(signed.Certificates.Bytes) > 0 {
+		parsed, err := x509.ParseCertificates(signed.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp certificates: %w", err)
+		}
+		certificates = parsed
+	}
+
+	signer := signed.SignerInfos[0]
+	certificate, err := findSigner(signer.SID, certificates)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(&signer, signed.EncapContentInfo.EContent, certificate); err != nil {
+		return nil, err
+	}
+
+	return &Token{
+		Raw:           raw,
+		GenTime:       tst.GenTime,
+		SerialNumber:  tst.SerialNumber.String(),
+		Policy:        tst.Policy.String(),
+		HashedMessage: tst.MessageImprint.HashedMessage,
+		Signer:        certificate,
+		Certificates:  certificates,
+		nonce:         tst.Nonce,
+	}, nil
+}
+
+// Verify checks that the token timestamps the digest and that the signer's
+// certificate chains to one of roots, or to the system roots with nil, and
+// may sign timestamps
+func (t *Token) Verify(digest []byte, roots *x509.CertPool) error {
+	if !bytes.Equal(t.HashedMessage, digest) {
+		return ErrImprintMismatch
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range t.Certificates {
+		intermediates.AddCert(certificate)
+	}
+	if _, err := t.Signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}); err != nil {
+		return fmt.Errorf("timestamp certificate is not trusted: %w", err)
+	}
+	return nil
+}
+
+// findSigner returns the certificate the signer identifier names
+func findSigner(sid asn1.RawValue, certificates []*x509.Certificate) (*x509.Certificate, error) {
+	for _, certificate := range certificates {
+		switch {
+		case sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence:
+			var id issuerAndSerialNumber
+			if _, err := asn1.Unmarshal(sid.FullBytes, &id); err != nil {
+				return nil, fmt.Errorf("invalid timestamp signer: %w", err)
+			}
+			if bytes.Equal(certificate.RawIssuer, id.Issuer.FullBytes) && certificate.SerialNumber.Cmp(id.SerialNumber) == 0 {
+				return certificate, nil
+			}
+		case sid.Class == asn1.ClassContextSpecific && sid.Tag == 0:
+			if bytes.Equal(certificate.SubjectKeyId, sid.Bytes) {
+				return certificate, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("timestamp token doesn't include its signer's certificate")
+}
+
+// verifySignature checks the signer's signature over the signed attributes
+// and that they carry the digest of the timestamp info
+func verifySignature(signer *signerInfo, content []byte, certificate *x509.Certificate) error {
+	hash, ok := hashFor(signer.DigestAlgorithm.Algorithm)
+	if !ok {
+		return fmt.Errorf("unsupported timestamp digest algorithm %s", signer.DigestAlgorithm.Algorithm)
+	}
+	algorithm, ok := signatureAlgorithm(signer.SignatureAlgorithm.Algorithm, hash)
+	if !ok {
+		return fmt.Errorf("unsupported timestamp signature algorithm %s", signer.SignatureAlgorithm.Algorithm)
+	}
+
+	h := hash.New()
+	h.Write(content)
+	contentDigest := h.Sum(nil)
+
+	// Without signed attributes the content itself is signed
+	if len(signer.SignedAttrs.FullBytes) == 0 {
+		if err := certificate.CheckSignature(algorithm, content, signer.Signature); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	var messageDigest []byte
+	for rest := signer.SignedAttrs.Bytes; len(rest) > 0; {
+		var attr attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return fmt.Errorf("invalid timestamp signed attributes: %w", err)
+		}
+		if attr.Type.Equal(oidMessageDigest) {
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &messageDigest); err != nil {
+				return fmt.Errorf("invalid timestamp message digest: %w", err)
+			}
+		}
+	}
+	if !bytes.Equal(messageDigest, contentDigest) {
+		return ErrInvalidSignature
+	}
+
+	// The attributes are signed as a SET rather than with their implicit tag
+	signedAttrs := append([]byte{0x31}, signer.SignedAttrs.FullBytes[1:]...)
+	if err := certificate.CheckSignature(algorithm, signedAttrs, signer.Signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// hashFor returns the hash of a digest algorithm
+func hashFor(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	switch {
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, true
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, true
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+// signatureAlgorithm returns the x509 signature algorithm of a signer,
+// which may name just the key type and leave the hash to the digest
+// algorithm
+func signatureAlgorithm(oid asn1.ObjectIdentifier, hash crypto.Hash) (x509.SignatureAlgorithm, bool) {
+	switch {
+	case oid.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, true
+	case oid.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, true
+	case oid.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, true
+	case oid.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, true
+	case oid.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, true
+	case oid.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, true
+	case oid.Equal(oidRSAEncryption):
+		switch hash {
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, true
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, true
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, true
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, false
+}
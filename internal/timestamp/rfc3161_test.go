@@ -0,0 +1,393 @@
+package timestamp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	oidContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	testPolicy     = asn1.ObjectIdentifier{1, 2, 3, 4}
+)
+
+// testAuthority is a timestamping authority with a certificate issued by its
+// own CA
+type testAuthority struct {
+	ca          *x509.Certificate
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	roots       *x509.CertPool
+}
+
+func newTestAuthority(t *testing.T, usages []x509.ExtKeyUsage, notAfter time.Time) *testAuthority {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test TSA CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return &testAuthority{ca: ca, certificate: certificate, key: key, roots: roots}
+}
+
+// tokenOptions vary the tokens a testAuthority issues
+type tokenOptions struct {
+	nonce              *big.Int
+	genTime            time.Time
+	withoutSignedAttrs bool
+	attrsDigest        []byte // messageDigest attribute other than the content's
+	signedContent      []byte // Content signed other than the one embedded
+	withoutCertificate bool
+	digestAlgorithm    asn1.ObjectIdentifier
+	signers            int
+}
+
+// token issues a DER timestamp token for digest
+func (a *testAuthority) token(t *testing.T, digest []byte, opts tokenOptions) []byte {
+	t.Helper()
+	if opts.genTime.IsZero() {
+		opts.genTime = time.Now().UTC().Truncate(time.Second)
+	}
+	if opts.digestAlgorithm == nil {
+		opts.digestAlgorithm = oidSHA256
+	}
+	if opts.signers == 0 {
+		opts.signers = 1
+	}
+
+	sha256Algorithm := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	content := mustMarshal(t, tstInfo{
+		Version:        1,
+		Policy:         testPolicy,
+		MessageImprint: messageImprint{HashAlgorithm: sha256Algorithm, HashedMessage: digest},
+		SerialNumber:   big.NewInt(42),
+		GenTime:        opts.genTime,
+		Nonce:          opts.nonce,
+	})
+	signedContent := content
+	if opts.signedContent != nil {
+		signedContent = opts.signedContent
+	}
+
+	contentDigest := sha256.Sum256(signedContent)
+	signed := signedContent
+	var signedAttrs asn1.RawValue
+	if !opts.withoutSignedAttrs {
+		attrsDigest := contentDigest[:]
+		if opts.attrsDigest != nil {
+			attrsDigest = opts.attrsDigest
+		}
+		set := mustMarshalSet(t, []attribute{
+			{Type: oidContentType, Values: setOf(t, oidTSTInfo)},
+			{Type: oidMessageDigest, Values: setOf(t, attrsDigest)},
+		})
+		signed = set
+		// Stored with the implicit tag [0] instead of SET
+		signedAttrs = asn1.RawValue{FullBytes: append([]byte{0xa0}, set[1:]...)}
+	}
+	signedDigest := sha256.Sum256(signed)
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, signedDigest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sid := mustMarshal(t, issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: a.certificate.RawIssuer},
+		SerialNumber: a.certificate.SerialNumber,
+	})
+	signer := signerInfo{
+		Version:            1,
+		SID:                asn1.RawValue{FullBytes: sid},
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: opts.digestAlgorithm, Parameters: asn1.NullRawValue},
+		SignedAttrs:        signedAttrs,
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+		Signature:          signature,
+	}
+	var signers []signerInfo
+	for i := 0; i < opts.signers; i++ {
+		signers = append(signers, signer)
+	}
+
+	certificates := append(append([]byte{}, a.certificate.Raw...), a.ca.Raw...)
+	if opts.withoutCertificate {
+		certificates = a.ca.Raw
+	}
+	signedDataDER := mustMarshal(t, signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Algorithm},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: content},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certificates},
+		SignerInfos:      signers,
+	})
+
+	return mustMarshal(t, contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedDataDER},
+	})
+}
+
+func mustMarshal(t *testing.T, value interface{}) []byte {
+	t.Helper()
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func mustMarshalSet(t *testing.T, value interface{}) []byte {
+	t.Helper()
+	der, err := asn1.MarshalWithParams(value, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// setOf returns a SET holding a single value, as attribute values are
+func setOf(t *testing.T, value interface{}) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshal(t, value)}
+}
+
+func testDigest(s string) []byte {
+	digest := sha256.Sum256([]byte(s))
+	return digest[:]
+}
+
+var timeStamping = []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}
+
+func TestParseAndVerifyToken(t *testing.T) {
+	authority := newTestAuthority(t, timeStamping, time.Now().Add(24*time.Hour))
+	digest := testDigest("document")
+
+	for _, withoutSignedAttrs := range []bool{false, true} {
+		raw := authority.token(t, digest, tokenOptions{withoutSignedAttrs: withoutSignedAttrs})
+		token, err := ParseToken(raw)
+		if err != nil {
+			t.Fatalf("ParseToken(signed attributes: %v): %v", !withoutSignedAttrs, err)
+		}
+		if token.SerialNumber != "42" || token.Policy != testPolicy.String() || !token.Signer.Equal(authority.certificate) {
+			t.Errorf("token = %+v", token)
+		}
+		if err := token.Verify(digest, authority.roots); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	authority := newTestAuthority(t, timeStamping, time.Now().Add(24*time.Hour))
+	other := newTestAuthority(t, timeStamping, time.Now().Add(24*time.Hour))
+	withoutUsage := newTestAuthority(t, []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}, time.Now().Add(24*time.Hour))
+	digest := testDigest("document")
+
+	tests := []struct {
+		name      string
+		authority *testAuthority
+		opts      tokenOptions
+		digest    []byte
+		roots     *x509.CertPool
+	}{
+		{"another hash", authority, tokenOptions{}, testDigest("other"), authority.roots},
+		{"untrusted authority", authority, tokenOptions{}, digest, other.roots},
+		{"system roots", authority, tokenOptions{}, digest, nil},
+		{"certificate without timestamping usage", withoutUsage, tokenOptions{}, digest, withoutUsage.roots},
+		{"signed after the certificate expired", authority, tokenOptions{genTime: time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)}, digest, authority.roots},
+	}
+	for _, tt := range tests {
+		token, err := ParseToken(tt.authority.token(t, digest, tt.opts))
+		if err != nil {
+			t.Fatalf("%s: ParseToken: %v", tt.name, err)
+		}
+		if err := token.Verify(tt.digest, tt.roots); err == nil {
+			t.Errorf("%s: Verify succeeded", tt.name)
+		}
+	}
+
+	token, _ := ParseToken(authority.token(t, digest, tokenOptions{}))
+	if err := token.Verify(testDigest("other"), authority.roots); !errors.Is(err, ErrImprintMismatch) {
+		t.Errorf("Verify(another hash) = %v, want %v", err, ErrImprintMismatch)
+	}
+}
+
+func TestParseTokenRejectsBadSignatures(t *testing.T) {
+	authority := newTestAuthority(t, timeStamping, time.Now().Add(24*time.Hour))
+	digest := testDigest("document")
+	forged := mustMarshal(t, tstInfo{
+		Version:        1,
+		Policy:         testPolicy,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}, HashedMessage: testDigest("other")},
+		SerialNumber:   big.NewInt(1),
+		GenTime:        time.Now().UTC().Truncate(time.Second),
+	})
+
+	tests := []struct {
+		name string
+		opts tokenOptions
+	}{
+		{"message digest of other content", tokenOptions{attrsDigest: testDigest("other")}},
+		{"signature over other content", tokenOptions{signedContent: forged}},
+		{"signature over other content without attributes", tokenOptions{signedContent: forged, withoutSignedAttrs: true}},
+	}
+	for _, tt := range tests {
+		if _, err := ParseToken(authority.token(t, digest, tt.opts)); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: ParseToken = %v, want %v", tt.name, err, ErrInvalidSignature)
+		}
+	}
+
+	// Flipping a bit of the signature, which ends the token
+	raw := authority.token(t, digest, tokenOptions{})
+	raw[len(raw)-1] ^= 0x01
+	if _, err := ParseToken(raw); err == nil {
+		t.Error("ParseToken accepted a modified signature")
+	}
+}
+
+func TestParseTokenRejectsMalformedTokens(t *testing.T) {
+	authority := newTestAuthority(t, timeStamping, time.Now().Add(24*time.Hour))
+	digest := testDigest("document")
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"garbage", []byte("not a token")},
+		{"truncated", authority.token(t, digest, tokenOptions{})[:100]},
+		{"not signed data", mustMarshal(t, contentInfo{
+			ContentType: oidTSTInfo,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: mustMarshal(t, 1)},
+		})},
+		{"two signers", authority.token(t, digest, tokenOptions{signers: 2})},
+		{"signer certificate missing", authority.token(t, digest, tokenOptions{withoutCertificate: true})},
+		{"unsupported digest algorithm", authority.token(t, digest, tokenOptions{digestAlgorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}})},
+	}
+	for _, tt := range tests {
+		if _, err := ParseToken(tt.raw); err == nil {
+			t.Errorf("%s: ParseToken succeeded", tt.name)
+		}
+	}
+}
+
+// authorityServer answers timestamp requests with respond
+func authorityServer(t *testing.T, respond func(req timeStampReq) (int, []byte)) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req timeStampReq
+		if _, err := asn1.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid timestamp request: %v", err)
+		}
+		status, response := respond(req)
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.WriteHeader(status)
+		w.Write(response)
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(server.URL)
+}
+
+func granted(t *testing.T, token []byte) []byte {
+	return mustMarshal(t, timeStampResp{TimeStampToken: asn1.RawValue{FullBytes: token}})
+}
+
+func TestClientTimestamp(t *testing.T) {
+	authority := newTestAuthority(t, timeStamping, time.Now().Add(24*time.Hour))
+	digest := testDigest("document")
+
+	tests := []struct {
+		name    string
+		respond func(req timeStampReq) (int, []byte)
+		ok      bool
+	}{
+		{"granted", func(req timeStampReq) (int, []byte) {
+			return http.StatusOK, granted(t, authority.token(t, req.MessageImprint.HashedMessage, tokenOptions{nonce: req.Nonce}))
+		}, true},
+		{"other nonce", func(req timeStampReq) (int, []byte) {
+			return http.StatusOK, granted(t, authority.token(t, req.MessageImprint.HashedMessage, tokenOptions{nonce: big.NewInt(1)}))
+		}, false},
+		{"no nonce", func(req timeStampReq) (int, []byte) {
+			return http.StatusOK, granted(t, authority.token(t, req.MessageImprint.HashedMessage, tokenOptions{}))
+		}, false},
+		{"other hash", func(req timeStampReq) (int, []byte) {
+			return http.StatusOK, granted(t, authority.token(t, testDigest("other"), tokenOptions{nonce: req.Nonce}))
+		}, false},
+		{"rejected", func(req timeStampReq) (int, []byte) {
+			return http.StatusOK, mustMarshal(t, timeStampResp{Status: pkiStatusInfo{Status: 2}})
+		}, false},
+		{"granted without token", func(req timeStampReq) (int, []byte) {
+			return http.StatusOK, mustMarshal(t, timeStampResp{})
+		}, false},
+		{"malformed response", func(req timeStampReq) (int, []byte) {
+			return http.StatusOK, []byte("not a response")
+		}, false},
+		{"server error", func(req timeStampReq) (int, []byte) {
+			return http.StatusInternalServerError, []byte("unavailable")
+		}, false},
+	}
+	for _, tt := range tests {
+		client := authorityServer(t, tt.respond)
+		token, err := client.Timestamp(context.Background(), digest)
+		if tt.ok && (err != nil || token.Verify(digest, authority.roots) != nil) {
+			t.Errorf("%s: Timestamp = %v", tt.name, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: Timestamp succeeded", tt.name)
+		}
+	}
+
+	if _, err := NewClient("http://127.0.0.1:0").Timestamp(context.Background(), digest[:16]); err == nil {
+		t.Error("Timestamp accepted a short digest")
+	}
+}
@@ -0,0 +1,68 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// qpdfExitWarnings is the exit code of qpdf when it succeeded with warnings,
+// common for slightly malformed PDFs
+const qpdfExitWarnings = 3
+
+// QPDFStamper stamps PDFs by overlaying a generated watermark page with qpdf
+type QPDFStamper struct {
+	binary string
+}
+
+// NewQPDFStamper creates a stamper running the qpdf binary
+func NewQPDFStamper(binary string) *QPDFStamper {
+	return &QPDFStamper{binary: binary}
+}
+
+// Name returns the stamper name
+func (s *QPDFStamper) Name() string {
+	return "qpdf"
+}
+
+// Stamp overlays the watermark onto every page of pdf
+func (s *QPDFStamper) Stamp(ctx context.Context, pdf []byte, lines []string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "watermark-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watermark directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.pdf")
+	overlay := filepath.Join(dir, "overlay.pdf")
+	output := filepath.Join(dir, "output.pdf")
+
+	if err := os.WriteFile(input, pdf, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write watermark input: %w", err)
+	}
+	if err := os.WriteFile(overlay, overlayPDF(lines), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write watermark overlay: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.binary, input, "--overlay", overlay, "--repeat=1", "--", output)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != qpdfExitWarnings {
+			return nil, fmt.Errorf("qpdf failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	}
+
+	stamped, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("qpdf produced no PDF: %w", err)
+	}
+
+	return stamped, nil
+}
@@ -0,0 +1,87 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Stamper stamps text onto every page of a PDF
+type Stamper interface {
+	// Stamp returns pdf with lines of text stamped on every page
+	Stamp(ctx context.Context, pdf []byte, lines []string) ([]byte, error)
+	// Name returns the stamper name for logging
+	Name() string
+}
+
+const (
+	// overlayWidth and overlayHeight are the A4 size of the overlay page in
+	// points; the overlay is scaled to each page it is stamped on
+	overlayWidth  = 595
+	overlayHeight = 842
+	// overlayOpacity is the opacity of the watermark text
+	overlayOpacity = 0.2
+)
+
+// overlayPDF builds a single transparent page carrying the watermark: the
+// text repeated diagonally across the page, and once along the bottom edge
+// so it survives cropping
+func overlayPDF(lines []string) []byte {
+	text := escapeText(strings.Join(lines, "  |  "))
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "q /GS1 gs 0.4 g BT /F1 14 Tf\n")
+	for y := -200; y < overlayHeight+200; y += 140 {
+		for x := -300; x < overlayWidth; x += 420 {
+			fmt.Fprintf(&content, "0.7071 0.7071 -0.7071 0.7071 %d %d Tm (%s) Tj\n", x, y, text)
+		}
+	}
+	fmt.Fprintf(&content, "ET Q\nq 0.3 g BT /F1 7 Tf 1 0 0 1 20 10 Tm (%s) Tj ET Q\n", text)
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 4 0 R >> /ExtGState << /GS1 5 0 R >> >> /Contents 6 0 R >>",
+			overlayWidth, overlayHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Type /ExtGState /ca %.2f /CA %.2f >>", overlayOpacity, overlayOpacity),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return pdf.Bytes()
+}
+
+// escapeText escapes text for a PDF string literal. Characters the standard
+// Helvetica encoding can't show are replaced with "?".
+func escapeText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7E:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}